@@ -2,23 +2,56 @@ package main
 
 import (
 	"context"
+	"fmt"
 
 	corev1 "k8s.io/api/core/v1"
 
 	"github.com/nubank/workflows/pkg/github"
+	"github.com/nubank/workflows/pkg/logstore"
+	"github.com/nubank/workflows/pkg/reconciler/checkrun"
+	"github.com/nubank/workflows/pkg/reconciler/commitstatus"
+	"github.com/nubank/workflows/pkg/reconciler/deployment"
+	"github.com/nubank/workflows/pkg/reconciler/logarchive"
+	"github.com/nubank/workflows/pkg/reconciler/summarycomment"
 	"github.com/nubank/workflows/pkg/reconciler/workflow"
+	"github.com/nubank/workflows/pkg/scm"
 	"knative.dev/pkg/injection"
 	"knative.dev/pkg/injection/sharedmain"
 )
 
 func main() {
-	githubClient := github.NewClientOrDie()
+	githubHost, err := github.NewHostFromEnv()
+	if err != nil {
+		panic(fmt.Errorf("Error configuring the Github host: %w", err))
+	}
 
 	ctx := injection.WithNamespaceScope(context.Background(), corev1.NamespaceAll)
 
-	ctx = github.WithDeployKeysReconciler(ctx, githubClient)
-	ctx = github.WithRepoReconciler(ctx, githubClient)
-	ctx = github.WithWebhookReconciler(ctx, githubClient)
+	github.RegisterMetrics()
+	githubClients := github.NewClientFactoryOrDie(ctx, githubHost)
 
-	sharedmain.MainWithContext(ctx, "controller", workflow.NewController)
+	providers, err := scm.NewProvidersFromEnv(githubHost, githubClients)
+	if err != nil {
+		panic(fmt.Errorf("Error configuring the providers hosting repositories: %w", err))
+	}
+
+	ctx = scm.WithProviders(ctx, providers)
+	ctx = github.WithCheckRunReconciler(ctx, githubClients)
+	ctx = github.WithCommitStatusReporter(ctx, githubClients)
+	ctx = github.WithCommentReconciler(ctx, githubClients)
+	ctx = github.WithDeploymentReporter(ctx, githubClients)
+
+	controllers := []injection.ControllerConstructor{workflow.NewController, checkrun.NewController, commitstatus.NewController, summarycomment.NewController, deployment.NewController}
+
+	logStore, err := logstore.NewStoreFromEnv()
+	if err != nil {
+		panic(fmt.Errorf("Error configuring the logs store: %w", err))
+	}
+
+	if logStore != nil {
+		ctx = logstore.WithStore(ctx, logStore)
+		controllers = append(controllers, logarchive.NewController)
+	}
+
+	sharedmain.MainWithContext(ctx, "controller", controllers...)
 }
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/nubank/workflows/pkg/testreports"
+)
+
+// main runs as the last step of tasks declaring testReports. It collects JUnit
+// reports and publishes their summary as a task result.
+func main() {
+	result := flag.String("result", "", "File where the summary of test reports is written to")
+	failedStepFile := flag.String("failed-step-file", "", "File holding the name of a previous step that failed, if any")
+	maxBytes := flag.Int("max-bytes", testreports.MaxSummaryBytes, "Maximum size of the summary written to the result file")
+	flag.Parse()
+
+	if err := collect(*result, *maxBytes, flag.Args()); err != nil {
+		fmt.Fprintf(os.Stderr, "Error collecting test reports: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Previous steps don't fail the task by themselves, so reports can be
+	// collected after tests fail. Fail it on their behalf.
+	if *failedStepFile != "" {
+		if failedStep, err := ioutil.ReadFile(*failedStepFile); err == nil {
+			fmt.Fprintf(os.Stderr, "Step %s failed\n", strings.TrimSpace(string(failedStep)))
+			os.Exit(1)
+		}
+	}
+}
+
+// collect summarizes reports matching the supplied globs and writes the
+// summary to the result file.
+func collect(result string, maxBytes int, globs []string) error {
+	dir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	reports, err := testreports.FindReports(dir, globs)
+	if err != nil {
+		return err
+	}
+
+	if len(reports) == 0 {
+		fmt.Printf("No test reports match %s\n", strings.Join(globs, ", "))
+	}
+
+	summary, err := testreports.Summarize(dir, reports)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Tests: %d passed, %d failed, %d skipped (%d reports)\n", summary.Passed, summary.Failed, summary.Skipped, len(reports))
+	for _, failure := range summary.Failures {
+		location := ""
+		if failure.File != "" {
+			location = fmt.Sprintf(" (%s:%d)", failure.File, failure.Line)
+		}
+		fmt.Printf("  FAIL %s%s: %s\n", failure.Name, location, failure.Message)
+	}
+
+	encoded, err := summary.Encode(maxBytes)
+	if err != nil {
+		return err
+	}
+
+	if result == "" {
+		return nil
+	}
+	return ioutil.WriteFile(result, []byte(encoded), 0644)
+}
@@ -34,3 +34,4 @@ gen_mocks() {
 
 gen_mocks pkg/github/interfaces.go
 gen_mocks pkg/github/workflow_reader.go
+gen_mocks pkg/github/membership.go
@@ -48,6 +48,15 @@ type Defaults struct {
 	// Annotations to be applied to all PipelineRun objects created by workflows.
 	// Useful for defining common metadata observed by other controllers.
 	Annotations map[string]string
+
+	// Additional markers that, when present in head commit messages or pull
+	// request titles, prevent workflows from being triggered. They are
+	// honored along with the built-in [skip ci] and [ci skip] markers.
+	SkipDirectives []string
+
+	// Image of the built-in step that collects test reports of tasks
+	// declaring testReports. Test reports aren't collected when it's unset.
+	TestReportsImage string
 }
 
 // parser is a function that turns the given string into a higher object and
@@ -70,10 +79,14 @@ func parseDefaultImage(defaults *Defaults, value string) error {
 }
 
 func parseWebhook(defaults *Defaults, value string) error {
-	_, err := url.ParseRequestURI(value)
+	u, err := url.ParseRequestURI(value)
 	if err != nil {
 		return fmt.Errorf("Invalid Webhook URL: %w", err)
 	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" {
+		return fmt.Errorf("Invalid Webhook URL %s: expected an absolute HTTP(S) URL without query", value)
+	}
 	defaults.Webhook = value
 
 	return nil
@@ -109,14 +122,31 @@ func parseAnnotations(defaults *Defaults, value string) error {
 	return nil
 }
 
+func parseSkipDirectives(defaults *Defaults, value string) error {
+	var skipDirectives []string
+	if err := yaml.Unmarshal([]byte(value), &skipDirectives); err != nil {
+		return fmt.Errorf("Invalid skip directives: %s", err)
+	}
+	defaults.SkipDirectives = skipDirectives
+
+	return nil
+}
+
+func parseTestReportsImage(defaults *Defaults, value string) error {
+	defaults.TestReportsImage = value
+	return nil
+}
+
 // parsers maps keys of known configs to a parser function.
 var parsers = map[string]parser{
-	"default-events": parseDefaultEvents,
-	"default-image":  parseDefaultImage,
-	"webhook":        parseWebhook,
-	"workflows-dir":  parseWorkflowsDir,
-	"labels":         parseLabels,
-	"annotations":    parseAnnotations,
+	"default-events":     parseDefaultEvents,
+	"default-image":      parseDefaultImage,
+	"webhook":            parseWebhook,
+	"workflows-dir":      parseWorkflowsDir,
+	"labels":             parseLabels,
+	"annotations":        parseAnnotations,
+	"skip-directives":    parseSkipDirectives,
+	"test-reports-image": parseTestReportsImage,
 }
 
 // NewDefaultsFromConfigMap takes a ConfigMap and returns a Defaults object.
@@ -18,12 +18,14 @@ func TestNewDefaults(t *testing.T) {
 	}{{
 		configMap: "valid-config-defaults.yaml",
 		defaults: &Defaults{
-			DefaultEvents: []string{"push", "pull_request"},
-			DefaultImage:  "ubuntu",
-			Webhook:       "https://hooks.example.com",
-			WorkflowsDir:  ".my-org/workflows",
-			Labels:        map[string]string{"workflows.dev/example-label": "example"},
-			Annotations:   map[string]string{"workflows.dev/example-annotation": "example"},
+			DefaultEvents:    []string{"push", "pull_request"},
+			DefaultImage:     "ubuntu",
+			Webhook:          "https://hooks.example.com",
+			WorkflowsDir:     ".my-org/workflows",
+			Labels:           map[string]string{"workflows.dev/example-label": "example"},
+			Annotations:      map[string]string{"workflows.dev/example-annotation": "example"},
+			SkipDirectives:   []string{"[no ci]", "[skip workflows]"},
+			TestReportsImage: "ghcr.io/nubank/workflows/test-reports",
 		},
 		valid: true,
 	},
@@ -56,6 +58,14 @@ func TestNewDefaults(t *testing.T) {
 			configMap: "invalid-config-defaults-5.yaml",
 			valid:     false,
 		},
+		{
+			configMap: "invalid-config-defaults-6.yaml",
+			valid:     false,
+		},
+		{
+			configMap: "invalid-config-defaults-7.yaml",
+			valid:     false,
+		},
 	}
 
 	for _, test := range tests {
@@ -1,3 +1,4 @@
+//go:build !ignore_autogenerated
 // +build !ignore_autogenerated
 
 /*
@@ -42,6 +43,11 @@ func (in *Defaults) DeepCopyInto(out *Defaults) {
 			(*out)[key] = val
 		}
 	}
+	if in.SkipDirectives != nil {
+		in, out := &in.SkipDirectives, &out.SkipDirectives
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
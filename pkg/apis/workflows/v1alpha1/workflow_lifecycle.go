@@ -38,6 +38,13 @@ func (w *WorkflowStatus) InitializeConditions() {
 	condSet.Manage(w).InitializeConditions()
 }
 
+func (w *WorkflowStatus) MarkCredentialsError(message string) {
+	condSet.Manage(w).MarkFalse(
+		WorkflowConditionReady,
+		"CredentialsError",
+		message)
+}
+
 func (w *WorkflowStatus) MarkDeployKeysError(message string) {
 	condSet.Manage(w).MarkFalse(
 		WorkflowConditionReady,
@@ -37,6 +37,8 @@ const (
 
 	webhookIDFormat = "workflows.dev/github.%s.%s.webhook-id"
 
+	webhookUUIDFormat = "workflows.dev/bitbucket.%s.%s.webhook-uuid"
+
 	deployKeyIDFormat = "workflows.dev/github.%s.%s.key-id"
 )
 
@@ -92,6 +94,12 @@ func (w *Workflow) SetDeployKeyID(repo *Repository, id int64) {
 	w.Status.Annotations[fmt.Sprintf(deployKeyIDFormat, repo.Owner, repo.Name)] = fmt.Sprint(id)
 }
 
+// DeleteDeployKeyID removes the deploy key id associated to the supplied
+// repository from the workflow in question.
+func (w *Workflow) DeleteDeployKeyID(repo *Repository) {
+	delete(w.Status.Annotations, fmt.Sprintf(deployKeyIDFormat, repo.Owner, repo.Name))
+}
+
 // GetWebhookID returns the id of a Webhook associated to the repository in
 // question or nil if no Webhook has been created yet.
 func (w *Workflow) GetWebhookID() *int64 {
@@ -117,6 +125,24 @@ func (w *Workflow) SetWebhookID(id int64) {
 	w.Status.Annotations[fmt.Sprintf(webhookIDFormat, repo.Owner, repo.Name)] = fmt.Sprint(id)
 }
 
+// GetWebhookUUID returns the UUID of a Webhook associated to the repository in
+// question or an empty string if no Webhook has been created yet. It's used by
+// providers identifying Webhooks by UUIDs (e.g. Bitbucket Cloud).
+func (w *Workflow) GetWebhookUUID() string {
+	repo := w.Spec.Repository
+	return w.Status.Annotations[fmt.Sprintf(webhookUUIDFormat, repo.Owner, repo.Name)]
+}
+
+// SetWebhookUUID stores the Webhook UUID associated to the supplied repository
+// as a metadata in the workflow in question.
+func (w *Workflow) SetWebhookUUID(uuid string) {
+	if w.Status.Annotations == nil {
+		w.Status.Annotations = make(map[string]string)
+	}
+	repo := w.Spec.Repository
+	w.Status.Annotations[fmt.Sprintf(webhookUUIDFormat, repo.Owner, repo.Name)] = uuid
+}
+
 // GetDeployKeysSecretName returns the name of the private SSH keys associated to
 // this workflow.
 func (w *Workflow) GetDeployKeysSecretName() string {
@@ -128,6 +154,78 @@ func (w *Workflow) GetWebhookSecretName() string {
 	return fmt.Sprintf("%s-webhook-secret", w.GetName())
 }
 
+// GetCredentialsSecretName returns the name of the Secret holding the Github
+// credentials set by this workflow or an empty string if it doesn't set any.
+func (w *Workflow) GetCredentialsSecretName() string {
+	if w.Spec.Credentials == nil {
+		return ""
+	}
+	return w.Spec.Credentials.SecretName
+}
+
+// UsesInstallationTokens returns true if checkout steps authenticate with
+// installation tokens issued for each run rather than with deploy keys.
+func (w *Workflow) UsesInstallationTokens() bool {
+	return w.Spec.CheckoutAuth == InstallationTokenCheckoutAuth
+}
+
+// NeedsCheckoutCredentials returns true if any repository of the workflow
+// requires credentials to be checked out (see
+// Repository.NeedsSSHPrivateKeys).
+func (w *Workflow) NeedsCheckoutCredentials() bool {
+	for _, repo := range w.GetRepositories() {
+		if repo.NeedsSSHPrivateKeys() {
+			return true
+		}
+	}
+	return false
+}
+
+// CopyImmutableAttributes overrides the attributes of the workflow that can't
+// be changed by configuration read from repositories with those of the
+// supplied workflow (i.e. the one read from the cluster). Besides the
+// repositories and how they are checked out, it covers who can trigger the
+// workflow, the credentials it runs with, the commit statuses it reports and
+// the environments its tasks deploy to, since anyone opening a pull request
+// controls the configuration read from its head commit.
+func (w *Workflow) CopyImmutableAttributes(original *Workflow) {
+	original = original.DeepCopy()
+	w.Spec.Repository = original.Spec.Repository
+	w.Spec.AdditionalRepositories = original.Spec.AdditionalRepositories
+	w.Spec.CheckoutAuth = original.Spec.CheckoutAuth
+	w.Spec.Actors = original.Spec.Actors
+	w.Spec.When = original.Spec.When
+	w.Spec.Credentials = original.Spec.Credentials
+	w.Spec.CommitStatus = original.Spec.CommitStatus
+
+	// The default context is named after the workflow, whose metadata is
+	// read from the repository as well.
+	if w.Spec.CommitStatus != nil {
+		w.Spec.CommitStatus.Context = original.Spec.CommitStatus.GetContext(original)
+	}
+
+	// Tasks only deploy to the environment the task of the same name deploys
+	// to in the original workflow, if any.
+	for name, task := range w.Spec.Tasks {
+		if task == nil {
+			continue
+		}
+
+		task.Environment = nil
+		if originalTask := original.Spec.Tasks[name]; originalTask != nil {
+			task.Environment = originalTask.Environment
+		}
+	}
+}
+
+// GetProvider returns the platform hosting the workflow's repositories.
+func (w *Workflow) GetProvider() Provider {
+	if w.Spec.Repository == nil {
+		return GithubProvider
+	}
+	return w.Spec.Repository.GetProvider()
+}
+
 // GetHooksURL returns the URL that Github Webhooks must use to triger this
 // workflow.
 func (w *Workflow) GetHooksURL() string {
@@ -156,7 +254,9 @@ type WorkflowSpec struct {
 	// +optional
 	AdditionalRepositories []Repository `json:"additionalRepos,omitempty"`
 
-	// Names of Github events that trigger this workflow.
+	// Names of Github events that trigger this workflow. Webhooks are
+	// subscribed to issue_comment events as well, which deliver slash
+	// commands written on pull requests.
 	// +optional
 	Events []string `json:"events,omitempty"`
 
@@ -164,11 +264,57 @@ type WorkflowSpec struct {
 	// +optional
 	Branches []string `json:"branches,omitempty"`
 
+	// Configures the workflow to run on pull requests targeting those
+	// branches.
+	// +optional
+	BaseBranches []string `json:"baseBranches,omitempty"`
+
 	// Configures the workflow to run on push or pull_request events where
 	// those paths have been modified (created, changed or deleted).
 	// +optional
 	Paths []string `json:"paths,omitempty"`
 
+	// Restricts which Github users can trigger this workflow.
+	// +optional
+	Actors *Actors `json:"actors,omitempty"`
+
+	// Makes the workflow run even when head commit messages or pull request
+	// titles contain skip directives such as [skip ci].
+	// +optional
+	IgnoreSkipDirectives bool `json:"ignoreSkipDirectives,omitempty"`
+
+	// A CEL expression that must evaluate to true for the workflow to be
+	// triggered. Expressions have access to the full event payload (body),
+	// a normalized representation of the event (event) and metadata about
+	// the workflow (workflow).
+	// +optional
+	When string `json:"when,omitempty"`
+
+	// Reports the workflow's progress as classic Github commit statuses, in
+	// addition to check runs.
+	// +optional
+	CommitStatus *CommitStatus `json:"commitStatus,omitempty"`
+
+	// Posts a summary of the workflow's results as a comment on pull
+	// requests. The same comment is updated on subsequent runs.
+	// +optional
+	PullRequestComment *PullRequestComment `json:"pullRequestComment,omitempty"`
+
+	// How checkout steps authenticate with private repositories (and
+	// repositories with writable deploy keys): deployKeys (default) manages a
+	// deploy key per repository, whereas installationToken issues a
+	// short-lived Github App installation token restricted to the workflow's
+	// repositories whenever a run starts. Tokens expire after an hour, so
+	// repositories must be checked out before then.
+	// +optional
+	CheckoutAuth CheckoutAuth `json:"checkoutAuth,omitempty"`
+
+	// Credentials the workflow talks to Github with. Defaults to the
+	// github-credentials Secret of the workflow's namespace when it exists or
+	// to the Github App the controller was installed with otherwise.
+	// +optional
+	Credentials *Credentials `json:"credentials,omitempty"`
+
 	// Default settings that will apply to all tasks in the workflow.
 	// +optional
 	Defaults *Defaults `json:"defaults,omitempty"`
@@ -199,6 +345,23 @@ type Repository struct {
 	// Whether or not the repository is private.
 	// +optional
 	Private bool `json:"private,omitempty"`
+
+	// Platform hosting the repository: github (default), gitlab,
+	// bitbucket-cloud, bitbucket-server or gitea (which covers Forgejo as
+	// well). GitLab repositories are identified by the full path of their
+	// group (e.g. my-group/my-subgroup) and the project's path, Bitbucket
+	// Cloud ones by their workspace and slug and Bitbucket Server ones by
+	// their project key and slug.
+	// +optional
+	Provider Provider `json:"provider,omitempty"`
+}
+
+// GetProvider returns the platform hosting the repository.
+func (r *Repository) GetProvider() Provider {
+	if r.Provider == "" {
+		return GithubProvider
+	}
+	return r.Provider
 }
 
 // GetSSHPrivateKeyName returns the name of the SSH private key associated to this repository.
@@ -250,6 +413,107 @@ type DeployKey struct {
 	ReadOnly bool `json:"readOnly"`
 }
 
+// Provider is a platform hosting repositories.
+type Provider string
+
+// Supported providers.
+const (
+	GithubProvider          Provider = "github"
+	GitlabProvider          Provider = "gitlab"
+	BitbucketCloudProvider  Provider = "bitbucket-cloud"
+	BitbucketServerProvider Provider = "bitbucket-server"
+	GiteaProvider           Provider = "gitea"
+)
+
+// CheckoutAuth tells how checkout steps authenticate with repositories.
+type CheckoutAuth string
+
+// Supported checkout authentication methods.
+const (
+	DeployKeysCheckoutAuth        CheckoutAuth = "deployKeys"
+	InstallationTokenCheckoutAuth CheckoutAuth = "installationToken"
+)
+
+// Credentials points to a Secret, in the workflow's namespace, holding either a
+// Github App (app-id, private-key and optionally installation-id keys) or a
+// token (token key).
+type Credentials struct {
+
+	// Name of the Secret.
+	SecretName string `json:"secretName"`
+}
+
+// Actors restricts the Github users (i.e. event senders) that can trigger the
+// workflow in question.
+type Actors struct {
+
+	// Logins of users allowed to trigger the workflow.
+	// +optional
+	Allow []string `json:"allow,omitempty"`
+
+	// Logins of users that can never trigger the workflow.
+	// +optional
+	Deny []string `json:"deny,omitempty"`
+
+	// Github organizations whose members are allowed to trigger the workflow.
+	// +optional
+	Orgs []string `json:"orgs,omitempty"`
+
+	// Github teams, in the format org/team-slug, whose members are allowed
+	// to trigger the workflow.
+	// +optional
+	Teams []string `json:"teams,omitempty"`
+
+	// Whether or not bot accounts (e.g. dependabot[bot]) are prevented
+	// from triggering the workflow. Bots explicitly listed in allow are
+	// still accepted.
+	// +optional
+	DenyBots bool `json:"denyBots,omitempty"`
+}
+
+// CommitStatus configures how the workflow's progress is reported as Github
+// commit statuses.
+type CommitStatus struct {
+
+	// Label that differentiates this status from others. Defaults to
+	// workflows/<workflow name>.
+	// +optional
+	Context string `json:"context,omitempty"`
+
+	// Template of the URL statuses link to (e.g. a Tekton Dashboard page).
+	// Besides the usual workflow variables, $(pipelinerun.name) and
+	// $(pipelinerun.namespace) are substituted by the PipelineRun's name and
+	// namespace.
+	// +optional
+	TargetURL string `json:"targetURL,omitempty"`
+}
+
+// PullRequestComment configures the summary comment posted on pull requests.
+type PullRequestComment struct {
+
+	// Template of the URL where logs of the PipelineRun can be found.
+	// Besides the usual workflow variables, $(pipelinerun.name) and
+	// $(pipelinerun.namespace) are substituted by the PipelineRun's name and
+	// namespace.
+	// +optional
+	LogsURL string `json:"logsURL,omitempty"`
+}
+
+// GetContext returns the context of commit statuses reported for the
+// supplied workflow.
+func (c *CommitStatus) GetContext(workflow *Workflow) string {
+	if c.Context != "" {
+		return c.Context
+	}
+	return fmt.Sprintf("workflows/%s", workflow.GetName())
+}
+
+// HasAllowList returns true if the workflow restricts which actors can trigger
+// it or false otherwise.
+func (a *Actors) HasAllowList() bool {
+	return len(a.Allow) != 0 || len(a.Orgs) != 0 || len(a.Teams) != 0
+}
+
 // Defaults defines default settings to all tasks in the workflow.
 type Defaults struct {
 
@@ -273,6 +537,12 @@ type Task struct {
 	// +optional
 	Env map[string]string `json:"env,omitempty"`
 
+	// Deployment environment this task deploys to. When set, the task is
+	// reported as a Github deployment whose statuses follow the underlying
+	// TaskRun. Workflows read from repositories can't change it.
+	// +optional
+	Environment *Environment `json:"environment,omitempty"`
+
 	// List of upstream tasks this task depends on.
 	// +optional
 	Require []string `json:"requires,omitempty"`
@@ -301,6 +571,14 @@ type Task struct {
 	// +optional
 	Steps []EmbeddedStep `json:"steps,omitempty"`
 
+	// Globs of JUnit XML reports written by the task's steps, relative to
+	// the working directory of the steps (** matches any number of
+	// directories). A built-in step collects them once the other steps
+	// have run, even if they failed, and failures are reported on the
+	// workflow's check run.
+	// +optional
+	TestReports []string `json:"testReports,omitempty"`
+
 	// Time after which the task times out.
 	// +optional
 	Timeout *metav1.Duration `json:"timeout,omitempty"`
@@ -310,6 +588,20 @@ type Task struct {
 	Use *pipelinev1beta1.TaskRef `json:"uses,omitempty"`
 }
 
+// Environment identifies where a task deploys to.
+type Environment struct {
+
+	// Name of the environment (e.g. staging or production).
+	Name string `json:"name"`
+
+	// Template of the URL where the deployed application can be reached.
+	// Besides the usual workflow variables, $(pipelinerun.name) and
+	// $(pipelinerun.namespace) are substituted by the PipelineRun's name and
+	// namespace.
+	// +optional
+	URL string `json:"url,omitempty"`
+}
+
 // EmbeddedStep defines a step to be executed as part of a task.
 type EmbeddedStep struct {
 
@@ -18,7 +18,12 @@ package v1alpha1
 
 import (
 	"context"
+	"fmt"
+	"net/url"
+	"strings"
 
+	"github.com/nubank/workflows/pkg/expressions"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"knative.dev/pkg/apis"
 )
 
@@ -29,5 +34,236 @@ func (w *Workflow) Validate(ctx context.Context) *apis.FieldError {
 
 // Validate implements apis.Validatable
 func (ws *WorkflowSpec) Validate(ctx context.Context) *apis.FieldError {
+	var errs *apis.FieldError
+
+	if ws.Actors != nil {
+		errs = errs.Also(ws.Actors.Validate(ctx).ViaField("actors"))
+	}
+
+	if ws.Webhook != nil {
+		errs = errs.Also(ws.Webhook.Validate(ctx).ViaField("webhook"))
+	}
+
+	if ws.Credentials != nil {
+		errs = errs.Also(ws.Credentials.Validate(ctx).ViaField("credentials"))
+	}
+
+	if ws.Repository != nil {
+		errs = errs.Also(ws.validateProvider())
+	}
+
+	switch ws.CheckoutAuth {
+	case "", DeployKeysCheckoutAuth:
+	case InstallationTokenCheckoutAuth:
+		errs = errs.Also(ws.validateInstallationTokens())
+	default:
+		errs = errs.Also(&apis.FieldError{
+			Message: fmt.Sprintf("invalid value: %s", ws.CheckoutAuth),
+			Paths:   []string{"checkoutAuth"},
+			Details: fmt.Sprintf("expected %s or %s", DeployKeysCheckoutAuth, InstallationTokenCheckoutAuth),
+		})
+	}
+
+	if ws.When != "" {
+		if _, err := expressions.Compile(ws.When); err != nil {
+			errs = errs.Also(&apis.FieldError{
+				Message: "invalid CEL expression",
+				Paths:   []string{"when"},
+				Details: err.Error(),
+			})
+		}
+	}
+
+	for name, task := range ws.Tasks {
+		if task != nil && task.Environment != nil {
+			errs = errs.Also(task.Environment.Validate(ctx).ViaField("environment").ViaKey(name).ViaField("tasks"))
+		}
+
+		if task != nil && task.Use != nil && len(task.TestReports) != 0 {
+			// Test reports are collected by a step added to embedded tasks.
+			errs = errs.Also(apis.ErrMultipleOneOf("uses", "testReports").ViaKey(name).ViaField("tasks"))
+		}
+	}
+
+	return errs
+}
+
+// validateInstallationTokens checks that a single installation token can grant
+// access to all repositories of the workflow, which requires them to belong
+// to the same account.
+func (ws *WorkflowSpec) validateInstallationTokens() *apis.FieldError {
+	if ws.Repository == nil {
+		return nil
+	}
+
+	var errs *apis.FieldError
+	for i, repo := range ws.AdditionalRepositories {
+		if !strings.EqualFold(repo.Owner, ws.Repository.Owner) {
+			err := &apis.FieldError{
+				Message: fmt.Sprintf("invalid value: %s", repo.Owner),
+				Paths:   []string{"owner"},
+				Details: fmt.Sprintf("checkoutAuth %s requires all repositories to be owned by %s", InstallationTokenCheckoutAuth, ws.Repository.Owner),
+			}
+			errs = errs.Also(err.ViaIndex(i).ViaField("additionalRepos"))
+		}
+	}
+	return errs
+}
+
+// providers lists the supported providers.
+var providers = map[Provider]bool{
+	GithubProvider:          true,
+	GitlabProvider:          true,
+	BitbucketCloudProvider:  true,
+	BitbucketServerProvider: true,
+	GiteaProvider:           true,
+}
+
+// portableEvents lists the events that workflows whose repositories aren't
+// hosted by Github can be triggered by. Merge requests and Bitbucket pull
+// requests are reported as pull_request events.
+var portableEvents = map[string]bool{"push": true, "pull_request": true}
+
+// validateProvider checks that all repositories are hosted by the same
+// provider and that workflows whose repositories aren't hosted by Github
+// don't rely on Github features.
+func (ws *WorkflowSpec) validateProvider() *apis.FieldError {
+	var errs *apis.FieldError
+
+	provider := ws.Repository.GetProvider()
+	if !providers[provider] {
+		return &apis.FieldError{
+			Message: fmt.Sprintf("invalid value: %s", ws.Repository.Provider),
+			Paths:   []string{"repo.provider"},
+			Details: fmt.Sprintf("expected %s, %s, %s, %s or %s", GithubProvider, GitlabProvider, BitbucketCloudProvider, BitbucketServerProvider, GiteaProvider),
+		}
+	}
+
+	for i, repo := range ws.AdditionalRepositories {
+		if repo.GetProvider() != provider {
+			err := &apis.FieldError{
+				Message: fmt.Sprintf("invalid value: %s", repo.GetProvider()),
+				Paths:   []string{"provider"},
+				Details: fmt.Sprintf("all repositories must be hosted by %s", provider),
+			}
+			errs = errs.Also(err.ViaIndex(i).ViaField("additionalRepos"))
+		}
+	}
+
+	if provider == GithubProvider {
+		return errs
+	}
+
+	if provider == BitbucketCloudProvider {
+		// Access keys of Bitbucket Cloud repositories are always read-only.
+		if repo := ws.Repository; !repo.IsReadOnlyDeployKey() {
+			errs = errs.Also(writableDeployKeyError(provider).ViaField("repo"))
+		}
+		for i, repo := range ws.AdditionalRepositories {
+			if !repo.IsReadOnlyDeployKey() {
+				errs = errs.Also(writableDeployKeyError(provider).ViaIndex(i).ViaField("additionalRepos"))
+			}
+		}
+	}
+
+	unsupported := make([]string, 0)
+	if ws.CheckoutAuth == InstallationTokenCheckoutAuth {
+		unsupported = append(unsupported, "checkoutAuth")
+	}
+	if ws.Credentials != nil {
+		unsupported = append(unsupported, "credentials")
+	}
+	if ws.CommitStatus != nil {
+		unsupported = append(unsupported, "commitStatus")
+	}
+	if ws.PullRequestComment != nil {
+		unsupported = append(unsupported, "pullRequestComment")
+	}
+	if ws.Actors != nil && len(ws.Actors.Orgs) != 0 {
+		unsupported = append(unsupported, "actors.orgs")
+	}
+	if ws.Actors != nil && len(ws.Actors.Teams) != 0 {
+		unsupported = append(unsupported, "actors.teams")
+	}
+
+	if len(unsupported) != 0 {
+		errs = errs.Also(&apis.FieldError{
+			Message: fmt.Sprintf("not supported for repositories hosted by %s", provider),
+			Paths:   unsupported,
+		})
+	}
+
+	for i, event := range ws.Events {
+		if !portableEvents[event] {
+			errs = errs.Also(apis.ErrInvalidArrayValue(event, "events", i))
+		}
+	}
+	return errs
+}
+
+// writableDeployKeyError returns the error reported for deploy keys granted
+// write access on repositories whose provider doesn't support it.
+func writableDeployKeyError(provider Provider) *apis.FieldError {
+	return &apis.FieldError{
+		Message: "invalid value: false",
+		Paths:   []string{"deployKey.readOnly"},
+		Details: fmt.Sprintf("deploy keys of repositories hosted by %s are read-only", provider),
+	}
+}
+
+// Validate implements apis.Validatable
+func (w *Webhook) Validate(ctx context.Context) *apis.FieldError {
+	if w.URL == "" {
+		return apis.ErrMissingField("url")
+	}
+
+	// Github and Github Enterprise Server instances deliver payloads to
+	// absolute HTTP(S) URLs. The hooks endpoint path is appended to the URL,
+	// so it can't carry a query or a fragment.
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+		return &apis.FieldError{
+			Message: fmt.Sprintf("invalid value: %s", w.URL),
+			Paths:   []string{"url"},
+			Details: "expected an absolute HTTP(S) URL without query or fragment",
+		}
+	}
+	return nil
+}
+
+// Validate implements apis.Validatable
+func (e *Environment) Validate(ctx context.Context) *apis.FieldError {
+	if e.Name == "" {
+		return apis.ErrMissingField("name")
+	}
+	return nil
+}
+
+// Validate implements apis.Validatable
+func (a *Actors) Validate(ctx context.Context) *apis.FieldError {
+	var errs *apis.FieldError
+
+	for i, team := range a.Teams {
+		if parts := strings.SplitN(team, "/", 2); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			errs = errs.Also(apis.ErrInvalidArrayValue(team, "teams", i))
+		}
+	}
+
+	return errs
+}
+
+// Validate implements apis.Validatable
+func (c *Credentials) Validate(ctx context.Context) *apis.FieldError {
+	if c.SecretName == "" {
+		return apis.ErrMissingField("secretName")
+	}
+
+	if msgs := validation.IsDNS1123Subdomain(c.SecretName); len(msgs) != 0 {
+		return &apis.FieldError{
+			Message: fmt.Sprintf("invalid value: %s", c.SecretName),
+			Paths:   []string{"secretName"},
+			Details: strings.Join(msgs, "; "),
+		}
+	}
 	return nil
 }
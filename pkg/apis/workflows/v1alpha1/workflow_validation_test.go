@@ -0,0 +1,388 @@
+package v1alpha1
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+)
+
+func TestValidateActors(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        *Actors
+		wantError string
+	}{{
+		name: "valid teams",
+		in: &Actors{
+			Teams: []string{"my-org/my-team"},
+		},
+		wantError: "",
+	},
+		{
+			name: "team without an organization",
+			in: &Actors{
+				Teams: []string{"my-team"},
+			},
+			wantError: "invalid value: my-team: spec.actors.teams[0]",
+		},
+		{
+			name: "team with an empty slug",
+			in: &Actors{
+				Teams: []string{"my-org/my-team", "my-org/"},
+			},
+			wantError: "invalid value: my-org/: spec.actors.teams[1]",
+		},
+	}
+
+	for _, test := range tests {
+		workflow := &Workflow{
+			Spec: WorkflowSpec{
+				Actors: test.in,
+			},
+		}
+
+		got := workflow.Validate(context.Background()).Error()
+
+		if test.wantError != got {
+			t.Errorf("Fail in %s.\nWant error %q, but got %q", test.name, test.wantError, got)
+		}
+	}
+}
+
+func TestValidateWhen(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        string
+		wantError string
+	}{{
+		name:      "valid expression",
+		in:        `event.branch == "main"`,
+		wantError: "",
+	},
+		{
+			name:      "invalid expression",
+			in:        `pipeline.name == "ci"`,
+			wantError: "invalid CEL expression: spec.when",
+		},
+	}
+
+	for _, test := range tests {
+		workflow := &Workflow{
+			Spec: WorkflowSpec{
+				When: test.in,
+			},
+		}
+
+		got := workflow.Validate(context.Background()).Error()
+		if test.wantError == "" && got != "" {
+			t.Errorf("Fail in %s.\nWant no error, but got %q", test.name, got)
+		}
+
+		if test.wantError != "" && !strings.HasPrefix(got, test.wantError) {
+			t.Errorf("Fail in %s.\nWant error starting with %q, but got %q", test.name, test.wantError, got)
+		}
+	}
+}
+
+func TestValidateEnvironment(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        *Environment
+		wantError string
+	}{{
+		name:      "valid environment",
+		in:        &Environment{Name: "staging", URL: "https://staging.example.com"},
+		wantError: "",
+	},
+		{
+			name:      "missing name",
+			in:        &Environment{URL: "https://staging.example.com"},
+			wantError: "missing field(s): spec.tasks[deploy].environment.name",
+		},
+	}
+
+	for _, test := range tests {
+		workflow := &Workflow{
+			Spec: WorkflowSpec{
+				Tasks: map[string]*Task{
+					"deploy": {Environment: test.in},
+				},
+			},
+		}
+
+		got := workflow.Validate(context.Background()).Error()
+		if got != test.wantError {
+			t.Errorf("Fail in %s.\nWant error %q, but got %q", test.name, test.wantError, got)
+		}
+	}
+}
+
+func TestValidateTestReports(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        *Task
+		wantError string
+	}{{
+		name:      "embedded task",
+		in:        &Task{Steps: []EmbeddedStep{{Run: "make test"}}, TestReports: []string{"reports/*.xml"}},
+		wantError: "",
+	},
+		{
+			name:      "existing task",
+			in:        &Task{Use: &pipelinev1beta1.TaskRef{Name: "unit-tests"}, TestReports: []string{"reports/*.xml"}},
+			wantError: "expected exactly one, got both: spec.tasks[test].testReports, spec.tasks[test].uses",
+		},
+	}
+
+	for _, test := range tests {
+		workflow := &Workflow{
+			Spec: WorkflowSpec{
+				Tasks: map[string]*Task{
+					"test": test.in,
+				},
+			},
+		}
+
+		got := workflow.Validate(context.Background()).Error()
+		if got != test.wantError {
+			t.Errorf("Fail in %s.\nWant error %q, but got %q", test.name, test.wantError, got)
+		}
+	}
+}
+
+func TestValidateWebhook(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        string
+		wantError string
+	}{
+		{
+			name: "https URL",
+			in:   "https://hooks.example.com",
+		},
+		{
+			name: "http URL of an internal host",
+			in:   "http://hook-listener.workflows-system.svc:8080/",
+		},
+		{
+			name:      "missing scheme",
+			in:        "hooks.example.com",
+			wantError: "invalid value: hooks.example.com: spec.webhook.url\nexpected an absolute HTTP(S) URL without query or fragment",
+		},
+		{
+			name:      "query",
+			in:        "https://hooks.example.com?token=abc",
+			wantError: "invalid value: https://hooks.example.com?token=abc: spec.webhook.url\nexpected an absolute HTTP(S) URL without query or fragment",
+		},
+		{
+			name:      "empty URL",
+			in:        "",
+			wantError: "missing field(s): spec.webhook.url",
+		},
+	}
+
+	for _, test := range tests {
+		workflow := &Workflow{
+			Spec: WorkflowSpec{
+				Webhook: &Webhook{URL: test.in},
+			},
+		}
+
+		got := workflow.Validate(context.Background()).Error()
+
+		if test.wantError != got {
+			t.Errorf("Fail in %s.\nWant error %q, but got %q", test.name, test.wantError, got)
+		}
+	}
+}
+
+func TestValidateCredentials(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        string
+		wantError string
+	}{
+		{
+			name: "valid Secret name",
+			in:   "my-team-github",
+		},
+		{
+			name:      "missing Secret name",
+			in:        "",
+			wantError: "missing field(s): spec.credentials.secretName",
+		},
+		{
+			name:      "invalid Secret name",
+			in:        "My_Secret",
+			wantError: "invalid value: My_Secret: spec.credentials.secretName\n",
+		},
+	}
+
+	for _, test := range tests {
+		workflow := &Workflow{
+			Spec: WorkflowSpec{
+				Credentials: &Credentials{SecretName: test.in},
+			},
+		}
+
+		got := workflow.Validate(context.Background()).Error()
+
+		if !strings.HasPrefix(got, test.wantError) || (test.wantError == "" && got != "") {
+			t.Errorf("Fail in %s.\nWant error %q, but got %q", test.name, test.wantError, got)
+		}
+	}
+}
+
+func TestValidateCheckoutAuth(t *testing.T) {
+	tests := []struct {
+		name            string
+		checkoutAuth    CheckoutAuth
+		additionalOwner string
+		wantError       string
+	}{
+		{
+			name:            "deploy keys",
+			checkoutAuth:    DeployKeysCheckoutAuth,
+			additionalOwner: "other-org",
+		},
+		{
+			name:            "installation tokens",
+			checkoutAuth:    InstallationTokenCheckoutAuth,
+			additionalOwner: "My-Org",
+		},
+		{
+			name:            "installation tokens for repositories of several owners",
+			checkoutAuth:    InstallationTokenCheckoutAuth,
+			additionalOwner: "other-org",
+			wantError:       "invalid value: other-org: spec.additionalRepos[0].owner\n",
+		},
+		{
+			name:            "unknown method",
+			checkoutAuth:    "password",
+			additionalOwner: "my-org",
+			wantError:       "invalid value: password: spec.checkoutAuth\n",
+		},
+	}
+
+	for _, test := range tests {
+		workflow := &Workflow{
+			Spec: WorkflowSpec{
+				Repository:             &Repository{Owner: "my-org", Name: "my-repo"},
+				AdditionalRepositories: []Repository{{Owner: test.additionalOwner, Name: "my-lib"}},
+				CheckoutAuth:           test.checkoutAuth,
+			},
+		}
+
+		got := workflow.Validate(context.Background()).Error()
+
+		if !strings.HasPrefix(got, test.wantError) || (test.wantError == "" && got != "") {
+			t.Errorf("Fail in %s.\nWant error %q, but got %q", test.name, test.wantError, got)
+		}
+	}
+}
+
+func TestValidateProvider(t *testing.T) {
+	tests := []struct {
+		name      string
+		spec      WorkflowSpec
+		wantError string
+	}{
+		{
+			name: "github by default",
+			spec: WorkflowSpec{
+				Repository:   &Repository{Owner: "my-org", Name: "my-repo"},
+				CommitStatus: &CommitStatus{},
+			},
+		},
+		{
+			name: "gitlab",
+			spec: WorkflowSpec{
+				Repository:             &Repository{Owner: "my-group/my-subgroup", Name: "my-project", Provider: GitlabProvider},
+				AdditionalRepositories: []Repository{{Owner: "my-group", Name: "my-lib", Provider: GitlabProvider}},
+				Events:                 []string{"push", "pull_request"},
+			},
+		},
+		{
+			name: "bitbucket server",
+			spec: WorkflowSpec{
+				Repository: &Repository{Owner: "PROJ", Name: "my-repo", Provider: BitbucketServerProvider, DeployKey: &DeployKey{ReadOnly: false}},
+				Events:     []string{"push", "pull_request"},
+			},
+		},
+		{
+			name: "gitea",
+			spec: WorkflowSpec{
+				Repository: &Repository{Owner: "my-org", Name: "my-repo", Provider: GiteaProvider, DeployKey: &DeployKey{ReadOnly: false}},
+				Events:     []string{"push", "pull_request"},
+			},
+		},
+		{
+			name: "gitea with Github features",
+			spec: WorkflowSpec{
+				Repository: &Repository{Owner: "my-org", Name: "my-repo", Provider: GiteaProvider},
+				Events:     []string{"push", "check_run"},
+			},
+			wantError: "invalid value: check_run: spec.events[1]",
+		},
+		{
+			name: "bitbucket cloud with writable deploy keys",
+			spec: WorkflowSpec{
+				Repository:             &Repository{Owner: "my-workspace", Name: "my-repo", Provider: BitbucketCloudProvider},
+				AdditionalRepositories: []Repository{{Owner: "my-workspace", Name: "my-lib", Provider: BitbucketCloudProvider, DeployKey: &DeployKey{ReadOnly: false}}},
+			},
+			wantError: "invalid value: false: spec.additionalRepos[0].deployKey.readOnly",
+		},
+		{
+			name: "bitbucket with Github features",
+			spec: WorkflowSpec{
+				Repository:         &Repository{Owner: "PROJ", Name: "my-repo", Provider: BitbucketServerProvider},
+				PullRequestComment: &PullRequestComment{},
+			},
+			wantError: "not supported for repositories hosted by bitbucket-server: spec.pullRequestComment",
+		},
+		{
+			name: "unknown provider",
+			spec: WorkflowSpec{
+				Repository: &Repository{Owner: "my-org", Name: "my-repo", Provider: "svn"},
+			},
+			wantError: "invalid value: svn: spec.repo.provider\n",
+		},
+		{
+			name: "repositories hosted by several providers",
+			spec: WorkflowSpec{
+				Repository:             &Repository{Owner: "my-group", Name: "my-project", Provider: GitlabProvider},
+				AdditionalRepositories: []Repository{{Owner: "my-org", Name: "my-lib"}},
+			},
+			wantError: "invalid value: github: spec.additionalRepos[0].provider\n",
+		},
+		{
+			name: "gitlab with Github features",
+			spec: WorkflowSpec{
+				Repository:   &Repository{Owner: "my-group", Name: "my-project", Provider: GitlabProvider},
+				CheckoutAuth: InstallationTokenCheckoutAuth,
+				CommitStatus: &CommitStatus{},
+			},
+			wantError: "not supported for repositories hosted by gitlab: spec.checkoutAuth, spec.commitStatus",
+		},
+		{
+			name: "gitlab with Github events",
+			spec: WorkflowSpec{
+				Repository: &Repository{Owner: "my-group", Name: "my-project", Provider: GitlabProvider},
+				Events:     []string{"push", "check_run"},
+			},
+			wantError: "invalid value: check_run: spec.events[1]",
+		},
+	}
+
+	for _, test := range tests {
+		workflow := &Workflow{Spec: test.spec}
+
+		got := workflow.Validate(context.Background()).Error()
+
+		if !strings.HasPrefix(got, test.wantError) || (test.wantError == "" && got != "") {
+			t.Errorf("Fail in %s.\nWant error %q, but got %q", test.name, test.wantError, got)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+// Package bitbucket manages repositories hosted by Bitbucket Cloud and by
+// Bitbucket Server (or Data Center) instances.
+package bitbucket
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nubank/workflows/pkg/github"
+)
+
+const (
+
+	// Directory where Bitbucket Cloud credentials are mounted. Bitbucket
+	// Cloud support is only enabled when it holds a token.
+	cloudCredentialsDir = "/var/run/secrets/bitbucket-cloud"
+
+	// Directory where Bitbucket Server credentials are mounted. Bitbucket
+	// Server support is only enabled when it holds a token.
+	serverCredentialsDir = "/var/run/secrets/bitbucket-server"
+
+	// Names of the files holding credentials. Requests are authenticated
+	// with the token as a bearer token (i.e. access tokens), or with the
+	// username and the token through basic authentication (i.e. app
+	// passwords) when the username is set.
+	tokenFile    = "token"
+	usernameFile = "username"
+
+	// Name of the environment variable that contains the base URL of the
+	// Bitbucket Server instance hosting repositories.
+	bitbucketServerURL = "BITBUCKET_SERVER_URL"
+
+	// Name of the environment variable that contains the base URL
+	// repositories hosted by Bitbucket Server are cloned from over SSH. It
+	// defaults to port 7999 of the instance's host.
+	bitbucketServerSSHURL = "BITBUCKET_SERVER_SSH_URL"
+
+	// Name of the environment variable that contains the path to a file
+	// holding PEM encoded certificates to be trusted when talking to
+	// Bitbucket Server.
+	bitbucketServerCABundlePath = "BITBUCKET_SERVER_CA_BUNDLE_PATH"
+
+	// Base URL of the Bitbucket Cloud REST API.
+	cloudAPIURL = "https://api.bitbucket.org/2.0/"
+
+	// Base URL Bitbucket Cloud repositories are cloned from.
+	cloudGitURL = "https://bitbucket.org"
+
+	// Path under which Bitbucket Server serves REST APIs.
+	serverAPIPath = "/rest/"
+
+	// Path under which Bitbucket Server serves repositories over HTTPS.
+	serverGitPath = "/scm"
+
+	// Default port Bitbucket Server serves repositories on over SSH.
+	serverSSHPort = "7999"
+
+	// Default timeout for calling Bitbucket.
+	timeout = time.Second * 15
+
+	// How many bytes of error responses are kept in errors.
+	maxErrorBodyBytes = 4096
+)
+
+// Client talks to the REST API of Bitbucket Cloud or of a Bitbucket Server
+// instance.
+type Client struct {
+	host       *github.Host
+	username   string
+	token      string
+	httpClient *http.Client
+}
+
+// NewCloudClient returns a Client for the Bitbucket Cloud REST API served at
+// the supplied URL, authenticated with the supplied access token or, when the
+// username is set, app password.
+func NewCloudClient(apiURL, username, token string) (*Client, error) {
+	if _, err := parseBaseURL(apiURL); err != nil {
+		return nil, err
+	}
+
+	host := &github.Host{APIURL: strings.TrimSuffix(apiURL, "/") + "/",
+		GitURL: cloudGitURL,
+	}
+	return newClient(host, username, token), nil
+}
+
+// NewServerClient returns a Client for the Bitbucket Server instance at the
+// supplied base URL, authenticated with the supplied HTTP access token.
+// Repositories are cloned over SSH from the supplied base URL, which defaults
+// to port 7999 of the instance's host.
+func NewServerClient(baseURL, sshURL, token string, caBundle []byte) (*Client, error) {
+	u, err := parseBaseURL(baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if sshURL == "" {
+		sshURL = fmt.Sprintf("ssh://git@%s:%s", u.Hostname(), serverSSHPort)
+	}
+
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	host := &github.Host{APIURL: baseURL + serverAPIPath,
+		GitURL:   baseURL + serverGitPath,
+		SSHURL:   strings.TrimSuffix(sshURL, "/"),
+		CABundle: caBundle,
+	}
+	return newClient(host, "", token), nil
+}
+
+// newClient returns a Client talking to the supplied host.
+func newClient(host *github.Host, username, token string) *Client {
+	return &Client{host: host,
+		username:   username,
+		token:      token,
+		httpClient: &http.Client{Transport: host.Transport(), Timeout: timeout},
+	}
+}
+
+// parseBaseURL parses the supplied base URL, which must be an absolute HTTP(S)
+// URL.
+func parseBaseURL(baseURL string) (*url.URL, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("Invalid Bitbucket URL %s: expected an absolute HTTP(S) URL", baseURL)
+	}
+	return u, nil
+}
+
+// NewCloudClientFromEnv returns a Client for Bitbucket Cloud authenticated with
+// the mounted credentials. It returns nil when no credentials are mounted,
+// meaning that Bitbucket Cloud isn't supported.
+func NewCloudClientFromEnv() (*Client, error) {
+	username, token, err := readCredentials(cloudCredentialsDir)
+	if err != nil || token == "" {
+		return nil, err
+	}
+	return NewCloudClient(cloudAPIURL, username, token)
+}
+
+// NewServerClientFromEnv returns a Client for the Bitbucket Server instance
+// configured through environment variables, authenticated with the mounted
+// token. It returns nil when no token is mounted, meaning that Bitbucket
+// Server isn't supported.
+func NewServerClientFromEnv() (*Client, error) {
+	_, token, err := readCredentials(serverCredentialsDir)
+	if err != nil || token == "" {
+		return nil, err
+	}
+
+	baseURL, ok := os.LookupEnv(bitbucketServerURL)
+	if !ok || baseURL == "" {
+		return nil, fmt.Errorf("Error configuring Bitbucket Server: %s must be set", bitbucketServerURL)
+	}
+
+	var caBundle []byte
+	if path, ok := os.LookupEnv(bitbucketServerCABundlePath); ok && path != "" {
+		if caBundle, err = ioutil.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("Error reading the CA bundle set by %s: %w", bitbucketServerCABundlePath, err)
+		}
+
+		if !x509.NewCertPool().AppendCertsFromPEM(caBundle) {
+			return nil, fmt.Errorf("The CA bundle set by %s doesn't contain any PEM encoded certificate", bitbucketServerCABundlePath)
+		}
+	}
+
+	return NewServerClient(baseURL, os.Getenv(bitbucketServerSSHURL), token, caBundle)
+}
+
+// readCredentials reads the username (if any) and the token mounted in the
+// supplied directory. The token is empty when it isn't mounted.
+func readCredentials(dir string) (string, string, error) {
+	token, err := ioutil.ReadFile(filepath.Join(dir, tokenFile))
+	if os.IsNotExist(err) {
+		return "", "", nil
+	} else if err != nil {
+		return "", "", fmt.Errorf("Error reading Bitbucket token: %w", err)
+	}
+
+	username, err := ioutil.ReadFile(filepath.Join(dir, usernameFile))
+	if err != nil && !os.IsNotExist(err) {
+		return "", "", fmt.Errorf("Error reading Bitbucket username: %w", err)
+	}
+
+	return strings.TrimSpace(string(username)), strings.TrimSpace(string(token)), nil
+}
+
+// Host returns the host repositories are checked out from.
+func (c *Client) Host() *github.Host {
+	return c.host
+}
+
+// ResponseError is returned when Bitbucket answers with an unsuccessful
+// status.
+type ResponseError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Message    string
+}
+
+// Error satisfies the error interface.
+func (r *ResponseError) Error() string {
+	return fmt.Sprintf("Bitbucket answered %s %s with status %d: %s", r.Method, r.Path, r.StatusCode, r.Message)
+}
+
+// hasStatus returns true if the supplied error is a ResponseError with the
+// status in question.
+func hasStatus(err error, statusCode int) bool {
+	var responseErr *ResponseError
+	return errors.As(err, &responseErr) && responseErr.StatusCode == statusCode
+}
+
+// do sends a request with the supplied JSON body (if any) to the API path in
+// question and decodes the JSON response into out (if any).
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	content, err := c.doRaw(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+
+	if out == nil || len(content) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(content, out); err != nil {
+		return fmt.Errorf("Error decoding the response to %s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+// doRaw sends a request with the supplied JSON body (if any) to the API path
+// in question and returns the response body as is.
+func (c *Client) doRaw(ctx context.Context, method, path string, body interface{}) ([]byte, error) {
+	var reader io.Reader
+	if body != nil {
+		content, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(content)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, c.host.APIURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.username != "" {
+		request.SetBasicAuth(c.username, c.token)
+	} else {
+		request.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	request.Header.Set("Accept", "application/json")
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("Error calling Bitbucket: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		message, _ := ioutil.ReadAll(io.LimitReader(response.Body, maxErrorBodyBytes))
+		return nil, &ResponseError{Method: method,
+			Path:       path,
+			StatusCode: response.StatusCode,
+			Message:    strings.TrimSpace(string(message)),
+		}
+	}
+
+	content, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the response to %s %s: %w", method, path, err)
+	}
+	return content, nil
+}
+
+// relativePath returns the API path of the supplied URL, which Bitbucket
+// returns to link pages of results. URLs that don't belong to the API are
+// rejected, so that credentials are never sent elsewhere.
+func (c *Client) relativePath(link string) (string, error) {
+	if !strings.HasPrefix(link, c.host.APIURL) {
+		return "", fmt.Errorf("Bitbucket linked to a page outside of its API: %s", link)
+	}
+	return strings.TrimPrefix(link, c.host.APIURL), nil
+}
+
+// escapePath escapes each segment of the supplied file path.
+func escapePath(filePath string) string {
+	segments := strings.Split(filePath, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
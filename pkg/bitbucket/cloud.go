@@ -0,0 +1,436 @@
+package bitbucket
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	"github.com/nubank/workflows/pkg/github"
+	"github.com/nubank/workflows/pkg/secrets"
+	"knative.dev/pkg/logging"
+)
+
+// cloudEvents maps events workflows are triggered by to the Bitbucket Cloud
+// events delivering them.
+var cloudEvents = map[string][]string{
+	"push":         {"repo:push"},
+	"pull_request": {"pullrequest:created", "pullrequest:updated", "pullrequest:fulfilled", "pullrequest:rejected"},
+}
+
+// cloudRepository is a Bitbucket Cloud repository, as returned by the API.
+type cloudRepository struct {
+	IsPrivate  bool `json:"is_private"`
+	MainBranch *struct {
+		Name string `json:"name"`
+	} `json:"mainbranch"`
+}
+
+// cloudHook is a Bitbucket Cloud repository webhook, as sent to and returned
+// by the API.
+type cloudHook struct {
+	UUID        string   `json:"uuid,omitempty"`
+	Description string   `json:"description"`
+	URL         string   `json:"url"`
+	Active      bool     `json:"active"`
+	Events      []string `json:"events"`
+	Secret      string   `json:"secret,omitempty"`
+}
+
+// cloudDeployKey is a Bitbucket Cloud access key, as sent to and returned by
+// the API. Access keys are always read-only.
+type cloudDeployKey struct {
+	ID    int64  `json:"id,omitempty"`
+	Key   string `json:"key"`
+	Label string `json:"label"`
+}
+
+// cloudRepoPath returns the path under which the API serves the supplied
+// repository.
+func cloudRepoPath(repo *workflowsv1alpha1.Repository) string {
+	return fmt.Sprintf("repositories/%s/%s", url.PathEscape(repo.Owner), url.PathEscape(repo.Name))
+}
+
+// cloudRepoReconciler implements github.RepoReconciler for Bitbucket Cloud
+// repositories.
+type cloudRepoReconciler struct {
+	client *Client
+}
+
+// ReconcileRepos implements github.RepoReconciler.
+func (r *cloudRepoReconciler) ReconcileRepos(ctx context.Context, workflow *workflowsv1alpha1.Workflow) error {
+	for _, repo := range repositoriesOf(workflow) {
+		var current cloudRepository
+		if err := r.client.do(ctx, http.MethodGet, cloudRepoPath(repo), nil, &current); err != nil {
+			return fmt.Errorf("Error fetching Bitbucket repository %s: %w", repo, err)
+		}
+
+		repo.Private = current.IsPrivate
+		if current.MainBranch != nil {
+			repo.DefaultBranch = current.MainBranch.Name
+		}
+	}
+	return nil
+}
+
+// NewCloudRepoReconciler returns a new RepoReconciler for Bitbucket Cloud
+// repositories.
+func NewCloudRepoReconciler(client *Client) github.RepoReconciler {
+	return &cloudRepoReconciler{client: client}
+}
+
+// cloudWebhookReconciler implements github.WebhookReconciler for Bitbucket
+// Cloud repository webhooks, which are identified by UUIDs.
+type cloudWebhookReconciler struct {
+	client *Client
+}
+
+// ReconcileHook creates or updates the webhook of the supplied workflow.
+// Updates rotate the webhook's secret.
+func (w *cloudWebhookReconciler) ReconcileHook(ctx context.Context, workflow *workflowsv1alpha1.Workflow) (*github.Webhook, error) {
+	repo := workflow.Spec.Repository
+	logger := logging.FromContext(ctx).With("repository", repo)
+
+	uuid := workflow.GetWebhookUUID()
+	if uuid == "" {
+		logger.Info("There are no recognized webhooks associated to the workflow. Creating a new one")
+		return w.createHook(ctx, workflow)
+	}
+
+	var current cloudHook
+	err := w.client.do(ctx, http.MethodGet, w.hookPath(repo, uuid), nil, &current)
+	if hasStatus(err, http.StatusNotFound) {
+		logger.Infow("Unable to find webhook for supplied UUID. It might have been deleted by mistake. Creating a new one", "webhook-uuid", uuid)
+		return w.createHook(ctx, workflow)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get webhook %s: %w", uuid, err)
+	}
+
+	desired := newCloudHook(workflow, "")
+	if current.URL == desired.URL && current.Active && equalEvents(current.Events, desired.Events) {
+		logger.Infow("Webhook settings are up to date", "webhook-uuid", uuid)
+		return nil, nil
+	}
+
+	logger.Infow("Webhook and workflow settings are out of sync. Updating webhook", "webhook-uuid", uuid)
+	secretToken := secrets.GenerateRandomToken()
+	if err := w.client.do(ctx, http.MethodPut, w.hookPath(repo, uuid), newCloudHook(workflow, secretToken), nil); err != nil {
+		return nil, fmt.Errorf("unable to update Bitbucket webhook for repository %s: %w", repo, err)
+	}
+
+	logger.Infow("Webhook has been successfully updated", "webhook-uuid", uuid)
+	return &github.Webhook{Secret: []byte(secretToken)}, nil
+}
+
+// hookPath returns the API path of the supplied webhook.
+func (w *cloudWebhookReconciler) hookPath(repo *workflowsv1alpha1.Repository, uuid string) string {
+	return fmt.Sprintf("%s/hooks/%s", cloudRepoPath(repo), url.PathEscape(uuid))
+}
+
+// newCloudHook returns the desired state of the workflow's webhook.
+func newCloudHook(workflow *workflowsv1alpha1.Workflow, secret string) *cloudHook {
+	h := &cloudHook{Description: fmt.Sprintf("workflows: %s/%s", workflow.GetNamespace(), workflow.GetName()),
+		URL:    workflow.GetHooksURL(),
+		Active: true,
+		Events: make([]string, 0),
+		Secret: secret,
+	}
+
+	for _, event := range workflow.Spec.Events {
+		h.Events = append(h.Events, cloudEvents[event]...)
+	}
+	return h
+}
+
+// createHook creates a new webhook, recording its UUID in the workflow.
+func (w *cloudWebhookReconciler) createHook(ctx context.Context, workflow *workflowsv1alpha1.Workflow) (*github.Webhook, error) {
+	repo := workflow.Spec.Repository
+	secretToken := secrets.GenerateRandomToken()
+
+	var created cloudHook
+	if err := w.client.do(ctx, http.MethodPost, cloudRepoPath(repo)+"/hooks", newCloudHook(workflow, secretToken), &created); err != nil {
+		return nil, fmt.Errorf("unable to create Bitbucket webhook for repository %s: %w", repo, err)
+	}
+
+	logger := logging.FromContext(ctx)
+	logger.Infow("Webhook has been successfully created",
+		"repository", repo,
+		"webhook-uuid", created.UUID)
+
+	workflow.SetWebhookUUID(created.UUID)
+	return &github.Webhook{Secret: []byte(secretToken)}, nil
+}
+
+// Delete deletes the webhook associated to the workflow in question. Webhooks
+// already deleted on Bitbucket are ignored.
+func (w *cloudWebhookReconciler) Delete(ctx context.Context, workflow *workflowsv1alpha1.Workflow) error {
+	repo := workflow.Spec.Repository
+
+	uuid := workflow.GetWebhookUUID()
+	if uuid == "" {
+		return fmt.Errorf("Unable to delete webhook because its identifier is unknown")
+	}
+
+	err := w.client.do(ctx, http.MethodDelete, w.hookPath(repo, uuid), nil, nil)
+	if err != nil && !hasStatus(err, http.StatusNotFound) {
+		return fmt.Errorf("Error deleting Bitbucket webhook: %w", err)
+	}
+
+	logger := logging.FromContext(ctx)
+	logger.Infow("Webhook has been successfully deleted", "repository", repo, "webhook-uuid", uuid)
+
+	return nil
+}
+
+// NewCloudWebhookReconciler returns a new WebhookReconciler for Bitbucket
+// Cloud repository webhooks.
+func NewCloudWebhookReconciler(client *Client) github.WebhookReconciler {
+	return &cloudWebhookReconciler{client: client}
+}
+
+// cloudDeployKeysReconciler implements github.DeployKeysReconciler for
+// Bitbucket Cloud access keys.
+type cloudDeployKeysReconciler struct {
+	client *Client
+}
+
+// ReconcileKeys creates the access keys of repositories that need them.
+func (d *cloudDeployKeysReconciler) ReconcileKeys(ctx context.Context, workflow *workflowsv1alpha1.Workflow) ([]secrets.KeyPair, error) {
+	keyPairs := make([]secrets.KeyPair, 0)
+
+	for _, repo := range workflow.GetRepositories() {
+		if !repo.NeedsSSHPrivateKeys() {
+			continue
+		}
+
+		logger := logging.FromContext(ctx).With("repository", repo)
+
+		if id := workflow.GetDeployKeyID(&repo); id != nil {
+			err := d.client.do(ctx, http.MethodGet, d.keyPath(&repo, *id), nil, nil)
+			if err == nil {
+				logger.Infow("Access key settings are up to date", "deploy-key-id", *id)
+				continue
+			}
+
+			if !hasStatus(err, http.StatusNotFound) {
+				return nil, fmt.Errorf("Unable to get access key #%d: %w", *id, err)
+			}
+			logger.Infow("Unable to find an access key for the supplied id. It might have been deleted by mistake. Creating a new one", "deploy-key-id", *id)
+		}
+
+		keyPair, err := d.createDeployKey(ctx, workflow, &repo)
+		if err != nil {
+			return nil, err
+		}
+		keyPairs = append(keyPairs, *keyPair)
+	}
+	return keyPairs, nil
+}
+
+// keyPath returns the API path of the supplied access key.
+func (d *cloudDeployKeysReconciler) keyPath(repo *workflowsv1alpha1.Repository, id int64) string {
+	return fmt.Sprintf("%s/deploy-keys/%d", cloudRepoPath(repo), id)
+}
+
+// createDeployKey creates a new access key, recording its id in the workflow.
+func (d *cloudDeployKeysReconciler) createDeployKey(ctx context.Context, workflow *workflowsv1alpha1.Workflow, repo *workflowsv1alpha1.Repository) (*secrets.KeyPair, error) {
+	keyPair, err := secrets.GenerateKeyPair(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	var created cloudDeployKey
+	if err := d.client.do(ctx, http.MethodPost, cloudRepoPath(repo)+"/deploy-keys", &cloudDeployKey{
+		Key:   string(keyPair.PublicKey),
+		Label: fmt.Sprintf("%s-ssh-public-key", workflow.GetName()),
+	}, &created); err != nil {
+		return nil, fmt.Errorf("unable to create Bitbucket access key for repository %s: %w", repo, err)
+	}
+
+	logger := logging.FromContext(ctx)
+	logger.Infow("Access key has been successfully created",
+		"repository", repo,
+		"deploy-key-id", created.ID)
+
+	workflow.SetDeployKeyID(repo, created.ID)
+	return keyPair, nil
+}
+
+// Delete deletes all access keys associated to the workflow in question. Keys
+// already deleted on Bitbucket are ignored.
+func (d *cloudDeployKeysReconciler) Delete(ctx context.Context, workflow *workflowsv1alpha1.Workflow) error {
+	for _, repo := range workflow.GetRepositories() {
+		if !repo.NeedsSSHPrivateKeys() {
+			continue
+		}
+
+		id := workflow.GetDeployKeyID(&repo)
+		if id == nil {
+			return fmt.Errorf("Error deleting access key for repository %s: the key's identifier is unknown", repo.String())
+		}
+
+		err := d.client.do(ctx, http.MethodDelete, d.keyPath(&repo, *id), nil, nil)
+		if err != nil && !hasStatus(err, http.StatusNotFound) {
+			return fmt.Errorf("unable to delete Bitbucket access key for repository %s: %w", repo.String(), err)
+		}
+
+		logger := logging.FromContext(ctx)
+		logger.Infow("Access key has been successfully deleted", "repository", repo, "deploy-key-id", *id)
+	}
+	return nil
+}
+
+// NewCloudDeployKeysReconciler returns a new DeployKeysReconciler for
+// Bitbucket Cloud access keys.
+func NewCloudDeployKeysReconciler(client *Client) github.DeployKeysReconciler {
+	return &cloudDeployKeysReconciler{client: client}
+}
+
+// cloudWorkflowReader implements github.WorkflowReader for workflows stored in
+// Bitbucket Cloud repositories.
+type cloudWorkflowReader struct {
+	client *Client
+}
+
+// GetWorkflowContent implements github.WorkflowReader.
+func (w *cloudWorkflowReader) GetWorkflowContent(ctx context.Context, workflow *workflowsv1alpha1.Workflow, filePath, ref string) (*workflowsv1alpha1.Workflow, error) {
+	path := fmt.Sprintf("%s/src/%s/%s", cloudRepoPath(workflow.Spec.Repository), url.PathEscape(ref), escapePath(filePath))
+
+	content, err := w.client.doRaw(ctx, http.MethodGet, path, nil)
+	if hasStatus(err, http.StatusNotFound) {
+		return nil, github.NewNotFoundError(fmt.Sprintf("Unable to find workflow %s", workflow.GetName()))
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return github.ParseWorkflow(workflow, content)
+}
+
+// NewCloudWorkflowReader returns a new WorkflowReader for workflows stored in
+// Bitbucket Cloud repositories.
+func NewCloudWorkflowReader(client *Client) github.WorkflowReader {
+	return &cloudWorkflowReader{client: client}
+}
+
+// cloudDiffStat is a page of files changed between two commits, as returned
+// by the API.
+type cloudDiffStat struct {
+	Values []struct {
+		Old *struct {
+			Path string `json:"path"`
+		} `json:"old"`
+		New *struct {
+			Path string `json:"path"`
+		} `json:"new"`
+	} `json:"values"`
+	Next string `json:"next"`
+}
+
+// cloudCommit is a Bitbucket Cloud commit, as returned by the API.
+type cloudCommit struct {
+	Hash string `json:"hash"`
+}
+
+// cloudEventCompleter implements github.EventCompleter for events delivered by
+// Bitbucket Cloud, whose payloads neither list changed files nor carry full
+// commit hashes for pull requests.
+type cloudEventCompleter struct {
+	client *Client
+}
+
+// CompleteEvent implements github.EventCompleter.
+func (c *cloudEventCompleter) CompleteEvent(ctx context.Context, workflow *workflowsv1alpha1.Workflow, event *github.Event) error {
+	repoPath := cloudRepoPath(workflow.Spec.Repository)
+
+	switch event.Name {
+	case "push":
+		if event.HeadCommitSHA == "" {
+			// The branch was deleted.
+			return nil
+		}
+
+		spec := event.HeadCommitSHA
+		if previous := previousCloudHead(event); previous != "" {
+			spec += ".." + previous
+		}
+		return c.readChanges(ctx, event, fmt.Sprintf("%s/diffstat/%s", repoPath, url.PathEscape(spec)))
+
+	case "pull_request":
+		for _, sha := range []*string{&event.HeadCommitSHA, &event.BaseCommitSHA} {
+			if *sha == "" || len(*sha) == fullHashLength {
+				continue
+			}
+
+			var commit cloudCommit
+			if err := c.client.do(ctx, http.MethodGet, fmt.Sprintf("%s/commit/%s", repoPath, url.PathEscape(*sha)), nil, &commit); err != nil {
+				return fmt.Errorf("Error resolving commit %s: %w", *sha, err)
+			}
+			*sha = commit.Hash
+		}
+		return c.readChanges(ctx, event, fmt.Sprintf("%s/pullrequests/%d/diffstat", repoPath, event.PullRequestNumber))
+	}
+	return nil
+}
+
+// readChanges fills the event's changes with the files listed by all pages of
+// the supplied diffstat.
+func (c *cloudEventCompleter) readChanges(ctx context.Context, event *github.Event, path string) error {
+	changes := newChangeSet()
+	for path != "" {
+		var page cloudDiffStat
+		if err := c.client.do(ctx, http.MethodGet, path, nil, &page); err != nil {
+			return fmt.Errorf("Error reading changed files: %w", err)
+		}
+
+		for _, value := range page.Values {
+			if value.New != nil {
+				changes.add(value.New.Path)
+			}
+			if value.Old != nil {
+				changes.add(value.Old.Path)
+			}
+		}
+
+		path = ""
+		if page.Next != "" {
+			var err error
+			if path, err = c.client.relativePath(page.Next); err != nil {
+				return err
+			}
+		}
+	}
+
+	event.Changes = changes.files
+	return nil
+}
+
+// NewCloudEventCompleter returns a new EventCompleter for events delivered by
+// Bitbucket Cloud.
+func NewCloudEventCompleter(client *Client) github.EventCompleter {
+	return &cloudEventCompleter{client: client}
+}
+
+// equalEvents returns true if both lists hold the same events, regardless of
+// their order.
+func equalEvents(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	sortedA := append([]string{}, a...)
+	sortedB := append([]string{}, b...)
+	sort.Strings(sortedA)
+	sort.Strings(sortedB)
+
+	for i := range sortedA {
+		if sortedA[i] != sortedB[i] {
+			return false
+		}
+	}
+	return true
+}
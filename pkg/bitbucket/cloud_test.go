@@ -0,0 +1,278 @@
+package bitbucket
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	"github.com/nubank/workflows/pkg/github"
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/logging"
+)
+
+func newTestContext() context.Context {
+	return logging.WithLogger(context.Background(), zap.NewNop().Sugar())
+}
+
+func newTestWorkflow(provider workflowsv1alpha1.Provider) *workflowsv1alpha1.Workflow {
+	return &workflowsv1alpha1.Workflow{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-1", Namespace: "dev"},
+		Spec: workflowsv1alpha1.WorkflowSpec{
+			Repository: &workflowsv1alpha1.Repository{Owner: "my-workspace",
+				Name:     "my-repo",
+				Provider: provider,
+			},
+			AdditionalRepositories: []workflowsv1alpha1.Repository{{Owner: "my-workspace",
+				Name:     "my-lib",
+				Provider: provider,
+			}},
+			Webhook: &workflowsv1alpha1.Webhook{URL: "https://workflows.example.com"},
+			Events:  []string{"pull_request"},
+		},
+	}
+}
+
+func TestCloudReconcileRepos(t *testing.T) {
+	fake, client := newFakeBitbucketCloud(t)
+	fake.respond(http.MethodGet, "repositories/my-workspace/my-repo", http.StatusOK, map[string]interface{}{
+		"is_private": true,
+		"mainbranch": map[string]string{"name": "main"},
+	})
+	fake.respond(http.MethodGet, "repositories/my-workspace/my-lib", http.StatusOK, map[string]interface{}{
+		"is_private": false,
+		"mainbranch": map[string]string{"name": "master"},
+	})
+
+	workflow := newTestWorkflow(workflowsv1alpha1.BitbucketCloudProvider)
+	if err := NewCloudRepoReconciler(client).ReconcileRepos(newTestContext(), workflow); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if repo := workflow.Spec.Repository; repo.DefaultBranch != "main" || !repo.Private {
+		t.Errorf("Want private repository with default branch main, got %+v", repo)
+	}
+
+	if repo := workflow.Spec.AdditionalRepositories[0]; repo.DefaultBranch != "master" || repo.Private {
+		t.Errorf("Want public repository with default branch master, got %+v", repo)
+	}
+}
+
+func TestCloudReconcileHook(t *testing.T) {
+	fake, client := newFakeBitbucketCloud(t)
+	fake.respond(http.MethodPost, "repositories/my-workspace/my-repo/hooks", http.StatusCreated, map[string]string{"uuid": "{8c8e4b5e}"})
+
+	ctx := newTestContext()
+	workflow := newTestWorkflow(workflowsv1alpha1.BitbucketCloudProvider)
+	reconciler := NewCloudWebhookReconciler(client)
+
+	created, err := reconciler.ReconcileHook(ctx, workflow)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if workflow.GetWebhookUUID() != "{8c8e4b5e}" {
+		t.Fatalf("Want hook {8c8e4b5e} to be recorded, got %+v", workflow.Status.Annotations)
+	}
+
+	posted := fake.requestsTo(http.MethodPost)[0].body
+	if posted["secret"] != string(created.Secret) || posted["url"] != workflow.GetHooksURL() || len(posted["events"].([]interface{})) != 4 {
+		t.Errorf("Unexpected hook %+v", posted)
+	}
+
+	// Up to date hooks are left alone.
+	hookPath := "repositories/my-workspace/my-repo/hooks/%7B8c8e4b5e%7D"
+	fake.respond(http.MethodGet, hookPath, http.StatusOK, map[string]interface{}{
+		"uuid":   "{8c8e4b5e}",
+		"url":    workflow.GetHooksURL(),
+		"active": true,
+		"events": []string{"pullrequest:updated", "pullrequest:created", "pullrequest:rejected", "pullrequest:fulfilled"},
+	})
+	if webhook, err := reconciler.ReconcileHook(ctx, workflow); err != nil || webhook != nil {
+		t.Errorf("Want no changes, got %+v and error %v", webhook, err)
+	}
+
+	// Hooks are updated when events change, rotating their secret.
+	workflow.Spec.Events = []string{"push", "pull_request"}
+	fake.respond(http.MethodPut, hookPath, http.StatusOK, nil)
+	updated, err := reconciler.ReconcileHook(ctx, workflow)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	put := fake.requestsTo(http.MethodPut)[0].body
+	if len(updated.Secret) == 0 || put["secret"] != string(updated.Secret) || len(put["events"].([]interface{})) != 5 {
+		t.Errorf("Want the hook to be updated with a new secret, got %+v", put)
+	}
+
+	// Hooks deleted by mistake are created again.
+	fake.respond(http.MethodGet, hookPath, http.StatusNotFound, nil)
+	fake.respond(http.MethodPost, "repositories/my-workspace/my-repo/hooks", http.StatusCreated, map[string]string{"uuid": "{1d2e3f4a}"})
+	if _, err := reconciler.ReconcileHook(ctx, workflow); err != nil || workflow.GetWebhookUUID() != "{1d2e3f4a}" {
+		t.Errorf("Want a new hook, got %s and error %v", workflow.GetWebhookUUID(), err)
+	}
+
+	fake.respond(http.MethodDelete, "repositories/my-workspace/my-repo/hooks/%7B1d2e3f4a%7D", http.StatusNoContent, nil)
+	if err := reconciler.Delete(ctx, workflow); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestCloudReconcileKeys(t *testing.T) {
+	fake, client := newFakeBitbucketCloud(t)
+	fake.respond(http.MethodPost, "repositories/my-workspace/my-repo/deploy-keys", http.StatusOK, map[string]interface{}{"id": 12})
+
+	ctx := newTestContext()
+	workflow := newTestWorkflow(workflowsv1alpha1.BitbucketCloudProvider)
+	workflow.Spec.Repository.Private = true
+	reconciler := NewCloudDeployKeysReconciler(client)
+
+	keyPairs, err := reconciler.ReconcileKeys(ctx, workflow)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(keyPairs) != 1 || *workflow.GetDeployKeyID(workflow.Spec.Repository) != 12 {
+		t.Fatalf("Want access key #12 to be created and recorded, got %d key pairs and %+v", len(keyPairs), workflow.Status.Annotations)
+	}
+
+	if posted := fake.requestsTo(http.MethodPost)[0].body; posted["key"] != string(keyPairs[0].PublicKey) {
+		t.Errorf("Unexpected access key %+v", posted)
+	}
+
+	// Existing keys are left alone.
+	fake.respond(http.MethodGet, "repositories/my-workspace/my-repo/deploy-keys/12", http.StatusOK, map[string]interface{}{"id": 12})
+	if keyPairs, err := reconciler.ReconcileKeys(ctx, workflow); err != nil || len(keyPairs) != 0 {
+		t.Errorf("Want no changes, got %d key pairs and error %v", len(keyPairs), err)
+	}
+
+	// Keys already deleted are ignored.
+	if err := reconciler.Delete(ctx, workflow); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestCloudGetWorkflowContent(t *testing.T) {
+	fake, client := newFakeBitbucketCloud(t)
+	fake.respond(http.MethodGet, "repositories/my-workspace/my-repo/src/a1b2c3/.workflows/test-1.yaml", http.StatusOK, `spec:
+  repo:
+    owner: attacker
+    name: other-repo
+  events:
+  - push
+  tasks:
+    test:
+      steps:
+      - run: make test
+`)
+
+	ctx := newTestContext()
+	workflow := newTestWorkflow(workflowsv1alpha1.BitbucketCloudProvider)
+	reader := NewCloudWorkflowReader(client)
+
+	got, err := reader.GetWorkflowContent(ctx, workflow, ".workflows/test-1.yaml", "a1b2c3")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if got.Spec.Repository.String() != "my-workspace/my-repo" || got.Spec.Tasks["test"] == nil {
+		t.Errorf("Unexpected workflow %+v", got.Spec)
+	}
+
+	if _, err := reader.GetWorkflowContent(ctx, workflow, ".workflows/test-1.yaml", "d4e5f6"); !github.IsNotFound(err) {
+		t.Errorf("Want a not found error, got %v", err)
+	}
+}
+
+func TestCloudCompleteEvent(t *testing.T) {
+	fake, client := newFakeBitbucketCloud(t)
+	head := "da1560886d4f094c3e6c9ef40349f7d38b5d27d7"
+	previous := "95790bf891e76fee5e1747ab589903a6a1f80f22"
+
+	fake.respond(http.MethodGet, "repositories/my-workspace/my-repo/diffstat/"+head+".."+previous, http.StatusOK, map[string]interface{}{
+		"values": []interface{}{
+			map[string]interface{}{"new": map[string]string{"path": "README.md"}, "old": map[string]string{"path": "README.md"}},
+			map[string]interface{}{"new": map[string]string{"path": "docs/guide.md"}, "old": map[string]string{"path": "guide.md"}},
+		},
+		"next": client.Host().APIURL + "repositories/my-workspace/my-repo/diffstat/" + head + ".." + previous + "?page=2",
+	})
+	fake.respond(http.MethodGet, "repositories/my-workspace/my-repo/diffstat/"+head+".."+previous+"?page=2", http.StatusOK, map[string]interface{}{
+		"values": []interface{}{
+			map[string]interface{}{"old": map[string]string{"path": "pkg/foo/bar.go"}},
+		},
+	})
+
+	event := &github.Event{Name: "push",
+		HeadCommitSHA: head,
+		Body:          []byte(`{"push": {"changes": [{"new": {"target": {"hash": "` + head + `"}}, "old": {"target": {"hash": "` + previous + `"}}}]}}`),
+	}
+
+	workflow := newTestWorkflow(workflowsv1alpha1.BitbucketCloudProvider)
+	if err := NewCloudEventCompleter(client).CompleteEvent(newTestContext(), workflow, event); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if diff := cmp.Diff([]string{"README.md", "docs/guide.md", "guide.md", "pkg/foo/bar.go"}, event.Changes); diff != "" {
+		t.Errorf("Changes mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestCloudCompleteEventResolvesShortHashes(t *testing.T) {
+	fake, client := newFakeBitbucketCloud(t)
+	fake.respond(http.MethodGet, "repositories/my-workspace/my-repo/commit/da1560886d4f", http.StatusOK, map[string]string{"hash": "da1560886d4f094c3e6c9ef40349f7d38b5d27d7"})
+	fake.respond(http.MethodGet, "repositories/my-workspace/my-repo/commit/95790bf891e7", http.StatusOK, map[string]string{"hash": "95790bf891e76fee5e1747ab589903a6a1f80f22"})
+	fake.respond(http.MethodGet, "repositories/my-workspace/my-repo/pullrequests/7/diffstat", http.StatusOK, map[string]interface{}{
+		"values": []interface{}{
+			map[string]interface{}{"new": map[string]string{"path": "main.go"}},
+		},
+	})
+
+	event := &github.Event{Name: "pull_request",
+		HeadCommitSHA:     "da1560886d4f",
+		BaseCommitSHA:     "95790bf891e7",
+		PullRequestNumber: 7,
+	}
+
+	workflow := newTestWorkflow(workflowsv1alpha1.BitbucketCloudProvider)
+	if err := NewCloudEventCompleter(client).CompleteEvent(newTestContext(), workflow, event); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if event.HeadCommitSHA != "da1560886d4f094c3e6c9ef40349f7d38b5d27d7" || event.BaseCommitSHA != "95790bf891e76fee5e1747ab589903a6a1f80f22" {
+		t.Errorf("Want full hashes, got head %s and base %s", event.HeadCommitSHA, event.BaseCommitSHA)
+	}
+
+	if diff := cmp.Diff([]string{"main.go"}, event.Changes); diff != "" {
+		t.Errorf("Changes mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestCloudCompleteEventRejectsForeignLinks(t *testing.T) {
+	fake, client := newFakeBitbucketCloud(t)
+	fake.respond(http.MethodGet, "repositories/my-workspace/my-repo/pullrequests/7/diffstat", http.StatusOK, map[string]interface{}{
+		"next": "https://attacker.example.com/2.0/diffstat?page=2",
+	})
+
+	event := &github.Event{Name: "pull_request",
+		HeadCommitSHA:     "da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+		PullRequestNumber: 7,
+	}
+
+	workflow := newTestWorkflow(workflowsv1alpha1.BitbucketCloudProvider)
+	if err := NewCloudEventCompleter(client).CompleteEvent(newTestContext(), workflow, event); err == nil {
+		t.Error("Want an error for links outside of the API")
+	}
+}
+
+func TestRequestsAreAuthenticated(t *testing.T) {
+	fake, client := newFakeBitbucketCloud(t)
+	fake.respond(http.MethodGet, "repositories/my-workspace/my-repo", http.StatusOK, map[string]interface{}{})
+	client.token = "bb-token-revoked"
+
+	err := NewCloudRepoReconciler(client).ReconcileRepos(newTestContext(), newTestWorkflow(workflowsv1alpha1.BitbucketCloudProvider))
+	if !hasStatus(err, http.StatusUnauthorized) {
+		t.Errorf("Want an unauthorized error, got %v", err)
+	}
+}
@@ -0,0 +1,353 @@
+package bitbucket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/nubank/workflows/pkg/github"
+)
+
+const (
+
+	// eventKeyHeader defines the header that contains the key of the event
+	// delivered (e.g. repo:push).
+	eventKeyHeader = "X-Event-Key"
+
+	// signatureHeader defines the header that contains the HMAC signature of
+	// the payload, calculated the same way as Github's.
+	signatureHeader = "X-Hub-Signature"
+
+	// cloudHookUUIDHeader defines the header that identifies the Bitbucket
+	// Cloud webhook that sent the request. It's only sent by Bitbucket Cloud.
+	cloudHookUUIDHeader = "X-Hook-UUID"
+
+	// cloudRequestUUIDHeader defines the header that contains a guid
+	// identifying deliveries of Bitbucket Cloud.
+	cloudRequestUUIDHeader = "X-Request-UUID"
+
+	// serverRequestIDHeader defines the header that contains a guid
+	// identifying deliveries of Bitbucket Server.
+	serverRequestIDHeader = "X-Request-Id"
+)
+
+// Length of full commit hashes.
+const fullHashLength = 40
+
+// zeroHash is sent by Bitbucket Server in place of the previous head of
+// branches that were just created.
+var zeroHash = strings.Repeat("0", fullHashLength)
+
+// cloudPullRequestActions translates keys of Bitbucket Cloud pull request
+// events into actions of Github pull_request events, which filters are
+// written against.
+var cloudPullRequestActions = map[string]string{
+	"pullrequest:created":   "opened",
+	"pullrequest:updated":   "synchronize",
+	"pullrequest:fulfilled": "closed",
+	"pullrequest:rejected":  "closed",
+}
+
+// serverPullRequestActions translates keys of Bitbucket Server pull request
+// events into actions of Github pull_request events.
+var serverPullRequestActions = map[string]string{
+	"pr:opened":           "opened",
+	"pr:from_ref_updated": "synchronize",
+	"pr:modified":         "edited",
+	"pr:merged":           "closed",
+	"pr:declined":         "closed",
+	"pr:deleted":          "closed",
+}
+
+// cloudRef is a branch or tag pushed to a Bitbucket Cloud repository.
+type cloudRef struct {
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Target struct {
+		Hash    string `json:"hash"`
+		Message string `json:"message"`
+	} `json:"target"`
+}
+
+// cloudPayload holds the attributes of Bitbucket Cloud payloads that events
+// are built from.
+type cloudPayload struct {
+	Actor struct {
+		Nickname string `json:"nickname"`
+	} `json:"actor"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+	Push struct {
+		Changes []struct {
+			New *cloudRef `json:"new"`
+			Old *cloudRef `json:"old"`
+		} `json:"changes"`
+	} `json:"push"`
+	PullRequest struct {
+		ID          int          `json:"id"`
+		Title       string       `json:"title"`
+		Source      cloudPullRef `json:"source"`
+		Destination cloudPullRef `json:"destination"`
+	} `json:"pullrequest"`
+}
+
+// cloudPullRef is the source or the destination of a Bitbucket Cloud pull
+// request.
+type cloudPullRef struct {
+	Branch struct {
+		Name string `json:"name"`
+	} `json:"branch"`
+	Commit struct {
+		Hash string `json:"hash"`
+	} `json:"commit"`
+}
+
+// serverRepositoryRef identifies a Bitbucket Server repository.
+type serverRepositoryRef struct {
+	Slug    string `json:"slug"`
+	Project struct {
+		Key string `json:"key"`
+	} `json:"project"`
+}
+
+// String returns the repository's full name (project/slug).
+func (s *serverRepositoryRef) String() string {
+	return s.Project.Key + "/" + s.Slug
+}
+
+// serverPullRef is the source or the target of a Bitbucket Server pull
+// request.
+type serverPullRef struct {
+	DisplayID    string              `json:"displayId"`
+	LatestCommit string              `json:"latestCommit"`
+	Repository   serverRepositoryRef `json:"repository"`
+}
+
+// serverPayload holds the attributes of Bitbucket Server payloads that events
+// are built from.
+type serverPayload struct {
+	Actor struct {
+		Name string `json:"name"`
+	} `json:"actor"`
+	Repository serverRepositoryRef `json:"repository"`
+	Changes    []struct {
+		Ref struct {
+			DisplayID string `json:"displayId"`
+			Type      string `json:"type"`
+		} `json:"ref"`
+		FromHash string `json:"fromHash"`
+		ToHash   string `json:"toHash"`
+		Type     string `json:"type"`
+	} `json:"changes"`
+	PullRequest struct {
+		ID      int           `json:"id"`
+		Title   string        `json:"title"`
+		FromRef serverPullRef `json:"fromRef"`
+		ToRef   serverPullRef `json:"toRef"`
+	} `json:"pullRequest"`
+}
+
+// IsWebhookRequest returns true if the supplied request was delivered by a
+// Bitbucket webhook.
+func IsWebhookRequest(request *http.Request) bool {
+	return request.Header.Get(eventKeyHeader) != ""
+}
+
+// ParseWebhookEvent creates a new Event object from the supplied HTTP request
+// delivered by a Bitbucket Cloud or Bitbucket Server webhook. Push and pull
+// request events are normalized into Github push and pull_request events, so
+// that workflows are filtered and run the same way regardless of where
+// repositories are hosted. Since payloads don't list changed files, they're
+// read once events are verified (see EventCompleter).
+func ParseWebhookEvent(request *http.Request) (*github.Event, error) {
+	eventKey := request.Header.Get(eventKeyHeader)
+	if eventKey == "" {
+		return nil, errors.New("Request doesn't appear to have been delivered by a Bitbucket webhook")
+	}
+
+	// Workflows' webhooks always have a secret.
+	signature := request.Header.Get(signatureHeader)
+	if signature == "" {
+		return nil, fmt.Errorf("Access denied: Bitbucket signature header %s is missing", signatureHeader)
+	}
+
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading request body: %w", err)
+	}
+
+	event := &github.Event{
+		Body:          body,
+		HMACSignature: []byte(signature),
+		Name:          eventKey,
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, fmt.Errorf("Error parsing event payload: %w", err)
+	}
+	event.Data = data
+
+	if hookUUID := request.Header.Get(cloudHookUUIDHeader); hookUUID != "" {
+		event.DeliveryID = request.Header.Get(cloudRequestUUIDHeader)
+		event.HookID = hookUUID
+
+		var payload cloudPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, fmt.Errorf("Error parsing event payload: %w", err)
+		}
+		parseCloudEvent(event, eventKey, &payload)
+	} else {
+		event.DeliveryID = request.Header.Get(serverRequestIDHeader)
+
+		var payload serverPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, fmt.Errorf("Error parsing event payload: %w", err)
+		}
+		parseServerEvent(event, eventKey, &payload)
+	}
+
+	return event, nil
+}
+
+// parseCloudEvent fills the supplied event with the contents of a Bitbucket
+// Cloud payload.
+func parseCloudEvent(event *github.Event, eventKey string, payload *cloudPayload) {
+	event.Repository = payload.Repository.FullName
+	event.Sender = payload.Actor.Nickname
+
+	if eventKey == "repo:push" {
+		event.Name = "push"
+		if len(payload.Push.Changes) == 0 {
+			return
+		}
+
+		change := payload.Push.Changes[0]
+		ref := change.New
+		if ref == nil {
+			// The branch or tag was deleted.
+			ref = change.Old
+		}
+
+		if ref != nil && ref.Type == "branch" {
+			event.Branch = ref.Name
+		}
+
+		if change.New != nil {
+			event.HeadCommitSHA = change.New.Target.Hash
+			event.HeadCommitMessage = change.New.Target.Message
+		}
+		return
+	}
+
+	if action, ok := cloudPullRequestActions[eventKey]; ok {
+		pullRequest := payload.PullRequest
+
+		event.Name = "pull_request"
+		event.Action = action
+		event.Branch = pullRequest.Source.Branch.Name
+		event.BaseBranch = pullRequest.Destination.Branch.Name
+		event.HeadCommitSHA = pullRequest.Source.Commit.Hash
+		event.BaseCommitSHA = pullRequest.Destination.Commit.Hash
+		event.PullRequestNumber = pullRequest.ID
+		event.PullRequestTitle = pullRequest.Title
+	}
+}
+
+// parseServerEvent fills the supplied event with the contents of a Bitbucket
+// Server payload.
+func parseServerEvent(event *github.Event, eventKey string, payload *serverPayload) {
+	event.Sender = payload.Actor.Name
+
+	if eventKey == "diagnostics:ping" {
+		event.Name = "ping"
+		return
+	}
+
+	if eventKey == "repo:refs_changed" {
+		event.Name = "push"
+		event.Repository = payload.Repository.String()
+		if len(payload.Changes) == 0 {
+			return
+		}
+
+		change := payload.Changes[0]
+		if change.Ref.Type == "BRANCH" {
+			event.Branch = change.Ref.DisplayID
+		}
+
+		if change.Type != "DELETE" {
+			event.HeadCommitSHA = change.ToHash
+		}
+		return
+	}
+
+	if action, ok := serverPullRequestActions[eventKey]; ok {
+		pullRequest := payload.PullRequest
+
+		event.Name = "pull_request"
+		event.Action = action
+		event.Repository = pullRequest.ToRef.Repository.String()
+		event.Branch = pullRequest.FromRef.DisplayID
+		event.BaseBranch = pullRequest.ToRef.DisplayID
+		event.HeadCommitSHA = pullRequest.FromRef.LatestCommit
+		event.BaseCommitSHA = pullRequest.ToRef.LatestCommit
+		event.PullRequestNumber = pullRequest.ID
+		event.PullRequestTitle = pullRequest.Title
+		return
+	}
+
+	event.Repository = payload.Repository.String()
+}
+
+// previousCloudHead returns the commit the branch pushed to pointed to before
+// the supplied Bitbucket Cloud push event, if any.
+func previousCloudHead(event *github.Event) string {
+	var payload cloudPayload
+	if err := json.Unmarshal(event.Body, &payload); err != nil || len(payload.Push.Changes) == 0 {
+		return ""
+	}
+
+	if old := payload.Push.Changes[0].Old; old != nil {
+		return old.Target.Hash
+	}
+	return ""
+}
+
+// previousServerHead returns the commit the branch pushed to pointed to
+// before the supplied Bitbucket Server push event, if any.
+func previousServerHead(event *github.Event) string {
+	var payload serverPayload
+	if err := json.Unmarshal(event.Body, &payload); err != nil || len(payload.Changes) == 0 {
+		return ""
+	}
+
+	if from := payload.Changes[0].FromHash; from != zeroHash {
+		return from
+	}
+	return ""
+}
+
+// changeSet collects changed files in the order they're listed, without
+// duplicates.
+type changeSet struct {
+	seen  map[string]bool
+	files []string
+}
+
+// newChangeSet returns an empty changeSet.
+func newChangeSet() *changeSet {
+	return &changeSet{seen: make(map[string]bool), files: make([]string, 0)}
+}
+
+// add adds the supplied file to the set, unless it's already there.
+func (c *changeSet) add(file string) {
+	if file != "" && !c.seen[file] {
+		c.seen[file] = true
+		c.files = append(c.files, file)
+	}
+}
@@ -0,0 +1,222 @@
+package bitbucket
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/nubank/workflows/pkg/github"
+)
+
+const webhookSecret = "secret"
+
+func newWebhookRequest(eventKey string, cloud bool, payload string) *http.Request {
+	request := &http.Request{
+		Body:   ioutil.NopCloser(strings.NewReader(payload)),
+		Header: http.Header{},
+	}
+	request.Header.Set("X-Event-Key", eventKey)
+	if cloud {
+		request.Header.Set("X-Hook-UUID", "{5b1f7d3e-2a4c-4b6d-8e0f-1a2b3c4d5e6f}")
+		request.Header.Set("X-Request-UUID", "2f5e0a4c-8b3d-4e1f-9a6b-7c8d9e0f1a2b")
+	} else {
+		request.Header.Set("X-Request-Id", "7c8d9e0f-1a2b-4e1f-9a6b-2f5e0a4c8b3d")
+	}
+
+	hash := hmac.New(sha256.New, []byte(webhookSecret))
+	hash.Write([]byte(payload))
+	request.Header.Set("X-Hub-Signature", "sha256="+hex.EncodeToString(hash.Sum(nil)))
+	return request
+}
+
+// ignoredFields lists the fields that aren't relevant for comparing events.
+var ignoredFields = cmpopts.IgnoreFields(github.Event{}, "Body", "Data", "HMACSignature")
+
+func TestParsesCloudPushEventsProperly(t *testing.T) {
+	payload := `{
+  "actor": {"nickname": "john-doe"},
+  "repository": {"full_name": "my-workspace/my-repo"},
+  "push": {
+    "changes": [
+      {
+        "new": {"type": "branch", "name": "dev", "target": {"hash": "da1560886d4f094c3e6c9ef40349f7d38b5d27d7", "message": "Fix tests\n"}},
+        "old": {"type": "branch", "name": "dev", "target": {"hash": "95790bf891e76fee5e1747ab589903a6a1f80f22"}}
+      }
+    ]
+  }
+}`
+
+	event, err := ParseWebhookEvent(newWebhookRequest("repo:push", true, payload))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := &github.Event{Name: "push",
+		Branch:            "dev",
+		DeliveryID:        "2f5e0a4c-8b3d-4e1f-9a6b-7c8d9e0f1a2b",
+		HeadCommitMessage: "Fix tests\n",
+		HeadCommitSHA:     "da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+		HookID:            "{5b1f7d3e-2a4c-4b6d-8e0f-1a2b3c4d5e6f}",
+		Repository:        "my-workspace/my-repo",
+		Sender:            "john-doe",
+	}
+
+	if diff := cmp.Diff(want, event, ignoredFields); diff != "" {
+		t.Errorf("Event mismatch (-want +got):\n%s", diff)
+	}
+
+	if previous := previousCloudHead(event); previous != "95790bf891e76fee5e1747ab589903a6a1f80f22" {
+		t.Errorf("Unexpected previous head %s", previous)
+	}
+
+	if valid, message := event.VerifySignature([]byte(webhookSecret)); !valid {
+		t.Errorf("Want a valid signature, got %s", message)
+	}
+}
+
+func TestParsesCloudPullRequestEventsProperly(t *testing.T) {
+	payload := `{
+  "actor": {"nickname": "john-doe"},
+  "repository": {"full_name": "my-workspace/my-repo"},
+  "pullrequest": {
+    "id": 7,
+    "title": "Fix tests",
+    "source": {"branch": {"name": "fix-tests"}, "commit": {"hash": "da1560886d4f"}},
+    "destination": {"branch": {"name": "main"}, "commit": {"hash": "95790bf891e7"}}
+  }
+}`
+
+	event, err := ParseWebhookEvent(newWebhookRequest("pullrequest:updated", true, payload))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := &github.Event{Name: "pull_request",
+		Action:            "synchronize",
+		BaseBranch:        "main",
+		BaseCommitSHA:     "95790bf891e7",
+		Branch:            "fix-tests",
+		DeliveryID:        "2f5e0a4c-8b3d-4e1f-9a6b-7c8d9e0f1a2b",
+		HeadCommitSHA:     "da1560886d4f",
+		HookID:            "{5b1f7d3e-2a4c-4b6d-8e0f-1a2b3c4d5e6f}",
+		PullRequestNumber: 7,
+		PullRequestTitle:  "Fix tests",
+		Repository:        "my-workspace/my-repo",
+		Sender:            "john-doe",
+	}
+
+	if diff := cmp.Diff(want, event, ignoredFields); diff != "" {
+		t.Errorf("Event mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestParsesServerPushEventsProperly(t *testing.T) {
+	payload := `{
+  "eventKey": "repo:refs_changed",
+  "actor": {"name": "john.doe"},
+  "repository": {"slug": "my-repo", "project": {"key": "PROJ"}},
+  "changes": [
+    {
+      "ref": {"id": "refs/heads/dev", "displayId": "dev", "type": "BRANCH"},
+      "fromHash": "0000000000000000000000000000000000000000",
+      "toHash": "da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+      "type": "ADD"
+    }
+  ]
+}`
+
+	event, err := ParseWebhookEvent(newWebhookRequest("repo:refs_changed", false, payload))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := &github.Event{Name: "push",
+		Branch:        "dev",
+		DeliveryID:    "7c8d9e0f-1a2b-4e1f-9a6b-2f5e0a4c8b3d",
+		HeadCommitSHA: "da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+		Repository:    "PROJ/my-repo",
+		Sender:        "john.doe",
+	}
+
+	if diff := cmp.Diff(want, event, ignoredFields); diff != "" {
+		t.Errorf("Event mismatch (-want +got):\n%s", diff)
+	}
+
+	// New branches have no previous head.
+	if previous := previousServerHead(event); previous != "" {
+		t.Errorf("Unexpected previous head %s", previous)
+	}
+}
+
+func TestParsesServerPullRequestEventsProperly(t *testing.T) {
+	payload := `{
+  "eventKey": "pr:merged",
+  "actor": {"name": "john.doe"},
+  "pullRequest": {
+    "id": 7,
+    "title": "Fix tests",
+    "fromRef": {"displayId": "fix-tests", "latestCommit": "da1560886d4f094c3e6c9ef40349f7d38b5d27d7", "repository": {"slug": "my-fork", "project": {"key": "~JOHN.DOE"}}},
+    "toRef": {"displayId": "main", "latestCommit": "95790bf891e76fee5e1747ab589903a6a1f80f22", "repository": {"slug": "my-repo", "project": {"key": "PROJ"}}}
+  }
+}`
+
+	event, err := ParseWebhookEvent(newWebhookRequest("pr:merged", false, payload))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := &github.Event{Name: "pull_request",
+		Action:            "closed",
+		BaseBranch:        "main",
+		BaseCommitSHA:     "95790bf891e76fee5e1747ab589903a6a1f80f22",
+		Branch:            "fix-tests",
+		DeliveryID:        "7c8d9e0f-1a2b-4e1f-9a6b-2f5e0a4c8b3d",
+		HeadCommitSHA:     "da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+		PullRequestNumber: 7,
+		PullRequestTitle:  "Fix tests",
+		Repository:        "PROJ/my-repo",
+		Sender:            "john.doe",
+	}
+
+	if diff := cmp.Diff(want, event, ignoredFields); diff != "" {
+		t.Errorf("Event mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestParsesServerPingEventsProperly(t *testing.T) {
+	event, err := ParseWebhookEvent(newWebhookRequest("diagnostics:ping", false, `{"test": true}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if event.Name != "ping" {
+		t.Errorf("Want a ping event, got %s", event.Name)
+	}
+}
+
+func TestUnsignedEventsAreRejected(t *testing.T) {
+	request := newWebhookRequest("repo:push", true, `{}`)
+	request.Header.Del("X-Hub-Signature")
+
+	if _, err := ParseWebhookEvent(request); err == nil || !strings.Contains(err.Error(), "X-Hub-Signature is missing") {
+		t.Errorf("Want a missing signature error, got %v", err)
+	}
+}
+
+func TestTamperedEventsAreRejected(t *testing.T) {
+	event, err := ParseWebhookEvent(newWebhookRequest("repo:push", true, `{}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	event.Body = []byte(`{"actor": {"nickname": "attacker"}}`)
+	if valid, _ := event.VerifySignature([]byte(webhookSecret)); valid {
+		t.Error("Want an invalid signature")
+	}
+}
@@ -0,0 +1,141 @@
+package bitbucket
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeToken is the token the fake Bitbucket API accepts.
+const fakeToken = "bb-token-abc"
+
+// fakeResponse is the canned response to a request. Strings are written as is
+// and any other body is encoded as JSON.
+type fakeResponse struct {
+	status int
+	body   interface{}
+}
+
+// fakeRequest is a request received by the fake Bitbucket API.
+type fakeRequest struct {
+	method string
+	path   string
+	body   map[string]interface{}
+}
+
+// fakeBitbucket is a local fake of the Bitbucket Cloud or Bitbucket Server
+// REST API, answering requests with canned responses keyed by method and API
+// path (e.g. GET repositories/my-workspace/my-repo). Paths include the query,
+// if any.
+type fakeBitbucket struct {
+	mutex     sync.Mutex
+	apiPath   string
+	responses map[string]fakeResponse
+	requests  []fakeRequest
+}
+
+// newFakeBitbucketCloud starts a fake Bitbucket Cloud API and returns a client
+// talking to it.
+func newFakeBitbucketCloud(t *testing.T) (*fakeBitbucket, *Client) {
+	fake, server := newFakeBitbucket(t, "/2.0/")
+
+	client, err := NewCloudClient(server.URL+"/2.0", "", fakeToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fake, client
+}
+
+// newFakeBitbucketServer starts a fake Bitbucket Server API and returns a
+// client talking to it.
+func newFakeBitbucketServer(t *testing.T) (*fakeBitbucket, *Client) {
+	fake, server := newFakeBitbucket(t, serverAPIPath)
+
+	client, err := NewServerClient(server.URL, "", fakeToken, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fake, client
+}
+
+// newFakeBitbucket starts a fake Bitbucket API served under the supplied path.
+func newFakeBitbucket(t *testing.T, apiPath string) (*fakeBitbucket, *httptest.Server) {
+	fake := &fakeBitbucket{apiPath: apiPath,
+		responses: make(map[string]fakeResponse),
+	}
+
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+// respond sets the response to requests sent with the supplied method to the
+// API path in question.
+func (f *fakeBitbucket) respond(method, path string, status int, body interface{}) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.responses[method+" "+path] = fakeResponse{status: status, body: body}
+}
+
+// requestsTo returns the requests received with the supplied method.
+func (f *fakeBitbucket) requestsTo(method string) []fakeRequest {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	requests := make([]fakeRequest, 0)
+	for _, request := range f.requests {
+		if request.method == method {
+			requests = append(requests, request)
+		}
+	}
+	return requests
+}
+
+// ServeHTTP implements http.Handler.
+func (f *fakeBitbucket) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if request.Header.Get("Authorization") != "Bearer "+fakeToken {
+		writeJSON(writer, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	path := strings.TrimPrefix(request.URL.EscapedPath(), f.apiPath)
+	if request.URL.RawQuery != "" {
+		path += "?" + request.URL.RawQuery
+	}
+
+	received := fakeRequest{method: request.Method, path: path}
+	if content, _ := ioutil.ReadAll(request.Body); len(content) != 0 {
+		json.Unmarshal(content, &received.body)
+	}
+	f.requests = append(f.requests, received)
+
+	response, exists := f.responses[request.Method+" "+path]
+	if !exists {
+		writeJSON(writer, http.StatusNotFound, map[string]string{"error": "Not Found"})
+		return
+	}
+
+	if content, ok := response.body.(string); ok {
+		writer.WriteHeader(response.status)
+		writer.Write([]byte(content))
+		return
+	}
+	writeJSON(writer, response.status, response.body)
+}
+
+// writeJSON writes the supplied value as a JSON response.
+func writeJSON(writer http.ResponseWriter, status int, value interface{}) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+	if value != nil {
+		json.NewEncoder(writer).Encode(value)
+	}
+}
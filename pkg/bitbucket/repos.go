@@ -0,0 +1,15 @@
+package bitbucket
+
+import (
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+)
+
+// repositoriesOf returns pointers to all repositories of the supplied
+// workflow, so that their attributes can be filled in.
+func repositoriesOf(workflow *workflowsv1alpha1.Workflow) []*workflowsv1alpha1.Repository {
+	repos := []*workflowsv1alpha1.Repository{workflow.Spec.Repository}
+	for i := range workflow.Spec.AdditionalRepositories {
+		repos = append(repos, &workflow.Spec.AdditionalRepositories[i])
+	}
+	return repos
+}
@@ -0,0 +1,469 @@
+package bitbucket
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	"github.com/nubank/workflows/pkg/github"
+	"github.com/nubank/workflows/pkg/secrets"
+	"knative.dev/pkg/logging"
+)
+
+// serverEvents maps events workflows are triggered by to the Bitbucket Server
+// events delivering them.
+var serverEvents = map[string][]string{
+	"push":         {"repo:refs_changed"},
+	"pull_request": {"pr:opened", "pr:from_ref_updated", "pr:modified", "pr:merged", "pr:declined", "pr:deleted"},
+}
+
+// Permissions of Bitbucket Server access keys.
+const (
+	repoReadPermission  = "REPO_READ"
+	repoWritePermission = "REPO_WRITE"
+)
+
+// Size of the pages of changed files read from Bitbucket Server.
+const serverChangesPageSize = 500
+
+// serverRepository is a Bitbucket Server repository, as returned by the API.
+type serverRepository struct {
+	Public bool `json:"public"`
+}
+
+// serverBranch is a Bitbucket Server branch, as returned by the API.
+type serverBranch struct {
+	DisplayID string `json:"displayId"`
+}
+
+// serverHook is a Bitbucket Server repository webhook, as sent to and
+// returned by the API.
+type serverHook struct {
+	ID            int64    `json:"id,omitempty"`
+	Name          string   `json:"name"`
+	URL           string   `json:"url"`
+	Active        bool     `json:"active"`
+	Events        []string `json:"events"`
+	Configuration struct {
+		Secret string `json:"secret,omitempty"`
+	} `json:"configuration"`
+}
+
+// serverDeployKey is a Bitbucket Server access key, as sent to and returned
+// by the API.
+type serverDeployKey struct {
+	Key struct {
+		ID    int64  `json:"id,omitempty"`
+		Text  string `json:"text"`
+		Label string `json:"label,omitempty"`
+	} `json:"key"`
+	Permission string `json:"permission"`
+}
+
+// serverRepoPath returns the path under which the supplied API serves the
+// repository in question (e.g. api/1.0 or keys/1.0).
+func serverRepoPath(api string, repo *workflowsv1alpha1.Repository) string {
+	return fmt.Sprintf("%s/projects/%s/repos/%s", api, url.PathEscape(repo.Owner), url.PathEscape(repo.Name))
+}
+
+// serverRepoReconciler implements github.RepoReconciler for Bitbucket Server
+// repositories.
+type serverRepoReconciler struct {
+	client *Client
+}
+
+// ReconcileRepos implements github.RepoReconciler.
+func (r *serverRepoReconciler) ReconcileRepos(ctx context.Context, workflow *workflowsv1alpha1.Workflow) error {
+	for _, repo := range repositoriesOf(workflow) {
+		path := serverRepoPath("api/1.0", repo)
+
+		var current serverRepository
+		if err := r.client.do(ctx, http.MethodGet, path, nil, &current); err != nil {
+			return fmt.Errorf("Error fetching Bitbucket repository %s: %w", repo, err)
+		}
+
+		// Empty repositories have no default branch.
+		var branch serverBranch
+		if err := r.client.do(ctx, http.MethodGet, path+"/branches/default", nil, &branch); err != nil && !hasStatus(err, http.StatusNotFound) {
+			return fmt.Errorf("Error fetching the default branch of Bitbucket repository %s: %w", repo, err)
+		}
+
+		repo.Private = !current.Public
+		repo.DefaultBranch = branch.DisplayID
+	}
+	return nil
+}
+
+// NewServerRepoReconciler returns a new RepoReconciler for Bitbucket Server
+// repositories.
+func NewServerRepoReconciler(client *Client) github.RepoReconciler {
+	return &serverRepoReconciler{client: client}
+}
+
+// serverWebhookReconciler implements github.WebhookReconciler for Bitbucket
+// Server repository webhooks.
+type serverWebhookReconciler struct {
+	client *Client
+}
+
+// ReconcileHook creates or updates the webhook of the supplied workflow.
+// Updates rotate the webhook's secret.
+func (w *serverWebhookReconciler) ReconcileHook(ctx context.Context, workflow *workflowsv1alpha1.Workflow) (*github.Webhook, error) {
+	repo := workflow.Spec.Repository
+	logger := logging.FromContext(ctx).With("repository", repo)
+
+	id := workflow.GetWebhookID()
+	if id == nil {
+		logger.Info("There are no recognized webhooks associated to the workflow. Creating a new one")
+		return w.createHook(ctx, workflow)
+	}
+
+	var current serverHook
+	err := w.client.do(ctx, http.MethodGet, w.hookPath(repo, *id), nil, &current)
+	if hasStatus(err, http.StatusNotFound) {
+		logger.Infow("Unable to find webhook for supplied id. It might have been deleted by mistake. Creating a new one", "webhook-id", *id)
+		return w.createHook(ctx, workflow)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get webhook #%d: %w", *id, err)
+	}
+
+	desired := newServerHook(workflow, "")
+	if current.URL == desired.URL && current.Active && equalEvents(current.Events, desired.Events) {
+		logger.Infow("Webhook settings are up to date", "webhook-id", *id)
+		return nil, nil
+	}
+
+	logger.Infow("Webhook and workflow settings are out of sync. Updating webhook", "webhook-id", *id)
+	secretToken := secrets.GenerateRandomToken()
+	if err := w.client.do(ctx, http.MethodPut, w.hookPath(repo, *id), newServerHook(workflow, secretToken), nil); err != nil {
+		return nil, fmt.Errorf("unable to update Bitbucket webhook for repository %s: %w", repo, err)
+	}
+
+	logger.Infow("Webhook has been successfully updated", "webhook-id", *id)
+	return &github.Webhook{ID: *id, Secret: []byte(secretToken)}, nil
+}
+
+// hookPath returns the API path of the supplied webhook.
+func (w *serverWebhookReconciler) hookPath(repo *workflowsv1alpha1.Repository, id int64) string {
+	return fmt.Sprintf("%s/webhooks/%d", serverRepoPath("api/1.0", repo), id)
+}
+
+// newServerHook returns the desired state of the workflow's webhook.
+func newServerHook(workflow *workflowsv1alpha1.Workflow, secret string) *serverHook {
+	h := &serverHook{Name: fmt.Sprintf("workflows: %s/%s", workflow.GetNamespace(), workflow.GetName()),
+		URL:    workflow.GetHooksURL(),
+		Active: true,
+		Events: make([]string, 0),
+	}
+	h.Configuration.Secret = secret
+
+	for _, event := range workflow.Spec.Events {
+		h.Events = append(h.Events, serverEvents[event]...)
+	}
+	return h
+}
+
+// createHook creates a new webhook, recording its id in the workflow.
+func (w *serverWebhookReconciler) createHook(ctx context.Context, workflow *workflowsv1alpha1.Workflow) (*github.Webhook, error) {
+	repo := workflow.Spec.Repository
+	secretToken := secrets.GenerateRandomToken()
+
+	var created serverHook
+	if err := w.client.do(ctx, http.MethodPost, serverRepoPath("api/1.0", repo)+"/webhooks", newServerHook(workflow, secretToken), &created); err != nil {
+		return nil, fmt.Errorf("unable to create Bitbucket webhook for repository %s: %w", repo, err)
+	}
+
+	logger := logging.FromContext(ctx)
+	logger.Infow("Webhook has been successfully created",
+		"repository", repo,
+		"webhook-id", created.ID)
+
+	workflow.SetWebhookID(created.ID)
+	return &github.Webhook{ID: created.ID, Secret: []byte(secretToken)}, nil
+}
+
+// Delete deletes the webhook associated to the workflow in question. Webhooks
+// already deleted on Bitbucket are ignored.
+func (w *serverWebhookReconciler) Delete(ctx context.Context, workflow *workflowsv1alpha1.Workflow) error {
+	repo := workflow.Spec.Repository
+
+	id := workflow.GetWebhookID()
+	if id == nil {
+		return fmt.Errorf("Unable to delete webhook because its identifier is unknown")
+	}
+
+	err := w.client.do(ctx, http.MethodDelete, w.hookPath(repo, *id), nil, nil)
+	if err != nil && !hasStatus(err, http.StatusNotFound) {
+		return fmt.Errorf("Error deleting Bitbucket webhook: %w", err)
+	}
+
+	logger := logging.FromContext(ctx)
+	logger.Infow("Webhook has been successfully deleted", "repository", repo, "webhook-id", *id)
+
+	return nil
+}
+
+// NewServerWebhookReconciler returns a new WebhookReconciler for Bitbucket
+// Server repository webhooks.
+func NewServerWebhookReconciler(client *Client) github.WebhookReconciler {
+	return &serverWebhookReconciler{client: client}
+}
+
+// serverDeployKeysReconciler implements github.DeployKeysReconciler for
+// Bitbucket Server access keys.
+type serverDeployKeysReconciler struct {
+	client *Client
+}
+
+// ReconcileKeys creates or updates the access keys of repositories that need
+// them.
+func (d *serverDeployKeysReconciler) ReconcileKeys(ctx context.Context, workflow *workflowsv1alpha1.Workflow) ([]secrets.KeyPair, error) {
+	keyPairs := make([]secrets.KeyPair, 0)
+
+	for _, repo := range workflow.GetRepositories() {
+		if repo.NeedsSSHPrivateKeys() {
+			if keyPair, err := d.reconcileKey(ctx, workflow, &repo); err != nil {
+				return nil, err
+			} else if keyPair != nil {
+				keyPairs = append(keyPairs, *keyPair)
+			}
+		}
+	}
+	return keyPairs, nil
+}
+
+// reconcileKey creates the access key of the supplied repository, or rotates
+// it when its permission changed.
+func (d *serverDeployKeysReconciler) reconcileKey(ctx context.Context, workflow *workflowsv1alpha1.Workflow, repo *workflowsv1alpha1.Repository) (*secrets.KeyPair, error) {
+	logger := logging.FromContext(ctx).With("repository", repo)
+
+	id := workflow.GetDeployKeyID(repo)
+	if id == nil {
+		logger.Info("There are no recognized access keys associated to the workflow. Creating a new one")
+		return d.createDeployKey(ctx, workflow, repo)
+	}
+
+	var current serverDeployKey
+	err := d.client.do(ctx, http.MethodGet, d.keyPath(repo, *id), nil, &current)
+	if hasStatus(err, http.StatusNotFound) {
+		logger.Infow("Unable to find an access key for the supplied id. It might have been deleted by mistake. Creating a new one", "deploy-key-id", *id)
+		return d.createDeployKey(ctx, workflow, repo)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get access key #%d: %w", *id, err)
+	}
+
+	if current.Permission != serverKeyPermission(repo) {
+		logger.Infow("Access key and workflow settings are out of sync. Rotating access key", "deploy-key-id", *id)
+		if err := d.deleteDeployKey(ctx, repo, *id); err != nil {
+			return nil, err
+		}
+		return d.createDeployKey(ctx, workflow, repo)
+	}
+
+	logger.Infow("Access key settings are up to date", "deploy-key-id", *id)
+
+	return nil, nil
+}
+
+// serverKeyPermission returns the permission the access key of the supplied
+// repository must be granted.
+func serverKeyPermission(repo *workflowsv1alpha1.Repository) string {
+	if repo.IsReadOnlyDeployKey() {
+		return repoReadPermission
+	}
+	return repoWritePermission
+}
+
+// keyPath returns the API path of the supplied access key.
+func (d *serverDeployKeysReconciler) keyPath(repo *workflowsv1alpha1.Repository, id int64) string {
+	return fmt.Sprintf("%s/ssh/%d", serverRepoPath("keys/1.0", repo), id)
+}
+
+// createDeployKey creates a new access key, recording its id in the workflow.
+func (d *serverDeployKeysReconciler) createDeployKey(ctx context.Context, workflow *workflowsv1alpha1.Workflow, repo *workflowsv1alpha1.Repository) (*secrets.KeyPair, error) {
+	keyPair, err := secrets.GenerateKeyPair(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &serverDeployKey{Permission: serverKeyPermission(repo)}
+	key.Key.Text = string(keyPair.PublicKey)
+	key.Key.Label = fmt.Sprintf("%s-ssh-public-key", workflow.GetName())
+
+	var created serverDeployKey
+	if err := d.client.do(ctx, http.MethodPost, serverRepoPath("keys/1.0", repo)+"/ssh", key, &created); err != nil {
+		return nil, fmt.Errorf("unable to create Bitbucket access key for repository %s: %w", repo, err)
+	}
+
+	logger := logging.FromContext(ctx)
+	logger.Infow("Access key has been successfully created",
+		"repository", repo,
+		"deploy-key-id", created.Key.ID)
+
+	workflow.SetDeployKeyID(repo, created.Key.ID)
+	return keyPair, nil
+}
+
+// deleteDeployKey deletes an existing access key. Keys already deleted on
+// Bitbucket are ignored.
+func (d *serverDeployKeysReconciler) deleteDeployKey(ctx context.Context, repo *workflowsv1alpha1.Repository, id int64) error {
+	err := d.client.do(ctx, http.MethodDelete, d.keyPath(repo, id), nil, nil)
+	if err != nil && !hasStatus(err, http.StatusNotFound) {
+		return fmt.Errorf("unable to delete Bitbucket access key for repository %s: %w", repo, err)
+	}
+
+	logger := logging.FromContext(ctx)
+	logger.Infow("Access key has been successfully deleted", "repository", repo, "deploy-key-id", id)
+
+	return nil
+}
+
+// Delete deletes all access keys associated to the workflow in question.
+func (d *serverDeployKeysReconciler) Delete(ctx context.Context, workflow *workflowsv1alpha1.Workflow) error {
+	for _, repo := range workflow.GetRepositories() {
+		if repo.NeedsSSHPrivateKeys() {
+			id := workflow.GetDeployKeyID(&repo)
+
+			if id == nil {
+				return fmt.Errorf("Error deleting access key for repository %s: the key's identifier is unknown", repo.String())
+			}
+
+			if err := d.deleteDeployKey(ctx, &repo, *id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// NewServerDeployKeysReconciler returns a new DeployKeysReconciler for
+// Bitbucket Server access keys.
+func NewServerDeployKeysReconciler(client *Client) github.DeployKeysReconciler {
+	return &serverDeployKeysReconciler{client: client}
+}
+
+// serverWorkflowReader implements github.WorkflowReader for workflows stored
+// in Bitbucket Server repositories.
+type serverWorkflowReader struct {
+	client *Client
+}
+
+// GetWorkflowContent implements github.WorkflowReader.
+func (w *serverWorkflowReader) GetWorkflowContent(ctx context.Context, workflow *workflowsv1alpha1.Workflow, filePath, ref string) (*workflowsv1alpha1.Workflow, error) {
+	path := fmt.Sprintf("%s/raw/%s?at=%s", serverRepoPath("api/1.0", workflow.Spec.Repository), escapePath(filePath), url.QueryEscape(ref))
+
+	content, err := w.client.doRaw(ctx, http.MethodGet, path, nil)
+	if hasStatus(err, http.StatusNotFound) {
+		return nil, github.NewNotFoundError(fmt.Sprintf("Unable to find workflow %s", workflow.GetName()))
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return github.ParseWorkflow(workflow, content)
+}
+
+// NewServerWorkflowReader returns a new WorkflowReader for workflows stored in
+// Bitbucket Server repositories.
+func NewServerWorkflowReader(client *Client) github.WorkflowReader {
+	return &serverWorkflowReader{client: client}
+}
+
+// serverChanges is a page of files changed between two commits, as returned
+// by the API.
+type serverChanges struct {
+	Values []struct {
+		Path struct {
+			ToString string `json:"toString"`
+		} `json:"path"`
+		SrcPath *struct {
+			ToString string `json:"toString"`
+		} `json:"srcPath"`
+	} `json:"values"`
+	IsLastPage    bool `json:"isLastPage"`
+	NextPageStart int  `json:"nextPageStart"`
+}
+
+// serverCommit is a Bitbucket Server commit, as returned by the API.
+type serverCommit struct {
+	Message string `json:"message"`
+}
+
+// serverEventCompleter implements github.EventCompleter for events delivered
+// by Bitbucket Server, whose payloads neither list changed files nor carry
+// the message of pushed commits.
+type serverEventCompleter struct {
+	client *Client
+}
+
+// CompleteEvent implements github.EventCompleter.
+func (s *serverEventCompleter) CompleteEvent(ctx context.Context, workflow *workflowsv1alpha1.Workflow, event *github.Event) error {
+	repoPath := serverRepoPath("api/1.0", workflow.Spec.Repository)
+
+	switch event.Name {
+	case "push":
+		if event.HeadCommitSHA == "" {
+			// The branch was deleted.
+			return nil
+		}
+
+		var commit serverCommit
+		if err := s.client.do(ctx, http.MethodGet, fmt.Sprintf("%s/commits/%s", repoPath, url.PathEscape(event.HeadCommitSHA)), nil, &commit); err != nil {
+			return fmt.Errorf("Error reading commit %s: %w", event.HeadCommitSHA, err)
+		}
+		event.HeadCommitMessage = commit.Message
+
+		query := url.Values{"until": {event.HeadCommitSHA}}
+		if previous := previousServerHead(event); previous != "" {
+			query.Set("since", previous)
+		}
+		return s.readChanges(ctx, event, fmt.Sprintf("%s/changes", repoPath), query)
+
+	case "pull_request":
+		return s.readChanges(ctx, event, fmt.Sprintf("%s/pull-requests/%d/changes", repoPath, event.PullRequestNumber), url.Values{})
+	}
+	return nil
+}
+
+// readChanges fills the event's changes with the files listed by all pages of
+// the supplied changes.
+func (s *serverEventCompleter) readChanges(ctx context.Context, event *github.Event, path string, query url.Values) error {
+	changes := newChangeSet()
+	query.Set("limit", fmt.Sprint(serverChangesPageSize))
+
+	for start := 0; ; {
+		query.Set("start", fmt.Sprint(start))
+
+		var page serverChanges
+		if err := s.client.do(ctx, http.MethodGet, path+"?"+query.Encode(), nil, &page); err != nil {
+			return fmt.Errorf("Error reading changed files: %w", err)
+		}
+
+		for _, value := range page.Values {
+			changes.add(value.Path.ToString)
+			if value.SrcPath != nil {
+				changes.add(value.SrcPath.ToString)
+			}
+		}
+
+		if page.IsLastPage || page.NextPageStart <= start {
+			break
+		}
+		start = page.NextPageStart
+	}
+
+	event.Changes = changes.files
+	return nil
+}
+
+// NewServerEventCompleter returns a new EventCompleter for events delivered by
+// Bitbucket Server.
+func NewServerEventCompleter(client *Client) github.EventCompleter {
+	return &serverEventCompleter{client: client}
+}
@@ -0,0 +1,210 @@
+package bitbucket
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	"github.com/nubank/workflows/pkg/github"
+)
+
+func TestServerHost(t *testing.T) {
+	client, err := NewServerClient("https://bitbucket.example.com/", "", fakeToken, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if got := client.Host().SSHCloneURL("PROJ", "my-repo"); got != "ssh://git@bitbucket.example.com:7999/PROJ/my-repo.git" {
+		t.Errorf("Unexpected SSH clone URL %s", got)
+	}
+
+	if got := client.Host().HTTPSCloneURL("PROJ", "my-repo"); got != "https://bitbucket.example.com/scm/PROJ/my-repo.git" {
+		t.Errorf("Unexpected clone URL %s", got)
+	}
+
+	if _, err := NewServerClient("bitbucket.example.com", "", fakeToken, nil); err == nil {
+		t.Error("Want an error for relative URLs")
+	}
+}
+
+func TestServerReconcileRepos(t *testing.T) {
+	fake, client := newFakeBitbucketServer(t)
+	fake.respond(http.MethodGet, "api/1.0/projects/my-workspace/repos/my-repo", http.StatusOK, map[string]interface{}{"public": false})
+	fake.respond(http.MethodGet, "api/1.0/projects/my-workspace/repos/my-repo/branches/default", http.StatusOK, map[string]string{"displayId": "main"})
+	// Empty repositories have no default branch.
+	fake.respond(http.MethodGet, "api/1.0/projects/my-workspace/repos/my-lib", http.StatusOK, map[string]interface{}{"public": true})
+
+	workflow := newTestWorkflow(workflowsv1alpha1.BitbucketServerProvider)
+	if err := NewServerRepoReconciler(client).ReconcileRepos(newTestContext(), workflow); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if repo := workflow.Spec.Repository; repo.DefaultBranch != "main" || !repo.Private {
+		t.Errorf("Want private repository with default branch main, got %+v", repo)
+	}
+
+	if repo := workflow.Spec.AdditionalRepositories[0]; repo.DefaultBranch != "" || repo.Private {
+		t.Errorf("Want public repository without default branch, got %+v", repo)
+	}
+}
+
+func TestServerReconcileHook(t *testing.T) {
+	fake, client := newFakeBitbucketServer(t)
+	fake.respond(http.MethodPost, "api/1.0/projects/my-workspace/repos/my-repo/webhooks", http.StatusCreated, map[string]interface{}{"id": 3})
+
+	ctx := newTestContext()
+	workflow := newTestWorkflow(workflowsv1alpha1.BitbucketServerProvider)
+	reconciler := NewServerWebhookReconciler(client)
+
+	created, err := reconciler.ReconcileHook(ctx, workflow)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if *workflow.GetWebhookID() != 3 {
+		t.Fatalf("Want hook #3 to be recorded, got %+v", workflow.Status.Annotations)
+	}
+
+	posted := fake.requestsTo(http.MethodPost)[0].body
+	if configuration := posted["configuration"].(map[string]interface{}); configuration["secret"] != string(created.Secret) || posted["url"] != workflow.GetHooksURL() {
+		t.Errorf("Unexpected hook %+v", posted)
+	}
+
+	// Hooks are updated when they're disabled, rotating their secret.
+	fake.respond(http.MethodGet, "api/1.0/projects/my-workspace/repos/my-repo/webhooks/3", http.StatusOK, map[string]interface{}{
+		"id":     3,
+		"url":    workflow.GetHooksURL(),
+		"active": false,
+		"events": posted["events"],
+	})
+	fake.respond(http.MethodPut, "api/1.0/projects/my-workspace/repos/my-repo/webhooks/3", http.StatusOK, nil)
+	updated, err := reconciler.ReconcileHook(ctx, workflow)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	put := fake.requestsTo(http.MethodPut)[0].body
+	if configuration := put["configuration"].(map[string]interface{}); len(updated.Secret) == 0 || configuration["secret"] != string(updated.Secret) || put["active"] != true {
+		t.Errorf("Want the hook to be enabled with a new secret, got %+v", put)
+	}
+}
+
+func TestServerReconcileKeys(t *testing.T) {
+	fake, client := newFakeBitbucketServer(t)
+	fake.respond(http.MethodPost, "keys/1.0/projects/my-workspace/repos/my-repo/ssh", http.StatusCreated, map[string]interface{}{
+		"key":        map[string]interface{}{"id": 21},
+		"permission": "REPO_WRITE",
+	})
+
+	ctx := newTestContext()
+	workflow := newTestWorkflow(workflowsv1alpha1.BitbucketServerProvider)
+	repo := workflow.Spec.Repository
+	repo.DeployKey = &workflowsv1alpha1.DeployKey{ReadOnly: false}
+	reconciler := NewServerDeployKeysReconciler(client)
+
+	keyPairs, err := reconciler.ReconcileKeys(ctx, workflow)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	posted := fake.requestsTo(http.MethodPost)[0].body
+	if len(keyPairs) != 1 || *workflow.GetDeployKeyID(repo) != 21 || posted["permission"] != "REPO_WRITE" {
+		t.Fatalf("Want a writable access key to be created and recorded, got %+v", posted)
+	}
+
+	// Up to date keys are left alone.
+	fake.respond(http.MethodGet, "keys/1.0/projects/my-workspace/repos/my-repo/ssh/21", http.StatusOK, map[string]interface{}{
+		"key":        map[string]interface{}{"id": 21},
+		"permission": "REPO_WRITE",
+	})
+	if keyPairs, err := reconciler.ReconcileKeys(ctx, workflow); err != nil || len(keyPairs) != 0 {
+		t.Errorf("Want no changes, got %d key pairs and error %v", len(keyPairs), err)
+	}
+
+	// Keys are rotated when their permissions change, as long as they're
+	// still needed.
+	workflow.Spec.Repository.Private = true
+	repo.DeployKey.ReadOnly = true
+	fake.respond(http.MethodDelete, "keys/1.0/projects/my-workspace/repos/my-repo/ssh/21", http.StatusNoContent, nil)
+	fake.respond(http.MethodPost, "keys/1.0/projects/my-workspace/repos/my-repo/ssh", http.StatusCreated, map[string]interface{}{
+		"key":        map[string]interface{}{"id": 22},
+		"permission": "REPO_READ",
+	})
+	if keyPairs, err := reconciler.ReconcileKeys(ctx, workflow); err != nil || len(keyPairs) != 1 {
+		t.Fatalf("Want the key to be rotated, got %d key pairs and error %v", len(keyPairs), err)
+	}
+
+	if posted := fake.requestsTo(http.MethodPost)[1].body; *workflow.GetDeployKeyID(repo) != 22 || posted["permission"] != "REPO_READ" || len(fake.requestsTo(http.MethodDelete)) != 1 {
+		t.Errorf("Want a read-only access key to replace #21, got %+v", posted)
+	}
+}
+
+func TestServerGetWorkflowContent(t *testing.T) {
+	fake, client := newFakeBitbucketServer(t)
+	fake.respond(http.MethodGet, "api/1.0/projects/my-workspace/repos/my-repo/raw/.workflows/test-1.yaml?at=refs%2Fheads%2Fmain", http.StatusOK, `spec:
+  events:
+  - push
+  tasks:
+    test:
+      steps:
+      - run: make test
+`)
+
+	ctx := newTestContext()
+	workflow := newTestWorkflow(workflowsv1alpha1.BitbucketServerProvider)
+	reader := NewServerWorkflowReader(client)
+
+	got, err := reader.GetWorkflowContent(ctx, workflow, ".workflows/test-1.yaml", "refs/heads/main")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if got.Spec.Repository.String() != "my-workspace/my-repo" || got.Spec.Tasks["test"] == nil {
+		t.Errorf("Unexpected workflow %+v", got.Spec)
+	}
+
+	if _, err := reader.GetWorkflowContent(ctx, workflow, ".workflows/test-1.yaml", "a1b2c3"); !github.IsNotFound(err) {
+		t.Errorf("Want a not found error, got %v", err)
+	}
+}
+
+func TestServerCompleteEvent(t *testing.T) {
+	fake, client := newFakeBitbucketServer(t)
+	head := "da1560886d4f094c3e6c9ef40349f7d38b5d27d7"
+	previous := "95790bf891e76fee5e1747ab589903a6a1f80f22"
+
+	fake.respond(http.MethodGet, "api/1.0/projects/my-workspace/repos/my-repo/commits/"+head, http.StatusOK, map[string]string{"message": "Fix tests"})
+	fake.respond(http.MethodGet, "api/1.0/projects/my-workspace/repos/my-repo/changes?limit=500&since="+previous+"&start=0&until="+head, http.StatusOK, map[string]interface{}{
+		"values": []interface{}{
+			map[string]interface{}{"path": map[string]string{"toString": "README.md"}},
+			map[string]interface{}{"path": map[string]string{"toString": "docs/guide.md"}, "srcPath": map[string]string{"toString": "guide.md"}},
+		},
+		"isLastPage":    false,
+		"nextPageStart": 2,
+	})
+	fake.respond(http.MethodGet, "api/1.0/projects/my-workspace/repos/my-repo/changes?limit=500&since="+previous+"&start=2&until="+head, http.StatusOK, map[string]interface{}{
+		"values": []interface{}{
+			map[string]interface{}{"path": map[string]string{"toString": "README.md"}},
+		},
+		"isLastPage": true,
+	})
+
+	event := &github.Event{Name: "push",
+		HeadCommitSHA: head,
+		Body:          []byte(`{"changes": [{"fromHash": "` + previous + `", "toHash": "` + head + `"}]}`),
+	}
+
+	workflow := newTestWorkflow(workflowsv1alpha1.BitbucketServerProvider)
+	if err := NewServerEventCompleter(client).CompleteEvent(newTestContext(), workflow, event); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if event.HeadCommitMessage != "Fix tests" {
+		t.Errorf("Want head commit message Fix tests, got %q", event.HeadCommitMessage)
+	}
+
+	if diff := cmp.Diff([]string{"README.md", "docs/guide.md", "guide.md"}, event.Changes); diff != "" {
+		t.Errorf("Changes mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,68 @@
+package chatops
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Slash commands that can be issued in pull request comments.
+const (
+	// Retest runs workflows listening to pull requests again against the
+	// pull request's current head commit.
+	Retest = "retest"
+
+	// Run runs the workflow whose name is given against the pull request's
+	// current head commit.
+	Run = "run"
+
+	// Cancel cancels running workflows triggered for the pull request's
+	// current head commit.
+	Cancel = "cancel"
+)
+
+// Command is a slash command issued in a pull request comment.
+type Command struct {
+	Name string
+	Args []string
+}
+
+// String returns the command as it was typed.
+func (c *Command) String() string {
+	return strings.Join(append([]string{"/" + c.Name}, c.Args...), " ")
+}
+
+// Parse returns the first well-formed command found at the beginning of a line
+// of the supplied comment and true or nil and false if the comment contains
+// no commands.
+func Parse(comment string) (*Command, bool) {
+	for _, line := range strings.Split(comment, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") {
+			continue
+		}
+
+		command := &Command{Name: strings.TrimPrefix(fields[0], "/"), Args: fields[1:]}
+		if err := command.validate(); err == nil {
+			return command, true
+		}
+	}
+	return nil, false
+}
+
+// validate returns an error if the command is unknown or has an unexpected
+// number of arguments.
+func (c *Command) validate() error {
+	switch c.Name {
+	case Retest, Cancel:
+		if len(c.Args) != 0 {
+			return fmt.Errorf("/%s takes no arguments", c.Name)
+		}
+	case Run:
+		if len(c.Args) != 1 {
+			return fmt.Errorf("/%s takes the name of a workflow", c.Name)
+		}
+	default:
+		return fmt.Errorf("unknown command /%s", c.Name)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package chatops
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		comment string
+		want    *Command
+	}{
+		{"/retest", &Command{Name: Retest, Args: []string{}}},
+		{"  /cancel  ", &Command{Name: Cancel, Args: []string{}}},
+		{"/run ci", &Command{Name: Run, Args: []string{"ci"}}},
+		{"LGTM, but the build is flaky.\r\n/retest\r\n", &Command{Name: Retest, Args: []string{}}},
+		{"/unknown\n/run ci", &Command{Name: Run, Args: []string{"ci"}}},
+		{"/run", nil},
+		{"/run ci lint", nil},
+		{"/retest please", nil},
+		{"Please /retest", nil},
+		{"", nil},
+	}
+
+	for _, test := range tests {
+		got, ok := Parse(test.comment)
+		if ok != (test.want != nil) {
+			t.Errorf("Comment %q: want ok to be %t, got %t", test.comment, test.want != nil, ok)
+		}
+
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("Comment %q: mismatch (-want +got):\n%s", test.comment, diff)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	command := &Command{Name: Run, Args: []string{"ci"}}
+	if got := command.String(); got != "/run ci" {
+		t.Errorf("Want /run ci, got %s", got)
+	}
+}
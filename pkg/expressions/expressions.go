@@ -0,0 +1,107 @@
+// package expressions compiles and evaluates CEL expressions declared in
+// workflows (see https://github.com/google/cel-spec).
+package expressions
+
+import (
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common/types"
+	lru "github.com/hashicorp/golang-lru"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+)
+
+// Names of the variables available to expressions.
+const (
+	// The full payload of the Github event.
+	BodyVar = "body"
+
+	// A normalized representation of the Github event.
+	EventVar = "event"
+
+	// Metadata about the workflow in question.
+	WorkflowVar = "workflow"
+)
+
+// env is the CEL environment shared by all expressions.
+var env = newEnvOrDie()
+
+// newEnvOrDie returns a new CEL environment declaring the variables available
+// to expressions.
+// It panics if the environment cannot be created.
+func newEnvOrDie() *cel.Env {
+	mapType := decls.NewMapType(decls.String, decls.Dyn)
+	env, err := cel.NewEnv(cel.Declarations(
+		decls.NewVar(BodyVar, mapType),
+		decls.NewVar(EventVar, mapType),
+		decls.NewVar(WorkflowVar, mapType),
+	))
+	if err != nil {
+		panic(fmt.Errorf("Error creating CEL environment: %w", err))
+	}
+	return env
+}
+
+// Compile parses and checks the supplied expression, returning a program
+// ready to be evaluated. It returns an error if the expression is invalid or
+// if it doesn't produce a boolean value.
+func Compile(expression string) (cel.Program, error) {
+	ast, issues := env.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+
+	resultType := ast.ResultType()
+	if resultType.GetPrimitive() != exprpb.Type_BOOL && resultType.GetDyn() == nil {
+		return nil, fmt.Errorf("expected a boolean expression, but got an expression of type %v", resultType)
+	}
+
+	return env.Program(ast)
+}
+
+// Evaluate evaluates the supplied program against the provided variables and
+// returns its boolean result.
+func Evaluate(program cel.Program, vars map[string]interface{}) (bool, error) {
+	out, _, err := program.Eval(vars)
+	if err != nil {
+		return false, err
+	}
+
+	result, ok := out.(types.Bool)
+	if !ok {
+		return false, fmt.Errorf("expected a boolean result, but got %v", out)
+	}
+	return bool(result), nil
+}
+
+// ProgramCache keeps the most recently compiled programs in memory, so that
+// the same expressions aren't parsed and checked over and over again.
+type ProgramCache struct {
+	programs *lru.Cache
+}
+
+// NewProgramCache returns a new ProgramCache holding up to size programs.
+func NewProgramCache(size int) *ProgramCache {
+	programs, err := lru.New(size)
+	if err != nil {
+		panic(fmt.Errorf("Error creating program cache: %w", err))
+	}
+	return &ProgramCache{programs: programs}
+}
+
+// Get returns the program for the supplied expression, compiling it if it
+// isn't cached yet.
+func (p *ProgramCache) Get(expression string) (cel.Program, error) {
+	if program, ok := p.programs.Get(expression); ok {
+		return program.(cel.Program), nil
+	}
+
+	program, err := Compile(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	p.programs.Add(expression, program)
+	return program, nil
+}
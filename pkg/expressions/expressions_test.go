@@ -0,0 +1,107 @@
+package expressions
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompileRejectsInvalidExpressions(t *testing.T) {
+	tests := []struct {
+		expression string
+		wantError  string
+	}{
+		{`event.branch ==`, "Syntax error"},
+		{`pipeline.name == "x"`, "undeclared reference to 'pipeline'"},
+		{`"x" + "y"`, "expected a boolean expression"},
+	}
+
+	for _, test := range tests {
+		_, err := Compile(test.expression)
+		if err == nil {
+			t.Errorf("Want an error compiling %s, but got none", test.expression)
+			continue
+		}
+
+		if !strings.Contains(err.Error(), test.wantError) {
+			t.Errorf("Want error containing %s, but got %s", test.wantError, err)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	vars := map[string]interface{}{
+		BodyVar: map[string]interface{}{
+			"pull_request": map[string]interface{}{
+				"draft": false,
+			},
+		},
+		EventVar: map[string]interface{}{
+			"branch": "feat/new-button",
+		},
+		WorkflowVar: map[string]interface{}{
+			"name": "ci",
+		},
+	}
+
+	tests := []struct {
+		expression string
+		want       bool
+	}{
+		{`!body.pull_request.draft`, true},
+		{`event.branch.startsWith("feat/")`, true},
+		{`event.branch.startsWith("fix/")`, false},
+		{`workflow.name == "ci" && event.branch != "main"`, true},
+	}
+
+	for _, test := range tests {
+		program, err := Compile(test.expression)
+		if err != nil {
+			t.Fatalf("Unexpected error compiling %s: %v", test.expression, err)
+		}
+
+		got, err := Evaluate(program, vars)
+		if err != nil {
+			t.Fatalf("Unexpected error evaluating %s: %v", test.expression, err)
+		}
+
+		if test.want != got {
+			t.Errorf("%s: want %t, but got %t", test.expression, test.want, got)
+		}
+	}
+}
+
+func TestEvaluateReturnsAnErrorForNonBooleanResults(t *testing.T) {
+	program, err := Compile(`body.action`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	_, err = Evaluate(program, map[string]interface{}{
+		BodyVar: map[string]interface{}{"action": "opened"},
+	})
+	if err == nil {
+		t.Error("Want an error, but the evaluation succeeded unexpectedly")
+	}
+}
+
+func TestProgramCache(t *testing.T) {
+	cache := NewProgramCache(1)
+
+	program1, err := cache.Get(`event.branch == "main"`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	program2, err := cache.Get(`event.branch == "main"`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if program1 != program2 {
+		t.Error("Want the cached program to be returned, but got a new one")
+	}
+
+	if _, err := cache.Get(`event.branch ==`); err == nil {
+		t.Error("Want an error, but got a compiled program")
+	}
+}
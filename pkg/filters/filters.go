@@ -2,10 +2,15 @@
 package filters
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/gobwas/glob"
+	"github.com/nubank/workflows/pkg/apis/config"
 	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	"github.com/nubank/workflows/pkg/expressions"
 	"github.com/nubank/workflows/pkg/github"
 )
 
@@ -17,101 +22,461 @@ const (
 
 	noConfiguredBranches = "skipped because there are no configured branches"
 
+	noConfiguredBaseBranches = "skipped because there are no configured base branches"
+
 	noConfiguredPaths = "skipped because there are no configured paths"
+
+	skipDirectivesIgnored = "skipped because the workflow ignores skip directives"
+
+	noConfiguredActors = "skipped because there are no configured actors"
+
+	noConfiguredExpression = "skipped because there is no configured expression"
+
+	previousFilterFailed = "skipped because a previous filter failed"
+)
+
+// programCacheSize is the maximum number of compiled CEL programs kept in
+// memory.
+const programCacheSize = 256
+
+// builtInSkipDirectives are markers that are always honored, regardless of the
+// skip directives declared in the default configuration.
+var builtInSkipDirectives = []string{"[skip ci]", "[ci skip]"}
+
+// programs caches CEL programs compiled from workflows' when expressions.
+var programs = expressions.NewProgramCache(programCacheSize)
+
+// Status represents the outcome of a filter.
+type Status string
+
+// Possible outcomes of a filter.
+const (
+	// The event satisfies the filter.
+	Passed Status = "passed"
+
+	// The event doesn't satisfy the filter.
+	Failed Status = "failed"
+
+	// The filter doesn't apply to the event or workflow in question.
+	Skipped Status = "skipped"
 )
 
 // Filter is a function that takes a workflow and a Github event and returns a
-// boolean value indicating whether the event satisfies filters declared in the
+// status indicating whether the event satisfies filters declared in the
 // workflow along with a message explaining the result.
-type Filter func(*workflowsv1alpha1.Workflow, *github.Event) (bool, string)
+type Filter func(context.Context, *workflowsv1alpha1.Workflow, *github.Event) (Status, string)
+
+// namedFilter associates a Filter to a name displayed in reports.
+type namedFilter struct {
+	name   string
+	filter Filter
+}
+
+// Result is the outcome of a single filter.
+type Result struct {
+	Filter  string `json:"filter"`
+	Status  Status `json:"status"`
+	Details string `json:"details"`
+}
+
+// Report contains the outcome of every filter evaluated against a Github
+// event.
+type Report struct {
+	Results []Result `json:"results"`
+}
+
+// Accepted returns true if no filter has failed or false otherwise.
+func (r *Report) Accepted() bool {
+	return len(r.failures()) == 0
+}
+
+// failures returns results of filters that have failed.
+func (r *Report) failures() []Result {
+	failures := make([]Result, 0)
+	for _, result := range r.Results {
+		if result.Status == Failed {
+			failures = append(failures, result)
+		}
+	}
+	return failures
+}
+
+// Message returns a human readable message summarizing the report.
+func (r *Report) Message() string {
+	failures := r.failures()
+	switch len(failures) {
+	case 0:
+		return workflowAccepted
+
+	case 1:
+		return fmt.Sprintf("Workflow was rejected because Github event doesn't satisfy rule: %s", failures[0].Details)
+
+	default:
+		details := make([]string, 0, len(failures))
+		for _, failure := range failures {
+			details = append(details, failure.Details)
+		}
+		return fmt.Sprintf("Workflow was rejected because Github event doesn't satisfy rules: %s", strings.Join(details, "; "))
+	}
+}
+
+// String satisfies fmt.Stringer interface.
+func (r *Report) String() string {
+	lines := make([]string, 0, len(r.Results))
+	for _, result := range r.Results {
+		lines = append(lines, fmt.Sprintf("%s: %s (%s)", result.Filter, result.Status, result.Details))
+	}
+	return strings.Join(lines, "\n")
+}
 
 // events verifies whether events configured in the workflow match the name of
 // the incoming Github event.
-func events(workflow *workflowsv1alpha1.Workflow, event *github.Event) (bool, string) {
+func events(ctx context.Context, workflow *workflowsv1alpha1.Workflow, event *github.Event) (Status, string) {
 	for _, eventName := range workflow.Spec.Events {
 		if eventName == event.Name {
-			return true, filterSucceeded
+			return Passed, filterSucceeded
 		}
 	}
-	return false, fmt.Sprintf("%s event doesn't match filters %+v", event.Name, workflow.Spec.Events)
+	return Failed, fmt.Sprintf("%s event doesn't match filters %+v", event.Name, workflow.Spec.Events)
 }
 
 // repository verifies whether the repository associated to the workflow matches
-// the repository that originated the Github event.
-func repository(workflow *workflowsv1alpha1.Workflow, event *github.Event) (bool, string) {
-	if event.Repository == workflow.Spec.Repository.String() {
-		return true, filterSucceeded
+// the repository that originated the Github event. Github names are
+// case-insensitive.
+func repository(ctx context.Context, workflow *workflowsv1alpha1.Workflow, event *github.Event) (Status, string) {
+	if strings.EqualFold(event.Repository, workflow.Spec.Repository.String()) {
+		return Passed, filterSucceeded
 	}
-	return false, fmt.Sprintf("repository %s doesn't match workflow's repository %s", event.Repository, workflow.Spec.Repository)
+	return Failed, fmt.Sprintf("repository %s doesn't match workflow's repository %s", event.Repository, workflow.Spec.Repository)
 }
 
 // branches verifies whether branches configured in the workflow match the
 // branch present in the Github event. This filter is only applied on push and
 // pull_request events.
-func branches(workflow *workflowsv1alpha1.Workflow, event *github.Event) (bool, string) {
+func branches(ctx context.Context, workflow *workflowsv1alpha1.Workflow, event *github.Event) (Status, string) {
 	if len(workflow.Spec.Branches) == 0 {
-		return true, noConfiguredBranches
+		return Skipped, noConfiguredBranches
 	}
 
 	if event.Name != "push" && event.Name != "pull_request" {
-		return true, fmt.Sprintf("skipped because %s event isn't supported", event.Name)
+		return Skipped, fmt.Sprintf("skipped because %s event isn't supported", event.Name)
 	}
 
 	for _, branch := range workflow.Spec.Branches {
 		globPattern, err := glob.Compile(branch)
 		if err != nil {
-			return false, err.Error()
+			return Failed, err.Error()
 		}
 
 		if globPattern.Match(event.Branch) {
-			return true, filterSucceeded
+			return Passed, filterSucceeded
+		}
+	}
+	return Failed, fmt.Sprintf("branch %s doesn't match filters %+v", event.Branch, workflow.Spec.Branches)
+}
+
+// baseBranches verifies whether base branches configured in the workflow match
+// the branch targeted by the pull request present in the Github event. This
+// filter is only applied on pull_request events.
+func baseBranches(ctx context.Context, workflow *workflowsv1alpha1.Workflow, event *github.Event) (Status, string) {
+	if len(workflow.Spec.BaseBranches) == 0 {
+		return Skipped, noConfiguredBaseBranches
+	}
+
+	if event.Name != "pull_request" {
+		return Skipped, fmt.Sprintf("skipped because %s event isn't supported", event.Name)
+	}
+
+	for _, branch := range workflow.Spec.BaseBranches {
+		globPattern, err := glob.Compile(branch)
+		if err != nil {
+			return Failed, err.Error()
+		}
+
+		if globPattern.Match(event.BaseBranch) {
+			return Passed, filterSucceeded
 		}
 	}
-	return false, fmt.Sprintf("branch %s doesn't match filters %+v", event.Branch, workflow.Spec.Branches)
+	return Failed, fmt.Sprintf("base branch %s doesn't match filters %+v", event.BaseBranch, workflow.Spec.BaseBranches)
 }
 
 // paths verifies whether paths configured in the workflow match modified files
 // present in the Github event. This filter is only applied on push and
 // pull_request events.
-func paths(workflow *workflowsv1alpha1.Workflow, event *github.Event) (bool, string) {
+func paths(ctx context.Context, workflow *workflowsv1alpha1.Workflow, event *github.Event) (Status, string) {
 	if event.Name != "push" && event.Name != "pull_request" {
-		return true, fmt.Sprintf("skipped because %s event isn't supported", event.Name)
+		return Skipped, fmt.Sprintf("skipped because %s event isn't supported", event.Name)
 	}
 
 	if len(workflow.Spec.Paths) == 0 {
-		return true, noConfiguredPaths
+		return Skipped, noConfiguredPaths
 	}
 
 	for _, path := range workflow.Spec.Paths {
 		globPattern, err := glob.Compile(path)
 		if err != nil {
-			return false, err.Error()
+			return Failed, err.Error()
 		}
 
 		for _, file := range event.Changes {
 			if globPattern.Match(file) {
-				return true, filterSucceeded
+				return Passed, filterSucceeded
+			}
+		}
+	}
+	return Failed, fmt.Sprintf("modified files don't match filters %+v", workflow.Spec.Paths)
+}
+
+// skipDirectives verifies whether the head commit message (on push events) or
+// the pull request title (on pull_request events) contains one of the known
+// skip directives, such as [skip ci]. Workflows can opt out of this filter by
+// setting ignoreSkipDirectives.
+func skipDirectives(ctx context.Context, workflow *workflowsv1alpha1.Workflow, event *github.Event) (Status, string) {
+	if workflow.Spec.IgnoreSkipDirectives {
+		return Skipped, skipDirectivesIgnored
+	}
+
+	var source, text string
+	switch event.Name {
+	case "push":
+		source, text = "head commit message", event.HeadCommitMessage
+
+	case "pull_request":
+		source, text = "pull request title", event.PullRequestTitle
+
+	default:
+		return Skipped, fmt.Sprintf("skipped because %s event isn't supported", event.Name)
+	}
+
+	text = strings.ToLower(text)
+	for _, directive := range getSkipDirectives(ctx) {
+		if strings.Contains(text, strings.ToLower(directive)) {
+			return Failed, fmt.Sprintf("%s contains the skip directive %s", source, directive)
+		}
+	}
+	return Passed, filterSucceeded
+}
+
+// getSkipDirectives returns the built-in skip directives along with those
+// declared in the default configuration.
+func getSkipDirectives(ctx context.Context) []string {
+	directives := append([]string{}, builtInSkipDirectives...)
+	if cfg := config.Get(ctx); cfg != nil && cfg.Defaults != nil {
+		directives = append(directives, cfg.Defaults.SkipDirectives...)
+	}
+	return directives
+}
+
+// actors verifies whether the user that triggered the Github event (i.e. the
+// event sender) is allowed to trigger the workflow. Memberships in
+// organizations and teams are checked through Github APIs.
+func actors(ctx context.Context, workflow *workflowsv1alpha1.Workflow, event *github.Event) (Status, string) {
+	actors := workflow.Spec.Actors
+	if actors == nil {
+		return Skipped, noConfiguredActors
+	}
+
+	sender := event.Sender
+
+	if contains(actors.Deny, sender) {
+		return Failed, fmt.Sprintf("actor %s is denied", sender)
+	}
+
+	if contains(actors.Allow, sender) {
+		return Passed, filterSucceeded
+	}
+
+	if actors.DenyBots && event.IsBot() {
+		return Failed, fmt.Sprintf("actor %s is a bot account and bots are denied", sender)
+	}
+
+	if !actors.HasAllowList() {
+		return Passed, filterSucceeded
+	}
+
+	if len(actors.Orgs) != 0 || len(actors.Teams) != 0 {
+		if sender == "" {
+			return Failed, "the actor that triggered the event is unknown"
+		}
+
+		checker := github.GetMembershipChecker(ctx)
+		if checker == nil {
+			return Failed, fmt.Sprintf("unable to verify memberships of actor %s", sender)
+		}
+
+		for _, org := range actors.Orgs {
+			member, err := checker.IsOrgMember(ctx, org, sender)
+			if err != nil {
+				return Failed, err.Error()
+			}
+
+			if member {
+				return Passed, filterSucceeded
 			}
 		}
+
+		for _, team := range actors.Teams {
+			parts := strings.SplitN(team, "/", 2)
+			if len(parts) != 2 {
+				return Failed, fmt.Sprintf("invalid team %s: expected the format org/team-slug", team)
+			}
+
+			member, err := checker.IsTeamMember(ctx, parts[0], parts[1], sender)
+			if err != nil {
+				return Failed, err.Error()
+			}
+
+			if member {
+				return Passed, filterSucceeded
+			}
+		}
+	}
+
+	return Failed, fmt.Sprintf("actor %s isn't allowed to trigger the workflow", sender)
+}
+
+// contains returns true if the supplied slice contains the value in question or
+// false otherwise. Values are compared case-insensitively, as Github logins are.
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// when evaluates the CEL expression declared in the workflow against the Github
+// event. The workflow is accepted only if the expression evaluates to true.
+func when(ctx context.Context, workflow *workflowsv1alpha1.Workflow, event *github.Event) (Status, string) {
+	expression := workflow.Spec.When
+	if expression == "" {
+		return Skipped, noConfiguredExpression
+	}
+
+	program, err := programs.Get(expression)
+	if err != nil {
+		return Failed, fmt.Sprintf("invalid expression %s: %v", expression, err)
+	}
+
+	body := make(map[string]interface{})
+	if len(event.Body) != 0 {
+		if err := json.Unmarshal(event.Body, &body); err != nil {
+			return Failed, fmt.Sprintf("unable to decode the event payload: %v", err)
+		}
+	}
+
+	result, err := expressions.Evaluate(program, map[string]interface{}{
+		expressions.BodyVar:     body,
+		expressions.EventVar:    eventVars(event),
+		expressions.WorkflowVar: workflowVars(workflow),
+	})
+	if err != nil {
+		return Failed, fmt.Sprintf("error evaluating expression %s: %v", expression, err)
+	}
+
+	if !result {
+		return Failed, fmt.Sprintf("expression %s evaluated to false", expression)
 	}
-	return false, fmt.Sprintf("modified files don't match filters %+v", workflow.Spec.Paths)
+	return Passed, filterSucceeded
+}
+
+// eventVars returns a normalized representation of the Github event exposed to
+// CEL expressions.
+func eventVars(event *github.Event) map[string]interface{} {
+	changes := event.Changes
+	if changes == nil {
+		changes = []string{}
+	}
+
+	return map[string]interface{}{
+		"name":              event.Name,
+		"deliveryID":        event.DeliveryID,
+		"repository":        event.Repository,
+		"branch":            event.Branch,
+		"baseBranch":        event.BaseBranch,
+		"baseCommitSHA":     event.BaseCommitSHA,
+		"headCommitSHA":     event.HeadCommitSHA,
+		"headCommitMessage": event.HeadCommitMessage,
+		"pullRequestTitle":  event.PullRequestTitle,
+		"sender":            event.Sender,
+		"senderType":        event.SenderType,
+		"changes":           changes,
+	}
+}
+
+// workflowVars returns metadata about the workflow exposed to CEL expressions.
+func workflowVars(workflow *workflowsv1alpha1.Workflow) map[string]interface{} {
+	labels := workflow.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+
+	annotations := workflow.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+
+	repo := workflow.Spec.Repository
+	vars := map[string]interface{}{
+		"name":        workflow.GetName(),
+		"namespace":   workflow.GetNamespace(),
+		"labels":      labels,
+		"annotations": annotations,
+		"repository":  map[string]interface{}{},
+	}
+
+	if repo != nil {
+		vars["repository"] = map[string]interface{}{
+			"owner":         repo.Owner,
+			"name":          repo.Name,
+			"defaultBranch": repo.DefaultBranch,
+		}
+	}
+	return vars
 }
 
 // filters is a chain of filter funcs.
-var filters = []Filter{events,
-	repository,
-	branches,
-	paths,
+var filters = []namedFilter{{"events", events},
+	{"repository", repository},
+	{"actors", actors},
+	{"skip-directives", skipDirectives},
+	{"branches", branches},
+	{"base-branches", baseBranches},
+	{"paths", paths},
+	{"when", when},
 }
 
 // CanTrigger verifies all filtering rules declared in the workflow by comparing
 // them against the supplied Github event.
-// Returns true if the workflow is eligible to be triggered or false otherwise.
-func CanTrigger(workflow *workflowsv1alpha1.Workflow, event *github.Event) (bool, string) {
-	for _, filter := range filters {
-		if ok, message := filter(workflow, event); !ok {
-			return false, fmt.Sprintf("Workflow was rejected because Github event doesn't satisfy rule: %s", message)
+// Returns a report containing the outcome of every filter. The workflow is
+// eligible to be triggered if the report is accepted. Once a filter fails, the
+// remaining ones are reported as skipped without being evaluated, since some
+// of them call Github APIs or evaluate expressions.
+func CanTrigger(ctx context.Context, workflow *workflowsv1alpha1.Workflow, event *github.Event) *Report {
+	report := &Report{Results: make([]Result, 0, len(filters))}
+	failed := false
+	for _, f := range filters {
+		status, details := Skipped, previousFilterFailed
+		if !failed {
+			status, details = f.filter(ctx, workflow, event)
+			failed = status == Failed
 		}
+
+		report.Results = append(report.Results, Result{Filter: f.name,
+			Status:  status,
+			Details: details,
+		})
 	}
-	return true, workflowAccepted
+	return report
+}
+
+// CanAct verifies whether the user that triggered the Github event is allowed
+// to act on the workflow according to its actors filter. It lets events that
+// bypass the remaining filters, like slash commands, honor the actors allowed
+// and denied by workflows.
+// Returns false along with the reason if the user isn't allowed.
+func CanAct(ctx context.Context, workflow *workflowsv1alpha1.Workflow, event *github.Event) (bool, string) {
+	status, details := actors(ctx, workflow, event)
+	return status != Failed, details
 }
@@ -1,10 +1,18 @@
 package filters
 
 import (
+	"context"
+	"errors"
+	"strings"
 	"testing"
 
+	"github.com/golang/mock/gomock"
+	"github.com/google/go-cmp/cmp"
+	"github.com/nubank/workflows/pkg/apis/config"
 	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
 	"github.com/nubank/workflows/pkg/github"
+	githubmocks "github.com/nubank/workflows/pkg/github/mocks"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestEvents(t *testing.T) {
@@ -17,21 +25,21 @@ func TestEvents(t *testing.T) {
 	tests := []struct {
 		eventName   string
 		wantMessage string
-		wantResult  bool
+		wantStatus  Status
 	}{
-		{"push", filterSucceeded, true},
-		{"pull_request", filterSucceeded, true},
-		{"release", "release event doesn't match filters [push pull_request]", false},
+		{"push", filterSucceeded, Passed},
+		{"pull_request", filterSucceeded, Passed},
+		{"release", "release event doesn't match filters [push pull_request]", Failed},
 	}
 
 	for _, test := range tests {
-		gotResult, gotMessage := events(workflow, &github.Event{Name: test.eventName})
+		gotStatus, gotMessage := events(context.Background(), workflow, &github.Event{Name: test.eventName})
 		if test.wantMessage != gotMessage {
 			t.Errorf("Want message %s, got %s", test.wantMessage, gotMessage)
 		}
 
-		if test.wantResult != gotResult {
-			t.Errorf("Want result %t, got %t", test.wantResult, gotResult)
+		if test.wantStatus != gotStatus {
+			t.Errorf("Want status %s, got %s", test.wantStatus, gotStatus)
 		}
 	}
 }
@@ -49,25 +57,217 @@ func TestRepository(t *testing.T) {
 	tests := []struct {
 		repository  string
 		wantMessage string
-		wantResult  bool
+		wantStatus  Status
 	}{
-		{"my-org/my-repo", filterSucceeded, true},
-		{"my-org/other-repo", "repository my-org/other-repo doesn't match workflow's repository my-org/my-repo", false},
-		{"other-org/my-repo", "repository other-org/my-repo doesn't match workflow's repository my-org/my-repo", false},
+		{"my-org/my-repo", filterSucceeded, Passed},
+		{"My-Org/My-Repo", filterSucceeded, Passed},
+		{"my-org/other-repo", "repository my-org/other-repo doesn't match workflow's repository my-org/my-repo", Failed},
+		{"other-org/my-repo", "repository other-org/my-repo doesn't match workflow's repository my-org/my-repo", Failed},
 	}
 
 	for _, test := range tests {
-		gotResult, gotMessage := repository(workflow, &github.Event{Repository: test.repository})
+		gotStatus, gotMessage := repository(context.Background(), workflow, &github.Event{Repository: test.repository})
 		if test.wantMessage != gotMessage {
 			t.Errorf("Want message %s, got %s", test.wantMessage, gotMessage)
 		}
 
-		if test.wantResult != gotResult {
-			t.Errorf("Want result %t, got %t", test.wantResult, gotResult)
+		if test.wantStatus != gotStatus {
+			t.Errorf("Want status %s, got %s", test.wantStatus, gotStatus)
+		}
+	}
+}
+
+func TestActors(t *testing.T) {
+	workflow := &workflowsv1alpha1.Workflow{
+		Spec: workflowsv1alpha1.WorkflowSpec{
+			Actors: &workflowsv1alpha1.Actors{
+				Allow:    []string{"jane-doe", "dependabot[bot]"},
+				Deny:     []string{"mallory"},
+				DenyBots: true,
+			},
+		},
+	}
+
+	tests := []struct {
+		sender      string
+		senderType  string
+		wantMessage string
+		wantStatus  Status
+	}{
+		{"jane-doe", "User", filterSucceeded, Passed},
+		{"dependabot[bot]", "Bot", filterSucceeded, Passed},
+		{"mallory", "User", "actor mallory is denied", Failed},
+		{"Mallory", "User", "actor Mallory is denied", Failed},
+		{"Jane-Doe", "User", filterSucceeded, Passed},
+		{"Dependabot[bot]", "Bot", filterSucceeded, Passed},
+		{"renovate[bot]", "Bot", "actor renovate[bot] is a bot account and bots are denied", Failed},
+		{"john-doe", "User", "actor john-doe isn't allowed to trigger the workflow", Failed},
+	}
+
+	for _, test := range tests {
+		gotStatus, gotMessage := actors(context.Background(), workflow, &github.Event{Sender: test.sender, SenderType: test.senderType})
+		if test.wantMessage != gotMessage {
+			t.Errorf("Want message %s, got %s", test.wantMessage, gotMessage)
+		}
+
+		if test.wantStatus != gotStatus {
+			t.Errorf("Want status %s, got %s", test.wantStatus, gotStatus)
+		}
+	}
+}
+
+func TestActorsWithoutAllowList(t *testing.T) {
+	workflow := &workflowsv1alpha1.Workflow{
+		Spec: workflowsv1alpha1.WorkflowSpec{
+			Actors: &workflowsv1alpha1.Actors{
+				DenyBots: true,
+			},
+		},
+	}
+
+	tests := []struct {
+		sender      string
+		senderType  string
+		wantMessage string
+		wantStatus  Status
+	}{
+		{"john-doe", "User", filterSucceeded, Passed},
+		{"dependabot[bot]", "Bot", "actor dependabot[bot] is a bot account and bots are denied", Failed},
+	}
+
+	for _, test := range tests {
+		gotStatus, gotMessage := actors(context.Background(), workflow, &github.Event{Sender: test.sender, SenderType: test.senderType})
+		if test.wantMessage != gotMessage {
+			t.Errorf("Want message %s, got %s", test.wantMessage, gotMessage)
+		}
+
+		if test.wantStatus != gotStatus {
+			t.Errorf("Want status %s, got %s", test.wantStatus, gotStatus)
 		}
 	}
 }
 
+func TestActorsWithMemberships(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	checker := githubmocks.NewMockMembershipChecker(mockCtrl)
+
+	workflow := &workflowsv1alpha1.Workflow{
+		Spec: workflowsv1alpha1.WorkflowSpec{
+			Actors: &workflowsv1alpha1.Actors{
+				Orgs:  []string{"my-org"},
+				Teams: []string{"other-org/maintainers"},
+			},
+		},
+	}
+
+	ctx := github.WithMembershipChecker(context.Background(), checker)
+
+	// Mock setup
+	checker.EXPECT().IsOrgMember(ctx, "my-org", "john-doe").Return(true, nil)
+	checker.EXPECT().IsOrgMember(ctx, "my-org", "jane-doe").Return(false, nil)
+	checker.EXPECT().IsTeamMember(ctx, "other-org", "maintainers", "jane-doe").Return(true, nil)
+	checker.EXPECT().IsOrgMember(ctx, "my-org", "mallory").Return(false, nil)
+	checker.EXPECT().IsTeamMember(ctx, "other-org", "maintainers", "mallory").Return(false, nil)
+	checker.EXPECT().IsOrgMember(ctx, "my-org", "ghost").Return(false, errors.New("Boom!"))
+
+	tests := []struct {
+		sender      string
+		wantMessage string
+		wantStatus  Status
+	}{
+		{"john-doe", filterSucceeded, Passed},
+		{"jane-doe", filterSucceeded, Passed},
+		{"mallory", "actor mallory isn't allowed to trigger the workflow", Failed},
+		{"ghost", "Boom!", Failed},
+		{"", "the actor that triggered the event is unknown", Failed},
+	}
+
+	for _, test := range tests {
+		gotStatus, gotMessage := actors(ctx, workflow, &github.Event{Sender: test.sender})
+		if test.wantMessage != gotMessage {
+			t.Errorf("Want message %s, got %s", test.wantMessage, gotMessage)
+		}
+
+		if test.wantStatus != gotStatus {
+			t.Errorf("Want status %s, got %s", test.wantStatus, gotStatus)
+		}
+	}
+}
+
+func TestWithNoConfiguredActors(t *testing.T) {
+	workflow := &workflowsv1alpha1.Workflow{
+		Spec: workflowsv1alpha1.WorkflowSpec{},
+	}
+
+	wantStatus, wantMessage := Skipped, noConfiguredActors
+	gotStatus, gotMessage := actors(context.Background(), workflow, &github.Event{Sender: "john-doe"})
+
+	if wantStatus != gotStatus {
+		t.Errorf("Want status %s, got %s", wantStatus, gotStatus)
+	}
+
+	if wantMessage != gotMessage {
+		t.Errorf("Want message %s, got %s", wantMessage, gotMessage)
+	}
+}
+
+func TestSkipDirectives(t *testing.T) {
+	workflow := &workflowsv1alpha1.Workflow{
+		Spec: workflowsv1alpha1.WorkflowSpec{},
+	}
+
+	ctx := config.WithConfig(context.Background(), &config.Config{
+		Defaults: &config.Defaults{SkipDirectives: []string{"[no ci]"}},
+	})
+
+	tests := []struct {
+		eventName   string
+		message     string
+		title       string
+		wantMessage string
+		wantStatus  Status
+	}{
+		{"push", "Fix typo", "", filterSucceeded, Passed},
+		{"push", "Fix typo [skip ci]", "", "head commit message contains the skip directive [skip ci]", Failed},
+		{"push", "[CI SKIP] Fix typo", "", "head commit message contains the skip directive [ci skip]", Failed},
+		{"push", "Fix typo [no ci]", "", "head commit message contains the skip directive [no ci]", Failed},
+		{"pull_request", "", "Fix typo", filterSucceeded, Passed},
+		{"pull_request", "[skip ci]", "Fix typo", filterSucceeded, Passed},
+		{"pull_request", "", "Fix typo [skip ci]", "pull request title contains the skip directive [skip ci]", Failed},
+		{"release", "", "", "skipped because release event isn't supported", Skipped},
+	}
+
+	for _, test := range tests {
+		gotStatus, gotMessage := skipDirectives(ctx, workflow, &github.Event{Name: test.eventName, HeadCommitMessage: test.message, PullRequestTitle: test.title})
+		if test.wantMessage != gotMessage {
+			t.Errorf("Want message %s, got %s", test.wantMessage, gotMessage)
+		}
+
+		if test.wantStatus != gotStatus {
+			t.Errorf("Want status %s, got %s", test.wantStatus, gotStatus)
+		}
+	}
+}
+
+func TestWorkflowIgnoringSkipDirectives(t *testing.T) {
+	workflow := &workflowsv1alpha1.Workflow{
+		Spec: workflowsv1alpha1.WorkflowSpec{
+			IgnoreSkipDirectives: true,
+		},
+	}
+
+	wantStatus, wantMessage := Skipped, skipDirectivesIgnored
+	gotStatus, gotMessage := skipDirectives(context.Background(), workflow, &github.Event{Name: "push", HeadCommitMessage: "Fix typo [skip ci]"})
+
+	if wantStatus != gotStatus {
+		t.Errorf("Want status %s, got %s", wantStatus, gotStatus)
+	}
+
+	if wantMessage != gotMessage {
+		t.Errorf("Want message %s, got %s", wantMessage, gotMessage)
+	}
+}
+
 func TestBranches(t *testing.T) {
 	workflow := &workflowsv1alpha1.Workflow{
 		Spec: workflowsv1alpha1.WorkflowSpec{
@@ -81,23 +281,23 @@ func TestBranches(t *testing.T) {
 		branch      string
 		eventName   string
 		wantMessage string
-		wantResult  bool
+		wantStatus  Status
 	}{
-		{"main", "push", filterSucceeded, true},
-		{"staging", "pull_request", filterSucceeded, true},
-		{"staging-john-patch1", "push", filterSucceeded, true},
-		{"dev", "pull_request", "branch dev doesn't match filters [main staging*]", false},
-		{"", "release", "skipped because release event isn't supported", true},
+		{"main", "push", filterSucceeded, Passed},
+		{"staging", "pull_request", filterSucceeded, Passed},
+		{"staging-john-patch1", "push", filterSucceeded, Passed},
+		{"dev", "pull_request", "branch dev doesn't match filters [main staging*]", Failed},
+		{"", "release", "skipped because release event isn't supported", Skipped},
 	}
 
 	for _, test := range tests {
-		gotResult, gotMessage := branches(workflow, &github.Event{Name: test.eventName, Branch: test.branch})
+		gotStatus, gotMessage := branches(context.Background(), workflow, &github.Event{Name: test.eventName, Branch: test.branch})
 		if test.wantMessage != gotMessage {
 			t.Errorf("Want message %s, got %s", test.wantMessage, gotMessage)
 		}
 
-		if test.wantResult != gotResult {
-			t.Errorf("Want result %t, got %t", test.wantResult, gotResult)
+		if test.wantStatus != gotStatus {
+			t.Errorf("Want status %s, got %s", test.wantStatus, gotStatus)
 		}
 	}
 }
@@ -107,11 +307,61 @@ func TestWithEmptyBranchesSlice(t *testing.T) {
 		Spec: workflowsv1alpha1.WorkflowSpec{},
 	}
 
-	wantResult, wantMessage := true, noConfiguredBranches
-	gotResult, gotMessage := branches(workflow, &github.Event{Name: "push", Branch: "dev"})
+	wantStatus, wantMessage := Skipped, noConfiguredBranches
+	gotStatus, gotMessage := branches(context.Background(), workflow, &github.Event{Name: "push", Branch: "dev"})
+
+	if wantStatus != gotStatus {
+		t.Errorf("Want status %s, got %s", wantStatus, gotStatus)
+	}
+
+	if wantMessage != gotMessage {
+		t.Errorf("Want message %s, got %s", wantMessage, gotMessage)
+	}
+}
+
+func TestBaseBranches(t *testing.T) {
+	workflow := &workflowsv1alpha1.Workflow{
+		Spec: workflowsv1alpha1.WorkflowSpec{
+			BaseBranches: []string{"main",
+				"release/*",
+			},
+		},
+	}
+
+	tests := []struct {
+		baseBranch  string
+		eventName   string
+		wantMessage string
+		wantStatus  Status
+	}{
+		{"main", "pull_request", filterSucceeded, Passed},
+		{"release/1.x", "pull_request", filterSucceeded, Passed},
+		{"dev", "pull_request", "base branch dev doesn't match filters [main release/*]", Failed},
+		{"", "push", "skipped because push event isn't supported", Skipped},
+	}
+
+	for _, test := range tests {
+		gotStatus, gotMessage := baseBranches(context.Background(), workflow, &github.Event{Name: test.eventName, BaseBranch: test.baseBranch})
+		if test.wantMessage != gotMessage {
+			t.Errorf("Want message %s, got %s", test.wantMessage, gotMessage)
+		}
+
+		if test.wantStatus != gotStatus {
+			t.Errorf("Want status %s, got %s", test.wantStatus, gotStatus)
+		}
+	}
+}
+
+func TestWithEmptyBaseBranchesSlice(t *testing.T) {
+	workflow := &workflowsv1alpha1.Workflow{
+		Spec: workflowsv1alpha1.WorkflowSpec{},
+	}
+
+	wantStatus, wantMessage := Skipped, noConfiguredBaseBranches
+	gotStatus, gotMessage := baseBranches(context.Background(), workflow, &github.Event{Name: "pull_request", BaseBranch: "main"})
 
-	if wantResult != gotResult {
-		t.Errorf("Want result %t, got %t", wantResult, gotResult)
+	if wantStatus != gotStatus {
+		t.Errorf("Want status %s, got %s", wantStatus, gotStatus)
 	}
 
 	if wantMessage != gotMessage {
@@ -132,24 +382,24 @@ func TestPaths(t *testing.T) {
 		files       []string
 		eventName   string
 		wantMessage string
-		wantResult  bool
+		wantStatus  Status
 	}{
-		{[]string{"pkg/x/y.go"}, "push", filterSucceeded, true},
-		{[]string{"pkg/x/y.go", "README.md"}, "push", filterSucceeded, true},
-		{[]string{"scripts/build.sh", "README.md"}, "pull_request", filterSucceeded, true},
-		{[]string{"README.md"}, "push", "modified files don't match filters [**/*.go **/*.sh]", false},
-		{[]string{"README.md"}, "pull_request", "modified files don't match filters [**/*.go **/*.sh]", false},
-		{[]string{}, "release", "skipped because release event isn't supported", true},
+		{[]string{"pkg/x/y.go"}, "push", filterSucceeded, Passed},
+		{[]string{"pkg/x/y.go", "README.md"}, "push", filterSucceeded, Passed},
+		{[]string{"scripts/build.sh", "README.md"}, "pull_request", filterSucceeded, Passed},
+		{[]string{"README.md"}, "push", "modified files don't match filters [**/*.go **/*.sh]", Failed},
+		{[]string{"README.md"}, "pull_request", "modified files don't match filters [**/*.go **/*.sh]", Failed},
+		{[]string{}, "release", "skipped because release event isn't supported", Skipped},
 	}
 
 	for _, test := range tests {
-		gotResult, gotMessage := paths(workflow, &github.Event{Name: test.eventName, Changes: test.files})
+		gotStatus, gotMessage := paths(context.Background(), workflow, &github.Event{Name: test.eventName, Changes: test.files})
 		if test.wantMessage != gotMessage {
 			t.Errorf("Want message %s, got %s", test.wantMessage, gotMessage)
 		}
 
-		if test.wantResult != gotResult {
-			t.Errorf("Want result %t, got %t", test.wantResult, gotResult)
+		if test.wantStatus != gotStatus {
+			t.Errorf("Want status %s, got %s", test.wantStatus, gotStatus)
 		}
 	}
 }
@@ -159,11 +409,92 @@ func TestWithEmptyPathsSlice(t *testing.T) {
 		Spec: workflowsv1alpha1.WorkflowSpec{},
 	}
 
-	wantResult, wantMessage := true, noConfiguredPaths
-	gotResult, gotMessage := paths(workflow, &github.Event{Name: "push", Changes: []string{"go.mod"}})
+	wantStatus, wantMessage := Skipped, noConfiguredPaths
+	gotStatus, gotMessage := paths(context.Background(), workflow, &github.Event{Name: "push", Changes: []string{"go.mod"}})
 
-	if wantResult != gotResult {
-		t.Errorf("Want result %t, got %t", wantResult, gotResult)
+	if wantStatus != gotStatus {
+		t.Errorf("Want status %s, got %s", wantStatus, gotStatus)
+	}
+
+	if wantMessage != gotMessage {
+		t.Errorf("Want message %s, got %s", wantMessage, gotMessage)
+	}
+}
+
+func TestWhen(t *testing.T) {
+	tests := []struct {
+		expression  string
+		wantMessage string
+		wantStatus  Status
+	}{
+		{`!body.pull_request.draft`, filterSucceeded, Passed},
+		{`event.branch.startsWith("feat/") && event.sender == "john-doe"`, filterSucceeded, Passed},
+		{`body.pull_request.base.repo.full_name == workflow.repository.owner + "/" + workflow.repository.name`, filterSucceeded, Passed},
+		{`workflow.labels["team"] == "platform"`, filterSucceeded, Passed},
+		{`"README.md" in event.changes`, "expression \"README.md\" in event.changes evaluated to false", Failed},
+		{`body.pull_request.draft`, "expression body.pull_request.draft evaluated to false", Failed},
+	}
+
+	workflow := &workflowsv1alpha1.Workflow{
+		ObjectMeta: metav1.ObjectMeta{Name: "ci",
+			Labels: map[string]string{"team": "platform"},
+		},
+		Spec: workflowsv1alpha1.WorkflowSpec{
+			Repository: &workflowsv1alpha1.Repository{Owner: "my-org",
+				Name: "my-repo",
+			},
+		},
+	}
+
+	event := &github.Event{Name: "pull_request",
+		Body:    []byte(`{"pull_request": {"draft": false, "base": {"repo": {"full_name": "my-org/my-repo"}}}}`),
+		Branch:  "feat/new-button",
+		Sender:  "john-doe",
+		Changes: []string{"main.go"},
+	}
+
+	for _, test := range tests {
+		workflow.Spec.When = test.expression
+
+		gotStatus, gotMessage := when(context.Background(), workflow, event)
+		if test.wantMessage != gotMessage {
+			t.Errorf("Want message %s, got %s", test.wantMessage, gotMessage)
+		}
+
+		if test.wantStatus != gotStatus {
+			t.Errorf("Want status %s, got %s", test.wantStatus, gotStatus)
+		}
+	}
+}
+
+func TestWhenWithInvalidExpression(t *testing.T) {
+	workflow := &workflowsv1alpha1.Workflow{
+		Spec: workflowsv1alpha1.WorkflowSpec{
+			When: `body.action ==`,
+		},
+	}
+
+	gotStatus, gotMessage := when(context.Background(), workflow, &github.Event{Name: "push"})
+
+	if gotStatus != Failed {
+		t.Errorf("Want status %s, got %s", Failed, gotStatus)
+	}
+
+	if !strings.HasPrefix(gotMessage, "invalid expression body.action ==") {
+		t.Errorf("Want a message describing the invalid expression, got %s", gotMessage)
+	}
+}
+
+func TestWithNoConfiguredExpression(t *testing.T) {
+	workflow := &workflowsv1alpha1.Workflow{
+		Spec: workflowsv1alpha1.WorkflowSpec{},
+	}
+
+	wantStatus, wantMessage := Skipped, noConfiguredExpression
+	gotStatus, gotMessage := when(context.Background(), workflow, &github.Event{Name: "push"})
+
+	if wantStatus != gotStatus {
+		t.Errorf("Want status %s, got %s", wantStatus, gotStatus)
 	}
 
 	if wantMessage != gotMessage {
@@ -187,24 +518,28 @@ func TestCanTrigger(t *testing.T) {
 		eventName   string
 		repo        string
 		branch      string
+		message     string
 		files       []string
 		wantMessage string
 		wantResult  bool
 	}{
-		{"push", "my-org/my-repo", "main", []string{"pkg/x/y.go"}, workflowAccepted, true},
-		{"pull_request", "my-org/my-repo", "main", []string{"pkg/x/y.go"}, "Workflow was rejected because Github event doesn't satisfy rule: pull_request event doesn't match filters [push]", false},
-		{"push", "my-org/other-repo", "main", []string{"pkg/x/y.go"}, "Workflow was rejected because Github event doesn't satisfy rule: repository my-org/other-repo doesn't match workflow's repository my-org/my-repo", false},
-		{"push", "my-org/my-repo", "dev", []string{"pkg/x/y.go"}, "Workflow was rejected because Github event doesn't satisfy rule: branch dev doesn't match filters [main]", false},
-		{"push", "my-org/my-repo", "main", []string{"README.md"}, "Workflow was rejected because Github event doesn't satisfy rule: modified files don't match filters [**/*.go]", false},
+		{"push", "my-org/my-repo", "main", "", []string{"pkg/x/y.go"}, workflowAccepted, true},
+		{"push", "my-org/my-repo", "main", "Fix typo [skip ci]", []string{"pkg/x/y.go"}, "Workflow was rejected because Github event doesn't satisfy rule: head commit message contains the skip directive [skip ci]", false},
+		{"pull_request", "my-org/my-repo", "main", "", []string{"pkg/x/y.go"}, "Workflow was rejected because Github event doesn't satisfy rule: pull_request event doesn't match filters [push]", false},
+		{"push", "my-org/other-repo", "main", "", []string{"pkg/x/y.go"}, "Workflow was rejected because Github event doesn't satisfy rule: repository my-org/other-repo doesn't match workflow's repository my-org/my-repo", false},
+		{"push", "my-org/my-repo", "dev", "", []string{"pkg/x/y.go"}, "Workflow was rejected because Github event doesn't satisfy rule: branch dev doesn't match filters [main]", false},
+		{"push", "my-org/my-repo", "main", "", []string{"README.md"}, "Workflow was rejected because Github event doesn't satisfy rule: modified files don't match filters [**/*.go]", false},
 	}
 
 	for _, test := range tests {
 		event := &github.Event{Name: test.eventName,
-			Repository: test.repo,
-			Branch:     test.branch,
-			Changes:    test.files,
+			Repository:        test.repo,
+			Branch:            test.branch,
+			HeadCommitMessage: test.message,
+			Changes:           test.files,
 		}
-		gotResult, gotMessage := CanTrigger(workflow, event)
+		report := CanTrigger(context.Background(), workflow, event)
+		gotResult, gotMessage := report.Accepted(), report.Message()
 		if test.wantMessage != gotMessage {
 			t.Errorf("Want message %s, got %s", test.wantMessage, gotMessage)
 		}
@@ -214,3 +549,103 @@ func TestCanTrigger(t *testing.T) {
 		}
 	}
 }
+
+func TestCanTriggerReportsEveryFilter(t *testing.T) {
+	workflow := &workflowsv1alpha1.Workflow{
+		Spec: workflowsv1alpha1.WorkflowSpec{
+			Repository: &workflowsv1alpha1.Repository{Owner: "my-org",
+				Name: "my-repo",
+			},
+			Events:   []string{"push"},
+			Branches: []string{"main"},
+		},
+	}
+
+	event := &github.Event{Name: "push",
+		Repository: "my-org/my-repo",
+		Branch:     "dev",
+		Changes:    []string{"README.md"},
+	}
+
+	report := CanTrigger(context.Background(), workflow, event)
+
+	want := []Result{
+		{Filter: "events", Status: Passed, Details: filterSucceeded},
+		{Filter: "repository", Status: Passed, Details: filterSucceeded},
+		{Filter: "actors", Status: Skipped, Details: noConfiguredActors},
+		{Filter: "skip-directives", Status: Passed, Details: filterSucceeded},
+		{Filter: "branches", Status: Failed, Details: "branch dev doesn't match filters [main]"},
+		{Filter: "base-branches", Status: Skipped, Details: previousFilterFailed},
+		{Filter: "paths", Status: Skipped, Details: previousFilterFailed},
+		{Filter: "when", Status: Skipped, Details: previousFilterFailed},
+	}
+
+	if diff := cmp.Diff(want, report.Results); diff != "" {
+		t.Errorf("Mismatch (-want +got):\n%s", diff)
+	}
+
+	if report.Accepted() {
+		t.Error("Want a rejected report, but got an accepted one")
+	}
+}
+
+func TestCanTriggerStopsAtTheFirstFailure(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	// Memberships must not be checked for events the workflow doesn't care
+	// about, which would waste rate limits.
+	checker := githubmocks.NewMockMembershipChecker(mockCtrl)
+	ctx := github.WithMembershipChecker(context.Background(), checker)
+
+	workflow := &workflowsv1alpha1.Workflow{
+		Spec: workflowsv1alpha1.WorkflowSpec{
+			Repository: &workflowsv1alpha1.Repository{Owner: "my-org",
+				Name: "my-repo",
+			},
+			Events: []string{"push"},
+			Actors: &workflowsv1alpha1.Actors{Orgs: []string{"my-org"}},
+			When:   "invalid expression (",
+		},
+	}
+
+	event := &github.Event{Name: "release",
+		Repository: "my-org/my-repo",
+		Sender:     "john-doe",
+	}
+
+	report := CanTrigger(ctx, workflow, event)
+
+	want := []Result{
+		{Filter: "events", Status: Failed, Details: "release event doesn't match filters [push]"},
+		{Filter: "repository", Status: Skipped, Details: previousFilterFailed},
+		{Filter: "actors", Status: Skipped, Details: previousFilterFailed},
+		{Filter: "skip-directives", Status: Skipped, Details: previousFilterFailed},
+		{Filter: "branches", Status: Skipped, Details: previousFilterFailed},
+		{Filter: "base-branches", Status: Skipped, Details: previousFilterFailed},
+		{Filter: "paths", Status: Skipped, Details: previousFilterFailed},
+		{Filter: "when", Status: Skipped, Details: previousFilterFailed},
+	}
+
+	if diff := cmp.Diff(want, report.Results); diff != "" {
+		t.Errorf("Mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestReportMessageWithMultipleFailures(t *testing.T) {
+	report := &Report{Results: []Result{
+		{Filter: "events", Status: Failed, Details: "release event doesn't match filters [push]"},
+		{Filter: "paths", Status: Skipped, Details: noConfiguredPaths},
+		{Filter: "branches", Status: Failed, Details: "branch dev doesn't match filters [main]"},
+	}}
+
+	wantMessage := "Workflow was rejected because Github event doesn't satisfy rules: release event doesn't match filters [push]; branch dev doesn't match filters [main]"
+	if gotMessage := report.Message(); wantMessage != gotMessage {
+		t.Errorf("Want message %s, got %s", wantMessage, gotMessage)
+	}
+
+	wantString := `events: failed (release event doesn't match filters [push])
+paths: skipped (skipped because there are no configured paths)
+branches: failed (branch dev doesn't match filters [main])`
+	if gotString := report.String(); wantString != gotString {
+		t.Errorf("Want %s, got %s", wantString, gotString)
+	}
+}
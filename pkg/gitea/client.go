@@ -0,0 +1,99 @@
+// Package gitea manages repositories hosted by Gitea instances, including
+// Forgejo ones, whose API and webhooks are the same.
+package gitea
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	"github.com/nubank/workflows/pkg/rest"
+)
+
+const (
+
+	// Name of the environment variable that contains the base URL of the
+	// Gitea instance hosting repositories.
+	giteaURL = "GITEA_URL"
+
+	// Name of the environment variable that contains the base URL
+	// repositories are cloned from over SSH (e.g.
+	// ssh://git@gitea.example.com:2222), for instances whose SSH server
+	// doesn't listen on port 22.
+	giteaSSHURL = "GITEA_SSH_URL"
+
+	// Name of the environment variable that contains the path to a file
+	// holding PEM encoded certificates to be trusted when talking to Gitea.
+	giteaCABundlePath = "GITEA_CA_BUNDLE_PATH"
+
+	// Path where the Gitea access token is mounted. Gitea support is only
+	// enabled when it exists.
+	giteaTokenPath = "/var/run/secrets/gitea/token"
+
+	// Path under which Gitea serves the REST API.
+	apiPath = "/api/v1/"
+)
+
+// Client talks to the REST API of a Gitea instance with an access token
+// granted read and write access to repositories.
+type Client struct {
+	*rest.Client
+	token string
+}
+
+// NewClient returns a Client for the Gitea instance at the supplied base URL,
+// trusting the certificates of the supplied bundle if any. Repositories are
+// cloned over SSH from the supplied URL when it's set.
+func NewClient(baseURL, sshURL, token string, caBundle []byte) (*Client, error) {
+	client := &Client{token: token}
+
+	var err error
+	if client.Client, err = rest.NewClient(client, baseURL, apiPath, sshURL, caBundle); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// NewClientFromEnv returns a Client for the Gitea instance configured through
+// environment variables. It returns nil when no Gitea token is mounted,
+// meaning that Gitea isn't supported.
+func NewClientFromEnv() (*Client, error) {
+	content, err := ioutil.ReadFile(giteaTokenPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Error reading Gitea token: %w", err)
+	}
+
+	baseURL, ok := os.LookupEnv(giteaURL)
+	if !ok || baseURL == "" {
+		return nil, fmt.Errorf("Error configuring Gitea: %s must be set", giteaURL)
+	}
+
+	caBundle, err := rest.ReadCABundle(giteaCABundlePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewClient(baseURL, os.Getenv(giteaSSHURL), strings.TrimSpace(string(content)), caBundle)
+}
+
+// Product implements rest.API.
+func (c *Client) Product() string {
+	return "Gitea"
+}
+
+// Authenticate implements rest.API.
+func (c *Client) Authenticate(request *http.Request) {
+	request.Header.Set("Authorization", "token "+c.token)
+	request.Header.Set("Accept", "application/json")
+}
+
+// RepoPath implements rest.API.
+func (c *Client) RepoPath(repo *workflowsv1alpha1.Repository) string {
+	return fmt.Sprintf("repos/%s/%s", url.PathEscape(repo.Owner), url.PathEscape(repo.Name))
+}
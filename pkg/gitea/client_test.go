@@ -0,0 +1,276 @@
+package gitea
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/logging"
+)
+
+// fakeToken is the token test clients are authenticated with.
+const fakeToken = "gta-abc"
+
+// receivedRequest is a request received by the fake Gitea API.
+type receivedRequest struct {
+	method string
+	path   string
+	header http.Header
+	body   map[string]interface{}
+}
+
+// fakeGitea answers requests to the Gitea API with canned JSON responses,
+// keyed by their method and path (e.g. GET /api/v1/repos/my-org/my-repo), and
+// records them. Other requests are answered with 404.
+type fakeGitea struct {
+	mutex     sync.Mutex
+	responses map[string]string
+	requests  []receivedRequest
+}
+
+// newFakeGitea starts a fake Gitea API and returns a client talking to it.
+func newFakeGitea(t *testing.T, responses map[string]string) (*fakeGitea, *Client) {
+	fake := &fakeGitea{responses: responses}
+
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	client, err := NewClient(server.URL, "", fakeToken, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fake, client
+}
+
+// ServeHTTP implements http.Handler.
+func (f *fakeGitea) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	received := receivedRequest{method: request.Method, path: request.URL.RequestURI(), header: request.Header}
+	json.NewDecoder(request.Body).Decode(&received.body)
+	f.requests = append(f.requests, received)
+
+	response, exists := f.responses[request.Method+" "+request.URL.Path]
+	if !exists {
+		writer.WriteHeader(http.StatusNotFound)
+		writer.Write([]byte(`{"message": "The target couldn't be found."}`))
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Write([]byte(response))
+}
+
+// requestsTo returns the requests received with the supplied method.
+func (f *fakeGitea) requestsTo(method string) []receivedRequest {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	requests := make([]receivedRequest, 0)
+	for _, request := range f.requests {
+		if request.method == method {
+			requests = append(requests, request)
+		}
+	}
+	return requests
+}
+
+func newTestContext() context.Context {
+	return logging.WithLogger(context.Background(), zap.NewNop().Sugar())
+}
+
+func newTestWorkflow() *workflowsv1alpha1.Workflow {
+	return &workflowsv1alpha1.Workflow{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-1", Namespace: "dev"},
+		Spec: workflowsv1alpha1.WorkflowSpec{
+			Repository: &workflowsv1alpha1.Repository{Owner: "my-org",
+				Name:     "my-repo",
+				Provider: workflowsv1alpha1.GiteaProvider,
+			},
+			Webhook: &workflowsv1alpha1.Webhook{URL: "https://workflows.example.com"},
+			Events:  []string{"pull_request"},
+		},
+	}
+}
+
+func TestHost(t *testing.T) {
+	client, err := NewClient("https://forgejo.example.com/", "ssh://git@forgejo.example.com:2222", fakeToken, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if got := client.Host().SSHCloneURL("my-org", "my-repo"); got != "ssh://git@forgejo.example.com:2222/my-org/my-repo.git" {
+		t.Errorf("Unexpected SSH clone URL %s", got)
+	}
+
+	if got := client.Host().HTTPSCloneURL("my-org", "my-repo"); got != "https://forgejo.example.com/my-org/my-repo.git" {
+		t.Errorf("Unexpected clone URL %s", got)
+	}
+
+	if _, err := NewClient("forgejo.example.com", "", fakeToken, nil); err == nil {
+		t.Error("Want an error for relative URLs")
+	}
+}
+
+func TestRequestsAreAuthenticatedWithTheToken(t *testing.T) {
+	fake, client := newFakeGitea(t, map[string]string{
+		"GET /api/v1/repos/my-org/my-repo": `{"id": 1, "default_branch": "main"}`,
+	})
+
+	if err := NewRepoReconciler(client).ReconcileRepos(newTestContext(), newTestWorkflow()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	header := fake.requests[0].header
+	if got := header.Get("Authorization"); got != "token "+fakeToken {
+		t.Errorf("Unexpected Authorization header %q", got)
+	}
+
+	if got := header.Get("Accept"); got != "application/json" {
+		t.Errorf("Unexpected Accept header %q", got)
+	}
+}
+
+func TestInternalRepositoriesArePrivate(t *testing.T) {
+	_, client := newFakeGitea(t, map[string]string{
+		"GET /api/v1/repos/my-org/my-repo": `{"id": 1, "default_branch": "main", "internal": true}`,
+		"GET /api/v1/repos/my-org/my-lib":  `{"id": 2, "default_branch": "master", "private": true}`,
+		"GET /api/v1/repos/my-org/my-docs": `{"id": 3, "default_branch": "gh-pages"}`,
+	})
+
+	workflow := newTestWorkflow()
+	workflow.Spec.AdditionalRepositories = []workflowsv1alpha1.Repository{{Owner: "my-org", Name: "my-lib"}, {Owner: "my-org", Name: "my-docs"}}
+	if err := NewRepoReconciler(client).ReconcileRepos(newTestContext(), workflow); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if repo := workflow.Spec.Repository; repo.DefaultBranch != "main" || !repo.Private {
+		t.Errorf("Want private repository with default branch main, got %+v", repo)
+	}
+
+	if repo := workflow.Spec.AdditionalRepositories[0]; !repo.Private {
+		t.Errorf("Want private repository, got %+v", repo)
+	}
+
+	if repo := workflow.Spec.AdditionalRepositories[1]; repo.DefaultBranch != "gh-pages" || repo.Private {
+		t.Errorf("Want public repository with default branch gh-pages, got %+v", repo)
+	}
+}
+
+func TestWebhooksListenToPushesToPullRequests(t *testing.T) {
+	fake, client := newFakeGitea(t, map[string]string{
+		"POST /api/v1/repos/my-org/my-repo/hooks": `{"id": 7}`,
+	})
+
+	workflow := newTestWorkflow()
+	created, err := NewWebhookReconciler(client).ReconcileHook(newTestContext(), workflow)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if created.ID != 7 || *workflow.GetWebhookID() != 7 {
+		t.Errorf("Want webhook #7 to be created and recorded, got %+v", created)
+	}
+
+	want := map[string]interface{}{
+		"type": "gitea",
+		"config": map[string]interface{}{
+			"url":          workflow.GetHooksURL(),
+			"content_type": "json",
+			"secret":       string(created.Secret),
+		},
+		"events": []interface{}{"pull_request", "pull_request_sync"},
+		"active": true,
+	}
+	if diff := cmp.Diff(want, fake.requestsTo(http.MethodPost)[0].body); diff != "" {
+		t.Errorf("Unexpected webhook (-want +got):\n%s", diff)
+	}
+}
+
+func TestWebhooksAreUpdatedWithPatch(t *testing.T) {
+	workflow := newTestWorkflow()
+	workflow.Spec.Events = []string{"push", "pull_request"}
+	workflow.SetWebhookID(7)
+
+	fake, client := newFakeGitea(t, map[string]string{
+		"GET /api/v1/repos/my-org/my-repo/hooks/7":   `{"id": 7, "config": {"url": "` + workflow.GetHooksURL() + `", "content_type": "json"}, "events": ["pull_request", "pull_request_sync"], "active": true}`,
+		"PATCH /api/v1/repos/my-org/my-repo/hooks/7": `{"id": 7}`,
+	})
+
+	updated, err := NewWebhookReconciler(client).ReconcileHook(newTestContext(), workflow)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	patches := fake.requestsTo(http.MethodPatch)
+	if updated == nil || len(patches) != 1 {
+		t.Fatalf("Want webhook #7 to be patched, got %+v and requests %+v", updated, fake.requests)
+	}
+
+	if diff := cmp.Diff([]interface{}{"push", "pull_request", "pull_request_sync"}, patches[0].body["events"]); diff != "" {
+		t.Errorf("Unexpected events (-want +got):\n%s", diff)
+	}
+
+	if _, exists := patches[0].body["config"].(map[string]interface{})["secret"]; exists {
+		t.Errorf("Want the secret to be left untouched, got %+v", patches[0].body)
+	}
+}
+
+func TestWebhooksListingTheSameEventsInAnotherOrderAreUpToDate(t *testing.T) {
+	workflow := newTestWorkflow()
+	workflow.SetWebhookID(7)
+
+	_, client := newFakeGitea(t, map[string]string{
+		"GET /api/v1/repos/my-org/my-repo/hooks/7": `{"id": 7, "config": {"url": "` + workflow.GetHooksURL() + `", "content_type": "json"}, "events": ["pull_request_sync", "pull_request"], "active": true}`,
+	})
+
+	if webhook, err := NewWebhookReconciler(client).ReconcileHook(newTestContext(), workflow); err != nil || webhook != nil {
+		t.Errorf("Want no changes, got %+v and error %v", webhook, err)
+	}
+}
+
+func TestDeployKeysAreRotatedWhenTheyAreNoLongerReadOnly(t *testing.T) {
+	workflow := newTestWorkflow()
+	workflow.Spec.Repository.Private = true
+	workflow.SetDeployKeyID(workflow.Spec.Repository, 3)
+
+	fake, client := newFakeGitea(t, map[string]string{
+		"GET /api/v1/repos/my-org/my-repo/keys/3":    `{"id": 3, "read_only": false}`,
+		"DELETE /api/v1/repos/my-org/my-repo/keys/3": ``,
+		"POST /api/v1/repos/my-org/my-repo/keys":     `{"id": 4, "read_only": true}`,
+	})
+
+	keyPairs, err := NewDeployKeysReconciler(client).ReconcileKeys(newTestContext(), workflow)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(keyPairs) != 1 || len(fake.requestsTo(http.MethodDelete)) != 1 || *workflow.GetDeployKeyID(workflow.Spec.Repository) != 4 {
+		t.Fatalf("Want deploy key #3 to be rotated, got requests %+v", fake.requests)
+	}
+
+	created := fake.requestsTo(http.MethodPost)[0].body
+	if created["read_only"] != true || created["key"] != string(keyPairs[0].PublicKey) {
+		t.Errorf("Want a read only key, got %+v", created)
+	}
+}
+
+func TestRawFilePathsKeepTheSlashesOfFilePaths(t *testing.T) {
+	client, err := NewClient("https://gitea.example.com", "", fakeToken, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	got := client.RawFilePath(newTestWorkflow().Spec.Repository, ".workflows/my tests/test-1.yaml", "feature/x")
+	if want := "repos/my-org/my-repo/raw/.workflows/my%20tests/test-1.yaml?ref=feature%2Fx"; got != want {
+		t.Errorf("Want path %s, got %s", want, got)
+	}
+}
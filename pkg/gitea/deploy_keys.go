@@ -0,0 +1,46 @@
+package gitea
+
+import (
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	"github.com/nubank/workflows/pkg/github"
+	"github.com/nubank/workflows/pkg/rest"
+)
+
+// deployKey is a Gitea deploy key, as sent to and returned by the API.
+type deployKey struct {
+	ID       int64  `json:"id,omitempty"`
+	Title    string `json:"title"`
+	Key      string `json:"key"`
+	ReadOnly bool   `json:"read_only"`
+}
+
+// GetID implements rest.DeployKey.
+func (d *deployKey) GetID() int64 {
+	return d.ID
+}
+
+// IsReadOnly implements rest.DeployKey.
+func (d *deployKey) IsReadOnly() bool {
+	return d.ReadOnly
+}
+
+// KeysPath implements rest.API.
+func (c *Client) KeysPath(repo *workflowsv1alpha1.Repository) string {
+	return c.RepoPath(repo) + "/keys"
+}
+
+// DeployKey implements rest.API.
+func (c *Client) DeployKey() rest.DeployKey {
+	return &deployKey{}
+}
+
+// NewDeployKey implements rest.API.
+func (c *Client) NewDeployKey(title, publicKey string, readOnly bool) rest.DeployKey {
+	return &deployKey{Title: title, Key: publicKey, ReadOnly: readOnly}
+}
+
+// NewDeployKeysReconciler returns a new DeployKeysReconciler for Gitea
+// deploy keys.
+func NewDeployKeysReconciler(client *Client) github.DeployKeysReconciler {
+	return rest.NewDeployKeysReconciler(client.Client)
+}
@@ -0,0 +1,124 @@
+package gitea
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/nubank/workflows/pkg/github"
+)
+
+const (
+
+	// giteaEventHeader defines the header that contains the name of the event
+	// delivered. Forgejo sends it along with forgejoEventHeader.
+	giteaEventHeader   = "X-Gitea-Event"
+	forgejoEventHeader = "X-Forgejo-Event"
+
+	// giteaDeliveryHeader defines the header that contains a guid identifying
+	// the delivery.
+	giteaDeliveryHeader   = "X-Gitea-Delivery"
+	forgejoDeliveryHeader = "X-Forgejo-Delivery"
+
+	// giteaSignatureHeader defines the header that contains the hex encoded
+	// HMAC-SHA256 signature of the payload, which unlike Github's isn't
+	// prefixed with the hash function.
+	giteaSignatureHeader   = "X-Gitea-Signature"
+	forgejoSignatureHeader = "X-Forgejo-Signature"
+)
+
+// githubEvents lists the events whose payloads are parsed as their Github
+// counterparts.
+var githubEvents = map[string]bool{"push": true, "pull_request": true}
+
+// pullRequestActions translates actions of Gitea pull request events that
+// differ from those of Github pull_request events, which filters are written
+// against.
+var pullRequestActions = map[string]string{
+	"synchronized": "synchronize",
+}
+
+// payload holds the attributes shared by payloads of all Gitea events.
+type payload struct {
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+	Sender struct {
+		Login string `json:"login"`
+	} `json:"sender"`
+}
+
+// IsWebhookRequest returns true if the supplied request was delivered by a
+// Gitea or Forgejo webhook.
+func IsWebhookRequest(request *http.Request) bool {
+	return getHeader(request, giteaEventHeader, forgejoEventHeader) != ""
+}
+
+// ParseWebhookEvent creates a new Event object from the supplied HTTP request
+// delivered by a Gitea or Forgejo webhook. Payloads of push and pull_request
+// events are compatible with Github's, so they're parsed the same way. Other
+// events are only described by their name, repository and sender.
+func ParseWebhookEvent(request *http.Request) (*github.Event, error) {
+	eventName := getHeader(request, giteaEventHeader, forgejoEventHeader)
+	if eventName == "" {
+		return nil, errors.New("Request doesn't appear to have been delivered by a Gitea webhook")
+	}
+
+	// Workflows' webhooks always have a secret.
+	signature := getHeader(request, giteaSignatureHeader, forgejoSignatureHeader)
+	if signature == "" {
+		return nil, fmt.Errorf("Access denied: Gitea signature header %s is missing", giteaSignatureHeader)
+	}
+
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading request body: %w", err)
+	}
+
+	var event *github.Event
+	if githubEvents[eventName] {
+		if event, err = github.ParseEventPayload(eventName, body); err != nil {
+			return nil, err
+		}
+
+		if action, ok := pullRequestActions[event.Action]; ok {
+			event.Action = action
+		}
+	} else {
+		var data map[string]interface{}
+		if err := json.Unmarshal(body, &data); err != nil {
+			return nil, fmt.Errorf("Error parsing event payload: %w", err)
+		}
+
+		var p payload
+		if err := json.Unmarshal(body, &p); err != nil {
+			return nil, fmt.Errorf("Error parsing event payload: %w", err)
+		}
+
+		event = &github.Event{Body: body,
+			Data:       data,
+			Name:       eventName,
+			Repository: p.Repository.FullName,
+			Sender:     p.Sender.Login,
+		}
+	}
+
+	event.DeliveryID = getHeader(request, giteaDeliveryHeader, forgejoDeliveryHeader)
+	// Signatures are verified the same way as Github's.
+	event.HMACSignature = []byte("sha256=" + signature)
+
+	return event, nil
+}
+
+// getHeader returns the value of the first of the supplied headers that is
+// set, so that Gitea and Forgejo headers are equally accepted.
+func getHeader(request *http.Request, names ...string) string {
+	for _, name := range names {
+		if value := request.Header.Get(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
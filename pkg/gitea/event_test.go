@@ -0,0 +1,162 @@
+package gitea
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/nubank/workflows/pkg/github"
+)
+
+const webhookSecret = "secret"
+
+func newWebhookRequest(product, eventName, payload string) *http.Request {
+	hash := hmac.New(sha256.New, []byte(webhookSecret))
+	hash.Write([]byte(payload))
+
+	request := &http.Request{
+		Body:   ioutil.NopCloser(strings.NewReader(payload)),
+		Header: http.Header{},
+	}
+	request.Header.Set("X-"+product+"-Event", eventName)
+	request.Header.Set("X-"+product+"-Delivery", "7c1d9f3a-4b2e-4a6d-9e8f-0a1b2c3d4e5f")
+	request.Header.Set("X-"+product+"-Signature", hex.EncodeToString(hash.Sum(nil)))
+	return request
+}
+
+func TestParsesThePushEventProperly(t *testing.T) {
+	payload := `{
+  "ref": "refs/heads/dev",
+  "before": "95790bf891e76fee5e1747ab589903a6a1f80f22",
+  "after": "da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+  "commits": [
+    {
+      "id": "da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+      "message": "Fix tests",
+      "added": [],
+      "modified": ["README.md"],
+      "removed": []
+    }
+  ],
+  "head_commit": {
+    "id": "da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+    "message": "Fix tests",
+    "added": [],
+    "modified": ["README.md"],
+    "removed": []
+  },
+  "repository": {
+    "id": 1,
+    "name": "my-repo",
+    "full_name": "my-org/my-repo"
+  },
+  "pusher": {
+    "login": "john-doe"
+  },
+  "sender": {
+    "login": "john-doe"
+  }
+}`
+
+	event, err := ParseWebhookEvent(newWebhookRequest("Gitea", "push", payload))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := &github.Event{
+		Branch:            "dev",
+		Changes:           []string{"README.md"},
+		DeliveryID:        "7c1d9f3a-4b2e-4a6d-9e8f-0a1b2c3d4e5f",
+		HeadCommitMessage: "Fix tests",
+		HeadCommitSHA:     "da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+		Name:              "push",
+		Repository:        "my-org/my-repo",
+		Sender:            "john-doe",
+	}
+
+	if diff := cmp.Diff(want, event, cmpopts.IgnoreFields(github.Event{}, "Body", "Data", "HMACSignature")); diff != "" {
+		t.Errorf("Mismatch (-want +got):\n%s", diff)
+	}
+
+	if ok, message := event.VerifySignature([]byte(webhookSecret)); !ok {
+		t.Errorf("Want a valid signature, got %s", message)
+	}
+
+	if ok, _ := event.VerifySignature([]byte("other-secret")); ok {
+		t.Error("Want an invalid signature for another secret")
+	}
+}
+
+func TestParsesThePullRequestEventProperly(t *testing.T) {
+	payload := `{
+  "action": "synchronized",
+  "number": 42,
+  "pull_request": {
+    "id": 7,
+    "number": 42,
+    "title": "Fix tests",
+    "head": {
+      "ref": "fix-tests",
+      "sha": "da1560886d4f094c3e6c9ef40349f7d38b5d27d7"
+    },
+    "base": {
+      "ref": "main",
+      "sha": "95790bf891e76fee5e1747ab589903a6a1f80f22"
+    }
+  },
+  "repository": {
+    "id": 1,
+    "name": "my-repo",
+    "full_name": "my-org/my-repo"
+  },
+  "sender": {
+    "login": "john-doe"
+  }
+}`
+
+	event, err := ParseWebhookEvent(newWebhookRequest("Forgejo", "pull_request", payload))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if event.Name != "pull_request" || event.Action != "synchronize" || event.Repository != "my-org/my-repo" || event.Sender != "john-doe" {
+		t.Errorf("Unexpected event %+v", event)
+	}
+
+	if event.HeadCommitSHA != "da1560886d4f094c3e6c9ef40349f7d38b5d27d7" || event.DeliveryID != "7c1d9f3a-4b2e-4a6d-9e8f-0a1b2c3d4e5f" {
+		t.Errorf("Unexpected event %+v", event)
+	}
+
+	if ok, message := event.VerifySignature([]byte(webhookSecret)); !ok {
+		t.Errorf("Want a valid signature, got %s", message)
+	}
+}
+
+func TestDescribesOtherEventsByTheirRepositoryAndSender(t *testing.T) {
+	payload := `{"action": "published", "repository": {"full_name": "my-org/my-repo"}, "sender": {"login": "john-doe"}}`
+
+	event, err := ParseWebhookEvent(newWebhookRequest("Gitea", "release", payload))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if event.Name != "release" || event.Repository != "my-org/my-repo" || event.Sender != "john-doe" || event.Data.(map[string]interface{})["action"] != "published" {
+		t.Errorf("Unexpected event %+v", event)
+	}
+}
+
+func TestReturnsAnErrorWhenTheSignatureIsMissing(t *testing.T) {
+	request := newWebhookRequest("Gitea", "push", `{}`)
+	request.Header.Del("X-Gitea-Signature")
+
+	_, err := ParseWebhookEvent(request)
+	if err == nil || !strings.Contains(err.Error(), "X-Gitea-Signature is missing") {
+		t.Errorf("Want a missing signature error, got %v", err)
+	}
+}
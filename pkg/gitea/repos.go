@@ -0,0 +1,35 @@
+package gitea
+
+import (
+	"github.com/nubank/workflows/pkg/github"
+	"github.com/nubank/workflows/pkg/rest"
+)
+
+// repository is a Gitea repository, as returned by the API.
+type repository struct {
+	ID            int64  `json:"id"`
+	DefaultBranch string `json:"default_branch"`
+	Private       bool   `json:"private"`
+	Internal      bool   `json:"internal"`
+}
+
+// GetDefaultBranch implements rest.Repository.
+func (r *repository) GetDefaultBranch() string {
+	return r.DefaultBranch
+}
+
+// IsPrivate implements rest.Repository. Internal repositories are considered
+// private, since they can't be cloned anonymously.
+func (r *repository) IsPrivate() bool {
+	return r.Private || r.Internal
+}
+
+// Repository implements rest.API.
+func (c *Client) Repository() rest.Repository {
+	return &repository{}
+}
+
+// NewRepoReconciler returns a new RepoReconciler for Gitea repositories.
+func NewRepoReconciler(client *Client) github.RepoReconciler {
+	return rest.NewRepoReconciler(client.Client)
+}
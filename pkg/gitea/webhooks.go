@@ -0,0 +1,106 @@
+package gitea
+
+import (
+	"net/http"
+	"sort"
+
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	"github.com/nubank/workflows/pkg/github"
+	"github.com/nubank/workflows/pkg/rest"
+)
+
+// hookEvents maps events workflows are triggered by to the Gitea hook events
+// delivering them. Pushes to pull requests are delivered by a hook event of
+// their own.
+var hookEvents = map[string][]string{
+	"push":         {"push"},
+	"pull_request": {"pull_request", "pull_request_sync"},
+}
+
+// hookConfig holds the settings of Gitea webhooks.
+type hookConfig struct {
+	URL         string `json:"url"`
+	ContentType string `json:"content_type"`
+	Secret      string `json:"secret,omitempty"`
+}
+
+// hook is a Gitea repository webhook, as sent to and returned by the API.
+type hook struct {
+	ID     int64      `json:"id,omitempty"`
+	Type   string     `json:"type,omitempty"`
+	Config hookConfig `json:"config"`
+	Events []string   `json:"events"`
+	Active bool       `json:"active"`
+}
+
+// GetID implements rest.Webhook.
+func (h *hook) GetID() int64 {
+	return h.ID
+}
+
+// ChangedFrom implements rest.Webhook.
+func (h *hook) ChangedFrom(desired rest.Webhook) bool {
+	d := desired.(*hook)
+	return h.Config.URL != d.Config.URL ||
+		h.Config.ContentType != d.Config.ContentType ||
+		!h.Active ||
+		!equalEvents(h.Events, d.Events)
+}
+
+// HooksPath implements rest.API.
+func (c *Client) HooksPath(repo *workflowsv1alpha1.Repository) string {
+	return c.RepoPath(repo) + "/hooks"
+}
+
+// HookUpdateMethod implements rest.API.
+func (c *Client) HookUpdateMethod() string {
+	return http.MethodPatch
+}
+
+// Webhook implements rest.API.
+func (c *Client) Webhook() rest.Webhook {
+	return &hook{}
+}
+
+// NewWebhook implements rest.API.
+func (c *Client) NewWebhook(workflow *workflowsv1alpha1.Workflow, secret string) rest.Webhook {
+	h := &hook{Type: "gitea",
+		Config: hookConfig{URL: workflow.GetHooksURL(),
+			ContentType: "json",
+			Secret:      secret,
+		},
+		Events: make([]string, 0),
+		Active: true,
+	}
+
+	for _, event := range workflow.Spec.Events {
+		h.Events = append(h.Events, hookEvents[event]...)
+	}
+	return h
+}
+
+// NewWebhookReconciler returns a new WebhookReconciler for Gitea repository
+// webhooks.
+func NewWebhookReconciler(client *Client) github.WebhookReconciler {
+	return rest.NewWebhookReconciler(client.Client)
+}
+
+// equalEvents returns true if both lists hold the same events, regardless of
+// their order.
+func equalEvents(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	sortedA := append([]string{}, a...)
+	sortedB := append([]string{}, b...)
+	sort.Strings(sortedA)
+	sort.Strings(sortedB)
+
+	for i := range sortedA {
+		if sortedA[i] != sortedB[i] {
+			return false
+		}
+	}
+	return true
+}
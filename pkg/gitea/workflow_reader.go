@@ -0,0 +1,32 @@
+package gitea
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	"github.com/nubank/workflows/pkg/github"
+	"github.com/nubank/workflows/pkg/rest"
+)
+
+// RawFilePath implements rest.API.
+func (c *Client) RawFilePath(repo *workflowsv1alpha1.Repository, filePath, ref string) string {
+	return fmt.Sprintf("%s/raw/%s?ref=%s", c.RepoPath(repo), escapePath(filePath), url.QueryEscape(ref))
+}
+
+// escapePath escapes each segment of the supplied file path, keeping the
+// slashes that separate them.
+func escapePath(filePath string) string {
+	segments := strings.Split(filePath, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// NewWorkflowReader returns a new WorkflowReader for workflows stored in
+// Gitea repositories.
+func NewWorkflowReader(client *Client) github.WorkflowReader {
+	return rest.NewWorkflowReader(client.Client)
+}
@@ -0,0 +1,407 @@
+package github
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gregjones/httpcache"
+	"github.com/hashicorp/golang-lru/simplelru"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+	"knative.dev/pkg/metrics"
+)
+
+const (
+
+	// Name of the environment variable that selects where responses from the
+	// Github API are cached: memory (default), lru or disk.
+	githubCacheBackend = "GITHUB_CACHE_BACKEND"
+
+	// Name of the environment variable that contains the maximum size, in
+	// bytes, of the lru and disk caches.
+	githubCacheMaxBytes = "GITHUB_CACHE_MAX_BYTES"
+
+	// Name of the environment variable that contains the directory where the
+	// disk cache stores responses. Replicas share responses (and their ETags)
+	// when it's on a volume mounted by all of them.
+	githubCacheDir = "GITHUB_CACHE_DIR"
+
+	// Supported cache backends.
+	memoryCacheBackend = "memory"
+	lruCacheBackend    = "lru"
+	diskCacheBackend   = "disk"
+
+	// Default maximum size of the lru and disk caches.
+	defaultCacheMaxBytes = 64 * 1024 * 1024
+)
+
+var (
+	cacheRequestsM = stats.Int64(
+		"github_cache_requests",
+		"Number of requests to the Github API by cache result (hit, revalidated or miss)",
+		stats.UnitDimensionless)
+
+	// Whether the response was served from the cache (hit), served from the
+	// cache after Github confirmed it's still valid (revalidated) or fetched
+	// from Github (miss). Revalidations don't count against rate limits.
+	cacheResultKey = tag.MustNewKey("result")
+)
+
+// NewCacheFromEnv returns the cache backend configured through environment
+// variables, which defaults to an unbounded in-memory cache.
+func NewCacheFromEnv() (httpcache.Cache, error) {
+	backend, _ := os.LookupEnv(githubCacheBackend)
+
+	switch backend {
+	case "", memoryCacheBackend:
+		return httpcache.NewMemoryCache(), nil
+
+	case lruCacheBackend:
+		maxBytes, err := cacheMaxBytesFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		return newLRUCache(maxBytes), nil
+
+	case diskCacheBackend:
+		dir, _ := os.LookupEnv(githubCacheDir)
+		if dir == "" {
+			return nil, fmt.Errorf("Missing environment variable %s required by the %s cache", githubCacheDir, diskCacheBackend)
+		}
+
+		maxBytes, err := cacheMaxBytesFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		return newDiskCache(dir, maxBytes)
+
+	default:
+		return nil, fmt.Errorf("Invalid value %s for environment variable %s: expected one of %s, %s or %s", backend, githubCacheBackend, memoryCacheBackend, lruCacheBackend, diskCacheBackend)
+	}
+}
+
+// cacheMaxBytesFromEnv returns the maximum size of bounded caches, which
+// defaults to defaultCacheMaxBytes.
+func cacheMaxBytesFromEnv() (int64, error) {
+	value, ok := os.LookupEnv(githubCacheMaxBytes)
+	if !ok || value == "" {
+		return defaultCacheMaxBytes, nil
+	}
+
+	maxBytes, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || maxBytes <= 0 {
+		return 0, fmt.Errorf("Invalid value %s for environment variable %s: expected a positive number of bytes", value, githubCacheMaxBytes)
+	}
+	return maxBytes, nil
+}
+
+// lruCache implements httpcache.Cache by keeping responses in memory up to a
+// maximum size, evicting the least recently used ones first.
+type lruCache struct {
+	mutex    sync.Mutex
+	entries  *simplelru.LRU
+	size     int64
+	maxBytes int64
+}
+
+// newLRUCache returns a new lruCache holding up to maxBytes of responses.
+func newLRUCache(maxBytes int64) *lruCache {
+	cache := &lruCache{maxBytes: maxBytes}
+
+	// The size is bounded in bytes rather than in entries.
+	cache.entries, _ = simplelru.NewLRU(math.MaxInt32, func(key, value interface{}) {
+		cache.size -= int64(len(value.([]byte)))
+	})
+	return cache
+}
+
+// Get implements httpcache.Cache.
+func (l *lruCache) Get(key string) ([]byte, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if value, ok := l.entries.Get(key); ok {
+		return value.([]byte), true
+	}
+	return nil, false
+}
+
+// Set implements httpcache.Cache.
+func (l *lruCache) Set(key string, response []byte) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.entries.Remove(key)
+	if int64(len(response)) > l.maxBytes {
+		return
+	}
+
+	l.entries.Add(key, response)
+	l.size += int64(len(response))
+
+	for l.size > l.maxBytes {
+		l.entries.RemoveOldest()
+	}
+}
+
+// Delete implements httpcache.Cache.
+func (l *lruCache) Delete(key string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.entries.Remove(key)
+}
+
+// diskCache implements httpcache.Cache by storing each response in a file
+// named after the hash of its key. Files are replaced atomically, so several
+// processes can share the same directory.
+// The directory is kept under a maximum size by evicting the least recently
+// used responses, as told by the modification times of files, which are
+// updated when responses are read. Every process sharing the directory takes
+// part in evicting responses, so no external cleanup is needed.
+type diskCache struct {
+	dir      string
+	maxBytes int64
+	now      func() time.Time
+
+	mutex sync.Mutex
+	// size estimates how many bytes the directory holds. It's brought up to
+	// date whenever responses are evicted, which accounts for responses
+	// stored by other processes.
+	size int64
+}
+
+// newDiskCache returns a new diskCache storing up to maxBytes of responses in
+// the supplied directory, which is created if it doesn't exist.
+func newDiskCache(dir string, maxBytes int64) (*diskCache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("Error creating the cache directory %s: %w", dir, err)
+	}
+
+	cache := &diskCache{dir: dir, maxBytes: maxBytes, now: time.Now}
+	if err := cache.evict(); err != nil {
+		return nil, fmt.Errorf("Error evicting responses from the cache directory %s: %w", dir, err)
+	}
+	return cache, nil
+}
+
+// path returns the path of the file storing the response for the supplied key.
+func (d *diskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(d.dir, hex.EncodeToString(sum[:]))
+}
+
+// Get implements httpcache.Cache.
+func (d *diskCache) Get(key string) ([]byte, bool) {
+	path := d.path(key)
+	response, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	// Mark the response as recently used.
+	now := d.now()
+	os.Chtimes(path, now, now)
+	return response, true
+}
+
+// Set implements httpcache.Cache. Responses that can't be stored are simply
+// fetched again next time.
+func (d *diskCache) Set(key string, response []byte) {
+	if int64(len(response)) > d.maxBytes {
+		d.Delete(key)
+		return
+	}
+
+	file, err := ioutil.TempFile(d.dir, tempFilePrefix)
+	if err != nil {
+		return
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(response)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil || os.Rename(file.Name(), d.path(key)) != nil {
+		return
+	}
+
+	d.mutex.Lock()
+	d.size += int64(len(response))
+	full := d.size > d.maxBytes
+	d.mutex.Unlock()
+
+	if full {
+		d.evict()
+	}
+}
+
+// Delete implements httpcache.Cache.
+func (d *diskCache) Delete(key string) {
+	os.Remove(d.path(key))
+}
+
+// tempFilePrefix is the prefix of files responses are written to before they
+// replace the stored ones.
+const tempFilePrefix = ".tmp-"
+
+// evict removes the least recently used responses until the directory holds
+// no more than maxBytes. Responses removed by other processes in the meantime
+// are skipped.
+func (d *diskCache) evict() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	files, err := ioutil.ReadDir(d.dir)
+	if err != nil {
+		return err
+	}
+
+	responses := make([]os.FileInfo, 0, len(files))
+	size := int64(0)
+	for _, file := range files {
+		if file.IsDir() || strings.HasPrefix(file.Name(), tempFilePrefix) {
+			continue
+		}
+		responses = append(responses, file)
+		size += file.Size()
+	}
+
+	sort.Slice(responses, func(i, j int) bool {
+		return responses[i].ModTime().Before(responses[j].ModTime())
+	})
+
+	for _, response := range responses {
+		if size <= d.maxBytes {
+			break
+		}
+
+		if err := os.Remove(filepath.Join(d.dir, response.Name())); err == nil || os.IsNotExist(err) {
+			size -= response.Size()
+		}
+	}
+
+	d.size = size
+	return nil
+}
+
+// prefixedCache scopes keys of a shared cache to a single installation (or
+// token). Github marks responses as fresh for a while, so they'd otherwise be
+// served to clients authenticated with other credentials without reaching
+// Github.
+type prefixedCache struct {
+	cache  httpcache.Cache
+	prefix string
+}
+
+// Get implements httpcache.Cache.
+func (p *prefixedCache) Get(key string) ([]byte, bool) {
+	return p.cache.Get(p.prefix + key)
+}
+
+// Set implements httpcache.Cache.
+func (p *prefixedCache) Set(key string, response []byte) {
+	p.cache.Set(p.prefix+key, response)
+}
+
+// Delete implements httpcache.Cache.
+func (p *prefixedCache) Delete(key string) {
+	p.cache.Delete(p.prefix + key)
+}
+
+type cacheProbeKey struct {
+}
+
+// cacheProbe records whether a request went past the cache and what status
+// Github answered with.
+type cacheProbe struct {
+	reachedGithub bool
+	statusCode    int
+}
+
+// withCacheProbe returns a copy of the supplied context carrying the probe.
+func withCacheProbe(ctx context.Context, probe *cacheProbe) context.Context {
+	return context.WithValue(ctx, cacheProbeKey{}, probe)
+}
+
+// result tells whether the request was served from the cache (hit),
+// revalidated with Github or fetched from it (miss).
+func (c *cacheProbe) result() string {
+	switch {
+	case !c.reachedGithub:
+		return "hit"
+	case c.statusCode == http.StatusNotModified:
+		return "revalidated"
+	default:
+		return "miss"
+	}
+}
+
+// cacheMetricsTransport counts requests served from the cache, revalidated
+// with Github or fetched from it.
+type cacheMetricsTransport struct {
+	transport    http.RoundTripper
+	installation string
+}
+
+// RoundTrip implements http.RoundTripper.
+func (c *cacheMetricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		// Only reads can be cached.
+		return c.transport.RoundTrip(req)
+	}
+
+	probe := &cacheProbe{}
+	response, err := c.transport.RoundTrip(req.WithContext(withCacheProbe(req.Context(), probe)))
+	if err != nil {
+		return nil, err
+	}
+
+	if ctx, err := tag.New(req.Context(), tag.Upsert(installationKey, c.installation), tag.Upsert(cacheResultKey, probe.result())); err == nil {
+		metrics.Record(ctx, cacheRequestsM.M(1))
+	}
+	return response, nil
+}
+
+// cacheProbeTransport fills the cacheProbe carried by requests that went past
+// the cache.
+type cacheProbeTransport struct {
+	transport http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (c *cacheProbeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	response, err := c.transport.RoundTrip(req)
+
+	if probe, ok := req.Context().Value(cacheProbeKey{}).(*cacheProbe); ok {
+		probe.reachedGithub = true
+		if response != nil {
+			probe.statusCode = response.StatusCode
+		}
+	}
+	return response, err
+}
+
+// newCachingTransport returns a transport that caches responses of the
+// supplied installation in its own slice of the shared cache, sending
+// conditional requests through the transport in question.
+func newCachingTransport(transport http.RoundTripper, cache httpcache.Cache, installation string) http.RoundTripper {
+	cachingTransport := httpcache.NewTransport(&prefixedCache{cache: cache, prefix: installation + "/"})
+	cachingTransport.Transport = &cacheProbeTransport{transport: transport}
+
+	return &cacheMetricsTransport{transport: cachingTransport, installation: installation}
+}
@@ -0,0 +1,274 @@
+package github
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gregjones/httpcache"
+)
+
+func TestNewCacheFromEnv(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cache")
+
+	tests := []struct {
+		name string
+		vars map[string]string
+		want interface{}
+	}{
+		{
+			name: "memory cache by default",
+			vars: map[string]string{},
+			want: &httpcache.MemoryCache{},
+		},
+		{
+			name: "lru cache",
+			vars: map[string]string{githubCacheBackend: "lru", githubCacheMaxBytes: "1024"},
+			want: &lruCache{},
+		},
+		{
+			name: "disk cache",
+			vars: map[string]string{githubCacheBackend: "disk", githubCacheDir: dir, githubCacheMaxBytes: "1024"},
+			want: &diskCache{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setEnv(t, test.vars)
+
+			cache, err := NewCacheFromEnv()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			switch test.want.(type) {
+			case *httpcache.MemoryCache:
+				if _, ok := cache.(*httpcache.MemoryCache); !ok {
+					t.Errorf("Want a memory cache, got %T", cache)
+				}
+			case *lruCache:
+				if lru, ok := cache.(*lruCache); !ok || lru.maxBytes != 1024 {
+					t.Errorf("Want a lru cache holding up to 1024 bytes, got %#v", cache)
+				}
+			case *diskCache:
+				if disk, ok := cache.(*diskCache); !ok || disk.dir != dir || disk.maxBytes != 1024 {
+					t.Errorf("Want a disk cache storing up to 1024 bytes in %s, got %#v", dir, cache)
+				}
+			}
+		})
+	}
+}
+
+func TestNewCacheFromEnvRejectsInvalidValues(t *testing.T) {
+	tests := []map[string]string{
+		{githubCacheBackend: "redis"},
+		{githubCacheBackend: "lru", githubCacheMaxBytes: "lots"},
+		{githubCacheBackend: "lru", githubCacheMaxBytes: "0"},
+		{githubCacheBackend: "disk"},
+		{githubCacheBackend: "disk", githubCacheDir: "/var/cache/github", githubCacheMaxBytes: "-1"},
+	}
+
+	for _, vars := range tests {
+		setEnv(t, vars)
+
+		if _, err := NewCacheFromEnv(); err == nil {
+			t.Errorf("Want an error for %v", vars)
+		}
+	}
+}
+
+func TestLRUCacheEvictsLeastRecentlyUsedResponses(t *testing.T) {
+	cache := newLRUCache(10)
+
+	cache.Set("a", []byte("1234"))
+	cache.Set("b", []byte("1234"))
+
+	// Reading a makes b the least recently used response.
+	if _, ok := cache.Get("a"); !ok {
+		t.Fatal("Want a to be cached")
+	}
+
+	cache.Set("c", []byte("1234"))
+
+	if _, ok := cache.Get("b"); ok {
+		t.Error("Want b to be evicted")
+	}
+
+	for _, key := range []string{"a", "c"} {
+		if _, ok := cache.Get(key); !ok {
+			t.Errorf("Want %s to be cached", key)
+		}
+	}
+
+	// Responses larger than the cache aren't stored at all.
+	cache.Set("d", []byte("12345678901"))
+	if _, ok := cache.Get("d"); ok {
+		t.Error("Want d not to be cached")
+	}
+
+	if cache.size != 8 {
+		t.Errorf("Want a size of 8 bytes, got %d", cache.size)
+	}
+}
+
+func TestDiskCache(t *testing.T) {
+	dir := t.TempDir()
+
+	first, err := newDiskCache(dir, defaultCacheMaxBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Caches sharing the directory (e.g. other replicas) see the same responses.
+	second, err := newDiskCache(dir, defaultCacheMaxBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first.Set("https://api.github.com/repos/nubank/workflows", []byte("response"))
+
+	got, ok := second.Get("https://api.github.com/repos/nubank/workflows")
+	if !ok || !bytes.Equal(got, []byte("response")) {
+		t.Errorf("Want the cached response, got %q", got)
+	}
+
+	second.Delete("https://api.github.com/repos/nubank/workflows")
+
+	if _, ok := first.Get("https://api.github.com/repos/nubank/workflows"); ok {
+		t.Error("Want the response to be deleted")
+	}
+}
+
+func TestDiskCacheEvictsLeastRecentlyUsedResponses(t *testing.T) {
+	dir := t.TempDir()
+
+	cache, err := newDiskCache(dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2021, 1, 20, 10, 0, 0, 0, time.UTC)
+	set := func(key, response string) {
+		now = now.Add(time.Minute)
+		cache.Set(key, []byte(response))
+		os.Chtimes(cache.path(key), now, now)
+	}
+
+	set("a", "1234")
+	set("b", "1234")
+
+	// Reading a marks it as recently used, so b is evicted instead.
+	now = now.Add(time.Minute)
+	cache.now = func() time.Time { return now }
+	if _, ok := cache.Get("a"); !ok {
+		t.Fatal("Want response a to be cached")
+	}
+
+	set("c", "1234")
+
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true} {
+		if _, ok := cache.Get(key); ok != want {
+			t.Errorf("Want response %s cached: %t, got %t", key, want, ok)
+		}
+	}
+
+	// Responses larger than the cache aren't stored.
+	cache.Set("d", []byte("12345678901"))
+	if _, ok := cache.Get("d"); ok {
+		t.Error("Want response d to be left out")
+	}
+
+	// Responses left by other processes count as well.
+	if err := ioutil.WriteFile(filepath.Join(dir, "other"), []byte("123456789"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(filepath.Join(dir, "other"), now.Add(-time.Hour), now.Add(-time.Hour))
+
+	if _, err := newDiskCache(dir, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "other")); !os.IsNotExist(err) {
+		t.Errorf("Want the oldest response to be evicted, got %v", err)
+	}
+}
+
+func TestPrefixedCacheIsolatesInstallations(t *testing.T) {
+	shared := httpcache.NewMemoryCache()
+	first := &prefixedCache{cache: shared, prefix: "1/"}
+	second := &prefixedCache{cache: shared, prefix: "2/"}
+
+	first.Set("key", []byte("response"))
+
+	if _, ok := second.Get("key"); ok {
+		t.Error("Want responses cached for an installation to be hidden from others")
+	}
+
+	if _, ok := first.Get("key"); !ok {
+		t.Error("Want the response to be cached")
+	}
+}
+
+func TestCachingTransportClassifiesRequests(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path == "/fresh" {
+			w.Header().Set("Cache-Control", "max-age=60")
+		} else {
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+		w.Header().Set("ETag", `"v1"`)
+
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte("response"))
+	}))
+	t.Cleanup(server.Close)
+
+	// Requests go through the caching transport directly so that the probe
+	// can be inspected.
+	metricsTransport := newCachingTransport(http.DefaultTransport, httpcache.NewMemoryCache(), "42").(*cacheMetricsTransport)
+
+	tests := []struct {
+		path      string
+		want      string
+		wantCalls int
+	}{
+		{path: "/fresh", want: "miss", wantCalls: 1},
+		{path: "/fresh", want: "hit", wantCalls: 1},
+		{path: "/stale", want: "miss", wantCalls: 2},
+		{path: "/stale", want: "revalidated", wantCalls: 3},
+	}
+
+	for _, test := range tests {
+		probe := &cacheProbe{}
+		req := httptest.NewRequest(http.MethodGet, server.URL+test.path, nil)
+		req.RequestURI = ""
+		req = req.WithContext(withCacheProbe(req.Context(), probe))
+
+		response, err := metricsTransport.transport.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		// Responses are only cached once their body has been read.
+		ioutil.ReadAll(response.Body)
+		response.Body.Close()
+
+		if got := probe.result(); got != test.want {
+			t.Errorf("Want %s for %s, got %s", test.want, test.path, got)
+		}
+
+		if requests != test.wantCalls {
+			t.Errorf("Want %d requests to Github after %s, got %d", test.wantCalls, test.path, requests)
+		}
+	}
+}
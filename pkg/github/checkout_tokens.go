@@ -0,0 +1,91 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/go-github/v33/github"
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	"knative.dev/pkg/logging"
+)
+
+// CheckoutToken is a short-lived Github App installation token that grants
+// checkout steps access to the repositories of a workflow.
+type CheckoutToken struct {
+	Token     string
+	ExpiresAt time.Time
+}
+
+// CheckoutTokenIssuer issues tokens that checkout steps clone repositories
+// with, in place of deploy keys.
+type CheckoutTokenIssuer interface {
+
+	// IssueCheckoutToken returns a new token restricted to the repositories
+	// of the supplied workflow. It grants write access to the contents of
+	// repositories when any of them declares a writable deploy key.
+	IssueCheckoutToken(ctx context.Context, workflow *workflowsv1alpha1.Workflow) (*CheckoutToken, error)
+}
+
+// installationTokenService creates installation tokens for the installation of
+// the Github App on the account that owns a repository.
+type installationTokenService interface {
+	CreateInstallationToken(ctx context.Context, owner, repo string, opts *github.InstallationTokenOptions) (*github.InstallationToken, *github.Response, error)
+}
+
+// defaultCheckoutTokenIssuer implements CheckoutTokenIssuer.
+type defaultCheckoutTokenIssuer struct {
+	repositories repositoriesService
+	tokens       installationTokenService
+}
+
+// IssueCheckoutToken implements CheckoutTokenIssuer.
+func (d *defaultCheckoutTokenIssuer) IssueCheckoutToken(ctx context.Context, workflow *workflowsv1alpha1.Workflow) (*CheckoutToken, error) {
+	if d.tokens == nil {
+		return nil, errors.New("Installation tokens can only be issued when authenticating as a Github App")
+	}
+
+	opts := &github.InstallationTokenOptions{
+		Permissions: &github.InstallationPermissions{
+			Contents: github.String("read"),
+			Metadata: github.String("read"),
+		},
+	}
+
+	// Go-github only restricts tokens to repositories by their ids.
+	for _, repo := range workflow.GetRepositories() {
+		repository, _, err := d.repositories.Get(ctx, repo.Owner, repo.Name)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading repository %s: %w", repo.String(), err)
+		}
+		opts.RepositoryIDs = append(opts.RepositoryIDs, repository.GetID())
+
+		if !repo.IsReadOnlyDeployKey() {
+			opts.Permissions.Contents = github.String("write")
+		}
+	}
+
+	repo := workflow.Spec.Repository
+	token, _, err := d.tokens.CreateInstallationToken(ctx, repo.Owner, repo.Name, opts)
+	if err != nil {
+		return nil, fmt.Errorf("Error issuing an installation token for workflow %s/%s: %w", workflow.GetNamespace(), workflow.GetName(), err)
+	}
+
+	logger := logging.FromContext(ctx)
+	logger.Infow("Installation token has been successfully issued for checking out repositories",
+		"contents", opts.Permissions.GetContents(),
+		"expires-at", token.GetExpiresAt())
+
+	return &CheckoutToken{Token: token.GetToken(), ExpiresAt: token.GetExpiresAt()}, nil
+}
+
+// NewCheckoutTokenIssuer returns a CheckoutTokenIssuer that issues tokens
+// through the Github App clients handed out by the supplied factory are
+// authenticated as.
+func NewCheckoutTokenIssuer(clients ClientFactory) CheckoutTokenIssuer {
+	tokens, _ := clients.(installationTokenService)
+	return &defaultCheckoutTokenIssuer{repositories: &repositoriesServices{clients: clients},
+		tokens: tokens,
+	}
+}
@@ -0,0 +1,102 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/go-github/v33/github"
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	githubmocks "github.com/nubank/workflows/pkg/github/mocks"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIssueCheckoutToken(t *testing.T) {
+	expiresAt := time.Date(2021, 1, 1, 1, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		deployKey    *workflowsv1alpha1.DeployKey
+		wantContents string
+	}{
+		{
+			name:         "read-only access",
+			wantContents: "read",
+		},
+		{
+			name:         "write access",
+			deployKey:    &workflowsv1alpha1.DeployKey{ReadOnly: false},
+			wantContents: "write",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			repositoriesService := githubmocks.NewMockrepositoriesService(mockCtrl)
+			appsService := githubmocks.NewMockappsService(mockCtrl)
+			ctx := context.Background()
+
+			tokens := newInstallationClientFactory(appsService, nil)
+			issuer := &defaultCheckoutTokenIssuer{repositories: repositoriesService, tokens: tokens}
+
+			workflow := &workflowsv1alpha1.Workflow{
+				ObjectMeta: metav1.ObjectMeta{Name: "ci", Namespace: "dev"},
+				Spec: workflowsv1alpha1.WorkflowSpec{
+					Repository: &workflowsv1alpha1.Repository{Owner: "nubank", Name: "my-repo", Private: true},
+					AdditionalRepositories: []workflowsv1alpha1.Repository{
+						{Owner: "nubank", Name: "my-lib", DeployKey: test.deployKey},
+					},
+				},
+			}
+
+			// Mock setup
+			repositoriesService.EXPECT().
+				Get(ctx, "nubank", "my-repo").
+				Return(&github.Repository{ID: github.Int64(1)}, nil, nil)
+
+			repositoriesService.EXPECT().
+				Get(ctx, "nubank", "my-lib").
+				Return(&github.Repository{ID: github.Int64(2)}, nil, nil)
+
+			appsService.EXPECT().
+				FindRepositoryInstallation(ctx, "nubank", "my-repo").
+				Return(&github.Installation{ID: github.Int64(42)}, &github.Response{Response: &http.Response{StatusCode: 200}}, nil)
+
+			appsService.EXPECT().
+				CreateInstallationToken(ctx, int64(42), &github.InstallationTokenOptions{
+					RepositoryIDs: []int64{1, 2},
+					Permissions: &github.InstallationPermissions{
+						Contents: github.String(test.wantContents),
+						Metadata: github.String("read"),
+					},
+				}).
+				Return(&github.InstallationToken{Token: github.String("ghs_abc"), ExpiresAt: &expiresAt}, nil, nil)
+
+			got, err := issuer.IssueCheckoutToken(ctx, workflow)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if got.Token != "ghs_abc" || !got.ExpiresAt.Equal(expiresAt) {
+				t.Errorf("Want token ghs_abc expiring at %s, got %+v", expiresAt, got)
+			}
+		})
+	}
+}
+
+func TestIssueCheckoutTokenRequiresAGithubApp(t *testing.T) {
+	issuer := NewCheckoutTokenIssuer(NewStaticClientFactory(github.NewClient(nil)))
+
+	workflow := &workflowsv1alpha1.Workflow{
+		Spec: workflowsv1alpha1.WorkflowSpec{
+			Repository: &workflowsv1alpha1.Repository{Owner: "nubank", Name: "my-repo"},
+		},
+	}
+
+	if _, err := issuer.IssueCheckoutToken(context.Background(), workflow); err == nil {
+		t.Error("Want an error when clients aren't authenticated as a Github App")
+	}
+}
@@ -0,0 +1,257 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/go-github/v33/github"
+	"knative.dev/pkg/logging"
+)
+
+// Possible statuses of Github check runs.
+const (
+	CheckRunQueued     = "queued"
+	CheckRunInProgress = "in_progress"
+	CheckRunCompleted  = "completed"
+)
+
+// Possible conclusions of completed Github check runs.
+const (
+	CheckRunSuccess   = "success"
+	CheckRunFailure   = "failure"
+	CheckRunCancelled = "cancelled"
+	CheckRunTimedOut  = "timed_out"
+)
+
+// Possible levels of check run annotations.
+const (
+	CheckRunAnnotationNotice  = "notice"
+	CheckRunAnnotationWarning = "warning"
+	CheckRunAnnotationFailure = "failure"
+)
+
+// MaxCheckRunAnnotations is the maximum number of annotations Github accepts
+// per request.
+const MaxCheckRunAnnotations = 50
+
+// MaxCheckRunTextLength is the maximum number of characters Github accepts in
+// the text of check run outputs.
+const MaxCheckRunTextLength = 65535
+
+// CheckRun represents the desired state of a Github check run.
+type CheckRun struct {
+
+	// ID of the check run or 0 if it hasn't been created yet.
+	ID int64
+
+	// Owner and Repo identify the repository the check run belongs to.
+	Owner string
+	Repo  string
+
+	// Name of the check.
+	Name string
+
+	// HeadSHA is the commit the check run refers to.
+	HeadSHA string
+
+	// ExternalID is a reference to the check run in our system.
+	ExternalID string
+
+	// DetailsURL links to the full details of the check (e.g. its logs).
+	DetailsURL string
+
+	// Status is one of queued, in_progress or completed.
+	Status string
+
+	// Conclusion is only set when the check run is completed.
+	Conclusion string
+
+	StartedAt   *time.Time
+	CompletedAt *time.Time
+
+	// Title, Summary and Text make up the check run's output. Summary and
+	// Text support Markdown.
+	Title   string
+	Summary string
+	Text    string
+
+	// Annotations point at specific lines of files. Github appends them to
+	// those already reported, so they're best sent along with the final
+	// state of the check run.
+	Annotations []CheckRunAnnotation
+}
+
+// CheckRunAnnotation describes an issue found at specific lines of a file.
+type CheckRunAnnotation struct {
+
+	// Path of the file, relative to the repository's root.
+	Path string
+
+	StartLine int
+	EndLine   int
+
+	// Level is one of notice, warning or failure.
+	Level string
+
+	Title   string
+	Message string
+}
+
+// CheckRunReconciler keeps Github check runs in sync with the progress of
+// workflows.
+type CheckRunReconciler interface {
+
+	// ReconcileCheckRun creates the supplied check run if it doesn't exist
+	// yet or updates it otherwise. Check runs without an ID are looked up by
+	// their external ID before being created, so they're never reported
+	// twice. Returns the ID of the check run.
+	ReconcileCheckRun(ctx context.Context, checkRun *CheckRun) (int64, error)
+}
+
+// defaultCheckRunReconciler implements CheckRunReconciler.
+type defaultCheckRunReconciler struct {
+	service checksService
+}
+
+// ReconcileCheckRun implements CheckRunReconciler.ReconcileCheckRun.
+func (c *defaultCheckRunReconciler) ReconcileCheckRun(ctx context.Context, checkRun *CheckRun) (int64, error) {
+	logger := logging.FromContext(ctx).With("check-run", checkRun.Name)
+
+	output := &github.CheckRunOutput{
+		Title:   github.String(checkRun.Title),
+		Summary: github.String(checkRun.Summary),
+	}
+	if checkRun.Text != "" {
+		output.Text = github.String(checkRun.Text)
+	}
+
+	for _, annotation := range checkRun.Annotations {
+		output.Annotations = append(output.Annotations, &github.CheckRunAnnotation{
+			Path:            github.String(annotation.Path),
+			StartLine:       github.Int(annotation.StartLine),
+			EndLine:         github.Int(annotation.EndLine),
+			AnnotationLevel: github.String(annotation.Level),
+			Title:           optionalString(annotation.Title),
+			Message:         github.String(annotation.Message),
+		})
+	}
+
+	id := checkRun.ID
+	if id == 0 && checkRun.ExternalID != "" {
+		var err error
+		if id, err = c.findCheckRun(ctx, checkRun); err != nil {
+			return 0, err
+		}
+	}
+
+	if id == 0 {
+		opts := github.CreateCheckRunOptions{
+			Name:        checkRun.Name,
+			HeadSHA:     checkRun.HeadSHA,
+			ExternalID:  optionalString(checkRun.ExternalID),
+			DetailsURL:  optionalString(checkRun.DetailsURL),
+			Status:      github.String(checkRun.Status),
+			Conclusion:  optionalString(checkRun.Conclusion),
+			StartedAt:   optionalTimestamp(checkRun.StartedAt),
+			CompletedAt: optionalTimestamp(checkRun.CompletedAt),
+			Output:      output,
+		}
+
+		createdCheckRun, _, err := c.service.CreateCheckRun(ctx, checkRun.Owner, checkRun.Repo, opts)
+		if err != nil {
+			return 0, fmt.Errorf("Error creating check run %s on %s/%s@%s: %w", checkRun.Name, checkRun.Owner, checkRun.Repo, checkRun.HeadSHA, err)
+		}
+
+		logger.Infow("Check run has been successfully created", "check-run-id", createdCheckRun.GetID())
+		return createdCheckRun.GetID(), nil
+	}
+
+	opts := github.UpdateCheckRunOptions{
+		Name:        checkRun.Name,
+		ExternalID:  optionalString(checkRun.ExternalID),
+		DetailsURL:  optionalString(checkRun.DetailsURL),
+		Status:      github.String(checkRun.Status),
+		Conclusion:  optionalString(checkRun.Conclusion),
+		CompletedAt: optionalTimestamp(checkRun.CompletedAt),
+		Output:      output,
+	}
+
+	if _, _, err := c.service.UpdateCheckRun(ctx, checkRun.Owner, checkRun.Repo, id, opts); err != nil {
+		return 0, fmt.Errorf("Error updating check run %d on %s/%s: %w", id, checkRun.Owner, checkRun.Repo, err)
+	}
+
+	logger.Infow("Check run has been successfully updated", "check-run-id", id)
+	return id, nil
+}
+
+// findCheckRun returns the ID of the check run reported for the same commit,
+// with the same name and external ID as the supplied one, or 0 if there's
+// none. It covers check runs whose ID couldn't be recorded after they were
+// created.
+func (c *defaultCheckRunReconciler) findCheckRun(ctx context.Context, checkRun *CheckRun) (int64, error) {
+	opts := &github.ListCheckRunsOptions{
+		CheckName:   github.String(checkRun.Name),
+		Filter:      github.String("all"),
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+
+	for {
+		results, response, err := c.service.ListCheckRunsForRef(ctx, checkRun.Owner, checkRun.Repo, checkRun.HeadSHA, opts)
+		if err != nil {
+			return 0, fmt.Errorf("Error listing check runs %s on %s/%s@%s: %w", checkRun.Name, checkRun.Owner, checkRun.Repo, checkRun.HeadSHA, err)
+		}
+
+		for _, existing := range results.CheckRuns {
+			if existing.GetExternalID() == checkRun.ExternalID {
+				return existing.GetID(), nil
+			}
+		}
+
+		if response == nil || response.NextPage == 0 {
+			return 0, nil
+		}
+		opts.Page = response.NextPage
+	}
+}
+
+// optionalString returns a pointer to the supplied string or nil if it's
+// empty.
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return github.String(s)
+}
+
+// optionalTimestamp converts the supplied time to a Github timestamp or
+// returns nil if it isn't set.
+func optionalTimestamp(t *time.Time) *github.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return &github.Timestamp{Time: *t}
+}
+
+// checkRunReconcilerKey is used to store CheckRunReconciler objects into
+// context.Context.
+type checkRunReconcilerKey struct {
+}
+
+// WithCheckRunReconciler returns a copy of the supplied context with a new
+// CheckRunReconciler object added.
+func WithCheckRunReconciler(ctx context.Context, clients ClientFactory) context.Context {
+	return context.WithValue(ctx, checkRunReconcilerKey{}, &defaultCheckRunReconciler{service: &checksServices{clients: clients}})
+}
+
+// GetCheckRunReconcilerOrDie returns a CheckRunReconciler instance from the
+// supplied context or dies by calling log.fatal if the context doesn't contain
+// a CheckRunReconciler object.
+func GetCheckRunReconcilerOrDie(ctx context.Context) CheckRunReconciler {
+	if checkRunReconciler, ok := ctx.Value(checkRunReconcilerKey{}).(CheckRunReconciler); ok {
+		return checkRunReconciler
+	}
+	log.Fatal("Unable to get a valid CheckRunReconciler instance from context")
+	return nil
+}
@@ -0,0 +1,226 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/go-github/v33/github"
+	githubmocks "github.com/nubank/workflows/pkg/github/mocks"
+)
+
+func TestCreateCheckRun(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	checksService := githubmocks.NewMockchecksService(mockCtrl)
+	reconciler := &defaultCheckRunReconciler{service: checksService}
+
+	startedAt := time.Date(2021, 1, 20, 10, 0, 0, 0, time.UTC)
+	checkRun := &CheckRun{Owner: "john-doe",
+		Repo:       "my-repo",
+		Name:       "ci",
+		HeadSHA:    "833568e",
+		DetailsURL: "https://hooks.example.com/logs",
+		Status:     CheckRunInProgress,
+		StartedAt:  &startedAt,
+		Title:      "0 of 2 tasks completed",
+		Summary:    "Tasks are running",
+	}
+
+	ctx := context.Background()
+
+	// Mock setup
+	checksService.EXPECT().
+		CreateCheckRun(ctx, "john-doe", "my-repo", github.CreateCheckRunOptions{
+			Name:       "ci",
+			HeadSHA:    "833568e",
+			DetailsURL: github.String("https://hooks.example.com/logs"),
+			Status:     github.String(CheckRunInProgress),
+			StartedAt:  &github.Timestamp{Time: startedAt},
+			Output: &github.CheckRunOutput{
+				Title:   github.String("0 of 2 tasks completed"),
+				Summary: github.String("Tasks are running"),
+			},
+		}).
+		Return(&github.CheckRun{ID: github.Int64(42)}, nil, nil)
+
+	id, err := reconciler.ReconcileCheckRun(ctx, checkRun)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if id != 42 {
+		t.Errorf("Want check run id 42, got %d", id)
+	}
+}
+
+func TestUpdateCheckRun(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	checksService := githubmocks.NewMockchecksService(mockCtrl)
+	reconciler := &defaultCheckRunReconciler{service: checksService}
+
+	completedAt := time.Date(2021, 1, 20, 10, 5, 0, 0, time.UTC)
+	checkRun := &CheckRun{ID: 42,
+		Owner:       "john-doe",
+		Repo:        "my-repo",
+		Name:        "ci",
+		HeadSHA:     "833568e",
+		Status:      CheckRunCompleted,
+		Conclusion:  CheckRunSuccess,
+		CompletedAt: &completedAt,
+		Title:       "Workflow succeeded",
+		Summary:     "All tasks completed successfully",
+		Text:        "| Task | Status |",
+		Annotations: []CheckRunAnnotation{{Path: "pkg/parser_test.go",
+			StartLine: 27,
+			EndLine:   27,
+			Level:     CheckRunAnnotationFailure,
+			Title:     "TestParse",
+			Message:   "expected 2, got 1",
+		}},
+	}
+
+	ctx := context.Background()
+
+	// Mock setup
+	checksService.EXPECT().
+		UpdateCheckRun(ctx, "john-doe", "my-repo", int64(42), github.UpdateCheckRunOptions{
+			Name:        "ci",
+			Status:      github.String(CheckRunCompleted),
+			Conclusion:  github.String(CheckRunSuccess),
+			CompletedAt: &github.Timestamp{Time: completedAt},
+			Output: &github.CheckRunOutput{
+				Title:   github.String("Workflow succeeded"),
+				Summary: github.String("All tasks completed successfully"),
+				Text:    github.String("| Task | Status |"),
+				Annotations: []*github.CheckRunAnnotation{{Path: github.String("pkg/parser_test.go"),
+					StartLine:       github.Int(27),
+					EndLine:         github.Int(27),
+					AnnotationLevel: github.String("failure"),
+					Title:           github.String("TestParse"),
+					Message:         github.String("expected 2, got 1"),
+				}},
+			},
+		}).
+		Return(&github.CheckRun{ID: github.Int64(42)}, nil, nil)
+
+	id, err := reconciler.ReconcileCheckRun(ctx, checkRun)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if id != 42 {
+		t.Errorf("Want check run id 42, got %d", id)
+	}
+}
+
+func TestReconcileCheckRunUpdatesTheCheckRunWithTheSameExternalID(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	checksService := githubmocks.NewMockchecksService(mockCtrl)
+	reconciler := &defaultCheckRunReconciler{service: checksService}
+
+	// The ID of the check run wasn't recorded when it was created.
+	checkRun := &CheckRun{Owner: "john-doe",
+		Repo:       "my-repo",
+		Name:       "ci",
+		HeadSHA:    "833568e",
+		ExternalID: "dev/ci-run-abc12",
+		Status:     CheckRunInProgress,
+		Title:      "1 of 2 tasks completed",
+		Summary:    "Tasks are running",
+	}
+
+	ctx := context.Background()
+
+	// Mock setup
+	checksService.EXPECT().
+		ListCheckRunsForRef(ctx, "john-doe", "my-repo", "833568e", &github.ListCheckRunsOptions{
+			CheckName:   github.String("ci"),
+			Filter:      github.String("all"),
+			ListOptions: github.ListOptions{PerPage: 100},
+		}).
+		Return(&github.ListCheckRunsResults{CheckRuns: []*github.CheckRun{
+			{ID: github.Int64(41), ExternalID: github.String("dev/ci-run-xyz89")},
+		}}, &github.Response{NextPage: 2}, nil)
+
+	checksService.EXPECT().
+		ListCheckRunsForRef(ctx, "john-doe", "my-repo", "833568e", &github.ListCheckRunsOptions{
+			CheckName:   github.String("ci"),
+			Filter:      github.String("all"),
+			ListOptions: github.ListOptions{Page: 2, PerPage: 100},
+		}).
+		Return(&github.ListCheckRunsResults{CheckRuns: []*github.CheckRun{
+			{ID: github.Int64(42), ExternalID: github.String("dev/ci-run-abc12")},
+		}}, &github.Response{}, nil)
+
+	checksService.EXPECT().
+		UpdateCheckRun(ctx, "john-doe", "my-repo", int64(42), gomock.Any()).
+		Return(&github.CheckRun{ID: github.Int64(42)}, nil, nil)
+
+	id, err := reconciler.ReconcileCheckRun(ctx, checkRun)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if id != 42 {
+		t.Errorf("Want check run id 42, got %d", id)
+	}
+}
+
+func TestReconcileCheckRunCreatesCheckRunsNotFoundByExternalID(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	checksService := githubmocks.NewMockchecksService(mockCtrl)
+	reconciler := &defaultCheckRunReconciler{service: checksService}
+
+	ctx := context.Background()
+
+	// Mock setup
+	checksService.EXPECT().
+		ListCheckRunsForRef(ctx, "john-doe", "my-repo", "833568e", gomock.Any()).
+		Return(&github.ListCheckRunsResults{}, &github.Response{}, nil)
+
+	checksService.EXPECT().
+		CreateCheckRun(ctx, "john-doe", "my-repo", gomock.Any()).
+		Return(&github.CheckRun{ID: github.Int64(42)}, nil, nil)
+
+	id, err := reconciler.ReconcileCheckRun(ctx, &CheckRun{Owner: "john-doe",
+		Repo:       "my-repo",
+		Name:       "ci",
+		HeadSHA:    "833568e",
+		ExternalID: "dev/ci-run-abc12",
+		Status:     CheckRunQueued,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if id != 42 {
+		t.Errorf("Want check run id 42, got %d", id)
+	}
+}
+
+func TestReconcileCheckRunReturnsAnErrorWhenGithubFails(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	checksService := githubmocks.NewMockchecksService(mockCtrl)
+	reconciler := &defaultCheckRunReconciler{service: checksService}
+
+	ctx := context.Background()
+
+	// Mock setup
+	checksService.EXPECT().
+		CreateCheckRun(ctx, "john-doe", "my-repo", gomock.Any()).
+		Return(nil, nil, errors.New("Boom!"))
+
+	_, err := reconciler.ReconcileCheckRun(ctx, &CheckRun{Owner: "john-doe",
+		Repo:    "my-repo",
+		Name:    "ci",
+		HeadSHA: "833568e",
+		Status:  CheckRunQueued,
+	})
+
+	want := "Error creating check run ci on john-doe/my-repo@833568e: Boom!"
+	if err == nil || err.Error() != want {
+		t.Errorf("Want error %s, got %v", want, err)
+	}
+}
@@ -0,0 +1,506 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bradleyfalzon/ghinstallation"
+	"github.com/google/go-github/v33/github"
+	"github.com/gregjones/httpcache"
+)
+
+// installationTTL is how long the installation found for a repository owner is
+// trusted before being looked up again, so Apps reinstalled on an account are
+// eventually picked up.
+const installationTTL = time.Hour
+
+// ClientFactory returns clients authenticated as the installation of the
+// Github App on the account that owns a repository, which lets a single
+// deployment serve repositories of several organizations.
+type ClientFactory interface {
+
+	// ClientFor returns a client for the supplied repository. Repo may be
+	// empty when only the owner is known (e.g. for checking organization
+	// memberships).
+	ClientFor(ctx context.Context, owner, repo string) (*github.Client, error)
+}
+
+// staticClientFactory implements ClientFactory by returning the same client for
+// every repository.
+type staticClientFactory struct {
+	client *github.Client
+
+	mutex sync.Mutex
+	login string
+}
+
+// ClientFor implements ClientFactory.
+func (s *staticClientFactory) ClientFor(ctx context.Context, owner, repo string) (*github.Client, error) {
+	return s.client, nil
+}
+
+// Login implements loginService. The login of the user owning the token the
+// client authenticates with is only asked for once.
+func (s *staticClientFactory) Login(ctx context.Context, owner, repo string) (string, error) {
+	s.mutex.Lock()
+	login := s.login
+	s.mutex.Unlock()
+
+	if login != "" {
+		return login, nil
+	}
+
+	user, _, err := s.client.Users.Get(ctx, "")
+	if err != nil {
+		return "", fmt.Errorf("Error getting the authenticated Github user: %w", err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.login = user.GetLogin()
+	return s.login, nil
+}
+
+// NewStaticClientFactory returns a ClientFactory that hands out the supplied
+// client for every repository.
+func NewStaticClientFactory(client *github.Client) ClientFactory {
+	return &staticClientFactory{client: client}
+}
+
+// installationEntry is the installation found for a repository owner.
+type installationEntry struct {
+	id        int64
+	expiresAt time.Time
+}
+
+// installationClientFactory implements ClientFactory by finding the
+// installation of the App for each repository owner through the App's JWT,
+// unless it's pinned to a single installation.
+// Clients are cached per installation, so every installation keeps its own
+// token and HTTP cache.
+type installationClientFactory struct {
+	apps           appsService
+	newClient      func(installationID int64) (*github.Client, error)
+	now            func() time.Time
+	installationID int64
+
+	mutex         sync.Mutex
+	installations map[string]installationEntry
+	clients       map[int64]*github.Client
+	login         string
+}
+
+// newInstallationClientFactory returns a new installationClientFactory.
+func newInstallationClientFactory(apps appsService, newClient func(installationID int64) (*github.Client, error)) *installationClientFactory {
+	return &installationClientFactory{apps: apps,
+		newClient:     newClient,
+		now:           time.Now,
+		installations: make(map[string]installationEntry),
+		clients:       make(map[int64]*github.Client),
+	}
+}
+
+// ClientFor implements ClientFactory.
+func (i *installationClientFactory) ClientFor(ctx context.Context, owner, repo string) (*github.Client, error) {
+	id, err := i.installationFor(ctx, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	if client, exists := i.clients[id]; exists {
+		return client, nil
+	}
+
+	client, err := i.newClient(id)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating client for installation #%d: %w", id, err)
+	}
+	i.clients[id] = client
+	return client, nil
+}
+
+// rotate makes the factory authenticate with the supplied service and clients,
+// which sign requests with a new private key of the App. Installations found so
+// far are kept, while clients built with the previous key are dropped.
+func (i *installationClientFactory) rotate(apps appsService, newClient func(installationID int64) (*github.Client, error)) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	i.apps = apps
+	i.newClient = newClient
+	i.clients = make(map[int64]*github.Client)
+}
+
+// appsService returns the service authenticating as the App with its current
+// private key.
+func (i *installationClientFactory) appsService() appsService {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	return i.apps
+}
+
+// installationFor returns the id of the App's installation on the account that
+// owns the supplied repository.
+func (i *installationClientFactory) installationFor(ctx context.Context, owner, repo string) (int64, error) {
+	if i.installationID != 0 {
+		return i.installationID, nil
+	}
+
+	key := strings.ToLower(owner)
+
+	i.mutex.Lock()
+	entry, exists := i.installations[key]
+	i.mutex.Unlock()
+
+	if exists && i.now().Before(entry.expiresAt) {
+		return entry.id, nil
+	}
+
+	var (
+		installation *github.Installation
+		response     *github.Response
+		err          error
+	)
+
+	if repo != "" {
+		installation, response, err = i.appsService().FindRepositoryInstallation(ctx, owner, repo)
+	} else {
+		installation, response, err = i.appsService().FindOrganizationInstallation(ctx, owner)
+	}
+
+	if response != nil && response.StatusCode == 404 {
+		return 0, &NotFoundError{msg: fmt.Sprintf("The Github App isn't installed on %s", owner)}
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("Error finding the Github App installation for %s: %w", owner, err)
+	}
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	i.installations[key] = installationEntry{id: installation.GetID(), expiresAt: i.now().Add(installationTTL)}
+	return installation.GetID(), nil
+}
+
+// CreateInstallationToken implements installationTokenService.
+func (i *installationClientFactory) CreateInstallationToken(ctx context.Context, owner, repo string, opts *github.InstallationTokenOptions) (*github.InstallationToken, *github.Response, error) {
+	id, err := i.installationFor(ctx, owner, repo)
+	if err != nil {
+		return nil, nil, err
+	}
+	return i.appsService().CreateInstallationToken(ctx, id, opts)
+}
+
+// Login implements loginService. Github Apps act as a bot named after their
+// slug, which is only asked for once.
+func (i *installationClientFactory) Login(ctx context.Context, owner, repo string) (string, error) {
+	i.mutex.Lock()
+	login := i.login
+	i.mutex.Unlock()
+
+	if login != "" {
+		return login, nil
+	}
+
+	app, _, err := i.appsService().Get(ctx, "")
+	if err != nil {
+		return "", fmt.Errorf("Error getting the authenticated Github App: %w", err)
+	}
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	i.login = app.GetSlug() + "[bot]"
+	return i.login, nil
+}
+
+// credentialsEntry is the factory built for a version of the credentials held
+// by a Secret.
+type credentialsEntry struct {
+	resourceVersion string
+	factory         ClientFactory
+}
+
+// credentialsClientFactory implements ClientFactory by handing out clients
+// authenticated with the credentials carried by contexts (see
+// WithCredentials), and delegating to a fallback factory when there are none.
+type credentialsClientFactory struct {
+	host     *Host
+	cache    httpcache.Cache
+	fallback ClientFactory
+
+	mutex     sync.Mutex
+	factories map[string]credentialsEntry
+}
+
+// ClientFor implements ClientFactory.
+func (c *credentialsClientFactory) ClientFor(ctx context.Context, owner, repo string) (*github.Client, error) {
+	credentials := GetCredentials(ctx)
+	if credentials == nil {
+		return c.fallback.ClientFor(ctx, owner, repo)
+	}
+
+	factory, err := c.factoryFor(ctx, credentials)
+	if err != nil {
+		return nil, err
+	}
+	return factory.ClientFor(ctx, owner, repo)
+}
+
+// CreateInstallationToken implements installationTokenService. It fails when
+// requests are authenticated with a token rather than with a Github App.
+func (c *credentialsClientFactory) CreateInstallationToken(ctx context.Context, owner, repo string, opts *github.InstallationTokenOptions) (*github.InstallationToken, *github.Response, error) {
+	factory := c.fallback
+	if credentials := GetCredentials(ctx); credentials != nil {
+		var err error
+		if factory, err = c.factoryFor(ctx, credentials); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	service, ok := factory.(installationTokenService)
+	if !ok {
+		return nil, nil, errors.New("Installation tokens can only be issued when authenticating as a Github App")
+	}
+	return service.CreateInstallationToken(ctx, owner, repo, opts)
+}
+
+// Login implements loginService.
+func (c *credentialsClientFactory) Login(ctx context.Context, owner, repo string) (string, error) {
+	factory := c.fallback
+	if credentials := GetCredentials(ctx); credentials != nil {
+		var err error
+		if factory, err = c.factoryFor(ctx, credentials); err != nil {
+			return "", err
+		}
+	}
+
+	service, ok := factory.(loginService)
+	if !ok {
+		return "", errors.New("Unable to tell which login Github clients act as")
+	}
+	return service.Login(ctx, owner, repo)
+}
+
+// factoryFor returns the factory for the supplied credentials, building a new
+// one when the Secret holding them has changed. Tokens are checked for missing
+// scopes before being used.
+// Factories are built without holding the lock, so that requests made with
+// other credentials don't wait for Github to answer.
+func (c *credentialsClientFactory) factoryFor(ctx context.Context, credentials *Credentials) (ClientFactory, error) {
+	key := credentials.String()
+
+	c.mutex.Lock()
+	entry, exists := c.factories[key]
+	c.mutex.Unlock()
+
+	if exists && entry.resourceVersion == credentials.ResourceVersion {
+		return entry.factory, nil
+	}
+
+	var (
+		factory ClientFactory
+		err     error
+	)
+
+	if credentials.Token != "" {
+		client := newTokenClient(c.host, c.cache, credentials.Token, key)
+		if err = checkTokenScopes(ctx, client); err == nil {
+			factory = NewStaticClientFactory(client)
+		}
+	} else {
+		factory, err = newAppClientFactory(c.host, c.cache, credentials.AppID, credentials.InstallationID, credentials.PrivateKey)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Error creating Github clients for credentials %s: %w", key, err)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// Keep the factory built by a concurrent request for the same credentials,
+	// along with the installations and clients it has cached.
+	if entry, exists := c.factories[key]; exists && entry.resourceVersion == credentials.ResourceVersion {
+		return entry.factory, nil
+	}
+
+	c.factories[key] = credentialsEntry{resourceVersion: credentials.ResourceVersion, factory: factory}
+	return factory, nil
+}
+
+// newTokenClient returns a client that talks to the APIs of the supplied host
+// authenticated with the token in question. Cached responses and metrics of
+// the token are scoped by the supplied name.
+func newTokenClient(host *Host, cache httpcache.Cache, token, name string) *github.Client {
+	transport := newCachingTransport(newRateLimitTransport(host.Transport(), name), cache, name)
+
+	// Enterprise clients can only fail to parse URLs, which were validated
+	// when the host was configured.
+	client, _ := newHostClient(host, &http.Client{
+		Transport: &tokenTransport{token: token, transport: transport},
+		Timeout:   timeout,
+	})
+	return client
+}
+
+// newAppClientFactory returns a ClientFactory for the supplied Github App.
+// All requests go through the installation in question when installationID
+// isn't zero. Otherwise, installations are found for each repository owner.
+func newAppClientFactory(host *Host, cache httpcache.Cache, appID, installationID int64, privateKey []byte) (*installationClientFactory, error) {
+	apps, newClient, err := newAppClientBuilder(host, cache, appID).build(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	factory := newInstallationClientFactory(apps, newClient)
+	factory.installationID = installationID
+	return factory, nil
+}
+
+// appClientBuilder builds the clients of a Github App for a given private key.
+// Rate limit transports are kept from one key to the next, so rotating the
+// key doesn't forget which installations are rate limited.
+type appClientBuilder struct {
+	host  *Host
+	cache httpcache.Cache
+	appID int64
+
+	mutex      sync.Mutex
+	rateLimits map[string]*rateLimitTransport
+}
+
+// newAppClientBuilder returns a new appClientBuilder for the supplied Github
+// App.
+func newAppClientBuilder(host *Host, cache httpcache.Cache, appID int64) *appClientBuilder {
+	return &appClientBuilder{host: host,
+		cache:      cache,
+		appID:      appID,
+		rateLimits: make(map[string]*rateLimitTransport),
+	}
+}
+
+// rateLimitTransport returns the rate limit transport of the supplied
+// installation, creating it the first time it's asked for.
+func (a *appClientBuilder) rateLimitTransport(installation string) *rateLimitTransport {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	transport, exists := a.rateLimits[installation]
+	if !exists {
+		transport = newRateLimitTransport(a.host.Transport(), installation)
+		a.rateLimits[installation] = transport
+	}
+	return transport
+}
+
+// build returns the service authenticating as the App and the function
+// creating clients of its installations, both signing requests with the
+// supplied private key.
+func (a *appClientBuilder) build(privateKey []byte) (appsService, func(installationID int64) (*github.Client, error), error) {
+	newClient := func(installationID int64) (*github.Client, error) {
+		installation := strconv.FormatInt(installationID, 10)
+		transport := newCachingTransport(a.rateLimitTransport(installation), a.cache, installation)
+		installationTransport, err := ghinstallation.New(transport, a.appID, installationID, privateKey)
+		if err != nil {
+			return nil, err
+		}
+		installationTransport.BaseURL = strings.TrimSuffix(a.host.APIURL, "/")
+
+		return newHostClient(a.host, &http.Client{Transport: installationTransport, Timeout: timeout})
+	}
+
+	appsTransport, err := ghinstallation.NewAppsTransport(a.rateLimitTransport("app"), a.appID, privateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	appsTransport.BaseURL = strings.TrimSuffix(a.host.APIURL, "/")
+
+	appsClient, err := newHostClient(a.host, &http.Client{Transport: appsTransport, Timeout: timeout})
+	if err != nil {
+		return nil, nil, err
+	}
+	return appsClient.Apps, newClient, nil
+}
+
+// NewClientFactoryOrDie returns a ClientFactory for the supplied Github host.
+// Requests made with credentials carried by contexts are authenticated with
+// them. Otherwise, they're authenticated with the token mounted at
+// /var/run/secrets/github/token when it exists, or as the Github App set by
+// GITHUB_APP_ID. App requests go through the installation set by
+// GITHUB_INSTALLATION_ID when it's present, or through the installation found
+// for each repository owner. The App's private key is reloaded when it's
+// rotated, until the supplied context is done.
+// It panics if the factory cannot be created or if the token lacks required
+// scopes.
+func NewClientFactoryOrDie(ctx context.Context, host *Host) ClientFactory {
+	const errorMessage = "Error initializing Github client factory: %w"
+
+	cache, err := NewCacheFromEnv()
+	if err != nil {
+		panic(fmt.Errorf(errorMessage, err))
+	}
+
+	factory := &credentialsClientFactory{host: host,
+		cache:     cache,
+		factories: make(map[string]credentialsEntry),
+	}
+
+	token, err := readToken(githubTokenPath)
+	if err != nil {
+		panic(fmt.Errorf(errorMessage, err))
+	}
+
+	if token != "" {
+		client := newTokenClient(host, cache, token, "token")
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		if err := checkTokenScopes(ctx, client); err != nil {
+			panic(fmt.Errorf(errorMessage, err))
+		}
+
+		factory.fallback = NewStaticClientFactory(client)
+		return factory
+	}
+
+	if _, exists := os.LookupEnv(githubAppID); !exists {
+		panic(fmt.Errorf("Error initializing Github client factory: no token was found at %s and environment variable %s isn't set", githubTokenPath, githubAppID))
+	}
+
+	appID, err := parseID(githubAppID)
+	if err != nil {
+		panic(fmt.Errorf(errorMessage, err))
+	}
+
+	var installationID int64
+	if _, exists := os.LookupEnv(githubInstallationID); exists {
+		if installationID, err = parseID(githubInstallationID); err != nil {
+			panic(fmt.Errorf(errorMessage, err))
+		}
+	}
+
+	reloadingFactory, err := newReloadingClientFactory(githubPrivateKeyPath, installationID, newAppClientBuilder(host, cache, appID).build)
+	if err != nil {
+		panic(fmt.Errorf(errorMessage, err))
+	}
+
+	go reloadingFactory.watch(ctx)
+
+	factory.fallback = reloadingFactory
+	return factory
+}
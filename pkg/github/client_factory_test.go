@@ -0,0 +1,190 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/go-github/v33/github"
+	githubmocks "github.com/nubank/workflows/pkg/github/mocks"
+)
+
+func TestStaticClientFactory(t *testing.T) {
+	client := github.NewClient(nil)
+	factory := NewStaticClientFactory(client)
+
+	got, err := factory.ClientFor(context.Background(), "john-doe", "my-repo")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if got != client {
+		t.Error("Want the supplied client")
+	}
+}
+
+func TestInstallationClientFactory(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	appsService := githubmocks.NewMockappsService(mockCtrl)
+	ctx := context.Background()
+
+	created := make(map[int64]int)
+	factory := newInstallationClientFactory(appsService, func(installationID int64) (*github.Client, error) {
+		created[installationID]++
+		return github.NewClient(nil), nil
+	})
+
+	// Mock setup
+	appsService.EXPECT().
+		FindRepositoryInstallation(ctx, "john-doe", "my-repo").
+		Return(&github.Installation{ID: github.Int64(1)}, &github.Response{Response: &http.Response{StatusCode: 200}}, nil).
+		Times(1)
+
+	appsService.EXPECT().
+		FindOrganizationInstallation(ctx, "nubank").
+		Return(&github.Installation{ID: github.Int64(2)}, &github.Response{Response: &http.Response{StatusCode: 200}}, nil).
+		Times(1)
+
+	first, err := factory.ClientFor(ctx, "john-doe", "my-repo")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The installation is cached per owner, regardless of case.
+	second, err := factory.ClientFor(ctx, "John-Doe", "other-repo")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if first != second {
+		t.Error("Want the same client for repositories of the same owner")
+	}
+
+	third, err := factory.ClientFor(ctx, "nubank", "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if third == first {
+		t.Error("Want different clients for different installations")
+	}
+
+	if created[1] != 1 || created[2] != 1 {
+		t.Errorf("Want a single client per installation, got %v", created)
+	}
+}
+
+func TestInstallationClientFactoryExpiresInstallations(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	appsService := githubmocks.NewMockappsService(mockCtrl)
+	ctx := context.Background()
+
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	factory := newInstallationClientFactory(appsService, func(installationID int64) (*github.Client, error) {
+		return github.NewClient(nil), nil
+	})
+	factory.now = func() time.Time { return now }
+
+	// Mock setup
+	appsService.EXPECT().
+		FindRepositoryInstallation(ctx, "john-doe", "my-repo").
+		Return(&github.Installation{ID: github.Int64(1)}, &github.Response{Response: &http.Response{StatusCode: 200}}, nil).
+		Times(2)
+
+	if _, err := factory.ClientFor(ctx, "john-doe", "my-repo"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	now = now.Add(installationTTL)
+
+	if _, err := factory.ClientFor(ctx, "john-doe", "my-repo"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestInstallationClientFactoryReturnsErrors(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	appsService := githubmocks.NewMockappsService(mockCtrl)
+	ctx := context.Background()
+
+	factory := newInstallationClientFactory(appsService, func(installationID int64) (*github.Client, error) {
+		return github.NewClient(nil), nil
+	})
+
+	// Mock setup
+	appsService.EXPECT().
+		FindRepositoryInstallation(ctx, "john-doe", "my-repo").
+		Return(nil, &github.Response{Response: &http.Response{StatusCode: 404}}, errors.New("404 Not Found"))
+
+	appsService.EXPECT().
+		FindRepositoryInstallation(ctx, "nubank", "my-repo").
+		Return(nil, nil, errors.New("connection refused"))
+
+	_, err := factory.ClientFor(ctx, "john-doe", "my-repo")
+	var notFound *NotFoundError
+	if !errors.As(err, &notFound) {
+		t.Errorf("Want a NotFoundError, got %v", err)
+	}
+
+	if _, err := factory.ClientFor(ctx, "nubank", "my-repo"); err == nil || errors.As(err, &notFound) {
+		t.Errorf("Want a generic error, got %v", err)
+	}
+}
+
+func TestInstallationClientFactoryActsAsTheBotOfTheApp(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	appsService := githubmocks.NewMockappsService(mockCtrl)
+	ctx := context.Background()
+
+	factory := newInstallationClientFactory(appsService, func(installationID int64) (*github.Client, error) {
+		return github.NewClient(nil), nil
+	})
+
+	// Mock setup: the App is only looked up once.
+	appsService.EXPECT().
+		Get(ctx, "").
+		Return(&github.App{Slug: github.String("workflows")}, nil, nil)
+
+	for i := 0; i < 2; i++ {
+		login, err := factory.Login(ctx, "john-doe", "my-repo")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if login != "workflows[bot]" {
+			t.Errorf("Want login workflows[bot], got %s", login)
+		}
+	}
+}
+
+func TestInstallationClientFactoryPinnedToAnInstallation(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	appsService := githubmocks.NewMockappsService(mockCtrl)
+	ctx := context.Background()
+
+	factory := newInstallationClientFactory(appsService, func(installationID int64) (*github.Client, error) {
+		return github.NewClient(nil), nil
+	})
+	factory.installationID = 99
+
+	// Mock setup: installations aren't looked up.
+	appsService.EXPECT().
+		CreateInstallationToken(ctx, int64(99), gomock.Any()).
+		Return(&github.InstallationToken{Token: github.String("ghs_abc")}, nil, nil)
+
+	if _, err := factory.ClientFor(ctx, "john-doe", "my-repo"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	token, _, err := factory.CreateInstallationToken(ctx, "john-doe", "my-repo", &github.InstallationTokenOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if token.GetToken() != "ghs_abc" {
+		t.Errorf("Want token ghs_abc, got %s", token.GetToken())
+	}
+}
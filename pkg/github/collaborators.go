@@ -0,0 +1,45 @@
+package github
+
+import (
+	"context"
+	"fmt"
+)
+
+// PermissionChecker verifies what Github users are allowed to do on
+// repositories.
+type PermissionChecker interface {
+
+	// CanWrite returns true if the user has write access (either write,
+	// maintain or admin) to the repository in question.
+	CanWrite(ctx context.Context, owner, repo, user string) (bool, error)
+}
+
+// writePermissions are permission levels granting write access to
+// repositories. The collaborators API reports maintainers as writers, but
+// both are accepted for good measure.
+var writePermissions = map[string]bool{
+	"admin":    true,
+	"maintain": true,
+	"write":    true,
+}
+
+// defaultPermissionChecker implements PermissionChecker by calling the
+// collaborators API.
+type defaultPermissionChecker struct {
+	service repositoriesService
+}
+
+// CanWrite implements PermissionChecker.CanWrite.
+func (d *defaultPermissionChecker) CanWrite(ctx context.Context, owner, repo, user string) (bool, error) {
+	level, _, err := d.service.GetPermissionLevel(ctx, owner, repo, user)
+	if err != nil {
+		return false, fmt.Errorf("Error checking permissions of %s on %s/%s: %w", user, owner, repo, err)
+	}
+
+	return writePermissions[level.GetPermission()], nil
+}
+
+// NewPermissionChecker creates a new PermissionChecker object.
+func NewPermissionChecker(clients ClientFactory) PermissionChecker {
+	return &defaultPermissionChecker{service: &repositoriesServices{clients: clients}}
+}
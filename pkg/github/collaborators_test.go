@@ -0,0 +1,66 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/go-github/v33/github"
+	githubmocks "github.com/nubank/workflows/pkg/github/mocks"
+)
+
+func TestCanWrite(t *testing.T) {
+	tests := []struct {
+		permission string
+		want       bool
+	}{
+		{"admin", true},
+		{"maintain", true},
+		{"write", true},
+		{"read", false},
+		{"none", false},
+	}
+
+	for _, test := range tests {
+		mockCtrl := gomock.NewController(t)
+		repositoriesService := githubmocks.NewMockrepositoriesService(mockCtrl)
+		checker := &defaultPermissionChecker{service: repositoriesService}
+
+		ctx := context.Background()
+
+		// Mock setup
+		repositoriesService.EXPECT().
+			GetPermissionLevel(ctx, "john-doe", "my-repo", "jane-doe").
+			Return(&github.RepositoryPermissionLevel{Permission: github.String(test.permission)}, nil, nil)
+
+		got, err := checker.CanWrite(ctx, "john-doe", "my-repo", "jane-doe")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if test.want != got {
+			t.Errorf("Permission %s: want %t, got %t", test.permission, test.want, got)
+		}
+	}
+}
+
+func TestCanWriteReturnsAnErrorWhenGithubFails(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	repositoriesService := githubmocks.NewMockrepositoriesService(mockCtrl)
+	checker := &defaultPermissionChecker{service: repositoriesService}
+
+	ctx := context.Background()
+
+	// Mock setup
+	repositoriesService.EXPECT().
+		GetPermissionLevel(ctx, "john-doe", "my-repo", "jane-doe").
+		Return(nil, nil, errors.New("Boom!"))
+
+	_, err := checker.CanWrite(ctx, "john-doe", "my-repo", "jane-doe")
+
+	want := "Error checking permissions of jane-doe on john-doe/my-repo: Boom!"
+	if err == nil || err.Error() != want {
+		t.Errorf("Want error %s, got %v", want, err)
+	}
+}
@@ -0,0 +1,140 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/google/go-github/v33/github"
+	"knative.dev/pkg/logging"
+)
+
+// commentsPerPage is the number of comments fetched per request while looking
+// for sticky comments.
+const commentsPerPage = 100
+
+// StickyComment represents a pull request comment that is updated in place
+// instead of being posted again.
+type StickyComment struct {
+
+	// Owner and Repo identify the repository the pull request belongs to.
+	Owner string
+	Repo  string
+
+	// Number of the pull request.
+	Number int
+
+	// Key identifies the comment among others posted on the same pull
+	// request. It's embedded in the comment as a hidden marker.
+	Key string
+
+	// Body of the comment (Markdown).
+	Body string
+}
+
+// CommentReconciler keeps sticky comments on pull requests up to date.
+type CommentReconciler interface {
+
+	// ReconcileComment updates the comment identified by the supplied
+	// sticky comment's key or creates it if it doesn't exist yet.
+	ReconcileComment(ctx context.Context, comment *StickyComment) error
+}
+
+// loginService tells which login clients handed out for a repository act as,
+// i.e. the user owning a token or the bot of a Github App.
+type loginService interface {
+	Login(ctx context.Context, owner, repo string) (string, error)
+}
+
+// defaultCommentReconciler implements CommentReconciler.
+type defaultCommentReconciler struct {
+	service issuesService
+}
+
+// ReconcileComment implements CommentReconciler.ReconcileComment.
+func (c *defaultCommentReconciler) ReconcileComment(ctx context.Context, comment *StickyComment) error {
+	logger := logging.FromContext(ctx).With("pull-request", fmt.Sprintf("%s/%s#%d", comment.Owner, comment.Repo, comment.Number))
+
+	marker := commentMarker(comment.Key)
+	body := &github.IssueComment{Body: github.String(fmt.Sprintf("%s\n%s", marker, comment.Body))}
+
+	login, err := c.service.Login(ctx, comment.Owner, comment.Repo)
+	if err != nil {
+		return err
+	}
+
+	existingComment, err := c.findComment(ctx, comment, marker, login)
+	if err != nil {
+		return err
+	}
+
+	if existingComment == nil {
+		if _, _, err := c.service.CreateComment(ctx, comment.Owner, comment.Repo, comment.Number, body); err != nil {
+			return fmt.Errorf("Error creating comment on %s/%s#%d: %w", comment.Owner, comment.Repo, comment.Number, err)
+		}
+		logger.Info("Comment has been successfully created")
+		return nil
+	}
+
+	if _, _, err := c.service.EditComment(ctx, comment.Owner, comment.Repo, existingComment.GetID(), body); err != nil {
+		return fmt.Errorf("Error updating comment %d on %s/%s#%d: %w", existingComment.GetID(), comment.Owner, comment.Repo, comment.Number, err)
+	}
+	logger.Infow("Comment has been successfully updated", "comment-id", existingComment.GetID())
+	return nil
+}
+
+// findComment looks for the comment containing the supplied marker posted by
+// the login in question, returning nil if there's none. Comments of other
+// users are ignored, since anyone can write the marker.
+func (c *defaultCommentReconciler) findComment(ctx context.Context, comment *StickyComment, marker, login string) (*github.IssueComment, error) {
+	opts := &github.IssueListCommentsOptions{
+		ListOptions: github.ListOptions{PerPage: commentsPerPage},
+	}
+
+	for {
+		comments, response, err := c.service.ListComments(ctx, comment.Owner, comment.Repo, comment.Number, opts)
+		if err != nil {
+			return nil, fmt.Errorf("Error listing comments on %s/%s#%d: %w", comment.Owner, comment.Repo, comment.Number, err)
+		}
+
+		for _, existingComment := range comments {
+			if strings.HasPrefix(existingComment.GetBody(), marker) && strings.EqualFold(existingComment.GetUser().GetLogin(), login) {
+				return existingComment, nil
+			}
+		}
+
+		if response == nil || response.NextPage == 0 {
+			return nil, nil
+		}
+		opts.Page = response.NextPage
+	}
+}
+
+// commentMarker returns the hidden marker that identifies sticky comments with
+// the supplied key.
+func commentMarker(key string) string {
+	return fmt.Sprintf("<!-- workflows.dev/comment: %s -->", key)
+}
+
+// commentReconcilerKey is used to store CommentReconciler objects into
+// context.Context.
+type commentReconcilerKey struct {
+}
+
+// WithCommentReconciler returns a copy of the supplied context with a new
+// CommentReconciler object added.
+func WithCommentReconciler(ctx context.Context, clients ClientFactory) context.Context {
+	return context.WithValue(ctx, commentReconcilerKey{}, &defaultCommentReconciler{service: &issuesServices{clients: clients}})
+}
+
+// GetCommentReconcilerOrDie returns a CommentReconciler instance from the
+// supplied context or dies by calling log.fatal if the context doesn't contain
+// a CommentReconciler object.
+func GetCommentReconcilerOrDie(ctx context.Context) CommentReconciler {
+	if commentReconciler, ok := ctx.Value(commentReconcilerKey{}).(CommentReconciler); ok {
+		return commentReconciler
+	}
+	log.Fatal("Unable to get a valid CommentReconciler instance from context")
+	return nil
+}
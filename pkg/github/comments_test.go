@@ -0,0 +1,128 @@
+package github
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/go-github/v33/github"
+	githubmocks "github.com/nubank/workflows/pkg/github/mocks"
+)
+
+func TestReconcileCommentCreatesANewComment(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	issuesService := githubmocks.NewMockissuesService(mockCtrl)
+	reconciler := &defaultCommentReconciler{service: issuesService}
+
+	ctx := context.Background()
+
+	// Mock setup
+	issuesService.EXPECT().
+		Login(ctx, "john-doe", "my-repo").
+		Return("workflows[bot]", nil)
+
+	issuesService.EXPECT().
+		ListComments(ctx, "john-doe", "my-repo", 7, &github.IssueListCommentsOptions{
+			ListOptions: github.ListOptions{PerPage: 100},
+		}).
+		Return([]*github.IssueComment{
+			{ID: github.Int64(1), Body: github.String("LGTM")},
+		}, &github.Response{}, nil)
+
+	issuesService.EXPECT().
+		CreateComment(ctx, "john-doe", "my-repo", 7, &github.IssueComment{
+			Body: github.String("<!-- workflows.dev/comment: dev/ci -->\nWorkflow succeeded"),
+		}).
+		Return(&github.IssueComment{ID: github.Int64(2)}, nil, nil)
+
+	err := reconciler.ReconcileComment(ctx, &StickyComment{Owner: "john-doe",
+		Repo:   "my-repo",
+		Number: 7,
+		Key:    "dev/ci",
+		Body:   "Workflow succeeded",
+	})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestReconcileCommentUpdatesTheExistingComment(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	issuesService := githubmocks.NewMockissuesService(mockCtrl)
+	reconciler := &defaultCommentReconciler{service: issuesService}
+
+	ctx := context.Background()
+
+	// Mock setup
+	issuesService.EXPECT().
+		Login(ctx, "john-doe", "my-repo").
+		Return("workflows[bot]", nil)
+
+	gomock.InOrder(
+		issuesService.EXPECT().
+			ListComments(ctx, "john-doe", "my-repo", 7, gomock.Any()).
+			Return([]*github.IssueComment{
+				{ID: github.Int64(1), Body: github.String("LGTM")},
+				{ID: github.Int64(2), Body: github.String("<!-- workflows.dev/comment: dev/other -->\nWorkflow failed"), User: &github.User{Login: github.String("workflows[bot]")}},
+			}, &github.Response{NextPage: 2}, nil),
+
+		issuesService.EXPECT().
+			ListComments(ctx, "john-doe", "my-repo", 7, &github.IssueListCommentsOptions{
+				ListOptions: github.ListOptions{Page: 2, PerPage: 100},
+			}).
+			Return([]*github.IssueComment{
+				{ID: github.Int64(3), Body: github.String("<!-- workflows.dev/comment: dev/ci -->\nWorkflow failed"), User: &github.User{Login: github.String("Workflows[bot]")}},
+			}, &github.Response{}, nil),
+	)
+
+	issuesService.EXPECT().
+		EditComment(ctx, "john-doe", "my-repo", int64(3), &github.IssueComment{
+			Body: github.String("<!-- workflows.dev/comment: dev/ci -->\nWorkflow succeeded"),
+		}).
+		Return(&github.IssueComment{ID: github.Int64(3)}, nil, nil)
+
+	err := reconciler.ReconcileComment(ctx, &StickyComment{Owner: "john-doe",
+		Repo:   "my-repo",
+		Number: 7,
+		Key:    "dev/ci",
+		Body:   "Workflow succeeded",
+	})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestReconcileCommentIgnoresMarkersWrittenByOtherUsers(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	issuesService := githubmocks.NewMockissuesService(mockCtrl)
+	reconciler := &defaultCommentReconciler{service: issuesService}
+
+	ctx := context.Background()
+
+	// Mock setup
+	issuesService.EXPECT().
+		Login(ctx, "john-doe", "my-repo").
+		Return("workflows[bot]", nil)
+
+	issuesService.EXPECT().
+		ListComments(ctx, "john-doe", "my-repo", 7, gomock.Any()).
+		Return([]*github.IssueComment{
+			{ID: github.Int64(1), Body: github.String("<!-- workflows.dev/comment: dev/ci -->\nAll good"), User: &github.User{Login: github.String("mallory")}},
+		}, &github.Response{}, nil)
+
+	issuesService.EXPECT().
+		CreateComment(ctx, "john-doe", "my-repo", 7, &github.IssueComment{
+			Body: github.String("<!-- workflows.dev/comment: dev/ci -->\nWorkflow failed"),
+		}).
+		Return(&github.IssueComment{ID: github.Int64(2)}, nil, nil)
+
+	err := reconciler.ReconcileComment(ctx, &StickyComment{Owner: "john-doe",
+		Repo:   "my-repo",
+		Number: 7,
+		Key:    "dev/ci",
+		Body:   "Workflow failed",
+	})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+
+	// Name of the Secret that, when present in a namespace, holds the
+	// credentials used by workflows of that namespace that don't set their
+	// own.
+	DefaultCredentialsSecretName = "github-credentials"
+
+	// Keys of Secrets holding Github credentials.
+	credentialsAppIDKey          = "app-id"
+	credentialsInstallationIDKey = "installation-id"
+	credentialsPrivateKeyKey     = "private-key"
+	credentialsTokenKey          = "token"
+)
+
+// Credentials authenticate requests made on behalf of workflows of a given
+// namespace, in place of the Github App the controller was installed with.
+// They hold either a Github App or a token.
+type Credentials struct {
+
+	// Namespace and name of the Secret the credentials were read from.
+	Namespace string
+	Name      string
+
+	// Resource version of the Secret, which tells whether clients built for
+	// previous versions of the credentials are still valid.
+	ResourceVersion string
+
+	// ID of the Github App.
+	AppID int64
+
+	// ID of the App's installation. When it's zero, installations are found
+	// for each repository owner.
+	InstallationID int64
+
+	// PEM encoded private key of the App.
+	PrivateKey []byte
+
+	// Personal access token or fine-grained token. It's empty when the
+	// credentials hold a Github App.
+	Token string
+}
+
+// String satisfies fmt.Stringer.
+func (c *Credentials) String() string {
+	return fmt.Sprintf("%s/%s", c.Namespace, c.Name)
+}
+
+// NewCredentialsFromSecret reads Github credentials from the supplied Secret.
+// It returns an error if the Secret holds neither a token nor a Github App.
+func NewCredentialsFromSecret(secret *corev1.Secret) (*Credentials, error) {
+	credentials := &Credentials{Namespace: secret.GetNamespace(),
+		Name:            secret.GetName(),
+		ResourceVersion: secret.GetResourceVersion(),
+	}
+
+	if token := strings.TrimSpace(string(secret.Data[credentialsTokenKey])); token != "" {
+		credentials.Token = token
+		return credentials, nil
+	}
+
+	appID, err := parseSecretID(secret, credentialsAppIDKey)
+	if err != nil {
+		return nil, err
+	}
+	credentials.AppID = appID
+
+	if _, exists := secret.Data[credentialsInstallationIDKey]; exists {
+		installationID, err := parseSecretID(secret, credentialsInstallationIDKey)
+		if err != nil {
+			return nil, err
+		}
+		credentials.InstallationID = installationID
+	}
+
+	credentials.PrivateKey = secret.Data[credentialsPrivateKeyKey]
+	if len(credentials.PrivateKey) == 0 {
+		return nil, fmt.Errorf("Secret %s/%s holds neither %s nor %s", secret.GetNamespace(), secret.GetName(), credentialsTokenKey, credentialsPrivateKeyKey)
+	}
+
+	return credentials, nil
+}
+
+// parseSecretID reads the supplied key of the Secret as an int64.
+func parseSecretID(secret *corev1.Secret, key string) (int64, error) {
+	value := strings.TrimSpace(string(secret.Data[key]))
+	if value == "" {
+		return 0, fmt.Errorf("Secret %s/%s is missing key %s", secret.GetNamespace(), secret.GetName(), key)
+	}
+
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid value for key %s of Secret %s/%s: %w", key, secret.GetNamespace(), secret.GetName(), err)
+	}
+	return id, nil
+}
+
+// ResolveCredentials reads the credentials held by the named Secret of the
+// supplied namespace. When name is empty, it falls back to the namespace's
+// default Secret and returns nil if there's none, meaning that requests must
+// be made as the Github App the controller was installed with.
+func ResolveCredentials(ctx context.Context, kubeClient kubernetes.Interface, namespace, name string) (*Credentials, error) {
+	secretName := name
+	if secretName == "" {
+		secretName = DefaultCredentialsSecretName
+	}
+
+	secret, err := kubeClient.CoreV1().Secrets(namespace).Get(ctx, secretName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) && name == "" {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Error reading Github credentials from Secret %s/%s: %w", namespace, secretName, err)
+	}
+
+	return NewCredentialsFromSecret(secret)
+}
+
+type credentialsKey struct {
+}
+
+// WithCredentials returns a copy of the supplied context carrying credentials
+// that clients handed out by ClientFactory objects must authenticate with.
+// Nil credentials leave the context untouched.
+func WithCredentials(ctx context.Context, credentials *Credentials) context.Context {
+	if credentials == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, credentialsKey{}, credentials)
+}
+
+// GetCredentials returns the credentials carried by the supplied context or
+// nil if there are none.
+func GetCredentials(ctx context.Context) *Credentials {
+	if credentials, ok := ctx.Value(credentialsKey{}).(*Credentials); ok {
+		return credentials
+	}
+	return nil
+}
+
+// tokenTransport authenticates requests with a static token.
+type tokenTransport struct {
+	token     string
+	transport http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrippers must not modify the original request.
+	clone := req.Clone(req.Context())
+	clone.Header.Set("Authorization", "token "+t.token)
+	return t.transport.RoundTrip(clone)
+}
@@ -26,7 +26,13 @@ type defaultDeployKeysReconciler struct {
 }
 
 // ReconcileKeys creates or updates all Github deploy keys associated to the supplied workflow.
+// Workflows whose checkout steps authenticate with installation tokens need
+// no deploy keys, so keys created before they switched are deleted.
 func (d *defaultDeployKeysReconciler) ReconcileKeys(ctx context.Context, workflow *v1alpha1.Workflow) ([]secrets.KeyPair, error) {
+	if workflow.UsesInstallationTokens() {
+		return nil, d.deleteKnownKeys(ctx, workflow)
+	}
+
 	keyPairs := make([]secrets.KeyPair, 0)
 	repos := workflow.GetRepositories()
 
@@ -177,8 +183,35 @@ func (d *defaultDeployKeysReconciler) deleteDeployKey(ctx context.Context, repo
 	return nil
 }
 
+// deleteKnownKeys deletes the deploy keys whose ids are recorded in the
+// workflow in question, forgetting them afterwards. Keys already deleted on
+// Github are simply forgotten.
+func (d *defaultDeployKeysReconciler) deleteKnownKeys(ctx context.Context, workflow *v1alpha1.Workflow) error {
+	logger := logging.FromContext(ctx)
+
+	for _, repo := range workflow.GetRepositories() {
+		id := workflow.GetDeployKeyID(&repo)
+		if id == nil {
+			continue
+		}
+
+		response, err := d.service.DeleteKey(ctx, repo.Owner, repo.Name, *id)
+		if err != nil && (response == nil || response.StatusCode != 404) {
+			return fmt.Errorf("unable to delete Github deploy key for repository %s: %w", repo.String(), err)
+		}
+
+		logger.Infow("Github deploy key is no longer needed and has been deleted", "repository", repo.String(), "deploy-key-id", *id)
+		workflow.DeleteDeployKeyID(&repo)
+	}
+	return nil
+}
+
 // Delete deletes all deploy keys associated to the workflow in question.
 func (d *defaultDeployKeysReconciler) Delete(ctx context.Context, workflow *v1alpha1.Workflow) error {
+	if workflow.UsesInstallationTokens() {
+		return d.deleteKnownKeys(ctx, workflow)
+	}
+
 	repos := workflow.GetRepositories()
 
 	for _, repo := range repos {
@@ -201,9 +234,15 @@ func (d *defaultDeployKeysReconciler) Delete(ctx context.Context, workflow *v1al
 type deployKeysReconcilerKey struct {
 }
 
+// NewDeployKeysReconciler returns a new DeployKeysReconciler that manages
+// deploy keys through clients handed out by the supplied factory.
+func NewDeployKeysReconciler(clients ClientFactory) DeployKeysReconciler {
+	return &defaultDeployKeysReconciler{service: &repositoriesServices{clients: clients}}
+}
+
 // WithDeployKeysReconciler returns a copy of the supplied context with a new DeployKeysReconciler object added.
-func WithDeployKeysReconciler(ctx context.Context, client *github.Client) context.Context {
-	return context.WithValue(ctx, deployKeysReconcilerKey{}, &defaultDeployKeysReconciler{service: client.Repositories})
+func WithDeployKeysReconciler(ctx context.Context, clients ClientFactory) context.Context {
+	return context.WithValue(ctx, deployKeysReconcilerKey{}, NewDeployKeysReconciler(clients))
 }
 
 // GetDeployKeysReconcilerOrDie returns a DeployKeyReconciler instance from the supplied
@@ -0,0 +1,62 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/go-github/v33/github"
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	githubmocks "github.com/nubank/workflows/pkg/github/mocks"
+)
+
+func TestReconcileKeysDeletesKeysOfWorkflowsUsingInstallationTokens(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	keysService := githubmocks.NewMockkeysService(mockCtrl)
+	reconciler := &defaultDeployKeysReconciler{service: keysService}
+	ctx := context.Background()
+
+	workflow := &workflowsv1alpha1.Workflow{
+		Spec: workflowsv1alpha1.WorkflowSpec{
+			Repository: &workflowsv1alpha1.Repository{Owner: "nubank", Name: "my-repo", Private: true},
+			AdditionalRepositories: []workflowsv1alpha1.Repository{
+				{Owner: "nubank", Name: "my-lib", Private: true},
+				{Owner: "nubank", Name: "my-docs"},
+			},
+			CheckoutAuth: workflowsv1alpha1.InstallationTokenCheckoutAuth,
+		},
+	}
+	workflow.SetDeployKeyID(workflow.Spec.Repository, 1)
+	workflow.SetDeployKeyID(&workflow.Spec.AdditionalRepositories[0], 2)
+
+	// Mock setup: the second key was already deleted on Github.
+	keysService.EXPECT().
+		DeleteKey(ctx, "nubank", "my-repo", int64(1)).
+		Return(&github.Response{Response: &http.Response{StatusCode: 204}}, nil)
+
+	keysService.EXPECT().
+		DeleteKey(ctx, "nubank", "my-lib", int64(2)).
+		Return(&github.Response{Response: &http.Response{StatusCode: 404}}, errors.New("404 Not Found"))
+
+	keyPairs, err := reconciler.ReconcileKeys(ctx, workflow)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(keyPairs) != 0 {
+		t.Errorf("Want no key pairs, got %d", len(keyPairs))
+	}
+
+	for _, repo := range workflow.GetRepositories() {
+		if id := workflow.GetDeployKeyID(&repo); id != nil {
+			t.Errorf("Want the deploy key of %s to be forgotten, got #%d", repo.String(), *id)
+		}
+	}
+
+	// Nothing is left to be deleted when the workflow is.
+	if err := reconciler.Delete(ctx, workflow); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/google/go-github/v33/github"
+	"knative.dev/pkg/logging"
+)
+
+// Possible states of Github deployment statuses reported by workflows.
+const (
+	DeploymentInProgress = "in_progress"
+	DeploymentSuccess    = "success"
+	DeploymentFailure    = "failure"
+)
+
+// Deployment represents a Github deployment.
+type Deployment struct {
+
+	// Owner and Repo identify the repository the deployment belongs to.
+	Owner string
+	Repo  string
+
+	// Ref is the commit being deployed.
+	Ref string
+
+	// Environment is the name of the environment being deployed to.
+	Environment string
+
+	// Description is a short, human readable description of the deployment.
+	Description string
+}
+
+// DeploymentStatus represents a status of a Github deployment.
+type DeploymentStatus struct {
+
+	// Owner and Repo identify the repository the deployment belongs to.
+	Owner string
+	Repo  string
+
+	// DeploymentID identifies the deployment the status refers to.
+	DeploymentID int64
+
+	// State is one of in_progress, success or failure.
+	State string
+
+	// Description is a short, human readable description of the status.
+	Description string
+
+	// EnvironmentURL is an optional link to the deployed application.
+	EnvironmentURL string
+}
+
+// DeploymentReporter reports tasks that deploy to environments as Github
+// deployments.
+type DeploymentReporter interface {
+
+	// CreateDeployment creates a new deployment and returns its ID.
+	CreateDeployment(ctx context.Context, deployment *Deployment) (int64, error)
+
+	// ReportDeploymentStatus adds a new status to an existing deployment.
+	ReportDeploymentStatus(ctx context.Context, status *DeploymentStatus) error
+}
+
+// defaultDeploymentReporter implements DeploymentReporter.
+type defaultDeploymentReporter struct {
+	service repositoriesService
+}
+
+// CreateDeployment implements DeploymentReporter.CreateDeployment.
+func (d *defaultDeploymentReporter) CreateDeployment(ctx context.Context, deployment *Deployment) (int64, error) {
+	request := &github.DeploymentRequest{
+		Ref:         github.String(deployment.Ref),
+		Environment: github.String(deployment.Environment),
+		Description: optionalString(deployment.Description),
+		// Workflows deploy whatever commit they run against, so neither merge
+		// the default branch nor wait for other checks (including the one
+		// reported by the workflow itself).
+		AutoMerge:        github.Bool(false),
+		RequiredContexts: &[]string{},
+	}
+
+	createdDeployment, _, err := d.service.CreateDeployment(ctx, deployment.Owner, deployment.Repo, request)
+	if err != nil {
+		return 0, fmt.Errorf("Error creating deployment to %s on %s/%s@%s: %w", deployment.Environment, deployment.Owner, deployment.Repo, deployment.Ref, err)
+	}
+
+	logger := logging.FromContext(ctx)
+	logger.Infow("Deployment has been successfully created", "environment", deployment.Environment, "deployment-id", createdDeployment.GetID())
+
+	return createdDeployment.GetID(), nil
+}
+
+// ReportDeploymentStatus implements DeploymentReporter.ReportDeploymentStatus.
+func (d *defaultDeploymentReporter) ReportDeploymentStatus(ctx context.Context, status *DeploymentStatus) error {
+	request := &github.DeploymentStatusRequest{
+		State:          github.String(status.State),
+		Description:    optionalString(status.Description),
+		EnvironmentURL: optionalString(status.EnvironmentURL),
+	}
+
+	if _, _, err := d.service.CreateDeploymentStatus(ctx, status.Owner, status.Repo, status.DeploymentID, request); err != nil {
+		return fmt.Errorf("Error creating status of deployment %d on %s/%s: %w", status.DeploymentID, status.Owner, status.Repo, err)
+	}
+
+	logger := logging.FromContext(ctx)
+	logger.Infow("Deployment status has been successfully created", "deployment-id", status.DeploymentID, "state", status.State)
+
+	return nil
+}
+
+// deploymentReporterKey is used to store DeploymentReporter objects into
+// context.Context.
+type deploymentReporterKey struct {
+}
+
+// WithDeploymentReporter returns a copy of the supplied context with a new
+// DeploymentReporter object added.
+func WithDeploymentReporter(ctx context.Context, clients ClientFactory) context.Context {
+	return context.WithValue(ctx, deploymentReporterKey{}, &defaultDeploymentReporter{service: &repositoriesServices{clients: clients}})
+}
+
+// GetDeploymentReporterOrDie returns a DeploymentReporter instance from the
+// supplied context or dies by calling log.fatal if the context doesn't contain
+// a DeploymentReporter object.
+func GetDeploymentReporterOrDie(ctx context.Context) DeploymentReporter {
+	if reporter, ok := ctx.Value(deploymentReporterKey{}).(DeploymentReporter); ok {
+		return reporter
+	}
+	log.Fatal("Unable to get a valid DeploymentReporter instance from context")
+	return nil
+}
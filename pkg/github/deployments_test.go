@@ -0,0 +1,96 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/go-github/v33/github"
+	githubmocks "github.com/nubank/workflows/pkg/github/mocks"
+)
+
+func TestCreateDeployment(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	repositoriesService := githubmocks.NewMockrepositoriesService(mockCtrl)
+	reporter := &defaultDeploymentReporter{service: repositoriesService}
+
+	ctx := context.Background()
+
+	// Mock setup
+	repositoriesService.EXPECT().
+		CreateDeployment(ctx, "john-doe", "my-repo", &github.DeploymentRequest{
+			Ref:              github.String("833568e"),
+			Environment:      github.String("staging"),
+			Description:      github.String("PipelineRun dev/ci-run-abc12"),
+			AutoMerge:        github.Bool(false),
+			RequiredContexts: &[]string{},
+		}).
+		Return(&github.Deployment{ID: github.Int64(42)}, nil, nil)
+
+	id, err := reporter.CreateDeployment(ctx, &Deployment{Owner: "john-doe",
+		Repo:        "my-repo",
+		Ref:         "833568e",
+		Environment: "staging",
+		Description: "PipelineRun dev/ci-run-abc12",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if id != 42 {
+		t.Errorf("Want deployment id 42, got %d", id)
+	}
+}
+
+func TestCreateDeploymentReturnsAnErrorWhenGithubFails(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	repositoriesService := githubmocks.NewMockrepositoriesService(mockCtrl)
+	reporter := &defaultDeploymentReporter{service: repositoriesService}
+
+	ctx := context.Background()
+
+	// Mock setup
+	repositoriesService.EXPECT().
+		CreateDeployment(ctx, "john-doe", "my-repo", gomock.Any()).
+		Return(nil, nil, errors.New("Boom!"))
+
+	_, err := reporter.CreateDeployment(ctx, &Deployment{Owner: "john-doe",
+		Repo:        "my-repo",
+		Ref:         "833568e",
+		Environment: "staging",
+	})
+
+	want := "Error creating deployment to staging on john-doe/my-repo@833568e: Boom!"
+	if err == nil || err.Error() != want {
+		t.Errorf("Want error %s, got %v", want, err)
+	}
+}
+
+func TestReportDeploymentStatus(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	repositoriesService := githubmocks.NewMockrepositoriesService(mockCtrl)
+	reporter := &defaultDeploymentReporter{service: repositoriesService}
+
+	ctx := context.Background()
+
+	// Mock setup
+	repositoriesService.EXPECT().
+		CreateDeploymentStatus(ctx, "john-doe", "my-repo", int64(42), &github.DeploymentStatusRequest{
+			State:          github.String(DeploymentSuccess),
+			Description:    github.String("Task deploy succeeded"),
+			EnvironmentURL: github.String("https://staging.example.com"),
+		}).
+		Return(&github.DeploymentStatus{}, nil, nil)
+
+	err := reporter.ReportDeploymentStatus(ctx, &DeploymentStatus{Owner: "john-doe",
+		Repo:           "my-repo",
+		DeploymentID:   42,
+		State:          DeploymentSuccess,
+		Description:    "Task deploy succeeded",
+		EnvironmentURL: "https://staging.example.com",
+	})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -4,16 +4,19 @@ import (
 	"context"
 	"crypto/hmac"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"reflect"
 	"regexp"
+	"strings"
 
 	"errors"
 
 	"github.com/google/go-github/v33/github"
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
 )
 
 const (
@@ -36,24 +39,55 @@ var refsPattern = regexp.MustCompile(`^refs/heads/(.*)$`)
 
 // Event represents a Github Webhook event.
 type Event struct {
-	Body          []byte
-	Branch        string
-	Data          interface{}
-	DeliveryID    string
-	HeadCommitSHA string
-	HMACSignature []byte
-	HookID        string
-	Name          string
-	Changes       []string
-	Repository    string
+	Action             string
+	BaseBranch         string
+	BaseCommitSHA      string
+	Body               []byte
+	Branch             string
+	CheckRunExternalID string
+	CheckRunName       string
+	Comment            string
+	Data               interface{}
+	DeliveryID         string
+	HeadCommitMessage  string
+	HeadCommitSHA      string
+	HMACSignature      []byte
+	HookID             string
+	Name               string
+	Changes            []string
+	PullRequestNumber  int
+	PullRequestTitle   string
+	Repository         string
+	Sender             string
+	SenderType         string
+
+	// Secret token sent as is by providers that don't sign payloads (e.g.
+	// GitLab), in place of HMACSignature.
+	Token []byte
+}
+
+// EventCompleter fills in details that providers leave out of the payloads of
+// events (e.g. the files changed by pushes to Bitbucket repositories), by
+// reading them on behalf of the workflow in question. It's only meant to be
+// called once events have been verified.
+type EventCompleter interface {
+	CompleteEvent(ctx context.Context, workflow *workflowsv1alpha1.Workflow, event *Event) error
 }
 
 // VerifySignature validates the payload sent by Github Webhooks by calculating
 // a hash signature using the provided key and comparing it with the signature
-// sent along with the request.
+// sent along with the request. Events carrying a token are validated by
+// comparing it with the key instead.
 // For further details about the algorithm, please see:
 // https://docs.github.com/en/free-pro-team@latest/developers/webhooks-and-events/securing-your-webhooks.
 func (e *Event) VerifySignature(webhookSecret []byte) (bool, string) {
+	if len(e.Token) != 0 {
+		if subtle.ConstantTimeCompare(e.Token, webhookSecret) != 1 {
+			return false, "Access denied: the secret token sent along with the request doesn't match the Webhook secret."
+		}
+		return true, "Access permitted: the secret token sent along with the request matches the Webhook secret."
+	}
+
 	if e.HMACSignature == nil || len(e.HMACSignature) == 0 {
 		return false, fmt.Sprintf("Access denied: Github signature header %s is missing", githubSignatureHeader)
 	}
@@ -87,12 +121,25 @@ func ParseWebhookEvent(request *http.Request) (*Event, error) {
 		return nil, fmt.Errorf("Error reading request body: %w", err)
 	}
 
+	event, err := ParseEventPayload(eventName, body)
+	if err != nil {
+		return nil, err
+	}
+
+	event.DeliveryID = request.Header.Get(githubDeliveryHeader)
+	event.HMACSignature = []byte(request.Header.Get(githubSignatureHeader))
+	event.HookID = request.Header.Get(githubHookHeader)
+
+	return event, nil
+}
+
+// ParseEventPayload creates a new Event object from the supplied payload of
+// the Github event in question. It's also meant for providers whose payloads
+// are compatible with Github's (e.g. Gitea).
+func ParseEventPayload(eventName string, body []byte) (*Event, error) {
 	event := &Event{
-		Body:          body,
-		DeliveryID:    request.Header.Get(githubDeliveryHeader),
-		HMACSignature: []byte(request.Header.Get(githubSignatureHeader)),
-		HookID:        request.Header.Get(githubHookHeader),
-		Name:          eventName,
+		Body: body,
+		Name: eventName,
 	}
 
 	eventPayload, err := github.ParseWebHook(event.Name, event.Body)
@@ -103,18 +150,51 @@ func ParseWebhookEvent(request *http.Request) (*Event, error) {
 	event.Data = eventPayload
 
 	event.Repository = getRepoFullName(eventPayload)
+	event.Action = getAction(eventPayload)
+
+	if sender := getSender(eventPayload); sender != nil {
+		event.Sender = sender.GetLogin()
+		event.SenderType = sender.GetType()
+	}
 
 	switch event.Name {
 	case "push":
 		pushEvent := eventPayload.(*github.PushEvent)
 		event.Branch = getBranch(*pushEvent.Ref)
-		event.HeadCommitSHA = *pushEvent.HeadCommit.ID
+		event.HeadCommitSHA = pushEvent.GetHeadCommit().GetID()
+		event.HeadCommitMessage = pushEvent.GetHeadCommit().GetMessage()
 		event.Changes = collectChanges(pushEvent)
 
 	case "pull_request":
 		pullRequestEvent := eventPayload.(*github.PullRequestEvent)
 		event.HeadCommitSHA = *pullRequestEvent.PullRequest.Head.SHA
-		event.Branch = getBranch(*pullRequestEvent.PullRequest.Head.Ref)
+		event.Branch = getPullRequestBranch(*pullRequestEvent.PullRequest.Head.Ref)
+		event.BaseCommitSHA = pullRequestEvent.PullRequest.GetBase().GetSHA()
+		event.BaseBranch = getPullRequestBranch(pullRequestEvent.PullRequest.GetBase().GetRef())
+		event.PullRequestNumber = pullRequestEvent.PullRequest.GetNumber()
+		event.PullRequestTitle = pullRequestEvent.PullRequest.GetTitle()
+
+	case "check_run":
+		checkRunEvent := eventPayload.(*github.CheckRunEvent)
+		event.HeadCommitSHA = checkRunEvent.GetCheckRun().GetHeadSHA()
+		event.Branch = checkRunEvent.GetCheckRun().GetCheckSuite().GetHeadBranch()
+		event.CheckRunExternalID = checkRunEvent.GetCheckRun().GetExternalID()
+		event.CheckRunName = checkRunEvent.GetCheckRun().GetName()
+
+	case "check_suite":
+		checkSuiteEvent := eventPayload.(*github.CheckSuiteEvent)
+		event.HeadCommitSHA = checkSuiteEvent.GetCheckSuite().GetHeadSHA()
+		event.Branch = checkSuiteEvent.GetCheckSuite().GetHeadBranch()
+
+	case "issue_comment":
+		issueCommentEvent := eventPayload.(*github.IssueCommentEvent)
+		event.Comment = issueCommentEvent.GetComment().GetBody()
+		// The payload doesn't describe the pull request's head, which must be
+		// read from Github when needed.
+		if issue := issueCommentEvent.GetIssue(); issue.IsPullRequest() {
+			event.PullRequestNumber = issue.GetNumber()
+			event.PullRequestTitle = issue.GetTitle()
+		}
 	}
 
 	return event, nil
@@ -147,6 +227,55 @@ func getRepoFullName(event interface{}) string {
 	}
 }
 
+// getSender returns the user that triggered the event using reflection or nil
+// if the value can't be obtained.
+func getSender(event interface{}) *github.User {
+	value := reflect.ValueOf(event).Elem()
+	field := value.FieldByName("Sender")
+	if !field.IsValid() {
+		return nil
+	}
+
+	sender, _ := field.Interface().(*github.User)
+	return sender
+}
+
+// getAction returns the action performed on the resource the event refers to
+// (e.g. opened, rerequested) using reflection or an empty string if the event
+// doesn't carry an action.
+func getAction(event interface{}) string {
+	value := reflect.ValueOf(event).Elem()
+	field := value.FieldByName("Action")
+	if !field.IsValid() {
+		return ""
+	}
+
+	action, _ := field.Interface().(*string)
+	if action == nil {
+		return ""
+	}
+	return *action
+}
+
+// IsRerunRequest returns true if the event asks for checks reported by
+// workflows to be run again (e.g. the "Re-run" button in Github was clicked)
+// or false otherwise.
+func (e *Event) IsRerunRequest() bool {
+	return e.IsCheckEvent() && e.Action == "rerequested"
+}
+
+// IsCheckEvent returns true if the event describes activity on checks (i.e.
+// check_run and check_suite events) or false otherwise.
+func (e *Event) IsCheckEvent() bool {
+	return e.Name == "check_run" || e.Name == "check_suite"
+}
+
+// IsBot returns true if the event was triggered by a bot account (e.g.
+// dependabot[bot]) or false otherwise.
+func (e *Event) IsBot() bool {
+	return e.SenderType == "Bot" || strings.HasSuffix(e.Sender, "[bot]")
+}
+
 // getBranch returns the name of the branch taken from the Git reference.
 func getBranch(reference string) string {
 	matches := refsPattern.FindStringSubmatch(reference)
@@ -157,6 +286,16 @@ func getBranch(reference string) string {
 	return matches[len(matches)-1]
 }
 
+// getPullRequestBranch returns the name of the branch taken from the reference
+// of a pull request's head or base. Github sends those references as plain
+// branch names, but fully qualified references are accepted as well.
+func getPullRequestBranch(reference string) string {
+	if branch := getBranch(reference); branch != "" {
+		return branch
+	}
+	return reference
+}
+
 // collectChanges returns all files that have been added, modified or removed in
 // commits associated to the push event in question.
 func collectChanges(event *github.PushEvent) []string {
@@ -78,11 +78,16 @@ func TestParsesThePushEventProperly(t *testing.T) {
 	}
     ],
     "head_commit": {
-	"id": "32eec86"
+	"id": "32eec86",
+	"message": "Add foo package"
     },
     "ref": "refs/heads/main",
     "repository": {
 	"full_name": "my-org/my-repo"
+    },
+    "sender": {
+	"login": "john-doe",
+	"type": "User"
     }
 }`
 
@@ -124,6 +129,12 @@ func TestParsesThePushEventProperly(t *testing.T) {
 		t.Errorf("event.HeadCommitSHA: want %s, but got %s", wantHeadCommitSHA, gotHeadCommitSHA)
 	}
 
+	wantHeadCommitMessage := "Add foo package"
+	gotHeadCommitMessage := event.HeadCommitMessage
+	if wantHeadCommitMessage != gotHeadCommitMessage {
+		t.Errorf("event.HeadCommitMessage: want %s, but got %s", wantHeadCommitMessage, gotHeadCommitMessage)
+	}
+
 	wantHMACSignature := "sha256=d8a72707"
 	gotHMACSignature := string(event.HMACSignature)
 
@@ -149,6 +160,18 @@ func TestParsesThePushEventProperly(t *testing.T) {
 		t.Errorf("event.Repository: want %s, but got %s", wantRepository, gotRepository)
 	}
 
+	wantSender := "john-doe"
+	gotSender := event.Sender
+	if wantSender != gotSender {
+		t.Errorf("event.Sender: want %s, but got %s", wantSender, gotSender)
+	}
+
+	wantSenderType := "User"
+	gotSenderType := event.SenderType
+	if wantSenderType != gotSenderType {
+		t.Errorf("event.SenderType: want %s, but got %s", wantSenderType, gotSenderType)
+	}
+
 	wantChanges := []string{
 		"pkg/foo/foo.go",
 		"pkg/foo/foo_test.go",
@@ -169,10 +192,16 @@ func TestParsesThePushEventProperly(t *testing.T) {
 func TestParsesThePullRequestEventProperly(t *testing.T) {
 	payload := `{
     "pull_request": {
+	"base": {
+	    "ref": "main",
+	    "sha": "a1b2c3d"
+	},
 	"head": {
 	    "ref": "refs/heads/dev",
 	    "sha": "32eec86"
-	}
+	},
+	"number": 7,
+	"title": "Add foo package"
     },
     "repository": {
 	"full_name": "my-org/my-repo"
@@ -203,6 +232,30 @@ func TestParsesThePullRequestEventProperly(t *testing.T) {
 		t.Errorf("event.HeadCommitSHA: want %s, but got %s", wantHeadCommitSHA, gotHeadCommitSHA)
 	}
 
+	wantBaseBranch := "main"
+	gotBaseBranch := event.BaseBranch
+	if wantBaseBranch != gotBaseBranch {
+		t.Errorf("event.BaseBranch: want %s, but got %s", wantBaseBranch, gotBaseBranch)
+	}
+
+	wantBaseCommitSHA := "a1b2c3d"
+	gotBaseCommitSHA := event.BaseCommitSHA
+	if wantBaseCommitSHA != gotBaseCommitSHA {
+		t.Errorf("event.BaseCommitSHA: want %s, but got %s", wantBaseCommitSHA, gotBaseCommitSHA)
+	}
+
+	wantPullRequestNumber := 7
+	gotPullRequestNumber := event.PullRequestNumber
+	if wantPullRequestNumber != gotPullRequestNumber {
+		t.Errorf("event.PullRequestNumber: want %d, but got %d", wantPullRequestNumber, gotPullRequestNumber)
+	}
+
+	wantPullRequestTitle := "Add foo package"
+	gotPullRequestTitle := event.PullRequestTitle
+	if wantPullRequestTitle != gotPullRequestTitle {
+		t.Errorf("event.PullRequestTitle: want %s, but got %s", wantPullRequestTitle, gotPullRequestTitle)
+	}
+
 	wantEventName := "pull_request"
 	gotEventName := event.Name
 	if wantEventName != gotEventName {
@@ -216,6 +269,198 @@ func TestParsesThePullRequestEventProperly(t *testing.T) {
 	}
 }
 
+func TestParsesTheCheckRunEventProperly(t *testing.T) {
+	payload := `{
+    "action": "rerequested",
+    "check_run": {
+	"name": "ci",
+	"head_sha": "833568e",
+	"external_id": "dev/ci-run-abc12",
+	"check_suite": {
+	    "head_branch": "dev"
+	}
+    },
+    "repository": {
+	"full_name": "my-org/my-repo"
+    },
+    "sender": {
+	"login": "john-doe",
+	"type": "User"
+    }
+}`
+
+	request := &http.Request{
+		Header: http.Header{},
+		Body:   ioutil.NopCloser(strings.NewReader(payload)),
+	}
+	request.Header.Set("X-GitHub-Event", "check_run")
+
+	event, err := ParseWebhookEvent(request)
+	if err != nil {
+		t.Fatalf("Want a well-formed event, but got error: %s", err)
+	}
+
+	want := &Event{Action: "rerequested",
+		Body:               []byte(payload),
+		Branch:             "dev",
+		CheckRunExternalID: "dev/ci-run-abc12",
+		CheckRunName:       "ci",
+		HeadCommitSHA:      "833568e",
+		HMACSignature:      []byte{},
+		Name:               "check_run",
+		Repository:         "my-org/my-repo",
+		Sender:             "john-doe",
+		SenderType:         "User",
+	}
+	want.Data = event.Data
+
+	if diff := cmp.Diff(want, event); diff != "" {
+		t.Errorf("Mismatch (-want +got):\n%s", diff)
+	}
+
+	if !event.IsRerunRequest() {
+		t.Error("Want a rerun request, but got false")
+	}
+}
+
+func TestParsesTheCheckSuiteEventProperly(t *testing.T) {
+	payload := `{
+    "action": "rerequested",
+    "check_suite": {
+	"head_branch": "dev",
+	"head_sha": "833568e"
+    },
+    "repository": {
+	"full_name": "my-org/my-repo"
+    }
+}`
+
+	request := &http.Request{
+		Header: http.Header{},
+		Body:   ioutil.NopCloser(strings.NewReader(payload)),
+	}
+	request.Header.Set("X-GitHub-Event", "check_suite")
+
+	event, err := ParseWebhookEvent(request)
+	if err != nil {
+		t.Fatalf("Want a well-formed event, but got error: %s", err)
+	}
+
+	if event.HeadCommitSHA != "833568e" {
+		t.Errorf("event.HeadCommitSHA: want 833568e, but got %s", event.HeadCommitSHA)
+	}
+
+	if event.Branch != "dev" {
+		t.Errorf("event.Branch: want dev, but got %s", event.Branch)
+	}
+
+	if !event.IsRerunRequest() {
+		t.Error("Want a rerun request, but got false")
+	}
+}
+
+func TestParsesTheIssueCommentEventProperly(t *testing.T) {
+	payload := `{
+    "action": "created",
+    "comment": {
+	"body": "/retest"
+    },
+    "issue": {
+	"number": 7,
+	"title": "Add foo package",
+	"pull_request": {
+	    "url": "https://api.github.com/repos/my-org/my-repo/pulls/7"
+	}
+    },
+    "repository": {
+	"full_name": "my-org/my-repo"
+    },
+    "sender": {
+	"login": "john-doe",
+	"type": "User"
+    }
+}`
+
+	request := &http.Request{
+		Header: http.Header{},
+		Body:   ioutil.NopCloser(strings.NewReader(payload)),
+	}
+	request.Header.Set("X-GitHub-Event", "issue_comment")
+
+	event, err := ParseWebhookEvent(request)
+	if err != nil {
+		t.Fatalf("Want a well-formed event, but got error: %s", err)
+	}
+
+	want := &Event{Action: "created",
+		Body:              []byte(payload),
+		Comment:           "/retest",
+		HMACSignature:     []byte{},
+		Name:              "issue_comment",
+		PullRequestNumber: 7,
+		PullRequestTitle:  "Add foo package",
+		Repository:        "my-org/my-repo",
+		Sender:            "john-doe",
+		SenderType:        "User",
+	}
+	want.Data = event.Data
+
+	if diff := cmp.Diff(want, event); diff != "" {
+		t.Errorf("Mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestIssueCommentsOnIssuesDoNotReferToPullRequests(t *testing.T) {
+	payload := `{
+    "action": "created",
+    "comment": {
+	"body": "/retest"
+    },
+    "issue": {
+	"number": 8,
+	"title": "Foo is broken"
+    },
+    "repository": {
+	"full_name": "my-org/my-repo"
+    }
+}`
+
+	request := &http.Request{
+		Header: http.Header{},
+		Body:   ioutil.NopCloser(strings.NewReader(payload)),
+	}
+	request.Header.Set("X-GitHub-Event", "issue_comment")
+
+	event, err := ParseWebhookEvent(request)
+	if err != nil {
+		t.Fatalf("Want a well-formed event, but got error: %s", err)
+	}
+
+	if event.PullRequestNumber != 0 {
+		t.Errorf("event.PullRequestNumber: want 0, but got %d", event.PullRequestNumber)
+	}
+}
+
+func TestIsRerunRequest(t *testing.T) {
+	tests := []struct {
+		event *Event
+		want  bool
+	}{
+		{&Event{Name: "check_run", Action: "rerequested"}, true},
+		{&Event{Name: "check_suite", Action: "rerequested"}, true},
+		{&Event{Name: "check_run", Action: "created"}, false},
+		{&Event{Name: "check_suite", Action: "completed"}, false},
+		{&Event{Name: "pull_request", Action: "opened"}, false},
+		{&Event{Name: "push"}, false},
+	}
+
+	for _, test := range tests {
+		if got := test.event.IsRerunRequest(); test.want != got {
+			t.Errorf("IsRerunRequest for %s/%s: want %t, but got %t", test.event.Name, test.event.Action, test.want, got)
+		}
+	}
+}
+
 func TestGetBranch(t *testing.T) {
 	tests := []struct {
 		ref    string
@@ -234,6 +479,42 @@ func TestGetBranch(t *testing.T) {
 	}
 }
 
+func TestGetPullRequestBranch(t *testing.T) {
+	tests := []struct {
+		ref    string
+		branch string
+	}{
+		{"main", "main"},
+		{"release/1.x", "release/1.x"},
+		{"refs/heads/issue10/fix-foo", "issue10/fix-foo"},
+	}
+
+	for _, test := range tests {
+		gotBranch := getPullRequestBranch(test.ref)
+		if test.branch != gotBranch {
+			t.Errorf("Want branch %s, but got %s", test.branch, gotBranch)
+		}
+	}
+}
+
+func TestIsBot(t *testing.T) {
+	tests := []struct {
+		event *Event
+		want  bool
+	}{
+		{&Event{Sender: "john-doe", SenderType: "User"}, false},
+		{&Event{Sender: "dependabot[bot]", SenderType: "Bot"}, true},
+		{&Event{Sender: "renovate[bot]"}, true},
+		{&Event{}, false},
+	}
+
+	for _, test := range tests {
+		if got := test.event.IsBot(); test.want != got {
+			t.Errorf("IsBot for sender %s: want %t, but got %t", test.event.Sender, test.want, got)
+		}
+	}
+}
+
 func TestDeniesRequestsIfSignatureIsMissing(t *testing.T) {
 	events := []*Event{
 		{HMACSignature: nil},
@@ -283,6 +564,23 @@ func TestRejectsRequestsWhenSignaturesDoNotMatch(t *testing.T) {
 	}
 }
 
+func TestVerifiesTokensSentAlongWithRequests(t *testing.T) {
+	tests := []struct {
+		token []byte
+		want  bool
+	}{
+		{[]byte("secret"), true},
+		{[]byte("other-secret"), false},
+	}
+
+	for _, test := range tests {
+		event := &Event{Token: test.token}
+		if valid, _ := event.VerifySignature([]byte("secret")); valid != test.want {
+			t.Errorf("Token %s: want %t, but got %t", test.token, test.want, valid)
+		}
+	}
+}
+
 func TestContextInfusedWithEvent(t *testing.T) {
 	ctx := context.Background()
 	wantEvent := &Event{Name: "push"}
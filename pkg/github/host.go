@@ -0,0 +1,195 @@
+package github
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const (
+
+	// Name of the environment variable that contains the base URL of the
+	// Github REST API. Github Enterprise Server instances serve it under
+	// /api/v3/.
+	githubAPIURL = "GITHUB_API_URL"
+
+	// Name of the environment variable that contains the base URL for
+	// uploading release assets.
+	githubUploadURL = "GITHUB_UPLOAD_URL"
+
+	// Name of the environment variable that contains the base URL
+	// repositories are cloned from.
+	githubGitURL = "GITHUB_GIT_URL"
+
+	// Name of the environment variable that contains the path to a file
+	// holding PEM encoded certificates to be trusted when talking to Github.
+	githubCABundlePath = "GITHUB_CA_BUNDLE_PATH"
+
+	// Base URLs of github.com.
+	defaultAPIURL    = "https://api.github.com/"
+	defaultUploadURL = "https://uploads.github.com/"
+	defaultGitURL    = "https://github.com"
+
+	// Paths under which Github Enterprise Server instances serve the REST API
+	// and uploads.
+	enterpriseAPIPath    = "api/v3/"
+	enterpriseUploadPath = "api/uploads/"
+)
+
+// Host describes the Github instance hosting repositories, which is either
+// github.com or a Github Enterprise Server instance.
+type Host struct {
+
+	// Base URL of the REST API, with a trailing slash.
+	APIURL string
+
+	// Base URL for uploading release assets, with a trailing slash.
+	UploadURL string
+
+	// Base URL repositories are cloned from over HTTPS, without a trailing
+	// slash.
+	GitURL string
+
+	// Base URL repositories are cloned from over SSH when they aren't cloned
+	// from git@<GitHostname>: (e.g. ssh://git@bitbucket.example.com:7999),
+	// without a trailing slash. It's empty for Github instances.
+	SSHURL string
+
+	// PEM encoded certificates trusted in addition to the system ones. It's
+	// empty unless the instance uses certificates issued by a private CA.
+	CABundle []byte
+}
+
+// DefaultHost returns the Host describing github.com.
+func DefaultHost() *Host {
+	return &Host{APIURL: defaultAPIURL,
+		UploadURL: defaultUploadURL,
+		GitURL:    defaultGitURL,
+	}
+}
+
+// NewHostFromEnv returns the Host configured through environment variables,
+// defaulting to github.com. Upload and git URLs default to the API URL's
+// origin when only the latter is set.
+func NewHostFromEnv() (*Host, error) {
+	host := DefaultHost()
+
+	apiURL, ok := os.LookupEnv(githubAPIURL)
+	if ok && apiURL != "" && withTrailingSlash(apiURL) != defaultAPIURL {
+		origin, err := parseOrigin(githubAPIURL, apiURL)
+		if err != nil {
+			return nil, err
+		}
+
+		host.APIURL = withTrailingSlash(apiURL)
+		if !strings.HasSuffix(host.APIURL, enterpriseAPIPath) {
+			host.APIURL += enterpriseAPIPath
+		}
+		host.UploadURL = origin + "/" + enterpriseUploadPath
+		host.GitURL = origin
+	}
+
+	if uploadURL, ok := os.LookupEnv(githubUploadURL); ok && uploadURL != "" {
+		if _, err := parseOrigin(githubUploadURL, uploadURL); err != nil {
+			return nil, err
+		}
+		host.UploadURL = withTrailingSlash(uploadURL)
+	}
+
+	if gitURL, ok := os.LookupEnv(githubGitURL); ok && gitURL != "" {
+		if _, err := parseOrigin(githubGitURL, gitURL); err != nil {
+			return nil, err
+		}
+		host.GitURL = strings.TrimSuffix(gitURL, "/")
+	}
+
+	if path, ok := os.LookupEnv(githubCABundlePath); ok && path != "" {
+		bundle, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading the CA bundle set by %s: %w", githubCABundlePath, err)
+		}
+
+		if !x509.NewCertPool().AppendCertsFromPEM(bundle) {
+			return nil, fmt.Errorf("The CA bundle set by %s doesn't contain any PEM encoded certificate", githubCABundlePath)
+		}
+		host.CABundle = bundle
+	}
+
+	return host, nil
+}
+
+// parseOrigin checks that the value of the supplied environment variable is
+// an absolute HTTP(S) URL and returns its scheme and host.
+func parseOrigin(varName, value string) (string, error) {
+	u, err := url.Parse(value)
+	if err != nil {
+		return "", fmt.Errorf("Invalid value %s for environment variable %s: %w", value, varName, err)
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("Invalid value %s for environment variable %s: expected an absolute HTTP(S) URL", value, varName)
+	}
+	return fmt.Sprintf("%s://%s", u.Scheme, u.Host), nil
+}
+
+// withTrailingSlash appends a slash to the supplied URL unless it already
+// ends with one.
+func withTrailingSlash(u string) string {
+	if strings.HasSuffix(u, "/") {
+		return u
+	}
+	return u + "/"
+}
+
+// IsEnterprise returns true if the host is a Github Enterprise Server instance
+// or false if it's github.com.
+func (h *Host) IsEnterprise() bool {
+	return h.APIURL != defaultAPIURL
+}
+
+// GitHostname returns the hostname repositories are cloned from, which is
+// also the one used to clone them over SSH.
+func (h *Host) GitHostname() string {
+	u, err := url.Parse(h.GitURL)
+	if err != nil || u.Hostname() == "" {
+		return "github.com"
+	}
+	return u.Hostname()
+}
+
+// HTTPSCloneURL returns the URL for cloning the supplied repository over
+// HTTPS.
+func (h *Host) HTTPSCloneURL(owner, repo string) string {
+	return fmt.Sprintf("%s/%s/%s.git", h.GitURL, owner, repo)
+}
+
+// SSHCloneURL returns the URL for cloning the supplied repository over SSH.
+func (h *Host) SSHCloneURL(owner, repo string) string {
+	if h.SSHURL != "" {
+		return fmt.Sprintf("%s/%s/%s.git", h.SSHURL, owner, repo)
+	}
+	return fmt.Sprintf("git@%s:%s/%s.git", h.GitHostname(), owner, repo)
+}
+
+// Transport returns the HTTP transport for talking to the host, which trusts
+// the host's CA bundle if any.
+func (h *Host) Transport() http.RoundTripper {
+	if len(h.CABundle) == 0 {
+		return http.DefaultTransport
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	pool.AppendCertsFromPEM(h.CABundle)
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	return transport
+}
@@ -0,0 +1,165 @@
+package github
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// setEnv sets the supplied environment variables for the duration of the test.
+func setEnv(t *testing.T, vars map[string]string) {
+	for _, name := range []string{githubAPIURL, githubUploadURL, githubGitURL, githubCABundlePath, githubCacheBackend, githubCacheMaxBytes, githubCacheDir} {
+		value, ok := os.LookupEnv(name)
+		os.Unsetenv(name)
+		t.Cleanup(func() {
+			if ok {
+				os.Setenv(name, value)
+			} else {
+				os.Unsetenv(name)
+			}
+		})
+	}
+
+	for name, value := range vars {
+		os.Setenv(name, value)
+	}
+}
+
+func TestNewHostFromEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want *Host
+	}{
+		{
+			name: "defaults to github.com",
+			env:  map[string]string{},
+			want: DefaultHost(),
+		},
+		{
+			name: "github.com set explicitly",
+			env:  map[string]string{githubAPIURL: "https://api.github.com"},
+			want: DefaultHost(),
+		},
+		{
+			name: "enterprise server API URL",
+			env:  map[string]string{githubAPIURL: "https://github.example.com/api/v3"},
+			want: &Host{APIURL: "https://github.example.com/api/v3/",
+				UploadURL: "https://github.example.com/api/uploads/",
+				GitURL:    "https://github.example.com",
+			},
+		},
+		{
+			name: "enterprise server origin",
+			env:  map[string]string{githubAPIURL: "https://github.example.com"},
+			want: &Host{APIURL: "https://github.example.com/api/v3/",
+				UploadURL: "https://github.example.com/api/uploads/",
+				GitURL:    "https://github.example.com",
+			},
+		},
+		{
+			name: "every URL set explicitly",
+			env: map[string]string{githubAPIURL: "https://api.github.example.com/api/v3/",
+				githubUploadURL: "https://uploads.github.example.com/api/uploads",
+				githubGitURL:    "https://git.example.com/",
+			},
+			want: &Host{APIURL: "https://api.github.example.com/api/v3/",
+				UploadURL: "https://uploads.github.example.com/api/uploads/",
+				GitURL:    "https://git.example.com",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setEnv(t, test.env)
+
+			got, err := NewHostFromEnv()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("Mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNewHostFromEnvRejectsInvalidValues(t *testing.T) {
+	tests := []map[string]string{
+		{githubAPIURL: "github.example.com"},
+		{githubUploadURL: "ftp://github.example.com"},
+		{githubGitURL: "/github"},
+		{githubCABundlePath: "testdata/missing.crt"},
+		{githubCABundlePath: "testdata/welcome.yaml"},
+	}
+
+	for _, env := range tests {
+		setEnv(t, env)
+
+		if _, err := NewHostFromEnv(); err == nil {
+			t.Errorf("Want an error for %v, got nil", env)
+		}
+	}
+}
+
+func TestHostCloneURLs(t *testing.T) {
+	host := &Host{GitURL: "https://github.example.com"}
+
+	if got := host.HTTPSCloneURL("john-doe", "my-repo"); got != "https://github.example.com/john-doe/my-repo.git" {
+		t.Errorf("Unexpected HTTPS clone URL %s", got)
+	}
+
+	if got := host.SSHCloneURL("john-doe", "my-repo"); got != "git@github.example.com:john-doe/my-repo.git" {
+		t.Errorf("Unexpected SSH clone URL %s", got)
+	}
+
+	sshHost := &Host{GitURL: "https://bitbucket.example.com/scm", SSHURL: "ssh://git@bitbucket.example.com:7999"}
+	if got := sshHost.SSHCloneURL("PROJ", "my-repo"); got != "ssh://git@bitbucket.example.com:7999/PROJ/my-repo.git" {
+		t.Errorf("Unexpected SSH clone URL %s", got)
+	}
+
+	if !host.IsEnterprise() {
+		t.Error("Want an enterprise host")
+	}
+
+	if DefaultHost().IsEnterprise() {
+		t.Error("Want github.com not to be an enterprise host")
+	}
+}
+
+func TestHostTransportTrustsCABundle(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	if _, err := (&http.Client{Transport: DefaultHost().Transport()}).Get(server.URL); err == nil {
+		t.Fatal("Want the server's certificate to be untrusted by default")
+	}
+
+	bundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	path := filepath.Join(t.TempDir(), "ca.crt")
+	if err := ioutil.WriteFile(path, bundle, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	setEnv(t, map[string]string{githubAPIURL: server.URL, githubCABundlePath: path})
+	host, err := NewHostFromEnv()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	response, err := (&http.Client{Transport: host.Transport()}).Get(server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	response.Body.Close()
+}
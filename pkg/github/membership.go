@@ -0,0 +1,152 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// How long results of membership lookups are kept in memory.
+	membershipCacheTTL = 5 * time.Minute
+
+	// State of team memberships whose invitation has been accepted.
+	activeMembershipState = "active"
+)
+
+// MembershipChecker verifies whether Github users are members of organizations
+// or teams.
+type MembershipChecker interface {
+	IsOrgMember(ctx context.Context, org, user string) (bool, error)
+	IsTeamMember(ctx context.Context, org, team, user string) (bool, error)
+}
+
+// defaultMembershipChecker implements MembershipChecker by calling Github
+// APIs and caching their results for a while, since the same users tend to
+// trigger workflows over and over again.
+type defaultMembershipChecker struct {
+	organizations organizationsService
+	teams         teamsService
+	cache         *membershipCache
+}
+
+// IsOrgMember implements MembershipChecker.IsOrgMember.
+func (d *defaultMembershipChecker) IsOrgMember(ctx context.Context, org, user string) (bool, error) {
+	key := fmt.Sprintf("orgs/%s/%s", org, user)
+	if member, ok := d.cache.get(key); ok {
+		return member, nil
+	}
+
+	member, _, err := d.organizations.IsMember(ctx, org, user)
+	if err != nil {
+		return false, fmt.Errorf("Error checking whether %s is a member of organization %s: %w", user, org, err)
+	}
+
+	d.cache.put(key, member)
+	return member, nil
+}
+
+// IsTeamMember implements MembershipChecker.IsTeamMember.
+func (d *defaultMembershipChecker) IsTeamMember(ctx context.Context, org, team, user string) (bool, error) {
+	key := fmt.Sprintf("teams/%s/%s/%s", org, team, user)
+	if member, ok := d.cache.get(key); ok {
+		return member, nil
+	}
+
+	membership, response, err := d.teams.GetTeamMembershipBySlug(ctx, org, team, user)
+	if response != nil && response.StatusCode == 404 {
+		// Github responds with 404 when the user isn't a member of the team.
+		d.cache.put(key, false)
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("Error checking whether %s is a member of team %s/%s: %w", user, org, team, err)
+	}
+
+	member := membership.GetState() == activeMembershipState
+	d.cache.put(key, member)
+	return member, nil
+}
+
+// membershipCache is a simple in-memory cache whose entries expire after a
+// fixed period of time.
+type membershipCache struct {
+	mutex   sync.Mutex
+	entries map[string]membershipCacheEntry
+	ttl     time.Duration
+	now     func() time.Time
+}
+
+// membershipCacheEntry is a single result stored in the membershipCache.
+type membershipCacheEntry struct {
+	member    bool
+	expiresAt time.Time
+}
+
+// newMembershipCache returns a new membershipCache whose entries live for the
+// supplied duration.
+func newMembershipCache(ttl time.Duration) *membershipCache {
+	return &membershipCache{
+		entries: make(map[string]membershipCacheEntry),
+		ttl:     ttl,
+		now:     time.Now,
+	}
+}
+
+// get returns the cached result for the supplied key and true or false and
+// false if the key is absent or has expired.
+func (m *membershipCache) get(key string) (bool, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	entry, exists := m.entries[key]
+	if !exists {
+		return false, false
+	}
+
+	if m.now().After(entry.expiresAt) {
+		delete(m.entries, key)
+		return false, false
+	}
+	return entry.member, true
+}
+
+// put stores the supplied result in the cache.
+func (m *membershipCache) put(key string, member bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.entries[key] = membershipCacheEntry{
+		member:    member,
+		expiresAt: m.now().Add(m.ttl),
+	}
+}
+
+// NewMembershipChecker creates a new MembershipChecker object.
+func NewMembershipChecker(clients ClientFactory) MembershipChecker {
+	return &defaultMembershipChecker{
+		organizations: &organizationsServices{clients: clients},
+		teams:         &teamsServices{clients: clients},
+		cache:         newMembershipCache(membershipCacheTTL),
+	}
+}
+
+// membershipCheckerKey is used to store MembershipChecker objects into context.Context.
+type membershipCheckerKey struct {
+}
+
+// WithMembershipChecker returns a copy of the supplied context with the MembershipChecker object added.
+func WithMembershipChecker(ctx context.Context, checker MembershipChecker) context.Context {
+	return context.WithValue(ctx, membershipCheckerKey{}, checker)
+}
+
+// GetMembershipChecker returns the MembershipChecker instance stored in the
+// supplied context or nil if the context doesn't contain one.
+func GetMembershipChecker(ctx context.Context) MembershipChecker {
+	if checker, ok := ctx.Value(membershipCheckerKey{}).(MembershipChecker); ok {
+		return checker
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/go-github/v33/github"
+	githubmocks "github.com/nubank/workflows/pkg/github/mocks"
+)
+
+func TestIsOrgMemberCachesResults(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	organizationsService := githubmocks.NewMockorganizationsService(mockCtrl)
+	checker := &defaultMembershipChecker{
+		organizations: organizationsService,
+		cache:         newMembershipCache(time.Minute),
+	}
+
+	ctx := context.Background()
+
+	// Mock setup
+	organizationsService.EXPECT().
+		IsMember(ctx, "my-org", "john-doe").
+		Return(true, nil, nil).
+		Times(1)
+
+	for i := 0; i < 2; i++ {
+		member, err := checker.IsOrgMember(ctx, "my-org", "john-doe")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if !member {
+			t.Error("Want john-doe to be a member of my-org, but got a non-member")
+		}
+	}
+}
+
+func TestIsOrgMemberReturnsAnErrorWhenTheLookupFails(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	organizationsService := githubmocks.NewMockorganizationsService(mockCtrl)
+	checker := &defaultMembershipChecker{
+		organizations: organizationsService,
+		cache:         newMembershipCache(time.Minute),
+	}
+
+	ctx := context.Background()
+
+	// Mock setup
+	organizationsService.EXPECT().
+		IsMember(ctx, "my-org", "john-doe").
+		Return(false, nil, errors.New("Boom!")).
+		Times(2)
+
+	// Errors must not be cached.
+	for i := 0; i < 2; i++ {
+		if _, err := checker.IsOrgMember(ctx, "my-org", "john-doe"); err == nil {
+			t.Error("Want an error, but the lookup succeeded unexpectedly")
+		}
+	}
+}
+
+func TestIsTeamMember(t *testing.T) {
+	tests := []struct {
+		membership *github.Membership
+		response   *github.Response
+		want       bool
+	}{
+		{&github.Membership{State: github.String("active")}, &github.Response{Response: &http.Response{StatusCode: 200}}, true},
+		{&github.Membership{State: github.String("pending")}, &github.Response{Response: &http.Response{StatusCode: 200}}, false},
+		{nil, &github.Response{Response: &http.Response{StatusCode: 404}}, false},
+	}
+
+	for _, test := range tests {
+		mockCtrl := gomock.NewController(t)
+		teamsService := githubmocks.NewMockteamsService(mockCtrl)
+		checker := &defaultMembershipChecker{
+			teams: teamsService,
+			cache: newMembershipCache(time.Minute),
+		}
+
+		ctx := context.Background()
+
+		// Mock setup
+		var err error
+		if test.response.StatusCode == 404 {
+			err = errors.New("404 Not Found")
+		}
+		teamsService.EXPECT().
+			GetTeamMembershipBySlug(ctx, "my-org", "my-team", "john-doe").
+			Return(test.membership, test.response, err)
+
+		got, err := checker.IsTeamMember(ctx, "my-org", "my-team", "john-doe")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if test.want != got {
+			t.Errorf("Want %t, but got %t", test.want, got)
+		}
+	}
+}
+
+func TestMembershipCacheEntriesExpire(t *testing.T) {
+	now := time.Now()
+	cache := newMembershipCache(time.Minute)
+	cache.now = func() time.Time {
+		return now
+	}
+
+	cache.put("orgs/my-org/john-doe", true)
+
+	if member, ok := cache.get("orgs/my-org/john-doe"); !ok || !member {
+		t.Errorf("Want a cached entry, but got (%t, %t)", member, ok)
+	}
+
+	now = now.Add(2 * time.Minute)
+
+	if _, ok := cache.get("orgs/my-org/john-doe"); ok {
+		t.Error("Want the cached entry to be expired, but it's still present")
+	}
+}
+
+func TestContextInfusedWithMembershipChecker(t *testing.T) {
+	ctx := context.Background()
+
+	if checker := GetMembershipChecker(ctx); checker != nil {
+		t.Errorf("Want no MembershipChecker, but got %+v", checker)
+	}
+
+	want := NewMembershipChecker(NewStaticClientFactory(github.NewClient(nil)))
+	got := GetMembershipChecker(WithMembershipChecker(ctx, want))
+
+	if want != got {
+		t.Errorf("Want MembershipChecker %+v, got %+v", want, got)
+	}
+}
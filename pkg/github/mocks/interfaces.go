@@ -248,6 +248,54 @@ func (m *MockrepositoriesService) EXPECT() *MockrepositoriesServiceMockRecorder
 	return m.recorder
 }
 
+// CreateDeployment mocks base method.
+func (m *MockrepositoriesService) CreateDeployment(ctx context.Context, owner, repo string, request *github.DeploymentRequest) (*github.Deployment, *github.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateDeployment", ctx, owner, repo, request)
+	ret0, _ := ret[0].(*github.Deployment)
+	ret1, _ := ret[1].(*github.Response)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// CreateDeployment indicates an expected call of CreateDeployment.
+func (mr *MockrepositoriesServiceMockRecorder) CreateDeployment(ctx, owner, repo, request interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateDeployment", reflect.TypeOf((*MockrepositoriesService)(nil).CreateDeployment), ctx, owner, repo, request)
+}
+
+// CreateDeploymentStatus mocks base method.
+func (m *MockrepositoriesService) CreateDeploymentStatus(ctx context.Context, owner, repo string, deployment int64, request *github.DeploymentStatusRequest) (*github.DeploymentStatus, *github.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateDeploymentStatus", ctx, owner, repo, deployment, request)
+	ret0, _ := ret[0].(*github.DeploymentStatus)
+	ret1, _ := ret[1].(*github.Response)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// CreateDeploymentStatus indicates an expected call of CreateDeploymentStatus.
+func (mr *MockrepositoriesServiceMockRecorder) CreateDeploymentStatus(ctx, owner, repo, deployment, request interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateDeploymentStatus", reflect.TypeOf((*MockrepositoriesService)(nil).CreateDeploymentStatus), ctx, owner, repo, deployment, request)
+}
+
+// CreateStatus mocks base method.
+func (m *MockrepositoriesService) CreateStatus(ctx context.Context, owner, repo, ref string, status *github.RepoStatus) (*github.RepoStatus, *github.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateStatus", ctx, owner, repo, ref, status)
+	ret0, _ := ret[0].(*github.RepoStatus)
+	ret1, _ := ret[1].(*github.Response)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// CreateStatus indicates an expected call of CreateStatus.
+func (mr *MockrepositoriesServiceMockRecorder) CreateStatus(ctx, owner, repo, ref, status interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateStatus", reflect.TypeOf((*MockrepositoriesService)(nil).CreateStatus), ctx, owner, repo, ref, status)
+}
+
 // Get mocks base method.
 func (m *MockrepositoriesService) Get(ctx context.Context, owner, repo string) (*github.Repository, *github.Response, error) {
 	m.ctrl.T.Helper()
@@ -263,3 +311,380 @@ func (mr *MockrepositoriesServiceMockRecorder) Get(ctx, owner, repo interface{})
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockrepositoriesService)(nil).Get), ctx, owner, repo)
 }
+
+// GetPermissionLevel mocks base method.
+func (m *MockrepositoriesService) GetPermissionLevel(ctx context.Context, owner, repo, user string) (*github.RepositoryPermissionLevel, *github.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPermissionLevel", ctx, owner, repo, user)
+	ret0, _ := ret[0].(*github.RepositoryPermissionLevel)
+	ret1, _ := ret[1].(*github.Response)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetPermissionLevel indicates an expected call of GetPermissionLevel.
+func (mr *MockrepositoriesServiceMockRecorder) GetPermissionLevel(ctx, owner, repo, user interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPermissionLevel", reflect.TypeOf((*MockrepositoriesService)(nil).GetPermissionLevel), ctx, owner, repo, user)
+}
+
+// MockorganizationsService is a mock of organizationsService interface.
+type MockorganizationsService struct {
+	ctrl     *gomock.Controller
+	recorder *MockorganizationsServiceMockRecorder
+}
+
+// MockorganizationsServiceMockRecorder is the mock recorder for MockorganizationsService.
+type MockorganizationsServiceMockRecorder struct {
+	mock *MockorganizationsService
+}
+
+// NewMockorganizationsService creates a new mock instance.
+func NewMockorganizationsService(ctrl *gomock.Controller) *MockorganizationsService {
+	mock := &MockorganizationsService{ctrl: ctrl}
+	mock.recorder = &MockorganizationsServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockorganizationsService) EXPECT() *MockorganizationsServiceMockRecorder {
+	return m.recorder
+}
+
+// IsMember mocks base method.
+func (m *MockorganizationsService) IsMember(ctx context.Context, org, user string) (bool, *github.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsMember", ctx, org, user)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(*github.Response)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// IsMember indicates an expected call of IsMember.
+func (mr *MockorganizationsServiceMockRecorder) IsMember(ctx, org, user interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsMember", reflect.TypeOf((*MockorganizationsService)(nil).IsMember), ctx, org, user)
+}
+
+// MockteamsService is a mock of teamsService interface.
+type MockteamsService struct {
+	ctrl     *gomock.Controller
+	recorder *MockteamsServiceMockRecorder
+}
+
+// MockteamsServiceMockRecorder is the mock recorder for MockteamsService.
+type MockteamsServiceMockRecorder struct {
+	mock *MockteamsService
+}
+
+// NewMockteamsService creates a new mock instance.
+func NewMockteamsService(ctrl *gomock.Controller) *MockteamsService {
+	mock := &MockteamsService{ctrl: ctrl}
+	mock.recorder = &MockteamsServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockteamsService) EXPECT() *MockteamsServiceMockRecorder {
+	return m.recorder
+}
+
+// GetTeamMembershipBySlug mocks base method.
+func (m *MockteamsService) GetTeamMembershipBySlug(ctx context.Context, org, slug, user string) (*github.Membership, *github.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTeamMembershipBySlug", ctx, org, slug, user)
+	ret0, _ := ret[0].(*github.Membership)
+	ret1, _ := ret[1].(*github.Response)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetTeamMembershipBySlug indicates an expected call of GetTeamMembershipBySlug.
+func (mr *MockteamsServiceMockRecorder) GetTeamMembershipBySlug(ctx, org, slug, user interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTeamMembershipBySlug", reflect.TypeOf((*MockteamsService)(nil).GetTeamMembershipBySlug), ctx, org, slug, user)
+}
+
+// MockchecksService is a mock of checksService interface.
+type MockchecksService struct {
+	ctrl     *gomock.Controller
+	recorder *MockchecksServiceMockRecorder
+}
+
+// MockchecksServiceMockRecorder is the mock recorder for MockchecksService.
+type MockchecksServiceMockRecorder struct {
+	mock *MockchecksService
+}
+
+// NewMockchecksService creates a new mock instance.
+func NewMockchecksService(ctrl *gomock.Controller) *MockchecksService {
+	mock := &MockchecksService{ctrl: ctrl}
+	mock.recorder = &MockchecksServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockchecksService) EXPECT() *MockchecksServiceMockRecorder {
+	return m.recorder
+}
+
+// CreateCheckRun mocks base method.
+func (m *MockchecksService) CreateCheckRun(ctx context.Context, owner, repo string, opts github.CreateCheckRunOptions) (*github.CheckRun, *github.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateCheckRun", ctx, owner, repo, opts)
+	ret0, _ := ret[0].(*github.CheckRun)
+	ret1, _ := ret[1].(*github.Response)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// CreateCheckRun indicates an expected call of CreateCheckRun.
+func (mr *MockchecksServiceMockRecorder) CreateCheckRun(ctx, owner, repo, opts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCheckRun", reflect.TypeOf((*MockchecksService)(nil).CreateCheckRun), ctx, owner, repo, opts)
+}
+
+// ListCheckRunsForRef mocks base method.
+func (m *MockchecksService) ListCheckRunsForRef(ctx context.Context, owner, repo, ref string, opts *github.ListCheckRunsOptions) (*github.ListCheckRunsResults, *github.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListCheckRunsForRef", ctx, owner, repo, ref, opts)
+	ret0, _ := ret[0].(*github.ListCheckRunsResults)
+	ret1, _ := ret[1].(*github.Response)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ListCheckRunsForRef indicates an expected call of ListCheckRunsForRef.
+func (mr *MockchecksServiceMockRecorder) ListCheckRunsForRef(ctx, owner, repo, ref, opts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListCheckRunsForRef", reflect.TypeOf((*MockchecksService)(nil).ListCheckRunsForRef), ctx, owner, repo, ref, opts)
+}
+
+// UpdateCheckRun mocks base method.
+func (m *MockchecksService) UpdateCheckRun(ctx context.Context, owner, repo string, checkRunID int64, opts github.UpdateCheckRunOptions) (*github.CheckRun, *github.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateCheckRun", ctx, owner, repo, checkRunID, opts)
+	ret0, _ := ret[0].(*github.CheckRun)
+	ret1, _ := ret[1].(*github.Response)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// UpdateCheckRun indicates an expected call of UpdateCheckRun.
+func (mr *MockchecksServiceMockRecorder) UpdateCheckRun(ctx, owner, repo, checkRunID, opts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateCheckRun", reflect.TypeOf((*MockchecksService)(nil).UpdateCheckRun), ctx, owner, repo, checkRunID, opts)
+}
+
+// MockissuesService is a mock of issuesService interface.
+type MockissuesService struct {
+	ctrl     *gomock.Controller
+	recorder *MockissuesServiceMockRecorder
+}
+
+// MockissuesServiceMockRecorder is the mock recorder for MockissuesService.
+type MockissuesServiceMockRecorder struct {
+	mock *MockissuesService
+}
+
+// NewMockissuesService creates a new mock instance.
+func NewMockissuesService(ctrl *gomock.Controller) *MockissuesService {
+	mock := &MockissuesService{ctrl: ctrl}
+	mock.recorder = &MockissuesServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockissuesService) EXPECT() *MockissuesServiceMockRecorder {
+	return m.recorder
+}
+
+// CreateComment mocks base method.
+func (m *MockissuesService) CreateComment(ctx context.Context, owner, repo string, number int, comment *github.IssueComment) (*github.IssueComment, *github.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateComment", ctx, owner, repo, number, comment)
+	ret0, _ := ret[0].(*github.IssueComment)
+	ret1, _ := ret[1].(*github.Response)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// CreateComment indicates an expected call of CreateComment.
+func (mr *MockissuesServiceMockRecorder) CreateComment(ctx, owner, repo, number, comment interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateComment", reflect.TypeOf((*MockissuesService)(nil).CreateComment), ctx, owner, repo, number, comment)
+}
+
+// EditComment mocks base method.
+func (m *MockissuesService) EditComment(ctx context.Context, owner, repo string, commentID int64, comment *github.IssueComment) (*github.IssueComment, *github.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EditComment", ctx, owner, repo, commentID, comment)
+	ret0, _ := ret[0].(*github.IssueComment)
+	ret1, _ := ret[1].(*github.Response)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// EditComment indicates an expected call of EditComment.
+func (mr *MockissuesServiceMockRecorder) EditComment(ctx, owner, repo, commentID, comment interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EditComment", reflect.TypeOf((*MockissuesService)(nil).EditComment), ctx, owner, repo, commentID, comment)
+}
+
+// ListComments mocks base method.
+func (m *MockissuesService) ListComments(ctx context.Context, owner, repo string, number int, opts *github.IssueListCommentsOptions) ([]*github.IssueComment, *github.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListComments", ctx, owner, repo, number, opts)
+	ret0, _ := ret[0].([]*github.IssueComment)
+	ret1, _ := ret[1].(*github.Response)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ListComments indicates an expected call of ListComments.
+func (mr *MockissuesServiceMockRecorder) ListComments(ctx, owner, repo, number, opts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListComments", reflect.TypeOf((*MockissuesService)(nil).ListComments), ctx, owner, repo, number, opts)
+}
+
+// Login mocks base method.
+func (m *MockissuesService) Login(ctx context.Context, owner, repo string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Login", ctx, owner, repo)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Login indicates an expected call of Login.
+func (mr *MockissuesServiceMockRecorder) Login(ctx, owner, repo interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Login", reflect.TypeOf((*MockissuesService)(nil).Login), ctx, owner, repo)
+}
+
+// MockpullRequestsService is a mock of pullRequestsService interface.
+type MockpullRequestsService struct {
+	ctrl     *gomock.Controller
+	recorder *MockpullRequestsServiceMockRecorder
+}
+
+// MockpullRequestsServiceMockRecorder is the mock recorder for MockpullRequestsService.
+type MockpullRequestsServiceMockRecorder struct {
+	mock *MockpullRequestsService
+}
+
+// NewMockpullRequestsService creates a new mock instance.
+func NewMockpullRequestsService(ctrl *gomock.Controller) *MockpullRequestsService {
+	mock := &MockpullRequestsService{ctrl: ctrl}
+	mock.recorder = &MockpullRequestsServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockpullRequestsService) EXPECT() *MockpullRequestsServiceMockRecorder {
+	return m.recorder
+}
+
+// Get mocks base method.
+func (m *MockpullRequestsService) Get(ctx context.Context, owner, repo string, number int) (*github.PullRequest, *github.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", ctx, owner, repo, number)
+	ret0, _ := ret[0].(*github.PullRequest)
+	ret1, _ := ret[1].(*github.Response)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockpullRequestsServiceMockRecorder) Get(ctx, owner, repo, number interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockpullRequestsService)(nil).Get), ctx, owner, repo, number)
+}
+
+// MockappsService is a mock of appsService interface.
+type MockappsService struct {
+	ctrl     *gomock.Controller
+	recorder *MockappsServiceMockRecorder
+}
+
+// MockappsServiceMockRecorder is the mock recorder for MockappsService.
+type MockappsServiceMockRecorder struct {
+	mock *MockappsService
+}
+
+// NewMockappsService creates a new mock instance.
+func NewMockappsService(ctrl *gomock.Controller) *MockappsService {
+	mock := &MockappsService{ctrl: ctrl}
+	mock.recorder = &MockappsServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockappsService) EXPECT() *MockappsServiceMockRecorder {
+	return m.recorder
+}
+
+// CreateInstallationToken mocks base method.
+func (m *MockappsService) CreateInstallationToken(ctx context.Context, id int64, opts *github.InstallationTokenOptions) (*github.InstallationToken, *github.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateInstallationToken", ctx, id, opts)
+	ret0, _ := ret[0].(*github.InstallationToken)
+	ret1, _ := ret[1].(*github.Response)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// CreateInstallationToken indicates an expected call of CreateInstallationToken.
+func (mr *MockappsServiceMockRecorder) CreateInstallationToken(ctx, id, opts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateInstallationToken", reflect.TypeOf((*MockappsService)(nil).CreateInstallationToken), ctx, id, opts)
+}
+
+// FindOrganizationInstallation mocks base method.
+func (m *MockappsService) FindOrganizationInstallation(ctx context.Context, org string) (*github.Installation, *github.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindOrganizationInstallation", ctx, org)
+	ret0, _ := ret[0].(*github.Installation)
+	ret1, _ := ret[1].(*github.Response)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// FindOrganizationInstallation indicates an expected call of FindOrganizationInstallation.
+func (mr *MockappsServiceMockRecorder) FindOrganizationInstallation(ctx, org interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOrganizationInstallation", reflect.TypeOf((*MockappsService)(nil).FindOrganizationInstallation), ctx, org)
+}
+
+// FindRepositoryInstallation mocks base method.
+func (m *MockappsService) FindRepositoryInstallation(ctx context.Context, owner, repo string) (*github.Installation, *github.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindRepositoryInstallation", ctx, owner, repo)
+	ret0, _ := ret[0].(*github.Installation)
+	ret1, _ := ret[1].(*github.Response)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// FindRepositoryInstallation indicates an expected call of FindRepositoryInstallation.
+func (mr *MockappsServiceMockRecorder) FindRepositoryInstallation(ctx, owner, repo interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindRepositoryInstallation", reflect.TypeOf((*MockappsService)(nil).FindRepositoryInstallation), ctx, owner, repo)
+}
+
+// Get mocks base method.
+func (m *MockappsService) Get(ctx context.Context, appSlug string) (*github.App, *github.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", ctx, appSlug)
+	ret0, _ := ret[0].(*github.App)
+	ret1, _ := ret[1].(*github.Response)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockappsServiceMockRecorder) Get(ctx, appSlug interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockappsService)(nil).Get), ctx, appSlug)
+}
@@ -0,0 +1,168 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v33/github"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+	"go.uber.org/zap"
+	"knative.dev/pkg/logging"
+	"knative.dev/pkg/metrics"
+)
+
+// privateKeyReloadInterval is how often the mounted private key of the Github
+// App is checked for changes. Kubernetes takes up to a minute to propagate
+// updated Secrets to pods anyway.
+const privateKeyReloadInterval = 30 * time.Second
+
+var (
+	privateKeyReloadsM = stats.Int64(
+		"github_private_key_reloads",
+		"Number of attempts to reload a rotated private key of the Github App by result",
+		stats.UnitDimensionless)
+
+	// Whether the rotated key was loaded (success) or rejected (failure), in
+	// which case the previous key is still in use.
+	reloadResultKey = tag.MustNewKey("result")
+)
+
+// reloadingClientFactory implements ClientFactory by delegating to the
+// factory of the Github App, whose private key is swapped whenever it changes.
+// Installations found so far and rate limits are kept across rotations, while
+// clients already handed out keep the key they were built with, so requests
+// in flight aren't affected.
+type reloadingClientFactory struct {
+	path    string
+	build   func(privateKey []byte) (appsService, func(installationID int64) (*github.Client, error), error)
+	factory *installationClientFactory
+
+	mutex      sync.Mutex
+	privateKey []byte
+}
+
+// newReloadingClientFactory returns a new reloadingClientFactory for the
+// private key stored at the supplied path. All requests go through the
+// installation in question when installationID isn't zero.
+func newReloadingClientFactory(path string, installationID int64, build func(privateKey []byte) (appsService, func(installationID int64) (*github.Client, error), error)) (*reloadingClientFactory, error) {
+	privateKey, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the Github App private key from %s: %w", path, err)
+	}
+
+	apps, newClient, err := build(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing the Github App private key from %s: %w", path, err)
+	}
+
+	factory := newInstallationClientFactory(apps, newClient)
+	factory.installationID = installationID
+
+	return &reloadingClientFactory{path: path,
+		build:      build,
+		factory:    factory,
+		privateKey: privateKey,
+	}, nil
+}
+
+// ClientFor implements ClientFactory.
+func (r *reloadingClientFactory) ClientFor(ctx context.Context, owner, repo string) (*github.Client, error) {
+	return r.factory.ClientFor(ctx, owner, repo)
+}
+
+// CreateInstallationToken implements installationTokenService.
+func (r *reloadingClientFactory) CreateInstallationToken(ctx context.Context, owner, repo string, opts *github.InstallationTokenOptions) (*github.InstallationToken, *github.Response, error) {
+	return r.factory.CreateInstallationToken(ctx, owner, repo, opts)
+}
+
+// Login implements loginService.
+func (r *reloadingClientFactory) Login(ctx context.Context, owner, repo string) (string, error) {
+	return r.factory.Login(ctx, owner, repo)
+}
+
+// reload swaps the private key of the factory when it has changed. It returns
+// true if it did. Rotated keys are checked against Github first, and the
+// current key is kept when they can't be read or are rejected.
+func (r *reloadingClientFactory) reload(ctx context.Context) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	privateKey, err := ioutil.ReadFile(r.path)
+	if err != nil {
+		return false, fmt.Errorf("Error reading the Github App private key from %s: %w", r.path, err)
+	}
+
+	if bytes.Equal(privateKey, r.privateKey) {
+		return false, nil
+	}
+
+	apps, newClient, err := r.build(privateKey)
+	if err == nil {
+		err = verifyApp(ctx, apps)
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("Error loading the rotated Github App private key from %s, the previous key is still in use: %w", r.path, err)
+	}
+
+	r.factory.rotate(apps, newClient)
+	r.privateKey = privateKey
+	return true, nil
+}
+
+// watch reloads the private key periodically until the supplied context is
+// done. The mounted file is polled rather than watched for events, since
+// Kubernetes updates Secret volumes by swapping symbolic links.
+func (r *reloadingClientFactory) watch(ctx context.Context) {
+	logger := logging.FromContext(ctx).With("path", r.path)
+
+	ticker := time.NewTicker(privateKeyReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			reloaded, err := r.reload(ctx)
+			if err != nil {
+				logger.Errorw("Error reloading the Github App private key", zap.Error(err))
+				recordPrivateKeyReload(ctx, "failure")
+			} else if reloaded {
+				logger.Info("Github App private key has been successfully reloaded")
+				recordPrivateKeyReload(ctx, "success")
+			}
+		}
+	}
+}
+
+// recordPrivateKeyReload counts an attempt to reload the private key.
+func recordPrivateKeyReload(ctx context.Context, result string) {
+	ctx, err := tag.New(ctx, tag.Upsert(reloadResultKey, result))
+	if err != nil {
+		return
+	}
+	metrics.Record(ctx, privateKeyReloadsM.M(1))
+}
+
+// verifyApp authenticates as the Github App, so that private keys Github
+// doesn't accept (e.g. keys of other Apps or keys that were revoked) are
+// caught before requests are made with them.
+func verifyApp(ctx context.Context, apps appsService) error {
+	_, response, err := apps.Get(ctx, "")
+	if response != nil && response.StatusCode == http.StatusUnauthorized {
+		return fmt.Errorf("Github rejected the private key of the App: %w", err)
+	}
+
+	if err != nil {
+		return fmt.Errorf("Error authenticating as the Github App: %w", err)
+	}
+	return nil
+}
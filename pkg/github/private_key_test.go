@@ -0,0 +1,239 @@
+package github
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/go-github/v33/github"
+	"github.com/gregjones/httpcache"
+	githubmocks "github.com/nubank/workflows/pkg/github/mocks"
+)
+
+// newTestReloadingClientFactory returns a reloadingClientFactory for a key
+// stored in a temporary file, which authenticates through the supplied service
+// whatever the key.
+func newTestReloadingClientFactory(t *testing.T, apps appsService, installationID int64) (*reloadingClientFactory, string) {
+	path := filepath.Join(t.TempDir(), "private-key")
+	if err := ioutil.WriteFile(path, []byte("first-key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	factory, err := newReloadingClientFactory(path, installationID, func(privateKey []byte) (appsService, func(installationID int64) (*github.Client, error), error) {
+		if string(privateKey) == "malformed-key" {
+			return nil, nil, errors.New("invalid key: Key must be a PEM encoded PKCS1 or PKCS8 key")
+		}
+
+		return apps, func(installationID int64) (*github.Client, error) {
+			return github.NewClient(nil), nil
+		}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return factory, path
+}
+
+func TestReloadRotatedPrivateKey(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	appsService := githubmocks.NewMockappsService(mockCtrl)
+	ctx := context.Background()
+
+	factory, path := newTestReloadingClientFactory(t, appsService, 1)
+
+	// Unchanged keys are neither verified nor reloaded.
+	reloaded, err := factory.reload(ctx)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if reloaded {
+		t.Error("Want the unchanged key to be kept")
+	}
+
+	client, err := factory.ClientFor(ctx, "john-doe", "my-repo")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	appsService.EXPECT().
+		Get(ctx, "").
+		Return(&github.App{}, &github.Response{Response: &http.Response{StatusCode: 200}}, nil).
+		Times(1)
+
+	if err := ioutil.WriteFile(path, []byte("second-key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err = factory.reload(ctx)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !reloaded {
+		t.Fatal("Want the rotated key to be reloaded")
+	}
+
+	rotated, err := factory.ClientFor(ctx, "john-doe", "my-repo")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if rotated == client {
+		t.Error("Want a new client authenticated with the rotated key")
+	}
+}
+
+func TestReloadKeepsTheInstallationsFound(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	appsService := githubmocks.NewMockappsService(mockCtrl)
+	ctx := context.Background()
+
+	factory, path := newTestReloadingClientFactory(t, appsService, 0)
+
+	// The installation is only looked up once, whatever the key.
+	appsService.EXPECT().
+		FindRepositoryInstallation(ctx, "john-doe", "my-repo").
+		Return(&github.Installation{ID: github.Int64(42)}, &github.Response{Response: &http.Response{StatusCode: 200}}, nil).
+		Times(1)
+
+	appsService.EXPECT().
+		Get(ctx, "").
+		Return(&github.App{}, &github.Response{Response: &http.Response{StatusCode: 200}}, nil).
+		Times(1)
+
+	if _, err := factory.ClientFor(ctx, "john-doe", "my-repo"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("second-key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if reloaded, err := factory.reload(ctx); err != nil || !reloaded {
+		t.Fatalf("Want the rotated key to be reloaded, got %v", err)
+	}
+
+	if _, err := factory.ClientFor(ctx, "john-doe", "my-repo"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestAppClientBuilderKeepsRateLimitsAcrossKeys(t *testing.T) {
+	builder := newAppClientBuilder(DefaultHost(), httpcache.NewMemoryCache(), 1)
+
+	if _, _, err := builder.build(newPrivateKey(t)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Installation #42 ran out of requests with the first key.
+	resetAt := time.Now().Add(time.Hour)
+	builder.rateLimitTransport("42").blockedUntil = resetAt
+
+	_, newClient, err := builder.build(newPrivateKey(t))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	client, err := newClient(42)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The token of the installation can't even be issued without hitting
+	// Github.
+	_, _, err = client.Repositories.Get(context.Background(), "john-doe", "my-repo")
+	want := (&RateLimitError{ResetAt: resetAt}).Error()
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("Want error containing %q, got %v", want, err)
+	}
+}
+
+// newPrivateKey returns a new PEM encoded RSA private key.
+func newPrivateKey(t *testing.T) []byte {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+}
+
+func TestReloadKeepsThePreviousKeyWhenTheRotatedOneIsInvalid(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	appsService := githubmocks.NewMockappsService(mockCtrl)
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		key     string
+		setup   func()
+		wantErr string
+	}{
+		{
+			name:    "malformed key",
+			key:     "malformed-key",
+			setup:   func() {},
+			wantErr: "invalid key",
+		},
+		{
+			name: "key rejected by Github",
+			key:  "revoked-key",
+			setup: func() {
+				appsService.EXPECT().
+					Get(ctx, "").
+					Return(nil, &github.Response{Response: &http.Response{StatusCode: 401}}, errors.New("401 A JSON web token could not be decoded")).
+					Times(1)
+			},
+			wantErr: "Github rejected the private key of the App",
+		},
+		{
+			name:    "missing key",
+			setup:   func() {},
+			wantErr: "Error reading the Github App private key",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			factory, path := newTestReloadingClientFactory(t, appsService, 1)
+			previous, err := factory.ClientFor(ctx, "john-doe", "my-repo")
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			test.setup()
+
+			if test.key == "" {
+				if err := os.Remove(path); err != nil {
+					t.Fatal(err)
+				}
+			} else if err := ioutil.WriteFile(path, []byte(test.key), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			reloaded, err := factory.reload(ctx)
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("Want error containing %q, got %v", test.wantErr, err)
+			}
+
+			current, err := factory.ClientFor(ctx, "john-doe", "my-repo")
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if reloaded || current != previous {
+				t.Error("Want the previous key to be kept")
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package github
+
+import (
+	"context"
+	"fmt"
+)
+
+// PullRequest holds the details of a Github pull request that workflows need
+// to run against it.
+type PullRequest struct {
+	Number        int
+	Title         string
+	HeadBranch    string
+	HeadCommitSHA string
+	BaseBranch    string
+	BaseCommitSHA string
+}
+
+// PullRequestReader reads pull requests from Github repositories.
+type PullRequestReader interface {
+
+	// GetPullRequest returns the current state of the pull request in
+	// question.
+	GetPullRequest(ctx context.Context, owner, repo string, number int) (*PullRequest, error)
+}
+
+// defaultPullRequestReader implements PullRequestReader.
+type defaultPullRequestReader struct {
+	service pullRequestsService
+}
+
+// GetPullRequest implements PullRequestReader.GetPullRequest.
+func (d *defaultPullRequestReader) GetPullRequest(ctx context.Context, owner, repo string, number int) (*PullRequest, error) {
+	pullRequest, _, err := d.service.Get(ctx, owner, repo, number)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading pull request #%d on %s/%s: %w", number, owner, repo, err)
+	}
+
+	return &PullRequest{Number: pullRequest.GetNumber(),
+		Title:         pullRequest.GetTitle(),
+		HeadBranch:    getPullRequestBranch(pullRequest.GetHead().GetRef()),
+		HeadCommitSHA: pullRequest.GetHead().GetSHA(),
+		BaseBranch:    getPullRequestBranch(pullRequest.GetBase().GetRef()),
+		BaseCommitSHA: pullRequest.GetBase().GetSHA(),
+	}, nil
+}
+
+// NewPullRequestReader creates a new PullRequestReader object.
+func NewPullRequestReader(clients ClientFactory) PullRequestReader {
+	return &defaultPullRequestReader{service: &pullRequestsServices{clients: clients}}
+}
@@ -0,0 +1,72 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-github/v33/github"
+	githubmocks "github.com/nubank/workflows/pkg/github/mocks"
+)
+
+func TestGetPullRequest(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	pullRequestsService := githubmocks.NewMockpullRequestsService(mockCtrl)
+	reader := &defaultPullRequestReader{service: pullRequestsService}
+
+	ctx := context.Background()
+
+	// Mock setup
+	pullRequestsService.EXPECT().
+		Get(ctx, "john-doe", "my-repo", 7).
+		Return(&github.PullRequest{Number: github.Int(7),
+			Title: github.String("Add foo package"),
+			Head: &github.PullRequestBranch{
+				Ref: github.String("dev"),
+				SHA: github.String("833568e"),
+			},
+			Base: &github.PullRequestBranch{
+				Ref: github.String("main"),
+				SHA: github.String("a1b2c3d"),
+			},
+		}, nil, nil)
+
+	got, err := reader.GetPullRequest(ctx, "john-doe", "my-repo", 7)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := &PullRequest{Number: 7,
+		Title:         "Add foo package",
+		HeadBranch:    "dev",
+		HeadCommitSHA: "833568e",
+		BaseBranch:    "main",
+		BaseCommitSHA: "a1b2c3d",
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestGetPullRequestReturnsAnErrorWhenGithubFails(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	pullRequestsService := githubmocks.NewMockpullRequestsService(mockCtrl)
+	reader := &defaultPullRequestReader{service: pullRequestsService}
+
+	ctx := context.Background()
+
+	// Mock setup
+	pullRequestsService.EXPECT().
+		Get(ctx, "john-doe", "my-repo", 7).
+		Return(nil, nil, errors.New("Boom!"))
+
+	_, err := reader.GetPullRequest(ctx, "john-doe", "my-repo", 7)
+
+	want := "Error reading pull request #7 on john-doe/my-repo: Boom!"
+	if err == nil || err.Error() != want {
+		t.Errorf("Want error %s, got %v", want, err)
+	}
+}
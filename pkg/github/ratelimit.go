@@ -0,0 +1,283 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"knative.dev/pkg/metrics"
+)
+
+const (
+
+	// Headers through which Github reports the state of rate limits.
+	rateLimitLimitHeader     = "X-RateLimit-Limit"
+	rateLimitRemainingHeader = "X-RateLimit-Remaining"
+	rateLimitResetHeader     = "X-RateLimit-Reset"
+	rateLimitResourceHeader  = "X-RateLimit-Resource"
+	retryAfterHeader         = "Retry-After"
+
+	// How long requests are held back after hitting a secondary rate limit
+	// that doesn't tell when to retry, as advised by Github.
+	defaultSecondaryRateLimitWait = time.Minute
+
+	// How many bytes of error responses are inspected to tell secondary rate
+	// limits apart from permission errors.
+	maxRateLimitBodyBytes = 4096
+)
+
+var (
+	rateLimitRemainingM = stats.Int64(
+		"github_rate_limit_remaining",
+		"Number of requests remaining in the current Github rate limit window",
+		stats.UnitDimensionless)
+
+	rateLimitLimitM = stats.Int64(
+		"github_rate_limit_limit",
+		"Maximum number of requests allowed in a Github rate limit window",
+		stats.UnitDimensionless)
+
+	rateLimitedRequestsM = stats.Int64(
+		"github_rate_limited_requests",
+		"Number of requests to Github that were refused or held back due to rate limits",
+		stats.UnitDimensionless)
+
+	// Identifies whose quota the measurement refers to (i.e. an installation
+	// id, the App itself or a token).
+	installationKey = tag.MustNewKey("installation")
+
+	// Rate limit resource (e.g. core, search or graphql).
+	resourceKey = tag.MustNewKey("resource")
+
+	// Whether the primary or a secondary rate limit was hit.
+	limitKey = tag.MustNewKey("limit")
+)
+
+// RegisterMetrics registers views exporting the state of Github rate limits,
+// of the cache of Github API responses and of private key reloads.
+// It panics if views cannot be registered.
+func RegisterMetrics() {
+	if err := view.Register(
+		&view.View{
+			Description: rateLimitRemainingM.Description(),
+			Measure:     rateLimitRemainingM,
+			Aggregation: view.LastValue(),
+			TagKeys:     []tag.Key{installationKey, resourceKey},
+		},
+		&view.View{
+			Description: rateLimitLimitM.Description(),
+			Measure:     rateLimitLimitM,
+			Aggregation: view.LastValue(),
+			TagKeys:     []tag.Key{installationKey, resourceKey},
+		},
+		&view.View{
+			Description: rateLimitedRequestsM.Description(),
+			Measure:     rateLimitedRequestsM,
+			Aggregation: view.Count(),
+			TagKeys:     []tag.Key{installationKey, limitKey},
+		},
+		&view.View{
+			Description: cacheRequestsM.Description(),
+			Measure:     cacheRequestsM,
+			Aggregation: view.Count(),
+			TagKeys:     []tag.Key{installationKey, cacheResultKey},
+		},
+		&view.View{
+			Description: privateKeyReloadsM.Description(),
+			Measure:     privateKeyReloadsM,
+			Aggregation: view.Count(),
+			TagKeys:     []tag.Key{reloadResultKey},
+		},
+	); err != nil {
+		panic(err)
+	}
+}
+
+// RateLimitError is returned when requests can't be made to Github until a
+// rate limit resets.
+type RateLimitError struct {
+
+	// When requests can be made again.
+	ResetAt time.Time
+
+	// Whether a secondary (abuse) rate limit was hit rather than the quota of
+	// requests per hour being exhausted.
+	Secondary bool
+}
+
+// Error satisfies the error interface.
+func (r *RateLimitError) Error() string {
+	limit := "rate limit"
+	if r.Secondary {
+		limit = "secondary rate limit"
+	}
+	return fmt.Sprintf("Github %s exceeded, requests are held back until %s", limit, r.ResetAt.UTC().Format(time.RFC3339))
+}
+
+// RetryAfter returns how long to wait before calling Github again if the
+// supplied error was caused by a rate limit. It returns false otherwise.
+func RetryAfter(err error) (time.Duration, bool) {
+	var rateLimitErr *RateLimitError
+	if !errors.As(err, &rateLimitErr) {
+		return 0, false
+	}
+
+	delay := time.Until(rateLimitErr.ResetAt)
+	if delay < time.Second {
+		delay = time.Second
+	}
+	return delay, true
+}
+
+// rateLimitTransport tracks the rate limit of the installation (or token)
+// requests are authenticated with. Once the limit is hit, requests are held
+// back until it resets, failing with a RateLimitError instead of reaching
+// Github.
+type rateLimitTransport struct {
+	transport    http.RoundTripper
+	installation string
+	now          func() time.Time
+
+	mutex        sync.Mutex
+	blockedUntil time.Time
+}
+
+// newRateLimitTransport returns a new rateLimitTransport whose metrics are
+// tagged with the supplied installation.
+func newRateLimitTransport(transport http.RoundTripper, installation string) *rateLimitTransport {
+	return &rateLimitTransport{transport: transport,
+		installation: installation,
+		now:          time.Now,
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.mutex.Lock()
+	blockedUntil := r.blockedUntil
+	r.mutex.Unlock()
+
+	if r.now().Before(blockedUntil) {
+		r.recordRateLimited(req.Context(), false)
+		return nil, &RateLimitError{ResetAt: blockedUntil}
+	}
+
+	response, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	r.recordQuota(req.Context(), response)
+
+	rateLimitErr := r.rateLimitErrorOf(response)
+	if rateLimitErr == nil {
+		return response, nil
+	}
+	response.Body.Close()
+
+	r.mutex.Lock()
+	if rateLimitErr.ResetAt.After(r.blockedUntil) {
+		r.blockedUntil = rateLimitErr.ResetAt
+	}
+	r.mutex.Unlock()
+
+	r.recordRateLimited(req.Context(), rateLimitErr.Secondary)
+	return nil, rateLimitErr
+}
+
+// rateLimitErrorOf returns a RateLimitError if the supplied response means
+// that a rate limit was hit or nil otherwise.
+func (r *rateLimitTransport) rateLimitErrorOf(response *http.Response) *RateLimitError {
+	if response.StatusCode != http.StatusForbidden && response.StatusCode != http.StatusTooManyRequests {
+		return nil
+	}
+
+	// Secondary rate limits usually tell how many seconds to wait.
+	if value := response.Header.Get(retryAfterHeader); value != "" {
+		if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return &RateLimitError{ResetAt: r.now().Add(time.Duration(seconds) * time.Second), Secondary: true}
+		}
+	}
+
+	if response.Header.Get(rateLimitRemainingHeader) == "0" {
+		if reset, err := strconv.ParseInt(response.Header.Get(rateLimitResetHeader), 10, 64); err == nil {
+			return &RateLimitError{ResetAt: time.Unix(reset, 0)}
+		}
+	}
+
+	if response.StatusCode == http.StatusTooManyRequests || mentionsSecondaryRateLimit(response) {
+		return &RateLimitError{ResetAt: r.now().Add(defaultSecondaryRateLimitWait), Secondary: true}
+	}
+
+	// Other 403 responses are permission errors left to callers.
+	return nil
+}
+
+// mentionsSecondaryRateLimit returns true if the body of the supplied
+// response says that a secondary rate limit was hit. The body can still be
+// read by callers afterwards.
+func mentionsSecondaryRateLimit(response *http.Response) bool {
+	head, err := ioutil.ReadAll(io.LimitReader(response.Body, maxRateLimitBodyBytes))
+	response.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), response.Body), response.Body}
+
+	if err != nil {
+		return false
+	}
+
+	message := strings.ToLower(string(head))
+	return strings.Contains(message, "secondary rate limit") || strings.Contains(message, "abuse detection")
+}
+
+// recordQuota exports the state of the rate limit reported by the supplied
+// response, if any.
+func (r *rateLimitTransport) recordQuota(ctx context.Context, response *http.Response) {
+	remaining, err := strconv.ParseInt(response.Header.Get(rateLimitRemainingHeader), 10, 64)
+	if err != nil {
+		return
+	}
+
+	resource := response.Header.Get(rateLimitResourceHeader)
+	if resource == "" {
+		resource = "core"
+	}
+
+	ctx, err = tag.New(ctx, tag.Upsert(installationKey, r.installation), tag.Upsert(resourceKey, resource))
+	if err != nil {
+		return
+	}
+
+	measurements := []stats.Measurement{rateLimitRemainingM.M(remaining)}
+	if limit, err := strconv.ParseInt(response.Header.Get(rateLimitLimitHeader), 10, 64); err == nil {
+		measurements = append(measurements, rateLimitLimitM.M(limit))
+	}
+	metrics.RecordBatch(ctx, measurements...)
+}
+
+// recordRateLimited counts a request refused or held back due to the primary
+// or a secondary rate limit.
+func (r *rateLimitTransport) recordRateLimited(ctx context.Context, secondary bool) {
+	limit := "primary"
+	if secondary {
+		limit = "secondary"
+	}
+
+	ctx, err := tag.New(ctx, tag.Upsert(installationKey, r.installation), tag.Upsert(limitKey, limit))
+	if err != nil {
+		return
+	}
+	metrics.Record(ctx, rateLimitedRequestsM.M(1))
+}
@@ -0,0 +1,175 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v33/github"
+)
+
+// newRateLimitedClient returns a client talking to a server that answers with
+// the supplied handler through a rateLimitTransport, along with a pointer to
+// the number of requests the server received.
+func newRateLimitedClient(t *testing.T, handler func(w http.ResponseWriter)) (*github.Client, *rateLimitTransport, *int) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		handler(w)
+	}))
+	t.Cleanup(server.Close)
+
+	host := &Host{APIURL: server.URL + "/api/v3/",
+		UploadURL: server.URL + "/api/uploads/",
+		GitURL:    server.URL,
+	}
+
+	transport := newRateLimitTransport(http.DefaultTransport, "42")
+	client, err := newHostClient(host, &http.Client{Transport: transport})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, transport, &requests
+}
+
+func TestRateLimitTransportHoldsRequestsBackUntilReset(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	reset := now.Add(10 * time.Minute)
+
+	exhausted := true
+	client, transport, requests := newRateLimitedClient(t, func(w http.ResponseWriter) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set(rateLimitLimitHeader, "5000")
+		w.Header().Set(rateLimitResetHeader, strconv.FormatInt(reset.Unix(), 10))
+		if exhausted {
+			w.Header().Set(rateLimitRemainingHeader, "0")
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"message": "API rate limit exceeded"}`))
+			return
+		}
+		w.Header().Set(rateLimitRemainingHeader, "4999")
+		w.Write([]byte(`{"id": 1}`))
+	})
+	transport.now = func() time.Time { return now }
+
+	ctx := context.Background()
+
+	_, _, err := client.Repositories.Get(ctx, "john-doe", "my-repo")
+	if _, rateLimited := RetryAfter(fmt.Errorf("Error fetching Github repository: %w", err)); !rateLimited {
+		t.Fatalf("Want a RateLimitError, got %v", err)
+	}
+
+	// Requests are held back without reaching Github until the limit resets.
+	exhausted = false
+	if _, _, err := client.Repositories.Get(ctx, "john-doe", "my-repo"); err == nil {
+		t.Fatal("Want an error while the rate limit is exhausted")
+	}
+
+	if *requests != 1 {
+		t.Errorf("Want a single request to reach Github, got %d", *requests)
+	}
+
+	now = reset
+	if _, _, err := client.Repositories.Get(ctx, "john-doe", "my-repo"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestRateLimitTransportHandlesSecondaryRateLimits(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		status      int
+		retryAfter  string
+		body        string
+		wantResetAt time.Time
+	}{
+		{
+			name:        "retry after header",
+			status:      http.StatusForbidden,
+			retryAfter:  "30",
+			body:        `{"message": "You have exceeded a secondary rate limit."}`,
+			wantResetAt: now.Add(30 * time.Second),
+		},
+		{
+			name:        "secondary rate limit without retry after header",
+			status:      http.StatusForbidden,
+			body:        `{"message": "You have exceeded a secondary rate limit."}`,
+			wantResetAt: now.Add(defaultSecondaryRateLimitWait),
+		},
+		{
+			name:        "too many requests",
+			status:      http.StatusTooManyRequests,
+			wantResetAt: now.Add(defaultSecondaryRateLimitWait),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, transport, _ := newRateLimitedClient(t, func(w http.ResponseWriter) {
+				if test.retryAfter != "" {
+					w.Header().Set(retryAfterHeader, test.retryAfter)
+				}
+				w.WriteHeader(test.status)
+				w.Write([]byte(test.body))
+			})
+			transport.now = func() time.Time { return now }
+
+			_, _, err := client.Repositories.Get(context.Background(), "john-doe", "my-repo")
+
+			var rateLimitErr *RateLimitError
+			if !errors.As(err, &rateLimitErr) {
+				t.Fatalf("Want a RateLimitError, got %v", err)
+			}
+
+			if !rateLimitErr.Secondary || !rateLimitErr.ResetAt.Equal(test.wantResetAt) {
+				t.Errorf("Want a secondary rate limit resetting at %s, got %+v", test.wantResetAt, rateLimitErr)
+			}
+		})
+	}
+}
+
+func TestRateLimitTransportLeavesPermissionErrorsToCallers(t *testing.T) {
+	body := `{"message": "Resource not accessible by integration"}`
+	client, _, _ := newRateLimitedClient(t, func(w http.ResponseWriter) {
+		w.Header().Set(rateLimitRemainingHeader, "4999")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(body))
+	})
+
+	_, response, err := client.Repositories.Get(context.Background(), "john-doe", "my-repo")
+	if _, rateLimited := RetryAfter(err); rateLimited || err == nil {
+		t.Fatalf("Want a permission error, got %v", err)
+	}
+
+	if response == nil || response.StatusCode != http.StatusForbidden {
+		t.Fatalf("Want the 403 response, got %v", response)
+	}
+
+	errorResponse, ok := err.(*github.ErrorResponse)
+	if !ok || errorResponse.Message != "Resource not accessible by integration" {
+		t.Errorf("Want the body to be readable by callers, got %v", err)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	if _, rateLimited := RetryAfter(fmt.Errorf("connection refused")); rateLimited {
+		t.Error("Want other errors not to be rate limits")
+	}
+
+	delay, rateLimited := RetryAfter(fmt.Errorf("Error: %w", &RateLimitError{ResetAt: time.Now().Add(time.Hour)}))
+	if !rateLimited || delay < 59*time.Minute || delay > time.Hour {
+		t.Errorf("Want a delay of about an hour, got %s", delay)
+	}
+
+	// Limits that have already reset are retried shortly.
+	if delay, _ := RetryAfter(&RateLimitError{ResetAt: time.Now().Add(-time.Minute)}); delay != time.Second {
+		t.Errorf("Want a delay of one second, got %s", delay)
+	}
+}
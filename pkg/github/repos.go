@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"log"
 
-	"github.com/google/go-github/v33/github"
 	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
 )
 
@@ -51,9 +50,15 @@ func (r *defaultRepoReconciler) setRepoInfo(ctx context.Context, repo *workflows
 type repoReconcilerKey struct {
 }
 
+// NewRepoReconciler returns a new RepoReconciler that reads repositories
+// through clients handed out by the supplied factory.
+func NewRepoReconciler(clients ClientFactory) RepoReconciler {
+	return &defaultRepoReconciler{service: &repositoriesServices{clients: clients}}
+}
+
 // WithRepoReconciler returns a copy of the supplied context with a new RepoReconciler object added.
-func WithRepoReconciler(ctx context.Context, client *github.Client) context.Context {
-	return context.WithValue(ctx, repoReconcilerKey{}, &defaultRepoReconciler{service: client.Repositories})
+func WithRepoReconciler(ctx context.Context, clients ClientFactory) context.Context {
+	return context.WithValue(ctx, repoReconcilerKey{}, NewRepoReconciler(clients))
 }
 
 // GetRepoReconcilerOrDie returns a RepoReconciler instance from the supplied
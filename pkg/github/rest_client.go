@@ -5,14 +5,11 @@ import (
 	"os"
 	"time"
 
-	"github.com/bradleyfalzon/ghinstallation"
-
 	"net/http"
 
 	"strconv"
 
 	"github.com/google/go-github/v33/github"
-	"github.com/gregjones/httpcache"
 )
 
 const (
@@ -20,7 +17,9 @@ const (
 	// Name of the environment variable that contains the Github App ID.
 	githubAppID = "GITHUB_APP_ID"
 
-	// Name of the environment variable that contains the Github installation ID.
+	// Name of the environment variable that contains the Github installation
+	// ID. When it's absent, installations are found for each repository
+	// owner.
 	githubInstallationID = "GITHUB_INSTALLATION_ID"
 
 	// Path where the Github App private key is mounted.
@@ -40,6 +39,12 @@ func (n *NotFoundError) Error() string {
 	return n.msg
 }
 
+// NewNotFoundError returns a NotFoundError with the supplied message. It lets
+// other providers report missing resources the same way.
+func NewNotFoundError(msg string) error {
+	return &NotFoundError{msg: msg}
+}
+
 // IsNotFound returns true if the supplied error is of the type NotFoundError
 // otherwise it returns false.
 func IsNotFound(e error) bool {
@@ -50,31 +55,13 @@ func IsNotFound(e error) bool {
 	return false
 }
 
-// NewClientOrDie returns a client to talk to Github APIs.
-// It panics if the client cannot be created.
-func NewClientOrDie() *github.Client {
-	const errorMessage = "Error initializing Github REST client: %w"
-
-	appID, err := parseID(githubAppID)
-	if err != nil {
-		panic(fmt.Errorf(errorMessage, err))
+// newHostClient returns a client that talks to the APIs of the supplied Github
+// host through the HTTP client in question.
+func newHostClient(host *Host, httpClient *http.Client) (*github.Client, error) {
+	if !host.IsEnterprise() {
+		return github.NewClient(httpClient), nil
 	}
-
-	installationID, err := parseID(githubInstallationID)
-	if err != nil {
-		panic(fmt.Errorf(errorMessage, err))
-	}
-
-	transport := httpcache.NewTransport(httpcache.NewMemoryCache())
-	installationTransport, err := ghinstallation.NewKeyFromFile(transport, appID, installationID, githubPrivateKeyPath)
-	if err != nil {
-		panic(fmt.Errorf(errorMessage, err))
-	}
-
-	return github.NewClient(&http.Client{
-		Transport: installationTransport,
-		Timeout:   timeout,
-	})
+	return github.NewEnterpriseClient(host.APIURL, host.UploadURL, httpClient)
 }
 
 // parseID attempts to read the environment variable whose name was given,
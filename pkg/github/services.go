@@ -0,0 +1,235 @@
+package github
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/go-github/v33/github"
+)
+
+// Types declared in this file implement the service interfaces used by
+// reconcilers by delegating every call to the client that a ClientFactory
+// returns for the repository (or organization) in question.
+
+// repositoriesServices implements contentsService, keysService, hooksService
+// and repositoriesService.
+type repositoriesServices struct {
+	clients ClientFactory
+}
+
+func (r *repositoriesServices) service(ctx context.Context, owner, repo string) (*github.RepositoriesService, error) {
+	client, err := r.clients.ClientFor(ctx, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+	return client.Repositories, nil
+}
+
+func (r *repositoriesServices) GetContents(ctx context.Context, owner, repo, path string, opts *github.RepositoryContentGetOptions) (*github.RepositoryContent, []*github.RepositoryContent, *github.Response, error) {
+	service, err := r.service(ctx, owner, repo)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return service.GetContents(ctx, owner, repo, path, opts)
+}
+
+func (r *repositoriesServices) GetKey(ctx context.Context, owner string, repo string, id int64) (*github.Key, *github.Response, error) {
+	service, err := r.service(ctx, owner, repo)
+	if err != nil {
+		return nil, nil, err
+	}
+	return service.GetKey(ctx, owner, repo, id)
+}
+
+func (r *repositoriesServices) CreateKey(ctx context.Context, owner string, repo string, key *github.Key) (*github.Key, *github.Response, error) {
+	service, err := r.service(ctx, owner, repo)
+	if err != nil {
+		return nil, nil, err
+	}
+	return service.CreateKey(ctx, owner, repo, key)
+}
+
+func (r *repositoriesServices) DeleteKey(ctx context.Context, owner string, repo string, id int64) (*github.Response, error) {
+	service, err := r.service(ctx, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+	return service.DeleteKey(ctx, owner, repo, id)
+}
+
+func (r *repositoriesServices) GetHook(ctx context.Context, owner, repo string, id int64) (*github.Hook, *github.Response, error) {
+	service, err := r.service(ctx, owner, repo)
+	if err != nil {
+		return nil, nil, err
+	}
+	return service.GetHook(ctx, owner, repo, id)
+}
+
+func (r *repositoriesServices) CreateHook(ctx context.Context, owner, repo string, hook *github.Hook) (*github.Hook, *github.Response, error) {
+	service, err := r.service(ctx, owner, repo)
+	if err != nil {
+		return nil, nil, err
+	}
+	return service.CreateHook(ctx, owner, repo, hook)
+}
+
+func (r *repositoriesServices) EditHook(ctx context.Context, owner, repo string, id int64, hook *github.Hook) (*github.Hook, *github.Response, error) {
+	service, err := r.service(ctx, owner, repo)
+	if err != nil {
+		return nil, nil, err
+	}
+	return service.EditHook(ctx, owner, repo, id, hook)
+}
+
+func (r *repositoriesServices) DeleteHook(ctx context.Context, owner, repo string, id int64) (*github.Response, error) {
+	service, err := r.service(ctx, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+	return service.DeleteHook(ctx, owner, repo, id)
+}
+
+func (r *repositoriesServices) Get(ctx context.Context, owner, repo string) (*github.Repository, *github.Response, error) {
+	service, err := r.service(ctx, owner, repo)
+	if err != nil {
+		return nil, nil, err
+	}
+	return service.Get(ctx, owner, repo)
+}
+
+func (r *repositoriesServices) CreateStatus(ctx context.Context, owner, repo, ref string, status *github.RepoStatus) (*github.RepoStatus, *github.Response, error) {
+	service, err := r.service(ctx, owner, repo)
+	if err != nil {
+		return nil, nil, err
+	}
+	return service.CreateStatus(ctx, owner, repo, ref, status)
+}
+
+func (r *repositoriesServices) GetPermissionLevel(ctx context.Context, owner, repo, user string) (*github.RepositoryPermissionLevel, *github.Response, error) {
+	service, err := r.service(ctx, owner, repo)
+	if err != nil {
+		return nil, nil, err
+	}
+	return service.GetPermissionLevel(ctx, owner, repo, user)
+}
+
+func (r *repositoriesServices) CreateDeployment(ctx context.Context, owner, repo string, request *github.DeploymentRequest) (*github.Deployment, *github.Response, error) {
+	service, err := r.service(ctx, owner, repo)
+	if err != nil {
+		return nil, nil, err
+	}
+	return service.CreateDeployment(ctx, owner, repo, request)
+}
+
+func (r *repositoriesServices) CreateDeploymentStatus(ctx context.Context, owner, repo string, deployment int64, request *github.DeploymentStatusRequest) (*github.DeploymentStatus, *github.Response, error) {
+	service, err := r.service(ctx, owner, repo)
+	if err != nil {
+		return nil, nil, err
+	}
+	return service.CreateDeploymentStatus(ctx, owner, repo, deployment, request)
+}
+
+// checksServices implements checksService.
+type checksServices struct {
+	clients ClientFactory
+}
+
+func (c *checksServices) CreateCheckRun(ctx context.Context, owner, repo string, opts github.CreateCheckRunOptions) (*github.CheckRun, *github.Response, error) {
+	client, err := c.clients.ClientFor(ctx, owner, repo)
+	if err != nil {
+		return nil, nil, err
+	}
+	return client.Checks.CreateCheckRun(ctx, owner, repo, opts)
+}
+
+func (c *checksServices) UpdateCheckRun(ctx context.Context, owner, repo string, checkRunID int64, opts github.UpdateCheckRunOptions) (*github.CheckRun, *github.Response, error) {
+	client, err := c.clients.ClientFor(ctx, owner, repo)
+	if err != nil {
+		return nil, nil, err
+	}
+	return client.Checks.UpdateCheckRun(ctx, owner, repo, checkRunID, opts)
+}
+
+func (c *checksServices) ListCheckRunsForRef(ctx context.Context, owner, repo, ref string, opts *github.ListCheckRunsOptions) (*github.ListCheckRunsResults, *github.Response, error) {
+	client, err := c.clients.ClientFor(ctx, owner, repo)
+	if err != nil {
+		return nil, nil, err
+	}
+	return client.Checks.ListCheckRunsForRef(ctx, owner, repo, ref, opts)
+}
+
+// issuesServices implements issuesService.
+type issuesServices struct {
+	clients ClientFactory
+}
+
+func (i *issuesServices) ListComments(ctx context.Context, owner string, repo string, number int, opts *github.IssueListCommentsOptions) ([]*github.IssueComment, *github.Response, error) {
+	client, err := i.clients.ClientFor(ctx, owner, repo)
+	if err != nil {
+		return nil, nil, err
+	}
+	return client.Issues.ListComments(ctx, owner, repo, number, opts)
+}
+
+func (i *issuesServices) CreateComment(ctx context.Context, owner string, repo string, number int, comment *github.IssueComment) (*github.IssueComment, *github.Response, error) {
+	client, err := i.clients.ClientFor(ctx, owner, repo)
+	if err != nil {
+		return nil, nil, err
+	}
+	return client.Issues.CreateComment(ctx, owner, repo, number, comment)
+}
+
+func (i *issuesServices) EditComment(ctx context.Context, owner string, repo string, commentID int64, comment *github.IssueComment) (*github.IssueComment, *github.Response, error) {
+	client, err := i.clients.ClientFor(ctx, owner, repo)
+	if err != nil {
+		return nil, nil, err
+	}
+	return client.Issues.EditComment(ctx, owner, repo, commentID, comment)
+}
+
+func (i *issuesServices) Login(ctx context.Context, owner string, repo string) (string, error) {
+	service, ok := i.clients.(loginService)
+	if !ok {
+		return "", errors.New("Unable to tell which login Github clients act as")
+	}
+	return service.Login(ctx, owner, repo)
+}
+
+// pullRequestsServices implements pullRequestsService.
+type pullRequestsServices struct {
+	clients ClientFactory
+}
+
+func (p *pullRequestsServices) Get(ctx context.Context, owner string, repo string, number int) (*github.PullRequest, *github.Response, error) {
+	client, err := p.clients.ClientFor(ctx, owner, repo)
+	if err != nil {
+		return nil, nil, err
+	}
+	return client.PullRequests.Get(ctx, owner, repo, number)
+}
+
+// organizationsServices implements organizationsService.
+type organizationsServices struct {
+	clients ClientFactory
+}
+
+func (o *organizationsServices) IsMember(ctx context.Context, org, user string) (bool, *github.Response, error) {
+	client, err := o.clients.ClientFor(ctx, org, "")
+	if err != nil {
+		return false, nil, err
+	}
+	return client.Organizations.IsMember(ctx, org, user)
+}
+
+// teamsServices implements teamsService.
+type teamsServices struct {
+	clients ClientFactory
+}
+
+func (t *teamsServices) GetTeamMembershipBySlug(ctx context.Context, org, slug, user string) (*github.Membership, *github.Response, error) {
+	client, err := t.clients.ClientFor(ctx, org, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	return client.Teams.GetTeamMembershipBySlug(ctx, org, slug, user)
+}
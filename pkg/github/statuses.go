@@ -0,0 +1,94 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/google/go-github/v33/github"
+	"knative.dev/pkg/logging"
+)
+
+// Possible states of Github commit statuses.
+const (
+	StatusPending = "pending"
+	StatusSuccess = "success"
+	StatusFailure = "failure"
+	StatusError   = "error"
+)
+
+// CommitStatus represents a Github commit status.
+type CommitStatus struct {
+
+	// Owner and Repo identify the repository the status belongs to.
+	Owner string
+	Repo  string
+
+	// SHA is the commit the status refers to.
+	SHA string
+
+	// State is one of pending, success, failure or error.
+	State string
+
+	// Context differentiates this status from statuses reported by other
+	// systems.
+	Context string
+
+	// Description is a short, human readable description of the status.
+	Description string
+
+	// TargetURL is an optional link to further details about the status.
+	TargetURL string
+}
+
+// CommitStatusReporter reports the progress of workflows as Github commit
+// statuses.
+type CommitStatusReporter interface {
+	ReportCommitStatus(ctx context.Context, status *CommitStatus) error
+}
+
+// defaultCommitStatusReporter implements CommitStatusReporter.
+type defaultCommitStatusReporter struct {
+	service repositoriesService
+}
+
+// ReportCommitStatus implements CommitStatusReporter.ReportCommitStatus.
+func (d *defaultCommitStatusReporter) ReportCommitStatus(ctx context.Context, status *CommitStatus) error {
+	repoStatus := &github.RepoStatus{
+		State:       github.String(status.State),
+		Context:     github.String(status.Context),
+		Description: optionalString(status.Description),
+		TargetURL:   optionalString(status.TargetURL),
+	}
+
+	if _, _, err := d.service.CreateStatus(ctx, status.Owner, status.Repo, status.SHA, repoStatus); err != nil {
+		return fmt.Errorf("Error creating status %s on %s/%s@%s: %w", status.Context, status.Owner, status.Repo, status.SHA, err)
+	}
+
+	logger := logging.FromContext(ctx)
+	logger.Infow("Commit status has been successfully created", "context", status.Context, "state", status.State)
+
+	return nil
+}
+
+// commitStatusReporterKey is used to store CommitStatusReporter objects into
+// context.Context.
+type commitStatusReporterKey struct {
+}
+
+// WithCommitStatusReporter returns a copy of the supplied context with a new
+// CommitStatusReporter object added.
+func WithCommitStatusReporter(ctx context.Context, clients ClientFactory) context.Context {
+	return context.WithValue(ctx, commitStatusReporterKey{}, &defaultCommitStatusReporter{service: &repositoriesServices{clients: clients}})
+}
+
+// GetCommitStatusReporterOrDie returns a CommitStatusReporter instance from the
+// supplied context or dies by calling log.fatal if the context doesn't contain
+// a CommitStatusReporter object.
+func GetCommitStatusReporterOrDie(ctx context.Context) CommitStatusReporter {
+	if reporter, ok := ctx.Value(commitStatusReporterKey{}).(CommitStatusReporter); ok {
+		return reporter
+	}
+	log.Fatal("Unable to get a valid CommitStatusReporter instance from context")
+	return nil
+}
@@ -0,0 +1,66 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/go-github/v33/github"
+	githubmocks "github.com/nubank/workflows/pkg/github/mocks"
+)
+
+func TestReportCommitStatus(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	repositoriesService := githubmocks.NewMockrepositoriesService(mockCtrl)
+	reporter := &defaultCommitStatusReporter{service: repositoriesService}
+
+	ctx := context.Background()
+
+	// Mock setup
+	repositoriesService.EXPECT().
+		CreateStatus(ctx, "john-doe", "my-repo", "833568e", &github.RepoStatus{
+			State:       github.String(StatusPending),
+			Context:     github.String("workflows/ci"),
+			Description: github.String("Workflow is running"),
+			TargetURL:   github.String("https://dashboard.example.com/#/namespaces/dev/pipelineruns/ci-run-abc12"),
+		}).
+		Return(&github.RepoStatus{}, nil, nil)
+
+	err := reporter.ReportCommitStatus(ctx, &CommitStatus{Owner: "john-doe",
+		Repo:        "my-repo",
+		SHA:         "833568e",
+		State:       StatusPending,
+		Context:     "workflows/ci",
+		Description: "Workflow is running",
+		TargetURL:   "https://dashboard.example.com/#/namespaces/dev/pipelineruns/ci-run-abc12",
+	})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestReportCommitStatusReturnsAnErrorWhenGithubFails(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	repositoriesService := githubmocks.NewMockrepositoriesService(mockCtrl)
+	reporter := &defaultCommitStatusReporter{service: repositoriesService}
+
+	ctx := context.Background()
+
+	// Mock setup
+	repositoriesService.EXPECT().
+		CreateStatus(ctx, "john-doe", "my-repo", "833568e", gomock.Any()).
+		Return(nil, nil, errors.New("Boom!"))
+
+	err := reporter.ReportCommitStatus(ctx, &CommitStatus{Owner: "john-doe",
+		Repo:    "my-repo",
+		SHA:     "833568e",
+		State:   StatusSuccess,
+		Context: "workflows/ci",
+	})
+
+	want := "Error creating status workflows/ci on john-doe/my-repo@833568e: Boom!"
+	if err == nil || err.Error() != want {
+		t.Errorf("Want error %s, got %v", want, err)
+	}
+}
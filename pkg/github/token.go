@@ -0,0 +1,92 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/google/go-github/v33/github"
+)
+
+const (
+
+	// Path where the personal access token or fine-grained token is mounted
+	// when the controller and the hook listener don't authenticate as a Github
+	// App.
+	githubTokenPath = "/var/run/secrets/github/token"
+
+	// Header through which Github lists the OAuth scopes granted to classic
+	// personal access tokens.
+	oauthScopesHeader = "X-OAuth-Scopes"
+)
+
+// tokenScope is an OAuth scope classic personal access tokens must be granted.
+type tokenScope struct {
+	name string
+
+	// What the scope is needed for.
+	usage string
+}
+
+// requiredTokenScopes lists OAuth scopes that classic personal access tokens
+// must be granted. Members of organizations and teams can only be verified
+// for workflows restricting actors if read:org is granted as well.
+var requiredTokenScopes = []tokenScope{
+	{name: "repo", usage: "reading repositories, managing deploy keys and reporting statuses and deployments"},
+	{name: "admin:repo_hook", usage: "managing Webhooks"},
+}
+
+// readToken returns the token stored in the file at the supplied path or an
+// empty string if there's no such file.
+func readToken(path string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("Error reading Github token: %w", err)
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// checkTokenScopes verifies that the token the supplied client authenticates
+// with is valid and, for classic personal access tokens, that it was granted
+// every required scope. Github doesn't list permissions of fine-grained tokens,
+// so they're only checked for validity.
+func checkTokenScopes(ctx context.Context, client *github.Client) error {
+	_, response, err := client.Users.Get(ctx, "")
+	if response != nil && response.StatusCode == http.StatusUnauthorized {
+		return fmt.Errorf("The Github token is invalid or has expired")
+	}
+
+	if err != nil {
+		return fmt.Errorf("Error checking the scopes of the Github token: %w", err)
+	}
+
+	header, exists := response.Header[http.CanonicalHeaderKey(oauthScopesHeader)]
+	if !exists {
+		// Fine-grained token.
+		return nil
+	}
+
+	granted := make(map[string]bool)
+	for _, value := range header {
+		for _, scope := range strings.Split(value, ",") {
+			granted[strings.TrimSpace(scope)] = true
+		}
+	}
+
+	var missing []string
+	for _, scope := range requiredTokenScopes {
+		if !granted[scope.name] {
+			missing = append(missing, fmt.Sprintf("%s (needed for %s)", scope.name, scope.usage))
+		}
+	}
+
+	if len(missing) != 0 {
+		return fmt.Errorf("The Github token is missing scopes %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
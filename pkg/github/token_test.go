@@ -0,0 +1,105 @@
+package github
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gregjones/httpcache"
+)
+
+func TestReadToken(t *testing.T) {
+	dir := t.TempDir()
+
+	token, err := readToken(filepath.Join(dir, "token"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if token != "" {
+		t.Errorf("Want no token, got %s", token)
+	}
+
+	path := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(path, []byte("ghp_abc\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	token, err = readToken(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if token != "ghp_abc" {
+		t.Errorf("Want token ghp_abc, got %s", token)
+	}
+}
+
+func TestCheckTokenScopes(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		scopes    []string
+		wantError string
+	}{
+		{
+			name:   "classic token with every required scope",
+			status: http.StatusOK,
+			scopes: []string{"admin:repo_hook, read:org, repo"},
+		},
+		{
+			name:   "fine-grained token",
+			status: http.StatusOK,
+		},
+		{
+			name:      "classic token missing a scope",
+			status:    http.StatusOK,
+			scopes:    []string{"repo"},
+			wantError: "The Github token is missing scopes admin:repo_hook (needed for managing Webhooks)",
+		},
+		{
+			name:      "classic token without scopes",
+			status:    http.StatusOK,
+			scopes:    []string{""},
+			wantError: "The Github token is missing scopes repo (needed for reading repositories, managing deploy keys and reporting statuses and deployments), admin:repo_hook",
+		},
+		{
+			name:      "invalid token",
+			status:    http.StatusUnauthorized,
+			wantError: "The Github token is invalid or has expired",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for _, scopes := range test.scopes {
+					w.Header().Add(oauthScopesHeader, scopes)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(test.status)
+				w.Write([]byte(`{"login": "john-doe"}`))
+			}))
+			defer server.Close()
+
+			host := &Host{APIURL: server.URL + "/api/v3/",
+				UploadURL: server.URL + "/api/uploads/",
+				GitURL:    server.URL,
+			}
+
+			err := checkTokenScopes(context.Background(), newTokenClient(host, httpcache.NewMemoryCache(), "ghp_abc", "token"))
+
+			if test.wantError == "" && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+
+			if test.wantError != "" && (err == nil || !strings.HasPrefix(err.Error(), test.wantError)) {
+				t.Errorf("Want error %q, got %v", test.wantError, err)
+			}
+		})
+	}
+}
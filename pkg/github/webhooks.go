@@ -13,6 +13,9 @@ import (
 	"github.com/nubank/workflows/pkg/secrets"
 )
 
+// commentEvent is the event delivering comments, and thus slash commands.
+const commentEvent = "issue_comment"
+
 // DefaultWebhookReconciler keeps Github Webhooks in sync to the desired state declared in
 // workflows.
 type WebhookReconciler interface {
@@ -102,7 +105,7 @@ func (w *defaultWebhookReconciler) getWebhook(ctx context.Context, workflow *v1a
 // since the last sync or false otherwise.
 func (w *defaultWebhookReconciler) changedSinceLastSync(workflow *v1alpha1.Workflow, hook *github.Hook) bool {
 	return !hook.GetActive() ||
-		!reflect.DeepEqual(workflow.Spec.Events, hook.Events) ||
+		!reflect.DeepEqual(hookEvents(workflow), hook.Events) ||
 		workflow.GetHooksURL() != hook.Config["url"] ||
 		hook.Config["content_type"] != "json" ||
 		hook.Config["insecure_ssl"] != "0"
@@ -136,7 +139,7 @@ func (w *defaultWebhookReconciler) createWebhook(ctx context.Context, workflow *
 // newHook returns a new Hook object.
 func (w *defaultWebhookReconciler) newHook(workflow *v1alpha1.Workflow, secret *string) *github.Hook {
 	hook := &github.Hook{Active: github.Bool(true),
-		Events: workflow.Spec.Events,
+		Events: hookEvents(workflow),
 		Config: map[string]interface{}{
 			"url":          workflow.GetHooksURL(),
 			"content_type": "json",
@@ -151,6 +154,19 @@ func (w *defaultWebhookReconciler) newHook(workflow *v1alpha1.Workflow, secret *
 	return hook
 }
 
+// hookEvents returns the events the Webhook of the supplied workflow
+// subscribes to: those triggering the workflow and issue_comment, which
+// delivers slash commands written on pull requests.
+func hookEvents(workflow *v1alpha1.Workflow) []string {
+	events := append([]string{}, workflow.Spec.Events...)
+	for _, event := range events {
+		if event == commentEvent {
+			return events
+		}
+	}
+	return append(events, commentEvent)
+}
+
 // updateWebhook updates an existing Github Webhook.
 func (w *defaultWebhookReconciler) updateWebhook(ctx context.Context, workflow *v1alpha1.Workflow, id int64) (*Webhook, error) {
 	repo := workflow.Spec.Repository
@@ -205,9 +221,15 @@ func (w *defaultWebhookReconciler) Delete(ctx context.Context, workflow *v1alpha
 type webhookReconcilerKey struct {
 }
 
+// NewWebhookReconciler returns a new WebhookReconciler that manages Webhooks
+// through clients handed out by the supplied factory.
+func NewWebhookReconciler(clients ClientFactory) WebhookReconciler {
+	return &defaultWebhookReconciler{service: &repositoriesServices{clients: clients}}
+}
+
 // WithWebhookReconciler returns a copy of the supplied context with a new WebhookReconciler object added.
-func WithWebhookReconciler(ctx context.Context, client *github.Client) context.Context {
-	return context.WithValue(ctx, webhookReconcilerKey{}, &defaultWebhookReconciler{service: client.Repositories})
+func WithWebhookReconciler(ctx context.Context, clients ClientFactory) context.Context {
+	return context.WithValue(ctx, webhookReconcilerKey{}, NewWebhookReconciler(clients))
 }
 
 // GetWebhookReconcilerOrDie returns a WebhookReconciler instance from the supplied
@@ -0,0 +1,78 @@
+package github
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-github/v33/github"
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	githubmocks "github.com/nubank/workflows/pkg/github/mocks"
+	"go.uber.org/zap"
+	"knative.dev/pkg/logging"
+)
+
+func newHookTestWorkflow(events ...string) *workflowsv1alpha1.Workflow {
+	return &workflowsv1alpha1.Workflow{
+		Spec: workflowsv1alpha1.WorkflowSpec{
+			Repository: &workflowsv1alpha1.Repository{Owner: "my-org", Name: "my-repo"},
+			Webhook:    &workflowsv1alpha1.Webhook{URL: "https://workflows.example.com"},
+			Events:     events,
+		},
+	}
+}
+
+func TestHooksSubscribeToCommentsForSlashCommands(t *testing.T) {
+	tests := []struct {
+		events []string
+		want   []string
+	}{
+		{events: []string{"push", "pull_request"}, want: []string{"push", "pull_request", "issue_comment"}},
+		{events: []string{"issue_comment", "push"}, want: []string{"issue_comment", "push"}},
+		{events: nil, want: []string{"issue_comment"}},
+	}
+
+	for _, test := range tests {
+		if diff := cmp.Diff(test.want, hookEvents(newHookTestWorkflow(test.events...))); diff != "" {
+			t.Errorf("Mismatch for events %v (-want +got):\n%s", test.events, diff)
+		}
+	}
+}
+
+func TestCreatedHooksSubscribeToComments(t *testing.T) {
+	ctx := logging.WithLogger(context.Background(), zap.NewNop().Sugar())
+	hooks := githubmocks.NewMockhooksService(gomock.NewController(t))
+	reconciler := &defaultWebhookReconciler{service: hooks}
+	workflow := newHookTestWorkflow("pull_request")
+
+	hooks.EXPECT().
+		CreateHook(gomock.Any(), "my-org", "my-repo", gomock.Any()).
+		DoAndReturn(func(ctx context.Context, owner, repo string, hook *github.Hook) (*github.Hook, *github.Response, error) {
+			if diff := cmp.Diff([]string{"pull_request", "issue_comment"}, hook.Events); diff != "" {
+				t.Errorf("Mismatch in events (-want +got):\n%s", diff)
+			}
+			return &github.Hook{ID: github.Int64(42)}, nil, nil
+		})
+
+	if _, err := reconciler.ReconcileHook(ctx, workflow); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Hooks subscribed to comments are up to date.
+	hooks.EXPECT().
+		GetHook(gomock.Any(), "my-org", "my-repo", int64(42)).
+		Return(&github.Hook{ID: github.Int64(42),
+			Active: github.Bool(true),
+			Events: []string{"pull_request", "issue_comment"},
+			Config: map[string]interface{}{
+				"url":          workflow.GetHooksURL(),
+				"content_type": "json",
+				"insecure_ssl": "0",
+			},
+		}, nil, nil)
+
+	if webhook, err := reconciler.ReconcileHook(ctx, workflow); err != nil || webhook != nil {
+		t.Errorf("Want no changes, got %+v and error %v", webhook, err)
+	}
+}
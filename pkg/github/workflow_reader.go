@@ -42,20 +42,23 @@ func (d *DefaultWorkflowReader) GetWorkflowContent(ctx context.Context, workflow
 		return nil, err
 	}
 
+	return ParseWorkflow(workflow, []byte(raw))
+}
+
+// ParseWorkflow parses the supplied content of a workflow read from its
+// repository, keeping the attributes of the supplied workflow that are meant
+// to be immutable (see Workflow.CopyImmutableAttributes).
+func ParseWorkflow(workflow *workflowsv1alpha1.Workflow, content []byte) (*workflowsv1alpha1.Workflow, error) {
 	var w workflowsv1alpha1.Workflow
-	if err := yaml.Unmarshal([]byte(raw), &w); err != nil {
+	if err := yaml.Unmarshal(content, &w); err != nil {
 		return nil, err
 	}
 
-	// Copy attributes that are meant to be immutable
-	originalWorkflow := workflow.DeepCopy()
-	w.Spec.Repository = originalWorkflow.Spec.Repository
-	w.Spec.AdditionalRepositories = originalWorkflow.Spec.AdditionalRepositories
-
+	w.CopyImmutableAttributes(workflow)
 	return &w, nil
 }
 
 // NewWorkflowReader creates a new WorkflowReader object.
-func NewWorkflowReader(client *github.Client) WorkflowReader {
-	return &DefaultWorkflowReader{service: client.Repositories}
+func NewWorkflowReader(clients ClientFactory) WorkflowReader {
+	return &DefaultWorkflowReader{service: &repositoriesServices{clients: clients}}
 }
@@ -91,3 +91,121 @@ func TestReadsTheWorkflowDeclaredInTheRepository(t *testing.T) {
 		t.Errorf("Mismatch (- want + got):\n%s", diff)
 	}
 }
+
+func TestKeepsTheActorsAndCredentialsOfTheOriginalWorkflow(t *testing.T) {
+	originalWorkflow := &workflowsv1alpha1.Workflow{
+		Spec: workflowsv1alpha1.WorkflowSpec{
+			Repository:  &workflowsv1alpha1.Repository{Owner: "john-doe", Name: "my-repo"},
+			Actors:      &workflowsv1alpha1.Actors{Allow: []string{"john-doe"}},
+			When:        `event.action != "labeled"`,
+			Credentials: &workflowsv1alpha1.Credentials{SecretName: "my-credentials"},
+		},
+	}
+
+	// The configuration of a pull request dropping every restriction.
+	content := []byte(`spec:
+  credentials:
+    secretName: other-credentials
+  tasks:
+    welcome:
+      steps:
+      - run: echo "Welcome!"
+`)
+
+	got, err := ParseWorkflow(originalWorkflow, content)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if diff := cmp.Diff(originalWorkflow.Spec.Actors, got.Spec.Actors); diff != "" {
+		t.Errorf("Mismatch in actors (- want + got):\n%s", diff)
+	}
+
+	if got.Spec.When != originalWorkflow.Spec.When {
+		t.Errorf("Want expression %s, but got %s", originalWorkflow.Spec.When, got.Spec.When)
+	}
+
+	if diff := cmp.Diff(originalWorkflow.Spec.Credentials, got.Spec.Credentials); diff != "" {
+		t.Errorf("Mismatch in credentials (- want + got):\n%s", diff)
+	}
+
+	if got.Spec.Tasks["welcome"] == nil {
+		t.Errorf("Want the tasks read from the repository, but got %+v", got.Spec.Tasks)
+	}
+}
+
+func TestKeepsTheCommitStatusOfTheOriginalWorkflow(t *testing.T) {
+	originalWorkflow := &workflowsv1alpha1.Workflow{
+		ObjectMeta: metav1.ObjectMeta{Name: "ci"},
+		Spec: workflowsv1alpha1.WorkflowSpec{
+			Repository:   &workflowsv1alpha1.Repository{Owner: "john-doe", Name: "my-repo"},
+			CommitStatus: &workflowsv1alpha1.CommitStatus{TargetURL: "https://dashboard.example.com"},
+		},
+	}
+
+	// The configuration of a pull request reporting a status required by
+	// protected branches.
+	content := []byte(`metadata:
+  name: security
+spec:
+  commitStatus:
+    context: security/scan
+  tasks:
+    welcome:
+      steps:
+      - run: echo "Welcome!"
+`)
+
+	got, err := ParseWorkflow(originalWorkflow, content)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := &workflowsv1alpha1.CommitStatus{Context: "workflows/ci", TargetURL: "https://dashboard.example.com"}
+	if diff := cmp.Diff(want, got.Spec.CommitStatus); diff != "" {
+		t.Errorf("Mismatch in commit status (- want + got):\n%s", diff)
+	}
+}
+
+func TestKeepsTheEnvironmentsOfTheOriginalWorkflow(t *testing.T) {
+	originalWorkflow := &workflowsv1alpha1.Workflow{
+		Spec: workflowsv1alpha1.WorkflowSpec{
+			Repository: &workflowsv1alpha1.Repository{Owner: "john-doe", Name: "my-repo"},
+			Tasks: map[string]*workflowsv1alpha1.Task{
+				"deploy": {Environment: &workflowsv1alpha1.Environment{Name: "staging"}},
+			},
+		},
+	}
+
+	// The configuration of a pull request deploying to production.
+	content := []byte(`spec:
+  tasks:
+    deploy:
+      environment:
+        name: production
+      steps:
+      - run: make deploy
+    release:
+      environment:
+        name: production
+      steps:
+      - run: make release
+`)
+
+	got, err := ParseWorkflow(originalWorkflow, content)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if diff := cmp.Diff(originalWorkflow.Spec.Tasks["deploy"].Environment, got.Spec.Tasks["deploy"].Environment); diff != "" {
+		t.Errorf("Mismatch in environment of task deploy (- want + got):\n%s", diff)
+	}
+
+	if environment := got.Spec.Tasks["release"].Environment; environment != nil {
+		t.Errorf("Want task release to deploy nowhere, but got %+v", environment)
+	}
+
+	if len(got.Spec.Tasks["deploy"].Steps) != 1 {
+		t.Errorf("Want the steps read from the repository, but got %+v", got.Spec.Tasks["deploy"])
+	}
+}
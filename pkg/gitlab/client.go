@@ -0,0 +1,96 @@
+package gitlab
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	"github.com/nubank/workflows/pkg/rest"
+)
+
+const (
+
+	// Name of the environment variable that contains the base URL of the
+	// GitLab instance hosting repositories.
+	gitlabURL = "GITLAB_URL"
+
+	// Name of the environment variable that contains the path to a file
+	// holding PEM encoded certificates to be trusted when talking to GitLab.
+	gitlabCABundlePath = "GITLAB_CA_BUNDLE_PATH"
+
+	// Path where the GitLab access token is mounted. GitLab support is only
+	// enabled when it exists.
+	gitlabTokenPath = "/var/run/secrets/gitlab/token"
+
+	// Base URL of gitlab.com.
+	defaultURL = "https://gitlab.com"
+
+	// Path under which GitLab serves the REST API.
+	apiPath = "/api/v4/"
+
+	// Header requests are authenticated with.
+	privateTokenHeader = "PRIVATE-TOKEN"
+)
+
+// Client talks to the REST API of a GitLab instance with an access token
+// granted the api scope.
+type Client struct {
+	*rest.Client
+	token string
+}
+
+// NewClient returns a Client for the GitLab instance at the supplied base URL,
+// trusting the certificates of the supplied bundle if any.
+func NewClient(baseURL, token string, caBundle []byte) (*Client, error) {
+	client := &Client{token: token}
+
+	var err error
+	if client.Client, err = rest.NewClient(client, baseURL, apiPath, "", caBundle); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// NewClientFromEnv returns a Client for the GitLab instance configured through
+// environment variables, which defaults to gitlab.com. It returns nil when no
+// GitLab token is mounted, meaning that GitLab isn't supported.
+func NewClientFromEnv() (*Client, error) {
+	content, err := ioutil.ReadFile(gitlabTokenPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Error reading GitLab token: %w", err)
+	}
+
+	baseURL := defaultURL
+	if value, ok := os.LookupEnv(gitlabURL); ok && value != "" {
+		baseURL = value
+	}
+
+	caBundle, err := rest.ReadCABundle(gitlabCABundlePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewClient(baseURL, strings.TrimSpace(string(content)), caBundle)
+}
+
+// Product implements rest.API.
+func (c *Client) Product() string {
+	return "GitLab"
+}
+
+// Authenticate implements rest.API.
+func (c *Client) Authenticate(request *http.Request) {
+	request.Header.Set(privateTokenHeader, c.token)
+}
+
+// RepoPath implements rest.API. Projects are identified by their URL-encoded
+// full path.
+func (c *Client) RepoPath(repo *workflowsv1alpha1.Repository) string {
+	return "projects/" + url.PathEscape(repo.Owner+"/"+repo.Name)
+}
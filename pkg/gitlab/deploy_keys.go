@@ -0,0 +1,46 @@
+package gitlab
+
+import (
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	"github.com/nubank/workflows/pkg/github"
+	"github.com/nubank/workflows/pkg/rest"
+)
+
+// deployKey is a GitLab deploy key, as sent to and returned by the API.
+type deployKey struct {
+	ID      int64  `json:"id,omitempty"`
+	Title   string `json:"title"`
+	Key     string `json:"key"`
+	CanPush bool   `json:"can_push"`
+}
+
+// GetID implements rest.DeployKey.
+func (d *deployKey) GetID() int64 {
+	return d.ID
+}
+
+// IsReadOnly implements rest.DeployKey.
+func (d *deployKey) IsReadOnly() bool {
+	return !d.CanPush
+}
+
+// KeysPath implements rest.API.
+func (c *Client) KeysPath(repo *workflowsv1alpha1.Repository) string {
+	return c.RepoPath(repo) + "/deploy_keys"
+}
+
+// DeployKey implements rest.API.
+func (c *Client) DeployKey() rest.DeployKey {
+	return &deployKey{}
+}
+
+// NewDeployKey implements rest.API.
+func (c *Client) NewDeployKey(title, publicKey string, readOnly bool) rest.DeployKey {
+	return &deployKey{Title: title, Key: publicKey, CanPush: !readOnly}
+}
+
+// NewDeployKeysReconciler returns a new DeployKeysReconciler for GitLab
+// deploy keys.
+func NewDeployKeysReconciler(client *Client) github.DeployKeysReconciler {
+	return rest.NewDeployKeysReconciler(client.Client)
+}
@@ -0,0 +1,213 @@
+package gitlab
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/nubank/workflows/pkg/github"
+)
+
+const (
+
+	// gitlabEventHeader defines the header that contains the name of the
+	// event delivered (e.g. Push Hook).
+	gitlabEventHeader = "X-Gitlab-Event"
+
+	// gitlabTokenHeader defines the header that contains the secret token of
+	// the project hook that sent the request.
+	gitlabTokenHeader = "X-Gitlab-Token"
+
+	// gitlabEventUUIDHeader defines the header that contains a guid
+	// identifying the delivery.
+	gitlabEventUUIDHeader = "X-Gitlab-Event-UUID"
+
+	// gitlabWebhookUUIDHeader defines the header that identifies the project
+	// hook that sent the request.
+	gitlabWebhookUUIDHeader = "X-Gitlab-Webhook-UUID"
+)
+
+// Names of GitLab events that workflows are triggered by.
+const (
+	pushHook         = "Push Hook"
+	mergeRequestHook = "Merge Request Hook"
+)
+
+// mergeRequestActions translates actions of merge request events into those
+// of Github pull_request events, which filters are written against.
+var mergeRequestActions = map[string]string{
+	"open":   "opened",
+	"reopen": "reopened",
+	"close":  "closed",
+	"merge":  "closed",
+}
+
+// user is the author of an event.
+type user struct {
+	Username string `json:"username"`
+}
+
+// projectPayload describes the project an event refers to.
+type projectPayload struct {
+	PathWithNamespace string `json:"path_with_namespace"`
+}
+
+// commit is a commit pushed to a project.
+type commit struct {
+	ID       string   `json:"id"`
+	Message  string   `json:"message"`
+	Added    []string `json:"added"`
+	Modified []string `json:"modified"`
+	Removed  []string `json:"removed"`
+}
+
+// pushPayload is the payload of Push Hook events.
+type pushPayload struct {
+	Ref          string         `json:"ref"`
+	After        string         `json:"after"`
+	CheckoutSHA  string         `json:"checkout_sha"`
+	UserUsername string         `json:"user_username"`
+	Project      projectPayload `json:"project"`
+	Commits      []commit       `json:"commits"`
+}
+
+// mergeRequestPayload is the payload of Merge Request Hook events.
+type mergeRequestPayload struct {
+	User             user           `json:"user"`
+	Project          projectPayload `json:"project"`
+	ObjectAttributes struct {
+		IID          int    `json:"iid"`
+		Title        string `json:"title"`
+		SourceBranch string `json:"source_branch"`
+		TargetBranch string `json:"target_branch"`
+		Action       string `json:"action"`
+		OldRev       string `json:"oldrev"`
+		LastCommit   commit `json:"last_commit"`
+	} `json:"object_attributes"`
+}
+
+// IsWebhookRequest returns true if the supplied request was delivered by a
+// GitLab project hook.
+func IsWebhookRequest(request *http.Request) bool {
+	return request.Header.Get(gitlabEventHeader) != ""
+}
+
+// ParseWebhookEvent creates a new Event object from the supplied HTTP request
+// delivered by a GitLab project hook. Push and merge request events are
+// normalized into Github push and pull_request events, so that workflows are
+// filtered and run the same way regardless of where repositories are hosted.
+// Other events are named after their kind (e.g. note).
+func ParseWebhookEvent(request *http.Request) (*github.Event, error) {
+	eventName := request.Header.Get(gitlabEventHeader)
+	if eventName == "" {
+		return nil, errors.New("Request doesn't appear to have been delivered by a GitLab project hook")
+	}
+
+	// Workflows' project hooks always have a secret token.
+	token := request.Header.Get(gitlabTokenHeader)
+	if token == "" {
+		return nil, fmt.Errorf("Access denied: GitLab token header %s is missing", gitlabTokenHeader)
+	}
+
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading request body: %w", err)
+	}
+
+	event := &github.Event{
+		Body:       body,
+		DeliveryID: request.Header.Get(gitlabEventUUIDHeader),
+		HookID:     request.Header.Get(gitlabWebhookUUIDHeader),
+		Token:      []byte(token),
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, fmt.Errorf("Error parsing event payload: %w", err)
+	}
+	event.Data = data
+
+	switch eventName {
+	case pushHook:
+		var payload pushPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, fmt.Errorf("Error parsing event payload: %w", err)
+		}
+		parsePush(event, &payload)
+
+	case mergeRequestHook:
+		var payload mergeRequestPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, fmt.Errorf("Error parsing event payload: %w", err)
+		}
+		parseMergeRequest(event, &payload)
+
+	default:
+		event.Name, _ = data["object_kind"].(string)
+		if event.Name == "" {
+			event.Name = strings.ToLower(strings.ReplaceAll(strings.TrimSuffix(eventName, " Hook"), " ", "_"))
+		}
+	}
+
+	return event, nil
+}
+
+// parsePush fills the supplied event with the contents of a push payload.
+func parsePush(event *github.Event, payload *pushPayload) {
+	event.Name = "push"
+	event.Repository = payload.Project.PathWithNamespace
+	event.Sender = payload.UserUsername
+	event.Branch = strings.TrimPrefix(payload.Ref, "refs/heads/")
+
+	event.HeadCommitSHA = payload.CheckoutSHA
+	if event.HeadCommitSHA == "" {
+		event.HeadCommitSHA = payload.After
+	}
+
+	set := make(map[string]bool)
+	event.Changes = make([]string, 0)
+	for _, c := range payload.Commits {
+		if c.ID == event.HeadCommitSHA {
+			event.HeadCommitMessage = c.Message
+		}
+
+		for _, files := range [][]string{c.Added, c.Modified, c.Removed} {
+			for _, file := range files {
+				if !set[file] {
+					set[file] = true
+					event.Changes = append(event.Changes, file)
+				}
+			}
+		}
+	}
+}
+
+// parseMergeRequest fills the supplied event with the contents of a merge
+// request payload.
+func parseMergeRequest(event *github.Event, payload *mergeRequestPayload) {
+	attributes := payload.ObjectAttributes
+
+	event.Name = "pull_request"
+	event.Repository = payload.Project.PathWithNamespace
+	event.Sender = payload.User.Username
+	event.Branch = attributes.SourceBranch
+	event.BaseBranch = attributes.TargetBranch
+	event.HeadCommitSHA = attributes.LastCommit.ID
+	event.HeadCommitMessage = attributes.LastCommit.Message
+	event.PullRequestNumber = attributes.IID
+	event.PullRequestTitle = attributes.Title
+
+	event.Action = attributes.Action
+	if action, ok := mergeRequestActions[attributes.Action]; ok {
+		event.Action = action
+	} else if attributes.Action == "update" {
+		// Updates carry the previous head when commits were pushed.
+		event.Action = "edited"
+		if attributes.OldRev != "" {
+			event.Action = "synchronize"
+		}
+	}
+}
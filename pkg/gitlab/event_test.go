@@ -0,0 +1,178 @@
+package gitlab
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/nubank/workflows/pkg/github"
+)
+
+func newWebhookRequest(eventName, token, payload string) *http.Request {
+	request := &http.Request{
+		Body:   ioutil.NopCloser(strings.NewReader(payload)),
+		Header: http.Header{},
+	}
+	request.Header.Set("X-Gitlab-Event", eventName)
+	request.Header.Set("X-Gitlab-Event-UUID", "2f5e0a4c-8b3d-4e1f-9a6b-7c8d9e0f1a2b")
+	request.Header.Set("X-Gitlab-Webhook-UUID", "5b1f7d3e-2a4c-4b6d-8e0f-1a2b3c4d5e6f")
+	if token != "" {
+		request.Header.Set("X-Gitlab-Token", token)
+	}
+	return request
+}
+
+func TestParsesThePushHookProperly(t *testing.T) {
+	payload := `{
+  "object_kind": "push",
+  "ref": "refs/heads/dev",
+  "before": "95790bf891e76fee5e1747ab589903a6a1f80f22",
+  "after": "da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+  "checkout_sha": "da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+  "user_username": "john-doe",
+  "project": {
+    "path_with_namespace": "my-group/my-subgroup/my-project"
+  },
+  "commits": [
+    {
+      "id": "b6568db1bc1dcd7f8b4d5a946b0b91f9dacd7327",
+      "message": "Update README",
+      "added": ["docs/README.md"],
+      "modified": ["README.md"],
+      "removed": []
+    },
+    {
+      "id": "da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+      "message": "Fix tests",
+      "added": [],
+      "modified": ["pkg/foo/foo_test.go", "README.md"],
+      "removed": ["pkg/foo/bar.go"]
+    }
+  ]
+}`
+
+	event, err := ParseWebhookEvent(newWebhookRequest("Push Hook", "secret", payload))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := &github.Event{
+		Branch:            "dev",
+		Changes:           []string{"docs/README.md", "README.md", "pkg/foo/foo_test.go", "pkg/foo/bar.go"},
+		DeliveryID:        "2f5e0a4c-8b3d-4e1f-9a6b-7c8d9e0f1a2b",
+		HeadCommitMessage: "Fix tests",
+		HeadCommitSHA:     "da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+		HookID:            "5b1f7d3e-2a4c-4b6d-8e0f-1a2b3c4d5e6f",
+		Name:              "push",
+		Repository:        "my-group/my-subgroup/my-project",
+		Sender:            "john-doe",
+		Token:             []byte("secret"),
+	}
+
+	if diff := cmp.Diff(want, event, cmpopts.IgnoreFields(github.Event{}, "Body", "Data")); diff != "" {
+		t.Errorf("Mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestParsesTheMergeRequestHookProperly(t *testing.T) {
+	tests := []struct {
+		action string
+		oldRev string
+		want   string
+	}{
+		{action: "open", want: "opened"},
+		{action: "reopen", want: "reopened"},
+		{action: "close", want: "closed"},
+		{action: "merge", want: "closed"},
+		{action: "update", oldRev: "95790bf891e76fee5e1747ab589903a6a1f80f22", want: "synchronize"},
+		{action: "update", want: "edited"},
+		{action: "approved", want: "approved"},
+	}
+
+	for _, test := range tests {
+		payload := `{
+  "object_kind": "merge_request",
+  "user": {
+    "username": "john-doe"
+  },
+  "project": {
+    "path_with_namespace": "my-group/my-project"
+  },
+  "object_attributes": {
+    "iid": 42,
+    "title": "Add feature",
+    "source_branch": "feature",
+    "target_branch": "main",
+    "action": "` + test.action + `",
+    "oldrev": "` + test.oldRev + `",
+    "last_commit": {
+      "id": "da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+      "message": "Add feature"
+    }
+  }
+}`
+
+		event, err := ParseWebhookEvent(newWebhookRequest("Merge Request Hook", "secret", payload))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		want := &github.Event{
+			Action:            test.want,
+			BaseBranch:        "main",
+			Branch:            "feature",
+			DeliveryID:        "2f5e0a4c-8b3d-4e1f-9a6b-7c8d9e0f1a2b",
+			HeadCommitMessage: "Add feature",
+			HeadCommitSHA:     "da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+			HookID:            "5b1f7d3e-2a4c-4b6d-8e0f-1a2b3c4d5e6f",
+			Name:              "pull_request",
+			PullRequestNumber: 42,
+			PullRequestTitle:  "Add feature",
+			Repository:        "my-group/my-project",
+			Sender:            "john-doe",
+			Token:             []byte("secret"),
+		}
+
+		if diff := cmp.Diff(want, event, cmpopts.IgnoreFields(github.Event{}, "Body", "Data")); diff != "" {
+			t.Errorf("Mismatch for action %s (-want +got):\n%s", test.action, diff)
+		}
+	}
+}
+
+func TestNamesOtherHooksAfterTheirKind(t *testing.T) {
+	event, err := ParseWebhookEvent(newWebhookRequest("Note Hook", "secret", `{"object_kind": "note"}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if event.Name != "note" {
+		t.Errorf("Want event note, got %s", event.Name)
+	}
+}
+
+func TestReturnsAnErrorWhenTheTokenIsMissing(t *testing.T) {
+	_, err := ParseWebhookEvent(newWebhookRequest("Push Hook", "", `{}`))
+
+	want := "Access denied: GitLab token header X-Gitlab-Token is missing"
+	if err == nil || err.Error() != want {
+		t.Errorf("Want error %s, got %v", want, err)
+	}
+}
+
+func TestVerifiesTheTokenOfProjectHooks(t *testing.T) {
+	event, err := ParseWebhookEvent(newWebhookRequest("Push Hook", "secret", `{}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if valid, message := event.VerifySignature([]byte("other-secret")); valid {
+		t.Errorf("Want the token to be rejected, got %s", message)
+	}
+
+	if valid, message := event.VerifySignature([]byte("secret")); !valid {
+		t.Errorf("Want the token to be accepted, got %s", message)
+	}
+}
@@ -0,0 +1,177 @@
+package gitlab
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeToken is the token the fake GitLab API accepts.
+const fakeToken = "glpat-abc"
+
+// fakeGitlab is a local fake of the parts of the GitLab REST API that
+// workflows use. Projects are identified by their URL-encoded full path, as
+// the client sends them.
+type fakeGitlab struct {
+	mutex    sync.Mutex
+	nextID   int64
+	projects map[string]*project
+	hooks    map[int64]*hook
+	keys     map[int64]*deployKey
+
+	// Raw contents of files by project, path and ref (e.g.
+	// my-group/my-project:.workflows/test.yaml@a1b2c3).
+	files map[string]string
+}
+
+// newFakeGitlab starts a fake GitLab API and returns a client talking to it.
+func newFakeGitlab(t *testing.T) (*fakeGitlab, *Client) {
+	fake := &fakeGitlab{nextID: 1,
+		projects: make(map[string]*project),
+		hooks:    make(map[int64]*hook),
+		keys:     make(map[int64]*deployKey),
+		files:    make(map[string]string),
+	}
+
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	client, err := NewClient(server.URL, fakeToken, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fake, client
+}
+
+// ServeHTTP implements http.Handler.
+func (f *fakeGitlab) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if request.Header.Get(privateTokenHeader) != fakeToken {
+		writeJSON(writer, http.StatusUnauthorized, map[string]string{"message": "401 Unauthorized"})
+		return
+	}
+
+	// Escaped paths keep the slashes of project and file paths encoded.
+	segments := strings.Split(strings.TrimPrefix(request.URL.EscapedPath(), apiPath+"projects/"), "/")
+	projectName, _ := url.PathUnescape(segments[0])
+
+	p, exists := f.projects[projectName]
+	if !exists {
+		writeJSON(writer, http.StatusNotFound, map[string]string{"message": "404 Project Not Found"})
+		return
+	}
+
+	switch {
+	case len(segments) == 1 && request.Method == http.MethodGet:
+		writeJSON(writer, http.StatusOK, p)
+
+	case len(segments) >= 2 && segments[1] == "hooks":
+		f.serveHooks(writer, request, segments[2:])
+
+	case len(segments) >= 2 && segments[1] == "deploy_keys":
+		f.serveDeployKeys(writer, request, segments[2:])
+
+	case len(segments) == 5 && segments[1] == "repository" && segments[2] == "files" && segments[4] == "raw":
+		filePath, _ := url.PathUnescape(segments[3])
+		content, exists := f.files[fmt.Sprintf("%s:%s@%s", projectName, filePath, request.URL.Query().Get("ref"))]
+		if !exists {
+			writeJSON(writer, http.StatusNotFound, map[string]string{"message": "404 File Not Found"})
+			return
+		}
+		writer.Write([]byte(content))
+
+	default:
+		writeJSON(writer, http.StatusNotFound, map[string]string{"message": "404 Not Found"})
+	}
+}
+
+// serveHooks serves project hooks.
+func (f *fakeGitlab) serveHooks(writer http.ResponseWriter, request *http.Request, segments []string) {
+	if len(segments) == 0 && request.Method == http.MethodPost {
+		var h hook
+		json.NewDecoder(request.Body).Decode(&h)
+		h.ID = f.newID()
+		f.hooks[h.ID] = &h
+		writeJSON(writer, http.StatusCreated, h)
+		return
+	}
+
+	id, _ := strconv.ParseInt(segments[0], 10, 64)
+	h, exists := f.hooks[id]
+	if !exists {
+		writeJSON(writer, http.StatusNotFound, map[string]string{"message": "404 Not found"})
+		return
+	}
+
+	switch request.Method {
+	case http.MethodGet:
+		// GitLab never returns tokens.
+		current := *h
+		current.Token = ""
+		writeJSON(writer, http.StatusOK, current)
+
+	case http.MethodPut:
+		var updated hook
+		json.NewDecoder(request.Body).Decode(&updated)
+		updated.ID = id
+		if updated.Token == "" {
+			updated.Token = h.Token
+		}
+		f.hooks[id] = &updated
+		writeJSON(writer, http.StatusOK, updated)
+
+	case http.MethodDelete:
+		delete(f.hooks, id)
+		writer.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// serveDeployKeys serves deploy keys.
+func (f *fakeGitlab) serveDeployKeys(writer http.ResponseWriter, request *http.Request, segments []string) {
+	if len(segments) == 0 && request.Method == http.MethodPost {
+		var key deployKey
+		json.NewDecoder(request.Body).Decode(&key)
+		key.ID = f.newID()
+		f.keys[key.ID] = &key
+		writeJSON(writer, http.StatusCreated, key)
+		return
+	}
+
+	id, _ := strconv.ParseInt(segments[0], 10, 64)
+	key, exists := f.keys[id]
+	if !exists {
+		writeJSON(writer, http.StatusNotFound, map[string]string{"message": "404 Deploy Key Not Found"})
+		return
+	}
+
+	switch request.Method {
+	case http.MethodGet:
+		writeJSON(writer, http.StatusOK, key)
+
+	case http.MethodDelete:
+		delete(f.keys, id)
+		writer.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// newID returns a new resource id.
+func (f *fakeGitlab) newID() int64 {
+	id := f.nextID
+	f.nextID++
+	return id
+}
+
+// writeJSON writes the supplied value as a JSON response.
+func writeJSON(writer http.ResponseWriter, status int, value interface{}) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+	json.NewEncoder(writer).Encode(value)
+}
@@ -0,0 +1,218 @@
+package gitlab
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	"github.com/nubank/workflows/pkg/github"
+	"github.com/nubank/workflows/pkg/rest"
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/logging"
+)
+
+func newTestContext() context.Context {
+	return logging.WithLogger(context.Background(), zap.NewNop().Sugar())
+}
+
+func newTestWorkflow() *workflowsv1alpha1.Workflow {
+	return &workflowsv1alpha1.Workflow{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-1", Namespace: "dev"},
+		Spec: workflowsv1alpha1.WorkflowSpec{
+			Repository: &workflowsv1alpha1.Repository{Owner: "my-group/my-subgroup",
+				Name:     "my-project",
+				Provider: workflowsv1alpha1.GitlabProvider,
+			},
+			AdditionalRepositories: []workflowsv1alpha1.Repository{{Owner: "my-group",
+				Name:      "my-lib",
+				Provider:  workflowsv1alpha1.GitlabProvider,
+				DeployKey: &workflowsv1alpha1.DeployKey{ReadOnly: false},
+			}},
+			Webhook: &workflowsv1alpha1.Webhook{URL: "https://workflows.example.com"},
+			Events:  []string{"pull_request"},
+		},
+	}
+}
+
+func TestReconcileRepos(t *testing.T) {
+	fake, client := newFakeGitlab(t)
+	fake.projects["my-group/my-subgroup/my-project"] = &project{ID: 1, DefaultBranch: "main", Visibility: "internal"}
+	fake.projects["my-group/my-lib"] = &project{ID: 2, DefaultBranch: "master", Visibility: "public"}
+
+	workflow := newTestWorkflow()
+	if err := NewRepoReconciler(client).ReconcileRepos(newTestContext(), workflow); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if repo := workflow.Spec.Repository; repo.DefaultBranch != "main" || !repo.Private {
+		t.Errorf("Want private repository with default branch main, got %+v", repo)
+	}
+
+	if repo := workflow.Spec.AdditionalRepositories[0]; repo.DefaultBranch != "master" || repo.Private {
+		t.Errorf("Want public repository with default branch master, got %+v", repo)
+	}
+}
+
+func TestReconcileReposFailsForUnknownProjects(t *testing.T) {
+	_, client := newFakeGitlab(t)
+
+	err := NewRepoReconciler(client).ReconcileRepos(newTestContext(), newTestWorkflow())
+	if err == nil || !strings.Contains(err.Error(), "404 Project Not Found") {
+		t.Errorf("Want a not found error, got %v", err)
+	}
+}
+
+func TestReconcileHook(t *testing.T) {
+	fake, client := newFakeGitlab(t)
+	fake.projects["my-group/my-subgroup/my-project"] = &project{ID: 1}
+
+	ctx := newTestContext()
+	workflow := newTestWorkflow()
+	reconciler := NewWebhookReconciler(client)
+
+	created, err := reconciler.ReconcileHook(ctx, workflow)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	h := fake.hooks[created.ID]
+	if h == nil || *workflow.GetWebhookID() != created.ID {
+		t.Fatalf("Want hook #%d to be created and recorded, got %+v", created.ID, workflow.Status.Annotations)
+	}
+
+	if h.Token != string(created.Secret) || h.URL != workflow.GetHooksURL() || h.PushEvents || !h.MergeRequestsEvents || !h.EnableSSLVerification {
+		t.Errorf("Unexpected hook %+v", h)
+	}
+
+	// Up to date hooks are left alone.
+	if webhook, err := reconciler.ReconcileHook(ctx, workflow); err != nil || webhook != nil {
+		t.Errorf("Want no changes, got %+v and error %v", webhook, err)
+	}
+
+	// Hooks are updated when events change, keeping their token.
+	workflow.Spec.Events = []string{"push", "pull_request"}
+	updated, err := reconciler.ReconcileHook(ctx, workflow)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if h := fake.hooks[created.ID]; updated.ID != created.ID || len(updated.Secret) != 0 || !h.PushEvents || h.Token != string(created.Secret) {
+		t.Errorf("Want hook #%d to be updated, got %+v", created.ID, h)
+	}
+
+	// Hooks deleted by mistake are created again.
+	delete(fake.hooks, created.ID)
+	recreated, err := reconciler.ReconcileHook(ctx, workflow)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if recreated.ID == created.ID || len(recreated.Secret) == 0 || *workflow.GetWebhookID() != recreated.ID {
+		t.Errorf("Want a new hook, got %+v", recreated)
+	}
+
+	if err := reconciler.Delete(ctx, workflow); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(fake.hooks) != 0 {
+		t.Errorf("Want hooks to be deleted, got %+v", fake.hooks)
+	}
+}
+
+func TestReconcileKeys(t *testing.T) {
+	fake, client := newFakeGitlab(t)
+	fake.projects["my-group/my-subgroup/my-project"] = &project{ID: 1}
+	fake.projects["my-group/my-lib"] = &project{ID: 2}
+
+	ctx := newTestContext()
+	workflow := newTestWorkflow()
+	workflow.Spec.Repository.Private = true
+	workflow.Spec.AdditionalRepositories[0].Private = true
+	reconciler := NewDeployKeysReconciler(client)
+
+	keyPairs, err := reconciler.ReconcileKeys(ctx, workflow)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(keyPairs) != 2 || len(fake.keys) != 2 {
+		t.Fatalf("Want 2 deploy keys, got %d key pairs and keys %+v", len(keyPairs), fake.keys)
+	}
+
+	lib := &workflow.Spec.AdditionalRepositories[0]
+	if key := fake.keys[*workflow.GetDeployKeyID(lib)]; !key.CanPush || key.Key != string(keyPairs[1].PublicKey) {
+		t.Errorf("Want a writable key for %s, got %+v", lib, key)
+	}
+
+	// Up to date keys are left alone.
+	if keyPairs, err := reconciler.ReconcileKeys(ctx, workflow); err != nil || len(keyPairs) != 0 {
+		t.Errorf("Want no changes, got %d key pairs and error %v", len(keyPairs), err)
+	}
+
+	// Keys are rotated when their permissions change.
+	previousID := *workflow.GetDeployKeyID(lib)
+	lib.DeployKey.ReadOnly = true
+	keyPairs, err = reconciler.ReconcileKeys(ctx, workflow)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(keyPairs) != 1 || fake.keys[previousID] != nil || fake.keys[*workflow.GetDeployKeyID(lib)].CanPush {
+		t.Errorf("Want the key of %s to be rotated, got keys %+v", lib, fake.keys)
+	}
+
+	if err := reconciler.Delete(ctx, workflow); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(fake.keys) != 0 {
+		t.Errorf("Want keys to be deleted, got %+v", fake.keys)
+	}
+}
+
+func TestGetWorkflowContent(t *testing.T) {
+	fake, client := newFakeGitlab(t)
+	fake.projects["my-group/my-subgroup/my-project"] = &project{ID: 1}
+	fake.files["my-group/my-subgroup/my-project:.workflows/test-1.yaml@a1b2c3"] = `spec:
+  repo:
+    owner: attacker
+    name: other-project
+  events:
+  - push
+  tasks:
+    test:
+      steps:
+      - run: make test
+`
+
+	ctx := newTestContext()
+	workflow := newTestWorkflow()
+	reader := NewWorkflowReader(client)
+
+	got, err := reader.GetWorkflowContent(ctx, workflow, ".workflows/test-1.yaml", "a1b2c3")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if got.Spec.Repository.String() != "my-group/my-subgroup/my-project" || got.Spec.Tasks["test"] == nil {
+		t.Errorf("Unexpected workflow %+v", got.Spec)
+	}
+
+	if _, err := reader.GetWorkflowContent(ctx, workflow, ".workflows/test-1.yaml", "d4e5f6"); !github.IsNotFound(err) {
+		t.Errorf("Want a not found error, got %v", err)
+	}
+}
+
+func TestRequestsAreAuthenticated(t *testing.T) {
+	fake, client := newFakeGitlab(t)
+	fake.projects["my-group/my-subgroup/my-project"] = &project{ID: 1}
+	client.token = "glpat-revoked"
+
+	err := NewRepoReconciler(client).ReconcileRepos(newTestContext(), newTestWorkflow())
+	if !rest.HasStatus(err, 401) {
+		t.Errorf("Want an unauthorized error, got %v", err)
+	}
+}
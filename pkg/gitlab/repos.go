@@ -0,0 +1,34 @@
+package gitlab
+
+import (
+	"github.com/nubank/workflows/pkg/github"
+	"github.com/nubank/workflows/pkg/rest"
+)
+
+// project is a GitLab project, as returned by the API.
+type project struct {
+	ID            int64  `json:"id"`
+	DefaultBranch string `json:"default_branch"`
+	Visibility    string `json:"visibility"`
+}
+
+// GetDefaultBranch implements rest.Repository.
+func (p *project) GetDefaultBranch() string {
+	return p.DefaultBranch
+}
+
+// IsPrivate implements rest.Repository. Internal projects are considered
+// private, since they can't be cloned anonymously.
+func (p *project) IsPrivate() bool {
+	return p.Visibility != "public"
+}
+
+// Repository implements rest.API.
+func (c *Client) Repository() rest.Repository {
+	return &project{}
+}
+
+// NewRepoReconciler returns a new RepoReconciler for GitLab projects.
+func NewRepoReconciler(client *Client) github.RepoReconciler {
+	return rest.NewRepoReconciler(client.Client)
+}
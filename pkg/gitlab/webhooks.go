@@ -0,0 +1,72 @@
+package gitlab
+
+import (
+	"net/http"
+
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	"github.com/nubank/workflows/pkg/github"
+	"github.com/nubank/workflows/pkg/rest"
+)
+
+// hook is a GitLab project hook, as sent to and returned by the API.
+type hook struct {
+	ID                    int64  `json:"id,omitempty"`
+	URL                   string `json:"url"`
+	Token                 string `json:"token,omitempty"`
+	PushEvents            bool   `json:"push_events"`
+	MergeRequestsEvents   bool   `json:"merge_requests_events"`
+	EnableSSLVerification bool   `json:"enable_ssl_verification"`
+}
+
+// GetID implements rest.Webhook.
+func (h *hook) GetID() int64 {
+	return h.ID
+}
+
+// ChangedFrom implements rest.Webhook.
+func (h *hook) ChangedFrom(desired rest.Webhook) bool {
+	d := desired.(*hook)
+	return h.URL != d.URL ||
+		h.PushEvents != d.PushEvents ||
+		h.MergeRequestsEvents != d.MergeRequestsEvents ||
+		!h.EnableSSLVerification
+}
+
+// HooksPath implements rest.API.
+func (c *Client) HooksPath(repo *workflowsv1alpha1.Repository) string {
+	return c.RepoPath(repo) + "/hooks"
+}
+
+// HookUpdateMethod implements rest.API.
+func (c *Client) HookUpdateMethod() string {
+	return http.MethodPut
+}
+
+// Webhook implements rest.API.
+func (c *Client) Webhook() rest.Webhook {
+	return &hook{}
+}
+
+// NewWebhook implements rest.API.
+func (c *Client) NewWebhook(workflow *workflowsv1alpha1.Workflow, token string) rest.Webhook {
+	h := &hook{URL: workflow.GetHooksURL(),
+		Token:                 token,
+		EnableSSLVerification: true,
+	}
+
+	for _, event := range workflow.Spec.Events {
+		switch event {
+		case "push":
+			h.PushEvents = true
+		case "pull_request":
+			h.MergeRequestsEvents = true
+		}
+	}
+	return h
+}
+
+// NewWebhookReconciler returns a new WebhookReconciler for GitLab project
+// hooks.
+func NewWebhookReconciler(client *Client) github.WebhookReconciler {
+	return rest.NewWebhookReconciler(client.Client)
+}
@@ -0,0 +1,21 @@
+package gitlab
+
+import (
+	"fmt"
+	"net/url"
+
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	"github.com/nubank/workflows/pkg/github"
+	"github.com/nubank/workflows/pkg/rest"
+)
+
+// RawFilePath implements rest.API.
+func (c *Client) RawFilePath(repo *workflowsv1alpha1.Repository, filePath, ref string) string {
+	return fmt.Sprintf("%s/repository/files/%s/raw?ref=%s", c.RepoPath(repo), url.PathEscape(filePath), url.QueryEscape(ref))
+}
+
+// NewWorkflowReader returns a new WorkflowReader for workflows stored in
+// GitLab repositories.
+func NewWorkflowReader(client *Client) github.WorkflowReader {
+	return rest.NewWorkflowReader(client.Client)
+}
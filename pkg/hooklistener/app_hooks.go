@@ -0,0 +1,99 @@
+package hooklistener
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	"github.com/nubank/workflows/pkg/github"
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/logging"
+)
+
+// defaultAppWebhookSecretPath is where the secret of the Github App's webhook
+// is mounted. The App webhook is only served when it exists.
+const defaultAppWebhookSecretPath = "/var/run/secrets/github-app-webhook/secret-token"
+
+// handleAppEvent takes the event delivered by the webhook of the Github App
+// workflows are installed with. Github only notifies the App owning checks
+// when someone asks for them to be run again, so rerun requests are the only
+// events acted upon. They're dispatched to every workflow of the event's
+// repository, which tell apart the checks they reported.
+func (e *EventHandler) handleAppEvent(ctx context.Context, event *github.Event) *Response {
+	logger := logging.FromContext(ctx)
+
+	webhookSecret, err := ioutil.ReadFile(e.appWebhookSecretPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return NotFound("The Github App webhook isn't configured")
+	} else if err != nil {
+		logger.Error("Error reading the Github App webhook secret", zap.Error(err))
+		return InternalServerError("An internal error has occurred while verifying the request signature")
+	}
+
+	if valid, message := event.VerifySignature(bytes.TrimSpace(webhookSecret)); !valid {
+		return Forbidden(message)
+	}
+
+	if event.Name == "ping" {
+		return OK("Webhook is all set!")
+	}
+
+	if !event.IsRerunRequest() {
+		return Accepted(fmt.Sprintf("Github %s event doesn't concern workflows: only requests to rerun checks are handled", event.Name))
+	}
+
+	workflows, err := e.workflowsClientSet.WorkflowsV1alpha1().Workflows(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		logger.Error("Error listing workflows", zap.Error(err))
+		return InternalServerError(fmt.Sprintf("An internal error has occurred while looking up the workflows of repository %s", event.Repository))
+	}
+
+	created := false
+	messages := make([]string, 0)
+	for i := range workflows.Items {
+		workflow := &workflows.Items[i]
+		if !isWorkflowOf(workflow, event.Repository) {
+			continue
+		}
+
+		workflowCtx, err := e.withCredentials(ctx, workflow)
+		if err != nil {
+			logger.Error("Error reading Github credentials", zap.Error(err))
+			return InternalServerError(fmt.Sprintf("An internal error has occurred while reading the Github credentials of workflow %s/%s", workflow.GetNamespace(), workflow.GetName()))
+		}
+
+		response := e.rerunWorkflow(workflowCtx, workflow, event)
+		switch response.Status {
+		case http.StatusCreated:
+			created = true
+		case http.StatusAccepted:
+		default:
+			return response
+		}
+		messages = append(messages, response.Payload.Message)
+	}
+
+	if len(messages) == 0 {
+		return Accepted(fmt.Sprintf("Repository %s has no workflows", event.Repository))
+	}
+
+	if created {
+		return Created(strings.Join(messages, "\n"))
+	}
+	return Accepted(strings.Join(messages, "\n"))
+}
+
+// isWorkflowOf returns true if the supplied workflow is triggered by events
+// of the Github repository in question.
+func isWorkflowOf(workflow *workflowsv1alpha1.Workflow, repository string) bool {
+	return workflow.GetProvider() == workflowsv1alpha1.GithubProvider &&
+		workflow.Spec.Repository != nil &&
+		strings.EqualFold(workflow.Spec.Repository.String(), repository)
+}
@@ -0,0 +1,119 @@
+package hooklistener
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nubank/workflows/pkg/apis/config"
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// withAppWebhookSecret configures the supplied handler with a Github App
+// webhook signed with the key secret.
+func withAppWebhookSecret(t *testing.T, handler *EventHandler) *EventHandler {
+	handler.appWebhookSecretPath = filepath.Join(t.TempDir(), "secret-token")
+	if err := ioutil.WriteFile(handler.appWebhookSecretPath, []byte("secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return handler
+}
+
+func TestAppWebhookRerunsCheckRuns(t *testing.T) {
+	handler := withAppWebhookSecret(t, newRerunEventHandler(newPipelineRun("test-1-run-abc12", "833568e", time.Now())))
+
+	event := newRerunEvent("check_run")
+	event.CheckRunName = "test-1"
+	event.CheckRunExternalID = "dev/test-1-run-abc12"
+
+	response := handler.handleAppEvent(newTestContext(&config.Defaults{}), event)
+
+	if response.Status != 201 {
+		t.Fatalf("Want status 201, but got %d: %s", response.Status, response.Payload.Message)
+	}
+
+	if reruns := getReruns(t, handler, "test-1-run-abc12"); len(reruns) != 1 {
+		t.Errorf("Want 1 rerun, got %d", len(reruns))
+	}
+}
+
+func TestAppWebhookIgnoresWorkflowsOfOtherRepositories(t *testing.T) {
+	handler := withAppWebhookSecret(t, newRerunEventHandler(newPipelineRun("test-1-run-abc12", "833568e", time.Now())))
+
+	event := newRerunEvent("check_suite")
+	event.Repository = "my-org/other-repo"
+
+	response := handler.handleAppEvent(newTestContext(&config.Defaults{}), event)
+
+	wantMessage := "Repository my-org/other-repo has no workflows"
+	if response.Status != 202 || response.Payload.Message != wantMessage {
+		t.Errorf("Want status 202 and message %s, but got %d and %s", wantMessage, response.Status, response.Payload.Message)
+	}
+
+	if reruns := getReruns(t, handler, "test-1-run-abc12"); len(reruns) != 0 {
+		t.Errorf("Want no reruns, got %d", len(reruns))
+	}
+}
+
+func TestAppWebhookIgnoresEventsOtherThanRerunRequests(t *testing.T) {
+	handler := withAppWebhookSecret(t, newRerunEventHandler(newPipelineRun("test-1-run-abc12", "833568e", time.Now())))
+
+	event := newRerunEvent("check_run")
+	event.Action = "completed"
+
+	response := handler.handleAppEvent(newTestContext(&config.Defaults{}), event)
+
+	if response.Status != 202 {
+		t.Errorf("Want status 202, but got %d: %s", response.Status, response.Payload.Message)
+	}
+
+	pipelineRuns, err := handler.tektonClientSet.TektonV1beta1().PipelineRuns("dev").List(newTestContext(&config.Defaults{}), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pipelineRuns.Items) != 1 {
+		t.Errorf("Want no new PipelineRuns, got %d PipelineRuns", len(pipelineRuns.Items))
+	}
+}
+
+func TestAppWebhookVerifiesSignatures(t *testing.T) {
+	handler := withAppWebhookSecret(t, newRerunEventHandler())
+	if err := ioutil.WriteFile(handler.appWebhookSecretPath, []byte("other-secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	response := handler.handleAppEvent(newTestContext(&config.Defaults{}), newRerunEvent("check_suite"))
+
+	if response.Status != 403 {
+		t.Errorf("Want status 403, but got %d: %s", response.Status, response.Payload.Message)
+	}
+}
+
+func TestAppWebhookIsNotFoundUnlessConfigured(t *testing.T) {
+	handler := newRerunEventHandler()
+	handler.appWebhookSecretPath = filepath.Join(t.TempDir(), "secret-token")
+
+	response := handler.handleAppEvent(newTestContext(&config.Defaults{}), newRerunEvent("check_suite"))
+
+	if response.Status != 404 {
+		t.Errorf("Want status 404, but got %d: %s", response.Status, response.Payload.Message)
+	}
+}
+
+func TestIsWorkflowOf(t *testing.T) {
+	workflow := &workflowsv1alpha1.Workflow{Spec: workflowsv1alpha1.WorkflowSpec{
+		Repository: &workflowsv1alpha1.Repository{Owner: "my-org", Name: "my-repo"},
+	}}
+
+	if !isWorkflowOf(workflow, "My-Org/my-repo") {
+		t.Error("Want repository names to be compared regardless of case")
+	}
+
+	workflow.Spec.Repository.Provider = workflowsv1alpha1.GitlabProvider
+	if isWorkflowOf(workflow, "my-org/my-repo") {
+		t.Error("Want workflows of other providers to be ignored")
+	}
+}
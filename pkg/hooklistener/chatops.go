@@ -0,0 +1,166 @@
+package hooklistener
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/nubank/workflows/pkg/chatops"
+	"github.com/nubank/workflows/pkg/filters"
+	"github.com/nubank/workflows/pkg/github"
+	"github.com/nubank/workflows/pkg/pipelinerun"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"knative.dev/pkg/logging"
+
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+)
+
+// parseCommand returns the slash command written in a newly created pull
+// request comment and true or nil and false if the event isn't such a
+// comment.
+func parseCommand(event *github.Event) (*chatops.Command, bool) {
+	if event.Name != "issue_comment" || event.Action != "created" || event.PullRequestNumber == 0 {
+		return nil, false
+	}
+	return chatops.Parse(event.Comment)
+}
+
+// runCommand executes the supplied slash command on behalf of the user who
+// commented on the pull request, provided they're allowed to act on the
+// workflow and have write access to the repository.
+// Filters other than actors aren't evaluated since commands explicitly ask for
+// workflows to run.
+func (e *EventHandler) runCommand(ctx context.Context, workflow *workflowsv1alpha1.Workflow, event *github.Event, command *chatops.Command) *Response {
+	logger := logging.FromContext(ctx).With("command", command.String())
+
+	if !commandAppliesTo(workflow, command) {
+		return Accepted(fmt.Sprintf("Command %s doesn't apply to workflow %s/%s", command, workflow.GetNamespace(), workflow.GetName()))
+	}
+
+	if allowed, reason := filters.CanAct(ctx, workflow, event); !allowed {
+		return Forbidden(fmt.Sprintf("%s can't run %s: %s", event.Sender, command, reason))
+	}
+
+	repository := workflow.Spec.Repository
+	allowed, err := e.permissionChecker.CanWrite(ctx, repository.Owner, repository.Name, event.Sender)
+	if err != nil {
+		logger.Error("Error checking the commenter's permissions", zap.Error(err))
+		return InternalServerError(fmt.Sprintf("An internal error has occurred while checking the permissions of %s", event.Sender))
+	}
+
+	if !allowed {
+		return Forbidden(fmt.Sprintf("%s must have write access to %s to run %s", event.Sender, repository, command))
+	}
+
+	pullRequest, err := e.pullRequestReader.GetPullRequest(ctx, repository.Owner, repository.Name, event.PullRequestNumber)
+	if err != nil {
+		logger.Error("Error reading pull request", zap.Error(err))
+		return InternalServerError(fmt.Sprintf("An internal error has occurred while reading pull request #%d", event.PullRequestNumber))
+	}
+
+	event = withPullRequest(event, pullRequest)
+
+	var response *Response
+	if command.Name == chatops.Cancel {
+		response = e.cancelPipelineRuns(ctx, workflow, event)
+	} else {
+		response = e.runWorkflow(ctx, workflow, event)
+	}
+
+	e.eventRecorder.Event(workflow, corev1.EventTypeNormal, "CommandExecuted", fmt.Sprintf("%s commented %s on pull request #%d (delivery %s): %s", event.Sender, command, event.PullRequestNumber, event.DeliveryID, response.Payload.Message))
+	return response
+}
+
+// commandAppliesTo returns true if the supplied command concerns the workflow
+// in question or false otherwise.
+func commandAppliesTo(workflow *workflowsv1alpha1.Workflow, command *chatops.Command) bool {
+	switch command.Name {
+	case chatops.Retest:
+		for _, event := range workflow.Spec.Events {
+			if event == "pull_request" {
+				return true
+			}
+		}
+		return false
+
+	case chatops.Run:
+		return command.Args[0] == workflow.GetName()
+
+	default:
+		return true
+	}
+}
+
+// withPullRequest returns a copy of the supplied event describing the current
+// state of the pull request, since issue_comment payloads lack it.
+func withPullRequest(event *github.Event, pullRequest *github.PullRequest) *github.Event {
+	e := *event
+	e.Branch = pullRequest.HeadBranch
+	e.HeadCommitSHA = pullRequest.HeadCommitSHA
+	e.BaseBranch = pullRequest.BaseBranch
+	e.BaseCommitSHA = pullRequest.BaseCommitSHA
+	e.PullRequestTitle = pullRequest.Title
+	return &e
+}
+
+// runWorkflow creates a PipelineRun for the pull request's head commit.
+func (e *EventHandler) runWorkflow(ctx context.Context, workflow *workflowsv1alpha1.Workflow, event *github.Event) *Response {
+	logger := logging.FromContext(ctx)
+
+	if w, err := e.getWorkflowFromRepository(ctx, workflow, event); err != nil {
+		logger.Errorw("Error getting workflow from repository", zap.Error(err))
+		return InternalServerError("An internal error has occurred while trying to read the workflow's configuration from the repository")
+	} else if w != nil {
+		workflow = w
+	}
+
+	createdPipelineRun, err := e.createPipelineRun(ctx, workflow, event)
+	if err != nil {
+		logger.Error("Error creating PipelineRun object", zap.Error(err))
+		return InternalServerError(fmt.Sprintf("An internal error has occurred while creating the PipelineRun for workflow %s/%s", workflow.GetNamespace(), workflow.GetName()))
+	}
+
+	logger.Infow("PipelineRun has been successfully created", "tekton.dev/pipeline-run", createdPipelineRun.GetName())
+	return Created(fmt.Sprintf("PipelineRun %s has been successfully created", createdPipelineRun.GetName()))
+}
+
+// cancelPipelineRuns cancels PipelineRuns of the workflow that are still
+// running against the pull request's head commit.
+func (e *EventHandler) cancelPipelineRuns(ctx context.Context, workflow *workflowsv1alpha1.Workflow, event *github.Event) *Response {
+	logger := logging.FromContext(ctx)
+
+	selector := labels.SelectorFromSet(labels.Set{pipelinerun.WorkflowLabel: workflow.GetName()})
+	pipelineRuns, err := e.tektonClientSet.TektonV1beta1().PipelineRuns(workflow.GetNamespace()).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		logger.Error("Error listing PipelineRuns", zap.Error(err))
+		return InternalServerError(fmt.Sprintf("An internal error has occurred while listing PipelineRuns of workflow %s/%s", workflow.GetNamespace(), workflow.GetName()))
+	}
+
+	cancelled := 0
+	for i := range pipelineRuns.Items {
+		pipelineRun := &pipelineRuns.Items[i]
+		annotations := pipelineRun.GetAnnotations()
+		if pipelineRun.IsDone() || pipelineRun.IsCancelled() ||
+			annotations[pipelinerun.RepositoryAnnotation] != workflow.Spec.Repository.String() ||
+			annotations[pipelinerun.PullRequestAnnotation] != strconv.Itoa(event.PullRequestNumber) ||
+			annotations[pipelinerun.HeadCommitAnnotation] != event.HeadCommitSHA {
+			continue
+		}
+
+		if err := pipelinerun.Cancel(ctx, e.tektonClientSet, pipelineRun); err != nil {
+			logger.Error("Error cancelling PipelineRun", zap.Error(err))
+			return InternalServerError(fmt.Sprintf("An internal error has occurred while cancelling PipelineRun %s", pipelineRun.GetName()))
+		}
+
+		logger.Infow("PipelineRun has been successfully cancelled", "tekton.dev/pipeline-run", pipelineRun.GetName())
+		cancelled++
+	}
+
+	if cancelled == 0 {
+		return Accepted(fmt.Sprintf("Workflow %s/%s has no running PipelineRuns to cancel", workflow.GetNamespace(), workflow.GetName()))
+	}
+	return OK(fmt.Sprintf("%d PipelineRun(s) have been successfully cancelled", cancelled))
+}
@@ -0,0 +1,285 @@
+package hooklistener
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/nubank/workflows/pkg/apis/config"
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	workflowsclientset "github.com/nubank/workflows/pkg/client/clientset/versioned/fake"
+	"github.com/nubank/workflows/pkg/github"
+	githubmocks "github.com/nubank/workflows/pkg/github/mocks"
+	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	tektonclientset "github.com/tektoncd/pipeline/pkg/client/clientset/versioned/fake"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	kubeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+// fakePermissionChecker grants write access to a fixed set of users.
+type fakePermissionChecker struct {
+	writers map[string]bool
+}
+
+// CanWrite implements github.PermissionChecker.
+func (f *fakePermissionChecker) CanWrite(ctx context.Context, owner, repo, user string) (bool, error) {
+	return f.writers[user], nil
+}
+
+// fakePullRequestReader returns the same pull request whatever the number.
+type fakePullRequestReader struct {
+	pullRequest *github.PullRequest
+}
+
+// GetPullRequest implements github.PullRequestReader.
+func (f *fakePullRequestReader) GetPullRequest(ctx context.Context, owner, repo string, number int) (*github.PullRequest, error) {
+	return f.pullRequest, nil
+}
+
+func newChatOpsEventHandler(t *testing.T, pipelineRuns ...*pipelinev1beta1.PipelineRun) *EventHandler {
+	objects := make([]runtime.Object, 0)
+	for _, pipelineRun := range pipelineRuns {
+		objects = append(objects, pipelineRun)
+	}
+
+	workflowReader := githubmocks.NewMockWorkflowReader(gomock.NewController(t))
+	workflowReader.EXPECT().
+		GetWorkflowContent(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil, &github.NotFoundError{}).
+		AnyTimes()
+
+	return &EventHandler{workflowsClientSet: workflowsclientset.NewSimpleClientset(&workflowsv1alpha1.Workflow{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-1",
+			Namespace: "dev",
+		},
+		Spec: workflowsv1alpha1.WorkflowSpec{
+			Repository: &workflowsv1alpha1.Repository{
+				Owner: "my-org",
+				Name:  "my-repo",
+			},
+			Events: []string{"pull_request"},
+		},
+	}),
+		kubeClientSet: kubeclientset.NewSimpleClientset(&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "test-1-webhook-secret",
+			Namespace: "dev",
+		},
+			Data: map[string][]byte{
+				"secret-token": []byte("secret"),
+			},
+		}),
+		eventRecorder:     record.NewFakeRecorder(10),
+		tektonClientSet:   tektonclientset.NewSimpleClientset(objects...),
+		workflowReader:    workflowReader,
+		permissionChecker: &fakePermissionChecker{writers: map[string]bool{"john-doe": true}},
+		pullRequestReader: &fakePullRequestReader{pullRequest: &github.PullRequest{Number: 7,
+			Title:         "Add foo package",
+			HeadBranch:    "dev",
+			HeadCommitSHA: "833568e",
+			BaseBranch:    "main",
+			BaseCommitSHA: "a1b2c3d",
+		}},
+	}
+}
+
+func newCommentEvent(sender, comment string) *github.Event {
+	return &github.Event{
+		Action: "created",
+		Body: []byte(`{
+    "ref": "refs/heads/dev"
+}`),
+		Comment: comment,
+		// This digest was calculated with the key secret.
+		HMACSignature:     []byte("sha256=4ae9df17f8cc696722c87f771f0c60fa7b03d44488ae3e0f712f570c4e7a3888"),
+		Name:              "issue_comment",
+		PullRequestNumber: 7,
+		Repository:        "my-org/my-repo",
+		Sender:            sender,
+	}
+}
+
+func TestRetestCommandCreatesAPipelineRunForTheHeadCommit(t *testing.T) {
+	handler := newChatOpsEventHandler(t)
+
+	response := deliverEvent(handler, newTestContext(&config.Defaults{WorkflowsDir: ".tektoncd/workflows"}), types.NamespacedName{Namespace: "dev", Name: "test-1"}, newCommentEvent("john-doe", "/retest"))
+
+	if response.Status != 201 {
+		t.Fatalf("Want status 201, but got %d: %s", response.Status, response.Payload.Message)
+	}
+
+	pipelineRuns, err := handler.tektonClientSet.TektonV1beta1().PipelineRuns("dev").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pipelineRuns.Items) != 1 {
+		t.Fatalf("Want 1 PipelineRun, got %d", len(pipelineRuns.Items))
+	}
+
+	annotations := pipelineRuns.Items[0].Annotations
+	if got := annotations["workflows.dev/head-commit"]; got != "833568e" {
+		t.Errorf("Want head commit 833568e, got %s", got)
+	}
+
+	if got := annotations["workflows.dev/pull-request"]; got != "7" {
+		t.Errorf("Want pull request 7, got %s", got)
+	}
+}
+
+func TestCommandsThatDoNotApplyToTheWorkflow(t *testing.T) {
+	handler := newChatOpsEventHandler(t)
+
+	response := deliverEvent(handler, newTestContext(&config.Defaults{WorkflowsDir: ".tektoncd/workflows"}), types.NamespacedName{Namespace: "dev", Name: "test-1"}, newCommentEvent("john-doe", "/run test-2"))
+
+	wantStatus := 202
+	wantMessage := "Command /run test-2 doesn't apply to workflow dev/test-1"
+
+	if wantStatus != response.Status {
+		t.Errorf("Want status %d, but got %d", wantStatus, response.Status)
+	}
+
+	if wantMessage != response.Payload.Message {
+		t.Errorf("Want message %s, but got %s", wantMessage, response.Payload.Message)
+	}
+}
+
+func TestCommandsRequireWriteAccess(t *testing.T) {
+	handler := newChatOpsEventHandler(t)
+
+	response := deliverEvent(handler, newTestContext(&config.Defaults{WorkflowsDir: ".tektoncd/workflows"}), types.NamespacedName{Namespace: "dev", Name: "test-1"}, newCommentEvent("jane-doe", "/run test-1"))
+
+	wantStatus := 403
+	wantMessage := "jane-doe must have write access to my-org/my-repo to run /run test-1"
+
+	if wantStatus != response.Status {
+		t.Errorf("Want status %d, but got %d", wantStatus, response.Status)
+	}
+
+	if wantMessage != response.Payload.Message {
+		t.Errorf("Want message %s, but got %s", wantMessage, response.Payload.Message)
+	}
+}
+
+func TestCommandsHonorTheActorsOfTheWorkflow(t *testing.T) {
+	handler := newChatOpsEventHandler(t)
+	handler.permissionChecker = &fakePermissionChecker{writers: map[string]bool{"john-doe": true, "mallory": true}}
+
+	workflow, err := handler.workflowsClientSet.WorkflowsV1alpha1().Workflows("dev").Get(context.Background(), "test-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	workflow.Spec.Actors = &workflowsv1alpha1.Actors{Deny: []string{"Mallory"}}
+	if _, err := handler.workflowsClientSet.WorkflowsV1alpha1().Workflows("dev").Update(context.Background(), workflow, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	response := deliverEvent(handler, newTestContext(&config.Defaults{WorkflowsDir: ".tektoncd/workflows"}), types.NamespacedName{Namespace: "dev", Name: "test-1"}, newCommentEvent("mallory", "/retest"))
+
+	wantStatus := 403
+	wantMessage := "mallory can't run /retest: actor mallory is denied"
+
+	if wantStatus != response.Status {
+		t.Errorf("Want status %d, but got %d", wantStatus, response.Status)
+	}
+
+	if wantMessage != response.Payload.Message {
+		t.Errorf("Want message %s, but got %s", wantMessage, response.Payload.Message)
+	}
+
+	pipelineRuns, err := handler.tektonClientSet.TektonV1beta1().PipelineRuns("dev").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pipelineRuns.Items) != 0 {
+		t.Errorf("Want no PipelineRuns, got %d", len(pipelineRuns.Items))
+	}
+}
+
+func TestCancelCommandCancelsRunningPipelineRuns(t *testing.T) {
+	newPullRequestRun := func(name, headCommit string) *pipelinev1beta1.PipelineRun {
+		return &pipelinev1beta1.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{Name: name,
+				Namespace: "dev",
+				Labels: map[string]string{
+					"workflows.dev/workflow": "test-1",
+				},
+				Annotations: map[string]string{
+					"workflows.dev/repository":   "my-org/my-repo",
+					"workflows.dev/head-commit":  headCommit,
+					"workflows.dev/pull-request": "7",
+				},
+			},
+		}
+	}
+
+	handler := newChatOpsEventHandler(t, newPullRequestRun("test-1-run-abc12", "833568e"),
+		newPullRequestRun("test-1-run-def34", "a1b2c3d"))
+
+	response := deliverEvent(handler, newTestContext(&config.Defaults{WorkflowsDir: ".tektoncd/workflows"}), types.NamespacedName{Namespace: "dev", Name: "test-1"}, newCommentEvent("john-doe", "/cancel"))
+
+	wantStatus := 200
+	wantMessage := "1 PipelineRun(s) have been successfully cancelled"
+
+	if wantStatus != response.Status {
+		t.Errorf("Want status %d, but got %d", wantStatus, response.Status)
+	}
+
+	if wantMessage != response.Payload.Message {
+		t.Errorf("Want message %s, but got %s", wantMessage, response.Payload.Message)
+	}
+
+	tests := map[string]pipelinev1beta1.PipelineRunSpecStatus{
+		"test-1-run-abc12": pipelinev1beta1.PipelineRunSpecStatusCancelled,
+		"test-1-run-def34": "",
+	}
+
+	for name, want := range tests {
+		pipelineRun, err := handler.tektonClientSet.TektonV1beta1().PipelineRuns("dev").Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if pipelineRun.Spec.Status != want {
+			t.Errorf("PipelineRun %s: want status %q, got %q", name, want, pipelineRun.Spec.Status)
+		}
+	}
+}
+
+func TestCommentsOtherThanCommandsDoNotTriggerWorkflows(t *testing.T) {
+	handler := newChatOpsEventHandler(t)
+
+	tests := map[string]*github.Event{
+		"plain comment":       newCommentEvent("john-doe", "LGTM"),
+		"edited command":      newCommentEvent("john-doe", "/retest"),
+		"issue comment":       newCommentEvent("john-doe", "/retest"),
+		"unknown command":     newCommentEvent("john-doe", "/deploy"),
+		"comment from anyone": newCommentEvent("jane-doe", "Please merge"),
+	}
+	tests["edited command"].Action = "edited"
+	tests["issue comment"].PullRequestNumber = 0
+
+	for name, event := range tests {
+		t.Run(name, func(t *testing.T) {
+			response := deliverEvent(handler, newTestContext(&config.Defaults{WorkflowsDir: ".tektoncd/workflows"}), types.NamespacedName{Namespace: "dev", Name: "test-1"}, event)
+
+			wantMessage := "Comment isn't a slash command written on a pull request"
+			if response.Status != 202 || response.Payload.Message != wantMessage {
+				t.Errorf("Want status 202 and message %s, but got %d and %s", wantMessage, response.Status, response.Payload.Message)
+			}
+		})
+	}
+
+	pipelineRuns, err := handler.tektonClientSet.TektonV1beta1().PipelineRuns("dev").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pipelineRuns.Items) != 0 {
+		t.Errorf("Want no PipelineRuns, got %d", len(pipelineRuns.Items))
+	}
+}
@@ -0,0 +1,55 @@
+package hooklistener
+
+import (
+	"context"
+	"fmt"
+
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	"github.com/nubank/workflows/pkg/secrets"
+	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/logging"
+)
+
+// createCheckoutTokenSecret issues an installation token restricted to the
+// repositories of the supplied workflow and stores it in a new Secret, which
+// the PipelineRun's checkout steps authenticate with.
+func (e *EventHandler) createCheckoutTokenSecret(ctx context.Context, workflow *workflowsv1alpha1.Workflow) (*corev1.Secret, error) {
+	token, err := e.checkoutTokens.IssueCheckoutToken(ctx, workflow)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := e.kubeClientSet.CoreV1().Secrets(workflow.GetNamespace()).Create(ctx, secrets.OfCheckoutToken(workflow, []byte(token.Token)), metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("Error creating the Secret holding the checkout token of workflow %s/%s: %w", workflow.GetNamespace(), workflow.GetName(), err)
+	}
+	return secret, nil
+}
+
+// handOverCheckoutTokenSecret makes the supplied PipelineRun own the Secret
+// holding its checkout token, so they're garbage collected together. The
+// Secret is deleted when the PipelineRun couldn't be created (i.e. it's nil).
+// Failures are only logged: the Secret stays owned by the workflow and the
+// token expires within an hour anyway.
+func (e *EventHandler) handOverCheckoutTokenSecret(ctx context.Context, secret *corev1.Secret, pipelineRun *pipelinev1beta1.PipelineRun) {
+	if secret == nil {
+		return
+	}
+
+	logger := logging.FromContext(ctx).With("secret", secret.GetName())
+
+	if pipelineRun == nil {
+		if err := e.kubeClientSet.CoreV1().Secrets(secret.GetNamespace()).Delete(ctx, secret.GetName(), metav1.DeleteOptions{}); err != nil {
+			logger.Warnw("Error deleting the Secret holding an unused checkout token", zap.Error(err))
+		}
+		return
+	}
+
+	secrets.SetPipelineRunOwner(secret, pipelineRun)
+	if _, err := e.kubeClientSet.CoreV1().Secrets(secret.GetNamespace()).Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+		logger.Warnw("Error handing the Secret holding the checkout token over to the PipelineRun", zap.Error(err), "tekton.dev/pipeline-run", pipelineRun.GetName())
+	}
+}
@@ -0,0 +1,145 @@
+package hooklistener
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nubank/workflows/pkg/apis/config"
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	workflowsclientset "github.com/nubank/workflows/pkg/client/clientset/versioned/fake"
+	"github.com/nubank/workflows/pkg/github"
+	"github.com/nubank/workflows/pkg/pipelinerun"
+	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	tektonclientset "github.com/tektoncd/pipeline/pkg/client/clientset/versioned/fake"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	kubeclientset "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+)
+
+// fakeCheckoutTokenIssuer issues the same token whatever the workflow.
+type fakeCheckoutTokenIssuer struct {
+	token string
+}
+
+// IssueCheckoutToken implements github.CheckoutTokenIssuer.
+func (f *fakeCheckoutTokenIssuer) IssueCheckoutToken(ctx context.Context, workflow *workflowsv1alpha1.Workflow) (*github.CheckoutToken, error) {
+	return &github.CheckoutToken{Token: f.token}, nil
+}
+
+func newCheckoutTokenEventHandler(createPipelineRun k8stesting.ReactionFunc) *EventHandler {
+	kubeClient := kubeclientset.NewSimpleClientset(&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "test-1-webhook-secret",
+		Namespace: "dev",
+	},
+		Data: map[string][]byte{
+			"secret-token": []byte("secret"),
+		},
+	})
+	// The fake clientset doesn't generate names.
+	kubeClient.PrependReactor("create", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		secret := action.(k8stesting.CreateAction).GetObject().(*corev1.Secret)
+		if secret.Name == "" {
+			secret.Name = secret.GenerateName + "abc12"
+		}
+		return false, nil, nil
+	})
+
+	tektonClient := tektonclientset.NewSimpleClientset()
+	tektonClient.PrependReactor("create", "pipelineruns", createPipelineRun)
+
+	return &EventHandler{workflowsClientSet: workflowsclientset.NewSimpleClientset(&workflowsv1alpha1.Workflow{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-1",
+			Namespace: "dev",
+		},
+		Spec: workflowsv1alpha1.WorkflowSpec{
+			Repository: &workflowsv1alpha1.Repository{
+				Owner:   "my-org",
+				Name:    "my-repo",
+				Private: true,
+			},
+			Events:       []string{"push"},
+			Branches:     []string{"main"},
+			CheckoutAuth: workflowsv1alpha1.InstallationTokenCheckoutAuth,
+		},
+	}),
+		kubeClientSet:   kubeClient,
+		eventRecorder:   record.NewFakeRecorder(10),
+		tektonClientSet: tektonClient,
+		checkoutTokens:  &fakeCheckoutTokenIssuer{token: "ghs_abc"},
+	}
+}
+
+func newCheckoutTokenEvent() *github.Event {
+	return &github.Event{
+		Body: []byte(`{
+    "ref": "refs/heads/dev"
+}`),
+		// This digest was calculated with the key secret.
+		HMACSignature: []byte("sha256=4ae9df17f8cc696722c87f771f0c60fa7b03d44488ae3e0f712f570c4e7a3888"),
+		Name:          "push",
+		Branch:        "main",
+		Repository:    "my-org/my-repo",
+	}
+}
+
+func TestPipelineRunOwnsTheSecretHoldingItsCheckoutToken(t *testing.T) {
+	var pipelineRun *pipelinev1beta1.PipelineRun
+	handler := newCheckoutTokenEventHandler(func(action k8stesting.Action) (bool, runtime.Object, error) {
+		pipelineRun = action.(k8stesting.CreateAction).GetObject().(*pipelinev1beta1.PipelineRun).DeepCopy()
+		pipelineRun.Name = "test-1-run-123"
+		pipelineRun.UID = "8a1c5e3f"
+		return true, pipelineRun, nil
+	})
+
+	response := deliverEvent(handler, newTestContext(&config.Defaults{}), types.NamespacedName{Namespace: "dev", Name: "test-1"}, newCheckoutTokenEvent())
+
+	if response.Status != 201 {
+		t.Fatalf("Want status 201, but got %d: %s", response.Status, response.Payload.Message)
+	}
+
+	secret, err := handler.kubeClientSet.CoreV1().Secrets("dev").Get(context.Background(), "test-1-checkout-token-abc12", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := string(secret.Data["token"]); got != "ghs_abc" {
+		t.Errorf("Want token ghs_abc, got %s", got)
+	}
+
+	if got := pipelineRun.Annotations[pipelinerun.CheckoutTokenSecretAnnotation]; got != secret.GetName() {
+		t.Errorf("Want the PipelineRun to refer to Secret %s, got %s", secret.GetName(), got)
+	}
+
+	owner := metav1.GetControllerOf(secret)
+	if owner == nil || owner.Kind != "PipelineRun" || owner.Name != "test-1-run-123" || owner.UID != "8a1c5e3f" {
+		t.Errorf("Want the Secret to be owned by PipelineRun test-1-run-123, got %+v", owner)
+	}
+}
+
+func TestSecretHoldingTheCheckoutTokenIsDeletedWhenThePipelineRunCannotBeCreated(t *testing.T) {
+	handler := newCheckoutTokenEventHandler(func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("error creating PipelineRun")
+	})
+
+	response := deliverEvent(handler, newTestContext(&config.Defaults{}), types.NamespacedName{Namespace: "dev", Name: "test-1"}, newCheckoutTokenEvent())
+
+	if response.Status != 500 {
+		t.Errorf("Want status 500, but got %d", response.Status)
+	}
+
+	secrets, err := handler.kubeClientSet.CoreV1().Secrets("dev").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, secret := range secrets.Items {
+		if secret.GetName() != "test-1-webhook-secret" {
+			t.Errorf("Want Secret %s to be deleted", secret.GetName())
+		}
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"github.com/nubank/workflows/pkg/apis/config"
 	"github.com/nubank/workflows/pkg/filters"
 	"github.com/nubank/workflows/pkg/github"
+	"github.com/nubank/workflows/pkg/logstore"
+	"github.com/nubank/workflows/pkg/scm"
 	"github.com/nubank/workflows/pkg/secrets"
 	"go.uber.org/zap"
 
@@ -17,11 +19,18 @@ import (
 	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
 	workflowsclientset "github.com/nubank/workflows/pkg/client/clientset/versioned"
 	"github.com/nubank/workflows/pkg/pipelinerun"
+	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
 	tektonclientset "github.com/tektoncd/pipeline/pkg/client/clientset/versioned"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
 )
 
+// maxEventMessageLength is the maximum length of messages attached to
+// Kubernetes Events.
+const maxEventMessageLength = 1024
+
 // EventHandler handles incoming events from Github Webhooks by coordinating the
 // execution of Tekton PipelineRuns.
 type EventHandler struct {
@@ -39,25 +48,65 @@ type EventHandler struct {
 	// workflowsClientSet allows us to retrieve workflow objects from the Kubernetes cluster.
 	workflowsClientSet workflowsclientset.Interface
 
+	// providers describe where repositories are hosted, which determines
+	// where they're checked out from.
+	providers scm.Providers
+
 	// workflowReader allows us to read workflows declared directly in
-	// Github repositories.
+	// repositories.
 	workflowReader github.WorkflowReader
+
+	// eventCompleter allows us to fill in details that some providers leave
+	// out of the payloads of events (e.g. changed files). It's nil when events
+	// are always complete.
+	eventCompleter github.EventCompleter
+
+	// pullRequestReader allows us to read the current state of pull requests
+	// commented with slash commands.
+	pullRequestReader github.PullRequestReader
+
+	// permissionChecker allows us to verify whether users issuing slash
+	// commands have write access to repositories.
+	permissionChecker github.PermissionChecker
+
+	// checkoutTokens allows us to issue installation tokens that checkout
+	// steps of workflows not using deploy keys authenticate with.
+	checkoutTokens github.CheckoutTokenIssuer
+
+	// logStore allows us to read logs archived for finished PipelineRuns. It's
+	// nil when logs aren't archived.
+	logStore logstore.Store
+
+	// eventRecorder allows us to attach Kubernetes Events to workflows
+	// explaining how incoming Github events were handled.
+	eventRecorder record.EventRecorder
+
+	// appWebhookSecretPath is the path to the secret that events delivered
+	// by the Github App's webhook are signed with.
+	appWebhookSecretPath string
 }
 
-// triggerWorkflow takes the event delivered by a Github Webhook and creates a
-// Tekton PipelineRun.
-func (e *EventHandler) triggerWorkflow(ctx context.Context, namespacedName types.NamespacedName, event *github.Event) *Response {
+// getWorkflow reads the workflow whose hook an event was delivered to.
+func (e *EventHandler) getWorkflow(ctx context.Context, namespacedName types.NamespacedName) (*workflowsv1alpha1.Workflow, *Response) {
 	logger := logging.FromContext(ctx)
 
 	workflow, err := e.workflowsClientSet.WorkflowsV1alpha1().Workflows(namespacedName.Namespace).Get(ctx, namespacedName.Name, metav1.GetOptions{})
 	if err != nil {
 		logger.Error("Error reading workflow", zap.Error(err))
 		if apierrors.IsNotFound(err) {
-			return NotFound(fmt.Sprintf("Workflow %s not found", namespacedName))
+			return nil, NotFound(fmt.Sprintf("Workflow %s not found", namespacedName))
 		} else {
-			return InternalServerError(fmt.Sprintf("An internal error has occurred while reading workflow %s", namespacedName))
+			return nil, InternalServerError(fmt.Sprintf("An internal error has occurred while reading workflow %s", namespacedName))
 		}
 	}
+	return workflow, nil
+}
+
+// triggerWorkflow takes the event delivered by the hook of the supplied
+// workflow and creates a Tekton PipelineRun.
+func (e *EventHandler) triggerWorkflow(ctx context.Context, workflow *workflowsv1alpha1.Workflow, event *github.Event) *Response {
+	logger := logging.FromContext(ctx)
+	namespacedName := types.NamespacedName{Namespace: workflow.GetNamespace(), Name: workflow.GetName()}
 
 	webhookSecret, err := e.kubeClientSet.CoreV1().Secrets(workflow.GetNamespace()).Get(ctx, workflow.GetWebhookSecretName(), metav1.GetOptions{})
 	if err != nil {
@@ -80,6 +129,47 @@ func (e *EventHandler) triggerWorkflow(ctx context.Context, namespacedName types
 		return OK("Webhook is all set!")
 	}
 
+	if e.eventCompleter != nil {
+		if err := e.eventCompleter.CompleteEvent(ctx, workflow, event); err != nil {
+			logger.Error("Error completing event", zap.Error(err))
+			return InternalServerError(fmt.Sprintf("An internal error has occurred while reading the details of the event delivered to workflow %s", namespacedName))
+		}
+	}
+
+	// Requests made to Github on behalf of the workflow are authenticated
+	// with the credentials set by the object read from the cluster, rather
+	// than by the configuration possibly read from the repository.
+	if ctx, err = e.withCredentials(ctx, workflow); err != nil {
+		logger.Error("Error reading Github credentials", zap.Error(err))
+		return InternalServerError(fmt.Sprintf("An internal error has occurred while reading the Github credentials of workflow %s", namespacedName))
+	}
+
+	if event.IsRerunRequest() {
+		// Someone clicked "Re-run" on a check reported by workflows.
+		return e.rerunWorkflow(ctx, workflow, event)
+	}
+
+	if event.IsCheckEvent() {
+		// Checks are reported by workflows themselves, so their activity
+		// must never start new runs, which would report new checks and so
+		// on.
+		return Accepted(fmt.Sprintf("Github %s event with action %s doesn't trigger workflows: only requests to rerun checks are handled", event.Name, event.Action))
+	}
+
+	if event.Name == "issue_comment" {
+		// Comments only matter when they're slash commands, since they don't
+		// refer to any commit that workflows could run against.
+		command, ok := parseCommand(event)
+		if !ok {
+			return Accepted("Comment isn't a slash command written on a pull request")
+		}
+		return e.runCommand(ctx, workflow, event, command)
+	}
+
+	// Keep the object read from the cluster since Kubernetes Events must refer
+	// to it rather than to the configuration read from the repository.
+	clusterWorkflow := workflow
+
 	if w, err := e.getWorkflowFromRepository(ctx, workflow, event); err != nil {
 		logger.Errorw("Error getting workflow from repository", zap.Error(err))
 		return InternalServerError("An internal error has occurred while trying to read the workflow's configuration from the repository")
@@ -89,22 +179,90 @@ func (e *EventHandler) triggerWorkflow(ctx context.Context, namespacedName types
 		logger.Info("Defaulting to the workflow's configuration read from the cluster")
 	}
 
-	if ok, message := filters.CanTrigger(workflow, event); !ok {
-		logger.Info(message)
-		return Accepted(message)
+	report := filters.CanTrigger(ctx, workflow, event)
+	e.recordFilterReport(clusterWorkflow, event, report)
+	if !report.Accepted() {
+		logger.Infow(report.Message(), "filters", report.Results)
+		return Accepted(report.Message()).WithFilterReport(report)
 	}
 
-	defaults := config.Get(ctx).Defaults
-	pipelineRun := pipelinerun.NewBuilder(workflow, event).WithDefaults(defaults).Build()
-	createdPipelineRun, err := e.tektonClientSet.TektonV1beta1().PipelineRuns(workflow.GetNamespace()).Create(ctx, pipelineRun, metav1.CreateOptions{})
-
+	createdPipelineRun, err := e.createPipelineRun(ctx, workflow, event)
 	if err != nil {
 		logger.Error("Error creating PipelineRun object", zap.Error(err))
 		return InternalServerError(fmt.Sprintf("An internal error has occurred while creating the PipelineRun for workflow %s", namespacedName))
 	}
 
 	logger.Infow("PipelineRun has been successfully created", "tekton.dev/pipeline-run", createdPipelineRun.GetName())
-	return Created(fmt.Sprintf("PipelineRun %s has been successfully created", createdPipelineRun.GetName()))
+	return Created(fmt.Sprintf("PipelineRun %s has been successfully created", createdPipelineRun.GetName())).WithFilterReport(report)
+}
+
+// withCredentials returns a copy of the supplied context carrying the
+// credentials that requests made to Github on behalf of the workflow are
+// authenticated with.
+func (e *EventHandler) withCredentials(ctx context.Context, workflow *workflowsv1alpha1.Workflow) (context.Context, error) {
+	credentials, err := github.ResolveCredentials(ctx, e.kubeClientSet, workflow.GetNamespace(), workflow.GetCredentialsSecretName())
+	if err != nil {
+		return nil, err
+	}
+	return github.WithCredentials(ctx, credentials), nil
+}
+
+// createPipelineRun builds a PipelineRun from the supplied workflow and event
+// and creates it in the workflow's namespace.
+func (e *EventHandler) createPipelineRun(ctx context.Context, workflow *workflowsv1alpha1.Workflow, event *github.Event) (*pipelinev1beta1.PipelineRun, error) {
+	defaults := config.Get(ctx).Defaults
+	builder := pipelinerun.NewBuilder(workflow, event).
+		WithDefaults(defaults).
+		WithCredentials(github.GetCredentials(ctx))
+
+	if provider := e.providers[workflow.GetProvider()]; provider != nil {
+		builder = builder.WithGithubHost(provider.Host)
+	}
+
+	if e.logStore != nil {
+		token, err := logstore.NewToken()
+		if err != nil {
+			return nil, err
+		}
+		builder = builder.WithLogsToken(token)
+	}
+
+	var checkoutTokenSecret *corev1.Secret
+	if workflow.UsesInstallationTokens() && workflow.NeedsCheckoutCredentials() {
+		var err error
+		if checkoutTokenSecret, err = e.createCheckoutTokenSecret(ctx, workflow); err != nil {
+			return nil, err
+		}
+		builder = builder.WithCheckoutTokenSecret(checkoutTokenSecret.GetName())
+	}
+
+	pipelineRun := builder.Build()
+	createdPipelineRun, err := e.tektonClientSet.TektonV1beta1().PipelineRuns(workflow.GetNamespace()).Create(ctx, pipelineRun, metav1.CreateOptions{})
+	if err != nil {
+		e.handOverCheckoutTokenSecret(ctx, checkoutTokenSecret, nil)
+		return nil, err
+	}
+
+	e.handOverCheckoutTokenSecret(ctx, checkoutTokenSecret, createdPipelineRun)
+	return createdPipelineRun, nil
+}
+
+// recordFilterReport attaches a Kubernetes Event to the workflow describing the
+// outcome of every filter evaluated against the Github event in question.
+func (e *EventHandler) recordFilterReport(workflow *workflowsv1alpha1.Workflow, event *github.Event, report *filters.Report) {
+	reason := "EventAccepted"
+	verb := "accepted"
+	if !report.Accepted() {
+		reason = "EventIgnored"
+		verb = "ignored"
+	}
+
+	message := fmt.Sprintf("Github %s event (delivery %s) was %s:\n%s", event.Name, event.DeliveryID, verb, report)
+	if len(message) > maxEventMessageLength {
+		message = message[:maxEventMessageLength-3] + "..."
+	}
+
+	e.eventRecorder.Event(workflow, corev1.EventTypeNormal, reason, message)
 }
 
 func (e *EventHandler) getWorkflowFromRepository(ctx context.Context, workflow *workflowsv1alpha1.Workflow, event *github.Event) (*workflowsv1alpha1.Workflow, error) {
@@ -129,6 +287,12 @@ func (e *EventHandler) getWorkflowFromRepository(ctx context.Context, workflow *
 
 	logger.Infof("Successfully read workflow's configuration from %s", filePath)
 
+	// Whoever opens a pull request controls the configuration read from its
+	// head commit, so the attributes deciding who can trigger the workflow
+	// and with which credentials always come from the cluster, whatever the
+	// reader in question returned.
+	w.CopyImmutableAttributes(workflow)
+
 	// Apply the same default values as those set by the admission controller.
 	w.SetDefaults(ctx)
 
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"testing"
 
 	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
@@ -18,6 +19,7 @@ import (
 	"github.com/nubank/workflows/pkg/apis/config"
 	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
 	workflowsclientset "github.com/nubank/workflows/pkg/client/clientset/versioned/fake"
+	"github.com/nubank/workflows/pkg/filters"
 	"github.com/nubank/workflows/pkg/github"
 	githubmocks "github.com/nubank/workflows/pkg/github/mocks"
 	tektonclientset "github.com/tektoncd/pipeline/pkg/client/clientset/versioned/fake"
@@ -25,9 +27,29 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
 	"knative.dev/pkg/logging"
 )
 
+// deliverEvent reads the workflow in question and triggers it with the
+// supplied event, as if the event had been delivered to the workflow's hook.
+func deliverEvent(handler *EventHandler, ctx context.Context, namespacedName types.NamespacedName, event *github.Event) *Response {
+	workflow, response := handler.getWorkflow(ctx, namespacedName)
+	if response != nil {
+		return response
+	}
+	return handler.triggerWorkflow(ctx, workflow, event)
+}
+
+// newTestContext returns a context carrying a no-op logger and the supplied
+// default configuration.
+func newTestContext(defaults *config.Defaults) context.Context {
+	ctx := logging.WithLogger(context.Background(), zap.NewNop().Sugar())
+	return config.WithConfig(ctx, &config.Config{
+		Defaults: defaults,
+	})
+}
+
 func TestReturn404WhenTheWorkflowDoesntExist(t *testing.T) {
 	handler := &EventHandler{workflowsClientSet: workflowsclientset.NewSimpleClientset(&workflowsv1alpha1.Workflow{ObjectMeta: metav1.ObjectMeta{Name: "anything",
 		Namespace: "dev",
@@ -40,7 +62,7 @@ func TestReturn404WhenTheWorkflowDoesntExist(t *testing.T) {
 	namespacedName := types.NamespacedName{Namespace: "dev", Name: "test-1"}
 	event := &github.Event{}
 
-	response := handler.triggerWorkflow(ctx, namespacedName, event)
+	response := deliverEvent(handler, ctx, namespacedName, event)
 
 	wantStatus := 404
 	wantMessage := "Workflow dev/test-1 not found"
@@ -74,7 +96,7 @@ func TestReturns500WhenTheWorkflowCannotBeLoaded(t *testing.T) {
 	namespacedName := types.NamespacedName{Namespace: "dev", Name: "test-1"}
 	event := &github.Event{}
 
-	response := handler.triggerWorkflow(ctx, namespacedName, event)
+	response := deliverEvent(handler, ctx, namespacedName, event)
 
 	wantStatus := 500
 	wantMessage := "An internal error has occurred while reading workflow dev/test-1"
@@ -104,7 +126,7 @@ func TestReturns500WhenTheWebhookSecretCannotBeLoaded(t *testing.T) {
 	namespacedName := types.NamespacedName{Namespace: "dev", Name: "test-1"}
 	event := &github.Event{}
 
-	response := handler.triggerWorkflow(ctx, namespacedName, event)
+	response := deliverEvent(handler, ctx, namespacedName, event)
 
 	wantStatus := 500
 	wantMessage := "An internal error has occurred while verifying the request signature"
@@ -145,7 +167,7 @@ func TestReturns403WhenSignaturesDoNotMatch(t *testing.T) {
 		HMACSignature: []byte("sha256=d8a72707bd05f566becba60815c77f1e2adddddfceed668ca4844489d12ded07"),
 	}
 
-	response := handler.triggerWorkflow(ctx, namespacedName, event)
+	response := deliverEvent(handler, ctx, namespacedName, event)
 
 	wantStatus := 403
 	wantMessage := "Access denied: HMAC signatures don't match. The request signature we calculated does not match the provided signature."
@@ -196,7 +218,7 @@ func TestReturns200WhenGithubSendAPingEvent(t *testing.T) {
 		Name:          "ping",
 	}
 
-	response := handler.triggerWorkflow(ctx, namespacedName, event)
+	response := deliverEvent(handler, ctx, namespacedName, event)
 
 	wantStatus := 200
 	gotStatus := response.Status
@@ -206,7 +228,71 @@ func TestReturns200WhenGithubSendAPingEvent(t *testing.T) {
 	}
 }
 
+// eventCompleterFunc implements github.EventCompleter with a function.
+type eventCompleterFunc func(ctx context.Context, workflow *workflowsv1alpha1.Workflow, event *github.Event) error
+
+// CompleteEvent implements github.EventCompleter.
+func (f eventCompleterFunc) CompleteEvent(ctx context.Context, workflow *workflowsv1alpha1.Workflow, event *github.Event) error {
+	return f(ctx, workflow, event)
+}
+
+func TestReturns500WhenTheEventCannotBeCompleted(t *testing.T) {
+	handler := &EventHandler{workflowsClientSet: workflowsclientset.NewSimpleClientset(&workflowsv1alpha1.Workflow{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-1",
+			Namespace: "dev",
+		},
+		Spec: workflowsv1alpha1.WorkflowSpec{
+			Repository: &workflowsv1alpha1.Repository{
+				Owner:    "PROJ",
+				Name:     "my-repo",
+				Provider: workflowsv1alpha1.BitbucketServerProvider,
+			},
+			Events: []string{"push"},
+		},
+	}),
+		kubeClientSet: kubeclientset.NewSimpleClientset(&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "test-1-webhook-secret",
+			Namespace: "dev",
+		},
+			Data: map[string][]byte{
+				"secret-token": []byte("secret"),
+			},
+		}),
+		eventCompleter: eventCompleterFunc(func(ctx context.Context, workflow *workflowsv1alpha1.Workflow, event *github.Event) error {
+			return errors.New("Bitbucket answered GET changes with status 503")
+		}),
+	}
+
+	ctx := logging.WithLogger(context.Background(), zap.NewNop().Sugar())
+
+	namespacedName := types.NamespacedName{Namespace: "dev", Name: "test-1"}
+	event := &github.Event{
+		Body: []byte(`{
+    "ref": "refs/heads/dev"
+}`),
+		// This digest was calculated with the key secret.
+		HMACSignature: []byte("sha256=4ae9df17f8cc696722c87f771f0c60fa7b03d44488ae3e0f712f570c4e7a3888"),
+		Name:          "push",
+		Branch:        "dev",
+		Repository:    "PROJ/my-repo",
+	}
+
+	response := deliverEvent(handler, ctx, namespacedName, event)
+
+	wantStatus := 500
+	wantMessage := "An internal error has occurred while reading the details of the event delivered to workflow dev/test-1"
+
+	if response.Status != wantStatus {
+		t.Errorf("Want status %d, but got %d", wantStatus, response.Status)
+	}
+
+	if response.Payload.Message != wantMessage {
+		t.Errorf("Want message %s, but got %s", wantMessage, response.Payload.Message)
+	}
+}
+
 func TestReturns202WhenFiltersDoNotMatch(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
 	handler := &EventHandler{workflowsClientSet: workflowsclientset.NewSimpleClientset(&workflowsv1alpha1.Workflow{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "test-1",
@@ -228,6 +314,7 @@ func TestReturns202WhenFiltersDoNotMatch(t *testing.T) {
 				"secret-token": []byte("secret"),
 			},
 		}),
+		eventRecorder: recorder,
 	}
 
 	ctx := logging.WithLogger(context.Background(), zap.NewNop().Sugar())
@@ -239,12 +326,13 @@ func TestReturns202WhenFiltersDoNotMatch(t *testing.T) {
 }`),
 		// This digest was calculated with the key secret.
 		HMACSignature: []byte("sha256=4ae9df17f8cc696722c87f771f0c60fa7b03d44488ae3e0f712f570c4e7a3888"),
+		DeliveryID:    "123",
 		Name:          "push",
 		Branch:        "john-patch1",
 		Repository:    "my-org/my-repo",
 	}
 
-	response := handler.triggerWorkflow(ctx, namespacedName, event)
+	response := deliverEvent(handler, ctx, namespacedName, event)
 
 	wantStatus := 202
 	wantMessage := "Workflow was rejected because Github event doesn't satisfy rule: branch john-patch1 doesn't match filters [main]"
@@ -259,6 +347,37 @@ func TestReturns202WhenFiltersDoNotMatch(t *testing.T) {
 	if wantMessage != gotMessage {
 		t.Errorf("Want message %s, but got %s", wantMessage, gotMessage)
 	}
+
+	wantFilter := filters.Result{Filter: "branches",
+		Status:  filters.Failed,
+		Details: "branch john-patch1 doesn't match filters [main]",
+	}
+	if !containsFilterResult(response.Payload.Filters, wantFilter) {
+		t.Errorf("Want filter result %+v in the response payload, but got %+v", wantFilter, response.Payload.Filters)
+	}
+
+	wantEvent := "Normal EventIgnored Github push event (delivery 123) was ignored"
+	select {
+	case gotEvent := <-recorder.Events:
+		if !strings.HasPrefix(gotEvent, wantEvent) {
+			t.Errorf("Want event starting with %s, but got %s", wantEvent, gotEvent)
+		}
+
+		if !strings.Contains(gotEvent, "branches: failed (branch john-patch1 doesn't match filters [main])") {
+			t.Errorf("Want event describing the failed filter, but got %s", gotEvent)
+		}
+	default:
+		t.Error("Want a Kubernetes Event attached to the workflow, but got none")
+	}
+}
+
+func containsFilterResult(results []filters.Result, want filters.Result) bool {
+	for _, result := range results {
+		if result == want {
+			return true
+		}
+	}
+	return false
 }
 
 func TestReturns500WhenThePipelineRunCannotBeCreated(t *testing.T) {
@@ -288,13 +407,11 @@ func TestReturns500WhenThePipelineRunCannotBeCreated(t *testing.T) {
 				"secret-token": []byte("secret"),
 			},
 		}),
+		eventRecorder:   record.NewFakeRecorder(10),
 		tektonClientSet: tektonClient,
 	}
 
-	ctx := logging.WithLogger(context.Background(), zap.NewNop().Sugar())
-	ctx = config.WithConfig(ctx, &config.Config{
-		Defaults: &config.Defaults{},
-	})
+	ctx := newTestContext(&config.Defaults{})
 
 	namespacedName := types.NamespacedName{Namespace: "dev", Name: "test-1"}
 	event := &github.Event{Body: []byte(`{
@@ -307,7 +424,7 @@ func TestReturns500WhenThePipelineRunCannotBeCreated(t *testing.T) {
 		Repository:    "my-org/my-repo",
 	}
 
-	response := handler.triggerWorkflow(ctx, namespacedName, event)
+	response := deliverEvent(handler, ctx, namespacedName, event)
 
 	wantStatus := 500
 	wantMessage := "An internal error has occurred while creating the PipelineRun for workflow dev/test-1"
@@ -354,13 +471,11 @@ func TestReturns201WhenThePipelineRunIsCreated(t *testing.T) {
 				"secret-token": []byte("secret"),
 			},
 		}),
+		eventRecorder:   record.NewFakeRecorder(10),
 		tektonClientSet: tektonClient,
 	}
 
-	ctx := logging.WithLogger(context.Background(), zap.NewNop().Sugar())
-	ctx = config.WithConfig(ctx, &config.Config{
-		Defaults: &config.Defaults{},
-	})
+	ctx := newTestContext(&config.Defaults{})
 
 	namespacedName := types.NamespacedName{Namespace: "dev", Name: "test-1"}
 	event := &github.Event{
@@ -374,7 +489,7 @@ func TestReturns201WhenThePipelineRunIsCreated(t *testing.T) {
 		Repository:    "my-org/my-repo",
 	}
 
-	response := handler.triggerWorkflow(ctx, namespacedName, event)
+	response := deliverEvent(handler, ctx, namespacedName, event)
 
 	wantStatus := 201
 	wantMessage := "PipelineRun test-1-run-123 has been successfully created"
@@ -431,14 +546,12 @@ func TestPipelineRunCreationWithWorkflowReadFromRepo(t *testing.T) {
 				"secret-token": []byte("secret"),
 			},
 		}),
+		eventRecorder:   record.NewFakeRecorder(10),
 		tektonClientSet: tektonClient,
 		workflowReader:  workflowReader,
 	}
 
-	ctx := logging.WithLogger(context.Background(), zap.NewNop().Sugar())
-	ctx = config.WithConfig(ctx, &config.Config{
-		Defaults: &config.Defaults{WorkflowsDir: ".tektoncd/workflows"},
-	})
+	ctx := newTestContext(&config.Defaults{WorkflowsDir: ".tektoncd/workflows"})
 
 	namespacedName := types.NamespacedName{Namespace: "dev", Name: "test-1"}
 	event := &github.Event{
@@ -459,7 +572,7 @@ func TestPipelineRunCreationWithWorkflowReadFromRepo(t *testing.T) {
 		GetWorkflowContent(gomock.Eq(ctx), gomock.Eq(workflow), gomock.Eq(".tektoncd/workflows/test-1.yaml"), gomock.Eq("abc123")).
 		Return(workflowFromRepo, nil)
 
-	response := handler.triggerWorkflow(ctx, namespacedName, event)
+	response := deliverEvent(handler, ctx, namespacedName, event)
 
 	wantStatus := 201
 	gotStatus := response.Status
@@ -468,6 +581,83 @@ func TestPipelineRunCreationWithWorkflowReadFromRepo(t *testing.T) {
 	}
 }
 
+func TestActorsCannotBeDroppedByTheWorkflowReadFromRepo(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	workflowReader := githubmocks.NewMockWorkflowReader(mockCtrl)
+	tektonClient := tektonclientset.NewSimpleClientset()
+
+	workflow := &workflowsv1alpha1.Workflow{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-1",
+			Namespace: "dev",
+		},
+		Spec: workflowsv1alpha1.WorkflowSpec{
+			Repository: &workflowsv1alpha1.Repository{
+				Owner: "my-org",
+				Name:  "my-repo",
+			},
+			Events: []string{"pull_request"},
+			Actors: &workflowsv1alpha1.Actors{Allow: []string{"john-doe"}},
+		},
+	}
+
+	// Simulate an outside contributor dropping the actors restriction in
+	// their pull request.
+	workflowFromRepo := workflow.DeepCopy()
+	workflowFromRepo.Spec.Actors = nil
+
+	handler := &EventHandler{workflowsClientSet: workflowsclientset.NewSimpleClientset(workflow),
+		kubeClientSet: kubeclientset.NewSimpleClientset(&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "test-1-webhook-secret",
+			Namespace: "dev",
+		},
+			Data: map[string][]byte{
+				"secret-token": []byte("secret"),
+			},
+		}),
+		eventRecorder:   record.NewFakeRecorder(10),
+		tektonClientSet: tektonClient,
+		workflowReader:  workflowReader,
+	}
+
+	ctx := newTestContext(&config.Defaults{WorkflowsDir: ".tektoncd/workflows"})
+
+	namespacedName := types.NamespacedName{Namespace: "dev", Name: "test-1"}
+	event := &github.Event{
+		Body: []byte(`{
+    "ref": "refs/heads/dev"
+}`),
+		HeadCommitSHA: "abc123",
+		// This digest was calculated with the key secret.
+		HMACSignature: []byte("sha256=4ae9df17f8cc696722c87f771f0c60fa7b03d44488ae3e0f712f570c4e7a3888"),
+		Name:          "pull_request",
+		Branch:        "main",
+		Repository:    "my-org/my-repo",
+		Sender:        "mallory",
+	}
+
+	workflowReader.EXPECT().
+		GetWorkflowContent(gomock.Eq(ctx), gomock.Eq(workflow), gomock.Eq(".tektoncd/workflows/test-1.yaml"), gomock.Eq("abc123")).
+		Return(workflowFromRepo, nil)
+
+	response := deliverEvent(handler, ctx, namespacedName, event)
+
+	if response.Status != 202 {
+		t.Errorf("Want status 202, but got %d (%s)", response.Status, response.Payload.Message)
+	}
+
+	wantFilter := filters.Result{Filter: "actors",
+		Status:  filters.Failed,
+		Details: "actor mallory isn't allowed to trigger the workflow",
+	}
+	if !containsFilterResult(response.Payload.Filters, wantFilter) {
+		t.Errorf("Want filter result %+v in the response payload, but got %+v", wantFilter, response.Payload.Filters)
+	}
+
+	if pipelineRuns, _ := tektonClient.TektonV1beta1().PipelineRuns("dev").List(ctx, metav1.ListOptions{}); len(pipelineRuns.Items) != 0 {
+		t.Errorf("Want no PipelineRuns, but got %d", len(pipelineRuns.Items))
+	}
+}
+
 func TestReturns500WhenTheWorkflowConfigCannotBeRead(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	workflowReader := githubmocks.NewMockWorkflowReader(mockCtrl)
@@ -499,10 +689,7 @@ func TestReturns500WhenTheWorkflowConfigCannotBeRead(t *testing.T) {
 		workflowReader: workflowReader,
 	}
 
-	ctx := logging.WithLogger(context.Background(), zap.NewNop().Sugar())
-	ctx = config.WithConfig(ctx, &config.Config{
-		Defaults: &config.Defaults{WorkflowsDir: ".tektoncd/workflows"},
-	})
+	ctx := newTestContext(&config.Defaults{WorkflowsDir: ".tektoncd/workflows"})
 
 	namespacedName := types.NamespacedName{Namespace: "dev", Name: "test-1"}
 	event := &github.Event{
@@ -522,7 +709,7 @@ func TestReturns500WhenTheWorkflowConfigCannotBeRead(t *testing.T) {
 		GetWorkflowContent(gomock.Eq(ctx), gomock.Eq(workflow), gomock.Eq(".tektoncd/workflows/test-1.yaml"), gomock.Eq("abc123")).
 		Return(nil, fmt.Errorf("Boom!"))
 
-	response := handler.triggerWorkflow(ctx, namespacedName, event)
+	response := deliverEvent(handler, ctx, namespacedName, event)
 
 	wantStatus := 500
 	gotStatus := response.Status
@@ -0,0 +1,104 @@
+package hooklistener
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/nubank/workflows/pkg/logstore"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"knative.dev/pkg/logging"
+)
+
+// logsRequest identifies the archived logs a client asks for. Task and step
+// are empty when logs of every step are requested.
+type logsRequest struct {
+	namespace   string
+	pipelineRun string
+	task        string
+	step        string
+	token       string
+}
+
+// String returns the namespaced name of the PipelineRun whose logs are
+// requested.
+func (l *logsRequest) String() string {
+	return fmt.Sprintf("%s/%s", l.namespace, l.pipelineRun)
+}
+
+// selectLogs returns the archived steps matching the request or a Response
+// explaining why they can't be read.
+func (e *EventHandler) selectLogs(ctx context.Context, request *logsRequest) ([]logstore.IndexedStep, *Response) {
+	logger := logging.FromContext(ctx)
+
+	if e.logStore == nil {
+		return nil, NotFound("Logs of PipelineRuns aren't archived")
+	}
+
+	notFound := NotFound(fmt.Sprintf("PipelineRun %s has no archived logs", request))
+	for _, name := range []string{request.namespace, request.pipelineRun} {
+		if len(validation.IsDNS1123Subdomain(name)) != 0 {
+			return nil, notFound
+		}
+	}
+
+	index, err := logstore.GetIndex(ctx, e.logStore, request.namespace, request.pipelineRun)
+	if logstore.IsNotFound(err) {
+		return nil, notFound
+	} else if err != nil {
+		logger.Error("Error reading index of archived logs", zap.Error(err))
+		return nil, InternalServerError(fmt.Sprintf("An internal error has occurred while reading logs of PipelineRun %s", request))
+	}
+
+	if !index.Authorizes(request.token) {
+		return nil, Forbidden(fmt.Sprintf("Missing or invalid token to read logs of PipelineRun %s", request))
+	}
+
+	if request.task == "" {
+		return index.Steps, nil
+	}
+
+	for _, step := range index.Steps {
+		if step.Task == request.task && step.Step == request.step {
+			return []logstore.IndexedStep{step}, nil
+		}
+	}
+	return nil, NotFound(fmt.Sprintf("PipelineRun %s has no archived logs for step %s/%s", request, request.task, request.step))
+}
+
+// writeLogs writes logs of the supplied steps as plain text. Logs of each step
+// are preceded by a header naming it when several steps are written.
+func (e *EventHandler) writeLogs(ctx context.Context, writer http.ResponseWriter, request *logsRequest, steps []logstore.IndexedStep) {
+	logger := logging.FromContext(ctx)
+
+	writer.Header().Set("content-type", "text/plain; charset=utf-8")
+	writer.WriteHeader(http.StatusOK)
+
+	for _, step := range steps {
+		if len(steps) > 1 {
+			fmt.Fprintf(writer, "==> %s/%s <==\n", step.Task, step.Step)
+		}
+
+		if err := e.copyStepLogs(ctx, writer, request, step); err != nil {
+			// The status has already been sent, so the best we can do is
+			// to end the output with the error.
+			logger.Error("Error writing archived logs", zap.Error(err))
+			fmt.Fprintf(writer, "\n[An internal error has occurred while reading logs of step %s/%s]\n", step.Task, step.Step)
+			return
+		}
+	}
+}
+
+// copyStepLogs copies archived logs of a step into the supplied writer.
+func (e *EventHandler) copyStepLogs(ctx context.Context, writer io.Writer, request *logsRequest, step logstore.IndexedStep) error {
+	reader, err := e.logStore.Get(ctx, logstore.StepKey(request.namespace, request.pipelineRun, step.Task, step.Step))
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	_, err = io.Copy(writer, reader)
+	return err
+}
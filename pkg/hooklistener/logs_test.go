@@ -0,0 +1,148 @@
+package hooklistener
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/nubank/workflows/pkg/apis/config"
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	"github.com/nubank/workflows/pkg/github"
+	"github.com/nubank/workflows/pkg/logstore"
+	tektonclientset "github.com/tektoncd/pipeline/pkg/client/clientset/versioned/fake"
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/logging"
+)
+
+func newLogStore(t *testing.T) (logstore.Store, func()) {
+	dir, err := ioutil.TempDir("", "logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := logstore.NewFileStore(dir)
+	ctx := context.Background()
+
+	index := &logstore.Index{TokenHash: logstore.HashToken("s3cr3t"),
+		Steps: []logstore.IndexedStep{{Task: "lint", Step: "golint"}, {Task: "test", Step: "unit-tests"}},
+	}
+	if err := logstore.PutIndex(ctx, store, "dev", "ci-run-abc12", index); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, step := range index.Steps {
+		if err := store.Put(ctx, logstore.StepKey("dev", "ci-run-abc12", step.Task, step.Step), strings.NewReader("running "+step.Step+"\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return store, func() { os.RemoveAll(dir) }
+}
+
+func getLogs(handler *EventHandler, path string) *httptest.ResponseRecorder {
+	ctx := logging.WithLogger(context.Background(), zap.NewNop().Sugar())
+	request := httptest.NewRequest(http.MethodGet, path, nil).WithContext(ctx)
+	recorder := httptest.NewRecorder()
+	initRoutes(handler).ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestServeLogs(t *testing.T) {
+	store, cleanup := newLogStore(t)
+	defer cleanup()
+
+	handler := &EventHandler{logStore: store}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/api/v1alpha1/namespaces/dev/pipelineruns/ci-run-abc12/logs?token=s3cr3t", "==> lint/golint <==\nrunning golint\n==> test/unit-tests <==\nrunning unit-tests\n"},
+		{"/api/v1alpha1/namespaces/dev/pipelineruns/ci-run-abc12/logs/test/unit-tests?token=s3cr3t", "running unit-tests\n"},
+	}
+
+	for _, test := range tests {
+		recorder := getLogs(handler, test.path)
+
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%s: want status 200, got %d (%s)", test.path, recorder.Code, recorder.Body)
+		}
+
+		if got := recorder.Header().Get("content-type"); !strings.HasPrefix(got, "text/plain") {
+			t.Errorf("%s: want content type text/plain, got %s", test.path, got)
+		}
+
+		if got := recorder.Body.String(); test.want != got {
+			t.Errorf("%s: want logs %q, got %q", test.path, test.want, got)
+		}
+	}
+}
+
+func TestServeLogsFailures(t *testing.T) {
+	store, cleanup := newLogStore(t)
+	defer cleanup()
+
+	tests := []struct {
+		handler     *EventHandler
+		path        string
+		wantStatus  int
+		wantMessage string
+	}{
+		{&EventHandler{}, "/api/v1alpha1/namespaces/dev/pipelineruns/ci-run-abc12/logs?token=s3cr3t", 404, "Logs of PipelineRuns aren't archived"},
+		{&EventHandler{logStore: store}, "/api/v1alpha1/namespaces/dev/pipelineruns/ci-run-xyz98/logs?token=s3cr3t", 404, "PipelineRun dev/ci-run-xyz98 has no archived logs"},
+		{&EventHandler{logStore: store}, "/api/v1alpha1/namespaces/dev/pipelineruns/CI_Run/logs?token=s3cr3t", 404, "PipelineRun dev/CI_Run has no archived logs"},
+		{&EventHandler{logStore: store}, "/api/v1alpha1/namespaces/dev/pipelineruns/ci-run-abc12/logs", 403, "Missing or invalid token to read logs of PipelineRun dev/ci-run-abc12"},
+		{&EventHandler{logStore: store}, "/api/v1alpha1/namespaces/dev/pipelineruns/ci-run-abc12/logs?token=guess", 403, "Missing or invalid token to read logs of PipelineRun dev/ci-run-abc12"},
+		{&EventHandler{logStore: store}, "/api/v1alpha1/namespaces/dev/pipelineruns/ci-run-abc12/logs/test/e2e-tests?token=s3cr3t", 404, "PipelineRun dev/ci-run-abc12 has no archived logs for step test/e2e-tests"},
+	}
+
+	for _, test := range tests {
+		recorder := getLogs(test.handler, test.path)
+
+		if recorder.Code != test.wantStatus {
+			t.Errorf("%s: want status %d, got %d", test.path, test.wantStatus, recorder.Code)
+		}
+
+		if got := recorder.Body.String(); !strings.Contains(got, test.wantMessage) {
+			t.Errorf("%s: want message %q, got %s", test.path, test.wantMessage, got)
+		}
+	}
+}
+
+func TestCreatedPipelineRunsCarryLogsTokens(t *testing.T) {
+	store, cleanup := newLogStore(t)
+	defer cleanup()
+
+	workflow := &workflowsv1alpha1.Workflow{ObjectMeta: metav1.ObjectMeta{Name: "test-1",
+		Namespace: "dev",
+	},
+		Spec: workflowsv1alpha1.WorkflowSpec{
+			Repository: &workflowsv1alpha1.Repository{Owner: "my-org", Name: "my-repo"},
+			Webhook:    &workflowsv1alpha1.Webhook{URL: "https://hooks.example.com"},
+		},
+	}
+
+	ctx := newTestContext(&config.Defaults{})
+
+	handler := &EventHandler{tektonClientSet: tektonclientset.NewSimpleClientset(), logStore: store}
+
+	pipelineRun, err := handler.createPipelineRun(ctx, workflow, &github.Event{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	token := pipelineRun.Annotations["workflows.dev/logs-token"]
+	if len(token) != 40 {
+		t.Errorf("Want a random token of 40 hex characters, got %q", token)
+	}
+
+	wantURL := "https://hooks.example.com/api/v1alpha1/namespaces/$(pipelinerun.namespace)/pipelineruns/$(pipelinerun.name)/logs?token=" + token
+	if got := pipelineRun.Annotations["workflows.dev/logs-url"]; wantURL != got {
+		t.Errorf("Want logs URL %s, got %s", wantURL, got)
+	}
+}
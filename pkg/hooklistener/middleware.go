@@ -7,35 +7,35 @@ import (
 	"go.uber.org/zap"
 	"knative.dev/pkg/logging"
 
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
 	"github.com/nubank/workflows/pkg/github"
+	"github.com/nubank/workflows/pkg/scm"
 )
 
-// eventParser is a middleware function that attempts to parse request bodies
-// into Github events.
-// It passes a request infused with the parsed event to the next handler or
-// returns a bad request error if the request body can't be coerced into a valid
-// Github event.
-func eventParser(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
-		ctx := request.Context()
-		logger := logging.FromContext(ctx)
-		event, err := github.ParseWebhookEvent(request)
-		if err != nil {
-			logger.Errorw("Unable to process incoming request", zap.Error(err))
-			BadRequest(err.Error()).
-				write(ctx, writer)
-		} else {
-			// Populate the logging context with relevant
-			// information about the event being handled.
-			logger = logger.With(zap.String("github/delivery-id", event.DeliveryID),
-				zap.String("github/hook-id", event.HookID),
-				zap.String("github/event", event.Name),
-				zap.String("github/repository", event.Repository))
-			ctx = logging.WithLogger(ctx, logger)
-
-			next.ServeHTTP(writer, request.WithContext(github.WithEvent(ctx, event)))
-		}
-	})
+// parseEvent attempts to parse the body of the supplied request into a Github
+// event, as delivered by the hook of the provider in question. Events
+// delivered by other providers are normalized into their Github counterparts.
+// It returns a request infused with the parsed event or a bad request error if
+// the request body can't be coerced into a valid Github event.
+func parseEvent(request *http.Request, provider workflowsv1alpha1.Provider) (*http.Request, *Response) {
+	ctx := request.Context()
+	logger := logging.FromContext(ctx)
+
+	event, err := scm.ParseWebhookEvent(provider, request)
+	if err != nil {
+		logger.Errorw("Unable to process incoming request", zap.Error(err))
+		return nil, BadRequest(err.Error())
+	}
+
+	// Populate the logging context with relevant information about the event
+	// being handled.
+	logger = logger.With(zap.String("github/delivery-id", event.DeliveryID),
+		zap.String("github/hook-id", event.HookID),
+		zap.String("github/event", event.Name),
+		zap.String("github/repository", event.Repository))
+	ctx = logging.WithLogger(ctx, logger)
+
+	return request.WithContext(github.WithEvent(ctx, event)), nil
 }
 
 // traceableResponseWriter wraps a http.ResponseWriter by allowing us to capture
@@ -1,27 +1,23 @@
 package hooklistener
 
 import (
+	"context"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 
+	"github.com/nubank/workflows/pkg/apis/config"
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	workflowsclientset "github.com/nubank/workflows/pkg/client/clientset/versioned/fake"
 	"github.com/nubank/workflows/pkg/github"
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/logging"
 )
 
-// fakeHandler records the request passed to ServeHTTP method.
-type fakeHandler struct {
-	request *http.Request
-}
-
-func (f *fakeHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
-	f.request = request
-}
-
 func TestParsesGithubEvents(t *testing.T) {
-	handler := &fakeHandler{}
-
 	payload := `{
     "head_commit": {
 	"id": "32eec86"
@@ -40,19 +36,20 @@ func TestParsesGithubEvents(t *testing.T) {
 	request.Header.Set("X-GitHub-Event", "push")
 	request.Header.Set("X-GitHub-Hook-ID", "456")
 	request.Header.Set("X-Hub-Signature-256", "sha256=d8a72707")
+	request = request.WithContext(context.Background())
 
-	eventParser(handler).
-		ServeHTTP(httptest.NewRecorder(), request)
+	request, response := parseEvent(request, workflowsv1alpha1.GithubProvider)
+	if response != nil {
+		t.Fatalf("Unexpected response %+v", response)
+	}
 
-	event := github.GetEvent(handler.request.Context())
+	event := github.GetEvent(request.Context())
 	if event == nil {
 		t.Error("Request's context doesn't contain a valid Github Event object")
 	}
 }
 
 func TestReturnsABadRequestErrorWhenTheEventCannotBeParsed(t *testing.T) {
-	handler := &fakeHandler{}
-
 	payload := "{"
 
 	request := &http.Request{
@@ -63,16 +60,43 @@ func TestReturnsABadRequestErrorWhenTheEventCannotBeParsed(t *testing.T) {
 	request.Header.Set("X-GitHub-Event", "push")
 	request.Header.Set("X-GitHub-Hook-ID", "456")
 	request.Header.Set("X-Hub-Signature-256", "sha256=d8a72707")
+	request = request.WithContext(context.Background())
 
-	responswWriter := httptest.NewRecorder()
-
-	eventParser(handler).
-		ServeHTTP(responswWriter, request)
+	_, response := parseEvent(request, workflowsv1alpha1.GithubProvider)
 
 	wantStatus := 400
-	gotStatus := responswWriter.Result().StatusCode
-	if wantStatus != gotStatus {
-		t.Errorf("Want status %d, but got %d", wantStatus, gotStatus)
+	if response == nil || response.Status != wantStatus {
+		t.Errorf("Want status %d, but got %+v", wantStatus, response)
+	}
+}
+
+func TestRejectsEventsDeliveredByTheHooksOfAnotherProvider(t *testing.T) {
+	workflow := &workflowsv1alpha1.Workflow{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-1", Namespace: "dev"},
+		Spec: workflowsv1alpha1.WorkflowSpec{
+			Repository: &workflowsv1alpha1.Repository{Owner: "my-group", Name: "my-project", Provider: workflowsv1alpha1.GitlabProvider},
+		},
+	}
+
+	handler := &EventHandler{configStore: config.NewStore(zap.NewNop().Sugar()),
+		workflowsClientSet: workflowsclientset.NewSimpleClientset(workflow),
+	}
+
+	ctx := logging.WithLogger(context.Background(), zap.NewNop().Sugar())
+	request := httptest.NewRequest(http.MethodPost, "/api/v1alpha1/namespaces/dev/workflows/test-1/hooks", strings.NewReader(`{}`)).WithContext(ctx)
+	request.Header.Set("X-GitHub-Event", "push")
+	request.Header.Set("X-Hub-Signature-256", "sha256=d8a72707")
+
+	recorder := httptest.NewRecorder()
+	initRoutes(handler).ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Want status %d, got %d", http.StatusBadRequest, recorder.Code)
+	}
+
+	want := "Request appears to have been delivered by a Github hook but the workflow's repositories are hosted by gitlab"
+	if !strings.Contains(recorder.Body.String(), want) {
+		t.Errorf("Want message %q, got %s", want, recorder.Body.String())
 	}
 }
 
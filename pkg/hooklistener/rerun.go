@@ -0,0 +1,146 @@
+package hooklistener
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	"github.com/nubank/workflows/pkg/github"
+	"github.com/nubank/workflows/pkg/logstore"
+	"github.com/nubank/workflows/pkg/pipelinerun"
+	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"knative.dev/pkg/logging"
+)
+
+// rerunWorkflow handles check_run and check_suite events asking for checks to
+// be run again by creating a new PipelineRun from the one that originally
+// reported the check.
+// Filters aren't evaluated since the original run has already passed them.
+func (e *EventHandler) rerunWorkflow(ctx context.Context, workflow *workflowsv1alpha1.Workflow, event *github.Event) *Response {
+	logger := logging.FromContext(ctx)
+
+	original, err := e.findOriginalPipelineRun(ctx, workflow, event)
+	if err != nil {
+		logger.Error("Error looking up the PipelineRun to be rerun", zap.Error(err))
+		return InternalServerError(fmt.Sprintf("An internal error has occurred while looking up the PipelineRun to be rerun for workflow %s/%s", workflow.GetNamespace(), workflow.GetName()))
+	}
+
+	if original == nil {
+		return Accepted(fmt.Sprintf("Workflow %s/%s has no PipelineRun matching the rerun request", workflow.GetNamespace(), workflow.GetName()))
+	}
+
+	rerun := pipelinerun.NewRerun(original)
+
+	// Logs of the rerun must not be readable with the token of the original
+	// run.
+	if webhook := workflow.Spec.Webhook; e.logStore != nil && webhook != nil && webhook.URL != "" {
+		token, err := logstore.NewToken()
+		if err != nil {
+			logger.Error("Error issuing a logs token", zap.Error(err))
+			return InternalServerError(fmt.Sprintf("An internal error has occurred while issuing a logs token to rerun PipelineRun %s", original.GetName()))
+		}
+		pipelinerun.SetLogsToken(rerun, webhook.URL, token)
+	}
+
+	// The installation token of the original run has most likely expired.
+	var checkoutTokenSecret *corev1.Secret
+	if _, exists := original.GetAnnotations()[pipelinerun.CheckoutTokenSecretAnnotation]; exists {
+		if checkoutTokenSecret, err = e.createCheckoutTokenSecret(ctx, workflow); err != nil {
+			logger.Error("Error issuing a checkout token", zap.Error(err))
+			return InternalServerError(fmt.Sprintf("An internal error has occurred while issuing a checkout token to rerun PipelineRun %s", original.GetName()))
+		}
+		pipelinerun.SetCheckoutTokenSecret(rerun, checkoutTokenSecret.GetName())
+	}
+
+	createdPipelineRun, err := e.tektonClientSet.TektonV1beta1().PipelineRuns(original.GetNamespace()).Create(ctx, rerun, metav1.CreateOptions{})
+	if err != nil {
+		e.handOverCheckoutTokenSecret(ctx, checkoutTokenSecret, nil)
+		logger.Error("Error creating PipelineRun object", zap.Error(err))
+		return InternalServerError(fmt.Sprintf("An internal error has occurred while rerunning PipelineRun %s", original.GetName()))
+	}
+	e.handOverCheckoutTokenSecret(ctx, checkoutTokenSecret, createdPipelineRun)
+
+	message := fmt.Sprintf("PipelineRun %s has been successfully created to rerun %s", createdPipelineRun.GetName(), original.GetName())
+	e.eventRecorder.Event(workflow, corev1.EventTypeNormal, "RerunRequested", fmt.Sprintf("Github %s event (delivery %s) requested a rerun: %s", event.Name, event.DeliveryID, message))
+
+	logger.Infow("PipelineRun has been successfully created", "tekton.dev/pipeline-run", createdPipelineRun.GetName(), "workflows.dev/rerun-of", original.GetName())
+	return Created(message)
+}
+
+// findOriginalPipelineRun returns the PipelineRun of the supplied workflow the
+// rerun request refers to or nil if there is none.
+// Check runs carry the PipelineRun's namespaced name as their external ID,
+// whereas check suites are matched against the latest PipelineRun created for
+// their head commit.
+func (e *EventHandler) findOriginalPipelineRun(ctx context.Context, workflow *workflowsv1alpha1.Workflow, event *github.Event) (*pipelinev1beta1.PipelineRun, error) {
+	if event.Name == "check_run" {
+		if event.CheckRunName != "" && event.CheckRunName != workflow.GetName() {
+			// The check run was reported by another workflow.
+			return nil, nil
+		}
+
+		if event.CheckRunExternalID != "" {
+			return e.getPipelineRun(ctx, workflow, event.CheckRunExternalID)
+		}
+	}
+
+	return e.getLatestPipelineRun(ctx, workflow, event)
+}
+
+// getPipelineRun returns the PipelineRun identified by the supplied namespaced
+// name or nil if it doesn't exist or wasn't created by the workflow.
+func (e *EventHandler) getPipelineRun(ctx context.Context, workflow *workflowsv1alpha1.Workflow, namespacedName string) (*pipelinev1beta1.PipelineRun, error) {
+	parts := strings.SplitN(namespacedName, "/", 2)
+	if len(parts) != 2 || parts[0] != workflow.GetNamespace() {
+		return nil, nil
+	}
+
+	pipelineRun, err := e.tektonClientSet.TektonV1beta1().PipelineRuns(parts[0]).Get(ctx, parts[1], metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if pipelineRun.GetLabels()[pipelinerun.WorkflowLabel] != workflow.GetName() {
+		return nil, nil
+	}
+	return pipelineRun, nil
+}
+
+// getLatestPipelineRun returns the most recent PipelineRun created by the
+// workflow for the event's repository and head commit or nil if there is
+// none.
+func (e *EventHandler) getLatestPipelineRun(ctx context.Context, workflow *workflowsv1alpha1.Workflow, event *github.Event) (*pipelinev1beta1.PipelineRun, error) {
+	if event.HeadCommitSHA == "" {
+		return nil, nil
+	}
+
+	selector := labels.SelectorFromSet(labels.Set{pipelinerun.WorkflowLabel: workflow.GetName()})
+	pipelineRuns, err := e.tektonClientSet.TektonV1beta1().PipelineRuns(workflow.GetNamespace()).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, err
+	}
+
+	var latest *pipelinev1beta1.PipelineRun
+	for i := range pipelineRuns.Items {
+		pipelineRun := &pipelineRuns.Items[i]
+		annotations := pipelineRun.GetAnnotations()
+		if annotations[pipelinerun.HeadCommitAnnotation] != event.HeadCommitSHA ||
+			annotations[pipelinerun.RepositoryAnnotation] != event.Repository {
+			continue
+		}
+
+		if latest == nil || latest.CreationTimestamp.Before(&pipelineRun.CreationTimestamp) {
+			latest = pipelineRun
+		}
+	}
+
+	return latest, nil
+}
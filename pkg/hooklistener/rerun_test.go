@@ -0,0 +1,272 @@
+package hooklistener
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nubank/workflows/pkg/apis/config"
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	workflowsclientset "github.com/nubank/workflows/pkg/client/clientset/versioned/fake"
+	"github.com/nubank/workflows/pkg/github"
+	"github.com/nubank/workflows/pkg/logstore"
+	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	tektonclientset "github.com/tektoncd/pipeline/pkg/client/clientset/versioned/fake"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	kubeclientset "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+)
+
+func newPipelineRun(name, headCommit string, createdAt time.Time) *pipelinev1beta1.PipelineRun {
+	return &pipelinev1beta1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{Name: name,
+			GenerateName:      "test-1-run-",
+			Namespace:         "dev",
+			CreationTimestamp: metav1.NewTime(createdAt),
+			Labels: map[string]string{
+				"workflows.dev/workflow": "test-1",
+			},
+			Annotations: map[string]string{
+				"workflows.dev/repository":           "my-org/my-repo",
+				"workflows.dev/head-commit":          headCommit,
+				"workflows.dev/check-run-id":         "42",
+				"workflows.dev/check-run-conclusion": "failure",
+			},
+		},
+	}
+}
+
+func newRerunEventHandler(pipelineRuns ...*pipelinev1beta1.PipelineRun) *EventHandler {
+	objects := make([]runtime.Object, 0)
+	for _, pipelineRun := range pipelineRuns {
+		objects = append(objects, pipelineRun)
+	}
+
+	return &EventHandler{workflowsClientSet: workflowsclientset.NewSimpleClientset(&workflowsv1alpha1.Workflow{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-1",
+			Namespace: "dev",
+		},
+		Spec: workflowsv1alpha1.WorkflowSpec{
+			Repository: &workflowsv1alpha1.Repository{
+				Owner: "my-org",
+				Name:  "my-repo",
+			},
+			Events: []string{"push"},
+		},
+	}),
+		kubeClientSet: kubeclientset.NewSimpleClientset(&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "test-1-webhook-secret",
+			Namespace: "dev",
+		},
+			Data: map[string][]byte{
+				"secret-token": []byte("secret"),
+			},
+		}),
+		eventRecorder:   record.NewFakeRecorder(10),
+		tektonClientSet: tektonclientset.NewSimpleClientset(objects...),
+	}
+}
+
+func newRerunEvent(name string) *github.Event {
+	return &github.Event{
+		Action: "rerequested",
+		Body: []byte(`{
+    "ref": "refs/heads/dev"
+}`),
+		// This digest was calculated with the key secret.
+		HMACSignature: []byte("sha256=4ae9df17f8cc696722c87f771f0c60fa7b03d44488ae3e0f712f570c4e7a3888"),
+		HeadCommitSHA: "833568e",
+		Name:          name,
+		Repository:    "my-org/my-repo",
+	}
+}
+
+// getReruns returns PipelineRuns created to rerun the supplied one.
+func getReruns(t *testing.T, handler *EventHandler, original string) []pipelinev1beta1.PipelineRun {
+	pipelineRuns, err := handler.tektonClientSet.TektonV1beta1().PipelineRuns("dev").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reruns := make([]pipelinev1beta1.PipelineRun, 0)
+	for _, pipelineRun := range pipelineRuns.Items {
+		if pipelineRun.Annotations["workflows.dev/rerun-of"] == original {
+			reruns = append(reruns, pipelineRun)
+		}
+	}
+	return reruns
+}
+
+func TestRerunCheckRun(t *testing.T) {
+	now := time.Now()
+	handler := newRerunEventHandler(newPipelineRun("test-1-run-abc12", "833568e", now))
+
+	event := newRerunEvent("check_run")
+	event.CheckRunName = "test-1"
+	event.CheckRunExternalID = "dev/test-1-run-abc12"
+
+	response := deliverEvent(handler, newTestContext(&config.Defaults{}), types.NamespacedName{Namespace: "dev", Name: "test-1"}, event)
+
+	if response.Status != 201 {
+		t.Fatalf("Want status 201, but got %d: %s", response.Status, response.Payload.Message)
+	}
+
+	reruns := getReruns(t, handler, "test-1-run-abc12")
+	if len(reruns) != 1 {
+		t.Fatalf("Want 1 rerun, got %d", len(reruns))
+	}
+
+	if _, ok := reruns[0].Annotations["workflows.dev/check-run-id"]; ok {
+		t.Error("Want the check run ID to be dropped from the rerun")
+	}
+
+	if got := reruns[0].Annotations["workflows.dev/head-commit"]; got != "833568e" {
+		t.Errorf("Want head commit 833568e, got %s", got)
+	}
+}
+
+func TestRerunCheckRunIgnoresChecksOfOtherWorkflows(t *testing.T) {
+	handler := newRerunEventHandler(newPipelineRun("test-1-run-abc12", "833568e", time.Now()))
+
+	event := newRerunEvent("check_run")
+	event.CheckRunName = "test-2"
+	event.CheckRunExternalID = "dev/test-2-run-def34"
+
+	response := deliverEvent(handler, newTestContext(&config.Defaults{}), types.NamespacedName{Namespace: "dev", Name: "test-1"}, event)
+
+	wantStatus := 202
+	wantMessage := "Workflow dev/test-1 has no PipelineRun matching the rerun request"
+
+	if wantStatus != response.Status {
+		t.Errorf("Want status %d, but got %d", wantStatus, response.Status)
+	}
+
+	if wantMessage != response.Payload.Message {
+		t.Errorf("Want message %s, but got %s", wantMessage, response.Payload.Message)
+	}
+}
+
+func TestRerunCheckSuiteUsesTheLatestPipelineRun(t *testing.T) {
+	now := time.Now()
+	handler := newRerunEventHandler(newPipelineRun("test-1-run-abc12", "833568e", now.Add(-time.Hour)),
+		newPipelineRun("test-1-run-def34", "833568e", now),
+		newPipelineRun("test-1-run-ghi56", "a1b2c3d", now.Add(time.Hour)))
+
+	response := deliverEvent(handler, newTestContext(&config.Defaults{}), types.NamespacedName{Namespace: "dev", Name: "test-1"}, newRerunEvent("check_suite"))
+
+	if response.Status != 201 {
+		t.Fatalf("Want status 201, but got %d: %s", response.Status, response.Payload.Message)
+	}
+
+	if reruns := getReruns(t, handler, "test-1-run-def34"); len(reruns) != 1 {
+		t.Errorf("Want 1 rerun of test-1-run-def34, got %d", len(reruns))
+	}
+}
+
+func TestCheckEventsOtherThanRerunRequestsDoNotTriggerWorkflows(t *testing.T) {
+	handler := newRerunEventHandler(newPipelineRun("test-1-run-abc12", "833568e", time.Now()))
+
+	// Users might subscribe to check_run events hoping to handle reruns.
+	workflow, err := handler.workflowsClientSet.WorkflowsV1alpha1().Workflows("dev").Get(context.Background(), "test-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	workflow.Spec.Events = []string{"check_run"}
+	if _, err := handler.workflowsClientSet.WorkflowsV1alpha1().Workflows("dev").Update(context.Background(), workflow, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	event := newRerunEvent("check_run")
+	event.Action = "completed"
+
+	response := deliverEvent(handler, newTestContext(&config.Defaults{}), types.NamespacedName{Namespace: "dev", Name: "test-1"}, event)
+
+	wantMessage := "Github check_run event with action completed doesn't trigger workflows: only requests to rerun checks are handled"
+	if response.Status != 202 || response.Payload.Message != wantMessage {
+		t.Errorf("Want status 202 and message %s, but got %d and %s", wantMessage, response.Status, response.Payload.Message)
+	}
+
+	pipelineRuns, err := handler.tektonClientSet.TektonV1beta1().PipelineRuns("dev").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pipelineRuns.Items) != 1 {
+		t.Errorf("Want no new PipelineRuns, got %d PipelineRuns", len(pipelineRuns.Items))
+	}
+}
+
+func TestRerunIsIssuedNewCheckoutAndLogsTokens(t *testing.T) {
+	original := newPipelineRun("test-1-run-abc12", "833568e", time.Now())
+	original.Annotations["workflows.dev/checkout-token-secret"] = "test-1-checkout-token-xyz98"
+	original.Annotations["workflows.dev/logs-token"] = "s3cr3t"
+	original.Annotations["workflows.dev/logs-url"] = "https://workflows.example.com/api/v1alpha1/namespaces/$(pipelinerun.namespace)/pipelineruns/$(pipelinerun.name)/logs?token=s3cr3t"
+	original.Spec.PipelineSpec = &pipelinev1beta1.PipelineSpec{
+		Tasks: []pipelinev1beta1.PipelineTask{{Name: "test",
+			TaskSpec: &pipelinev1beta1.EmbeddedTask{TaskSpec: pipelinev1beta1.TaskSpec{
+				Volumes: []corev1.Volume{{Name: "checkout-token",
+					VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "test-1-checkout-token-xyz98"}},
+				}},
+			}},
+		}},
+	}
+
+	handler := newRerunEventHandler(original)
+	handler.checkoutTokens = &fakeCheckoutTokenIssuer{token: "ghs_abc"}
+	handler.logStore = logstore.NewFileStore(t.TempDir())
+	// The fake clientset doesn't generate names.
+	handler.kubeClientSet.(*kubeclientset.Clientset).PrependReactor("create", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		secret := action.(k8stesting.CreateAction).GetObject().(*corev1.Secret)
+		if secret.Name == "" {
+			secret.Name = secret.GenerateName + "abc12"
+		}
+		return false, nil, nil
+	})
+
+	workflow, err := handler.workflowsClientSet.WorkflowsV1alpha1().Workflows("dev").Get(context.Background(), "test-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	workflow.Spec.Webhook = &workflowsv1alpha1.Webhook{URL: "https://workflows.example.com"}
+	if _, err := handler.workflowsClientSet.WorkflowsV1alpha1().Workflows("dev").Update(context.Background(), workflow, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	event := newRerunEvent("check_run")
+	event.CheckRunName = "test-1"
+	event.CheckRunExternalID = "dev/test-1-run-abc12"
+
+	response := deliverEvent(handler, newTestContext(&config.Defaults{}), types.NamespacedName{Namespace: "dev", Name: "test-1"}, event)
+
+	if response.Status != 201 {
+		t.Fatalf("Want status 201, but got %d: %s", response.Status, response.Payload.Message)
+	}
+
+	reruns := getReruns(t, handler, "test-1-run-abc12")
+	if len(reruns) != 1 {
+		t.Fatalf("Want 1 rerun, got %d", len(reruns))
+	}
+	rerun := reruns[0]
+
+	if got := rerun.Annotations["workflows.dev/checkout-token-secret"]; got != "test-1-checkout-token-abc12" {
+		t.Errorf("Want the rerun to refer to Secret test-1-checkout-token-abc12, got %s", got)
+	}
+
+	if got := rerun.Spec.PipelineSpec.Tasks[0].TaskSpec.Volumes[0].Secret.SecretName; got != "test-1-checkout-token-abc12" {
+		t.Errorf("Want the rerun to check out with Secret test-1-checkout-token-abc12, got %s", got)
+	}
+
+	token := rerun.Annotations["workflows.dev/logs-token"]
+	if token == "" || token == "s3cr3t" {
+		t.Errorf("Want the rerun to be issued a new logs token, got %q", token)
+	}
+
+	if logsURL := rerun.Annotations["workflows.dev/logs-url"]; !strings.HasSuffix(logsURL, "?token="+token) {
+		t.Errorf("Want the logs URL to carry the new logs token, got %s", logsURL)
+	}
+}
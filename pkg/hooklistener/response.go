@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/nubank/workflows/pkg/filters"
 	"go.uber.org/zap"
 	"knative.dev/pkg/logging"
 )
@@ -17,7 +18,8 @@ type Response struct {
 
 // ResponsePayload is the payload returned in the HTTP response.
 type ResponsePayload struct {
-	Message string `json:"message"`
+	Message string           `json:"message"`
+	Filters []filters.Result `json:"filters,omitempty"`
 }
 
 // write ends the request by writing the response to the server's output stream.
@@ -41,6 +43,13 @@ func newResponse(status int, message string) *Response {
 	}
 }
 
+// WithFilterReport returns the same Response with the outcome of every filter
+// evaluated against the event added to its payload.
+func (r *Response) WithFilterReport(report *filters.Report) *Response {
+	r.Payload.Filters = report.Results
+	return r
+}
+
 // Accepted returns a HTTP 202 response with the supplied message.
 func Accepted(message string) *Response {
 	return newResponse(http.StatusAccepted, message)
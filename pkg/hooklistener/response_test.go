@@ -5,6 +5,8 @@ import (
 	"io/ioutil"
 	"net/http/httptest"
 	"testing"
+
+	"github.com/nubank/workflows/pkg/filters"
 )
 
 func TestTestVariousResponseConstructors(t *testing.T) {
@@ -65,3 +67,25 @@ func TestWritingResponse(t *testing.T) {
 		t.Errorf("Want payload %s, but got %s", wantPayload, gotPayload)
 	}
 }
+
+func TestWritingResponseWithFilterReport(t *testing.T) {
+	fakeResponseWriter := httptest.NewRecorder()
+	report := &filters.Report{Results: []filters.Result{
+		{Filter: "events", Status: filters.Passed, Details: "filter succeeded"},
+		{Filter: "branches", Status: filters.Failed, Details: "branch dev doesn't match filters [main]"},
+	}}
+	Response := Accepted("Lorem Ipsum").WithFilterReport(report)
+	Response.write(context.Background(), fakeResponseWriter)
+	result := fakeResponseWriter.Result()
+
+	body, err := ioutil.ReadAll(result.Body)
+	if err != nil {
+		t.Fatalf("Error reading response body: %v", err)
+	}
+
+	wantPayload := `{"message":"Lorem Ipsum","filters":[{"filter":"events","status":"passed","details":"filter succeeded"},{"filter":"branches","status":"failed","details":"branch dev doesn't match filters [main]"}]}` + "\n"
+	gotPayload := string(body)
+	if wantPayload != gotPayload {
+		t.Errorf("Want payload %s, but got %s", wantPayload, gotPayload)
+	}
+}
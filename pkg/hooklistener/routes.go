@@ -6,11 +6,13 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 
 	"github.com/gorilla/mux"
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
 	"github.com/nubank/workflows/pkg/github"
 )
 
 // repositoryEventHandler returns a handler func that calls the provided
-// EventHandler object.
+// EventHandler object. Events are parsed as delivered by the provider hosting
+// the repositories of the workflow in question.
 func repositoryEventHandler(handler *EventHandler) http.Handler {
 	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
 		ctx := handler.configStore.ToContext(request.Context())
@@ -19,12 +21,66 @@ func repositoryEventHandler(handler *EventHandler) http.Handler {
 			Namespace: vars["namespace"],
 			Name:      vars["name"],
 		}
-		event := github.GetEvent(ctx)
-		Response := handler.triggerWorkflow(ctx, namespacedName, event)
+
+		workflow, Response := handler.getWorkflow(ctx, namespacedName)
+		if Response != nil {
+			Response.write(ctx, writer)
+			return
+		}
+
+		request, Response = parseEvent(request.WithContext(ctx), workflow.GetProvider())
+		if Response != nil {
+			Response.write(ctx, writer)
+			return
+		}
+
+		ctx = request.Context()
+		Response = handler.triggerWorkflow(ctx, workflow, github.GetEvent(ctx))
+		Response.write(ctx, writer)
+	})
+}
+
+// appEventHandler returns a handler func that calls the provided EventHandler
+// object for events delivered by the Github App's webhook.
+func appEventHandler(handler *EventHandler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		ctx := handler.configStore.ToContext(request.Context())
+
+		request, Response := parseEvent(request.WithContext(ctx), workflowsv1alpha1.GithubProvider)
+		if Response != nil {
+			Response.write(ctx, writer)
+			return
+		}
+
+		ctx = request.Context()
+		Response = handler.handleAppEvent(ctx, github.GetEvent(ctx))
 		Response.write(ctx, writer)
 	})
 }
 
+// logsHandler returns a handler func that serves logs archived for
+// PipelineRuns.
+func logsHandler(handler *EventHandler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		ctx := request.Context()
+		vars := mux.Vars(request)
+		logsRequest := &logsRequest{
+			namespace:   vars["namespace"],
+			pipelineRun: vars["name"],
+			task:        vars["task"],
+			step:        vars["step"],
+			token:       request.URL.Query().Get("token"),
+		}
+
+		steps, response := handler.selectLogs(ctx, logsRequest)
+		if response != nil {
+			response.write(ctx, writer)
+			return
+		}
+		handler.writeLogs(ctx, writer, logsRequest, steps)
+	})
+}
+
 // initRoutes configures routes exposed by the hook listener API.
 func initRoutes(handler *EventHandler) *mux.Router {
 	router := mux.NewRouter().StrictSlash(true)
@@ -37,8 +93,18 @@ func initRoutes(handler *EventHandler) *mux.Router {
 
 	api := router.PathPrefix("/api/v1alpha1").Subrouter()
 	api.Use(tracer)
-	api.Use(eventParser)
 	api.Methods("POST").Path("/namespaces/{namespace}/workflows/{name}/hooks").Handler(repositoryEventHandler(handler))
 
+	// Github only delivers requests to rerun checks to the App owning them,
+	// so the App's webhook must point here.
+	api.Methods("POST").Path("/hooks").Handler(appEventHandler(handler))
+
+	// Logs are read by people following links, so requests don't carry
+	// Github events.
+	logs := router.PathPrefix("/api/v1alpha1/namespaces/{namespace}/pipelineruns/{name}/logs").Subrouter()
+	logs.Use(tracer)
+	logs.Methods("GET").Path("").Handler(logsHandler(handler))
+	logs.Methods("GET").Path("/{task}/{step}").Handler(logsHandler(handler))
+
 	return router
 }
@@ -11,11 +11,17 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/nubank/workflows/pkg/apis/config"
 	workflowsclientset "github.com/nubank/workflows/pkg/client/clientset/versioned"
+	workflowsscheme "github.com/nubank/workflows/pkg/client/clientset/versioned/scheme"
 	"github.com/nubank/workflows/pkg/github"
+	"github.com/nubank/workflows/pkg/logstore"
+	"github.com/nubank/workflows/pkg/scm"
 	tektonclientset "github.com/tektoncd/pipeline/pkg/client/clientset/versioned"
 	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 	"knative.dev/pkg/configmap"
 	"knative.dev/pkg/configmap/informer"
 	"knative.dev/pkg/logging"
@@ -23,7 +29,20 @@ import (
 
 // New creates a HTTP server to handle events delivered by Github Webhooks.
 func New(ctx context.Context) *http.Server {
-	handler := newEventHandlerOrDie(ctx)
+	githubHost, err := github.NewHostFromEnv()
+	if err != nil {
+		panic(fmt.Errorf("Error configuring the Github host: %w", err))
+	}
+
+	githubClients := github.NewClientFactoryOrDie(ctx, githubHost)
+	ctx = github.WithMembershipChecker(ctx, github.NewMembershipChecker(githubClients))
+
+	providers, err := scm.NewProvidersFromEnv(githubHost, githubClients)
+	if err != nil {
+		panic(fmt.Errorf("Error configuring the providers hosting repositories: %w", err))
+	}
+
+	handler := newEventHandlerOrDie(ctx, providers, githubClients)
 	routes := initRoutes(handler)
 	return newServer(ctx, routes)
 }
@@ -45,10 +64,10 @@ func newServer(ctx context.Context, routes *mux.Router) *http.Server {
 }
 
 // newEventHandlerOrDie returns a new EventHandler by initializing all required
-// dependencies (Kubernetes client sets, config map watchers and Github
-// clients).
+// dependencies (Kubernetes client sets, config map watchers and clients of the
+// providers hosting repositories).
 // It panics if any of those dependencies fails to be created.
-func newEventHandlerOrDie(ctx context.Context) *EventHandler {
+func newEventHandlerOrDie(ctx context.Context, providers scm.Providers, githubClients github.ClientFactory) *EventHandler {
 	config, err := rest.InClusterConfig()
 	if err != nil {
 		panic(fmt.Errorf("Error creating Kubernetes config: %w", err))
@@ -58,16 +77,45 @@ func newEventHandlerOrDie(ctx context.Context) *EventHandler {
 	configStore := newConfigStoreOrDie(ctx, kubeClient)
 	tektonClient := tektonclientset.NewForConfigOrDie(config)
 	workflowsClient := workflowsclientset.NewForConfigOrDie(config)
-	workflowReader := github.NewWorkflowReader(github.NewClientOrDie())
+	workflowReader := scm.NewWorkflowReader(providers)
+
+	logStore, err := logstore.NewStoreFromEnv()
+	if err != nil {
+		panic(fmt.Errorf("Error configuring the logs store: %w", err))
+	}
+
 	return &EventHandler{
 		configStore:        configStore,
 		kubeClientSet:      kubeClient,
 		tektonClientSet:    tektonClient,
 		workflowsClientSet: workflowsClient,
+		providers:          providers,
 		workflowReader:     workflowReader,
+		eventCompleter:     scm.NewEventCompleter(providers),
+		pullRequestReader:  github.NewPullRequestReader(githubClients),
+		permissionChecker:  github.NewPermissionChecker(githubClients),
+		checkoutTokens:     github.NewCheckoutTokenIssuer(githubClients),
+		logStore:           logStore,
+		eventRecorder:      newEventRecorder(ctx, kubeClient),
+
+		appWebhookSecretPath: defaultAppWebhookSecretPath,
 	}
 }
 
+// newEventRecorder returns a new EventRecorder that attaches Kubernetes Events
+// to workflows.
+func newEventRecorder(ctx context.Context, kubeClient kubernetes.Interface) record.EventRecorder {
+	logger := logging.FromContext(ctx)
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartLogging(logger.Named("event-broadcaster").Infof)
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: kubeClient.CoreV1().Events("")})
+	go func() {
+		<-ctx.Done()
+		broadcaster.Shutdown()
+	}()
+	return broadcaster.NewRecorder(workflowsscheme.Scheme, corev1.EventSource{Component: "hook-listener"})
+}
+
 // newConfigStoreOrDie creates a Store filled with the initial state of
 // configurations.
 func newConfigStoreOrDie(ctx context.Context, kubeClient kubernetes.Interface) *config.Store {
@@ -0,0 +1,96 @@
+package logstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
+	"knative.dev/pkg/logging"
+)
+
+// Collector archives logs of steps of finished PipelineRuns.
+type Collector struct {
+	streamLogs   streamLogsFunc
+	store        Store
+	maxStepBytes int64
+}
+
+// streamLogsFunc streams logs of a container of a pod.
+type streamLogsFunc func(ctx context.Context, namespace, pod string, options *corev1.PodLogOptions) (io.ReadCloser, error)
+
+// NewCollector returns a Collector that streams logs of pods into the
+// supplied store, keeping at most maxStepBytes per step.
+func NewCollector(kubeClientSet kubernetes.Interface, store Store, maxStepBytes int64) *Collector {
+	streamLogs := func(ctx context.Context, namespace, pod string, options *corev1.PodLogOptions) (io.ReadCloser, error) {
+		return kubeClientSet.CoreV1().Pods(namespace).GetLogs(pod, options).Stream(ctx)
+	}
+
+	return &Collector{streamLogs: streamLogs,
+		store:        store,
+		maxStepBytes: maxStepBytes,
+	}
+}
+
+// Collect archives logs of every step of the supplied PipelineRun, followed by
+// an index that grants access to them to holders of the supplied token.
+// Steps whose pods are already gone are archived with a notice instead.
+func (c *Collector) Collect(ctx context.Context, pipelineRun *pipelinev1beta1.PipelineRun, token string) error {
+	index := &Index{TokenHash: HashToken(token), Steps: make([]IndexedStep, 0)}
+
+	for _, taskRun := range sortTaskRuns(pipelineRun) {
+		for _, step := range taskRun.Status.Steps {
+			if err := c.collectStep(ctx, pipelineRun, taskRun, step); err != nil {
+				return err
+			}
+			index.Steps = append(index.Steps, IndexedStep{Task: taskRun.PipelineTaskName, Step: step.Name})
+		}
+	}
+
+	return PutIndex(ctx, c.store, pipelineRun.GetNamespace(), pipelineRun.GetName(), index)
+}
+
+// collectStep streams logs of a single step into the store.
+func (c *Collector) collectStep(ctx context.Context, pipelineRun *pipelinev1beta1.PipelineRun, taskRun *pipelinev1beta1.PipelineRunTaskRunStatus, step pipelinev1beta1.StepState) error {
+	logger := logging.FromContext(ctx)
+	key := StepKey(pipelineRun.GetNamespace(), pipelineRun.GetName(), taskRun.PipelineTaskName, step.Name)
+
+	// Read one byte beyond the limit, so truncated logs can be told apart.
+	limitBytes := c.maxStepBytes + 1
+	stream, err := c.streamLogs(ctx, pipelineRun.GetNamespace(), taskRun.Status.PodName, &corev1.PodLogOptions{Container: step.ContainerName, LimitBytes: &limitBytes})
+
+	if apierrors.IsNotFound(err) {
+		logger.Infof("Logs of step %s/%s are no longer available", taskRun.PipelineTaskName, step.Name)
+		return c.store.Put(ctx, key, strings.NewReader(fmt.Sprintf("[Logs are no longer available: pod %s was deleted before they could be archived]\n", taskRun.Status.PodName)))
+	} else if err != nil {
+		return fmt.Errorf("Error streaming logs of step %s/%s: %w", taskRun.PipelineTaskName, step.Name, err)
+	}
+	defer stream.Close()
+
+	return c.store.Put(ctx, key, LimitReader(stream, c.maxStepBytes))
+}
+
+// sortTaskRuns returns statuses of TaskRuns of the PipelineRun in the order
+// they started.
+func sortTaskRuns(pipelineRun *pipelinev1beta1.PipelineRun) []*pipelinev1beta1.PipelineRunTaskRunStatus {
+	taskRuns := make([]*pipelinev1beta1.PipelineRunTaskRunStatus, 0, len(pipelineRun.Status.TaskRuns))
+	for _, taskRun := range pipelineRun.Status.TaskRuns {
+		if taskRun.Status != nil && taskRun.Status.PodName != "" {
+			taskRuns = append(taskRuns, taskRun)
+		}
+	}
+
+	sort.Slice(taskRuns, func(i, j int) bool {
+		a, b := taskRuns[i].Status.StartTime, taskRuns[j].Status.StartTime
+		if a != nil && b != nil && !a.Equal(b) {
+			return a.Before(b)
+		}
+		return taskRuns[i].PipelineTaskName < taskRuns[j].PipelineTaskName
+	})
+	return taskRuns
+}
@@ -0,0 +1,107 @@
+package logstore
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func newPipelineRun() *pipelinev1beta1.PipelineRun {
+	start := time.Date(2021, 1, 20, 10, 0, 0, 0, time.UTC)
+	taskRunStatus := func(podName string, startTime time.Time, steps ...string) *pipelinev1beta1.TaskRunStatus {
+		status := &pipelinev1beta1.TaskRunStatus{}
+		status.PodName = podName
+		status.StartTime = &metav1.Time{Time: startTime}
+		for _, step := range steps {
+			status.Steps = append(status.Steps, pipelinev1beta1.StepState{Name: step, ContainerName: "step-" + step})
+		}
+		return status
+	}
+
+	pipelineRun := &pipelinev1beta1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "ci-run-abc12", Namespace: "dev"}}
+	pipelineRun.Status.TaskRuns = map[string]*pipelinev1beta1.PipelineRunTaskRunStatus{
+		"ci-run-abc12-test-xyz": {PipelineTaskName: "test", Status: taskRunStatus("test-pod", start.Add(time.Minute), "unit-tests")},
+		"ci-run-abc12-lint-xyz": {PipelineTaskName: "lint", Status: taskRunStatus("lint-pod", start, "checkout", "golint")},
+		"ci-run-abc12-skip-xyz": {PipelineTaskName: "skipped"},
+	}
+	return pipelineRun
+}
+
+func TestCollect(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := NewFileStore(dir)
+	collector := NewCollector(nil, store, 10)
+	collector.streamLogs = func(ctx context.Context, namespace, pod string, options *corev1.PodLogOptions) (io.ReadCloser, error) {
+		if *options.LimitBytes != 11 {
+			t.Errorf("Want logs to be limited to 11 bytes, got %d", *options.LimitBytes)
+		}
+
+		switch pod {
+		case "lint-pod":
+			return ioutil.NopCloser(strings.NewReader("logs of " + options.Container)), nil
+		default:
+			return nil, apierrors.NewNotFound(schema.GroupResource{Resource: "pods"}, pod)
+		}
+	}
+
+	ctx := context.Background()
+	if err := collector.Collect(ctx, newPipelineRun(), "s3cr3t"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	index, err := GetIndex(ctx, store, "dev", "ci-run-abc12")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantSteps := []IndexedStep{{"lint", "checkout"}, {"lint", "golint"}, {"test", "unit-tests"}}
+	if len(index.Steps) != len(wantSteps) {
+		t.Fatalf("Want steps %v, got %v", wantSteps, index.Steps)
+	}
+	for i := range wantSteps {
+		if wantSteps[i] != index.Steps[i] {
+			t.Errorf("Want steps %v, got %v", wantSteps, index.Steps)
+		}
+	}
+
+	if !index.Authorizes("s3cr3t") {
+		t.Error("Want the index to authorize the token")
+	}
+
+	wantLogs := map[string]string{
+		StepKey("dev", "ci-run-abc12", "lint", "checkout"):   "logs of st\n[Logs truncated: the step wrote more than 10 bytes]\n",
+		StepKey("dev", "ci-run-abc12", "test", "unit-tests"): "[Logs are no longer available: pod test-pod was deleted before they could be archived]\n",
+	}
+
+	for key, want := range wantLogs {
+		reader, err := store.Get(ctx, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := ioutil.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if want != string(got) {
+			t.Errorf("Want logs %q at %s, got %q", want, key, got)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package logstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// FileStore is a Store that keeps logs as files under a directory, typically a
+// PersistentVolumeClaim mounted by both the controller and the hook listener.
+type FileStore struct {
+	root string
+}
+
+// NewFileStore returns a FileStore rooted at the supplied directory.
+func NewFileStore(root string) *FileStore {
+	return &FileStore{root: root}
+}
+
+// Put implements Store.Put. Content is written to a temporary file first, so
+// readers never see partially written logs.
+func (f *FileStore) Put(ctx context.Context, key string, content io.Reader) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	path := filepath.Join(f.root, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("Error storing logs at %s: %w", key, err)
+	}
+
+	file, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return fmt.Errorf("Error storing logs at %s: %w", key, err)
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := io.Copy(file, content); err != nil {
+		file.Close()
+		return fmt.Errorf("Error storing logs at %s: %w", key, err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("Error storing logs at %s: %w", key, err)
+	}
+
+	if err := os.Rename(file.Name(), path); err != nil {
+		return fmt.Errorf("Error storing logs at %s: %w", key, err)
+	}
+	return nil
+}
+
+// Get implements Store.Get.
+func (f *FileStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(filepath.Join(f.root, filepath.FromSlash(key)))
+	if os.IsNotExist(err) {
+		return nil, &NotFoundError{Key: key}
+	} else if err != nil {
+		return nil, fmt.Errorf("Error reading logs at %s: %w", key, err)
+	}
+	return file, nil
+}
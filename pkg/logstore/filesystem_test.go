@@ -0,0 +1,59 @@
+package logstore
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := NewFileStore(dir)
+	ctx := context.Background()
+	key := StepKey("dev", "ci-run-abc12", "test", "unit-tests")
+
+	if _, err := store.Get(ctx, key); !IsNotFound(err) {
+		t.Errorf("Want a NotFoundError, got %v", err)
+	}
+
+	if err := store.Put(ctx, key, strings.NewReader("ok\n")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	reader, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer reader.Close()
+
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(content) != "ok\n" {
+		t.Errorf("Want content %q, got %q", "ok\n", content)
+	}
+}
+
+func TestFileStoreRejectsInvalidKeys(t *testing.T) {
+	store := NewFileStore("/var/run/workflows/logs")
+	ctx := context.Background()
+
+	for _, key := range []string{"../etc/passwd", "dev/../../etc", "/etc/passwd", "dev//ci", "dev/..", "Dev/ci", ""} {
+		if err := store.Put(ctx, key, strings.NewReader("")); err == nil {
+			t.Errorf("Key %q: want an error on Put, got nil", key)
+		}
+
+		if _, err := store.Get(ctx, key); err == nil || IsNotFound(err) {
+			t.Errorf("Key %q: want an error on Get, got %v", key, err)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package logstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// Index lists the steps whose logs were archived for a PipelineRun.
+type Index struct {
+
+	// TokenHash is the SHA256 digest (hex encoded) of the token that grants
+	// access to the logs.
+	TokenHash string `json:"tokenHash"`
+
+	// Steps lists archived steps in the order they ran.
+	Steps []IndexedStep `json:"steps"`
+}
+
+// IndexedStep identifies a step whose logs were archived.
+type IndexedStep struct {
+	Task string `json:"task"`
+	Step string `json:"step"`
+}
+
+// NewToken returns a random token to grant access to the logs of a
+// PipelineRun.
+func NewToken() (string, error) {
+	token := make([]byte, 20)
+	if _, err := rand.Read(token); err != nil {
+		return "", fmt.Errorf("Error generating token: %w", err)
+	}
+	return hex.EncodeToString(token), nil
+}
+
+// HashToken returns the digest of the supplied token as stored in indexes.
+func HashToken(token string) string {
+	return hashHex([]byte(token))
+}
+
+// Authorizes returns true if the supplied token grants access to the logs.
+func (i *Index) Authorizes(token string) bool {
+	if i.TokenHash == "" || token == "" {
+		return false
+	}
+
+	digest := sha256.Sum256([]byte(token))
+	want, err := hex.DecodeString(i.TokenHash)
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(digest[:], want) == 1
+}
+
+// PutIndex stores the index of logs archived for a PipelineRun.
+func PutIndex(ctx context.Context, store Store, namespace, pipelineRun string, index *Index) error {
+	content, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	return store.Put(ctx, IndexKey(namespace, pipelineRun), bytes.NewReader(content))
+}
+
+// GetIndex returns the index of logs archived for a PipelineRun or a
+// NotFoundError if its logs haven't been archived.
+func GetIndex(ctx context.Context, store Store, namespace, pipelineRun string) (*Index, error) {
+	reader, err := store.Get(ctx, IndexKey(namespace, pipelineRun))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	index := &Index{}
+	if err := json.Unmarshal(content, index); err != nil {
+		return nil, fmt.Errorf("Invalid index of logs of PipelineRun %s/%s: %w", namespace, pipelineRun, err)
+	}
+	return index, nil
+}
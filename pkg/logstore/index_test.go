@@ -0,0 +1,60 @@
+package logstore
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestIndexAuthorizesToken(t *testing.T) {
+	token, err := NewToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index := &Index{TokenHash: HashToken(token)}
+
+	if !index.Authorizes(token) {
+		t.Error("Want the token to be authorized")
+	}
+
+	for _, other := range []string{"", "s3cr3t", index.TokenHash} {
+		if index.Authorizes(other) {
+			t.Errorf("Want token %q to be rejected", other)
+		}
+	}
+
+	if (&Index{}).Authorizes("") {
+		t.Error("Want indexes without a token hash to reject every token")
+	}
+}
+
+func TestPutAndGetIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := NewFileStore(dir)
+	ctx := context.Background()
+
+	if _, err := GetIndex(ctx, store, "dev", "ci-run-abc12"); !IsNotFound(err) {
+		t.Errorf("Want a NotFoundError, got %v", err)
+	}
+
+	index := &Index{TokenHash: HashToken("s3cr3t"), Steps: []IndexedStep{{Task: "test", Step: "unit-tests"}}}
+	if err := PutIndex(ctx, store, "dev", "ci-run-abc12", index); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	got, err := GetIndex(ctx, store, "dev", "ci-run-abc12")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if got.TokenHash != index.TokenHash || len(got.Steps) != 1 || got.Steps[0] != index.Steps[0] {
+		t.Errorf("Want index %+v, got %+v", index, got)
+	}
+}
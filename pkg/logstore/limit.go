@@ -0,0 +1,53 @@
+package logstore
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// LimitReader returns a Reader that reads at most limit bytes from the
+// supplied reader. If there's more to read, a notice explaining that logs
+// were truncated is appended instead.
+func LimitReader(reader io.Reader, limit int64) io.Reader {
+	return &limitedReader{reader: reader, limit: limit, remaining: limit}
+}
+
+// limitedReader implements the Reader returned by LimitReader.
+type limitedReader struct {
+	reader    io.Reader
+	limit     int64
+	remaining int64
+
+	// tail is read once the limit is reached.
+	tail io.Reader
+}
+
+// Read implements io.Reader.
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.tail == nil && l.remaining <= 0 {
+		l.tail = l.truncationNotice()
+	}
+
+	if l.tail != nil {
+		return l.tail.Read(p)
+	}
+
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+
+	n, err := l.reader.Read(p)
+	l.remaining -= int64(n)
+	return n, err
+}
+
+// truncationNotice returns a Reader with a notice about truncated logs if the
+// underlying reader has more to read or an empty Reader otherwise.
+func (l *limitedReader) truncationNotice() io.Reader {
+	var next [1]byte
+	if n, _ := io.ReadFull(l.reader, next[:]); n == 0 {
+		return strings.NewReader("")
+	}
+	return strings.NewReader(fmt.Sprintf("\n[Logs truncated: the step wrote more than %d bytes]\n", l.limit))
+}
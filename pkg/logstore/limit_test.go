@@ -0,0 +1,30 @@
+package logstore
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestLimitReader(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", ""},
+		{"abc", "abc"},
+		{"abcde", "abcde"},
+		{"abcdef", "abcde\n[Logs truncated: the step wrote more than 5 bytes]\n"},
+	}
+
+	for _, test := range tests {
+		got, err := ioutil.ReadAll(LimitReader(strings.NewReader(test.in), 5))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if test.want != string(got) {
+			t.Errorf("Input %q: want %q, got %q", test.in, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package logstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// Default timeout for calling S3-compatible services.
+	s3Timeout = 30 * time.Second
+
+	// Payloads aren't hashed when signing requests, which S3 allows over
+	// HTTPS.
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// S3Store is a Store that keeps logs as objects of a bucket on any service
+// compatible with the Amazon S3 API (e.g. AWS, MinIO or Ceph). Buckets are
+// addressed path-style and requests are signed with AWS Signature Version 4.
+type S3Store struct {
+	endpoint        *url.URL
+	bucket          string
+	region          string
+	accessKeyID     string
+	secretAccessKey string
+	client          *http.Client
+	now             func() time.Time
+}
+
+// NewS3Store returns a S3Store that keeps logs in the supplied bucket.
+func NewS3Store(endpoint, bucket, region, accessKeyID, secretAccessKey string) (*S3Store, error) {
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil || endpointURL.Scheme == "" || endpointURL.Host == "" {
+		return nil, fmt.Errorf("Invalid S3 endpoint %q", endpoint)
+	}
+
+	return &S3Store{endpoint: endpointURL,
+		bucket:          bucket,
+		region:          region,
+		accessKeyID:     accessKeyID,
+		secretAccessKey: secretAccessKey,
+		client:          &http.Client{Timeout: s3Timeout},
+		now:             time.Now,
+	}, nil
+}
+
+// Put implements Store.Put. Content is buffered in memory since S3 requires
+// the length of objects upfront; the size of step logs is capped anyway.
+func (s *S3Store) Put(ctx context.Context, key string, content io.Reader) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	body, err := ioutil.ReadAll(content)
+	if err != nil {
+		return fmt.Errorf("Error storing logs at %s: %w", key, err)
+	}
+
+	request, err := s.newRequest(ctx, http.MethodPut, key, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.ContentLength = int64(len(body))
+	request.Header.Set("Content-Type", "text/plain; charset=utf-8")
+
+	response, err := s.do(request)
+	if err != nil {
+		return fmt.Errorf("Error storing logs at %s: %w", key, err)
+	}
+	response.Body.Close()
+	return nil
+}
+
+// Get implements Store.Get.
+func (s *S3Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	request, err := s.newRequest(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.do(request)
+	if err != nil {
+		if response != nil && response.StatusCode == http.StatusNotFound {
+			return nil, &NotFoundError{Key: key}
+		}
+		return nil, fmt.Errorf("Error reading logs at %s: %w", key, err)
+	}
+	return response.Body, nil
+}
+
+// newRequest returns a signed request for the object stored under the
+// supplied key.
+func (s *S3Store) newRequest(ctx context.Context, method, key string, body io.Reader) (*http.Request, error) {
+	objectURL := *s.endpoint
+	objectURL.Path = strings.TrimSuffix(objectURL.Path, "/") + "/" + s.bucket + "/" + key
+
+	request, err := http.NewRequestWithContext(ctx, method, objectURL.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	s.sign(request)
+	return request, nil
+}
+
+// do sends the supplied request and returns an error along with the response
+// if the service didn't respond with a successful status.
+func (s *S3Store) do(request *http.Request) (*http.Response, error) {
+	response, err := s.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		response.Body.Close()
+		return response, fmt.Errorf("%s %s: unexpected status %s", request.Method, request.URL.Path, response.Status)
+	}
+	return response, nil
+}
+
+// sign adds an Authorization header computed with AWS Signature Version 4.
+// For further details about the algorithm, please see:
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html.
+func (s *S3Store) sign(request *http.Request) {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	request.Header.Set("X-Amz-Date", amzDate)
+	request.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+
+	const signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		request.URL.EscapedPath(),
+		request.URL.RawQuery,
+		"host:" + request.URL.Host,
+		"x-amz-content-sha256:" + unsignedPayload,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		unsignedPayload,
+	}, "\n")
+
+	scope := strings.Join([]string{date, s.region, "s3", "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hashHex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretAccessKey), date)
+	for _, part := range []string{s.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	request.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", s.accessKeyID, scope, signedHeaders, signature))
+}
+
+// hashHex returns the hex encoded SHA256 digest of the supplied data.
+func hashHex(data []byte) string {
+	digest := sha256.Sum256(data)
+	return hex.EncodeToString(digest[:])
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of the supplied data using the key in
+// question.
+func hmacSHA256(key []byte, data string) []byte {
+	hash := hmac.New(sha256.New, key)
+	hash.Write([]byte(data))
+	return hash.Sum(nil)
+}
@@ -0,0 +1,166 @@
+package logstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Name of the environment variable that selects the store where logs
+	// are archived (filesystem or s3). Logs aren't archived when it's unset.
+	storeEnv = "LOGS_STORE"
+
+	// Name of the environment variable that holds the directory where the
+	// filesystem store keeps logs (typically a mounted PVC).
+	dirEnv = "LOGS_DIR"
+
+	// Names of environment variables that configure the s3 store.
+	s3EndpointEnv        = "LOGS_S3_ENDPOINT"
+	s3BucketEnv          = "LOGS_S3_BUCKET"
+	s3RegionEnv          = "LOGS_S3_REGION"
+	s3AccessKeyIDEnv     = "LOGS_S3_ACCESS_KEY_ID"
+	s3SecretAccessKeyEnv = "LOGS_S3_SECRET_ACCESS_KEY"
+
+	// Name of the environment variable that holds the maximum number of
+	// bytes archived per step.
+	maxStepBytesEnv = "LOGS_MAX_STEP_BYTES"
+
+	// DefaultMaxStepBytes is the maximum number of bytes archived per step
+	// when LOGS_MAX_STEP_BYTES isn't set.
+	DefaultMaxStepBytes = 4 * 1024 * 1024
+)
+
+// keyPattern matches keys made of Kubernetes names separated by slashes.
+var keyPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9.-]*[a-z0-9])?(/[a-z0-9]([a-z0-9.-]*[a-z0-9])?)*$`)
+
+// Store keeps logs of finished PipelineRuns after their pods are gone.
+type Store interface {
+
+	// Put stores the supplied content under the key in question, replacing
+	// any content previously stored.
+	Put(ctx context.Context, key string, content io.Reader) error
+
+	// Get returns the content stored under the key in question or a
+	// NotFoundError if there is none. Callers must close it.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+// NotFoundError is returned when there is no content stored under a key.
+type NotFoundError struct {
+	Key string
+}
+
+// Error satisfies the error interface.
+func (n *NotFoundError) Error() string {
+	return fmt.Sprintf("No logs found at %s", n.Key)
+}
+
+// IsNotFound returns true if the supplied error is of the type NotFoundError
+// otherwise it returns false.
+func IsNotFound(err error) bool {
+	_, ok := err.(*NotFoundError)
+	return ok
+}
+
+// IndexKey returns the key of the index of logs archived for a PipelineRun.
+func IndexKey(namespace, pipelineRun string) string {
+	return strings.Join([]string{namespace, pipelineRun, "index"}, "/")
+}
+
+// StepKey returns the key of logs archived for a step of a PipelineRun.
+func StepKey(namespace, pipelineRun, task, step string) string {
+	return strings.Join([]string{namespace, pipelineRun, "steps", task, step}, "/")
+}
+
+// validateKey returns an error if the key could escape the store's root
+// (e.g. by containing ..).
+func validateKey(key string) error {
+	if !keyPattern.MatchString(key) {
+		return fmt.Errorf("Invalid key %q", key)
+	}
+
+	for _, segment := range strings.Split(key, "/") {
+		if strings.Trim(segment, ".") == "" || strings.Contains(segment, "..") {
+			return fmt.Errorf("Invalid key %q", key)
+		}
+	}
+	return nil
+}
+
+// NewStoreFromEnv returns the Store configured through environment variables
+// or nil if archiving logs isn't enabled.
+func NewStoreFromEnv() (Store, error) {
+	switch kind := os.Getenv(storeEnv); kind {
+	case "":
+		return nil, nil
+
+	case "filesystem":
+		dir, err := lookupEnv(dirEnv)
+		if err != nil {
+			return nil, err
+		}
+		return NewFileStore(dir), nil
+
+	case "s3":
+		values := make(map[string]string)
+		for _, name := range []string{s3EndpointEnv, s3BucketEnv, s3RegionEnv, s3AccessKeyIDEnv, s3SecretAccessKeyEnv} {
+			value, err := lookupEnv(name)
+			if err != nil {
+				return nil, err
+			}
+			values[name] = value
+		}
+		return NewS3Store(values[s3EndpointEnv], values[s3BucketEnv], values[s3RegionEnv], values[s3AccessKeyIDEnv], values[s3SecretAccessKeyEnv])
+
+	default:
+		return nil, fmt.Errorf("Invalid value %s for environment variable %s: must be either filesystem or s3", kind, storeEnv)
+	}
+}
+
+// MaxStepBytesFromEnv returns the maximum number of bytes archived per step.
+func MaxStepBytesFromEnv() (int64, error) {
+	value, ok := os.LookupEnv(maxStepBytesEnv)
+	if !ok {
+		return DefaultMaxStepBytes, nil
+	}
+
+	maxStepBytes, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || maxStepBytes <= 0 {
+		return 0, fmt.Errorf("Invalid value %s for environment variable %s: must be a positive number of bytes", value, maxStepBytesEnv)
+	}
+	return maxStepBytes, nil
+}
+
+// lookupEnv returns the value of the environment variable in question or an
+// error if it isn't set.
+func lookupEnv(name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		return "", fmt.Errorf("Missing environment variable %s", name)
+	}
+	return value, nil
+}
+
+// storeKey is used to store Store objects into context.Context.
+type storeKey struct {
+}
+
+// WithStore returns a copy of the supplied context with the Store object
+// added.
+func WithStore(ctx context.Context, store Store) context.Context {
+	return context.WithValue(ctx, storeKey{}, store)
+}
+
+// GetStore returns the Store instance stored in the supplied context or nil if
+// the context doesn't contain one.
+func GetStore(ctx context.Context) Store {
+	if store, ok := ctx.Value(storeKey{}).(Store); ok {
+		return store
+	}
+	return nil
+}
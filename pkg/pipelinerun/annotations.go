@@ -0,0 +1,34 @@
+package pipelinerun
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	tektonclientset "github.com/tektoncd/pipeline/pkg/client/clientset/versioned"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Annotate adds the supplied annotations to an existing PipelineRun by
+// patching it.
+func Annotate(ctx context.Context, tektonClientSet tektonclientset.Interface, pipelineRun *pipelinev1beta1.PipelineRun, annotations map[string]string) error {
+	if len(annotations) == 0 {
+		return nil
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": annotations,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := tektonClientSet.TektonV1beta1().PipelineRuns(pipelineRun.GetNamespace()).Patch(ctx, pipelineRun.GetName(), types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("Error annotating PipelineRun %s/%s: %w", pipelineRun.GetNamespace(), pipelineRun.GetName(), err)
+	}
+	return nil
+}
@@ -1,7 +1,10 @@
 package pipelinerun
 
 import (
+	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/nubank/workflows/pkg/apis/config"
 	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
@@ -12,13 +15,84 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// Labels and annotations that workflows attach to PipelineRuns.
+const (
+	// reservedAnnotationPrefix prefixes the annotations that only workflows
+	// themselves can attach to PipelineRuns.
+	reservedAnnotationPrefix = "workflows.dev/"
+
+	// WorkflowLabel identifies the workflow that originated the PipelineRun.
+	WorkflowLabel = "workflows.dev/workflow"
+
+	// ProviderLabel identifies the provider hosting the repository of the
+	// workflow that originated the PipelineRun. It's only set for
+	// repositories that aren't hosted by Github.
+	ProviderLabel = "workflows.dev/provider"
+
+	// RepositoryAnnotation holds the full name (owner/name) of the
+	// repository that originated the PipelineRun.
+	RepositoryAnnotation = "workflows.dev/repository"
+
+	// HeadCommitAnnotation holds the SHA of the commit the PipelineRun runs
+	// against.
+	HeadCommitAnnotation = "workflows.dev/head-commit"
+
+	// EventAnnotation holds the name of the Github event that triggered the
+	// PipelineRun.
+	EventAnnotation = "workflows.dev/event"
+
+	// DeliveryIDAnnotation holds the delivery ID of the Github event that
+	// triggered the PipelineRun.
+	DeliveryIDAnnotation = "workflows.dev/delivery-id"
+
+	// CheckRunIDAnnotation holds the ID of the Github check run that reports
+	// the PipelineRun's progress.
+	CheckRunIDAnnotation = "workflows.dev/check-run-id"
+
+	// CheckRunConclusionAnnotation holds the conclusion reported to Github
+	// once the PipelineRun is done.
+	CheckRunConclusionAnnotation = "workflows.dev/check-run-conclusion"
+
+	// CommitStatusContextAnnotation holds the context of Github commit
+	// statuses reporting the PipelineRun's progress. Statuses are only
+	// reported for PipelineRuns carrying this annotation.
+	CommitStatusContextAnnotation = "workflows.dev/commit-status-context"
+
+	// CommitStatusTargetURLAnnotation holds the URL commit statuses link to.
+	CommitStatusTargetURLAnnotation = "workflows.dev/commit-status-target-url"
+
+	// CommitStatusStateAnnotation holds the last commit status state reported
+	// to Github.
+	CommitStatusStateAnnotation = "workflows.dev/commit-status-state"
+
+	// PullRequestAnnotation holds the number of the pull request that
+	// triggered the PipelineRun.
+	PullRequestAnnotation = "workflows.dev/pull-request"
+
+	// PullRequestCommentAnnotation indicates that a summary of the
+	// PipelineRun must be posted on the pull request once it's done.
+	PullRequestCommentAnnotation = "workflows.dev/pull-request-comment"
+
+	// PullRequestCommentLogsURLAnnotation holds the URL of the PipelineRun's
+	// logs linked from the pull request comment.
+	PullRequestCommentLogsURLAnnotation = "workflows.dev/pull-request-comment-logs-url"
+
+	// PullRequestCommentPostedAnnotation indicates that the summary has
+	// already been posted.
+	PullRequestCommentPostedAnnotation = "workflows.dev/pull-request-comment-posted"
+)
+
 // Builder builds Tekton PipelineRun objects.
 type Builder struct {
-	builtInSteps map[workflowsv1alpha1.BuiltInStep]BuiltInStep
-	defaults     *config.Defaults
-	event        *github.Event
-	replacements *variables.Replacements
-	workflow     *workflowsv1alpha1.Workflow
+	builtInSteps        map[workflowsv1alpha1.BuiltInStep]BuiltInStep
+	checkoutTokenSecret string
+	credentials         *github.Credentials
+	defaults            *config.Defaults
+	event               *github.Event
+	githubHost          *github.Host
+	logsToken           string
+	replacements        *variables.Replacements
+	workflow            *workflowsv1alpha1.Workflow
 }
 
 // BuiltInStep is the interface that built-in, convenience steps provided by
@@ -51,6 +125,7 @@ func NewBuilder(workflow *workflowsv1alpha1.Workflow, event *github.Event) *Buil
 		builtInSteps: make(map[workflowsv1alpha1.BuiltInStep]BuiltInStep),
 		defaults:     &config.Defaults{},
 		event:        event,
+		githubHost:   github.DefaultHost(),
 		replacements: variables.MakeReplacements(workflow, event),
 		workflow:     workflow,
 	}
@@ -62,6 +137,35 @@ func (b *Builder) WithDefaults(defaults *config.Defaults) *Builder {
 	return b
 }
 
+// WithCredentials returns the same Builder with the Github credentials the
+// PipelineRun must be reported with.
+func (b *Builder) WithCredentials(credentials *github.Credentials) *Builder {
+	b.credentials = credentials
+	return b
+}
+
+// WithCheckoutTokenSecret returns the same Builder with the Secret holding the
+// installation token private repositories must be checked out with, in place
+// of deploy keys.
+func (b *Builder) WithCheckoutTokenSecret(name string) *Builder {
+	b.checkoutTokenSecret = name
+	return b
+}
+
+// WithGithubHost returns the same Builder with the Github instance that
+// repositories are checked out from.
+func (b *Builder) WithGithubHost(host *github.Host) *Builder {
+	b.githubHost = host
+	return b
+}
+
+// WithLogsToken returns the same Builder with the token that will grant access
+// to the PipelineRun's logs once they're archived.
+func (b *Builder) WithLogsToken(token string) *Builder {
+	b.logsToken = token
+	return b
+}
+
 // Build returns a new PipelineRun object.
 func (b *Builder) Build() *pipelinev1beta1.PipelineRun {
 	pipelineRun := &pipelinev1beta1.PipelineRun{
@@ -69,7 +173,7 @@ func (b *Builder) Build() *pipelinev1beta1.PipelineRun {
 			GenerateName: fmt.Sprintf("%s-run-", b.workflow.GetName()),
 			Namespace:    b.workflow.GetNamespace(),
 			Labels: map[string]string{
-				"workflows.dev/workflow": b.workflow.GetName(),
+				WorkflowLabel: b.workflow.GetName(),
 			},
 			Annotations: make(map[string]string),
 		},
@@ -81,6 +185,14 @@ func (b *Builder) Build() *pipelinev1beta1.PipelineRun {
 
 	b.copyLabelsAndAnnotations(pipelineRun)
 	b.addDefaultLabelsAndAnnotations(pipelineRun)
+	b.addProviderLabel(pipelineRun)
+	b.addEventAnnotations(pipelineRun)
+	b.addCommitStatusAnnotations(pipelineRun)
+	b.addPullRequestCommentAnnotations(pipelineRun)
+	b.addEnvironmentsAnnotation(pipelineRun)
+	b.addLogsAnnotations(pipelineRun)
+	b.addCredentialsAnnotation(pipelineRun)
+	b.addCheckoutTokenAnnotation(pipelineRun)
 
 	// Let built-in steps to modify the PipelineRun resource.
 	for _, builtInStep := range b.builtInSteps {
@@ -143,18 +255,47 @@ func (b *Builder) buildEmbededTask(task *workflowsv1alpha1.Task) *pipelinev1beta
 	}
 	embededTask.Steps = make([]pipelinev1beta1.Step, 0)
 
-	for _, embeddedStep := range task.Steps {
+	collectsTestReports := b.collectsTestReports(task)
+	writesJobSummary := b.writesJobSummary(task)
+
+	for i, embeddedStep := range task.Steps {
 		var step pipelinev1beta1.Step
 
 		if embeddedStep.Use != "" {
 			step = b.invokeBuiltInAction(embeddedStep)
 		} else {
 			step = b.buildStep(embeddedStep)
+			if collectsTestReports || writesJobSummary {
+				step = guardStep(step, i)
+			}
 		}
 
 		embededTask.Steps = append(embededTask.Steps, step)
 	}
 
+	if writesJobSummary {
+		embededTask.Steps = append(embededTask.Steps, b.buildJobSummaryStep(jobSummaryBudget(collectsTestReports), collectsTestReports))
+		embededTask.Results = append(embededTask.Results, pipelinev1beta1.TaskResult{
+			Name:        JobSummaryResult,
+			Description: "Compressed Markdown summary written by steps",
+		})
+	}
+
+	if collectsTestReports {
+		embededTask.Steps = append(embededTask.Steps, b.buildTestReportsStep(task, writesJobSummary))
+		embededTask.Results = append(embededTask.Results, pipelinev1beta1.TaskResult{
+			Name:        TestReportResult,
+			Description: "Summary of test reports",
+		})
+	}
+
+	if collectsTestReports || writesJobSummary {
+		embededTask.Results = append(embededTask.Results, pipelinev1beta1.TaskResult{
+			Name:        FailedStepResult,
+			Description: "Name of the first step that failed",
+		})
+	}
+
 	// Let built-in steps to modify the embedded task
 	for _, builtInStep := range b.builtInSteps {
 		builtInStep.PostEmbeddedTaskCreation(embededTask)
@@ -208,8 +349,10 @@ func (b *Builder) invokeBuiltInAction(embeddedStep workflowsv1alpha1.EmbeddedSte
 	switch builtdStepType {
 	case workflowsv1alpha1.CheckoutStep:
 		builtInStep = &Checkout{
-			event:    b.event,
-			workflow: b.workflow,
+			event:       b.event,
+			host:        b.githubHost,
+			workflow:    b.workflow,
+			tokenSecret: b.checkoutTokenSecret,
 		}
 
 	default:
@@ -260,7 +403,9 @@ func (b *Builder) buildTaskRunSpecs() []pipelinev1beta1.PipelineTaskRunSpec {
 }
 
 // copyLabelsAndAnnotations copies all labels and annotations in the workflow to
-// the supplied pipelineRun.
+// the supplied pipelineRun, except for annotations under the reserved prefix,
+// which controllers trust to report the run and which the workflow read from
+// the repository mustn't be able to set.
 // All variables declared in annotations will be substituted by values taken
 // from the replacement context.
 func (b *Builder) copyLabelsAndAnnotations(pipelineRun *pipelinev1beta1.PipelineRun) {
@@ -269,6 +414,9 @@ func (b *Builder) copyLabelsAndAnnotations(pipelineRun *pipelinev1beta1.Pipeline
 	}
 
 	for key, value := range b.workflow.Annotations {
+		if strings.HasPrefix(key, reservedAnnotationPrefix) {
+			continue
+		}
 		pipelineRun.Annotations[key] = variables.Expand(value, b.replacements)
 	}
 }
@@ -290,3 +438,120 @@ func (b *Builder) addDefaultLabelsAndAnnotations(pipelineRun *pipelinev1beta1.Pi
 		}
 	}
 }
+
+// addProviderLabel labels PipelineRuns of repositories that aren't hosted by
+// Github, so that their progress isn't reported to Github.
+func (b *Builder) addProviderLabel(pipelineRun *pipelinev1beta1.PipelineRun) {
+	if provider := b.workflow.GetProvider(); provider != workflowsv1alpha1.GithubProvider {
+		pipelineRun.Labels[ProviderLabel] = string(provider)
+	}
+}
+
+// addEventAnnotations adds annotations describing the Github event that
+// triggered the PipelineRun, which allow controllers to report the run's
+// progress back to Github.
+func (b *Builder) addEventAnnotations(pipelineRun *pipelinev1beta1.PipelineRun) {
+	annotations := map[string]string{
+		RepositoryAnnotation: b.workflow.Spec.Repository.String(),
+		HeadCommitAnnotation: b.event.HeadCommitSHA,
+		EventAnnotation:      b.event.Name,
+		DeliveryIDAnnotation: b.event.DeliveryID,
+	}
+
+	if b.event.PullRequestNumber != 0 {
+		annotations[PullRequestAnnotation] = strconv.Itoa(b.event.PullRequestNumber)
+	}
+
+	for key, value := range annotations {
+		if value != "" {
+			pipelineRun.Annotations[key] = value
+		}
+	}
+}
+
+// addCommitStatusAnnotations adds annotations that configure how the
+// PipelineRun's progress is reported as Github commit statuses, when the
+// workflow opts into them.
+func (b *Builder) addCommitStatusAnnotations(pipelineRun *pipelinev1beta1.PipelineRun) {
+	commitStatus := b.workflow.Spec.CommitStatus
+	if commitStatus == nil {
+		return
+	}
+
+	pipelineRun.Annotations[CommitStatusContextAnnotation] = commitStatus.GetContext(b.workflow)
+	if commitStatus.TargetURL != "" {
+		pipelineRun.Annotations[CommitStatusTargetURLAnnotation] = variables.Expand(commitStatus.TargetURL, b.replacements)
+	}
+}
+
+// addPullRequestCommentAnnotations adds annotations that configure the summary
+// comment posted on the pull request that triggered the PipelineRun, when the
+// workflow opts into it.
+func (b *Builder) addPullRequestCommentAnnotations(pipelineRun *pipelinev1beta1.PipelineRun) {
+	comment := b.workflow.Spec.PullRequestComment
+	if comment == nil || b.event.PullRequestNumber == 0 {
+		return
+	}
+
+	pipelineRun.Annotations[PullRequestCommentAnnotation] = "true"
+	if comment.LogsURL != "" {
+		pipelineRun.Annotations[PullRequestCommentLogsURLAnnotation] = variables.Expand(comment.LogsURL, b.replacements)
+	}
+}
+
+// addEnvironmentsAnnotation adds an annotation describing the environments
+// tasks deploy to, so their progress can be reported as Github deployments.
+func (b *Builder) addEnvironmentsAnnotation(pipelineRun *pipelinev1beta1.PipelineRun) {
+	environments := make(map[string]Environment)
+	for taskName, task := range b.workflow.Spec.Tasks {
+		if task.Environment == nil {
+			continue
+		}
+
+		environments[taskName] = Environment{Name: task.Environment.Name,
+			URL: variables.Expand(task.Environment.URL, b.replacements),
+		}
+	}
+
+	if len(environments) == 0 {
+		return
+	}
+
+	// Marshaling a map of plain strings can't fail.
+	value, _ := json.Marshal(environments)
+	pipelineRun.Annotations[EnvironmentsAnnotation] = string(value)
+}
+
+// addLogsAnnotations adds annotations that allow the PipelineRun's logs to be
+// archived and read through the hook listener the workflow's Webhook points
+// to.
+func (b *Builder) addLogsAnnotations(pipelineRun *pipelinev1beta1.PipelineRun) {
+	webhook := b.workflow.Spec.Webhook
+	if b.logsToken == "" || webhook == nil || webhook.URL == "" {
+		return
+	}
+
+	SetLogsToken(pipelineRun, webhook.URL, b.logsToken)
+}
+
+// addCredentialsAnnotation records the Secret holding the Github credentials
+// the PipelineRun must be reported with. Annotations copied from the workflow
+// can't choose it, since the workflow may have been read from the repository.
+func (b *Builder) addCredentialsAnnotation(pipelineRun *pipelinev1beta1.PipelineRun) {
+	if b.credentials == nil {
+		delete(pipelineRun.Annotations, CredentialsAnnotation)
+		return
+	}
+	pipelineRun.Annotations[CredentialsAnnotation] = b.credentials.Name
+}
+
+// addCheckoutTokenAnnotation records the Secret holding the installation token
+// repositories are checked out with, so reruns can replace it with a fresh
+// one.
+func (b *Builder) addCheckoutTokenAnnotation(pipelineRun *pipelinev1beta1.PipelineRun) {
+	if b.checkoutTokenSecret == "" {
+		delete(pipelineRun.Annotations, CheckoutTokenSecretAnnotation)
+		return
+	}
+	pipelineRun.Annotations[CheckoutTokenSecretAnnotation] = b.checkoutTokenSecret
+}
@@ -12,6 +12,7 @@ import (
 	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
 
 	"github.com/nubank/workflows/pkg/apis/config"
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
 	"github.com/nubank/workflows/pkg/github"
 	"github.com/nubank/workflows/pkg/testutils"
 	corev1 "k8s.io/api/core/v1"
@@ -248,7 +249,10 @@ func TestVariableExpansion(t *testing.T) {
 	pipelineRun := NewBuilder(workflow, event).Build()
 
 	wantAnnotations := map[string]string{
-		"workflows.dev/author": "john-doe",
+		"example.com/author":        "john-doe",
+		"workflows.dev/event":       "push",
+		"workflows.dev/head-commit": "833568e",
+		"workflows.dev/repository":  "john-doe/my-repo",
 	}
 
 	gotAnnotations := pipelineRun.Annotations
@@ -288,8 +292,8 @@ func TestLabelsAndAnnotations(t *testing.T) {
 			"workflows.dev/example-label": "def",
 		},
 		Annotations: map[string]string{
-			"workflows.dev/example-annotation": "ghi",
-			"workflows.dev/other-annotation":   "xyz",
+			"example.com/example-annotation": "ghi",
+			"workflows.dev/other-annotation": "xyz",
 		},
 	}
 
@@ -308,9 +312,12 @@ func TestLabelsAndAnnotations(t *testing.T) {
 	}
 
 	wantAnnotations := map[string]string{
-		"workflows.dev/author":             "john-doe",
-		"workflows.dev/example-annotation": "def",
-		"workflows.dev/other-annotation":   "xyz",
+		"example.com/author":             "john-doe",
+		"workflows.dev/event":            "push",
+		"example.com/example-annotation": "def",
+		"workflows.dev/head-commit":      "833568e",
+		"workflows.dev/other-annotation": "xyz",
+		"workflows.dev/repository":       "john-doe/my-repo",
 	}
 
 	gotAnnotations := pipelineRun.Annotations
@@ -321,6 +328,249 @@ func TestLabelsAndAnnotations(t *testing.T) {
 
 }
 
+func TestCommitStatusAnnotations(t *testing.T) {
+	workflow, err := testutils.ReadWorkflow("commit-status.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event, err := testutils.ReadEvent("event.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pipelineRun := NewBuilder(workflow, event).Build()
+
+	tests := []struct {
+		annotation string
+		want       string
+	}{
+		{"workflows.dev/commit-status-context", "workflows/hello"},
+		{"workflows.dev/commit-status-target-url", "https://dashboard.example.com/#/namespaces/$(pipelinerun.namespace)/pipelineruns/$(pipelinerun.name)?commit=833568e"},
+	}
+
+	for _, test := range tests {
+		if got := pipelineRun.Annotations[test.annotation]; test.want != got {
+			t.Errorf("Annotation %s: want %s, got %s", test.annotation, test.want, got)
+		}
+	}
+}
+
+func TestPullRequestCommentAnnotations(t *testing.T) {
+	workflow, err := testutils.ReadWorkflow("labels-and-annotations.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	workflow.Spec.PullRequestComment = &workflowsv1alpha1.PullRequestComment{
+		LogsURL: "https://dashboard.example.com/#/namespaces/$(pipelinerun.namespace)/pipelineruns/$(pipelinerun.name)",
+	}
+
+	tests := []struct {
+		event           *github.Event
+		wantAnnotations map[string]string
+	}{
+		{&github.Event{Name: "pull_request", PullRequestNumber: 7}, map[string]string{
+			"workflows.dev/pull-request":                  "7",
+			"workflows.dev/pull-request-comment":          "true",
+			"workflows.dev/pull-request-comment-logs-url": "https://dashboard.example.com/#/namespaces/$(pipelinerun.namespace)/pipelineruns/$(pipelinerun.name)",
+		}},
+		{&github.Event{Name: "push"}, map[string]string{
+			"workflows.dev/pull-request":                  "",
+			"workflows.dev/pull-request-comment":          "",
+			"workflows.dev/pull-request-comment-logs-url": "",
+		}},
+	}
+
+	for _, test := range tests {
+		pipelineRun := NewBuilder(workflow, test.event).Build()
+
+		for annotation, want := range test.wantAnnotations {
+			if got := pipelineRun.Annotations[annotation]; want != got {
+				t.Errorf("%s event: want annotation %s to be %q, got %q", test.event.Name, annotation, want, got)
+			}
+		}
+	}
+}
+
+func TestWorkflowsCantSetReservedAnnotations(t *testing.T) {
+	workflow, err := testutils.ReadWorkflow("labels-and-annotations.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event, err := testutils.ReadEvent("event.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	workflow.Annotations[EnvironmentsAnnotation] = `{"test":{"name":"production"}}`
+	workflow.Annotations[CommitStatusContextAnnotation] = "security/scan"
+
+	pipelineRun := NewBuilder(workflow, event).Build()
+
+	for _, annotation := range []string{EnvironmentsAnnotation, CommitStatusContextAnnotation} {
+		if value, ok := pipelineRun.Annotations[annotation]; ok {
+			t.Errorf("Want no annotation %s, got %s", annotation, value)
+		}
+	}
+
+	if got := pipelineRun.Annotations["example.com/author"]; got != "john-doe" {
+		t.Errorf("Want other annotations to be copied, got %+v", pipelineRun.Annotations)
+	}
+}
+
+func TestEnvironmentsAnnotation(t *testing.T) {
+	workflow, err := testutils.ReadWorkflow("labels-and-annotations.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event, err := testutils.ReadEvent("event.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pipelineRun := NewBuilder(workflow, event).Build()
+	if _, ok := pipelineRun.Annotations["workflows.dev/environments"]; ok {
+		t.Error("Want no environments annotation for workflows without environments")
+	}
+
+	workflow.Spec.Tasks["test"].Environment = &workflowsv1alpha1.Environment{Name: "staging",
+		URL: "https://$(workflow.head-commit).staging.example.com",
+	}
+
+	pipelineRun = NewBuilder(workflow, event).Build()
+
+	want := `{"test":{"name":"staging","url":"https://833568e.staging.example.com"}}`
+	if got := pipelineRun.Annotations["workflows.dev/environments"]; want != got {
+		t.Errorf("Want annotation %s, got %s", want, got)
+	}
+}
+
+func TestLogsAnnotations(t *testing.T) {
+	workflow, err := testutils.ReadWorkflow("labels-and-annotations.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event, err := testutils.ReadEvent("event.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pipelineRun := NewBuilder(workflow, event).WithLogsToken("s3cr3t").Build()
+	if _, ok := pipelineRun.Annotations["workflows.dev/logs-url"]; ok {
+		t.Error("Want no logs annotations for workflows without Webhooks")
+	}
+
+	workflow.Spec.Webhook = &workflowsv1alpha1.Webhook{URL: "https://hooks.example.com/"}
+
+	pipelineRun = NewBuilder(workflow, event).Build()
+	if _, ok := pipelineRun.Annotations["workflows.dev/logs-url"]; ok {
+		t.Error("Want no logs annotations when logs aren't archived")
+	}
+
+	pipelineRun = NewBuilder(workflow, event).WithLogsToken("s3cr3t").Build()
+
+	want := map[string]string{
+		"workflows.dev/logs-token": "s3cr3t",
+		"workflows.dev/logs-url":   "https://hooks.example.com/api/v1alpha1/namespaces/$(pipelinerun.namespace)/pipelineruns/$(pipelinerun.name)/logs?token=s3cr3t",
+	}
+
+	for key, value := range want {
+		if got := pipelineRun.Annotations[key]; value != got {
+			t.Errorf("Want annotation %s=%s, got %s", key, value, got)
+		}
+	}
+}
+
+func TestCredentialsAnnotation(t *testing.T) {
+	workflow, err := testutils.ReadWorkflow("labels-and-annotations.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event, err := testutils.ReadEvent("event.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Workflows can't choose the credentials through their own annotations.
+	workflow.Annotations = map[string]string{"workflows.dev/github-credentials": "someone-else"}
+
+	pipelineRun := NewBuilder(workflow, event).Build()
+	if value, ok := pipelineRun.Annotations["workflows.dev/github-credentials"]; ok {
+		t.Errorf("Want no credentials annotation, got %s", value)
+	}
+
+	pipelineRun = NewBuilder(workflow, event).
+		WithCredentials(&github.Credentials{Namespace: "default", Name: "my-team-github"}).
+		Build()
+
+	if got := pipelineRun.Annotations["workflows.dev/github-credentials"]; got != "my-team-github" {
+		t.Errorf("Want credentials annotation my-team-github, got %s", got)
+	}
+}
+
+func TestProviderLabel(t *testing.T) {
+	workflow, err := testutils.ReadWorkflow("labels-and-annotations.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event, err := testutils.ReadEvent("event.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Progress is only reported to Github for repositories hosted there.
+	pipelineRun := NewBuilder(workflow, event).Build()
+	if value, ok := pipelineRun.Labels[ProviderLabel]; ok || !HostedByGithubFilterFunc(pipelineRun) {
+		t.Errorf("Want a PipelineRun reporting to Github, got provider label %s", value)
+	}
+
+	workflow.Spec.Repository.Provider = workflowsv1alpha1.GitlabProvider
+	pipelineRun = NewBuilder(workflow, event).Build()
+	if got := pipelineRun.Labels[ProviderLabel]; got != "gitlab" || HostedByGithubFilterFunc(pipelineRun) {
+		t.Errorf("Want a PipelineRun labeled with provider gitlab, got %s", got)
+	}
+}
+
+func TestCheckoutTokenSecret(t *testing.T) {
+	workflow, err := testutils.ReadWorkflow("checking-out-private-repos.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event, err := testutils.ReadEvent("event.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pipelineRun := NewBuilder(workflow, event).
+		WithCheckoutTokenSecret("lorem-ipsum-checkout-token-abc12").
+		Build()
+
+	if got := pipelineRun.Annotations[CheckoutTokenSecretAnnotation]; got != "lorem-ipsum-checkout-token-abc12" {
+		t.Errorf("Want checkout token annotation lorem-ipsum-checkout-token-abc12, got %s", got)
+	}
+
+	// Reruns replace the token of the original run.
+	SetCheckoutTokenSecret(pipelineRun, "lorem-ipsum-checkout-token-def34")
+
+	if got := pipelineRun.Annotations[CheckoutTokenSecretAnnotation]; got != "lorem-ipsum-checkout-token-def34" {
+		t.Errorf("Want checkout token annotation lorem-ipsum-checkout-token-def34, got %s", got)
+	}
+
+	for _, task := range pipelineRun.Spec.PipelineSpec.Tasks {
+		volumes := task.TaskSpec.Volumes
+		if len(volumes) != 1 || volumes[0].Secret.SecretName != "lorem-ipsum-checkout-token-def34" {
+			t.Errorf("Want task %s to mount the new token, got %+v", task.Name, volumes)
+		}
+	}
+}
+
 func TestGraph(t *testing.T) {
 	workflow, err := testutils.ReadWorkflow("creating-graphs.yaml")
 	if err != nil {
@@ -0,0 +1,29 @@
+package pipelinerun
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	tektonclientset "github.com/tektoncd/pipeline/pkg/client/clientset/versioned"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Cancel asks Tekton to cancel the supplied PipelineRun by patching its spec.
+func Cancel(ctx context.Context, tektonClientSet tektonclientset.Interface, pipelineRun *pipelinev1beta1.PipelineRun) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"status": pipelinev1beta1.PipelineRunSpecStatusCancelled,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := tektonClientSet.TektonV1beta1().PipelineRuns(pipelineRun.GetNamespace()).Patch(ctx, pipelineRun.GetName(), types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("Error cancelling PipelineRun %s/%s: %w", pipelineRun.GetNamespace(), pipelineRun.GetName(), err)
+	}
+	return nil
+}
@@ -3,10 +3,12 @@ package pipelinerun
 import (
 	"bytes"
 	"fmt"
+	"strings"
 	"text/template"
 
 	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
 	"github.com/nubank/workflows/pkg/github"
+	"github.com/nubank/workflows/pkg/secrets"
 	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
 	corev1 "k8s.io/api/core/v1"
 )
@@ -26,6 +28,17 @@ const (
 
 	// Name of the volume used to mount SSH private keys into steps.
 	sshPrivateKeysVolumeName = "ssh-private-keys"
+
+	// Path inside the container where the installation token of the run will
+	// be mounted.
+	checkoutTokenMountPath = "/var/run/secrets/workflows-checkout"
+
+	// Name of the volume used to mount the installation token into steps.
+	checkoutTokenVolumeName = "checkout-token"
+
+	// File where the CA bundle of the Github host is written to, relative to
+	// the home directory of steps.
+	caBundleFile = "github-ca.crt"
 )
 
 var (
@@ -42,11 +55,18 @@ set -euo pipefail
 {{if .SSHPrivateKey}}
 mkdir -p ~/.ssh
 cat > ~/.ssh/config<<EOF
-Host github.com
+Host {{.GitHostname}}
   User git
-  Hostname github.com
+  Hostname {{.GitHostname}}
   IdentityFile {{.SSHPrivateKey}}
 EOF
+{{end}}{{if .TokenFile}}
+git config --global credential.helper '!f() { test "$1" = get || exit 0; echo username=x-access-token; echo "password=$(cat {{.TokenFile}})"; }; f'
+{{end}}{{if .CABundle}}
+cat > ~/{{.CABundleFile}}<<'EOF'
+{{.CABundle}}
+EOF
+export GIT_SSL_CAINFO=~/{{.CABundleFile}}
 {{end}}
 /ko-app/git-init \
     -url="{{.URL}}" \
@@ -64,25 +84,35 @@ echo -n "$(git rev-parse HEAD)" > /tekton/results/{{.ResultName}}`))
 type Checkout struct {
 	workflow *workflowsv1alpha1.Workflow
 	event    *github.Event
+	host     *github.Host
+
+	// Name of the Secret holding the installation token private repositories
+	// are checked out with. It's empty when they're checked out with deploy
+	// keys.
+	tokenSecret string
 }
 
 // CheckoutOptions represents a few options to be passed to the checkout script.
 type CheckoutOptions struct {
+	CABundle      string
+	CABundleFile  string
 	Dept          int
 	Destination   string
+	GitHostname   string
 	ResultName    string
 	Revision      string
 	SSHPrivateKey string
+	TokenFile     string
 	URL           string
 }
 
 // BuildStep implements BuiltInStep.
 func (c *Checkout) BuildStep(embeddedStep workflowsv1alpha1.EmbeddedStep) pipelinev1beta1.Step {
-	return buildCheckoutStep(embeddedStep, c.workflow.Spec.Repository, c.event)
+	return c.buildCheckoutStep(embeddedStep, c.workflow.Spec.Repository, c.event)
 }
 
-func buildCheckoutStep(embeddedStep workflowsv1alpha1.EmbeddedStep, repo *workflowsv1alpha1.Repository, event *github.Event) pipelinev1beta1.Step {
-	options := BuildCheckoutOptions(repo, event)
+func (c *Checkout) buildCheckoutStep(embeddedStep workflowsv1alpha1.EmbeddedStep, repo *workflowsv1alpha1.Repository, event *github.Event) pipelinev1beta1.Step {
+	options := c.buildCheckoutOptions(repo, event)
 	step := pipelinev1beta1.Step{
 		Container: corev1.Container{
 			Image: gitInitImage,
@@ -96,7 +126,14 @@ func buildCheckoutStep(embeddedStep workflowsv1alpha1.EmbeddedStep, repo *workfl
 		step.Name = "checkout"
 	}
 
-	if repo.NeedsSSHPrivateKeys() {
+	if repo.NeedsSSHPrivateKeys() && c.tokenSecret != "" {
+		step.VolumeMounts = []corev1.VolumeMount{
+			{Name: checkoutTokenVolumeName,
+				ReadOnly:  true,
+				MountPath: checkoutTokenMountPath,
+			},
+		}
+	} else if repo.NeedsSSHPrivateKeys() {
 		step.VolumeMounts = []corev1.VolumeMount{
 			{Name: sshPrivateKeysVolumeName,
 				ReadOnly:  true,
@@ -108,20 +145,42 @@ func buildCheckoutStep(embeddedStep workflowsv1alpha1.EmbeddedStep, repo *workfl
 	return step
 }
 
+// buildCheckoutOptions returns options for checking out the supplied
+// repository, which is cloned over HTTPS with the installation token of the
+// run, if any, rather than over SSH with its deploy key.
+func (c *Checkout) buildCheckoutOptions(repo *workflowsv1alpha1.Repository, event *github.Event) CheckoutOptions {
+	options := BuildCheckoutOptions(repo, event, c.host)
+
+	if repo.NeedsSSHPrivateKeys() && c.tokenSecret != "" {
+		options.SSHPrivateKey = ""
+		options.TokenFile = fmt.Sprintf("%s/%s", checkoutTokenMountPath, secrets.CheckoutTokenKey)
+		options.URL = c.host.HTTPSCloneURL(repo.Owner, repo.Name)
+	}
+
+	return options
+}
+
 // BuildCheckoutOptions returns options that control the behavior of the
-// checkout process for the supplied repository.
-func BuildCheckoutOptions(repo *workflowsv1alpha1.Repository, event *github.Event) CheckoutOptions {
+// checkout process for the supplied repository, hosted by the Github instance
+// in question.
+func BuildCheckoutOptions(repo *workflowsv1alpha1.Repository, event *github.Event, host *github.Host) CheckoutOptions {
 	options := CheckoutOptions{
 		Dept:        1,
 		Destination: fmt.Sprintf("%s/%s", projectsWorkspaceExpr, repo.Name),
+		GitHostname: host.GitHostname(),
 		ResultName:  resultName(repo),
 	}
 
 	if repo.NeedsSSHPrivateKeys() {
 		options.SSHPrivateKey = fmt.Sprintf("%s/%s", sshPrivateKeysMountPath, repo.GetSSHPrivateKeyName())
-		options.URL = fmt.Sprintf("git@github.com:%s/%s.git", repo.Owner, repo.Name)
+		options.URL = host.SSHCloneURL(repo.Owner, repo.Name)
 	} else {
-		options.URL = fmt.Sprintf("https://github.com/%s/%s.git", repo.Owner, repo.Name)
+		options.URL = host.HTTPSCloneURL(repo.Owner, repo.Name)
+	}
+
+	if len(host.CABundle) != 0 {
+		options.CABundle = strings.TrimSpace(string(host.CABundle))
+		options.CABundleFile = caBundleFile
 	}
 
 	if event.HeadCommitSHA != "" {
@@ -182,8 +241,8 @@ func (c *Checkout) PostEmbeddedTaskCreation(task *pipelinev1beta1.EmbeddedTask)
 		})
 	}
 
-	// Control whether a volume for projecting SSH private keys should be
-	// mounted.
+	// Control whether a volume for projecting SSH private keys (or the
+	// installation token) should be mounted.
 	needsSSHPrivateKeys := c.workflow.Spec.Repository.NeedsSSHPrivateKeys()
 
 	// Inject steps for checking out additional repositories.
@@ -203,7 +262,7 @@ func (c *Checkout) PostEmbeddedTaskCreation(task *pipelinev1beta1.EmbeddedTask)
 				Name: fmt.Sprintf("checkout-%s", repo.Name),
 				Use:  workflowsv1alpha1.CheckoutStep,
 			}
-			steps = append(steps, buildCheckoutStep(embeddedStep, &repo, event))
+			steps = append(steps, c.buildCheckoutStep(embeddedStep, &repo, event))
 
 			if repo.NeedsSSHPrivateKeys() {
 				needsSSHPrivateKeys = true
@@ -218,6 +277,23 @@ func (c *Checkout) PostEmbeddedTaskCreation(task *pipelinev1beta1.EmbeddedTask)
 		task.Steps = steps
 	}
 
+	// If credentials are required (i.e. there are private repositories
+	// associated to this workflow) and the run has an installation token,
+	// create the volume to mount the secret containing it into the steps.
+	if needsSSHPrivateKeys && c.tokenSecret != "" {
+		task.Volumes = []corev1.Volume{{
+			Name: checkoutTokenVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName:  c.tokenSecret,
+					DefaultMode: &defaultVolumeMode,
+				},
+			},
+		},
+		}
+		return
+	}
+
 	// If SSH private keys are required (i.e. there are private repositories
 	// associated to this workflow), create the volume to mount the secret
 	// containing the deploy key into the step.
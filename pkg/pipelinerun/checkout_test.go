@@ -8,6 +8,8 @@ import (
 	"github.com/google/go-cmp/cmp"
 	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
 
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	"github.com/nubank/workflows/pkg/github"
 	"github.com/nubank/workflows/pkg/testutils"
 )
 
@@ -26,6 +28,7 @@ func newTestCheckoutStep(filename string) (*Checkout, error) {
 	return &Checkout{
 		workflow: workflow,
 		event:    event,
+		host:     github.DefaultHost(),
 	}, nil
 }
 
@@ -143,6 +146,125 @@ echo -n "$(git rev-parse HEAD)" > /tekton/results/my-repo-commit`,
 	}
 }
 
+func TestCheckoutBuildStepForEnterpriseServer(t *testing.T) {
+	checkout, err := newTestCheckoutStep("checking-out-private-repos.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkout.host = &github.Host{APIURL: "https://github.example.com/api/v3/",
+		UploadURL: "https://github.example.com/api/uploads/",
+		GitURL:    "https://github.example.com",
+		CABundle:  []byte("-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n"),
+	}
+
+	want := `#!/usr/bin/env sh
+set -euo pipefail
+
+mkdir -p ~/.ssh
+cat > ~/.ssh/config<<EOF
+Host github.example.com
+  User git
+  Hostname github.example.com
+  IdentityFile /var/run/secrets/workflows/my-repo_id_rsa
+EOF
+
+cat > ~/github-ca.crt<<'EOF'
+-----BEGIN CERTIFICATE-----
+MIIB
+-----END CERTIFICATE-----
+EOF
+export GIT_SSL_CAINFO=~/github-ca.crt
+
+/ko-app/git-init \
+    -url="git@github.example.com:john-doe/my-repo.git" \
+    -revision="833568e" \
+    -path="$(workspaces.projects.path)/my-repo" \
+    -sslVerify="true" \
+    -submodules="true" \
+    -depth="1"
+
+cd $(workspaces.projects.path)/my-repo
+echo -n "$(git rev-parse HEAD)" > /tekton/results/my-repo-commit`
+
+	got := checkout.BuildStep(checkout.workflow.Spec.Tasks["lint"].Steps[0])
+	if diff := cmp.Diff(want, got.Script); diff != "" {
+		t.Errorf("Mismatch (-want +got):\n%s", diff)
+	}
+
+	options := BuildCheckoutOptions(&workflowsv1alpha1.Repository{Owner: "john-doe", Name: "my-other-repo"}, &github.Event{}, checkout.host)
+	if options.URL != "https://github.example.com/john-doe/my-other-repo.git" {
+		t.Errorf("Unexpected clone URL %s", options.URL)
+	}
+}
+
+func TestCheckoutWithInstallationToken(t *testing.T) {
+	checkout, err := newTestCheckoutStep("checking-out-private-repos.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkout.tokenSecret = "lorem-ipsum-checkout-token-abc12"
+
+	want := pipelinev1beta1.Step{
+		Container: corev1.Container{
+			Name:  "checkout",
+			Image: gitInitImage,
+			VolumeMounts: []corev1.VolumeMount{
+				{Name: checkoutTokenVolumeName,
+					ReadOnly:  true,
+					MountPath: checkoutTokenMountPath,
+				},
+			},
+		},
+		Script: `#!/usr/bin/env sh
+set -euo pipefail
+
+git config --global credential.helper '!f() { test "$1" = get || exit 0; echo username=x-access-token; echo "password=$(cat /var/run/secrets/workflows-checkout/token)"; }; f'
+
+/ko-app/git-init \
+    -url="https://github.com/john-doe/my-repo.git" \
+    -revision="833568e" \
+    -path="$(workspaces.projects.path)/my-repo" \
+    -sslVerify="true" \
+    -submodules="true" \
+    -depth="1"
+
+cd $(workspaces.projects.path)/my-repo
+echo -n "$(git rev-parse HEAD)" > /tekton/results/my-repo-commit`,
+	}
+
+	got := checkout.BuildStep(checkout.workflow.Spec.Tasks["lint"].Steps[0])
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Mismatch (-want +got):\n%s", diff)
+	}
+
+	task := pipelinev1beta1.EmbeddedTask{
+		TaskSpec: pipelinev1beta1.TaskSpec{
+			Steps: []pipelinev1beta1.Step{got},
+		},
+	}
+	checkout.PostEmbeddedTaskCreation(&task)
+
+	wantVolumes := []corev1.Volume{{
+		Name: checkoutTokenVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName:  "lorem-ipsum-checkout-token-abc12",
+				DefaultMode: &defaultVolumeMode,
+			},
+		},
+	},
+	}
+	if diff := cmp.Diff(wantVolumes, task.Volumes); diff != "" {
+		t.Errorf("Mismatch (-want +got):\n%s", diff)
+	}
+
+	// The additional repository is checked out with the same token.
+	if mounts := task.Steps[1].VolumeMounts; len(mounts) != 1 || mounts[0].Name != checkoutTokenVolumeName {
+		t.Errorf("Want the token to be mounted into %s, got %+v", task.Steps[1].Name, mounts)
+	}
+}
+
 func TestCheckoutPostEmbeddedTaskCreation(t *testing.T) {
 	tests := []struct {
 		name     string
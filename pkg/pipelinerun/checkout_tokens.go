@@ -0,0 +1,36 @@
+package pipelinerun
+
+import (
+	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+)
+
+// CheckoutTokenSecretAnnotation holds the name of the Secret, owned by the
+// PipelineRun, holding the installation token its repositories are checked
+// out with. It's absent when repositories are checked out with deploy keys.
+const CheckoutTokenSecretAnnotation = "workflows.dev/checkout-token-secret"
+
+// SetCheckoutTokenSecret makes the checkout steps of the supplied PipelineRun
+// authenticate with the installation token held by the named Secret. It lets
+// reruns replace the token of the original run, which expires shortly.
+func SetCheckoutTokenSecret(pipelineRun *pipelinev1beta1.PipelineRun, name string) {
+	if pipelineRun.Annotations == nil {
+		pipelineRun.Annotations = make(map[string]string)
+	}
+	pipelineRun.Annotations[CheckoutTokenSecretAnnotation] = name
+
+	if pipelineRun.Spec.PipelineSpec == nil {
+		return
+	}
+
+	for _, task := range pipelineRun.Spec.PipelineSpec.Tasks {
+		if task.TaskSpec == nil {
+			continue
+		}
+
+		for _, volume := range task.TaskSpec.Volumes {
+			if volume.Name == checkoutTokenVolumeName && volume.Secret != nil {
+				volume.Secret.SecretName = name
+			}
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package pipelinerun
+
+import (
+	"context"
+
+	"github.com/nubank/workflows/pkg/github"
+	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// CredentialsAnnotation holds the name of the Secret, in the PipelineRun's
+// namespace, holding the Github credentials the run must be reported with. It's
+// absent when the run is reported as the Github App the controller was
+// installed with.
+const CredentialsAnnotation = "workflows.dev/github-credentials"
+
+// WithGithubCredentials returns a copy of the supplied context carrying the
+// Github credentials the PipelineRun must be reported with, if any.
+func WithGithubCredentials(ctx context.Context, kubeClientSet kubernetes.Interface, pipelineRun *pipelinev1beta1.PipelineRun) (context.Context, error) {
+	name := pipelineRun.GetAnnotations()[CredentialsAnnotation]
+	if name == "" {
+		return ctx, nil
+	}
+
+	credentials, err := github.ResolveCredentials(ctx, kubeClientSet, pipelineRun.GetNamespace(), name)
+	if err != nil {
+		return nil, err
+	}
+	return github.WithCredentials(ctx, credentials), nil
+}
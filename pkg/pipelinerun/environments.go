@@ -0,0 +1,65 @@
+package pipelinerun
+
+import (
+	"encoding/json"
+	"fmt"
+
+	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+)
+
+const (
+	// EnvironmentsAnnotation holds a JSON object mapping names of tasks that
+	// deploy somewhere to their environments.
+	EnvironmentsAnnotation = "workflows.dev/environments"
+
+	// DeploymentsAnnotation holds a JSON object mapping names of tasks to the
+	// Github deployments created for them.
+	DeploymentsAnnotation = "workflows.dev/deployments"
+)
+
+// Environment is where a task of the PipelineRun deploys to.
+type Environment struct {
+	Name string `json:"name"`
+	URL  string `json:"url,omitempty"`
+}
+
+// Deployment is the Github deployment created for a task along with the last
+// state reported to it.
+type Deployment struct {
+	ID    int64  `json:"id"`
+	State string `json:"state"`
+}
+
+// GetEnvironments returns the environments tasks of the supplied PipelineRun
+// deploy to, keyed by task name.
+func GetEnvironments(pipelineRun *pipelinev1beta1.PipelineRun) (map[string]Environment, error) {
+	environments := make(map[string]Environment)
+	if err := unmarshalAnnotation(pipelineRun, EnvironmentsAnnotation, &environments); err != nil {
+		return nil, err
+	}
+	return environments, nil
+}
+
+// GetDeployments returns the Github deployments created for tasks of the
+// supplied PipelineRun, keyed by task name.
+func GetDeployments(pipelineRun *pipelinev1beta1.PipelineRun) (map[string]Deployment, error) {
+	deployments := make(map[string]Deployment)
+	if err := unmarshalAnnotation(pipelineRun, DeploymentsAnnotation, &deployments); err != nil {
+		return nil, err
+	}
+	return deployments, nil
+}
+
+// unmarshalAnnotation decodes the JSON value of the supplied annotation, if
+// it's set.
+func unmarshalAnnotation(pipelineRun *pipelinev1beta1.PipelineRun, key string, v interface{}) error {
+	value, ok := pipelineRun.GetAnnotations()[key]
+	if !ok {
+		return nil
+	}
+
+	if err := json.Unmarshal([]byte(value), v); err != nil {
+		return fmt.Errorf("invalid annotation %s: %w", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+package pipelinerun
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"text/template"
+
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	"github.com/nubank/workflows/pkg/testreports"
+	"github.com/nubank/workflows/pkg/variables"
+	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// JobSummaryResult is the name of the task result that holds the
+	// compressed Markdown summary written by steps of a task.
+	JobSummaryResult = "job-summary"
+
+	// Name of the built-in step that collects job summaries.
+	jobSummaryStepName = "job-summary"
+
+	// maxJobSummaryInputBytes is the maximum number of bytes read from the
+	// summary file, which is in line with the size of Github comments.
+	maxJobSummaryInputBytes = 65536
+
+	// maxJobSummaryBytes is the maximum size of a decompressed job summary.
+	// It protects the controller against results that inflate too much.
+	maxJobSummaryBytes = 65536
+
+	// jobSummaryTruncatedNotice is appended to job summaries that had to be
+	// truncated.
+	jobSummaryTruncatedNotice = "_Summary truncated to fit in the space available for task results._"
+
+	// MaxJobSummariesBytes is the maximum size of rendered job summaries. It
+	// leaves room for the rest of check run outputs and comments, which
+	// Github limits to 64KiB.
+	MaxJobSummariesBytes = 49152
+
+	// summaryVariable is the variable steps refer to in order to write job
+	// summaries.
+	summaryVariable = "$(workflow.summary-path)"
+)
+
+// jobSummaryScript compresses the summary file into a task result, cutting it
+// down until the encoded result fits in the budget. When it's the last
+// built-in step of the task, it also fails on behalf of the steps that
+// failed.
+var jobSummaryScript = template.Must(template.New("job-summary").
+	Parse(`#!/usr/bin/env sh
+set -eu
+summary={{.SummaryPath}}
+result=/tekton/results/{{.Result}}
+if [ -s "$summary" ]; then
+  size=$(($(wc -c < "$summary")))
+  length=$size
+  if [ "$length" -gt {{.MaxInputBytes}} ]; then
+    length={{.MaxInputBytes}}
+  fi
+  while :; do
+    {
+      head -c "$length" "$summary"
+      if [ "$length" -lt "$size" ]; then
+        printf '\n\n%s\n' '{{.TruncatedNotice}}'
+      fi
+    } | gzip -9 | base64 | tr -d '\n' > "$result"
+    if [ "$(($(wc -c < "$result")))" -le {{.MaxBytes}} ]; then
+      break
+    fi
+    length=$((length * 3 / 4))
+  done
+fi
+{{- if .FailedStepFile}}
+if [ -f {{.FailedStepFile}} ]; then
+  echo "Step $(cat {{.FailedStepFile}}) failed"
+  exit 1
+fi
+{{- end}}
+`))
+
+// writesJobSummary returns true if steps of the supplied task refer to the
+// summary file, in which case it must be collected.
+func (b *Builder) writesJobSummary(task *workflowsv1alpha1.Task) bool {
+	if b.defaults.DefaultImage == "" {
+		return false
+	}
+
+	for _, value := range task.Env {
+		if strings.Contains(value, summaryVariable) {
+			return true
+		}
+	}
+
+	for _, step := range task.Steps {
+		if strings.Contains(step.Run, summaryVariable) || strings.Contains(step.WorkingDir, summaryVariable) {
+			return true
+		}
+
+		for _, value := range step.Env {
+			if strings.Contains(value, summaryVariable) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// buildJobSummaryStep returns the built-in step that publishes the summary
+// written by steps of a task as a task result, within the supplied number of
+// bytes.
+// Unless test reports are collected afterwards, it also fails on behalf of
+// previous steps that failed.
+func (b *Builder) buildJobSummaryStep(maxBytes int, collectsTestReports bool) pipelinev1beta1.Step {
+	failedStep := failedStepFile
+	if collectsTestReports {
+		failedStep = ""
+	}
+
+	var buffer bytes.Buffer
+	err := jobSummaryScript.Execute(&buffer, map[string]interface{}{
+		"FailedStepFile":  failedStep,
+		"MaxBytes":        maxBytes,
+		"MaxInputBytes":   maxJobSummaryInputBytes,
+		"Result":          JobSummaryResult,
+		"SummaryPath":     variables.SummaryPath,
+		"TruncatedNotice": jobSummaryTruncatedNotice,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return pipelinev1beta1.Step{
+		Container: corev1.Container{
+			Name:  jobSummaryStepName,
+			Image: b.defaults.DefaultImage,
+		},
+		Script: buffer.String(),
+	}
+}
+
+// TaskJobSummary is the Markdown summary written by steps of a task.
+type TaskJobSummary struct {
+	Task     string
+	Markdown string
+}
+
+// GetJobSummaries returns the job summaries published by tasks of the
+// PipelineRun, sorted by task name. Summaries are sanitized so they can be
+// safely embedded in check runs and comments. Invalid ones are ignored.
+func GetJobSummaries(pipelineRun *pipelinev1beta1.PipelineRun) []TaskJobSummary {
+	summaries := make([]TaskJobSummary, 0)
+	for _, taskRun := range pipelineRun.Status.TaskRuns {
+		if taskRun.Status == nil {
+			continue
+		}
+
+		for _, result := range taskRun.Status.TaskRunResults {
+			if result.Name != JobSummaryResult {
+				continue
+			}
+
+			markdown, err := decodeJobSummary(result.Value)
+			if err != nil || strings.TrimSpace(markdown) == "" {
+				continue
+			}
+			summaries = append(summaries, TaskJobSummary{Task: taskRun.PipelineTaskName, Markdown: sanitizeMarkdown(markdown)})
+		}
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Task < summaries[j].Task
+	})
+	return summaries
+}
+
+// decodeJobSummary decompresses a job summary published as a task result.
+func decodeJobSummary(value string) (string, error) {
+	compressed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil {
+		return "", fmt.Errorf("Invalid job summary: %w", err)
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return "", fmt.Errorf("Invalid job summary: %w", err)
+	}
+	defer reader.Close()
+
+	content, err := ioutil.ReadAll(io.LimitReader(reader, maxJobSummaryBytes))
+	if err != nil {
+		return "", fmt.Errorf("Invalid job summary: %w", err)
+	}
+	return strings.ToValidUTF8(string(content), ""), nil
+}
+
+// sanitizeMarkdown keeps the Markdown written by steps from breaking out of
+// its section: HTML comments are escaped, since they'd hide the rest of the
+// document or spoof markers of sticky comments, and code fences left open
+// are closed.
+func sanitizeMarkdown(markdown string) string {
+	markdown = strings.TrimRight(strings.ReplaceAll(markdown, "<!--", "&lt;!--"), "\n")
+
+	open := false
+	for _, line := range strings.Split(markdown, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			open = !open
+		}
+	}
+	if open {
+		markdown += "\n```"
+	}
+	return markdown
+}
+
+// RenderJobSummaries renders the supplied job summaries as Markdown sections,
+// one per task, leaving out whatever doesn't fit in the supplied number of
+// bytes.
+func RenderJobSummaries(summaries []TaskJobSummary, maxBytes int) string {
+	var builder strings.Builder
+	for i, summary := range summaries {
+		section := fmt.Sprintf("#### Task `%s`\n\n%s\n\n", summary.Task, summary.Markdown)
+		if builder.Len()+len(section) > maxBytes {
+			fmt.Fprintf(&builder, "_%d more summaries were left out because they're too long._\n", len(summaries)-i)
+			break
+		}
+		builder.WriteString(section)
+	}
+	return builder.String()
+}
+
+// jobSummaryBudget returns how many bytes of the termination message the job
+// summary may use, which it shares with the test report if both are collected.
+func jobSummaryBudget(collectsTestReports bool) int {
+	if collectsTestReports {
+		return testreports.MaxSummaryBytes / 2
+	}
+	return testreports.MaxSummaryBytes
+}
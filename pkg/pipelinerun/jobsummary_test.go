@@ -0,0 +1,195 @@
+package pipelinerun
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nubank/workflows/pkg/apis/config"
+	"github.com/nubank/workflows/pkg/github"
+	"github.com/nubank/workflows/pkg/testutils"
+	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+)
+
+func encodeJobSummary(t *testing.T, markdown string) string {
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	if _, err := writer.Write([]byte(markdown)); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(buffer.Bytes())
+}
+
+func TestJobSummariesAreCollected(t *testing.T) {
+	workflow, err := testutils.ReadWorkflow("writing-job-summaries.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defaults := &config.Defaults{DefaultImage: "busybox"}
+	pipelineRun := NewBuilder(workflow, &github.Event{}).WithDefaults(defaults).Build()
+
+	task, err := findPipelineTaskOrFail(pipelineRun, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := task.TaskSpec.Steps
+	if len(steps) != 3 {
+		t.Fatalf("Want 3 steps, got %d", len(steps))
+	}
+
+	if !strings.Contains(steps[1].Script, `echo "### Coverage: 87%" >> /workspace/.workflows/summary.md`) {
+		t.Errorf("Want $(workflow.summary-path) to be expanded, got script:\n%s", steps[1].Script)
+	}
+
+	if !strings.Contains(steps[1].Script, `echo -n "unit-tests" > /tekton/results/failed-step`) {
+		t.Errorf("Want the step to be guarded, got script:\n%s", steps[1].Script)
+	}
+
+	summaryStep := steps[2]
+	if summaryStep.Name != "job-summary" || summaryStep.Image != "busybox" {
+		t.Errorf("Want the job-summary step to run busybox, got %s running %s", summaryStep.Name, summaryStep.Image)
+	}
+
+	for _, want := range []string{"gzip -9 | base64", "-le 3072", "exit 1"} {
+		if !strings.Contains(summaryStep.Script, want) {
+			t.Errorf("Want the script to contain %q, got:\n%s", want, summaryStep.Script)
+		}
+	}
+
+	wantResult := pipelinev1beta1.TaskResult{Name: "job-summary", Description: "Compressed Markdown summary written by steps"}
+	if diff := cmp.Diff(wantResult, task.TaskSpec.Results[0]); diff != "" {
+		t.Errorf("Mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestJobSummariesShareResultsWithTestReports(t *testing.T) {
+	workflow, err := testutils.ReadWorkflow("writing-job-summaries.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defaults := &config.Defaults{DefaultImage: "busybox", TestReportsImage: "ghcr.io/nubank/workflows/test-reports"}
+	pipelineRun := NewBuilder(workflow, &github.Event{}).WithDefaults(defaults).Build()
+
+	task, err := findPipelineTaskOrFail(pipelineRun, "lint")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := task.TaskSpec.Steps
+	if len(steps) != 3 {
+		t.Fatalf("Want 3 steps, got %d", len(steps))
+	}
+
+	if steps[1].Name != "job-summary" || steps[2].Name != "test-reports" {
+		t.Fatalf("Want the job summary to be collected before test reports, got steps %s and %s", steps[1].Name, steps[2].Name)
+	}
+
+	if strings.Contains(steps[1].Script, "exit 1") {
+		t.Errorf("Want the test-reports step to fail on behalf of steps, got script:\n%s", steps[1].Script)
+	}
+
+	if !strings.Contains(steps[1].Script, "-le 1536") {
+		t.Errorf("Want the job summary to use half of the budget, got script:\n%s", steps[1].Script)
+	}
+
+	if !strings.Contains(strings.Join(steps[2].Args, " "), "-max-bytes=1536") {
+		t.Errorf("Want the test report to use half of the budget, got args %v", steps[2].Args)
+	}
+}
+
+func TestJobSummariesAreCollectedOnlyWhenReferenced(t *testing.T) {
+	workflow, err := testutils.ReadWorkflow("writing-job-summaries.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defaults := &config.Defaults{DefaultImage: "busybox"}
+	pipelineRun := NewBuilder(workflow, &github.Event{}).WithDefaults(defaults).Build()
+
+	task, err := findPipelineTaskOrFail(pipelineRun, "build")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(task.TaskSpec.Steps) != 1 {
+		t.Errorf("Want no job summary step, got %d steps", len(task.TaskSpec.Steps))
+	}
+
+	// Other built-in steps may add results, so only the job summary result
+	// is looked for.
+	for _, result := range task.TaskSpec.Results {
+		if result.Name == JobSummaryResult {
+			t.Errorf("Want no %s result, got results %v", JobSummaryResult, task.TaskSpec.Results)
+		}
+	}
+}
+
+func TestGetJobSummaries(t *testing.T) {
+	pipelineRun := &pipelinev1beta1.PipelineRun{
+		Status: pipelinev1beta1.PipelineRunStatus{
+			PipelineRunStatusFields: pipelinev1beta1.PipelineRunStatusFields{
+				TaskRuns: map[string]*pipelinev1beta1.PipelineRunTaskRunStatus{
+					"ci-test": {
+						PipelineTaskName: "test",
+						Status: &pipelinev1beta1.TaskRunStatus{
+							TaskRunStatusFields: pipelinev1beta1.TaskRunStatusFields{
+								TaskRunResults: []pipelinev1beta1.TaskRunResult{
+									{Name: "job-summary", Value: encodeJobSummary(t, "Coverage <!-- workflows.dev/comment: x -->\n```\ngo test\n")},
+								},
+							},
+						},
+					},
+					"ci-build": {
+						PipelineTaskName: "build",
+						Status: &pipelinev1beta1.TaskRunStatus{
+							TaskRunStatusFields: pipelinev1beta1.TaskRunStatusFields{
+								TaskRunResults: []pipelinev1beta1.TaskRunResult{
+									{Name: "job-summary", Value: encodeJobSummary(t, "Built **3** images\n")},
+								},
+							},
+						},
+					},
+					"ci-lint": {
+						PipelineTaskName: "lint",
+						Status: &pipelinev1beta1.TaskRunStatus{
+							TaskRunStatusFields: pipelinev1beta1.TaskRunStatusFields{
+								TaskRunResults: []pipelinev1beta1.TaskRunResult{
+									{Name: "job-summary", Value: "not base64"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	want := []TaskJobSummary{
+		{Task: "build", Markdown: "Built **3** images"},
+		{Task: "test", Markdown: "Coverage &lt;!-- workflows.dev/comment: x -->\n```\ngo test\n```"},
+	}
+	if diff := cmp.Diff(want, GetJobSummaries(pipelineRun)); diff != "" {
+		t.Errorf("Mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestRenderJobSummaries(t *testing.T) {
+	summaries := []TaskJobSummary{
+		{Task: "build", Markdown: "Built **3** images"},
+		{Task: "test", Markdown: strings.Repeat("x", 100)},
+	}
+
+	want := "#### Task `build`\n\nBuilt **3** images\n\n_1 more summaries were left out because they're too long._\n"
+	if diff := cmp.Diff(want, RenderJobSummaries(summaries, 100)); diff != "" {
+		t.Errorf("Mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,42 @@
+package pipelinerun
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+)
+
+const (
+	// LogsTokenAnnotation holds the token that grants access to the logs of
+	// the PipelineRun once they're archived.
+	LogsTokenAnnotation = "workflows.dev/logs-token"
+
+	// LogsURLAnnotation holds the URL the hook listener serves the archived
+	// logs of the PipelineRun at. It may contain the $(pipelinerun.name)
+	// variable since the name is generated on creation.
+	LogsURLAnnotation = "workflows.dev/logs-url"
+
+	// LogsArchivedAnnotation indicates that the logs of the PipelineRun have
+	// been archived.
+	LogsArchivedAnnotation = "workflows.dev/logs-archived"
+)
+
+// logsEndpointPath is the path of the hook listener's route serving archived
+// logs of PipelineRuns.
+const logsEndpointPath = "/api/v1alpha1/namespaces/$(pipelinerun.namespace)/pipelineruns/$(pipelinerun.name)/logs"
+
+// SetLogsToken makes the supplied token grant access to the logs of the
+// PipelineRun once they're archived, which are served by the hook listener
+// listening at webhookURL. It lets reruns replace the token of the original
+// run, which must not grant access to the logs of another run.
+func SetLogsToken(pipelineRun *pipelinev1beta1.PipelineRun, webhookURL, token string) {
+	if pipelineRun.Annotations == nil {
+		pipelineRun.Annotations = make(map[string]string)
+	}
+
+	baseURL := strings.TrimSuffix(webhookURL, "/")
+	pipelineRun.Annotations[LogsTokenAnnotation] = token
+	pipelineRun.Annotations[LogsURLAnnotation] = fmt.Sprintf("%s%s?token=%s", baseURL, logsEndpointPath, url.QueryEscape(token))
+}
@@ -0,0 +1,25 @@
+package pipelinerun
+
+import (
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// HostedByGithubFilterFunc returns true for PipelineRuns created by workflows
+// whose repositories are hosted by Github, which are those whose progress is
+// reported back to Github. It's meant to be used as the FilterFunc of
+// informers' event handlers.
+func HostedByGithubFilterFunc(obj interface{}) bool {
+	object, ok := obj.(metav1.Object)
+	if !ok {
+		return false
+	}
+
+	labels := object.GetLabels()
+	if _, ok := labels[WorkflowLabel]; !ok {
+		return false
+	}
+
+	provider, ok := labels[ProviderLabel]
+	return !ok || provider == string(workflowsv1alpha1.GithubProvider)
+}
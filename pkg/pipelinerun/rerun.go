@@ -0,0 +1,76 @@
+package pipelinerun
+
+import (
+	"fmt"
+
+	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RerunOfAnnotation holds the name of the PipelineRun a rerun was created
+// from.
+const RerunOfAnnotation = "workflows.dev/rerun-of"
+
+// reportingAnnotations track what has already been reported to Github about
+// a PipelineRun, so they must not be carried over to reruns.
+var reportingAnnotations = []string{
+	CheckRunIDAnnotation,
+	CheckRunConclusionAnnotation,
+	CommitStatusStateAnnotation,
+	PullRequestCommentPostedAnnotation,
+	DeploymentsAnnotation,
+	LogsArchivedAnnotation,
+}
+
+// perRunAnnotations hold Secrets and tokens issued to a single PipelineRun.
+// Reruns are issued their own, since those of the original run may have
+// expired or must keep granting access to the original run only.
+var perRunAnnotations = []string{
+	CheckoutTokenSecretAnnotation,
+	LogsTokenAnnotation,
+	LogsURLAnnotation,
+}
+
+// NewRerun returns a new PipelineRun that runs the same workflow against the
+// same commit and event context as the supplied PipelineRun. Labels and
+// annotations describing the original event are kept, whereas those tracking
+// what was reported to Github are dropped so the rerun is reported afresh.
+// Checkout tokens and logs tokens of the original run are dropped too: callers
+// must issue new ones with SetCheckoutTokenSecret and SetLogsToken.
+func NewRerun(original *pipelinev1beta1.PipelineRun) *pipelinev1beta1.PipelineRun {
+	generateName := original.GetGenerateName()
+	if generateName == "" {
+		generateName = fmt.Sprintf("%s-", original.GetName())
+	}
+
+	labels := make(map[string]string)
+	for key, value := range original.GetLabels() {
+		labels[key] = value
+	}
+
+	annotations := make(map[string]string)
+	for key, value := range original.GetAnnotations() {
+		annotations[key] = value
+	}
+	for _, key := range reportingAnnotations {
+		delete(annotations, key)
+	}
+	for _, key := range perRunAnnotations {
+		delete(annotations, key)
+	}
+	annotations[RerunOfAnnotation] = original.GetName()
+
+	spec := original.Spec.DeepCopy()
+	// Do not inherit the cancellation of the original run.
+	spec.Status = ""
+
+	return &pipelinev1beta1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: generateName,
+			Namespace:    original.GetNamespace(),
+			Labels:       labels,
+			Annotations:  annotations,
+		},
+		Spec: *spec,
+	}
+}
@@ -0,0 +1,78 @@
+package pipelinerun
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNewRerun(t *testing.T) {
+	original := &pipelinev1beta1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "ci-run-abc12",
+			GenerateName: "ci-run-",
+			Namespace:    "dev",
+			Labels: map[string]string{
+				"workflows.dev/workflow": "ci",
+			},
+			Annotations: map[string]string{
+				"workflows.dev/repository":                  "john-doe/my-repo",
+				"workflows.dev/head-commit":                 "833568e",
+				"workflows.dev/event":                       "pull_request",
+				"workflows.dev/delivery-id":                 "123",
+				"workflows.dev/pull-request":                "7",
+				"workflows.dev/pull-request-comment":        "true",
+				"workflows.dev/pull-request-comment-posted": "true",
+				"workflows.dev/check-run-id":                "42",
+				"workflows.dev/check-run-conclusion":        "failure",
+				"workflows.dev/commit-status-context":       "workflows/ci",
+				"workflows.dev/commit-status-state":         "failure",
+				"workflows.dev/checkout-token-secret":       "ci-checkout-token-xyz98",
+				"workflows.dev/logs-token":                  "s3cr3t",
+				"workflows.dev/logs-url":                    "https://workflows.example.com/api/v1alpha1/namespaces/$(pipelinerun.namespace)/pipelineruns/$(pipelinerun.name)/logs?token=s3cr3t",
+				"workflows.dev/logs-archived":               "true",
+			},
+		},
+		Spec: pipelinev1beta1.PipelineRunSpec{
+			PipelineSpec: &pipelinev1beta1.PipelineSpec{
+				Tasks: []pipelinev1beta1.PipelineTask{{Name: "test"}},
+			},
+			Status: pipelinev1beta1.PipelineRunSpecStatusCancelled,
+		},
+	}
+
+	want := &pipelinev1beta1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{GenerateName: "ci-run-",
+			Namespace: "dev",
+			Labels: map[string]string{
+				"workflows.dev/workflow": "ci",
+			},
+			Annotations: map[string]string{
+				"workflows.dev/repository":            "john-doe/my-repo",
+				"workflows.dev/head-commit":           "833568e",
+				"workflows.dev/event":                 "pull_request",
+				"workflows.dev/delivery-id":           "123",
+				"workflows.dev/pull-request":          "7",
+				"workflows.dev/pull-request-comment":  "true",
+				"workflows.dev/commit-status-context": "workflows/ci",
+				"workflows.dev/rerun-of":              "ci-run-abc12",
+			},
+		},
+		Spec: pipelinev1beta1.PipelineRunSpec{
+			PipelineSpec: &pipelinev1beta1.PipelineSpec{
+				Tasks: []pipelinev1beta1.PipelineTask{{Name: "test"}},
+			},
+		},
+	}
+
+	got := NewRerun(original)
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Mismatch (-want +got):\n%s", diff)
+	}
+
+	if original.Annotations["workflows.dev/check-run-id"] != "42" {
+		t.Error("The original PipelineRun must not be modified")
+	}
+}
@@ -0,0 +1,197 @@
+package pipelinerun
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nubank/workflows/pkg/testreports"
+	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	"knative.dev/pkg/apis"
+)
+
+// TaskSummary describes the state of a single task in a PipelineRun.
+type TaskSummary struct {
+	Name        string
+	Status      string
+	Duration    string
+	FailedSteps []string
+	Done        bool
+}
+
+// SummarizeTasks returns the state of every task declared in the PipelineRun,
+// following the order in which they were declared.
+func SummarizeTasks(pipelineRun *pipelinev1beta1.PipelineRun) []TaskSummary {
+	taskRuns := make(map[string]*pipelinev1beta1.PipelineRunTaskRunStatus)
+	for _, taskRun := range pipelineRun.Status.TaskRuns {
+		taskRuns[taskRun.PipelineTaskName] = taskRun
+	}
+
+	pipelineSpec := pipelineRun.Spec.PipelineSpec
+	if pipelineSpec == nil {
+		pipelineSpec = pipelineRun.Status.PipelineSpec
+	}
+
+	summaries := make([]TaskSummary, 0)
+	if pipelineSpec == nil {
+		return summaries
+	}
+
+	for _, task := range pipelineSpec.Tasks {
+		summary := TaskSummary{Name: task.Name, Status: "Pending"}
+
+		taskRun, ok := taskRuns[task.Name]
+		switch {
+		case !ok || taskRun.Status == nil:
+			if pipelineRun.IsDone() {
+				summary.Status = "Skipped"
+				summary.Done = true
+			}
+
+		default:
+			status := taskRun.Status
+			condition := status.GetCondition(apis.ConditionSucceeded)
+			switch {
+			case condition == nil || condition.IsUnknown():
+				summary.Status = "Running"
+
+			case condition.IsTrue():
+				summary.Status = "Succeeded"
+				summary.Done = true
+
+			default:
+				summary.Status = fmt.Sprintf("Failed (%s)", condition.Reason)
+				summary.Done = true
+			}
+
+			if status.StartTime != nil && status.CompletionTime != nil {
+				summary.Duration = status.CompletionTime.Sub(status.StartTime.Time).Round(time.Second).String()
+			}
+
+			summary.FailedSteps = failedSteps(status)
+		}
+
+		summaries = append(summaries, summary)
+	}
+
+	return summaries
+}
+
+// failedSteps returns the names of the steps of a TaskRun that failed. Built-in
+// steps failing on behalf of guarded steps are replaced by the step that
+// actually failed, as recorded in the failed-step result.
+func failedSteps(status *pipelinev1beta1.TaskRunStatus) []string {
+	failedStep := ""
+	for _, result := range status.TaskRunResults {
+		if result.Name == FailedStepResult {
+			failedStep = strings.TrimSpace(result.Value)
+		}
+	}
+
+	var names []string
+	for _, step := range status.Steps {
+		if step.Terminated == nil || step.Terminated.ExitCode == 0 {
+			continue
+		}
+
+		name := step.Name
+		if failedStep != "" && (name == testReportsStepName || name == jobSummaryStepName) {
+			name = failedStep
+		}
+		if !contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// contains returns true if the supplied name is one of names.
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// RenderTaskSummaries renders the supplied summaries as a Markdown table.
+func RenderTaskSummaries(summaries []TaskSummary) string {
+	if len(summaries) == 0 {
+		return ""
+	}
+
+	var builder strings.Builder
+	builder.WriteString("| Task | Status | Duration | Failed steps |\n")
+	builder.WriteString("| --- | --- | --- | --- |\n")
+	for _, summary := range summaries {
+		fmt.Fprintf(&builder, "| %s | %s | %s | %s |\n", summary.Name, summary.Status, orDash(summary.Duration), orDash(strings.Join(summary.FailedSteps, ", ")))
+	}
+	return builder.String()
+}
+
+// orDash returns the supplied text or a dash if it's empty.
+func orDash(text string) string {
+	if text == "" {
+		return "-"
+	}
+	return text
+}
+
+// ExpandRunVariables substitutes $(pipelinerun.name) and
+// $(pipelinerun.namespace) in the supplied text. These variables can't be
+// expanded when PipelineRuns are built because their names are generated by
+// Kubernetes.
+func ExpandRunVariables(text string, pipelineRun *pipelinev1beta1.PipelineRun) string {
+	return strings.NewReplacer("$(pipelinerun.name)", pipelineRun.GetName(),
+		"$(pipelinerun.namespace)", pipelineRun.GetNamespace(),
+	).Replace(text)
+}
+
+// TaskTestReport is the summary of test reports collected in a task.
+type TaskTestReport struct {
+	Task    string
+	Summary *testreports.Summary
+}
+
+// GetTestReports returns summaries of test reports published by tasks of the
+// PipelineRun, sorted by task name. Invalid summaries are ignored.
+func GetTestReports(pipelineRun *pipelinev1beta1.PipelineRun) []TaskTestReport {
+	reports := make([]TaskTestReport, 0)
+	for _, taskRun := range pipelineRun.Status.TaskRuns {
+		if taskRun.Status == nil {
+			continue
+		}
+
+		for _, result := range taskRun.Status.TaskRunResults {
+			if result.Name != TestReportResult {
+				continue
+			}
+
+			if summary, err := testreports.Decode(result.Value); err == nil {
+				reports = append(reports, TaskTestReport{Task: taskRun.PipelineTaskName, Summary: summary})
+			}
+		}
+	}
+
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].Task < reports[j].Task
+	})
+	return reports
+}
+
+// RenderTestReports renders the supplied test reports as a Markdown table.
+func RenderTestReports(reports []TaskTestReport) string {
+	if len(reports) == 0 {
+		return ""
+	}
+
+	var builder strings.Builder
+	builder.WriteString("| Task | Passed | Failed | Skipped |\n")
+	builder.WriteString("| --- | --- | --- | --- |\n")
+	for _, report := range reports {
+		fmt.Fprintf(&builder, "| %s | %d | %d | %d |\n", report.Task, report.Summary.Passed, report.Summary.Failed, report.Summary.Skipped)
+	}
+	return builder.String()
+}
@@ -0,0 +1,125 @@
+package pipelinerun
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	"github.com/nubank/workflows/pkg/testreports"
+	"github.com/nubank/workflows/pkg/variables"
+	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// TestReportResult is the name of the task result that holds the summary
+	// of test reports collected in a task.
+	TestReportResult = "test-report"
+
+	// FailedStepResult is the name of the task result that holds the name of
+	// the first step that failed in a task whose steps are guarded. Built-in
+	// steps fail on its behalf, so it tells which step is to blame.
+	FailedStepResult = "failed-step"
+
+	// Name of the built-in step that collects test reports.
+	testReportsStepName = "test-reports"
+
+	// Directory, inside the implicit workspace shared by steps, where scripts
+	// of steps are written to when test reports or job summaries are
+	// collected.
+	stepScriptsDir = "/workspace/.workflows"
+
+	// File recording the name of the first step that failed, which is
+	// published as a task result.
+	failedStepFile = "/tekton/results/" + FailedStepResult
+
+	// Delimiter of the here-document embedding scripts of steps, which is
+	// extended until it doesn't appear in the script in question.
+	scriptDelimiter = "WORKFLOWS_STEP_SCRIPT"
+)
+
+// guardedStepScript runs the script of a step without failing the task, so
+// the built-in steps collecting test reports and job summaries still run. It
+// records the step as failed instead and skips the step altogether if a
+// previous one has failed.
+var guardedStepScript = template.Must(template.New("guarded-step").
+	Parse(`#!/usr/bin/env sh
+set -u
+if [ -f {{.FailedStepFile}} ]; then
+  echo "Skipping step {{.Name}} because step $(cat {{.FailedStepFile}}) failed"
+  exit 0
+fi
+
+mkdir -p {{.ScriptsDir}}
+cat > {{.ScriptFile}} <<'{{.Delimiter}}'
+{{.Script}}
+{{.Delimiter}}
+chmod +x {{.ScriptFile}}
+{{.ScriptFile}} || echo -n "{{.Name}}" > {{.FailedStepFile}}
+`))
+
+// collectsTestReports returns true if test reports of the supplied task must
+// be collected.
+func (b *Builder) collectsTestReports(task *workflowsv1alpha1.Task) bool {
+	return len(task.TestReports) != 0 && b.defaults.TestReportsImage != ""
+}
+
+// guardStep rewrites the script of the supplied step, the i-th of its task, so
+// that its failure doesn't prevent test reports and job summaries from being
+// collected.
+func guardStep(step pipelinev1beta1.Step, i int) pipelinev1beta1.Step {
+	name := step.Name
+	if name == "" {
+		name = fmt.Sprintf("#%d", i+1)
+	}
+
+	delimiter := scriptDelimiter
+	for strings.Contains(step.Script, delimiter) {
+		delimiter += "_"
+	}
+
+	var buffer bytes.Buffer
+	err := guardedStepScript.Execute(&buffer, map[string]string{
+		"Delimiter":      delimiter,
+		"FailedStepFile": failedStepFile,
+		"Name":           name,
+		"Script":         step.Script,
+		"ScriptFile":     fmt.Sprintf("%s/step-%d.sh", stepScriptsDir, i),
+		"ScriptsDir":     stepScriptsDir,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	step.Script = buffer.String()
+	return step
+}
+
+// buildTestReportsStep returns the built-in step that collects test reports of
+// the supplied task and fails on behalf of previous steps that failed. If the
+// task writes a job summary as well, the test report gets a smaller share of
+// the termination message.
+func (b *Builder) buildTestReportsStep(task *workflowsv1alpha1.Task, writesJobSummary bool) pipelinev1beta1.Step {
+	args := []string{
+		fmt.Sprintf("-result=/tekton/results/%s", TestReportResult),
+		fmt.Sprintf("-failed-step-file=%s", failedStepFile),
+	}
+
+	if writesJobSummary {
+		args = append(args, fmt.Sprintf("-max-bytes=%d", testreports.MaxSummaryBytes-jobSummaryBudget(true)))
+	}
+
+	for _, glob := range task.TestReports {
+		args = append(args, variables.Expand(glob, b.replacements))
+	}
+
+	return pipelinev1beta1.Step{
+		Container: corev1.Container{
+			Name:  testReportsStepName,
+			Image: b.defaults.TestReportsImage,
+			Args:  args,
+		},
+	}
+}
@@ -0,0 +1,160 @@
+package pipelinerun
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nubank/workflows/pkg/apis/config"
+	"github.com/nubank/workflows/pkg/github"
+	"github.com/nubank/workflows/pkg/testutils"
+	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"knative.dev/pkg/apis"
+	duckv1beta1 "knative.dev/pkg/apis/duck/v1beta1"
+)
+
+func TestTestReportsAreCollected(t *testing.T) {
+	workflow, err := testutils.ReadWorkflow("collecting-test-reports.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defaults := &config.Defaults{TestReportsImage: "ghcr.io/nubank/workflows/test-reports"}
+	pipelineRun := NewBuilder(workflow, &github.Event{}).WithDefaults(defaults).Build()
+
+	task, err := findPipelineTaskOrFail(pipelineRun, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := task.TaskSpec.Steps
+	if len(steps) != 4 {
+		t.Fatalf("Want 4 steps, got %d", len(steps))
+	}
+
+	if strings.Contains(steps[0].Script, failedStepFile) {
+		t.Error("Want the checkout step to be left untouched")
+	}
+
+	wantScript := `#!/usr/bin/env sh
+set -u
+if [ -f /tekton/results/failed-step ]; then
+  echo "Skipping step unit-tests because step $(cat /tekton/results/failed-step) failed"
+  exit 0
+fi
+
+mkdir -p /workspace/.workflows
+cat > /workspace/.workflows/step-1.sh <<'WORKFLOWS_STEP_SCRIPT'
+#!/usr/bin/env sh
+set -eu
+make test
+WORKFLOWS_STEP_SCRIPT
+chmod +x /workspace/.workflows/step-1.sh
+/workspace/.workflows/step-1.sh || echo -n "unit-tests" > /tekton/results/failed-step
+`
+	if diff := cmp.Diff(wantScript, steps[1].Script); diff != "" {
+		t.Errorf("Mismatch (-want +got):\n%s", diff)
+	}
+
+	if !strings.Contains(steps[2].Script, `echo -n "#3" > /tekton/results/failed-step`) {
+		t.Errorf("Want unnamed steps to be referred to by their position, got script:\n%s", steps[2].Script)
+	}
+
+	wantStep := pipelinev1beta1.Step{
+		Container: corev1.Container{
+			Name:  "test-reports",
+			Image: "ghcr.io/nubank/workflows/test-reports",
+			Args: []string{"-result=/tekton/results/test-report",
+				"-failed-step-file=/tekton/results/failed-step",
+				"build/test-results/**/*.xml",
+				"reports/ci-*.xml",
+			},
+			WorkingDir: "$(workspaces.projects.path)/my-repo",
+		},
+	}
+	if diff := cmp.Diff(wantStep, steps[3]); diff != "" {
+		t.Errorf("Mismatch (-want +got):\n%s", diff)
+	}
+
+	for _, name := range []string{"failed-step", "test-report"} {
+		found := false
+		for _, result := range task.TaskSpec.Results {
+			if result.Name == name {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Want the task to declare the %s result, got %v", name, task.TaskSpec.Results)
+		}
+	}
+}
+
+func TestGuardedScriptsCanContainTheHereDocumentDelimiter(t *testing.T) {
+	step := pipelinev1beta1.Step{Script: "#!/usr/bin/env sh\ncat <<'WORKFLOWS_STEP_SCRIPT'\n## Summary\nWORKFLOWS_STEP_SCRIPT\n"}
+
+	script := guardStep(step, 0).Script
+	if !strings.Contains(script, "<<'WORKFLOWS_STEP_SCRIPT_'\n") || !strings.Contains(script, "\nWORKFLOWS_STEP_SCRIPT_\n") {
+		t.Errorf("Want the here-document to be delimited by WORKFLOWS_STEP_SCRIPT_, got script:\n%s", script)
+	}
+
+	if !strings.Contains(script, step.Script) {
+		t.Errorf("Want the script to be embedded as is, got script:\n%s", script)
+	}
+}
+
+func TestTestReportsAreIgnoredWithoutImage(t *testing.T) {
+	workflow, err := testutils.ReadWorkflow("collecting-test-reports.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pipelineRun := NewBuilder(workflow, &github.Event{}).Build()
+
+	task, err := findPipelineTaskOrFail(pipelineRun, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(task.TaskSpec.Steps) != 3 {
+		t.Errorf("Want 3 steps, got %d", len(task.TaskSpec.Steps))
+	}
+}
+
+func TestSummarizeTasksBlamesTheStepThatFailed(t *testing.T) {
+	pipelineRun := &pipelinev1beta1.PipelineRun{
+		Spec: pipelinev1beta1.PipelineRunSpec{
+			PipelineSpec: &pipelinev1beta1.PipelineSpec{
+				Tasks: []pipelinev1beta1.PipelineTask{{Name: "test"}},
+			},
+		},
+	}
+
+	status := &pipelinev1beta1.TaskRunStatus{
+		Status: duckv1beta1.Status{
+			Conditions: duckv1beta1.Conditions{{Type: apis.ConditionSucceeded, Status: corev1.ConditionFalse, Reason: "Failed"}},
+		},
+	}
+	// Guarded steps always succeed and the built-in steps fail on their behalf.
+	status.Steps = []pipelinev1beta1.StepState{
+		{Name: "unit-tests", ContainerState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 0}}},
+		{Name: "job-summary", ContainerState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 0}}},
+		{Name: "test-reports", ContainerState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 1}}},
+	}
+	status.TaskRunResults = []pipelinev1beta1.TaskRunResult{
+		{Name: "failed-step", Value: "unit-tests"},
+	}
+
+	pipelineRun.Status.TaskRuns = map[string]*pipelinev1beta1.PipelineRunTaskRunStatus{
+		"ci-run-abc12-test": {PipelineTaskName: "test", Status: status},
+	}
+
+	summaries := SummarizeTasks(pipelineRun)
+	if len(summaries) != 1 {
+		t.Fatalf("Want 1 summary, got %d", len(summaries))
+	}
+
+	if diff := cmp.Diff([]string{"unit-tests"}, summaries[0].FailedSteps); diff != "" {
+		t.Errorf("Mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,276 @@
+package checkrun
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/pkg/apis"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/logging"
+
+	"github.com/nubank/workflows/pkg/github"
+	"github.com/nubank/workflows/pkg/pipelinerun"
+	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	tektonclientset "github.com/tektoncd/pipeline/pkg/client/clientset/versioned"
+	listers "github.com/tektoncd/pipeline/pkg/client/listers/pipeline/v1beta1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Reconciler implements controller.Reconciler for PipelineRuns created by
+// workflows. It keeps one Github check run per PipelineRun in sync with the
+// run's progress.
+type Reconciler struct {
+
+	// checkRuns allows us to create and update Github check runs.
+	checkRuns github.CheckRunReconciler
+
+	// kubeClientSet allows us to read Secrets holding Github credentials.
+	kubeClientSet kubernetes.Interface
+
+	// tektonClientSet allows us to annotate PipelineRuns.
+	tektonClientSet tektonclientset.Interface
+
+	// pipelineRunLister indexes PipelineRun objects.
+	pipelineRunLister listers.PipelineRunLister
+}
+
+// Check that our Reconciler implements controller.Reconciler.
+var _ controller.Reconciler = (*Reconciler)(nil)
+
+// Reconcile implements controller.Reconciler.
+func (r *Reconciler) Reconcile(ctx context.Context, key string) error {
+	logger := logging.FromContext(ctx)
+
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		logger.Errorf("Invalid resource key: %s", key)
+		return nil
+	}
+
+	pipelineRun, err := r.pipelineRunLister.PipelineRuns(namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		logger.Debugf("PipelineRun %s no longer exists", key)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if _, reported := pipelineRun.Annotations[pipelinerun.CheckRunConclusionAnnotation]; reported {
+		// The final state of the PipelineRun has already been reported.
+		return nil
+	}
+
+	ctx, err = pipelinerun.WithGithubCredentials(ctx, r.kubeClientSet, pipelineRun)
+	if err != nil {
+		return err
+	}
+
+	// Only Github Apps can write check runs. The progress of PipelineRuns
+	// reported with a token is left to commit statuses.
+	if credentials := github.GetCredentials(ctx); credentials != nil && credentials.Token != "" {
+		logger.Debugf("Skipping PipelineRun %s: check runs can't be written with the token held by %s", key, credentials)
+		return nil
+	}
+
+	checkRun, err := makeCheckRun(pipelineRun)
+	if err != nil {
+		logger.Infof("Skipping PipelineRun %s: %v", key, err)
+		return nil
+	}
+
+	id, err := r.checkRuns.ReconcileCheckRun(ctx, checkRun)
+	if err != nil {
+		return err
+	}
+
+	annotations := make(map[string]string)
+	if checkRun.ID != id {
+		annotations[pipelinerun.CheckRunIDAnnotation] = strconv.FormatInt(id, 10)
+	}
+
+	if checkRun.Status == github.CheckRunCompleted {
+		annotations[pipelinerun.CheckRunConclusionAnnotation] = checkRun.Conclusion
+	}
+
+	return pipelinerun.Annotate(ctx, r.tektonClientSet, pipelineRun, annotations)
+}
+
+// makeCheckRun returns the desired state of the check run associated to the
+// supplied PipelineRun. It returns an error if the PipelineRun lacks the
+// information needed to report its progress to Github.
+func makeCheckRun(pipelineRun *pipelinev1beta1.PipelineRun) (*github.CheckRun, error) {
+	annotations := pipelineRun.GetAnnotations()
+
+	repo := strings.SplitN(annotations[pipelinerun.RepositoryAnnotation], "/", 2)
+	if len(repo) != 2 || repo[0] == "" || repo[1] == "" {
+		return nil, fmt.Errorf("missing or invalid annotation %s", pipelinerun.RepositoryAnnotation)
+	}
+
+	headSHA := annotations[pipelinerun.HeadCommitAnnotation]
+	if headSHA == "" {
+		return nil, fmt.Errorf("missing annotation %s", pipelinerun.HeadCommitAnnotation)
+	}
+
+	checkRun := &github.CheckRun{Owner: repo[0],
+		Repo:       repo[1],
+		Name:       pipelineRun.GetLabels()[pipelinerun.WorkflowLabel],
+		HeadSHA:    headSHA,
+		ExternalID: fmt.Sprintf("%s/%s", pipelineRun.GetNamespace(), pipelineRun.GetName()),
+		DetailsURL: pipelinerun.ExpandRunVariables(annotations[pipelinerun.LogsURLAnnotation], pipelineRun),
+	}
+
+	if id, ok := annotations[pipelinerun.CheckRunIDAnnotation]; ok {
+		parsedID, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid annotation %s: %w", pipelinerun.CheckRunIDAnnotation, err)
+		}
+		checkRun.ID = parsedID
+	}
+
+	tasks := pipelinerun.SummarizeTasks(pipelineRun)
+	completed := 0
+	for _, task := range tasks {
+		if task.Done {
+			completed++
+		}
+	}
+
+	condition := pipelineRun.Status.GetCondition(apis.ConditionSucceeded)
+
+	switch {
+	case pipelineRun.IsDone():
+		checkRun.Status = github.CheckRunCompleted
+		checkRun.CompletedAt = getCompletionTime(pipelineRun)
+		checkRun.Conclusion = conclusion(condition)
+		checkRun.Title = titles[checkRun.Conclusion]
+
+	case pipelineRun.HasStarted():
+		checkRun.Status = github.CheckRunInProgress
+		checkRun.Title = fmt.Sprintf("%d of %d tasks completed", completed, len(tasks))
+
+	default:
+		checkRun.Status = github.CheckRunQueued
+		checkRun.Title = "Waiting for the workflow to start"
+	}
+
+	if pipelineRun.HasStarted() {
+		checkRun.StartedAt = &pipelineRun.Status.StartTime.Time
+	}
+
+	checkRun.Summary = fmt.Sprintf("PipelineRun `%s`", checkRun.ExternalID)
+	if condition != nil && condition.Message != "" {
+		checkRun.Summary = fmt.Sprintf("%s: %s", checkRun.Summary, condition.Message)
+	}
+
+	checkRun.Text = pipelinerun.RenderTaskSummaries(tasks)
+
+	if reports := pipelinerun.GetTestReports(pipelineRun); len(reports) != 0 {
+		checkRun.Text = fmt.Sprintf("%s\n### Tests\n\n%s", checkRun.Text, pipelinerun.RenderTestReports(reports))
+
+		// Github appends annotations on every update, so only send them
+		// along with the final state.
+		if checkRun.Status == github.CheckRunCompleted {
+			checkRun.Annotations = makeAnnotations(reports)
+		}
+	}
+
+	if summaries := pipelinerun.GetJobSummaries(pipelineRun); len(summaries) != 0 {
+		checkRun.Text = fmt.Sprintf("%s\n### Summary\n\n%s", checkRun.Text, pipelinerun.RenderJobSummaries(summaries, pipelinerun.MaxJobSummariesBytes))
+	}
+
+	checkRun.Text = truncateText(checkRun.Text, github.MaxCheckRunTextLength)
+	return checkRun, nil
+}
+
+// textTruncatedNotice is appended to texts of check runs that had to be
+// truncated.
+const textTruncatedNotice = "\n\n_Output truncated to fit in the space available for check runs._"
+
+// truncateText cuts the supplied text down to maxLength characters, leaving
+// room for a notice saying it was truncated.
+func truncateText(text string, maxLength int) string {
+	if utf8.RuneCountInString(text) <= maxLength {
+		return text
+	}
+
+	runes := []rune(text)
+	return string(runes[:maxLength-utf8.RuneCountInString(textTruncatedNotice)]) + textTruncatedNotice
+}
+
+// makeAnnotations turns failed tests that could be located in the repository
+// into check run annotations.
+func makeAnnotations(reports []pipelinerun.TaskTestReport) []github.CheckRunAnnotation {
+	annotations := make([]github.CheckRunAnnotation, 0)
+	for _, report := range reports {
+		for _, failure := range report.Summary.Failures {
+			if failure.File == "" {
+				continue
+			}
+
+			if len(annotations) == github.MaxCheckRunAnnotations {
+				return annotations
+			}
+
+			line := failure.Line
+			if line == 0 {
+				line = 1
+			}
+
+			message := failure.Message
+			if message == "" {
+				message = "Test failed"
+			}
+
+			annotations = append(annotations, github.CheckRunAnnotation{Path: failure.File,
+				StartLine: line,
+				EndLine:   line,
+				Level:     github.CheckRunAnnotationFailure,
+				Title:     fmt.Sprintf("%s (task %s)", failure.Name, report.Task),
+				Message:   message,
+			})
+		}
+	}
+	return annotations
+}
+
+// titles maps conclusions of check runs to human readable titles.
+var titles = map[string]string{
+	github.CheckRunSuccess:   "Workflow succeeded",
+	github.CheckRunFailure:   "Workflow failed",
+	github.CheckRunCancelled: "Workflow cancelled",
+	github.CheckRunTimedOut:  "Workflow timed out",
+}
+
+// conclusion returns the conclusion of a check run based on the Succeeded
+// condition of a PipelineRun that is done.
+func conclusion(condition *apis.Condition) string {
+	if condition.IsTrue() {
+		return github.CheckRunSuccess
+	}
+
+	switch condition.Reason {
+	case pipelinev1beta1.PipelineRunReasonCancelled.String():
+		return github.CheckRunCancelled
+
+	case pipelinev1beta1.PipelineRunReasonTimedOut.String():
+		return github.CheckRunTimedOut
+
+	default:
+		return github.CheckRunFailure
+	}
+}
+
+// getCompletionTime returns the time the PipelineRun has finished.
+func getCompletionTime(pipelineRun *pipelinev1beta1.PipelineRun) *time.Time {
+	if pipelineRun.Status.CompletionTime != nil {
+		return &pipelineRun.Status.CompletionTime.Time
+	}
+	now := time.Now()
+	return &now
+}
@@ -0,0 +1,448 @@
+package checkrun
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nubank/workflows/pkg/github"
+	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	faketektonclientset "github.com/tektoncd/pipeline/pkg/client/clientset/versioned/fake"
+	listers "github.com/tektoncd/pipeline/pkg/client/listers/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakekubeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/pkg/apis"
+	duckv1beta1 "knative.dev/pkg/apis/duck/v1beta1"
+)
+
+var (
+	startTime      = metav1.NewTime(time.Date(2021, 1, 20, 10, 0, 0, 0, time.UTC))
+	completionTime = metav1.NewTime(time.Date(2021, 1, 20, 10, 1, 30, 0, time.UTC))
+)
+
+// fakeCheckRunReconciler records check runs instead of sending them to
+// Github.
+type fakeCheckRunReconciler struct {
+	id        int64
+	checkRuns []*github.CheckRun
+}
+
+// ReconcileCheckRun implements github.CheckRunReconciler.
+func (f *fakeCheckRunReconciler) ReconcileCheckRun(ctx context.Context, checkRun *github.CheckRun) (int64, error) {
+	f.checkRuns = append(f.checkRuns, checkRun)
+	return f.id, nil
+}
+
+func newPipelineRun() *pipelinev1beta1.PipelineRun {
+	return &pipelinev1beta1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "ci-run-abc12",
+			Namespace: "dev",
+			Labels: map[string]string{
+				"workflows.dev/workflow": "ci",
+			},
+			Annotations: map[string]string{
+				"workflows.dev/repository":  "john-doe/my-repo",
+				"workflows.dev/head-commit": "833568e",
+			},
+		},
+		Spec: pipelinev1beta1.PipelineRunSpec{
+			PipelineSpec: &pipelinev1beta1.PipelineSpec{
+				Tasks: []pipelinev1beta1.PipelineTask{
+					{Name: "lint"},
+					{Name: "test"},
+				},
+			},
+		},
+	}
+}
+
+func taskRunStatus(status corev1.ConditionStatus, reason string, done bool) *pipelinev1beta1.PipelineRunTaskRunStatus {
+	taskRunStatus := &pipelinev1beta1.TaskRunStatus{
+		Status: duckv1beta1.Status{
+			Conditions: duckv1beta1.Conditions{{Type: apis.ConditionSucceeded,
+				Status: status,
+				Reason: reason,
+			}},
+		},
+	}
+	taskRunStatus.StartTime = &startTime
+	if done {
+		taskRunStatus.CompletionTime = &completionTime
+	}
+
+	return &pipelinev1beta1.PipelineRunTaskRunStatus{Status: taskRunStatus}
+}
+
+func TestMakeCheckRunForQueuedPipelineRuns(t *testing.T) {
+	got, err := makeCheckRun(newPipelineRun())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := &github.CheckRun{Owner: "john-doe",
+		Repo:       "my-repo",
+		Name:       "ci",
+		HeadSHA:    "833568e",
+		ExternalID: "dev/ci-run-abc12",
+		Status:     github.CheckRunQueued,
+		Title:      "Waiting for the workflow to start",
+		Summary:    "PipelineRun `dev/ci-run-abc12`",
+		Text: `| Task | Status | Duration | Failed steps |
+| --- | --- | --- | --- |
+| lint | Pending | - | - |
+| test | Pending | - | - |
+`,
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestMakeCheckRunForRunningPipelineRuns(t *testing.T) {
+	pipelineRun := newPipelineRun()
+	pipelineRun.Annotations["workflows.dev/check-run-id"] = "42"
+	pipelineRun.Status.StartTime = &startTime
+	pipelineRun.Status.SetCondition(&apis.Condition{Type: apis.ConditionSucceeded,
+		Status:  corev1.ConditionUnknown,
+		Reason:  "Running",
+		Message: "Tasks Completed: 1 (Failed: 0, Cancelled 0), Incomplete: 1, Skipped: 0",
+	})
+	pipelineRun.Status.TaskRuns = map[string]*pipelinev1beta1.PipelineRunTaskRunStatus{
+		"ci-run-abc12-lint": taskRunStatus(corev1.ConditionTrue, "Succeeded", true),
+		"ci-run-abc12-test": taskRunStatus(corev1.ConditionUnknown, "Running", false),
+	}
+	pipelineRun.Status.TaskRuns["ci-run-abc12-lint"].PipelineTaskName = "lint"
+	pipelineRun.Status.TaskRuns["ci-run-abc12-test"].PipelineTaskName = "test"
+
+	got, err := makeCheckRun(pipelineRun)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := &github.CheckRun{ID: 42,
+		Owner:      "john-doe",
+		Repo:       "my-repo",
+		Name:       "ci",
+		HeadSHA:    "833568e",
+		ExternalID: "dev/ci-run-abc12",
+		Status:     github.CheckRunInProgress,
+		StartedAt:  &startTime.Time,
+		Title:      "1 of 2 tasks completed",
+		Summary:    "PipelineRun `dev/ci-run-abc12`: Tasks Completed: 1 (Failed: 0, Cancelled 0), Incomplete: 1, Skipped: 0",
+		Text: `| Task | Status | Duration | Failed steps |
+| --- | --- | --- | --- |
+| lint | Succeeded | 1m30s | - |
+| test | Running | - | - |
+`,
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestMakeCheckRunForCompletedPipelineRuns(t *testing.T) {
+	tests := []struct {
+		status         corev1.ConditionStatus
+		reason         string
+		wantConclusion string
+		wantTitle      string
+	}{
+		{corev1.ConditionTrue, "Succeeded", github.CheckRunSuccess, "Workflow succeeded"},
+		{corev1.ConditionFalse, "Failed", github.CheckRunFailure, "Workflow failed"},
+		{corev1.ConditionFalse, "Cancelled", github.CheckRunCancelled, "Workflow cancelled"},
+		{corev1.ConditionFalse, "PipelineRunTimeout", github.CheckRunTimedOut, "Workflow timed out"},
+	}
+
+	for _, test := range tests {
+		pipelineRun := newPipelineRun()
+		pipelineRun.Status.StartTime = &startTime
+		pipelineRun.Status.CompletionTime = &completionTime
+		pipelineRun.Status.SetCondition(&apis.Condition{Type: apis.ConditionSucceeded,
+			Status: test.status,
+			Reason: test.reason,
+		})
+
+		got, err := makeCheckRun(pipelineRun)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if got.Status != github.CheckRunCompleted {
+			t.Errorf("Want status %s, got %s", github.CheckRunCompleted, got.Status)
+		}
+
+		if test.wantConclusion != got.Conclusion {
+			t.Errorf("Want conclusion %s, got %s", test.wantConclusion, got.Conclusion)
+		}
+
+		if test.wantTitle != got.Title {
+			t.Errorf("Want title %s, got %s", test.wantTitle, got.Title)
+		}
+
+		if !got.CompletedAt.Equal(completionTime.Time) {
+			t.Errorf("Want completion time %s, got %s", completionTime, got.CompletedAt)
+		}
+	}
+}
+
+func withTestReport(taskRun *pipelinev1beta1.PipelineRunTaskRunStatus, taskName, report string) *pipelinev1beta1.PipelineRunTaskRunStatus {
+	taskRun.PipelineTaskName = taskName
+	taskRun.Status.TaskRunResults = []pipelinev1beta1.TaskRunResult{{Name: "test-report", Value: report}}
+	return taskRun
+}
+
+func TestMakeCheckRunReportsTestResults(t *testing.T) {
+	pipelineRun := newPipelineRun()
+	pipelineRun.Status.StartTime = &startTime
+	pipelineRun.Status.CompletionTime = &completionTime
+	pipelineRun.Status.SetCondition(&apis.Condition{Type: apis.ConditionSucceeded,
+		Status: corev1.ConditionFalse,
+		Reason: "Failed",
+	})
+	pipelineRun.Status.TaskRuns = map[string]*pipelinev1beta1.PipelineRunTaskRunStatus{
+		"ci-run-abc12-lint": withTestReport(taskRunStatus(corev1.ConditionTrue, "Succeeded", true), "lint", `{"passed":3,"failed":0,"skipped":0}`),
+		"ci-run-abc12-test": withTestReport(taskRunStatus(corev1.ConditionFalse, "Failed", true), "test",
+			`{"passed":40,"failed":3,"skipped":2,"failures":[{"name":"parser.TestParse","file":"pkg/parser_test.go","line":27,"message":"expected 2, got 1"},{"name":"parser.TestLex","file":"pkg/lexer.go"},{"name":"TestUnlocated","message":"boom"}]}`),
+	}
+
+	got, err := makeCheckRun(pipelineRun)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	wantText := `| Task | Status | Duration | Failed steps |
+| --- | --- | --- | --- |
+| lint | Succeeded | 1m30s | - |
+| test | Failed (Failed) | 1m30s | - |
+
+### Tests
+
+| Task | Passed | Failed | Skipped |
+| --- | --- | --- | --- |
+| lint | 3 | 0 | 0 |
+| test | 40 | 3 | 2 |
+`
+	if diff := cmp.Diff(wantText, got.Text); diff != "" {
+		t.Errorf("Mismatch (-want +got):\n%s", diff)
+	}
+
+	wantAnnotations := []github.CheckRunAnnotation{
+		{Path: "pkg/parser_test.go", StartLine: 27, EndLine: 27, Level: "failure", Title: "parser.TestParse (task test)", Message: "expected 2, got 1"},
+		{Path: "pkg/lexer.go", StartLine: 1, EndLine: 1, Level: "failure", Title: "parser.TestLex (task test)", Message: "Test failed"},
+	}
+	if diff := cmp.Diff(wantAnnotations, got.Annotations); diff != "" {
+		t.Errorf("Mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestMakeCheckRunPublishesJobSummaries(t *testing.T) {
+	pipelineRun := newPipelineRun()
+	pipelineRun.Status.StartTime = &startTime
+	lint := taskRunStatus(corev1.ConditionTrue, "Succeeded", true)
+	lint.PipelineTaskName = "lint"
+	// Coverage: **87%**
+	lint.Status.TaskRunResults = []pipelinev1beta1.TaskRunResult{{Name: "job-summary", Value: "H4sIAAAAAAACA3POL0stSkxPtVLQ0rIwV9XS4gIAizzv8hIAAAA="}}
+	pipelineRun.Status.TaskRuns = map[string]*pipelinev1beta1.PipelineRunTaskRunStatus{
+		"ci-run-abc12-lint": lint,
+	}
+
+	got, err := makeCheckRun(pipelineRun)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	wantText := `| Task | Status | Duration | Failed steps |
+| --- | --- | --- | --- |
+| lint | Succeeded | 1m30s | - |
+| test | Pending | - | - |
+
+### Summary
+
+#### Task ` + "`lint`" + `
+
+Coverage: **87%**
+
+`
+	if diff := cmp.Diff(wantText, got.Text); diff != "" {
+		t.Errorf("Mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestTruncateText(t *testing.T) {
+	if got := truncateText("| lint | Succeeded |", 20); got != "| lint | Succeeded |" {
+		t.Errorf("Want short texts to be left untouched, got %q", got)
+	}
+
+	text := strings.Repeat("é", github.MaxCheckRunTextLength+1)
+	got := truncateText(text, github.MaxCheckRunTextLength)
+	if length := utf8.RuneCountInString(got); length != github.MaxCheckRunTextLength {
+		t.Errorf("Want %d characters, got %d", github.MaxCheckRunTextLength, length)
+	}
+
+	if !strings.HasSuffix(got, textTruncatedNotice) || !utf8.ValidString(got) {
+		t.Errorf("Want a valid text ending with the truncation notice, got %q", got[len(got)-100:])
+	}
+}
+
+func TestMakeCheckRunSendsAnnotationsOnlyOnCompletion(t *testing.T) {
+	pipelineRun := newPipelineRun()
+	pipelineRun.Status.StartTime = &startTime
+	pipelineRun.Status.SetCondition(&apis.Condition{Type: apis.ConditionSucceeded,
+		Status: corev1.ConditionUnknown,
+		Reason: "Running",
+	})
+	pipelineRun.Status.TaskRuns = map[string]*pipelinev1beta1.PipelineRunTaskRunStatus{
+		"ci-run-abc12-test": withTestReport(taskRunStatus(corev1.ConditionFalse, "Failed", true), "test",
+			`{"passed":0,"failed":1,"skipped":0,"failures":[{"name":"TestParse","file":"parser_test.go","line":7}]}`),
+	}
+
+	got, err := makeCheckRun(pipelineRun)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(got.Annotations) != 0 {
+		t.Errorf("Want no annotations while the workflow runs, got %v", got.Annotations)
+	}
+}
+
+func TestMakeCheckRunLinksToArchivedLogs(t *testing.T) {
+	pipelineRun := newPipelineRun()
+	pipelineRun.Annotations["workflows.dev/logs-url"] = "https://hooks.example.com/api/v1alpha1/namespaces/$(pipelinerun.namespace)/pipelineruns/$(pipelinerun.name)/logs?token=s3cr3t"
+
+	got, err := makeCheckRun(pipelineRun)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := "https://hooks.example.com/api/v1alpha1/namespaces/dev/pipelineruns/ci-run-abc12/logs?token=s3cr3t"
+	if want != got.DetailsURL {
+		t.Errorf("Want details URL %s, got %s", want, got.DetailsURL)
+	}
+}
+
+func TestMakeCheckRunReturnsAnErrorWhenAnnotationsAreMissing(t *testing.T) {
+	pipelineRun := newPipelineRun()
+	delete(pipelineRun.Annotations, "workflows.dev/head-commit")
+
+	if _, err := makeCheckRun(pipelineRun); err == nil {
+		t.Error("Want an error, but got a check run")
+	}
+}
+
+func TestReconcileAnnotatesPipelineRuns(t *testing.T) {
+	pipelineRun := newPipelineRun()
+	pipelineRun.Status.StartTime = &startTime
+	pipelineRun.Status.CompletionTime = &completionTime
+	pipelineRun.Status.SetCondition(&apis.Condition{Type: apis.ConditionSucceeded,
+		Status: corev1.ConditionTrue,
+		Reason: "Succeeded",
+	})
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	if err := indexer.Add(pipelineRun); err != nil {
+		t.Fatal(err)
+	}
+
+	checkRuns := &fakeCheckRunReconciler{id: 42}
+	tektonClientSet := faketektonclientset.NewSimpleClientset(pipelineRun)
+
+	reconciler := &Reconciler{
+		checkRuns:         checkRuns,
+		tektonClientSet:   tektonClientSet,
+		pipelineRunLister: listers.NewPipelineRunLister(indexer),
+	}
+
+	ctx := context.Background()
+
+	if err := reconciler.Reconcile(ctx, "dev/ci-run-abc12"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(checkRuns.checkRuns) != 1 {
+		t.Fatalf("Want 1 check run to be reported, got %d", len(checkRuns.checkRuns))
+	}
+
+	got, err := tektonClientSet.TektonV1beta1().PipelineRuns("dev").Get(ctx, "ci-run-abc12", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"workflows.dev/repository":           "john-doe/my-repo",
+		"workflows.dev/head-commit":          "833568e",
+		"workflows.dev/check-run-id":         "42",
+		"workflows.dev/check-run-conclusion": "success",
+	}
+
+	if diff := cmp.Diff(want, got.Annotations); diff != "" {
+		t.Errorf("Mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestReconcileSkipsReportedPipelineRuns(t *testing.T) {
+	pipelineRun := newPipelineRun()
+	pipelineRun.Annotations["workflows.dev/check-run-conclusion"] = "success"
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	if err := indexer.Add(pipelineRun); err != nil {
+		t.Fatal(err)
+	}
+
+	checkRuns := &fakeCheckRunReconciler{}
+	reconciler := &Reconciler{
+		checkRuns:         checkRuns,
+		tektonClientSet:   faketektonclientset.NewSimpleClientset(pipelineRun),
+		pipelineRunLister: listers.NewPipelineRunLister(indexer),
+	}
+
+	if err := reconciler.Reconcile(context.Background(), "dev/ci-run-abc12"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(checkRuns.checkRuns) != 0 {
+		t.Errorf("Want no check runs to be reported, got %d", len(checkRuns.checkRuns))
+	}
+}
+
+func TestReconcileSkipsPipelineRunsReportedWithTokens(t *testing.T) {
+	pipelineRun := newPipelineRun()
+	pipelineRun.Annotations["workflows.dev/github-credentials"] = "my-token"
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	if err := indexer.Add(pipelineRun); err != nil {
+		t.Fatal(err)
+	}
+
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "my-token", Namespace: "dev"},
+		Data: map[string][]byte{"token": []byte("ghp_abc")},
+	}
+
+	checkRuns := &fakeCheckRunReconciler{}
+	tektonClientSet := faketektonclientset.NewSimpleClientset(pipelineRun)
+	reconciler := &Reconciler{
+		checkRuns:         checkRuns,
+		kubeClientSet:     fakekubeclientset.NewSimpleClientset(secret),
+		tektonClientSet:   tektonClientSet,
+		pipelineRunLister: listers.NewPipelineRunLister(indexer),
+	}
+
+	if err := reconciler.Reconcile(context.Background(), "dev/ci-run-abc12"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(checkRuns.checkRuns) != 0 {
+		t.Errorf("Want no check runs to be reported, got %d", len(checkRuns.checkRuns))
+	}
+
+	if actions := tektonClientSet.Actions(); len(actions) != 0 {
+		t.Errorf("Want the PipelineRun to be left alone, got actions %+v", actions)
+	}
+}
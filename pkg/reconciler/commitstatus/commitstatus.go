@@ -0,0 +1,145 @@
+package commitstatus
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/pkg/apis"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/logging"
+
+	"github.com/nubank/workflows/pkg/github"
+	"github.com/nubank/workflows/pkg/pipelinerun"
+	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	tektonclientset "github.com/tektoncd/pipeline/pkg/client/clientset/versioned"
+	listers "github.com/tektoncd/pipeline/pkg/client/listers/pipeline/v1beta1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Reconciler implements controller.Reconciler for PipelineRuns created by
+// workflows that opted into commit statuses. It reports a new status whenever
+// the state of the PipelineRun changes.
+type Reconciler struct {
+
+	// statuses allows us to create Github commit statuses.
+	statuses github.CommitStatusReporter
+
+	// kubeClientSet allows us to read Secrets holding Github credentials.
+	kubeClientSet kubernetes.Interface
+
+	// tektonClientSet allows us to annotate PipelineRuns.
+	tektonClientSet tektonclientset.Interface
+
+	// pipelineRunLister indexes PipelineRun objects.
+	pipelineRunLister listers.PipelineRunLister
+}
+
+// Check that our Reconciler implements controller.Reconciler.
+var _ controller.Reconciler = (*Reconciler)(nil)
+
+// Reconcile implements controller.Reconciler.
+func (r *Reconciler) Reconcile(ctx context.Context, key string) error {
+	logger := logging.FromContext(ctx)
+
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		logger.Errorf("Invalid resource key: %s", key)
+		return nil
+	}
+
+	pipelineRun, err := r.pipelineRunLister.PipelineRuns(namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		logger.Debugf("PipelineRun %s no longer exists", key)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if _, ok := pipelineRun.Annotations[pipelinerun.CommitStatusContextAnnotation]; !ok {
+		// The workflow hasn't opted into commit statuses.
+		return nil
+	}
+
+	status, err := makeCommitStatus(pipelineRun)
+	if err != nil {
+		logger.Infof("Skipping PipelineRun %s: %v", key, err)
+		return nil
+	}
+
+	if pipelineRun.Annotations[pipelinerun.CommitStatusStateAnnotation] == status.State {
+		// The current state has already been reported.
+		return nil
+	}
+
+	ctx, err = pipelinerun.WithGithubCredentials(ctx, r.kubeClientSet, pipelineRun)
+	if err != nil {
+		return err
+	}
+
+	if err := r.statuses.ReportCommitStatus(ctx, status); err != nil {
+		return err
+	}
+
+	return pipelinerun.Annotate(ctx, r.tektonClientSet, pipelineRun, map[string]string{
+		pipelinerun.CommitStatusStateAnnotation: status.State,
+	})
+}
+
+// makeCommitStatus returns the commit status that reflects the current state of
+// the supplied PipelineRun. It returns an error if the PipelineRun lacks the
+// information needed to report its progress to Github.
+func makeCommitStatus(pipelineRun *pipelinev1beta1.PipelineRun) (*github.CommitStatus, error) {
+	annotations := pipelineRun.GetAnnotations()
+
+	repo := strings.SplitN(annotations[pipelinerun.RepositoryAnnotation], "/", 2)
+	if len(repo) != 2 || repo[0] == "" || repo[1] == "" {
+		return nil, fmt.Errorf("missing or invalid annotation %s", pipelinerun.RepositoryAnnotation)
+	}
+
+	sha := annotations[pipelinerun.HeadCommitAnnotation]
+	if sha == "" {
+		return nil, fmt.Errorf("missing annotation %s", pipelinerun.HeadCommitAnnotation)
+	}
+
+	state, description := stateOf(pipelineRun)
+
+	targetURL := pipelinerun.ExpandRunVariables(annotations[pipelinerun.CommitStatusTargetURLAnnotation], pipelineRun)
+
+	return &github.CommitStatus{Owner: repo[0],
+		Repo:        repo[1],
+		SHA:         sha,
+		State:       state,
+		Context:     annotations[pipelinerun.CommitStatusContextAnnotation],
+		Description: description,
+		TargetURL:   targetURL,
+	}, nil
+}
+
+// stateOf returns the commit status state along with a description based on
+// the Succeeded condition of the PipelineRun.
+func stateOf(pipelineRun *pipelinev1beta1.PipelineRun) (string, string) {
+	condition := pipelineRun.Status.GetCondition(apis.ConditionSucceeded)
+
+	switch {
+	case condition == nil || condition.IsUnknown():
+		return github.StatusPending, "Workflow is running"
+
+	case condition.IsTrue():
+		return github.StatusSuccess, "Workflow succeeded"
+
+	case condition.Reason == pipelinev1beta1.PipelineRunReasonFailed.String():
+		return github.StatusFailure, "Workflow failed"
+
+	case condition.Reason == pipelinev1beta1.PipelineRunReasonCancelled.String():
+		return github.StatusError, "Workflow was cancelled"
+
+	case condition.Reason == pipelinev1beta1.PipelineRunReasonTimedOut.String():
+		return github.StatusError, "Workflow timed out"
+
+	default:
+		return github.StatusError, fmt.Sprintf("Workflow couldn't run: %s", condition.Reason)
+	}
+}
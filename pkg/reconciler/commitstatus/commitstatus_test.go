@@ -0,0 +1,158 @@
+package commitstatus
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nubank/workflows/pkg/github"
+	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	faketektonclientset "github.com/tektoncd/pipeline/pkg/client/clientset/versioned/fake"
+	listers "github.com/tektoncd/pipeline/pkg/client/listers/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/pkg/apis"
+)
+
+// fakeCommitStatusReporter records commit statuses instead of sending them to
+// Github.
+type fakeCommitStatusReporter struct {
+	statuses []*github.CommitStatus
+}
+
+// ReportCommitStatus implements github.CommitStatusReporter.
+func (f *fakeCommitStatusReporter) ReportCommitStatus(ctx context.Context, status *github.CommitStatus) error {
+	f.statuses = append(f.statuses, status)
+	return nil
+}
+
+func newPipelineRun() *pipelinev1beta1.PipelineRun {
+	return &pipelinev1beta1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "ci-run-abc12",
+			Namespace: "dev",
+			Labels: map[string]string{
+				"workflows.dev/workflow": "ci",
+			},
+			Annotations: map[string]string{
+				"workflows.dev/repository":               "john-doe/my-repo",
+				"workflows.dev/head-commit":              "833568e",
+				"workflows.dev/commit-status-context":    "ci/tekton",
+				"workflows.dev/commit-status-target-url": "https://dashboard.example.com/#/namespaces/$(pipelinerun.namespace)/pipelineruns/$(pipelinerun.name)",
+			},
+		},
+	}
+}
+
+func TestMakeCommitStatus(t *testing.T) {
+	tests := []struct {
+		condition       *apis.Condition
+		wantState       string
+		wantDescription string
+	}{
+		{nil, github.StatusPending, "Workflow is running"},
+		{&apis.Condition{Status: corev1.ConditionUnknown, Reason: "Running"}, github.StatusPending, "Workflow is running"},
+		{&apis.Condition{Status: corev1.ConditionTrue, Reason: "Succeeded"}, github.StatusSuccess, "Workflow succeeded"},
+		{&apis.Condition{Status: corev1.ConditionFalse, Reason: "Failed"}, github.StatusFailure, "Workflow failed"},
+		{&apis.Condition{Status: corev1.ConditionFalse, Reason: "Cancelled"}, github.StatusError, "Workflow was cancelled"},
+		{&apis.Condition{Status: corev1.ConditionFalse, Reason: "PipelineRunTimeout"}, github.StatusError, "Workflow timed out"},
+		{&apis.Condition{Status: corev1.ConditionFalse, Reason: "CouldntGetPipeline"}, github.StatusError, "Workflow couldn't run: CouldntGetPipeline"},
+	}
+
+	for _, test := range tests {
+		pipelineRun := newPipelineRun()
+		if test.condition != nil {
+			test.condition.Type = apis.ConditionSucceeded
+			pipelineRun.Status.SetCondition(test.condition)
+		}
+
+		got, err := makeCommitStatus(pipelineRun)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		want := &github.CommitStatus{Owner: "john-doe",
+			Repo:        "my-repo",
+			SHA:         "833568e",
+			State:       test.wantState,
+			Context:     "ci/tekton",
+			Description: test.wantDescription,
+			TargetURL:   "https://dashboard.example.com/#/namespaces/dev/pipelineruns/ci-run-abc12",
+		}
+
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("Mismatch (-want +got):\n%s", diff)
+		}
+	}
+}
+
+func TestReconcileReportsStateChangesOnlyOnce(t *testing.T) {
+	pipelineRun := newPipelineRun()
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	if err := indexer.Add(pipelineRun); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses := &fakeCommitStatusReporter{}
+	tektonClientSet := faketektonclientset.NewSimpleClientset(pipelineRun)
+
+	reconciler := &Reconciler{
+		statuses:          statuses,
+		tektonClientSet:   tektonClientSet,
+		pipelineRunLister: listers.NewPipelineRunLister(indexer),
+	}
+
+	ctx := context.Background()
+
+	if err := reconciler.Reconcile(ctx, "dev/ci-run-abc12"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	got, err := tektonClientSet.TektonV1beta1().PipelineRuns("dev").Get(ctx, "ci-run-abc12", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if state := got.Annotations["workflows.dev/commit-status-state"]; state != github.StatusPending {
+		t.Errorf("Want state annotation %s, got %s", github.StatusPending, state)
+	}
+
+	// Reconcile the annotated PipelineRun again.
+	if err := indexer.Update(got); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := reconciler.Reconcile(ctx, "dev/ci-run-abc12"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(statuses.statuses) != 1 {
+		t.Errorf("Want 1 commit status to be reported, got %d", len(statuses.statuses))
+	}
+}
+
+func TestReconcileIgnoresPipelineRunsWithoutCommitStatuses(t *testing.T) {
+	pipelineRun := newPipelineRun()
+	delete(pipelineRun.Annotations, "workflows.dev/commit-status-context")
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	if err := indexer.Add(pipelineRun); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses := &fakeCommitStatusReporter{}
+	reconciler := &Reconciler{
+		statuses:          statuses,
+		tektonClientSet:   faketektonclientset.NewSimpleClientset(pipelineRun),
+		pipelineRunLister: listers.NewPipelineRunLister(indexer),
+	}
+
+	if err := reconciler.Reconcile(context.Background(), "dev/ci-run-abc12"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(statuses.statuses) != 0 {
+		t.Errorf("Want no commit statuses to be reported, got %d", len(statuses.statuses))
+	}
+}
@@ -0,0 +1,42 @@
+package commitstatus
+
+import (
+	"context"
+
+	kubeclient "knative.dev/pkg/client/injection/kube/client"
+	"knative.dev/pkg/configmap"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/logging"
+
+	"github.com/nubank/workflows/pkg/github"
+	"github.com/nubank/workflows/pkg/pipelinerun"
+	pipelineclient "github.com/tektoncd/pipeline/pkg/client/injection/client"
+	pipelineruninformer "github.com/tektoncd/pipeline/pkg/client/injection/informers/pipeline/v1beta1/pipelinerun"
+	"k8s.io/client-go/tools/cache"
+)
+
+// NewController creates a Reconciler that reports the progress of
+// PipelineRuns as Github commit statuses and returns the result of NewImpl.
+func NewController(ctx context.Context, watcher configmap.Watcher) *controller.Impl {
+	logger := logging.FromContext(ctx)
+
+	pipelineRunInformer := pipelineruninformer.Get(ctx)
+
+	reconciler := &Reconciler{
+		statuses:          github.GetCommitStatusReporterOrDie(ctx),
+		kubeClientSet:     kubeclient.Get(ctx),
+		tektonClientSet:   pipelineclient.Get(ctx),
+		pipelineRunLister: pipelineRunInformer.Lister(),
+	}
+
+	impl := controller.NewImpl(reconciler, logger, "CommitStatuses")
+
+	logger.Info("Setting up event handlers")
+
+	pipelineRunInformer.Informer().AddEventHandler(cache.FilteringResourceEventHandler{
+		FilterFunc: pipelinerun.HostedByGithubFilterFunc,
+		Handler:    controller.HandleAll(impl.Enqueue),
+	})
+
+	return impl
+}
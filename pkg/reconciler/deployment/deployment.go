@@ -0,0 +1,197 @@
+package deployment
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/pkg/apis"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/logging"
+
+	"github.com/nubank/workflows/pkg/github"
+	"github.com/nubank/workflows/pkg/pipelinerun"
+	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	tektonclientset "github.com/tektoncd/pipeline/pkg/client/clientset/versioned"
+	listers "github.com/tektoncd/pipeline/pkg/client/listers/pipeline/v1beta1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Reconciler implements controller.Reconciler for PipelineRuns whose tasks
+// deploy to environments. It creates a Github deployment once such a task
+// starts and reports a new deployment status whenever the state of the
+// underlying TaskRun changes.
+type Reconciler struct {
+
+	// deployments allows us to create Github deployments and their statuses.
+	deployments github.DeploymentReporter
+
+	// kubeClientSet allows us to read Secrets holding Github credentials.
+	kubeClientSet kubernetes.Interface
+
+	// tektonClientSet allows us to annotate PipelineRuns.
+	tektonClientSet tektonclientset.Interface
+
+	// pipelineRunLister indexes PipelineRun objects.
+	pipelineRunLister listers.PipelineRunLister
+}
+
+// Check that our Reconciler implements controller.Reconciler.
+var _ controller.Reconciler = (*Reconciler)(nil)
+
+// Reconcile implements controller.Reconciler.
+func (r *Reconciler) Reconcile(ctx context.Context, key string) error {
+	logger := logging.FromContext(ctx)
+
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		logger.Errorf("Invalid resource key: %s", key)
+		return nil
+	}
+
+	pipelineRun, err := r.pipelineRunLister.PipelineRuns(namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		logger.Debugf("PipelineRun %s no longer exists", key)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if _, ok := pipelineRun.Annotations[pipelinerun.EnvironmentsAnnotation]; !ok {
+		// None of the workflow's tasks deploy to an environment.
+		return nil
+	}
+
+	environments, err := pipelinerun.GetEnvironments(pipelineRun)
+	if err != nil {
+		logger.Infof("Skipping PipelineRun %s: %v", key, err)
+		return nil
+	}
+
+	deployments, err := pipelinerun.GetDeployments(pipelineRun)
+	if err != nil {
+		logger.Infof("Skipping PipelineRun %s: %v", key, err)
+		return nil
+	}
+
+	annotations := pipelineRun.GetAnnotations()
+	repo := strings.SplitN(annotations[pipelinerun.RepositoryAnnotation], "/", 2)
+	if len(repo) != 2 || repo[0] == "" || repo[1] == "" {
+		logger.Infof("Skipping PipelineRun %s: missing or invalid annotation %s", key, pipelinerun.RepositoryAnnotation)
+		return nil
+	}
+
+	sha := annotations[pipelinerun.HeadCommitAnnotation]
+	if sha == "" {
+		logger.Infof("Skipping PipelineRun %s: missing annotation %s", key, pipelinerun.HeadCommitAnnotation)
+		return nil
+	}
+
+	ctx, err = pipelinerun.WithGithubCredentials(ctx, r.kubeClientSet, pipelineRun)
+	if err != nil {
+		return err
+	}
+
+	taskNames := make([]string, 0, len(environments))
+	for taskName := range environments {
+		taskNames = append(taskNames, taskName)
+	}
+	sort.Strings(taskNames)
+
+	var reportErr error
+	changed := false
+	for _, taskName := range taskNames {
+		state, description, started := stateOf(pipelineRun, taskName)
+		if !started || deployments[taskName].State == state {
+			continue
+		}
+
+		deployment := deployments[taskName]
+		reportErr = r.reportDeployment(ctx, pipelineRun, repo[0], repo[1], sha, environments[taskName], &deployment, state, description)
+		if deployment != deployments[taskName] {
+			deployments[taskName] = deployment
+			changed = true
+		}
+
+		if reportErr != nil {
+			break
+		}
+	}
+
+	if changed {
+		// Record deployments even if reporting some of them failed, so
+		// they aren't created twice.
+		value, err := json.Marshal(deployments)
+		if err != nil {
+			return err
+		}
+
+		if err := pipelinerun.Annotate(ctx, r.tektonClientSet, pipelineRun, map[string]string{
+			pipelinerun.DeploymentsAnnotation: string(value),
+		}); err != nil {
+			return err
+		}
+	}
+
+	return reportErr
+}
+
+// reportDeployment creates the Github deployment of a task, unless it already
+// exists, and reports its new state. The supplied deployment is updated as
+// each call to Github succeeds.
+func (r *Reconciler) reportDeployment(ctx context.Context, pipelineRun *pipelinev1beta1.PipelineRun, owner, repo, sha string, environment pipelinerun.Environment, deployment *pipelinerun.Deployment, state, description string) error {
+	if deployment.ID == 0 {
+		id, err := r.deployments.CreateDeployment(ctx, &github.Deployment{Owner: owner,
+			Repo:        repo,
+			Ref:         sha,
+			Environment: environment.Name,
+			Description: fmt.Sprintf("PipelineRun %s/%s", pipelineRun.GetNamespace(), pipelineRun.GetName()),
+		})
+		if err != nil {
+			return err
+		}
+		deployment.ID = id
+	}
+
+	err := r.deployments.ReportDeploymentStatus(ctx, &github.DeploymentStatus{Owner: owner,
+		Repo:           repo,
+		DeploymentID:   deployment.ID,
+		State:          state,
+		Description:    description,
+		EnvironmentURL: pipelinerun.ExpandRunVariables(environment.URL, pipelineRun),
+	})
+	if err != nil {
+		return err
+	}
+
+	deployment.State = state
+	return nil
+}
+
+// stateOf returns the deployment state along with a description based on the
+// Succeeded condition of the TaskRun created for the supplied pipeline task.
+// It returns false if the task hasn't started yet.
+func stateOf(pipelineRun *pipelinev1beta1.PipelineRun, taskName string) (string, string, bool) {
+	for _, taskRun := range pipelineRun.Status.TaskRuns {
+		if taskRun.PipelineTaskName != taskName || taskRun.Status == nil {
+			continue
+		}
+
+		condition := taskRun.Status.GetCondition(apis.ConditionSucceeded)
+		switch {
+		case condition == nil || condition.IsUnknown():
+			return github.DeploymentInProgress, fmt.Sprintf("Task %s is running", taskName), true
+
+		case condition.IsTrue():
+			return github.DeploymentSuccess, fmt.Sprintf("Task %s succeeded", taskName), true
+
+		default:
+			return github.DeploymentFailure, fmt.Sprintf("Task %s failed", taskName), true
+		}
+	}
+	return "", "", false
+}
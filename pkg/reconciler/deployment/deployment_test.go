@@ -0,0 +1,196 @@
+package deployment
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nubank/workflows/pkg/github"
+	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	faketektonclientset "github.com/tektoncd/pipeline/pkg/client/clientset/versioned/fake"
+	listers "github.com/tektoncd/pipeline/pkg/client/listers/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/pkg/apis"
+	duckv1beta1 "knative.dev/pkg/apis/duck/v1beta1"
+)
+
+// fakeDeploymentReporter records deployments and their statuses instead of
+// sending them to Github.
+type fakeDeploymentReporter struct {
+	deployments []*github.Deployment
+	statuses    []*github.DeploymentStatus
+	statusErr   error
+}
+
+// CreateDeployment implements github.DeploymentReporter.
+func (f *fakeDeploymentReporter) CreateDeployment(ctx context.Context, deployment *github.Deployment) (int64, error) {
+	f.deployments = append(f.deployments, deployment)
+	return 42, nil
+}
+
+// ReportDeploymentStatus implements github.DeploymentReporter.
+func (f *fakeDeploymentReporter) ReportDeploymentStatus(ctx context.Context, status *github.DeploymentStatus) error {
+	if f.statusErr != nil {
+		return f.statusErr
+	}
+	f.statuses = append(f.statuses, status)
+	return nil
+}
+
+func newPipelineRun() *pipelinev1beta1.PipelineRun {
+	return &pipelinev1beta1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "ci-run-abc12",
+			Namespace: "dev",
+			Labels: map[string]string{
+				"workflows.dev/workflow": "ci",
+			},
+			Annotations: map[string]string{
+				"workflows.dev/repository":   "john-doe/my-repo",
+				"workflows.dev/head-commit":  "833568e",
+				"workflows.dev/environments": `{"deploy":{"name":"staging","url":"https://staging.example.com/$(pipelinerun.name)"}}`,
+			},
+		},
+	}
+}
+
+func withTaskRun(pipelineRun *pipelinev1beta1.PipelineRun, taskName string, status corev1.ConditionStatus) *pipelinev1beta1.PipelineRun {
+	pipelineRun.Status.TaskRuns = map[string]*pipelinev1beta1.PipelineRunTaskRunStatus{
+		"ci-run-abc12-" + taskName: {PipelineTaskName: taskName,
+			Status: &pipelinev1beta1.TaskRunStatus{
+				Status: duckv1beta1.Status{
+					Conditions: duckv1beta1.Conditions{{Type: apis.ConditionSucceeded,
+						Status: status,
+					}},
+				},
+			},
+		},
+	}
+	return pipelineRun
+}
+
+func newReconciler(t *testing.T, reporter github.DeploymentReporter, pipelineRun *pipelinev1beta1.PipelineRun) *Reconciler {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	if err := indexer.Add(pipelineRun); err != nil {
+		t.Fatal(err)
+	}
+
+	return &Reconciler{
+		deployments:       reporter,
+		tektonClientSet:   faketektonclientset.NewSimpleClientset(pipelineRun),
+		pipelineRunLister: listers.NewPipelineRunLister(indexer),
+	}
+}
+
+func getDeploymentsAnnotation(t *testing.T, reconciler *Reconciler) string {
+	pipelineRun, err := reconciler.tektonClientSet.TektonV1beta1().PipelineRuns("dev").Get(context.Background(), "ci-run-abc12", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pipelineRun.Annotations["workflows.dev/deployments"]
+}
+
+func TestReconcileCreatesDeploymentsWhenTasksStart(t *testing.T) {
+	reporter := &fakeDeploymentReporter{}
+	reconciler := newReconciler(t, reporter, withTaskRun(newPipelineRun(), "deploy", corev1.ConditionUnknown))
+
+	if err := reconciler.Reconcile(context.Background(), "dev/ci-run-abc12"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	wantDeployments := []*github.Deployment{{Owner: "john-doe",
+		Repo:        "my-repo",
+		Ref:         "833568e",
+		Environment: "staging",
+		Description: "PipelineRun dev/ci-run-abc12",
+	}}
+
+	if diff := cmp.Diff(wantDeployments, reporter.deployments); diff != "" {
+		t.Errorf("Mismatch (-want +got):\n%s", diff)
+	}
+
+	wantStatuses := []*github.DeploymentStatus{{Owner: "john-doe",
+		Repo:           "my-repo",
+		DeploymentID:   42,
+		State:          github.DeploymentInProgress,
+		Description:    "Task deploy is running",
+		EnvironmentURL: "https://staging.example.com/ci-run-abc12",
+	}}
+
+	if diff := cmp.Diff(wantStatuses, reporter.statuses); diff != "" {
+		t.Errorf("Mismatch (-want +got):\n%s", diff)
+	}
+
+	want := `{"deploy":{"id":42,"state":"in_progress"}}`
+	if got := getDeploymentsAnnotation(t, reconciler); want != got {
+		t.Errorf("Want annotation %s, got %s", want, got)
+	}
+}
+
+func TestReconcileReportsNewStatesOfExistingDeployments(t *testing.T) {
+	tests := []struct {
+		status    corev1.ConditionStatus
+		wantState string
+	}{
+		{corev1.ConditionTrue, github.DeploymentSuccess},
+		{corev1.ConditionFalse, github.DeploymentFailure},
+	}
+
+	for _, test := range tests {
+		pipelineRun := withTaskRun(newPipelineRun(), "deploy", test.status)
+		pipelineRun.Annotations["workflows.dev/deployments"] = `{"deploy":{"id":7,"state":"in_progress"}}`
+
+		reporter := &fakeDeploymentReporter{}
+		reconciler := newReconciler(t, reporter, pipelineRun)
+
+		if err := reconciler.Reconcile(context.Background(), "dev/ci-run-abc12"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if len(reporter.deployments) != 0 {
+			t.Errorf("Want no deployments to be created, got %d", len(reporter.deployments))
+		}
+
+		if len(reporter.statuses) != 1 || reporter.statuses[0].DeploymentID != 7 || reporter.statuses[0].State != test.wantState {
+			t.Errorf("Want a single %s status of deployment 7, got %+v", test.wantState, reporter.statuses)
+		}
+	}
+}
+
+func TestReconcileSkipsTasksThatHaveNotStartedOrWereReported(t *testing.T) {
+	pipelineRuns := []*pipelinev1beta1.PipelineRun{
+		newPipelineRun(),
+		withTaskRun(newPipelineRun(), "test", corev1.ConditionUnknown),
+		withTaskRun(newPipelineRun(), "deploy", corev1.ConditionTrue),
+	}
+	pipelineRuns[2].Annotations["workflows.dev/deployments"] = `{"deploy":{"id":7,"state":"success"}}`
+
+	for _, pipelineRun := range pipelineRuns {
+		reporter := &fakeDeploymentReporter{}
+		reconciler := newReconciler(t, reporter, pipelineRun)
+
+		if err := reconciler.Reconcile(context.Background(), "dev/ci-run-abc12"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if len(reporter.deployments) != 0 || len(reporter.statuses) != 0 {
+			t.Errorf("Want nothing to be reported, got %d deployments and %d statuses", len(reporter.deployments), len(reporter.statuses))
+		}
+	}
+}
+
+func TestReconcileRecordsDeploymentsWhenStatusesFail(t *testing.T) {
+	reporter := &fakeDeploymentReporter{statusErr: errors.New("Boom!")}
+	reconciler := newReconciler(t, reporter, withTaskRun(newPipelineRun(), "deploy", corev1.ConditionUnknown))
+
+	if err := reconciler.Reconcile(context.Background(), "dev/ci-run-abc12"); err == nil {
+		t.Error("Want an error, got nil")
+	}
+
+	want := `{"deploy":{"id":42,"state":""}}`
+	if got := getDeploymentsAnnotation(t, reconciler); want != got {
+		t.Errorf("Want annotation %s, got %s", want, got)
+	}
+}
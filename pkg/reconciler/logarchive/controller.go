@@ -0,0 +1,54 @@
+package logarchive
+
+import (
+	"context"
+
+	kubeclient "knative.dev/pkg/client/injection/kube/client"
+	"knative.dev/pkg/configmap"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/logging"
+	pkgreconciler "knative.dev/pkg/reconciler"
+
+	"github.com/nubank/workflows/pkg/logstore"
+	"github.com/nubank/workflows/pkg/pipelinerun"
+	pipelineclient "github.com/tektoncd/pipeline/pkg/client/injection/client"
+	pipelineruninformer "github.com/tektoncd/pipeline/pkg/client/injection/informers/pipeline/v1beta1/pipelinerun"
+	"go.uber.org/zap"
+	"k8s.io/client-go/tools/cache"
+)
+
+// NewController creates a Reconciler that archives logs of finished
+// PipelineRuns into the store found in the context and returns the result of
+// NewImpl.
+func NewController(ctx context.Context, watcher configmap.Watcher) *controller.Impl {
+	logger := logging.FromContext(ctx)
+
+	store := logstore.GetStore(ctx)
+	if store == nil {
+		logger.Fatal("Unable to fetch the logs store from the context")
+	}
+
+	maxStepBytes, err := logstore.MaxStepBytesFromEnv()
+	if err != nil {
+		logger.Fatal("Error reading the maximum size of logs archived per step", zap.Error(err))
+	}
+
+	pipelineRunInformer := pipelineruninformer.Get(ctx)
+
+	reconciler := &Reconciler{
+		collector:         logstore.NewCollector(kubeclient.Get(ctx), store, maxStepBytes),
+		tektonClientSet:   pipelineclient.Get(ctx),
+		pipelineRunLister: pipelineRunInformer.Lister(),
+	}
+
+	impl := controller.NewImpl(reconciler, logger, "LogArchive")
+
+	logger.Info("Setting up event handlers")
+
+	pipelineRunInformer.Informer().AddEventHandler(cache.FilteringResourceEventHandler{
+		FilterFunc: pkgreconciler.LabelExistsFilterFunc(pipelinerun.WorkflowLabel),
+		Handler:    controller.HandleAll(impl.Enqueue),
+	})
+
+	return impl
+}
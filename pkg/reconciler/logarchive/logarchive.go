@@ -0,0 +1,75 @@
+package logarchive
+
+import (
+	"context"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/logging"
+
+	"github.com/nubank/workflows/pkg/pipelinerun"
+	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	tektonclientset "github.com/tektoncd/pipeline/pkg/client/clientset/versioned"
+	listers "github.com/tektoncd/pipeline/pkg/client/listers/pipeline/v1beta1"
+)
+
+// collector archives logs of PipelineRuns.
+type collector interface {
+	Collect(ctx context.Context, pipelineRun *pipelinev1beta1.PipelineRun, token string) error
+}
+
+// Reconciler implements controller.Reconciler for PipelineRuns whose logs
+// must be archived. It archives logs of every step once the PipelineRun is
+// done, before its pods get garbage-collected.
+type Reconciler struct {
+
+	// collector allows us to archive logs of PipelineRuns.
+	collector collector
+
+	// tektonClientSet allows us to annotate PipelineRuns.
+	tektonClientSet tektonclientset.Interface
+
+	// pipelineRunLister indexes PipelineRun objects.
+	pipelineRunLister listers.PipelineRunLister
+}
+
+// Check that our Reconciler implements controller.Reconciler.
+var _ controller.Reconciler = (*Reconciler)(nil)
+
+// Reconcile implements controller.Reconciler.
+func (r *Reconciler) Reconcile(ctx context.Context, key string) error {
+	logger := logging.FromContext(ctx)
+
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		logger.Errorf("Invalid resource key: %s", key)
+		return nil
+	}
+
+	pipelineRun, err := r.pipelineRunLister.PipelineRuns(namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		logger.Debugf("PipelineRun %s no longer exists", key)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	annotations := pipelineRun.GetAnnotations()
+	token := annotations[pipelinerun.LogsTokenAnnotation]
+	if token == "" || !pipelineRun.IsDone() || annotations[pipelinerun.LogsArchivedAnnotation] == "true" {
+		// Logs aren't meant to be archived, the PipelineRun is still running
+		// or they've already been archived.
+		return nil
+	}
+
+	if err := r.collector.Collect(ctx, pipelineRun, token); err != nil {
+		return err
+	}
+
+	logger.Infof("Logs of PipelineRun %s have been successfully archived", key)
+
+	return pipelinerun.Annotate(ctx, r.tektonClientSet, pipelineRun, map[string]string{
+		pipelinerun.LogsArchivedAnnotation: "true",
+	})
+}
@@ -0,0 +1,130 @@
+package logarchive
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	faketektonclientset "github.com/tektoncd/pipeline/pkg/client/clientset/versioned/fake"
+	listers "github.com/tektoncd/pipeline/pkg/client/listers/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/pkg/apis"
+	duckv1beta1 "knative.dev/pkg/apis/duck/v1beta1"
+)
+
+// fakeCollector records the PipelineRuns whose logs were collected.
+type fakeCollector struct {
+	collected []string
+	tokens    []string
+	err       error
+}
+
+// Collect implements collector.
+func (f *fakeCollector) Collect(ctx context.Context, pipelineRun *pipelinev1beta1.PipelineRun, token string) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.collected = append(f.collected, pipelineRun.GetName())
+	f.tokens = append(f.tokens, token)
+	return nil
+}
+
+func newPipelineRun(status corev1.ConditionStatus) *pipelinev1beta1.PipelineRun {
+	return &pipelinev1beta1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "ci-run-abc12",
+			Namespace: "dev",
+			Labels: map[string]string{
+				"workflows.dev/workflow": "ci",
+			},
+			Annotations: map[string]string{
+				"workflows.dev/logs-token": "s3cr3t",
+			},
+		},
+		Status: pipelinev1beta1.PipelineRunStatus{
+			Status: duckv1beta1.Status{
+				Conditions: duckv1beta1.Conditions{{Type: apis.ConditionSucceeded,
+					Status: status,
+				}},
+			},
+		},
+	}
+}
+
+func newReconciler(t *testing.T, collector collector, pipelineRun *pipelinev1beta1.PipelineRun) *Reconciler {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	if err := indexer.Add(pipelineRun); err != nil {
+		t.Fatal(err)
+	}
+
+	return &Reconciler{
+		collector:         collector,
+		tektonClientSet:   faketektonclientset.NewSimpleClientset(pipelineRun),
+		pipelineRunLister: listers.NewPipelineRunLister(indexer),
+	}
+}
+
+func getArchivedAnnotation(t *testing.T, reconciler *Reconciler) string {
+	pipelineRun, err := reconciler.tektonClientSet.TektonV1beta1().PipelineRuns("dev").Get(context.Background(), "ci-run-abc12", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pipelineRun.Annotations["workflows.dev/logs-archived"]
+}
+
+func TestReconcileArchivesLogsOfFinishedPipelineRuns(t *testing.T) {
+	for _, status := range []corev1.ConditionStatus{corev1.ConditionTrue, corev1.ConditionFalse} {
+		collector := &fakeCollector{}
+		reconciler := newReconciler(t, collector, newPipelineRun(status))
+
+		if err := reconciler.Reconcile(context.Background(), "dev/ci-run-abc12"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if len(collector.collected) != 1 || collector.tokens[0] != "s3cr3t" {
+			t.Errorf("Want logs to be collected once with the PipelineRun's token, got %v", collector.collected)
+		}
+
+		if got := getArchivedAnnotation(t, reconciler); got != "true" {
+			t.Errorf("Want annotation workflows.dev/logs-archived=true, got %q", got)
+		}
+	}
+}
+
+func TestReconcileSkipsPipelineRuns(t *testing.T) {
+	running := newPipelineRun(corev1.ConditionUnknown)
+
+	withoutToken := newPipelineRun(corev1.ConditionTrue)
+	delete(withoutToken.Annotations, "workflows.dev/logs-token")
+
+	archived := newPipelineRun(corev1.ConditionTrue)
+	archived.Annotations["workflows.dev/logs-archived"] = "true"
+
+	for _, pipelineRun := range []*pipelinev1beta1.PipelineRun{running, withoutToken, archived} {
+		collector := &fakeCollector{}
+		reconciler := newReconciler(t, collector, pipelineRun)
+
+		if err := reconciler.Reconcile(context.Background(), "dev/ci-run-abc12"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if len(collector.collected) != 0 {
+			t.Errorf("Want no logs to be collected, got %v", collector.collected)
+		}
+	}
+}
+
+func TestReconcileRetriesWhenCollectingFails(t *testing.T) {
+	collector := &fakeCollector{err: errors.New("Boom!")}
+	reconciler := newReconciler(t, collector, newPipelineRun(corev1.ConditionTrue))
+
+	if err := reconciler.Reconcile(context.Background(), "dev/ci-run-abc12"); err == nil {
+		t.Error("Want an error, got nil")
+	}
+
+	if got := getArchivedAnnotation(t, reconciler); got != "" {
+		t.Errorf("Want no annotation workflows.dev/logs-archived, got %q", got)
+	}
+}
@@ -0,0 +1,160 @@
+package summarycomment
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/pkg/apis"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/logging"
+
+	"github.com/nubank/workflows/pkg/github"
+	"github.com/nubank/workflows/pkg/pipelinerun"
+	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	tektonclientset "github.com/tektoncd/pipeline/pkg/client/clientset/versioned"
+	listers "github.com/tektoncd/pipeline/pkg/client/listers/pipeline/v1beta1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Reconciler implements controller.Reconciler for PipelineRuns triggered by
+// pull requests. Once a PipelineRun is done, it posts a summary of its results
+// on the pull request, updating the comment left by previous runs of the same
+// workflow.
+type Reconciler struct {
+
+	// comments allows us to manage sticky comments on pull requests.
+	comments github.CommentReconciler
+
+	// kubeClientSet allows us to read Secrets holding Github credentials.
+	kubeClientSet kubernetes.Interface
+
+	// tektonClientSet allows us to annotate PipelineRuns.
+	tektonClientSet tektonclientset.Interface
+
+	// pipelineRunLister indexes PipelineRun objects.
+	pipelineRunLister listers.PipelineRunLister
+}
+
+// Check that our Reconciler implements controller.Reconciler.
+var _ controller.Reconciler = (*Reconciler)(nil)
+
+// Reconcile implements controller.Reconciler.
+func (r *Reconciler) Reconcile(ctx context.Context, key string) error {
+	logger := logging.FromContext(ctx)
+
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		logger.Errorf("Invalid resource key: %s", key)
+		return nil
+	}
+
+	pipelineRun, err := r.pipelineRunLister.PipelineRuns(namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		logger.Debugf("PipelineRun %s no longer exists", key)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	annotations := pipelineRun.GetAnnotations()
+	if _, ok := annotations[pipelinerun.PullRequestCommentAnnotation]; !ok || !pipelineRun.IsDone() {
+		return nil
+	}
+
+	if _, posted := annotations[pipelinerun.PullRequestCommentPostedAnnotation]; posted {
+		return nil
+	}
+
+	comment, err := makeComment(pipelineRun)
+	if err != nil {
+		logger.Infof("Skipping PipelineRun %s: %v", key, err)
+		return nil
+	}
+
+	ctx, err = pipelinerun.WithGithubCredentials(ctx, r.kubeClientSet, pipelineRun)
+	if err != nil {
+		return err
+	}
+
+	if err := r.comments.ReconcileComment(ctx, comment); err != nil {
+		return err
+	}
+
+	return pipelinerun.Annotate(ctx, r.tektonClientSet, pipelineRun, map[string]string{
+		pipelinerun.PullRequestCommentPostedAnnotation: "true",
+	})
+}
+
+// makeComment returns the sticky comment summarizing the results of the
+// supplied PipelineRun. It returns an error if the PipelineRun lacks the
+// information needed to comment on the pull request.
+func makeComment(pipelineRun *pipelinev1beta1.PipelineRun) (*github.StickyComment, error) {
+	annotations := pipelineRun.GetAnnotations()
+
+	repo := strings.SplitN(annotations[pipelinerun.RepositoryAnnotation], "/", 2)
+	if len(repo) != 2 || repo[0] == "" || repo[1] == "" {
+		return nil, fmt.Errorf("missing or invalid annotation %s", pipelinerun.RepositoryAnnotation)
+	}
+
+	number, err := strconv.Atoi(annotations[pipelinerun.PullRequestAnnotation])
+	if err != nil {
+		return nil, fmt.Errorf("missing or invalid annotation %s", pipelinerun.PullRequestAnnotation)
+	}
+
+	workflow := pipelineRun.GetLabels()[pipelinerun.WorkflowLabel]
+
+	return &github.StickyComment{Owner: repo[0],
+		Repo:   repo[1],
+		Number: number,
+		Key:    fmt.Sprintf("%s/%s", pipelineRun.GetNamespace(), workflow),
+		Body:   renderSummary(workflow, pipelineRun),
+	}, nil
+}
+
+// renderSummary renders the Markdown summary of the supplied PipelineRun.
+func renderSummary(workflow string, pipelineRun *pipelinev1beta1.PipelineRun) string {
+	annotations := pipelineRun.GetAnnotations()
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "### Workflow `%s` %s\n\n", workflow, outcome(pipelineRun))
+
+	details := []string{fmt.Sprintf("PipelineRun `%s/%s`", pipelineRun.GetNamespace(), pipelineRun.GetName())}
+	if sha := annotations[pipelinerun.HeadCommitAnnotation]; sha != "" {
+		details = append([]string{fmt.Sprintf("Commit %s", sha)}, details...)
+	}
+	if logsURL := annotations[pipelinerun.PullRequestCommentLogsURLAnnotation]; logsURL != "" {
+		details = append(details, fmt.Sprintf("[View logs](%s)", pipelinerun.ExpandRunVariables(logsURL, pipelineRun)))
+	}
+	fmt.Fprintf(&builder, "%s\n\n", strings.Join(details, " | "))
+
+	builder.WriteString(pipelinerun.RenderTaskSummaries(pipelinerun.SummarizeTasks(pipelineRun)))
+
+	if summaries := pipelinerun.GetJobSummaries(pipelineRun); len(summaries) != 0 {
+		fmt.Fprintf(&builder, "\n%s", pipelinerun.RenderJobSummaries(summaries, pipelinerun.MaxJobSummariesBytes))
+	}
+
+	return builder.String()
+}
+
+// outcome describes how the PipelineRun has finished.
+func outcome(pipelineRun *pipelinev1beta1.PipelineRun) string {
+	condition := pipelineRun.Status.GetCondition(apis.ConditionSucceeded)
+
+	switch {
+	case condition.IsTrue():
+		return "succeeded"
+
+	case condition.Reason == pipelinev1beta1.PipelineRunReasonCancelled.String():
+		return "was cancelled"
+
+	case condition.Reason == pipelinev1beta1.PipelineRunReasonTimedOut.String():
+		return "timed out"
+
+	default:
+		return "failed"
+	}
+}
@@ -0,0 +1,200 @@
+package summarycomment
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/nubank/workflows/pkg/github"
+	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	faketektonclientset "github.com/tektoncd/pipeline/pkg/client/clientset/versioned/fake"
+	listers "github.com/tektoncd/pipeline/pkg/client/listers/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/pkg/apis"
+	duckv1beta1 "knative.dev/pkg/apis/duck/v1beta1"
+)
+
+// fakeCommentReconciler records comments instead of sending them to Github.
+type fakeCommentReconciler struct {
+	comments []*github.StickyComment
+}
+
+// ReconcileComment implements github.CommentReconciler.
+func (f *fakeCommentReconciler) ReconcileComment(ctx context.Context, comment *github.StickyComment) error {
+	f.comments = append(f.comments, comment)
+	return nil
+}
+
+func newPipelineRun() *pipelinev1beta1.PipelineRun {
+	startTime := metav1.NewTime(time.Date(2021, 1, 20, 10, 0, 0, 0, time.UTC))
+	completionTime := metav1.NewTime(time.Date(2021, 1, 20, 10, 0, 42, 0, time.UTC))
+
+	pipelineRun := &pipelinev1beta1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "ci-run-abc12",
+			Namespace: "dev",
+			Labels: map[string]string{
+				"workflows.dev/workflow": "ci",
+			},
+			Annotations: map[string]string{
+				"workflows.dev/repository":                    "john-doe/my-repo",
+				"workflows.dev/head-commit":                   "833568e",
+				"workflows.dev/pull-request":                  "7",
+				"workflows.dev/pull-request-comment":          "true",
+				"workflows.dev/pull-request-comment-logs-url": "https://dashboard.example.com/#/namespaces/$(pipelinerun.namespace)/pipelineruns/$(pipelinerun.name)",
+			},
+		},
+		Spec: pipelinev1beta1.PipelineRunSpec{
+			PipelineSpec: &pipelinev1beta1.PipelineSpec{
+				Tasks: []pipelinev1beta1.PipelineTask{
+					{Name: "lint"},
+					{Name: "test"},
+				},
+			},
+		},
+	}
+
+	pipelineRun.Status.StartTime = &startTime
+	pipelineRun.Status.CompletionTime = &completionTime
+	pipelineRun.Status.SetCondition(&apis.Condition{Type: apis.ConditionSucceeded,
+		Status: corev1.ConditionFalse,
+		Reason: "Failed",
+	})
+
+	lint := &pipelinev1beta1.TaskRunStatus{
+		Status: duckv1beta1.Status{
+			Conditions: duckv1beta1.Conditions{{Type: apis.ConditionSucceeded, Status: corev1.ConditionTrue, Reason: "Succeeded"}},
+		},
+	}
+	lint.StartTime = &startTime
+	lint.CompletionTime = &completionTime
+
+	test := &pipelinev1beta1.TaskRunStatus{
+		Status: duckv1beta1.Status{
+			Conditions: duckv1beta1.Conditions{{Type: apis.ConditionSucceeded, Status: corev1.ConditionFalse, Reason: "Failed"}},
+		},
+	}
+	test.StartTime = &startTime
+	test.CompletionTime = &completionTime
+	test.Steps = []pipelinev1beta1.StepState{
+		{Name: "checkout", ContainerState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 0}}},
+		{Name: "unit-tests", ContainerState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 1}}},
+	}
+
+	pipelineRun.Status.TaskRuns = map[string]*pipelinev1beta1.PipelineRunTaskRunStatus{
+		"ci-run-abc12-lint": {PipelineTaskName: "lint", Status: lint},
+		"ci-run-abc12-test": {PipelineTaskName: "test", Status: test},
+	}
+
+	return pipelineRun
+}
+
+func TestMakeComment(t *testing.T) {
+	got, err := makeComment(newPipelineRun())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := &github.StickyComment{Owner: "john-doe",
+		Repo:   "my-repo",
+		Number: 7,
+		Key:    "dev/ci",
+		Body: "### Workflow `ci` failed\n\n" +
+			"Commit 833568e | PipelineRun `dev/ci-run-abc12` | [View logs](https://dashboard.example.com/#/namespaces/dev/pipelineruns/ci-run-abc12)\n\n" +
+			"| Task | Status | Duration | Failed steps |\n" +
+			"| --- | --- | --- | --- |\n" +
+			"| lint | Succeeded | 42s | - |\n" +
+			"| test | Failed (Failed) | 42s | unit-tests |\n",
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestMakeCommentPublishesJobSummaries(t *testing.T) {
+	pipelineRun := newPipelineRun()
+	// Coverage: **87%**
+	pipelineRun.Status.TaskRuns["ci-run-abc12-test"].Status.TaskRunResults = []pipelinev1beta1.TaskRunResult{
+		{Name: "job-summary", Value: "H4sIAAAAAAACA3POL0stSkxPtVLQ0rIwV9XS4gIAizzv8hIAAAA="},
+	}
+
+	got, err := makeComment(pipelineRun)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := "| test | Failed (Failed) | 42s | unit-tests |\n" +
+		"\n" +
+		"#### Task `test`\n\n" +
+		"Coverage: **87%**\n\n"
+	if !strings.HasSuffix(got.Body, want) {
+		t.Errorf("Want the body to end with:\n%s\ngot:\n%s", want, got.Body)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	tests := []struct {
+		name         string
+		pipelineRun  func() *pipelinev1beta1.PipelineRun
+		wantComments int
+	}{
+		{"finished run", newPipelineRun, 1},
+		{"running run", func() *pipelinev1beta1.PipelineRun {
+			pipelineRun := newPipelineRun()
+			pipelineRun.Status.SetCondition(&apis.Condition{Type: apis.ConditionSucceeded, Status: corev1.ConditionUnknown})
+			return pipelineRun
+		}, 0},
+		{"comment already posted", func() *pipelinev1beta1.PipelineRun {
+			pipelineRun := newPipelineRun()
+			pipelineRun.Annotations["workflows.dev/pull-request-comment-posted"] = "true"
+			return pipelineRun
+		}, 0},
+		{"workflow without comments", func() *pipelinev1beta1.PipelineRun {
+			pipelineRun := newPipelineRun()
+			delete(pipelineRun.Annotations, "workflows.dev/pull-request-comment")
+			return pipelineRun
+		}, 0},
+	}
+
+	for _, test := range tests {
+		pipelineRun := test.pipelineRun()
+
+		indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+		if err := indexer.Add(pipelineRun); err != nil {
+			t.Fatal(err)
+		}
+
+		comments := &fakeCommentReconciler{}
+		tektonClientSet := faketektonclientset.NewSimpleClientset(pipelineRun)
+		reconciler := &Reconciler{
+			comments:          comments,
+			tektonClientSet:   tektonClientSet,
+			pipelineRunLister: listers.NewPipelineRunLister(indexer),
+		}
+
+		ctx := context.Background()
+
+		if err := reconciler.Reconcile(ctx, "dev/ci-run-abc12"); err != nil {
+			t.Fatalf("Fail in %s: unexpected error: %v", test.name, err)
+		}
+
+		if test.wantComments != len(comments.comments) {
+			t.Errorf("Fail in %s: want %d comments, got %d", test.name, test.wantComments, len(comments.comments))
+		}
+
+		if test.wantComments != 0 {
+			got, err := tektonClientSet.TektonV1beta1().PipelineRuns("dev").Get(ctx, "ci-run-abc12", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got.Annotations["workflows.dev/pull-request-comment-posted"] != "true" {
+				t.Errorf("Fail in %s: want the PipelineRun to be annotated as posted", test.name)
+			}
+		}
+	}
+}
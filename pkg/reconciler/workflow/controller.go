@@ -13,7 +13,7 @@ import (
 	workflowsclient "github.com/nubank/workflows/pkg/client/injection/client"
 	workflowinformer "github.com/nubank/workflows/pkg/client/injection/informers/workflows/v1alpha1/workflow"
 	workflowreconciler "github.com/nubank/workflows/pkg/client/injection/reconciler/workflows/v1alpha1/workflow"
-	"github.com/nubank/workflows/pkg/github"
+	"github.com/nubank/workflows/pkg/scm"
 )
 
 // NewController creates a Reconciler and returns the result of NewImpl.
@@ -25,10 +25,12 @@ func NewController(ctx context.Context, watcher configmap.Watcher) *controller.I
 	configStore := config.NewStore(logger.Named("configs"))
 	configStore.WatchConfigs(watcher)
 
+	providers := scm.GetProvidersOrDie(ctx)
+
 	reconciler := &Reconciler{
-		deployKeys:         github.GetDeployKeysReconcilerOrDie(ctx),
-		webhook:            github.GetWebhookReconcilerOrDie(ctx),
-		repositories:       github.GetRepoReconcilerOrDie(ctx),
+		deployKeys:         scm.NewDeployKeysReconciler(providers),
+		webhook:            scm.NewWebhookReconciler(providers),
+		repositories:       scm.NewRepoReconciler(providers),
 		kubeClientSet:      kubeclient.Get(ctx),
 		workflowsClientSet: workflowsclient.Get(ctx),
 		workflowLister:     workflowInformer.Lister(),
@@ -38,6 +40,8 @@ func NewController(ctx context.Context, watcher configmap.Watcher) *controller.I
 		return controller.Options{ConfigStore: configStore}
 	})
 
+	reconciler.enqueueAfter = impl.EnqueueAfter
+
 	logger.Info("Setting up event handlers")
 
 	workflowInformer.Informer().AddEventHandler(controller.HandleAll(impl.Enqueue))
@@ -3,6 +3,7 @@ package workflow
 import (
 	"context"
 	"fmt"
+	"time"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 
@@ -18,6 +19,7 @@ import (
 	workflowreconciler "github.com/nubank/workflows/pkg/client/injection/reconciler/workflows/v1alpha1/workflow"
 	listers "github.com/nubank/workflows/pkg/client/listers/workflows/v1alpha1"
 	"k8s.io/client-go/kubernetes"
+	"knative.dev/pkg/controller"
 	"knative.dev/pkg/kmp"
 	"knative.dev/pkg/logging"
 	"knative.dev/pkg/reconciler"
@@ -45,6 +47,10 @@ type Reconciler struct {
 
 	// workflowsClientSet allows us to configure Workflows objects.
 	workflowsClientSet workflowsclientset.Interface
+
+	// enqueueAfter schedules a workflow to be reconciled again after the
+	// supplied delay.
+	enqueueAfter func(interface{}, time.Duration)
 }
 
 // Check that our Reconciler implements the required interfaces
@@ -58,9 +64,21 @@ func (r *Reconciler) ReconcileKind(ctx context.Context, workflow *workflowsv1alp
 	logger := logging.FromContext(ctx)
 	logger.Info("Reconciling workflow")
 
+	return r.requeueIfRateLimited(ctx, workflow, r.reconcile(ctx, workflow))
+}
+
+// reconcile keeps the Github resources of the supplied workflow in sync with
+// its desired state.
+func (r *Reconciler) reconcile(ctx context.Context, workflow *workflowsv1alpha1.Workflow) error {
 	// Save a copy with the current state to compare later
 	currentWorkflow := workflow.DeepCopy()
 
+	ctx, err := r.withCredentials(ctx, workflow)
+	if err != nil {
+		workflow.Status.MarkCredentialsError(err.Error())
+		return err
+	}
+
 	// Set important attributes such as Repository.DefaultBranch and
 	// Repository.Private in all repositories declared in this workflow
 	if err := r.repositories.ReconcileRepos(ctx, workflow); err != nil {
@@ -96,6 +114,31 @@ func (r *Reconciler) ReconcileKind(ctx context.Context, workflow *workflowsv1alp
 	return nil
 }
 
+// requeueIfRateLimited schedules the workflow to be reconciled again once the
+// Github rate limit resets, when the supplied error was caused by it. The
+// error is then made permanent, so that the work queue doesn't retry right
+// away.
+func (r *Reconciler) requeueIfRateLimited(ctx context.Context, workflow *workflowsv1alpha1.Workflow, err error) error {
+	delay, rateLimited := github.RetryAfter(err)
+	if !rateLimited {
+		return err
+	}
+
+	logging.FromContext(ctx).Warnf("Reconciling workflow again in %s: %v", delay, err)
+	r.enqueueAfter(workflow, delay)
+	return controller.NewPermanentError(err)
+}
+
+// withCredentials returns a copy of the supplied context carrying the Github
+// credentials the workflow talks to Github with, if any.
+func (r *Reconciler) withCredentials(ctx context.Context, workflow *workflowsv1alpha1.Workflow) (context.Context, error) {
+	credentials, err := github.ResolveCredentials(ctx, r.kubeClientSet, workflow.GetNamespace(), workflow.GetCredentialsSecretName())
+	if err != nil {
+		return nil, err
+	}
+	return github.WithCredentials(ctx, credentials), nil
+}
+
 // reconcileWebhook keeps Github Webhooks in sync with the desired state
 // declared in workflows.
 func (r *Reconciler) reconcileWebhook(ctx context.Context, workflow *workflowsv1alpha1.Workflow) error {
@@ -236,6 +279,19 @@ func (r *Reconciler) reconcileDeployKeysSecret(ctx context.Context, workflow *wo
 
 // FinalizeKind implements Finalizer.FinalizeKind.
 func (r *Reconciler) FinalizeKind(ctx context.Context, workflow *workflowsv1alpha1.Workflow) reconciler.Event {
+	return r.requeueIfRateLimited(ctx, workflow, r.finalize(ctx, workflow))
+}
+
+// finalize deletes the Github resources of the supplied workflow.
+func (r *Reconciler) finalize(ctx context.Context, workflow *workflowsv1alpha1.Workflow) error {
+	if credentialsCtx, err := r.withCredentials(ctx, workflow); err != nil {
+		// The Secret may have been deleted along with the namespace. Try
+		// with the controller's Github App rather than blocking deletion.
+		logging.FromContext(ctx).Warnf("Finalizing workflow without its Github credentials: %v", err)
+	} else {
+		ctx = credentialsCtx
+	}
+
 	if err := r.webhook.Delete(ctx, workflow); err != nil {
 		return err
 	}
@@ -0,0 +1,219 @@
+// Package rest implements what providers whose repositories are managed
+// through a plain REST API (e.g. GitLab and Gitea) have in common: the client
+// and the reconcilers of repositories, webhooks and deploy keys, which rely on
+// the API of each provider to tell them apart.
+package rest
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	"github.com/nubank/workflows/pkg/github"
+)
+
+const (
+
+	// Default timeout for calling providers.
+	timeout = time.Second * 15
+
+	// How many bytes of error responses are kept in errors.
+	maxErrorBodyBytes = 4096
+)
+
+// API describes the REST API of a provider.
+type API interface {
+
+	// Product returns the name of the provider, as shown in errors.
+	Product() string
+
+	// Authenticate sets the credentials of the supplied request.
+	Authenticate(request *http.Request)
+
+	// RepoPath returns the path under which the API serves the supplied
+	// repository.
+	RepoPath(repo *workflowsv1alpha1.Repository) string
+
+	// Repository returns an empty repository to decode responses into.
+	Repository() Repository
+
+	// RawFilePath returns the path under which the API serves the raw
+	// content of a file of the supplied repository at the ref in question.
+	RawFilePath(repo *workflowsv1alpha1.Repository, filePath, ref string) string
+
+	// KeysPath returns the path under which the API serves the deploy keys
+	// of the supplied repository.
+	KeysPath(repo *workflowsv1alpha1.Repository) string
+
+	// DeployKey returns an empty deploy key to decode responses into.
+	DeployKey() DeployKey
+
+	// NewDeployKey returns the deploy key to create.
+	NewDeployKey(title, publicKey string, readOnly bool) DeployKey
+
+	// HooksPath returns the path under which the API serves the webhooks of
+	// the supplied repository.
+	HooksPath(repo *workflowsv1alpha1.Repository) string
+
+	// HookUpdateMethod returns the HTTP method webhooks are updated with.
+	HookUpdateMethod() string
+
+	// Webhook returns an empty webhook to decode responses into.
+	Webhook() Webhook
+
+	// NewWebhook returns the desired state of the workflow's webhook. The
+	// secret is left untouched by updates when it's empty.
+	NewWebhook(workflow *workflowsv1alpha1.Workflow, secret string) Webhook
+}
+
+// Client talks to the REST API of a provider.
+type Client struct {
+	api        API
+	host       *github.Host
+	httpClient *http.Client
+}
+
+// NewClient returns a Client for the API served under the supplied path of
+// the instance at the base URL in question, trusting the certificates of the
+// supplied bundle if any. Repositories are cloned over SSH from the supplied
+// URL when it's set.
+func NewClient(api API, baseURL, apiPath, sshURL string, caBundle []byte) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("Invalid %s URL %s: expected an absolute HTTP(S) URL", api.Product(), baseURL)
+	}
+
+	baseURL = strings.TrimSuffix(baseURL, "/")
+
+	// Repositories are checked out the same way as those hosted by Github.
+	host := &github.Host{APIURL: baseURL + apiPath,
+		GitURL:   baseURL,
+		SSHURL:   strings.TrimSuffix(sshURL, "/"),
+		CABundle: caBundle,
+	}
+
+	return &Client{api: api,
+		host:       host,
+		httpClient: &http.Client{Transport: host.Transport(), Timeout: timeout},
+	}, nil
+}
+
+// ReadCABundle reads the PEM encoded certificates of the file whose path is
+// set by the supplied environment variable, if any.
+func ReadCABundle(env string) ([]byte, error) {
+	path, ok := os.LookupEnv(env)
+	if !ok || path == "" {
+		return nil, nil
+	}
+
+	caBundle, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the CA bundle set by %s: %w", env, err)
+	}
+
+	if !x509.NewCertPool().AppendCertsFromPEM(caBundle) {
+		return nil, fmt.Errorf("The CA bundle set by %s doesn't contain any PEM encoded certificate", env)
+	}
+	return caBundle, nil
+}
+
+// Host returns the host repositories are checked out from.
+func (c *Client) Host() *github.Host {
+	return c.host
+}
+
+// ResponseError is returned when a provider answers with an unsuccessful
+// status.
+type ResponseError struct {
+	Product    string
+	Method     string
+	Path       string
+	StatusCode int
+	Message    string
+}
+
+// Error satisfies the error interface.
+func (r *ResponseError) Error() string {
+	return fmt.Sprintf("%s answered %s %s with status %d: %s", r.Product, r.Method, r.Path, r.StatusCode, r.Message)
+}
+
+// HasStatus returns true if the supplied error is a ResponseError with the
+// status in question.
+func HasStatus(err error, statusCode int) bool {
+	var responseErr *ResponseError
+	return errors.As(err, &responseErr) && responseErr.StatusCode == statusCode
+}
+
+// Do sends a request with the supplied JSON body (if any) to the API path in
+// question and decodes the JSON response into out (if any).
+func (c *Client) Do(ctx context.Context, method, path string, body, out interface{}) error {
+	content, err := c.DoRaw(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+
+	if out == nil || len(content) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(content, out); err != nil {
+		return fmt.Errorf("Error decoding the response to %s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+// DoRaw sends a request with the supplied JSON body (if any) to the API path
+// in question and returns the response body as is.
+func (c *Client) DoRaw(ctx context.Context, method, path string, body interface{}) ([]byte, error) {
+	var reader io.Reader
+	if body != nil {
+		content, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(content)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, c.host.APIURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	c.api.Authenticate(request)
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("Error calling %s: %w", c.api.Product(), err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		message, _ := ioutil.ReadAll(io.LimitReader(response.Body, maxErrorBodyBytes))
+		return nil, &ResponseError{Product: c.api.Product(),
+			Method:     method,
+			Path:       path,
+			StatusCode: response.StatusCode,
+			Message:    strings.TrimSpace(string(message)),
+		}
+	}
+
+	content, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the response to %s %s: %w", method, path, err)
+	}
+	return content, nil
+}
@@ -0,0 +1,144 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	"github.com/nubank/workflows/pkg/github"
+	"github.com/nubank/workflows/pkg/secrets"
+	"knative.dev/pkg/logging"
+)
+
+// DeployKey is a deploy key, as sent to and returned by the API of a provider.
+type DeployKey interface {
+
+	// GetID returns the id of the deploy key.
+	GetID() int64
+
+	// IsReadOnly returns true if the deploy key can't push to the repository.
+	IsReadOnly() bool
+}
+
+// deployKeysReconciler implements github.DeployKeysReconciler.
+type deployKeysReconciler struct {
+	client *Client
+}
+
+// ReconcileKeys creates or updates the deploy keys of repositories that need
+// them.
+func (d *deployKeysReconciler) ReconcileKeys(ctx context.Context, workflow *workflowsv1alpha1.Workflow) ([]secrets.KeyPair, error) {
+	keyPairs := make([]secrets.KeyPair, 0)
+
+	for _, repo := range workflow.GetRepositories() {
+		if repo.NeedsSSHPrivateKeys() {
+			if keyPair, err := d.reconcileKey(ctx, workflow, &repo); err != nil {
+				return nil, err
+			} else if keyPair != nil {
+				keyPairs = append(keyPairs, *keyPair)
+			}
+		}
+	}
+	return keyPairs, nil
+}
+
+// reconcileKey creates the deploy key of the supplied repository, or rotates
+// it when its permissions changed.
+func (d *deployKeysReconciler) reconcileKey(ctx context.Context, workflow *workflowsv1alpha1.Workflow, repo *workflowsv1alpha1.Repository) (*secrets.KeyPair, error) {
+	logger := logging.FromContext(ctx).With("repository", repo)
+
+	id := workflow.GetDeployKeyID(repo)
+	if id == nil {
+		logger.Info("There are no recognized deploy keys associated to the workflow. Creating a new one")
+		return d.createDeployKey(ctx, workflow, repo)
+	}
+
+	current := d.client.api.DeployKey()
+	err := d.client.Do(ctx, http.MethodGet, d.keyPath(repo, *id), nil, current)
+	if HasStatus(err, http.StatusNotFound) {
+		logger.Infow("Unable to find a deploy key for the supplied id. It might have been deleted by mistake. Creating a new one", "deploy-key-id", *id)
+		return d.createDeployKey(ctx, workflow, repo)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get deploy key #%d: %w", *id, err)
+	}
+
+	if current.IsReadOnly() != repo.IsReadOnlyDeployKey() {
+		logger.Infow("Deploy key and workflow settings are out of sync. Rotating deploy key", "deploy-key-id", *id)
+		if err := d.deleteDeployKey(ctx, repo, *id); err != nil {
+			return nil, err
+		}
+		return d.createDeployKey(ctx, workflow, repo)
+	}
+
+	logger.Infow("Deploy key settings are up to date", "deploy-key-id", *id)
+
+	return nil, nil
+}
+
+// keyPath returns the API path of the supplied deploy key.
+func (d *deployKeysReconciler) keyPath(repo *workflowsv1alpha1.Repository, id int64) string {
+	return fmt.Sprintf("%s/%d", d.client.api.KeysPath(repo), id)
+}
+
+// createDeployKey creates a new deploy key, recording its id in the workflow.
+func (d *deployKeysReconciler) createDeployKey(ctx context.Context, workflow *workflowsv1alpha1.Workflow, repo *workflowsv1alpha1.Repository) (*secrets.KeyPair, error) {
+	keyPair, err := secrets.GenerateKeyPair(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	key := d.client.api.NewDeployKey(fmt.Sprintf("%s-ssh-public-key", workflow.GetName()), string(keyPair.PublicKey), repo.IsReadOnlyDeployKey())
+	created := d.client.api.DeployKey()
+	if err := d.client.Do(ctx, http.MethodPost, d.client.api.KeysPath(repo), key, created); err != nil {
+		return nil, fmt.Errorf("unable to create %s deploy key for repository %s: %w", d.client.api.Product(), repo, err)
+	}
+
+	logger := logging.FromContext(ctx)
+	logger.Infow("DeployKey has been successfully created",
+		"repository", repo,
+		"deploy-key-id", created.GetID())
+
+	workflow.SetDeployKeyID(repo, created.GetID())
+	return keyPair, nil
+}
+
+// deleteDeployKey deletes an existing deploy key. Keys already deleted are
+// ignored.
+func (d *deployKeysReconciler) deleteDeployKey(ctx context.Context, repo *workflowsv1alpha1.Repository, id int64) error {
+	err := d.client.Do(ctx, http.MethodDelete, d.keyPath(repo, id), nil, nil)
+	if err != nil && !HasStatus(err, http.StatusNotFound) {
+		return fmt.Errorf("unable to delete %s deploy key for repository %s: %w", d.client.api.Product(), repo, err)
+	}
+
+	logger := logging.FromContext(ctx)
+	logger.Infow("Deploy key has been successfully deleted", "repository", repo, "deploy-key-id", id)
+
+	return nil
+}
+
+// Delete deletes all deploy keys associated to the workflow in question.
+func (d *deployKeysReconciler) Delete(ctx context.Context, workflow *workflowsv1alpha1.Workflow) error {
+	for _, repo := range workflow.GetRepositories() {
+		if repo.NeedsSSHPrivateKeys() {
+			id := workflow.GetDeployKeyID(&repo)
+
+			if id == nil {
+				return fmt.Errorf("Error deleting deploy key for repository %s: the key's identifier is unknown", repo.String())
+			}
+
+			if err := d.deleteDeployKey(ctx, &repo, *id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// NewDeployKeysReconciler returns a new DeployKeysReconciler for the deploy
+// keys of repositories served by the supplied client.
+func NewDeployKeysReconciler(client *Client) github.DeployKeysReconciler {
+	return &deployKeysReconciler{client: client}
+}
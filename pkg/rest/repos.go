@@ -0,0 +1,60 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	"github.com/nubank/workflows/pkg/github"
+)
+
+// Repository is a repository, as returned by the API of a provider.
+type Repository interface {
+
+	// GetDefaultBranch returns the default branch of the repository.
+	GetDefaultBranch() string
+
+	// IsPrivate returns true if the repository can't be cloned anonymously.
+	IsPrivate() bool
+}
+
+// repoReconciler implements github.RepoReconciler.
+type repoReconciler struct {
+	client *Client
+}
+
+// ReconcileRepos implements github.RepoReconciler.
+func (r *repoReconciler) ReconcileRepos(ctx context.Context, workflow *workflowsv1alpha1.Workflow) error {
+	if err := r.setRepoInfo(ctx, workflow.Spec.Repository); err != nil {
+		return err
+	}
+
+	for i := range workflow.Spec.AdditionalRepositories {
+		if err := r.setRepoInfo(ctx, &workflow.Spec.AdditionalRepositories[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setRepoInfo sets the DefaultBranch and Private attributes of the supplied
+// repository according to the one returned by the API.
+func (r *repoReconciler) setRepoInfo(ctx context.Context, repo *workflowsv1alpha1.Repository) error {
+	current := r.client.api.Repository()
+	if err := r.client.Do(ctx, http.MethodGet, r.client.api.RepoPath(repo), nil, current); err != nil {
+		return fmt.Errorf("Error fetching %s repository %s: %w", r.client.api.Product(), repo, err)
+	}
+
+	repo.DefaultBranch = current.GetDefaultBranch()
+	repo.Private = current.IsPrivate()
+
+	return nil
+}
+
+// NewRepoReconciler returns a new RepoReconciler for repositories served by
+// the supplied client.
+func NewRepoReconciler(client *Client) github.RepoReconciler {
+	return &repoReconciler{client: client}
+}
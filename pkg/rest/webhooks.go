@@ -0,0 +1,118 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	"github.com/nubank/workflows/pkg/github"
+	"github.com/nubank/workflows/pkg/secrets"
+	"knative.dev/pkg/logging"
+)
+
+// Webhook is a repository webhook, as sent to and returned by the API of a
+// provider.
+type Webhook interface {
+
+	// GetID returns the id of the webhook.
+	GetID() int64
+
+	// ChangedFrom returns true if the webhook differs from the supplied
+	// desired state, ignoring secrets which the API never returns.
+	ChangedFrom(desired Webhook) bool
+}
+
+// webhookReconciler implements github.WebhookReconciler.
+type webhookReconciler struct {
+	client *Client
+}
+
+// ReconcileHook creates or updates the webhook of the supplied workflow.
+func (w *webhookReconciler) ReconcileHook(ctx context.Context, workflow *workflowsv1alpha1.Workflow) (*github.Webhook, error) {
+	repo := workflow.Spec.Repository
+	logger := logging.FromContext(ctx).With("repository", repo)
+
+	id := workflow.GetWebhookID()
+	if id == nil {
+		logger.Info("There are no recognized webhooks associated to the workflow. Creating a new one")
+		return w.createHook(ctx, workflow)
+	}
+
+	current := w.client.api.Webhook()
+	err := w.client.Do(ctx, http.MethodGet, w.hookPath(repo, *id), nil, current)
+	if HasStatus(err, http.StatusNotFound) {
+		logger.Infow("Unable to find webhook for supplied id. It might have been deleted by mistake. Creating a new one", "webhook-id", *id)
+		return w.createHook(ctx, workflow)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get webhook #%d: %w", *id, err)
+	}
+
+	desired := w.client.api.NewWebhook(workflow, "")
+	if current.ChangedFrom(desired) {
+		logger.Infow("Webhook and workflow settings are out of sync. Updating webhook", "webhook-id", *id)
+		if err := w.client.Do(ctx, w.client.api.HookUpdateMethod(), w.hookPath(repo, *id), desired, nil); err != nil {
+			return nil, fmt.Errorf("unable to update %s webhook for repository %s: %w", w.client.api.Product(), repo, err)
+		}
+
+		logger.Infow("Webhook has been successfully updated", "webhook-id", *id)
+		return &github.Webhook{ID: *id}, nil
+	}
+
+	logger.Infow("Webhook settings are up to date", "webhook-id", *id)
+
+	return nil, nil
+}
+
+// hookPath returns the API path of the supplied webhook.
+func (w *webhookReconciler) hookPath(repo *workflowsv1alpha1.Repository, id int64) string {
+	return fmt.Sprintf("%s/%d", w.client.api.HooksPath(repo), id)
+}
+
+// createHook creates a new webhook, recording its id in the workflow.
+func (w *webhookReconciler) createHook(ctx context.Context, workflow *workflowsv1alpha1.Workflow) (*github.Webhook, error) {
+	repo := workflow.Spec.Repository
+	secretToken := secrets.GenerateRandomToken()
+
+	created := w.client.api.Webhook()
+	if err := w.client.Do(ctx, http.MethodPost, w.client.api.HooksPath(repo), w.client.api.NewWebhook(workflow, secretToken), created); err != nil {
+		return nil, fmt.Errorf("unable to create %s webhook for repository %s: %w", w.client.api.Product(), repo, err)
+	}
+
+	logger := logging.FromContext(ctx)
+	logger.Infow("Webhook has been successfully created",
+		"repository", repo,
+		"webhook-id", created.GetID())
+
+	workflow.SetWebhookID(created.GetID())
+	return &github.Webhook{ID: created.GetID(), Secret: []byte(secretToken)}, nil
+}
+
+// Delete deletes the webhook associated to the workflow in question. Webhooks
+// already deleted are ignored.
+func (w *webhookReconciler) Delete(ctx context.Context, workflow *workflowsv1alpha1.Workflow) error {
+	repo := workflow.Spec.Repository
+
+	id := workflow.GetWebhookID()
+	if id == nil {
+		return fmt.Errorf("Unable to delete webhook because its identifier is unknown")
+	}
+
+	err := w.client.Do(ctx, http.MethodDelete, w.hookPath(repo, *id), nil, nil)
+	if err != nil && !HasStatus(err, http.StatusNotFound) {
+		return fmt.Errorf("Error deleting %s webhook: %w", w.client.api.Product(), err)
+	}
+
+	logger := logging.FromContext(ctx)
+	logger.Infow("Webhook has been successfully deleted", "repository", repo, "webhook-id", *id)
+
+	return nil
+}
+
+// NewWebhookReconciler returns a new WebhookReconciler for the webhooks of
+// repositories served by the supplied client.
+func NewWebhookReconciler(client *Client) github.WebhookReconciler {
+	return &webhookReconciler{client: client}
+}
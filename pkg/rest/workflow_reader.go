@@ -0,0 +1,37 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	"github.com/nubank/workflows/pkg/github"
+)
+
+// workflowReader implements github.WorkflowReader.
+type workflowReader struct {
+	client *Client
+}
+
+// GetWorkflowContent implements github.WorkflowReader.
+func (w *workflowReader) GetWorkflowContent(ctx context.Context, workflow *workflowsv1alpha1.Workflow, filePath, ref string) (*workflowsv1alpha1.Workflow, error) {
+	path := w.client.api.RawFilePath(workflow.Spec.Repository, filePath, ref)
+
+	content, err := w.client.DoRaw(ctx, http.MethodGet, path, nil)
+	if HasStatus(err, http.StatusNotFound) {
+		return nil, github.NewNotFoundError(fmt.Sprintf("Unable to find workflow %s", workflow.GetName()))
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return github.ParseWorkflow(workflow, content)
+}
+
+// NewWorkflowReader returns a new WorkflowReader for workflows stored in
+// repositories served by the supplied client.
+func NewWorkflowReader(client *Client) github.WorkflowReader {
+	return &workflowReader{client: client}
+}
@@ -0,0 +1,137 @@
+package scm
+
+import (
+	"context"
+
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	"github.com/nubank/workflows/pkg/github"
+	"github.com/nubank/workflows/pkg/secrets"
+)
+
+// repoReconciler implements github.RepoReconciler by dispatching to the
+// provider hosting the workflow's repositories.
+type repoReconciler struct {
+	providers Providers
+}
+
+// ReconcileRepos implements github.RepoReconciler.
+func (r *repoReconciler) ReconcileRepos(ctx context.Context, workflow *workflowsv1alpha1.Workflow) error {
+	provider, err := r.providers.For(workflow)
+	if err != nil {
+		return err
+	}
+	return provider.Repositories.ReconcileRepos(ctx, workflow)
+}
+
+// NewRepoReconciler returns a RepoReconciler for repositories hosted by any of
+// the supplied providers.
+func NewRepoReconciler(providers Providers) github.RepoReconciler {
+	return &repoReconciler{providers: providers}
+}
+
+// webhookReconciler implements github.WebhookReconciler by dispatching to the
+// provider hosting the workflow's repositories.
+type webhookReconciler struct {
+	providers Providers
+}
+
+// ReconcileHook implements github.WebhookReconciler.
+func (w *webhookReconciler) ReconcileHook(ctx context.Context, workflow *workflowsv1alpha1.Workflow) (*github.Webhook, error) {
+	provider, err := w.providers.For(workflow)
+	if err != nil {
+		return nil, err
+	}
+	return provider.Webhooks.ReconcileHook(ctx, workflow)
+}
+
+// Delete implements github.WebhookReconciler.
+func (w *webhookReconciler) Delete(ctx context.Context, workflow *workflowsv1alpha1.Workflow) error {
+	provider, err := w.providers.For(workflow)
+	if err != nil {
+		return err
+	}
+	return provider.Webhooks.Delete(ctx, workflow)
+}
+
+// NewWebhookReconciler returns a WebhookReconciler for repositories hosted by
+// any of the supplied providers.
+func NewWebhookReconciler(providers Providers) github.WebhookReconciler {
+	return &webhookReconciler{providers: providers}
+}
+
+// deployKeysReconciler implements github.DeployKeysReconciler by dispatching
+// to the provider hosting the workflow's repositories.
+type deployKeysReconciler struct {
+	providers Providers
+}
+
+// ReconcileKeys implements github.DeployKeysReconciler.
+func (d *deployKeysReconciler) ReconcileKeys(ctx context.Context, workflow *workflowsv1alpha1.Workflow) ([]secrets.KeyPair, error) {
+	provider, err := d.providers.For(workflow)
+	if err != nil {
+		return nil, err
+	}
+	return provider.DeployKeys.ReconcileKeys(ctx, workflow)
+}
+
+// Delete implements github.DeployKeysReconciler.
+func (d *deployKeysReconciler) Delete(ctx context.Context, workflow *workflowsv1alpha1.Workflow) error {
+	provider, err := d.providers.For(workflow)
+	if err != nil {
+		return err
+	}
+	return provider.DeployKeys.Delete(ctx, workflow)
+}
+
+// NewDeployKeysReconciler returns a DeployKeysReconciler for repositories
+// hosted by any of the supplied providers.
+func NewDeployKeysReconciler(providers Providers) github.DeployKeysReconciler {
+	return &deployKeysReconciler{providers: providers}
+}
+
+// workflowReader implements github.WorkflowReader by dispatching to the
+// provider hosting the workflow's repositories.
+type workflowReader struct {
+	providers Providers
+}
+
+// GetWorkflowContent implements github.WorkflowReader.
+func (w *workflowReader) GetWorkflowContent(ctx context.Context, workflow *workflowsv1alpha1.Workflow, filePath, ref string) (*workflowsv1alpha1.Workflow, error) {
+	provider, err := w.providers.For(workflow)
+	if err != nil {
+		return nil, err
+	}
+	return provider.WorkflowReader.GetWorkflowContent(ctx, workflow, filePath, ref)
+}
+
+// NewWorkflowReader returns a WorkflowReader for workflows stored in
+// repositories hosted by any of the supplied providers.
+func NewWorkflowReader(providers Providers) github.WorkflowReader {
+	return &workflowReader{providers: providers}
+}
+
+// eventCompleter implements github.EventCompleter by dispatching to the
+// provider hosting the workflow's repositories.
+type eventCompleter struct {
+	providers Providers
+}
+
+// CompleteEvent implements github.EventCompleter. Events of providers that
+// describe them fully are left untouched.
+func (e *eventCompleter) CompleteEvent(ctx context.Context, workflow *workflowsv1alpha1.Workflow, event *github.Event) error {
+	provider, err := e.providers.For(workflow)
+	if err != nil {
+		return err
+	}
+
+	if provider.Events == nil {
+		return nil
+	}
+	return provider.Events.CompleteEvent(ctx, workflow, event)
+}
+
+// NewEventCompleter returns an EventCompleter for events delivered by any of
+// the supplied providers.
+func NewEventCompleter(providers Providers) github.EventCompleter {
+	return &eventCompleter{providers: providers}
+}
@@ -0,0 +1,224 @@
+// Package scm dispatches the management of repositories to the source code
+// management provider hosting them (e.g. Github, GitLab, Bitbucket or Gitea).
+package scm
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	"github.com/nubank/workflows/pkg/bitbucket"
+	"github.com/nubank/workflows/pkg/gitea"
+	"github.com/nubank/workflows/pkg/github"
+	"github.com/nubank/workflows/pkg/gitlab"
+)
+
+// Provider bundles what workflows need to manage repositories hosted by a
+// given provider.
+type Provider struct {
+
+	// Host describes where repositories are checked out from.
+	Host *github.Host
+
+	// Repositories fills in information about repositories.
+	Repositories github.RepoReconciler
+
+	// Webhooks manages the hooks delivering events to workflows.
+	Webhooks github.WebhookReconciler
+
+	// DeployKeys manages the keys repositories are checked out with.
+	DeployKeys github.DeployKeysReconciler
+
+	// WorkflowReader reads workflows declared in repositories.
+	WorkflowReader github.WorkflowReader
+
+	// Events fills in details that the provider leaves out of the payloads of
+	// events, if any.
+	Events github.EventCompleter
+}
+
+// NewGithubProvider returns the Provider of repositories hosted by the
+// supplied Github instance.
+func NewGithubProvider(host *github.Host, clients github.ClientFactory) *Provider {
+	return &Provider{Host: host,
+		Repositories:   github.NewRepoReconciler(clients),
+		Webhooks:       github.NewWebhookReconciler(clients),
+		DeployKeys:     github.NewDeployKeysReconciler(clients),
+		WorkflowReader: github.NewWorkflowReader(clients),
+	}
+}
+
+// NewGitlabProvider returns the Provider of repositories hosted by the GitLab
+// instance the supplied client talks to.
+func NewGitlabProvider(client *gitlab.Client) *Provider {
+	return &Provider{Host: client.Host(),
+		Repositories:   gitlab.NewRepoReconciler(client),
+		Webhooks:       gitlab.NewWebhookReconciler(client),
+		DeployKeys:     gitlab.NewDeployKeysReconciler(client),
+		WorkflowReader: gitlab.NewWorkflowReader(client),
+	}
+}
+
+// NewBitbucketCloudProvider returns the Provider of repositories hosted by
+// Bitbucket Cloud.
+func NewBitbucketCloudProvider(client *bitbucket.Client) *Provider {
+	return &Provider{Host: client.Host(),
+		Repositories:   bitbucket.NewCloudRepoReconciler(client),
+		Webhooks:       bitbucket.NewCloudWebhookReconciler(client),
+		DeployKeys:     bitbucket.NewCloudDeployKeysReconciler(client),
+		WorkflowReader: bitbucket.NewCloudWorkflowReader(client),
+		Events:         bitbucket.NewCloudEventCompleter(client),
+	}
+}
+
+// NewBitbucketServerProvider returns the Provider of repositories hosted by the
+// Bitbucket Server instance the supplied client talks to.
+func NewBitbucketServerProvider(client *bitbucket.Client) *Provider {
+	return &Provider{Host: client.Host(),
+		Repositories:   bitbucket.NewServerRepoReconciler(client),
+		Webhooks:       bitbucket.NewServerWebhookReconciler(client),
+		DeployKeys:     bitbucket.NewServerDeployKeysReconciler(client),
+		WorkflowReader: bitbucket.NewServerWorkflowReader(client),
+		Events:         bitbucket.NewServerEventCompleter(client),
+	}
+}
+
+// NewGiteaProvider returns the Provider of repositories hosted by the Gitea
+// (or Forgejo) instance the supplied client talks to.
+func NewGiteaProvider(client *gitea.Client) *Provider {
+	return &Provider{Host: client.Host(),
+		Repositories:   gitea.NewRepoReconciler(client),
+		Webhooks:       gitea.NewWebhookReconciler(client),
+		DeployKeys:     gitea.NewDeployKeysReconciler(client),
+		WorkflowReader: gitea.NewWorkflowReader(client),
+	}
+}
+
+// Providers holds the configured providers by name.
+type Providers map[workflowsv1alpha1.Provider]*Provider
+
+// NewProvidersFromEnv returns the supplied Github provider along with those
+// configured through environment variables and mounted credentials.
+func NewProvidersFromEnv(githubHost *github.Host, githubClients github.ClientFactory) (Providers, error) {
+	providers := Providers{
+		workflowsv1alpha1.GithubProvider: NewGithubProvider(githubHost, githubClients),
+	}
+
+	gitlabClient, err := gitlab.NewClientFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("Error configuring GitLab: %w", err)
+	}
+
+	if gitlabClient != nil {
+		providers[workflowsv1alpha1.GitlabProvider] = NewGitlabProvider(gitlabClient)
+	}
+
+	bitbucketCloudClient, err := bitbucket.NewCloudClientFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("Error configuring Bitbucket Cloud: %w", err)
+	}
+
+	if bitbucketCloudClient != nil {
+		providers[workflowsv1alpha1.BitbucketCloudProvider] = NewBitbucketCloudProvider(bitbucketCloudClient)
+	}
+
+	bitbucketServerClient, err := bitbucket.NewServerClientFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("Error configuring Bitbucket Server: %w", err)
+	}
+
+	if bitbucketServerClient != nil {
+		providers[workflowsv1alpha1.BitbucketServerProvider] = NewBitbucketServerProvider(bitbucketServerClient)
+	}
+
+	giteaClient, err := gitea.NewClientFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("Error configuring Gitea: %w", err)
+	}
+
+	if giteaClient != nil {
+		providers[workflowsv1alpha1.GiteaProvider] = NewGiteaProvider(giteaClient)
+	}
+
+	return providers, nil
+}
+
+// For returns the provider hosting the repositories of the supplied workflow.
+func (p Providers) For(workflow *workflowsv1alpha1.Workflow) (*Provider, error) {
+	if provider, ok := p[workflow.GetProvider()]; ok {
+		return provider, nil
+	}
+	return nil, fmt.Errorf("Repositories hosted by %s aren't supported: the provider isn't configured", workflow.GetProvider())
+}
+
+// webhookFormat describes the requests delivered by the hooks of a provider.
+type webhookFormat struct {
+
+	// Name of the product whose hooks deliver the requests.
+	product string
+
+	// parse creates a new Event object from requests of the format.
+	parse func(request *http.Request) (*github.Event, error)
+}
+
+// webhookFormats maps providers to the format of requests their hooks deliver.
+var webhookFormats = map[workflowsv1alpha1.Provider]webhookFormat{
+	workflowsv1alpha1.GithubProvider:          {product: "Github", parse: github.ParseWebhookEvent},
+	workflowsv1alpha1.GitlabProvider:          {product: "GitLab", parse: gitlab.ParseWebhookEvent},
+	workflowsv1alpha1.BitbucketCloudProvider:  {product: "Bitbucket", parse: bitbucket.ParseWebhookEvent},
+	workflowsv1alpha1.BitbucketServerProvider: {product: "Bitbucket", parse: bitbucket.ParseWebhookEvent},
+	workflowsv1alpha1.GiteaProvider:           {product: "Gitea", parse: gitea.ParseWebhookEvent},
+}
+
+// deliveredBy returns the name of the product whose hook delivered the
+// supplied request, judging by its headers.
+func deliveredBy(request *http.Request) string {
+	switch {
+	case gitlab.IsWebhookRequest(request):
+		return "GitLab"
+	case bitbucket.IsWebhookRequest(request):
+		return "Bitbucket"
+	// Gitea sends Github headers as well.
+	case gitea.IsWebhookRequest(request):
+		return "Gitea"
+	default:
+		return "Github"
+	}
+}
+
+// ParseWebhookEvent creates a new Event object from the supplied HTTP request
+// delivered by the hook of a workflow whose repositories are hosted by the
+// provider in question. Requests whose headers were set by the hooks of other
+// providers are rejected rather than parsed with a parser they pick.
+func ParseWebhookEvent(provider workflowsv1alpha1.Provider, request *http.Request) (*github.Event, error) {
+	format, ok := webhookFormats[provider]
+	if !ok {
+		return nil, fmt.Errorf("Events delivered by %s aren't supported", provider)
+	}
+
+	if product := deliveredBy(request); product != format.product {
+		return nil, fmt.Errorf("Request appears to have been delivered by a %s hook but the workflow's repositories are hosted by %s", product, provider)
+	}
+	return format.parse(request)
+}
+
+type providersKey struct {
+}
+
+// WithProviders returns a copy of the supplied context with the supplied
+// providers added.
+func WithProviders(ctx context.Context, providers Providers) context.Context {
+	return context.WithValue(ctx, providersKey{}, providers)
+}
+
+// GetProvidersOrDie returns the providers from the supplied context or dies by
+// calling log.fatal if the context doesn't contain them.
+func GetProvidersOrDie(ctx context.Context) Providers {
+	if providers, ok := ctx.Value(providersKey{}).(Providers); ok {
+		return providers
+	}
+	log.Fatal("Unable to get valid providers from context")
+	return nil
+}
@@ -0,0 +1,136 @@
+package scm
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	"github.com/nubank/workflows/pkg/github"
+	githubmocks "github.com/nubank/workflows/pkg/github/mocks"
+)
+
+func newWorkflow(provider workflowsv1alpha1.Provider) *workflowsv1alpha1.Workflow {
+	workflow := &workflowsv1alpha1.Workflow{
+		Spec: workflowsv1alpha1.WorkflowSpec{
+			Repository: &workflowsv1alpha1.Repository{Owner: "my-group", Name: "my-project", Provider: provider},
+		},
+	}
+	workflow.SetName("test-1")
+	return workflow
+}
+
+func TestDispatchesToTheProviderHostingRepositories(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	githubReader := githubmocks.NewMockWorkflowReader(mockCtrl)
+	gitlabReader := githubmocks.NewMockWorkflowReader(mockCtrl)
+
+	reader := NewWorkflowReader(Providers{
+		workflowsv1alpha1.GithubProvider: {WorkflowReader: githubReader},
+		workflowsv1alpha1.GitlabProvider: {WorkflowReader: gitlabReader},
+	})
+
+	ctx := context.Background()
+
+	gitlabWorkflow := newWorkflow(workflowsv1alpha1.GitlabProvider)
+	gitlabReader.EXPECT().GetWorkflowContent(ctx, gitlabWorkflow, ".workflows/test-1.yaml", "a1b2c3").Return(gitlabWorkflow, nil)
+	if _, err := reader.GetWorkflowContent(ctx, gitlabWorkflow, ".workflows/test-1.yaml", "a1b2c3"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	// Repositories are hosted by Github unless stated otherwise.
+	githubWorkflow := newWorkflow("")
+	githubReader.EXPECT().GetWorkflowContent(ctx, githubWorkflow, ".workflows/test-1.yaml", "a1b2c3").Return(githubWorkflow, nil)
+	if _, err := reader.GetWorkflowContent(ctx, githubWorkflow, ".workflows/test-1.yaml", "a1b2c3"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestReturnsAnErrorWhenTheProviderIsNotConfigured(t *testing.T) {
+	providers := Providers{workflowsv1alpha1.GithubProvider: {}}
+
+	err := NewRepoReconciler(providers).ReconcileRepos(context.Background(), newWorkflow(workflowsv1alpha1.GitlabProvider))
+
+	want := "Repositories hosted by gitlab aren't supported: the provider isn't configured"
+	if err == nil || err.Error() != want {
+		t.Errorf("Want error %s, got %v", want, err)
+	}
+}
+
+func TestParsesEventsWithTheProviderOfTheWorkflow(t *testing.T) {
+	tests := []struct {
+		provider workflowsv1alpha1.Provider
+		header   string
+		value    string
+		want     string
+	}{
+		{provider: workflowsv1alpha1.GithubProvider, header: "X-GitHub-Event", value: "ping", want: "ping"},
+		{provider: workflowsv1alpha1.GitlabProvider, header: "X-Gitlab-Event", value: "Push Hook", want: "push"},
+		{provider: workflowsv1alpha1.BitbucketServerProvider, header: "X-Event-Key", value: "diagnostics:ping", want: "ping"},
+		{provider: workflowsv1alpha1.GiteaProvider, header: "X-Forgejo-Event", value: "release", want: "release"},
+	}
+
+	for _, test := range tests {
+		request := &http.Request{
+			Body:   ioutil.NopCloser(strings.NewReader(`{}`)),
+			Header: http.Header{},
+		}
+		request.Header.Set(test.header, test.value)
+		request.Header.Set("X-Gitlab-Token", "secret")
+		request.Header.Set("X-Hub-Signature", "sha256=abc")
+		request.Header.Set("X-Forgejo-Signature", "abc")
+
+		event, err := ParseWebhookEvent(test.provider, request)
+		if err != nil {
+			t.Fatalf("Unexpected error parsing %s: %v", test.value, err)
+		}
+
+		if event.Name != test.want {
+			t.Errorf("Want event %s, got %s", test.want, event.Name)
+		}
+	}
+}
+
+func TestRejectsEventsDeliveredByTheHooksOfOtherProviders(t *testing.T) {
+	tests := []struct {
+		provider workflowsv1alpha1.Provider
+		header   string
+		value    string
+		want     string
+	}{
+		{provider: workflowsv1alpha1.GithubProvider, header: "X-Gitlab-Event", value: "Push Hook", want: "delivered by a GitLab hook but the workflow's repositories are hosted by github"},
+		{provider: workflowsv1alpha1.GithubProvider, header: "X-Gitea-Event", value: "push", want: "delivered by a Gitea hook"},
+		{provider: workflowsv1alpha1.GitlabProvider, header: "X-GitHub-Event", value: "push", want: "delivered by a Github hook"},
+		{provider: workflowsv1alpha1.GiteaProvider, header: "X-Event-Key", value: "repo:push", want: "delivered by a Bitbucket hook"},
+		{provider: "sourceforge", header: "X-GitHub-Event", value: "push", want: "Events delivered by sourceforge aren't supported"},
+	}
+
+	for _, test := range tests {
+		request := &http.Request{
+			Body:   ioutil.NopCloser(strings.NewReader(`{}`)),
+			Header: http.Header{},
+		}
+		request.Header.Set(test.header, test.value)
+
+		_, err := ParseWebhookEvent(test.provider, request)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("Want error containing %q, got %v", test.want, err)
+		}
+	}
+}
+
+func TestLeavesEventsOfOtherProvidersUntouched(t *testing.T) {
+	completer := NewEventCompleter(Providers{workflowsv1alpha1.GithubProvider: {}})
+
+	event := &github.Event{Name: "push", Changes: []string{"README.md"}}
+	if err := completer.CompleteEvent(context.Background(), newWorkflow(""), event); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(event.Changes) != 1 {
+		t.Errorf("Want changes to be kept, got %v", event.Changes)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 
 	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
 	"golang.org/x/crypto/ssh"
 	corev1 "k8s.io/api/core/v1"
 
@@ -21,6 +22,10 @@ const (
 	// Key that stores Webhook secrets.
 	secretTokenKey = "secret-token"
 
+	// CheckoutTokenKey is the key that stores installation tokens checkout
+	// steps authenticate with.
+	CheckoutTokenKey = "token"
+
 	// Size of private keys.
 	keySize = 4096
 )
@@ -153,3 +158,22 @@ func SetSSHPrivateKeys(deployKeysSecret *corev1.Secret, keyPairs []KeyPair) {
 		deployKeysSecret.Data[keyPair.Repository.GetSSHPrivateKeyName()] = keyPair.PrivateKey
 	}
 }
+
+// OfCheckoutToken constructs a Kubernetes secret object to project the
+// installation token checkout steps of a single run authenticate with. Its
+// name is generated, since it's created before the PipelineRun, and it's
+// owned by the workflow until the PipelineRun takes it over (see
+// SetPipelineRunOwner).
+func OfCheckoutToken(workflow *workflowsv1alpha1.Workflow, token []byte) *corev1.Secret {
+	checkoutToken := newSecret("", workflow)
+	checkoutToken.SetGenerateName(fmt.Sprintf("%s-checkout-token-", workflow.GetName()))
+	checkoutToken.Data[CheckoutTokenKey] = token
+	return checkoutToken
+}
+
+// SetPipelineRunOwner makes the provided Secret object dependent on the
+// PipelineRun in question, so it's garbage collected along with it.
+func SetPipelineRunOwner(secret *corev1.Secret, pipelineRun *pipelinev1beta1.PipelineRun) {
+	ownerRef := metav1.NewControllerRef(pipelineRun, pipelinev1beta1.SchemeGroupVersion.WithKind("PipelineRun"))
+	secret.SetOwnerReferences([]metav1.OwnerReference{*ownerRef})
+}
@@ -5,6 +5,8 @@ import (
 	"testing"
 
 	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestGenerateKeyPair(t *testing.T) {
@@ -49,3 +51,34 @@ func TestGenerateRandomToken(t *testing.T) {
 		}
 	}
 }
+
+func TestOfCheckoutToken(t *testing.T) {
+	workflow := &workflowsv1alpha1.Workflow{ObjectMeta: metav1.ObjectMeta{Name: "ci",
+		Namespace: "dev",
+		UID:       "workflow-uid",
+	},
+	}
+
+	secret := OfCheckoutToken(workflow, []byte("ghs_abc"))
+
+	if secret.GetGenerateName() != "ci-checkout-token-" || secret.GetNamespace() != "dev" {
+		t.Errorf("Want a Secret named after the workflow in its namespace, got %s/%s", secret.GetNamespace(), secret.GetGenerateName())
+	}
+
+	if string(secret.Data[CheckoutTokenKey]) != "ghs_abc" {
+		t.Errorf("Want token ghs_abc, got %s", secret.Data[CheckoutTokenKey])
+	}
+
+	if owners := secret.GetOwnerReferences(); len(owners) != 1 || owners[0].UID != "workflow-uid" {
+		t.Errorf("Want the Secret to be owned by the workflow, got %+v", owners)
+	}
+
+	SetPipelineRunOwner(secret, &pipelinev1beta1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "ci-run-abc12",
+		UID: "pipelinerun-uid",
+	},
+	})
+
+	if owners := secret.GetOwnerReferences(); len(owners) != 1 || owners[0].UID != "pipelinerun-uid" || owners[0].Kind != "PipelineRun" {
+		t.Errorf("Want the Secret to be owned by the PipelineRun, got %+v", owners)
+	}
+}
@@ -0,0 +1,167 @@
+package testreports
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// locationPattern matches references to source files such as
+// pkg/parser_test.go:42 in failure details.
+var locationPattern = regexp.MustCompile(`([A-Za-z0-9_.\-/]+\.[A-Za-z0-9]+):([0-9]+)`)
+
+// Collect parses every JUnit report matching the supplied globs and returns
+// their summary. Globs are relative to dir and support ** to match any
+// number of directories. Failures are located relative to dir.
+func Collect(dir string, globs []string) (*Summary, error) {
+	files, err := FindReports(dir, globs)
+	if err != nil {
+		return nil, err
+	}
+	return Summarize(dir, files)
+}
+
+// Summarize parses the supplied JUnit reports, relative to dir, and returns
+// their summary.
+func Summarize(dir string, files []string) (*Summary, error) {
+	summary := &Summary{}
+	for _, file := range files {
+		testCases, err := parseFile(filepath.Join(dir, file))
+		if err != nil {
+			return nil, fmt.Errorf("Error reading %s: %w", file, err)
+		}
+
+		for _, testCase := range testCases {
+			if testCase.Status == Failed {
+				testCase.File, testCase.Line = locate(dir, testCase)
+			}
+			summary.Add(testCase)
+		}
+	}
+
+	return summary, nil
+}
+
+// FindReports returns paths, relative to dir, of regular files matching any
+// of the supplied globs, sorted alphabetically.
+func FindReports(dir string, globs []string) ([]string, error) {
+	files := make([]string, 0)
+	err := filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		relative, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+
+		relative = filepath.ToSlash(relative)
+		for _, glob := range globs {
+			if Match(glob, relative) {
+				files = append(files, relative)
+				break
+			}
+		}
+		return nil
+	})
+
+	sort.Strings(files)
+	return files, err
+}
+
+// Match returns true if the slash-separated path matches the supplied glob.
+// Besides the syntax supported by path.Match, a ** segment matches any number
+// of directories.
+func Match(glob, name string) bool {
+	return matchSegments(strings.Split(path.Clean(glob), "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(patterns, segments []string) bool {
+	if len(patterns) == 0 {
+		return len(segments) == 0
+	}
+
+	if patterns[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(patterns[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(segments) == 0 {
+		return false
+	}
+
+	if matched, err := path.Match(patterns[0], segments[0]); err != nil || !matched {
+		return false
+	}
+	return matchSegments(patterns[1:], segments[1:])
+}
+
+// locate returns the path, relative to dir, and the line of the source file a
+// failed test case refers to. When reports don't say where test cases are
+// declared, the first reference to an existing file in the failure's message
+// or details is taken instead.
+func locate(dir string, testCase TestCase) (string, int) {
+	if testCase.File != "" {
+		if file, ok := relativeTo(dir, testCase.File); ok {
+			return file, testCase.Line
+		}
+	}
+
+	for _, match := range locationPattern.FindAllStringSubmatch(testCase.Message+"\n"+testCase.Details, -1) {
+		file, ok := relativeTo(dir, match[1])
+		if !ok {
+			continue
+		}
+
+		if info, err := os.Stat(filepath.Join(dir, file)); err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+
+		line, _ := strconv.Atoi(match[2])
+		return file, line
+	}
+
+	return "", 0
+}
+
+// relativeTo returns the supplied file as a slash-separated path relative to
+// dir or false if it lies outside of dir.
+func relativeTo(dir, file string) (string, bool) {
+	if filepath.IsAbs(file) {
+		relative, err := filepath.Rel(dir, file)
+		if err != nil {
+			return "", false
+		}
+		file = relative
+	}
+
+	file = filepath.ToSlash(filepath.Clean(file))
+	if file == "." || file == ".." || strings.HasPrefix(file, "../") {
+		return "", false
+	}
+	return file, true
+}
+
+func parseFile(file string) ([]TestCase, error) {
+	reader, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return ParseJUnit(reader)
+}
@@ -0,0 +1,73 @@
+package testreports
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCollect(t *testing.T) {
+	dir, err := filepath.Abs("testdata/project")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Collect(dir, []string{"build/test-results/**/*.xml"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := &Summary{Passed: 3,
+		Failed:  2,
+		Skipped: 1,
+		Failures: []Failure{
+			{Name: "parser.TestParseComments", File: "pkg/parser_test.go", Line: 27, Message: "expected 2 comments, got 1"},
+			{Name: "parser.TestParseUnicode", File: "pkg/parser.go", Line: 12, Message: "panic"},
+		},
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestCollectWithoutReports(t *testing.T) {
+	got, err := Collect("testdata/project", []string{"reports/*.xml"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if diff := cmp.Diff(&Summary{}, got); diff != "" {
+		t.Errorf("Mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestCollectReturnsAnErrorForInvalidReports(t *testing.T) {
+	if _, err := Collect("testdata/project", []string{"build/**/*.txt"}); err == nil {
+		t.Error("Want an error, got nil")
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		glob string
+		name string
+		want bool
+	}{
+		{"*.xml", "report.xml", true},
+		{"*.xml", "build/report.xml", false},
+		{"build/*.xml", "build/report.xml", true},
+		{"build/**/*.xml", "build/report.xml", true},
+		{"build/**/*.xml", "build/test-results/unit/report.xml", true},
+		{"**/TEST-*.xml", "a/b/TEST-parser.xml", true},
+		{"**/TEST-*.xml", "a/b/parser.xml", false},
+		{"./reports/*.xml", "reports/report.xml", true},
+	}
+
+	for _, test := range tests {
+		if got := Match(test.glob, test.name); test.want != got {
+			t.Errorf("Match(%q, %q): want %t, got %t", test.glob, test.name, test.want, got)
+		}
+	}
+}
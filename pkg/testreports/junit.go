@@ -0,0 +1,114 @@
+package testreports
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Possible outcomes of test cases.
+const (
+	Passed  = "passed"
+	Failed  = "failed"
+	Skipped = "skipped"
+)
+
+// TestCase is the outcome of a single test case read from a JUnit report.
+type TestCase struct {
+
+	// Name of the test case, prefixed by its class name when there is one.
+	Name string
+
+	// File and Line locate the test case when the report provides them.
+	File string
+	Line int
+
+	// Status is one of passed, failed or skipped. Errors count as failures.
+	Status string
+
+	// Message and Details describe why the test case failed.
+	Message string
+	Details string
+}
+
+// junitSuite represents both testsuites and testsuite elements, which may be
+// nested arbitrarily depending on the tool that wrote the report.
+type junitSuite struct {
+	Suites    []junitSuite    `xml:"testsuite"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+// junitTestCase represents testcase elements.
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr"`
+	Line      string        `xml:"line,attr"`
+	Failures  []junitResult `xml:"failure"`
+	Errors    []junitResult `xml:"error"`
+	Skipped   *junitResult  `xml:"skipped"`
+}
+
+// junitResult represents failure, error and skipped elements.
+type junitResult struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// ParseJUnit returns the test cases of the supplied JUnit XML report.
+func ParseJUnit(reader io.Reader) ([]TestCase, error) {
+	root := junitSuite{}
+	if err := xml.NewDecoder(reader).Decode(&root); err != nil {
+		return nil, fmt.Errorf("Invalid JUnit report: %w", err)
+	}
+
+	testCases := make([]TestCase, 0)
+	collectTestCases(&root, &testCases)
+	return testCases, nil
+}
+
+// collectTestCases appends test cases of the supplied suite and its nested
+// suites.
+func collectTestCases(suite *junitSuite, testCases *[]TestCase) {
+	for _, junitTestCase := range suite.TestCases {
+		*testCases = append(*testCases, makeTestCase(junitTestCase))
+	}
+
+	for i := range suite.Suites {
+		collectTestCases(&suite.Suites[i], testCases)
+	}
+}
+
+func makeTestCase(junitTestCase junitTestCase) TestCase {
+	testCase := TestCase{Name: junitTestCase.Name,
+		File:   junitTestCase.File,
+		Status: Passed,
+	}
+
+	if junitTestCase.ClassName != "" {
+		testCase.Name = fmt.Sprintf("%s.%s", junitTestCase.ClassName, junitTestCase.Name)
+	}
+
+	if line, err := strconv.Atoi(junitTestCase.Line); err == nil && line > 0 {
+		testCase.Line = line
+	}
+
+	results := append(junitTestCase.Failures, junitTestCase.Errors...)
+	switch {
+	case len(results) != 0:
+		testCase.Status = Failed
+		testCase.Message = strings.TrimSpace(results[0].Message)
+		testCase.Details = strings.TrimSpace(results[0].Text)
+		if testCase.Message == "" {
+			testCase.Message = strings.TrimSpace(results[0].Type)
+		}
+
+	case junitTestCase.Skipped != nil:
+		testCase.Status = Skipped
+	}
+
+	return testCase
+}
@@ -0,0 +1,61 @@
+package testreports
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseJUnit(t *testing.T) {
+	reader, err := os.Open("testdata/project/build/test-results/unit/TEST-parser.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	got, err := ParseJUnit(reader)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := []TestCase{
+		{Name: "parser.TestParseEmpty", Status: Passed},
+		{Name: "parser.TestParseComments",
+			File:    "pkg/parser_test.go",
+			Line:    27,
+			Status:  Failed,
+			Message: "expected 2 comments, got 1",
+			Details: "parser_test.go:31: expected 2 comments, got 1",
+		},
+		{Name: "parser.TestParseUnicode",
+			Status:  Failed,
+			Message: "panic",
+			Details: "panic: runtime error: index out of range\ngoroutine 7 [running]:\n/root/go/src/runtime/panic.go:88\npkg/parser.go:12 +0x1d",
+		},
+		{Name: "parser.TestParseHuge", Status: Skipped},
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseJUnitWithASingleSuite(t *testing.T) {
+	got, err := ParseJUnit(strings.NewReader(`<testsuite><testcase name="a"/><testcase name="b"><skipped/></testcase></testsuite>`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := []TestCase{{Name: "a", Status: Passed}, {Name: "b", Status: Skipped}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseJUnitReturnsAnErrorForInvalidReports(t *testing.T) {
+	if _, err := ParseJUnit(strings.NewReader("not a report")); err == nil {
+		t.Error("Want an error, got nil")
+	}
+}
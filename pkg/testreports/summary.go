@@ -0,0 +1,104 @@
+package testreports
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// MaxSummaryBytes is the maximum size of encoded summaries. Tekton
+	// publishes task results through termination messages, which are
+	// limited to 4KiB per pod.
+	MaxSummaryBytes = 3072
+
+	// maxMessageLength is the maximum number of characters kept from
+	// messages of failures.
+	maxMessageLength = 200
+)
+
+// Summary is a compact summary of test reports, small enough to be published
+// as a task result.
+type Summary struct {
+	Passed  int `json:"passed"`
+	Failed  int `json:"failed"`
+	Skipped int `json:"skipped"`
+
+	// Failures lists failed test cases. Some may be left out to keep the
+	// summary small, in which case they're counted by OmittedFailures.
+	Failures        []Failure `json:"failures,omitempty"`
+	OmittedFailures int       `json:"omittedFailures,omitempty"`
+}
+
+// Failure describes a failed test case.
+type Failure struct {
+	Name    string `json:"name"`
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// Add counts the supplied test case in the summary.
+func (s *Summary) Add(testCase TestCase) {
+	switch testCase.Status {
+	case Failed:
+		s.Failed++
+		s.Failures = append(s.Failures, Failure{Name: testCase.Name,
+			File:    testCase.File,
+			Line:    testCase.Line,
+			Message: firstLine(testCase.Message, maxMessageLength),
+		})
+
+	case Skipped:
+		s.Skipped++
+
+	default:
+		s.Passed++
+	}
+}
+
+// Encode returns the JSON representation of the summary, leaving out as many
+// failures as needed to fit in the supplied number of bytes.
+func (s *Summary) Encode(maxBytes int) (string, error) {
+	summary := *s
+	for {
+		content, err := json.Marshal(&summary)
+		if err != nil {
+			return "", err
+		}
+
+		if len(content) <= maxBytes || len(summary.Failures) == 0 {
+			return string(content), nil
+		}
+
+		summary.Failures = summary.Failures[:len(summary.Failures)-1]
+		summary.OmittedFailures++
+	}
+}
+
+// Decode parses a summary previously encoded.
+func Decode(value string) (*Summary, error) {
+	summary := &Summary{}
+	if err := json.Unmarshal([]byte(value), summary); err != nil {
+		return nil, fmt.Errorf("Invalid summary of test reports: %w", err)
+	}
+	return summary, nil
+}
+
+// firstLine returns the first non-empty line of the supplied text, truncated
+// to the maximum number of characters in question.
+func firstLine(text string, max int) string {
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if utf8.RuneCountInString(line) > max {
+			return string([]rune(line)[:max-3]) + "..."
+		}
+		return line
+	}
+	return ""
+}
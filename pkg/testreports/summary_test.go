@@ -0,0 +1,72 @@
+package testreports
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSummaryTruncatesMessages(t *testing.T) {
+	summary := &Summary{}
+	summary.Add(TestCase{Name: "TestLongMessage", Status: Failed, Message: "\n  " + strings.Repeat("x", 300) + "\nsecond line"})
+
+	message := summary.Failures[0].Message
+	if len(message) != maxMessageLength || !strings.HasSuffix(message, "...") {
+		t.Errorf("Want the message to be truncated to %d characters, got %q", maxMessageLength, message)
+	}
+}
+
+func TestEncodeAndDecode(t *testing.T) {
+	summary := &Summary{Passed: 10, Skipped: 1}
+	summary.Add(TestCase{Name: "TestParse", File: "parser_test.go", Line: 7, Status: Failed, Message: "boom"})
+
+	encoded, err := summary.Encode(MaxSummaryBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"passed":10,"failed":1,"skipped":1,"failures":[{"name":"TestParse","file":"parser_test.go","line":7,"message":"boom"}]}`
+	if want != encoded {
+		t.Errorf("Want %s, got %s", want, encoded)
+	}
+
+	decoded, err := Decode(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(summary, decoded); diff != "" {
+		t.Errorf("Mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestEncodeOmitsFailuresToFitInTaskResults(t *testing.T) {
+	summary := &Summary{}
+	for i := 0; i < 100; i++ {
+		summary.Add(TestCase{Name: fmt.Sprintf("TestCase%d", i), Status: Failed, Message: strings.Repeat("x", 150)})
+	}
+
+	encoded, err := summary.Encode(MaxSummaryBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(encoded) > MaxSummaryBytes {
+		t.Errorf("Want at most %d bytes, got %d", MaxSummaryBytes, len(encoded))
+	}
+
+	decoded, err := Decode(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.Failed != 100 || len(decoded.Failures)+decoded.OmittedFailures != 100 || decoded.OmittedFailures == 0 {
+		t.Errorf("Want 100 failures, some of them omitted, got %d listed and %d omitted", len(decoded.Failures), decoded.OmittedFailures)
+	}
+
+	if len(summary.Failures) != 100 {
+		t.Errorf("Want the original summary to be left untouched, got %d failures", len(summary.Failures))
+	}
+}
@@ -7,6 +7,10 @@ import (
 	"github.com/nubank/workflows/pkg/github"
 )
 
+// SummaryPath is the file, exposed as $(workflow.summary-path), where steps
+// can append Markdown to be published as the summary of their task.
+const SummaryPath = "/workspace/.workflows/summary.md"
+
 var (
 
 	// Regex to match expressions containing variables such as
@@ -30,10 +34,13 @@ type Replacements struct {
 func MakeReplacements(workflow *workflowsv1alpha1.Workflow, event *github.Event) *Replacements {
 	replacements := &Replacements{
 		specialVariables: map[string]string{
-			"workflow.name":        workflow.GetName(),
-			"workflow.repo.owner":  workflow.Spec.Repository.Owner,
-			"workflow.repo.name":   workflow.Spec.Repository.Name,
-			"workflow.head-commit": event.HeadCommitSHA,
+			"workflow.name":         workflow.GetName(),
+			"workflow.repo.owner":   workflow.Spec.Repository.Owner,
+			"workflow.repo.name":    workflow.Spec.Repository.Name,
+			"workflow.head-commit":  event.HeadCommitSHA,
+			"workflow.base-branch":  event.BaseBranch,
+			"workflow.base-commit":  event.BaseCommitSHA,
+			"workflow.summary-path": SummaryPath,
 		},
 		event: event,
 	}
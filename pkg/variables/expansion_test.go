@@ -5,6 +5,7 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	workflowsv1alpha1 "github.com/nubank/workflows/pkg/apis/workflows/v1alpha1"
+	"github.com/nubank/workflows/pkg/github"
 	"github.com/nubank/workflows/pkg/testutils"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -50,3 +51,45 @@ func TestExpand(t *testing.T) {
 		}
 	}
 }
+
+func TestExpandBaseVariables(t *testing.T) {
+	workflow := &workflowsv1alpha1.Workflow{
+		Spec: workflowsv1alpha1.WorkflowSpec{
+			Repository: &workflowsv1alpha1.Repository{
+				Owner: "john-doe",
+				Name:  "my-repo",
+			},
+		},
+	}
+
+	event := &github.Event{Name: "pull_request",
+		BaseBranch:    "main",
+		BaseCommitSHA: "a1b2c3d",
+		HeadCommitSHA: "833568e",
+	}
+
+	want := "git diff a1b2c3d...833568e (main)"
+	got := Expand("git diff $(workflow.base-commit)...$(workflow.head-commit) ($(workflow.base-branch))", MakeReplacements(workflow, event))
+
+	if want != got {
+		t.Errorf("Want %s, got %s", want, got)
+	}
+}
+
+func TestExpandSummaryPath(t *testing.T) {
+	workflow := &workflowsv1alpha1.Workflow{
+		Spec: workflowsv1alpha1.WorkflowSpec{
+			Repository: &workflowsv1alpha1.Repository{
+				Owner: "john-doe",
+				Name:  "my-repo",
+			},
+		},
+	}
+
+	want := "echo '### Coverage' >> /workspace/.workflows/summary.md"
+	got := Expand("echo '### Coverage' >> $(workflow.summary-path)", MakeReplacements(workflow, &github.Event{Name: "push"}))
+
+	if want != got {
+		t.Errorf("Want %s, got %s", want, got)
+	}
+}